@@ -0,0 +1,161 @@
+// @llr REQ-0-DDLN-SWL-049
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The kinds of the annotation tags, telling what a code file referencing a requirement with the tag
+// does.
+const (
+	// implementsAnnotation is for the requirements the code implements, as with @llr, which are the
+	// parents of the code file.
+	implementsAnnotation = "implements"
+	// partialAnnotation is for the requirements the code implements in part, which are started but
+	// not completed by it.
+	partialAnnotation = "partial"
+	// verifiesAnnotation is for the requirements the code verifies, e.g. as a test, as with
+	// @verifies.
+	verifiesAnnotation = "verifies"
+)
+
+// annotationTags are the kinds of the tags of the code annotations, by tag without the @.
+var annotationTags = map[string]string{
+	"llr":      implementsAnnotation,
+	"verifies": verifiesAnnotation,
+}
+
+// AnnotationConf defines a tag of the code annotations, as one of the "annotations" in
+// attributes.json, e.g. { "tag": "satisfies", "kind": "implements" } for @satisfies.
+type AnnotationConf struct {
+	// Tag is the keyword of the annotation without the @, e.g. hlr or partially-implements.
+	Tag string `json:"tag"`
+	// Kind is implements, partial or verifies, see annotationTags, or none to remove a built-in tag.
+	Kind string `json:"kind"`
+}
+
+var reAnnotationTag = regexp.MustCompile(`^[A-Za-z][\w-]*$`)
+
+// registerAnnotations adds the tags to the annotation tags, replacing the built-in ones of the same
+// names, and compiles the regular expressions of the references again.
+func registerAnnotations(annotations []AnnotationConf) error {
+	for _, a := range annotations {
+		if !reAnnotationTag.MatchString(a.Tag) {
+			return fmt.Errorf("Invalid annotation tag %q", a.Tag)
+		}
+		switch a.Kind {
+		case implementsAnnotation, partialAnnotation, verifiesAnnotation:
+		case "none":
+		default:
+			return fmt.Errorf("Invalid annotation @%s: unknown kind %q", a.Tag, a.Kind)
+		}
+	}
+	for _, a := range annotations {
+		if a.Kind == "none" {
+			delete(annotationTags, a.Tag)
+		} else {
+			annotationTags[a.Tag] = a.Kind
+		}
+	}
+	compileReferences()
+	return nil
+}
+
+// tagsPattern returns the regular expression matching the tags of the kinds, e.g. @(?:hlr|llr),
+// matching nothing if there are none.
+func tagsPattern(kinds ...string) string {
+	var tags []string
+	for tag, kind := range annotationTags {
+		for _, k := range kinds {
+			if kind == k {
+				tags = append(tags, regexp.QuoteMeta(tag))
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return `[^\s\S]`
+	}
+	sort.Strings(tags)
+	return `@(?:` + strings.Join(tags, "|") + `)`
+}
+
+// referencePattern returns the regular expression matching the references of the code with the
+// tags of the implementing annotations, after prefix, e.g. the start of a comment, capturing the
+// ID referenced.
+func referencePattern(prefix string) string {
+	return prefix + tagsPattern(implementsAnnotation, partialAnnotation) + `\s*(` + codeReqID + `)`
+}
+
+// compileReferences compiles the regular expressions of the references for the annotation tags.
+func compileReferences() {
+	reCommentLLRReference = regexp.MustCompile(referencePattern(``))
+	reLLRReference = regexp.MustCompile(referencePattern(`//\s*`) + `.*`)
+	reAdaLLRReference = regexp.MustCompile(referencePattern(`--\s*`))
+	reAsmLLRReference = regexp.MustCompile(referencePattern(`(?:;|#|//)\s*`))
+	reHashCommentLLRReference = regexp.MustCompile(referencePattern(`#\s*`))
+	reMatlabLLRReference = regexp.MustCompile(referencePattern(`%\s*`))
+	reRustLLRReference = regexp.MustCompile(referencePattern(`//[/!]?\s*`))
+	reVerifies = regexp.MustCompile(tagsPattern(verifiesAnnotation) + `\s+(` + codeReqID + `)(#AC\d+\b)?`)
+}
+
+func init() {
+	compileReferences()
+}
+
+// Annotation is a reference of a code file to a requirement with the tag it was made with, a typed
+// edge of the graph.
+type Annotation struct {
+	Tag string `json:"tag"`
+	ID  string `json:"id"`
+}
+
+// Kind returns the kind of the tag of the annotation, see annotationTags.
+func (a Annotation) Kind() string {
+	return annotationTags[a.Tag]
+}
+
+// reTaggedReference matches a reference with its tag, capturing the tag and the ID.
+var reTaggedReference = regexp.MustCompile(`@([A-Za-z][\w-]*)\s*(` + codeReqID + `)`)
+
+// annotationTag returns the tag of the reference to id found in the line, or last if the line
+// doesn't hold its tag, e.g. for the references continuing a list of the previous lines.
+func annotationTag(line, id, last string) string {
+	for _, m := range reTaggedReference.FindAllStringSubmatch(line, -1) {
+		if m[2] == id {
+			if _, ok := annotationTags[m[1]]; ok {
+				return m[1]
+			}
+		}
+	}
+	return last
+}
+
+// resolvePartial links the code file to the requirements it partially implements, and returns the
+// errors of the references to requirements which don't exist, are deleted or may not be
+// referenced by code, one per line.
+func (rg reqGraph) resolvePartial(code *Req) string {
+	errorResult := ""
+	for _, a := range code.Annotations {
+		if a.Kind() != partialAnnotation {
+			continue
+		}
+		r := rg[a.ID]
+		switch {
+		case r == nil:
+			errorResult += "Invalid reference in file " + code.Path + ": " + a.ID + " does not exist.\n"
+		case r.IsDeleted():
+			errorResult += "Invalid reference in file " + code.Path + ": " + a.ID + " is deleted.\n"
+		default:
+			if err := checkCodeReference(code, r); err != "" {
+				errorResult += err
+				continue
+			}
+			code.PartiallyImplements = append(code.PartiallyImplements, r)
+			r.PartiallyImplementedBy = append(r.PartiallyImplementedBy, code)
+		}
+	}
+	return errorResult
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterAnnotations(t *testing.T) {
+	defer func(tags map[string]string) {
+		annotationTags = tags
+		compileReferences()
+	}(annotationTags)
+	annotationTags = map[string]string{"llr": implementsAnnotation, "verifies": verifiesAnnotation}
+
+	assert.NoError(t, registerAnnotations([]AnnotationConf{
+		{Tag: "hlr", Kind: "implements"},
+		{Tag: "satisfies", Kind: "implements"},
+		{Tag: "partially-implements", Kind: "partial"},
+		{Tag: "tests", Kind: "verifies"},
+		{Tag: "verifies", Kind: "none"},
+	}))
+
+	dir, err := ioutil.TempDir("", "annotations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "a.c")
+	// The annotations are split so they are not taken as references of this file.
+	code := "/* @" + "satisfies REQ-0-TEST-SWL-001\n" +
+		" *            REQ-0-TEST-SWL-002 */\n" +
+		"/" + "/ @" + "partially-implements REQ-0-TEST-SWL-003\n" +
+		"/" + "/ @" + "hlr REQ-0-TEST-SWH-001\n" +
+		"/" + "/ @" + "tests REQ-0-TEST-SWL-004\n" +
+		"/" + "/ @" + "verifies REQ-0-TEST-SWL-005\n" +
+		"/" + "/ @" + "llr REQ-0-TEST-SWL-006\n"
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(code), 0644))
+
+	rg := reqGraph{}
+	assert.NoError(t, parseCode("a.c", fileName, codeLanguages[".c"].references(), rg))
+	c := rg[fileName]
+	if !assert.NotNil(t, c) {
+		return
+	}
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002", "REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-006"}, c.ParentIds)
+	assert.Equal(t, []Annotation{
+		{"satisfies", "REQ-0-TEST-SWL-001"},
+		{"satisfies", "REQ-0-TEST-SWL-002"},
+		{"partially-implements", "REQ-0-TEST-SWL-003"},
+		{"hlr", "REQ-0-TEST-SWH-001"},
+		{"llr", "REQ-0-TEST-SWL-006"},
+	}, c.Annotations)
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-004"}, c.VerifiesIds)
+
+	assert.EqualError(t, registerAnnotations([]AnnotationConf{{Tag: "@hlr", Kind: "implements"}}), `Invalid annotation tag "@hlr"`)
+	assert.EqualError(t, registerAnnotations([]AnnotationConf{{Tag: "hlr", Kind: "refines"}}), `Invalid annotation @hlr: unknown kind "refines"`)
+}
+
+func TestReqGraph_ResolvePartial(t *testing.T) {
+	defer func(tags map[string]string) { annotationTags = tags }(annotationTags)
+	annotationTags = map[string]string{"llr": implementsAnnotation, "partially-implements": partialAnnotation}
+
+	graph := func(ids ...string) reqGraph {
+		sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM}
+		high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{sys.ID}}
+		low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, ParentIds: []string{high.ID}}
+		code := &Req{ID: "a.c", Path: "a.c", Level: config.CODE}
+		for _, id := range ids {
+			code.Annotations = append(code.Annotations, Annotation{"partially-implements", id})
+		}
+		return reqGraph{sys.ID: sys, high.ID: high, low.ID: low, code.ID: code}
+	}
+
+	rg := graph("REQ-0-TEST-SWL-001")
+	assert.NoError(t, rg.Resolve())
+	low := rg["REQ-0-TEST-SWL-001"]
+	assert.Equal(t, []*Req{rg["a.c"]}, low.PartiallyImplementedBy)
+	assert.Equal(t, []*Req{low}, rg["a.c"].PartiallyImplements)
+	assert.Empty(t, low.Children)
+	assert.Equal(t, STARTED, low.Status)
+
+	rg = graph("REQ-0-TEST-SWL-002", "REQ-0-TEST-SWH-001")
+	assert.EqualError(t, rg.Resolve(), "Invalid reference in file a.c: REQ-0-TEST-SWL-002 does not exist.\n"+
+		"Invalid reference in file a.c: REQ-0-TEST-SWH-001 is a SWH requirement, code may only reference HWL, SWL.\n\n")
+}
@@ -0,0 +1,122 @@
+// @llr REQ-0-DDLN-SWL-087
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+)
+
+// ApprovalFixes returns the fixes setting the attribute of the requirements of the .md and .toml certification
+// documents to the approval status of their tasks, read back from the task manager keeping the approvals, e.g.
+// Polarion. The requirements without a task, or whose task has no approval status or the one of the attribute
+// already, are left unchanged, as are the deleted ones.
+func (rg reqGraph) ApprovalFixes(attribute string, approvals taskmgr.ApprovalReader) ([]Fix, error) {
+	projects, err := levelProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var reqs []*Req
+	for _, r := range rg {
+		if _, ok := projects[r.Level]; ok && r.Path != "" && !r.IsDeleted() {
+			reqs = append(reqs, r)
+		}
+	}
+	sort.Sort(byPosition(reqs))
+	var fixes []Fix
+	lines := map[string][]string{}
+	for _, r := range reqs {
+		task, err := taskmgr.TaskMgr.FindTask(r.ID, r.Title, projects[r.Level])
+		if err != nil {
+			return nil, fmt.Errorf("Error finding task for requirement %s, caused by\n%v", r.ID, err)
+		}
+		if task == nil {
+			continue
+		}
+		status, err := approvals.ApprovalStatus(task.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the approval of requirement %s, caused by\n%v", r.ID, err)
+		}
+		if status == "" || r.Attributes[strings.ToUpper(attribute)] == status {
+			continue
+		}
+		fileName := r.Path
+		if !strings.HasPrefix(fileName, git.RepoPath()) {
+			// The certification documents are relative to the repository root.
+			fileName = filepath.Join(git.RepoPath(), r.Path)
+		}
+		if _, ok := lines[fileName]; !ok {
+			b, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				return nil, err
+			}
+			lines[fileName] = strings.Split(string(b), "\n")
+		}
+		fixes = append(fixes, approvalFixes(fileName, relativePathToRepo(fileName, git.RepoPath()), lines[fileName], r, attribute, status)...)
+	}
+	return fixes, nil
+}
+
+// levelProjects returns the IDs of the projects of the tasks of the system, high-level and low-level requirements,
+// as created by UpdateTasks.
+func levelProjects() (map[config.RequirementLevel]string, error) {
+	projects := map[config.RequirementLevel]string{}
+	for level, name := range map[config.RequirementLevel]string{
+		config.SYSTEM: config.ProjectName + "-SYS",
+		config.HIGH:   config.ProjectName + "-HLR",
+		config.LOW:    config.ProjectName,
+	} {
+		id, err := taskmgr.TaskMgr.GetProject(name)
+		if err != nil {
+			return nil, err
+		}
+		projects[level] = id
+	}
+	return projects, nil
+}
+
+// approvalFixes returns the fix setting the attribute of the requirement to the approval status of its task, replacing
+// the attribute line of a .md certification document or adding one after the last attribute.
+func approvalFixes(fileName, id string, lines []string, r *Req, attribute, status string) []Fix {
+	finding := fmt.Sprintf("Requirement %s is %s in the task manager, its attribute %s is %q", r.ID, status, attribute, r.Attributes[strings.ToUpper(attribute)])
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".toml":
+		return []Fix{{finding, fmt.Sprintf("%s: set %s = %q in %s, writing the document in its canonical form", id, tomlKey(strings.ToLower(attribute)), status, r.ID),
+			func() error { return addTomlAttribute(fileName, r.ID, strings.ToLower(attribute), status) }}}
+	case ".md":
+		if i := markdownAttribute(lines, r.ID, attribute); i >= 0 {
+			marker := reMarkdownAttribute.FindStringSubmatch(lines[i])[1]
+			name := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(lines[i], marker), ":", 2)[0])
+			return []Fix{lineFix(finding, fileName, id, i, lines[i:i+1], []string{marker + name + ": " + status})}
+		}
+		if i := markdownLastAttribute(lines, r.ID); i >= 0 {
+			marker := reMarkdownAttribute.FindStringSubmatch(lines[i])[1]
+			return []Fix{lineFix(finding, fileName, id, i, lines[i:i+1], []string{lines[i], marker + attribute + ": " + status})}
+		}
+	}
+	return nil
+}
+
+// markdownAttribute returns the index of the line of the attribute of the requirement, named regardless of the case,
+// in the lines of a .md certification document, -1 if it has none.
+func markdownAttribute(lines []string, reqID, attribute string) int {
+	last := markdownLastAttribute(lines, reqID)
+	for i := last; i >= 0; i-- {
+		m := reMarkdownAttribute.FindStringSubmatch(lines[i])
+		if m == nil {
+			break
+		}
+		name := strings.SplitN(strings.TrimPrefix(lines[i], m[1]), ":", 2)[0]
+		if strings.EqualFold(strings.TrimSpace(name), attribute) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalFixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	doc := filepath.Join(dir, "0-TEST-100-SRD.md")
+	md := `## REQ-0-TEST-SWH-001 High
+
+Body.
+
+###### Attributes:
+- approval: waiting
+- Rationale: Because.
+
+## REQ-0-TEST-SWH-002 Other
+
+###### Attributes:
+- Rationale: Because.
+`
+	assert.NoError(t, ioutil.WriteFile(doc, []byte(md), 0644))
+	lines := strings.Split(md, "\n")
+	assert.Equal(t, 5, markdownAttribute(lines, "REQ-0-TEST-SWH-001", "Approval"))
+	assert.Equal(t, -1, markdownAttribute(lines, "REQ-0-TEST-SWH-002", "Approval"))
+
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Attributes: map[string]string{"APPROVAL": "waiting"}}
+	other := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Attributes: map[string]string{}}
+	fixes := approvalFixes(doc, "0-TEST-100-SRD.md", lines, high, "Approval", "approved")
+	fixes = append(fixes, approvalFixes(doc, "0-TEST-100-SRD.md", lines, other, "Approval", "disapproved")...)
+	if assert.Len(t, fixes, 2) {
+		assert.Equal(t, `Requirement REQ-0-TEST-SWH-001 is approved in the task manager, its attribute Approval is "waiting"`, fixes[0].Finding)
+		assert.Equal(t, "0-TEST-100-SRD.md:6\n- - approval: waiting\n+ - approval: approved\n", fixes[0].Change)
+		assert.Equal(t, `Requirement REQ-0-TEST-SWH-002 is disapproved in the task manager, its attribute Approval is ""`, fixes[1].Finding)
+	}
+	for _, f := range fixes {
+		assert.NoError(t, f.Apply())
+	}
+	b, err := ioutil.ReadFile(doc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "- approval: approved\n- Rationale: Because.\n\n## REQ-0-TEST-SWH-002")
+	assert.Contains(t, string(b), "- Rationale: Because.\n- Approval: disapproved\n")
+
+	// The attribute of a .toml document is replaced.
+	toml := filepath.Join(dir, "0-TEST-100-SRD.toml")
+	assert.NoError(t, ioutil.WriteFile(toml, []byte("[[requirement]]\nid = \"REQ-0-TEST-SWH-001\"\ntitle = \"High\"\n\n[requirement.attributes]\napproval = \"waiting\"\n"), 0644))
+	fixes = approvalFixes(toml, "0-TEST-100-SRD.toml", nil, high, "Approval", "approved")
+	if assert.Len(t, fixes, 1) {
+		assert.NoError(t, fixes[0].Apply())
+	}
+	b, err = ioutil.ReadFile(toml)
+	assert.NoError(t, err)
+	assert.Equal(t, "[[requirement]]\nid = \"REQ-0-TEST-SWH-001\"\ntitle = \"High\"\n\n[requirement.attributes]\napproval = \"approved\"\n", string(b))
+}
@@ -0,0 +1,203 @@
+// @llr REQ-0-DDLN-SWL-028
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveConf configures the archive command, the "archive" entry in attributes.json.
+type ArchiveConf struct {
+	// Include are the files and directories archived with the reports, relative to the
+	// repository root, e.g. the baselines, the review records and the waivers.
+	Include []string `json:"include"`
+}
+
+const archiveManifest = "manifest.json"
+
+// archiveFile is a file of an archive.
+type archiveFile struct {
+	Name    string
+	Content []byte
+}
+
+// ArchiveManifest is the index of an archive, its first file. The other files are listed with
+// their SHA-256, so the archive can be checked long after it was written.
+type ArchiveManifest struct {
+	Commit  string                 `json:"commit"`
+	Created time.Time              `json:"created"`
+	Files   []ArchiveManifestEntry `json:"files"`
+}
+
+type ArchiveManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// archiveFormat returns the format of the archive written to fileName, from its extension.
+func archiveFormat(fileName string) (string, error) {
+	switch {
+	case strings.HasSuffix(fileName, ".zip"):
+		return "zip", nil
+	case strings.HasSuffix(fileName, ".tar"):
+		return "tar", nil
+	case strings.HasSuffix(fileName, ".tar.gz"), strings.HasSuffix(fileName, ".tgz"):
+		return "tgz", nil
+	}
+	return "", fmt.Errorf("Unknown archive format of %s, expected .zip, .tar, .tar.gz or .tgz", fileName)
+}
+
+// snapshot returns the requirements and the code files of the graph as json, sorted by ID, with
+// all their fields.
+func (rg reqGraph) snapshot() ([]byte, error) {
+	sorted := make([]*Req, 0, len(rg))
+	for _, r := range rg {
+		sorted = append(sorted, r)
+	}
+	sort.Sort(byIDOrPath(sorted))
+	reqs := make([]map[string]interface{}, len(sorted))
+	for i, r := range sorted {
+		reqs[i] = r.Select(FieldAll)
+	}
+	return json.MarshalIndent(reqs, "", "  ")
+}
+
+// archiveFiles returns the files archived for the graph: a snapshot of the graph as json, the
+// reports, and the included files of the repository at repoPath, which are kept under files/.
+func (rg reqGraph) archiveFiles(repoPath string, conf ArchiveConf, riskConf RiskConf) ([]archiveFile, error) {
+	var files []archiveFile
+
+	b, err := rg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, archiveFile{"graph.json", b})
+
+	reports := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"reports/req-down.html", rg.ReportDown},
+		{"reports/req-up.html", rg.ReportUp},
+		{"reports/req-issues.html", rg.ReportIssues},
+		{"reports/req-risk.html", func(w io.Writer) error { return rg.ReportRisk(w, riskConf, nil, nil) }},
+	}
+	for _, r := range reports {
+		var b bytes.Buffer
+		if err := r.write(&b); err != nil {
+			return nil, fmt.Errorf("Error writing %s: %v", r.name, err)
+		}
+		files = append(files, archiveFile{r.name, b.Bytes()})
+	}
+
+	// The files of the ignore file aren't archived.
+	ignore, err := loadIgnoreRules(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, include := range conf.Include {
+		err := filepath.Walk(filepath.Join(repoPath, include), ignore.walkFunc(repoPath, func(fileName string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			relPath, err := filepath.Rel(repoPath, fileName)
+			if err != nil {
+				return err
+			}
+			b, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				return err
+			}
+			files = append(files, archiveFile{"files/" + filepath.ToSlash(relPath), b})
+			return nil
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+type byIDOrPath []*Req
+
+func (a byIDOrPath) Len() int      { return len(a) }
+func (a byIDOrPath) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byIDOrPath) Less(i, j int) bool {
+	if a[i].ID != a[j].ID {
+		return a[i].ID < a[j].ID
+	}
+	return a[i].Path < a[j].Path
+}
+
+// writeArchive writes the files to w in the given format, zip, tar or tgz, after their
+// manifest.
+func writeArchive(w io.Writer, format, commit string, created time.Time, files []archiveFile) error {
+	manifest := ArchiveManifest{Commit: commit, Created: created.UTC()}
+	for _, f := range files {
+		sum := sha256.Sum256(f.Content)
+		manifest.Files = append(manifest.Files, ArchiveManifestEntry{f.Name, len(f.Content), hex.EncodeToString(sum[:])})
+	}
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	files = append([]archiveFile{{archiveManifest, b}}, files...)
+
+	switch format {
+	case "zip":
+		zw := zip.NewWriter(w)
+		for _, f := range files {
+			h := &zip.FileHeader{Name: f.Name, Method: zip.Deflate}
+			h.SetModTime(created)
+			fw, err := zw.CreateHeader(h)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(f.Content); err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	case "tar", "tgz":
+		var gw *gzip.Writer
+		if format == "tgz" {
+			gw = gzip.NewWriter(w)
+			w = gw
+		}
+		tw := tar.NewWriter(w)
+		for _, f := range files {
+			h := &tar.Header{Name: f.Name, Mode: 0644, Size: int64(len(f.Content)), ModTime: created}
+			if err := tw.WriteHeader(h); err != nil {
+				return err
+			}
+			if _, err := tw.Write(f.Content); err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		if gw != nil {
+			return gw.Close()
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown archive format %q", format)
+}
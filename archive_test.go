@@ -0,0 +1,118 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveFormat(t *testing.T) {
+	for fileName, format := range map[string]string{"a.zip": "zip", "a.tar": "tar", "a.tar.gz": "tgz", "a.tgz": "tgz"} {
+		f, err := archiveFormat(fileName)
+		assert.NoError(t, err)
+		assert.Equal(t, format, f)
+	}
+	_, err := archiveFormat("a.rar")
+	assert.EqualError(t, err, "Unknown archive format of a.rar, expected .zip, .tar, .tar.gz or .tgz")
+}
+
+func TestReqGraph_archiveFiles(t *testing.T) {
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+
+	dir, err := ioutil.TempDir("", "archive")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "reviews"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reviews", "srd.txt"), []byte("Reviewed."), 0644))
+	// The files ignored aren't archived.
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "reviews", "srd.txt~"), []byte("Draft."), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ignoreFileName), []byte("*~\n"), 0644))
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "System"}
+	rg := reqGraph{sys.ID: sys}
+	files, err := rg.archiveFiles(dir, ArchiveConf{Include: []string{"reviews"}}, defaultRiskConf)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"graph.json", "reports/req-down.html", "reports/req-up.html", "reports/req-issues.html",
+		"reports/req-risk.html", "files/reviews/srd.txt"}, names)
+	assert.Contains(t, string(files[0].Content), `"id": "REQ-0-TEST-SYS-001"`)
+	assert.Contains(t, string(files[1].Content), "REQ-0-TEST-SYS-001")
+	assert.Equal(t, "Reviewed.", string(files[5].Content))
+
+	_, err = rg.archiveFiles(dir, ArchiveConf{Include: []string{"waivers"}}, defaultRiskConf)
+	assert.Error(t, err)
+}
+
+// checkManifest checks the manifest is the first of the files read and lists the others.
+func checkManifest(t *testing.T, read map[string][]byte, order []string, files []archiveFile) {
+	if !assert.Equal(t, len(files)+1, len(order)) {
+		return
+	}
+	assert.Equal(t, archiveManifest, order[0])
+	var manifest ArchiveManifest
+	assert.NoError(t, json.Unmarshal(read[archiveManifest], &manifest))
+	assert.Equal(t, "abc123", manifest.Commit)
+	for i, f := range files {
+		sum := sha256.Sum256(read[f.Name])
+		assert.Equal(t, ArchiveManifestEntry{f.Name, len(f.Content), hex.EncodeToString(sum[:])}, manifest.Files[i])
+		assert.Equal(t, f.Content, read[f.Name])
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	files := []archiveFile{{"graph.json", []byte("[]")}, {"files/reviews/srd.txt", []byte("Reviewed.")}}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	var b bytes.Buffer
+	assert.NoError(t, writeArchive(&b, "zip", "abc123", created, files))
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	assert.NoError(t, err)
+	read := map[string][]byte{}
+	var order []string
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		read[f.Name], err = ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		order = append(order, f.Name)
+	}
+	checkManifest(t, read, order, files)
+
+	b.Reset()
+	assert.NoError(t, writeArchive(&b, "tgz", "abc123", created, files))
+	gr, err := gzip.NewReader(&b)
+	assert.NoError(t, err)
+	tr := tar.NewReader(gr)
+	read = map[string][]byte{}
+	order = nil
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		read[h.Name], err = ioutil.ReadAll(tr)
+		assert.NoError(t, err)
+		order = append(order, h.Name)
+	}
+	checkManifest(t, read, order, files)
+}
@@ -0,0 +1,167 @@
+// @llr REQ-0-DDLN-SWL-076
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// AttributeStats is the distribution of the values of an attribute across the requirements.
+type AttributeStats struct {
+	Name string
+	// Required is whether the attributes json requires the attribute.
+	Required bool
+	// Total is the number of requirements which could have the attribute, and Set the number of
+	// those which have it.
+	Total, Set int
+	// Values are the counts of the values, by decreasing count. The parents aren't counted, being the
+	// links rather than values.
+	Values []AttributeValueCount
+	// Invalid are the requirements whose value doesn't match the one required, by ID.
+	Invalid []*Req
+}
+
+// AttributeValueCount is the number of requirements with a value of an attribute.
+type AttributeValueCount struct {
+	Value string
+	Count int
+}
+
+// Completeness is the number and the percentage of the requirements which have the attribute.
+func (s *AttributeStats) Completeness() string {
+	return metricsShare(s.Set, s.Total)
+}
+
+// IncompleteReq is a requirement missing required attributes.
+type IncompleteReq struct {
+	Req     *Req
+	Missing []string
+}
+
+// byValueCount sorts the values by decreasing count, then by value.
+type byValueCount []AttributeValueCount
+
+func (a byValueCount) Len() int      { return len(a) }
+func (a byValueCount) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byValueCount) Less(i, j int) bool {
+	if a[i].Count != a[j].Count {
+		return a[i].Count > a[j].Count
+	}
+	return a[i].Value < a[j].Value
+}
+
+// byIncompleteID sorts the incomplete requirements by ID.
+type byIncompleteID []*IncompleteReq
+
+func (a byIncompleteID) Len() int           { return len(a) }
+func (a byIncompleteID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byIncompleteID) Less(i, j int) bool { return a[i].Req.ID < a[j].Req.ID }
+
+// AttributeStatistics returns the statistics of the attributes of the requirements matching the
+// filter and the diffs, the required ones first, in the order of the attributes json, then the
+// others by name, and the requirements missing required attributes, by ID. The deleted
+// requirements are left out. As in the precommit checks, the system requirements don't need
+// parents.
+func (rg reqGraph) AttributeStatistics(as []map[string]string, f ReqFilter, diffs map[string][]string) ([]*AttributeStats, []*IncompleteReq, error) {
+	var stats []*AttributeStats
+	byName := map[string]*AttributeStats{}
+	required := map[string]*regexp.Regexp{}
+	for _, a := range as {
+		name := strings.ToUpper(a["name"])
+		if name == "" || byName[name] != nil {
+			continue
+		}
+		s := &AttributeStats{Name: a["name"], Required: true}
+		stats = append(stats, s)
+		byName[name] = s
+		if v, ok := a["value"]; ok {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Invalid value %q of the attribute %s: %v", v, a["name"], err)
+			}
+			required[name] = re
+		}
+	}
+
+	var reqs []*Req
+	var others []string
+	for _, r := range rg {
+		if r.Level == config.CODE || r.IsDeleted() || !r.Matches(f, diffs) {
+			continue
+		}
+		reqs = append(reqs, r)
+		for name := range r.Attributes {
+			if byName[name] == nil {
+				byName[name] = &AttributeStats{Name: name}
+				others = append(others, name)
+			}
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		stats = append(stats, byName[name])
+	}
+	sort.Sort(byIDOrPath(reqs))
+
+	var incomplete []*IncompleteReq
+	counts := map[string]map[string]int{}
+	for _, r := range reqs {
+		var missing []string
+		for _, s := range stats {
+			name := strings.ToUpper(s.Name)
+			value, ok := r.Attributes[name]
+			if r.Level != config.SYSTEM || name != "PARENTS" {
+				s.Total++
+				if !ok && s.Required {
+					missing = append(missing, s.Name)
+				}
+			}
+			if !ok {
+				continue
+			}
+			s.Set++
+			if re := required[name]; re != nil && !re.MatchString(value) {
+				s.Invalid = append(s.Invalid, r)
+			}
+			if name != "PARENTS" {
+				if counts[name] == nil {
+					counts[name] = map[string]int{}
+				}
+				counts[name][strings.TrimSpace(value)]++
+			}
+		}
+		if len(missing) > 0 {
+			incomplete = append(incomplete, &IncompleteReq{r, missing})
+		}
+	}
+	for _, s := range stats {
+		for v, n := range counts[strings.ToUpper(s.Name)] {
+			s.Values = append(s.Values, AttributeValueCount{v, n})
+		}
+		sort.Sort(byValueCount(s.Values))
+	}
+	sort.Sort(byIncompleteID(incomplete))
+	return stats, incomplete, nil
+}
+
+type attributesReportData struct {
+	Stats      []*AttributeStats
+	Incomplete []*IncompleteReq
+	Filter     ReqFilter
+}
+
+// ReportAttributes writes an HTML report of the distributions of the values of the attributes of
+// the requirements matching the filter and the diffs, and of the requirements missing required
+// attributes.
+func (rg reqGraph) ReportAttributes(w io.Writer, as []map[string]string, f ReqFilter, diffs map[string][]string) error {
+	stats, incomplete, err := rg.AttributeStatistics(as, f, diffs)
+	if err != nil {
+		return err
+	}
+	return reportTmpl.ExecuteTemplate(w, "ATTRIBUTES", attributesReportData{stats, incomplete, f})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_AttributeStatistics(t *testing.T) {
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Attributes: map[string]string{"SAFETY IMPACT": "Major", "VERIFICATION": "Test"}},
+		{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Attributes: map[string]string{"PARENTS": "REQ-0-TEST-SYS-001", "SAFETY IMPACT": "Major", "VERIFICATION": "Unit test", "URGENT": "Yes"}},
+		{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Attributes: map[string]string{"PARENTS": "REQ-0-TEST-SWH-001", "SAFETY IMPACT": " None", "VERIFICATION": "Review"}},
+		{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Attributes: map[string]string{"VERIFICATION": "Test"}},
+		{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Title: "DELETED"},
+	} {
+		rg[r.ID] = r
+	}
+	rg["/repo/a.go"] = &Req{ID: "a.go", Level: config.CODE}
+	as := []map[string]string{{"name": "Parents"}, {"name": "Verification", "value": "(Unit [Tt]est|[Tt]est)"}, {"name": "Safety Impact"}}
+
+	stats, incomplete, err := rg.AttributeStatistics(as, nil, nil)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 4)
+
+	parents := stats[0]
+	assert.Equal(t, "Parents", parents.Name)
+	// The system requirement doesn't need parents, which aren't counted as values.
+	assert.Equal(t, "2 (66%)", parents.Completeness())
+	assert.Empty(t, parents.Values)
+
+	verification := stats[1]
+	assert.Equal(t, "4 (100%)", verification.Completeness())
+	assert.Equal(t, []AttributeValueCount{{"Test", 2}, {"Review", 1}, {"Unit test", 1}}, verification.Values)
+	assert.Equal(t, []*Req{rg["REQ-0-TEST-SWL-001"]}, verification.Invalid)
+
+	safety := stats[2]
+	assert.Equal(t, "3 (75%)", safety.Completeness())
+	assert.Equal(t, []AttributeValueCount{{"Major", 2}, {"None", 1}}, safety.Values)
+
+	urgent := stats[3]
+	assert.Equal(t, "URGENT", urgent.Name)
+	assert.False(t, urgent.Required)
+	assert.Equal(t, "1 (25%)", urgent.Completeness())
+
+	assert.Equal(t, []*IncompleteReq{{rg["REQ-0-TEST-SWL-002"], []string{"Parents", "Safety Impact"}}}, incomplete)
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportAttributes(&b, as, nil, nil))
+	report := b.String()
+	assert.Contains(t, report, "<strong>Safety Impact</strong> <small>required</small>")
+	assert.Contains(t, report, `<span class="text-danger">3 (75%)</span>`)
+	assert.Contains(t, report, "Major: 2<br>")
+	assert.Contains(t, report, `<span class="text-danger">REQ-0-TEST-SWL-001</span>`)
+
+	// Only the requirements matching the filter are counted.
+	stats, incomplete, err = rg.AttributeStatistics(as, ReqFilter{IdFilter: regexp.MustCompile("SWL")}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "1 (50%)", stats[2].Completeness())
+	assert.Len(t, incomplete, 1)
+
+	_, _, err = rg.AttributeStatistics([]map[string]string{{"name": "Verification", "value": "("}}, nil, nil)
+	assert.Error(t, err)
+}
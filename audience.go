@@ -0,0 +1,276 @@
+// @llr REQ-0-DDLN-SWL-030
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// AudienceConf configures a variant of the top-down report for an audience, as one of the
+// "audiences" in attributes.json. The variant is selected with --audience.
+type AudienceConf struct {
+	// Template is tree for the traceability tree of the requirements, or summary for their
+	// statuses and their counts.
+	Template string `json:"template"`
+	// Types are the types of the requirements shown, e.g. SYS and SWH. The requirements of the
+	// other types are skipped, their children being shown under their parents. All the types
+	// are shown if empty.
+	Types []string `json:"types"`
+	// Fields are the fields of the requirements shown in the tree besides the ID, the title and
+	// the status: body, attributes, attribute:<name>, code, changelists and tasks. All the fields
+	// are shown if missing.
+	Fields []string `json:"fields"`
+}
+
+// defaultAudiences are the audiences available when attributes.json doesn't configure them.
+var defaultAudiences = map[string]AudienceConf{
+	"engineer": {Template: "tree"},
+	"manager":  {Template: "summary"},
+	"customer": {Template: "tree", Types: []string{"SYS", "SWH"}, Fields: []string{"body"}},
+}
+
+var audienceFields = map[string]bool{"body": true, "attributes": true, "code": true, "changelists": true, "tasks": true}
+
+// audienceConf returns the configuration of the audience with the given name, the configured
+// one or else the default one.
+func (c JsonConf) audienceConf(name string) (AudienceConf, error) {
+	a, ok := c.Audiences[name]
+	if !ok {
+		a, ok = defaultAudiences[name]
+	}
+	if !ok {
+		return a, fmt.Errorf("Unknown audience %q", name)
+	}
+	return a, a.validate(name)
+}
+
+// audienceNames returns the names of the audiences, configured or default, sorted.
+func (c JsonConf) audienceNames() []string {
+	var names []string
+	for name := range defaultAudiences {
+		if _, ok := c.Audiences[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name := range c.Audiences {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate checks the template, the types and the fields of the audience.
+func (a AudienceConf) validate(name string) error {
+	if a.Template != "tree" && a.Template != "summary" {
+		return fmt.Errorf("Invalid audience %s: unknown template %q, expected tree or summary", name, a.Template)
+	}
+	for _, t := range a.Types {
+		if _, ok := config.ReqTypeToReqLevel[t]; !ok {
+			return fmt.Errorf("Invalid audience %s: unknown requirement type %q", name, t)
+		}
+	}
+	for _, f := range a.Fields {
+		if !audienceFields[f] && !strings.HasPrefix(f, matrixAttributePrefix) {
+			return fmt.Errorf("Invalid audience %s: unknown field %q", name, f)
+		}
+	}
+	return nil
+}
+
+// shows returns whether the requirement r is shown to the audience.
+func (a AudienceConf) shows(r *Req) bool {
+	if r.Level == config.CODE || r.IsDeleted() {
+		return false
+	}
+	if len(a.Types) == 0 {
+		return true
+	}
+	for _, t := range a.Types {
+		if r.ReqType() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// showsField returns whether the field is shown to the audience.
+func (a AudienceConf) showsField(field string) bool {
+	if a.Fields == nil {
+		return true
+	}
+	for _, f := range a.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// showsAttribute returns whether the attribute, named in upper case, is shown to the audience.
+func (a AudienceConf) showsAttribute(name string) bool {
+	for _, f := range a.Fields {
+		if strings.HasPrefix(f, matrixAttributePrefix) && strings.ToUpper(strings.TrimPrefix(f, matrixAttributePrefix)) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// redact returns a copy of r with only the fields shown to the audience.
+func (a AudienceConf) redact(r *Req) *Req {
+	c := &Req{ID: r.ID, Title: r.Title, Level: r.Level, Path: r.Path, Position: r.Position, Status: r.Status}
+	if a.showsField("body") {
+		c.Body, c.storedBody = r.Body, r.storedBody
+	}
+	for k, v := range r.Attributes {
+		if a.showsField("attributes") || a.showsAttribute(k) {
+			if c.Attributes == nil {
+				c.Attributes = map[string]string{}
+			}
+			c.Attributes[k] = v
+		}
+	}
+	return c
+}
+
+// audienceNode is a requirement of the tree shown to an audience.
+type audienceNode struct {
+	// Req is the redacted requirement, Level -1 if it's shown already elsewhere in the tree.
+	Req      *Req
+	Source   *Req
+	Children []*audienceNode
+	conf     AudienceConf
+}
+
+// Shows returns whether the field of the requirement is shown.
+func (n *audienceNode) Shows(field string) bool {
+	return n.conf.showsField(field)
+}
+
+// IsLow returns whether the requirement is a low-level requirement, implemented by code.
+func (n *audienceNode) IsLow() bool {
+	return n.Source.Level == config.LOW
+}
+
+type audienceReportData struct {
+	Name    string
+	Roots   []*audienceNode
+	Summary []audienceCount
+	Reqs    []*Req
+	Filter  ReqFilter
+	Diffs   map[string][]string
+}
+
+// audienceCount is the number of requirements of a type shown to the audience, by status.
+type audienceCount struct {
+	Type   string
+	Counts [3]int
+	Total  int
+}
+
+// statusSummary counts the requirements by type and by status, the types in the order they're
+// first found.
+func statusSummary(reqs []*Req) []audienceCount {
+	var summary []audienceCount
+	index := map[string]int{}
+	for _, r := range reqs {
+		i, ok := index[r.ReqType()]
+		if !ok {
+			i = len(summary)
+			index[r.ReqType()] = i
+			summary = append(summary, audienceCount{Type: r.ReqType()})
+		}
+		summary[i].Counts[r.Status]++
+		summary[i].Total++
+	}
+	return summary
+}
+
+// shownReqs returns the requirements shown to the audience which match the filter and the diffs.
+func (rg reqGraph) shownReqs(a AudienceConf, f ReqFilter, diffs map[string][]string) map[*Req]bool {
+	shown := map[*Req]bool{}
+	for _, r := range rg {
+		if a.shows(r) && r.Matches(f, diffs) {
+			shown[r] = true
+		}
+	}
+	return shown
+}
+
+// nearest returns the requirements shown reached from r by following the links, through the
+// requirements which are not shown.
+func nearest(r *Req, shown map[*Req]bool, links func(r *Req) []*Req, seen map[*Req]bool) []*Req {
+	var reached []*Req
+	for _, l := range links(r) {
+		if seen[l] {
+			continue
+		}
+		seen[l] = true
+		if shown[l] {
+			reached = append(reached, l)
+			continue
+		}
+		reached = append(reached, nearest(l, shown, links, seen)...)
+	}
+	return reached
+}
+
+func parents(r *Req) []*Req  { return r.Parents }
+func children(r *Req) []*Req { return r.Children }
+
+// ReportAudience writes the variant of the top-down report for the audience named, showing the
+// requirements matching the filter and the diffs.
+func (rg reqGraph) ReportAudience(w io.Writer, name string, a AudienceConf, f ReqFilter, diffs map[string][]string) error {
+	shown := rg.shownReqs(a, f, diffs)
+	var reqs []*Req
+	for r := range shown {
+		reqs = append(reqs, r)
+	}
+	sort.Sort(byLevelModulePosition(reqs))
+	data := audienceReportData{Name: name, Filter: f, Diffs: diffs}
+
+	if a.Template == "summary" {
+		data.Summary = statusSummary(reqs)
+		for _, r := range reqs {
+			data.Reqs = append(data.Reqs, a.redact(r))
+		}
+		return reportTmpl.ExecuteTemplate(w, "SUMMARY", data)
+	}
+
+	once := Oncer{}
+	var node func(r *Req) *audienceNode
+	node = func(r *Req) *audienceNode {
+		n := &audienceNode{Req: once.Once(a.redact(r)), Source: r, conf: a}
+		if n.Req.Level == -1 {
+			return n
+		}
+		next := nearest(r, shown, children, map[*Req]bool{})
+		sort.Sort(byLevelModulePosition(next))
+		for _, c := range next {
+			n.Children = append(n.Children, node(c))
+		}
+		return n
+	}
+	for _, r := range reqs {
+		if len(nearest(r, shown, parents, map[*Req]bool{})) == 0 {
+			data.Roots = append(data.Roots, node(r))
+		}
+	}
+	return reportTmpl.ExecuteTemplate(w, "AUDIENCE", data)
+}
+
+// byLevelModulePosition sorts by level, by certification document and by position.
+type byLevelModulePosition []*Req
+
+func (a byLevelModulePosition) Len() int      { return len(a) }
+func (a byLevelModulePosition) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byLevelModulePosition) Less(i, j int) bool {
+	if a[i].Level != a[j].Level {
+		return a[i].Level < a[j].Level
+	}
+	return byModulePosition(a).Less(i, j)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func audienceGraph() reqGraph {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Position: 1,
+		Title: "System", Body: "System body.", Attributes: map[string]string{"RATIONALE": "Secret rationale"}}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 1,
+		Title: "High", Body: "High body.", Status: STARTED}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 1,
+		Title: "Low", Body: "Low body.", Status: COMPLETED}
+	code := &Req{ID: "a.go", Level: config.CODE, Path: "code/a.go", Status: COMPLETED}
+	sys.Children = []*Req{high}
+	high.Parents = []*Req{sys}
+	high.Children = []*Req{low}
+	low.Parents = []*Req{high}
+	low.Children = []*Req{code}
+	code.Parents = []*Req{low}
+	return reqGraph{sys.ID: sys, high.ID: high, low.ID: low, code.Path: code}
+}
+
+func TestJsonConf_audienceConf(t *testing.T) {
+	a, err := JsonConf{}.audienceConf("customer")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultAudiences["customer"], a)
+
+	conf := JsonConf{Audiences: map[string]AudienceConf{
+		"customer": {Template: "summary"},
+		"auditor":  {Template: "tree", Types: []string{"SWL"}},
+		"bad":      {Template: "tree", Fields: []string{"owner"}},
+	}}
+	a, err = conf.audienceConf("customer")
+	assert.NoError(t, err)
+	assert.Equal(t, "summary", a.Template)
+	assert.Equal(t, []string{"auditor", "bad", "customer", "engineer", "manager"}, conf.audienceNames())
+
+	_, err = conf.audienceConf("investor")
+	assert.EqualError(t, err, `Unknown audience "investor"`)
+	_, err = conf.audienceConf("bad")
+	assert.EqualError(t, err, `Invalid audience bad: unknown field "owner"`)
+	_, err = JsonConf{Audiences: map[string]AudienceConf{"x": {Template: "list"}}}.audienceConf("x")
+	assert.EqualError(t, err, `Invalid audience x: unknown template "list", expected tree or summary`)
+	_, err = JsonConf{Audiences: map[string]AudienceConf{"x": {Template: "tree", Types: []string{"LLR"}}}}.audienceConf("x")
+	assert.EqualError(t, err, `Invalid audience x: unknown requirement type "LLR"`)
+}
+
+func TestReqGraph_ReportAudience(t *testing.T) {
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	rg := audienceGraph()
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportAudience(&b, "engineer", defaultAudiences["engineer"], nil, nil))
+	report := b.String()
+	for _, s := range []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-001", "Low body.", "Secret rationale", "file://code/a.go"} {
+		assert.Contains(t, report, s)
+	}
+
+	b.Reset()
+	assert.NoError(t, rg.ReportAudience(&b, "customer", defaultAudiences["customer"], nil, nil))
+	report = b.String()
+	for _, s := range []string{"REQ-0-TEST-SYS-001", "System body.", "REQ-0-TEST-SWH-001", "High body."} {
+		assert.Contains(t, report, s)
+	}
+	for _, s := range []string{"REQ-0-TEST-SWL-001", "Secret rationale", "a.go", "Changelists", "Problem Reports"} {
+		assert.NotContains(t, report, s)
+	}
+	assert.True(t, strings.Index(report, "REQ-0-TEST-SYS-001") < strings.Index(report, "REQ-0-TEST-SWH-001"))
+
+	// The low-level requirement is shown under the system requirement, through the hidden high-level one.
+	b.Reset()
+	a := AudienceConf{Template: "tree", Types: []string{"SYS", "SWL"}, Fields: []string{"attribute:Rationale"}}
+	assert.NoError(t, rg.ReportAudience(&b, "auditor", a, nil, nil))
+	report = b.String()
+	assert.True(t, regexp.MustCompile(`(?s)REQ-0-TEST-SYS-001.*Secret rationale.*<ul>.*REQ-0-TEST-SWL-001`).MatchString(report))
+	assert.NotContains(t, report, "REQ-0-TEST-SWH-001")
+	assert.NotContains(t, report, "System body.")
+
+	b.Reset()
+	assert.NoError(t, rg.ReportAudience(&b, "manager", defaultAudiences["manager"], nil, nil))
+	report = b.String()
+	assert.True(t, regexp.MustCompile(`(?s)<strong>SWH</strong></td>\s*<td>0</td><td>1</td><td>0</td>\s*<td><strong>1</strong>`).MatchString(report))
+	assert.NotContains(t, report, "System body.")
+	assert.NotContains(t, report, "a.go")
+
+	b.Reset()
+	f := ReqFilter{IdFilter: regexp.MustCompile("SWH")}
+	assert.NoError(t, rg.ReportAudience(&b, "customer", defaultAudiences["customer"], f, nil))
+	report = b.String()
+	assert.Contains(t, report, "REQ-0-TEST-SWH-001")
+	assert.NotContains(t, report, "REQ-0-TEST-SYS-001")
+}
@@ -0,0 +1,135 @@
+// @llr REQ-0-DDLN-SWL-058
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// Badge is a status badge of the traceability, as the shields shown on the landing pages of the
+// repositories: a label and a message on a background of the color of the status.
+type Badge struct {
+	Label   string
+	Message string
+	// Color is the background color of the message, as in SVG.
+	Color string
+}
+
+// The colors of the badges, by status.
+const (
+	badgeGood    = "#4c1"
+	badgeWarning = "#dfb317"
+	badgeBad     = "#e05d44"
+	badgeUnknown = "#9f9f9f"
+)
+
+// Badges returns the badges of the graph, by name: the shares of the low-level requirements
+// implemented by code and verified, and whether the graph was built without issues.
+func (rg reqGraph) Badges(valid bool) map[string]Badge {
+	var llrs, implemented, verified int
+	for _, r := range rg {
+		if r.Level != config.LOW || r.IsDeleted() {
+			continue
+		}
+		llrs++
+		for _, c := range r.Children {
+			if c.Level == config.CODE {
+				implemented++
+				break
+			}
+		}
+		if len(r.VerifiedBy) > 0 || len(r.TestCases) > 0 {
+			verified++
+		}
+	}
+	validation := Badge{"validation", "passing", badgeGood}
+	if !valid {
+		validation = Badge{"validation", "failing", badgeBad}
+	}
+	return map[string]Badge{
+		"llr-coverage":     percentBadge("LLR code coverage", implemented, llrs),
+		"llr-verification": percentBadge("LLR verification", verified, llrs),
+		"validation":       validation,
+	}
+}
+
+// percentBadge returns the badge of the share of n in total, green from 90% and yellow from 75%.
+func percentBadge(label string, n, total int) Badge {
+	if total == 0 {
+		return Badge{label, "n/a", badgeUnknown}
+	}
+	percent := n * 100 / total
+	color := badgeBad
+	switch {
+	case percent >= 90:
+		color = badgeGood
+	case percent >= 75:
+		color = badgeWarning
+	}
+	return Badge{label, fmt.Sprintf("%d%%", percent), color}
+}
+
+var badgeTmpl = template.Must(template.New("badge").Parse(
+	`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{html .Label}}: {{html .Message}}">
+<title>{{html .Label}}: {{html .Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{html .Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="14">{{html .Label}}</text>
+<text x="{{.MessageX}}" y="14">{{html .Message}}</text>
+</g>
+</svg>
+`))
+
+// badgeLayout is the data of badgeTmpl, the widths being estimated from the number of characters.
+type badgeLayout struct {
+	Badge
+	Width, LabelWidth, MessageWidth int
+	LabelX, MessageX                int
+}
+
+// WriteSVG writes the badge as SVG.
+func (b Badge) WriteSVG(w io.Writer) error {
+	l := badgeLayout{Badge: b, LabelWidth: textWidth(b.Label), MessageWidth: textWidth(b.Message)}
+	l.Width = l.LabelWidth + l.MessageWidth
+	l.LabelX, l.MessageX = l.LabelWidth/2, l.LabelWidth+l.MessageWidth/2
+	return badgeTmpl.Execute(w, l)
+}
+
+// textWidth returns the width of the text in the badges, with its padding.
+func textWidth(s string) int {
+	return 7*utf8.RuneCountInString(s) + 10
+}
+
+// WriteBadges writes the badges of the graph as SVG files in dir, named after the badges, e.g.
+// llr-coverage.svg, see Badges.
+func (rg reqGraph) WriteBadges(dir string, valid bool) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, b := range rg.Badges(valid) {
+		f, err := os.Create(filepath.Join(dir, name+".svg"))
+		if err != nil {
+			return err
+		}
+		if err := b.WriteSVG(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_Badges(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{sys.ID}}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	for i, id := range []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002", "REQ-0-TEST-SWL-003", "REQ-0-TEST-SWL-004"} {
+		r := &Req{ID: id, Level: config.LOW, ParentIds: []string{high.ID}}
+		rg[id] = r
+		if i < 3 {
+			rg["code/"+id+".go"] = &Req{ID: id + ".go", Path: "code/" + id + ".go", Level: config.CODE, ParentIds: []string{id}}
+		}
+		if i < 2 {
+			rg["code/"+id+"_test.go"] = &Req{ID: id + "_test.go", Path: "code/" + id + "_test.go", Level: config.CODE, VerifiesIds: []string{id}}
+		}
+	}
+	rg["REQ-0-TEST-SWL-005"] = &Req{ID: "REQ-0-TEST-SWL-005", Level: config.LOW, Title: "DELETED", ParentIds: []string{high.ID}}
+	assert.NoError(t, rg.Resolve())
+
+	assert.Equal(t, map[string]Badge{
+		"llr-coverage":     {"LLR code coverage", "75%", badgeWarning},
+		"llr-verification": {"LLR verification", "50%", badgeBad},
+		"validation":       {"validation", "failing", badgeBad},
+	}, rg.Badges(false))
+	assert.Equal(t, Badge{"validation", "passing", badgeGood}, rg.Badges(true)["validation"])
+	assert.Equal(t, Badge{"LLR code coverage", "n/a", badgeUnknown}, reqGraph{}.Badges(true)["llr-coverage"])
+
+	var b bytes.Buffer
+	assert.NoError(t, Badge{"R&D", "96%", badgeGood}.WriteSVG(&b))
+	svg := b.String()
+	assert.Contains(t, svg, `<svg xmlns="http://www.w3.org/2000/svg" width="62" height="20"`)
+	assert.Contains(t, svg, `<text x="15" y="14">R&amp;D</text>`)
+	assert.Contains(t, svg, `<rect x="31" width="31" height="20" fill="#4c1"/>`)
+}
@@ -0,0 +1,155 @@
+// @llr REQ-0-DDLN-SWL-093
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// Baseline is the artifact of a requirements baseline, formally identifying the requirements of a
+// certification milestone: the commit tagged, the SHA-256 of the certification documents and the
+// code files of the graph, and the snapshot of the graph, as the graph.json of the archives.
+type Baseline struct {
+	Name    string                 `json:"name"`
+	Tag     string                 `json:"tag"`
+	Commit  string                 `json:"commit"`
+	Created time.Time              `json:"created"`
+	Files   []ArchiveManifestEntry `json:"files"`
+	Graph   json.RawMessage        `json:"graph"`
+}
+
+// baselineTagPrefix prefixes the names of the git tags of the baselines, e.g. baseline/SOI-2.
+const baselineTagPrefix = "baseline/"
+
+// reBaselineName matches the names of the baselines, valid in the names of their git tags.
+var reBaselineName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validBaselineName returns an error if the name of the baseline isn't valid.
+func validBaselineName(name string) error {
+	if !reBaselineName.MatchString(name) || strings.HasSuffix(name, ".lock") || strings.Contains(name, "..") {
+		return fmt.Errorf("Invalid baseline name %q, expected letters, digits, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+// repoFile returns the path relative to the repository of the file of the requirement or the code
+// file. The paths of the documents parsed are rooted at the repository, e.g.
+// /certdocs/0-DDLN-100-ORD.md, and the code files are identified by their relative path.
+func repoFile(r *Req) string {
+	if r.Level == config.CODE {
+		return filepath.ToSlash(r.ID)
+	}
+	return strings.TrimPrefix(filepath.ToSlash(r.Path), "/")
+}
+
+// newBaseline returns the baseline of the graph at the given commit, the files of the graph being
+// read from the repository at repoPath.
+func (rg reqGraph) newBaseline(name, repoPath, commit string, created time.Time) (*Baseline, error) {
+	graph, err := rg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{Name: name, Tag: baselineTagPrefix + name, Commit: commit, Created: created.UTC(), Graph: graph}
+	paths := map[string]bool{}
+	for _, r := range rg {
+		if p := repoFile(r); p != "" {
+			paths[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	for _, p := range sorted {
+		content, err := ioutil.ReadFile(filepath.Join(repoPath, p))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		b.Files = append(b.Files, ArchiveManifestEntry{Path: p, Size: len(content), SHA256: hex.EncodeToString(sum[:])})
+	}
+	return b, nil
+}
+
+// baselineArtifactSuffix suffixes the names of the artifacts of the baselines written by default.
+const baselineArtifactSuffix = ".baseline.json"
+
+// uncommittedChanges returns the files changed in the working tree of the repository at repoPath,
+// other than the artifacts of the baselines, which aren't committed, the tag identifying the
+// baseline in the repository, and the artifact written to fileName.
+func uncommittedChanges(changed []string, repoPath, fileName string) []string {
+	output := ""
+	if abs, err := filepath.Abs(fileName); err == nil {
+		if rel, err := filepath.Rel(repoPath, abs); err == nil {
+			output = filepath.ToSlash(rel)
+		}
+	}
+	var res []string
+	for _, p := range changed {
+		if !strings.HasSuffix(p, baselineArtifactSuffix) && p != output {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// CreateBaseline tags the head commit as the baseline with the given name, and writes the artifact
+// of the baseline to fileName, the message of the tag giving its SHA-256. The working tree must be
+// clean but for the artifacts of the baselines, so the files of the artifact are those of the
+// commit tagged.
+func (rg reqGraph) CreateBaseline(name, fileName string) (*Baseline, error) {
+	if err := validBaselineName(name); err != nil {
+		return nil, err
+	}
+	changed, err := git.FilesChangedInWorkTree()
+	if err != nil {
+		return nil, err
+	}
+	if changed = uncommittedChanges(changed, git.RepoPath(), fileName); len(changed) > 0 {
+		return nil, fmt.Errorf("Uncommitted changes, commit or stash them before creating the baseline:\n\t%s", strings.Join(changed, "\n\t"))
+	}
+	commit, err := git.HeadCommit()
+	if err != nil {
+		return nil, err
+	}
+	b, err := rg.newBaseline(name, git.RepoPath(), commit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	// The artifact is written before the commit is tagged, and renamed once tagged, so a baseline
+	// is either tagged with its artifact or not created.
+	if err := ioutil.WriteFile(fileName+".tmp", content, 0644); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	message := fmt.Sprintf("Requirements baseline %s\n\n%s sha256:%s\n", name, filepath.Base(fileName), hex.EncodeToString(sum[:]))
+	if err := git.Tag(b.Tag, message); err != nil {
+		os.Remove(fileName + ".tmp")
+		return nil, fmt.Errorf("Error tagging the baseline %s: %v", b.Tag, err)
+	}
+	if err := os.Rename(fileName+".tmp", fileName); err != nil {
+		os.Remove(fileName + ".tmp")
+		if tagErr := git.DeleteTag(b.Tag); tagErr != nil {
+			return nil, fmt.Errorf("Error writing the artifact of the baseline %s: %v, and deleting its tag %s: %v", name, err, b.Tag, tagErr)
+		}
+		return nil, err
+	}
+	return b, nil
+}
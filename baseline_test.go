@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidBaselineName(t *testing.T) {
+	assert.NoError(t, validBaselineName("SOI-2"))
+	assert.NoError(t, validBaselineName("v1.2_rc"))
+	for _, name := range []string{"", "-a", "a b", "a/b", "a..b", "a.lock"} {
+		assert.Error(t, validBaselineName(name), name)
+	}
+}
+
+func TestReqGraph_newBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "certdocs"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "certdocs", "0-TEST-211-SRD.md"), []byte("srd"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.go"), []byte("code"), 0644))
+
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "/certdocs/0-TEST-211-SRD.md", Title: "High"}
+	other := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Title: "Other"}
+	code := &Req{ID: "a.go", Level: config.CODE, Path: filepath.Join(dir, "a.go")}
+	rg := reqGraph{high.ID: high, other.ID: other, code.Path: code}
+	created := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	b, err := rg.newBaseline("SOI-2", dir, "abc123", created)
+	assert.NoError(t, err)
+	assert.Equal(t, "baseline/SOI-2", b.Tag)
+	assert.Equal(t, "abc123", b.Commit)
+	assert.Equal(t, created, b.Created)
+	assert.Equal(t, []ArchiveManifestEntry{
+		{Path: "a.go", Size: 4, SHA256: "5694d08a2e53ffcae0c3103e5ad6f6076abd960eb1f8a56577040bc1028f702b"},
+		{Path: "certdocs/0-TEST-211-SRD.md", Size: 3, SHA256: "93af2521b866413b9a8765190a46c435062f6b2d1b3a9949f1f9fa3ae41164eb"},
+	}, b.Files)
+	snapshot, err := decodeSnapshot(b.Graph, "SOI-2.baseline.json")
+	assert.NoError(t, err)
+	assert.Len(t, snapshot, 3)
+
+	// The files of the graph are read.
+	assert.NoError(t, os.Remove(filepath.Join(dir, "a.go")))
+	_, err = rg.newBaseline("SOI-2", dir, "abc123", created)
+	assert.Error(t, err)
+}
+
+func TestUncommittedChanges(t *testing.T) {
+	changed := []string{"req.go", "SOI-1.baseline.json", "records/SOI-2.json", "records/other.json"}
+	assert.Equal(t, []string{"req.go", "records/other.json"}, uncommittedChanges(changed, "/repo", "/repo/records/SOI-2.json"))
+	assert.Equal(t, []string{"req.go", "records/SOI-2.json", "records/other.json"}, uncommittedChanges(changed, "/repo", "/tmp/SOI-2.json"))
+	assert.Empty(t, uncommittedChanges([]string{"SOI-2.baseline.json"}, "/repo", "SOI-2.baseline.json"))
+}
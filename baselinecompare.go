@@ -0,0 +1,197 @@
+// @llr REQ-0-DDLN-SWL-094
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// The kinds of the changes of the files of the baselines.
+const (
+	fileAdded   = "added"
+	fileRemoved = "removed"
+	fileChanged = "changed"
+)
+
+// BaselineComparison is the change report of a baseline from an older one, for the change control
+// board.
+type BaselineComparison struct {
+	From, To *Baseline
+	// Reqs are the requirements added, deleted and modified, see DiffFrom.
+	Reqs []ReqDiff
+	// Reparented are the requirements modified whose parents changed, with their old and new
+	// parents.
+	Reparented []ReqDiff
+	// Files are the certification documents and the code files added, removed and changed, sorted
+	// by path.
+	Files []FileChange
+	// code are the paths of the code files of the graphs.
+	code map[string]bool
+}
+
+// FileChange is a file of the baselines added, removed, or changed as identified by its SHA-256.
+type FileChange struct {
+	Kind, Path string
+}
+
+// baselineNode is a requirement or a code file of the snapshot of a baseline.
+type baselineNode struct {
+	ID         string            `json:"id"`
+	Level      int               `json:"level"`
+	Path       string            `json:"path"`
+	Title      string            `json:"title"`
+	Body       string            `json:"body"`
+	Attributes map[string]string `json:"attributes"`
+	Parents    []string          `json:"parents"`
+}
+
+// loadBaseline reads the artifact of the baseline in the given file or, if there's none, in the
+// <name>.baseline.json file of the baseline with the given name.
+func loadBaseline(fileOrName string) (*Baseline, error) {
+	fileName := fileOrName
+	if _, err := os.Stat(fileName); os.IsNotExist(err) {
+		fileName = fileOrName + baselineArtifactSuffix
+	}
+	content, err := ioutil.ReadFile(fileName)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No baseline artifact %s nor %s.baseline.json", fileOrName, fileOrName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("Error while parsing the baseline %s: %v", fileName, err)
+	}
+	return &b, nil
+}
+
+// graph returns the graph of the snapshot of the baseline, with the fields DiffFrom compares.
+func (b *Baseline) graph() (reqGraph, error) {
+	var nodes []baselineNode
+	if err := json.Unmarshal(b.Graph, &nodes); err != nil {
+		return nil, fmt.Errorf("Error while parsing the graph of the baseline %s: %v", b.Name, err)
+	}
+	rg := reqGraph{}
+	for _, n := range nodes {
+		rg[n.ID] = &Req{ID: n.ID, Level: config.RequirementLevel(n.Level), Path: n.Path, Title: n.Title,
+			Body: template.HTML(n.Body), Attributes: n.Attributes, ParentIds: n.Parents}
+	}
+	return rg, nil
+}
+
+// CompareBaselines returns the changes of the baseline to from the baseline from.
+func CompareBaselines(from, to *Baseline) (*BaselineComparison, error) {
+	old, err := from.graph()
+	if err != nil {
+		return nil, err
+	}
+	rg, err := to.graph()
+	if err != nil {
+		return nil, err
+	}
+	c := &BaselineComparison{From: from, To: to, Reqs: rg.DiffFrom(old), code: map[string]bool{}}
+	for _, d := range c.Reqs {
+		for _, f := range d.Fields {
+			if f.Name == "parents" {
+				c.Reparented = append(c.Reparented, ReqDiff{Kind: d.Kind, ID: d.ID, Title: d.Title, Fields: []FieldDiff{f}})
+			}
+		}
+	}
+	for _, g := range []reqGraph{old, rg} {
+		for _, r := range g {
+			if r.Level == config.CODE {
+				c.code[repoFile(r)] = true
+			}
+		}
+	}
+
+	oldFiles, newFiles := map[string]string{}, map[string]string{}
+	for _, f := range from.Files {
+		oldFiles[f.Path] = f.SHA256
+	}
+	for _, f := range to.Files {
+		newFiles[f.Path] = f.SHA256
+	}
+	for path, sum := range newFiles {
+		if oldSum, ok := oldFiles[path]; !ok {
+			c.Files = append(c.Files, FileChange{fileAdded, path})
+		} else if oldSum != sum {
+			c.Files = append(c.Files, FileChange{fileChanged, path})
+		}
+	}
+	for path := range oldFiles {
+		if _, ok := newFiles[path]; !ok {
+			c.Files = append(c.Files, FileChange{fileRemoved, path})
+		}
+	}
+	sort.Slice(c.Files, func(i, j int) bool { return c.Files[i].Path < c.Files[j].Path })
+	return c, nil
+}
+
+// Write writes the change report, the changes grouped by category, then the counts.
+func (c *BaselineComparison) Write(w io.Writer) {
+	fmt.Fprintf(w, "Baseline %s (commit %s) compared with baseline %s (commit %s)\n", c.To.Name, c.To.Commit, c.From.Name, c.From.Commit)
+	counts := map[string]int{}
+	byKind := map[string][]ReqDiff{}
+	for _, d := range c.Reqs {
+		counts[d.Kind]++
+		byKind[d.Kind] = append(byKind[d.Kind], d)
+	}
+	for _, s := range []struct {
+		title string
+		diffs []ReqDiff
+	}{
+		{"New requirements", byKind[reqAdded]},
+		{"Deleted requirements", byKind[reqDeleted]},
+		{"Modified requirements", byKind[reqModified]},
+		{"Re-parented requirements", c.Reparented},
+	} {
+		if len(s.diffs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (%d)\n", s.title, len(s.diffs))
+		for _, d := range s.diffs {
+			fmt.Fprintf(w, "\t%s %s\n", d.ID, d.Title)
+			for _, f := range d.Fields {
+				if f.Lines != nil {
+					fmt.Fprintf(w, "\t\t%s:\n", f.Name)
+					for _, l := range f.Lines {
+						fmt.Fprintf(w, "\t\t\t%s\n", l)
+					}
+					continue
+				}
+				fmt.Fprintf(w, "\t\t%s: %q -> %q\n", f.Name, f.Old, f.New)
+			}
+		}
+	}
+	var code, docs []FileChange
+	for _, f := range c.Files {
+		if c.code[f.Path] {
+			code = append(code, f)
+		} else {
+			docs = append(docs, f)
+		}
+	}
+	for _, s := range []struct {
+		title string
+		files []FileChange
+	}{{"Changed code files", code}, {"Changed certification documents", docs}} {
+		if len(s.files) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (%d)\n", s.title, len(s.files))
+		for _, f := range s.files {
+			fmt.Fprintf(w, "\t%s %s\n", f.Kind, f.Path)
+		}
+	}
+	fmt.Fprintf(w, "\n%d new, %d deleted, %d modified, %d re-parented requirements, %d code files and %d documents changed\n",
+		counts[reqAdded], counts[reqDeleted], counts[reqModified], len(c.Reparented), len(code), len(docs))
+}
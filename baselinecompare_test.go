@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareBaselines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	write := func(name, content string) {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "/0-TEST-100-ORD.md", Title: "System"}
+	other := &Req{ID: "REQ-0-TEST-SYS-002", Level: config.SYSTEM, Path: "/0-TEST-100-ORD.md", Title: "Other"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "/0-TEST-211-SRD.md", Title: "High", Body: "The RMT SHALL parse.",
+		Attributes: map[string]string{"VERIFICATION": "Test"}, Parents: []*Req{sys}}
+	gone := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "/0-TEST-211-SRD.md", Title: "Gone", Parents: []*Req{sys}}
+	code := &Req{ID: "a.go", Level: config.CODE, Path: "/repo/a.go", Parents: []*Req{high}}
+	write("0-TEST-100-ORD.md", "ord")
+	write("0-TEST-211-SRD.md", "srd")
+	write("a.go", "code")
+	from, err := reqGraph{sys.ID: sys, other.ID: other, high.ID: high, gone.ID: gone, code.ID: code}.newBaseline("SOI-2", dir, "abc123", time.Now())
+	assert.NoError(t, err)
+
+	newHigh := &Req{ID: high.ID, Level: config.HIGH, Path: high.Path, Title: "High", Body: "The RMT SHALL parse fast.",
+		Attributes: map[string]string{"VERIFICATION": "Test"}, Parents: []*Req{other}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "/0-TEST-212-SDD.md", Title: "Low", Parents: []*Req{newHigh}}
+	write("0-TEST-211-SRD.md", "srd changed")
+	write("0-TEST-212-SDD.md", "sdd")
+	write("a.go", "code changed")
+	to, err := reqGraph{sys.ID: sys, other.ID: other, high.ID: newHigh, low.ID: low, code.ID: code}.newBaseline("SOI-3", dir, "def456", time.Now())
+	assert.NoError(t, err)
+
+	c, err := CompareBaselines(from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, []FileChange{{fileChanged, "0-TEST-211-SRD.md"}, {fileAdded, "0-TEST-212-SDD.md"}, {fileChanged, "a.go"}}, c.Files)
+	var b bytes.Buffer
+	c.Write(&b)
+	assert.Equal(t, `Baseline SOI-3 (commit def456) compared with baseline SOI-2 (commit abc123)
+
+New requirements (1)
+	REQ-0-TEST-SWL-001 Low
+
+Deleted requirements (1)
+	REQ-0-TEST-SWH-002 Gone
+
+Modified requirements (1)
+	REQ-0-TEST-SWH-001 High
+		body:
+			-The RMT SHALL parse.
+			+The RMT SHALL parse fast.
+		parents: "REQ-0-TEST-SYS-001" -> "REQ-0-TEST-SYS-002"
+
+Re-parented requirements (1)
+	REQ-0-TEST-SWH-001 High
+		parents: "REQ-0-TEST-SYS-001" -> "REQ-0-TEST-SYS-002"
+
+Changed code files (1)
+	changed a.go
+
+Changed certification documents (2)
+	changed 0-TEST-211-SRD.md
+	added 0-TEST-212-SDD.md
+
+1 new, 1 deleted, 1 modified, 1 re-parented requirements, 1 code files and 2 documents changed
+`, b.String())
+
+	// The artifacts are found by the names of the baselines.
+	content, err := json.Marshal(to)
+	assert.NoError(t, err)
+	write("SOI-3.baseline.json", string(content))
+	loaded, err := loadBaseline(filepath.Join(dir, "SOI-3"))
+	assert.NoError(t, err)
+	assert.Equal(t, to.Files, loaded.Files)
+	_, err = loadBaseline(filepath.Join(dir, "SOI-4"))
+	assert.EqualError(t, err, "No baseline artifact "+filepath.Join(dir, "SOI-4")+" nor "+filepath.Join(dir, "SOI-4")+".baseline.json")
+}
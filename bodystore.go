@@ -0,0 +1,80 @@
+// @llr REQ-0-DDLN-SWL-070
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// bodies is the store the bodies of the requirements parsed are moved to, to keep them out of
+// memory, nil to keep them in the requirements, as set with --low-memory.
+var bodies *bodyStore
+
+// bodyStore keeps the bodies of the requirements in a temporary file, for the repositories too
+// large for the memory of the CI runners. The file is removed at once, so it's not left behind
+// whichever way the command exits, and its space is freed when the process ends.
+type bodyStore struct {
+	f    *os.File
+	size int64
+}
+
+// bodyLocation is the location of a body in the store.
+type bodyLocation struct {
+	offset, length int64
+}
+
+// newBodyStore creates an empty store in the temporary directory.
+func newBodyStore() (*bodyStore, error) {
+	f, err := ioutil.TempFile("", "reqtraq-bodies-")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &bodyStore{f: f}, nil
+}
+
+// put moves the body of the requirement to the store, see LoadBody.
+func (s *bodyStore) put(r *Req) error {
+	if r.Body == "" {
+		return nil
+	}
+	n, err := s.f.WriteAt([]byte(r.Body), s.size)
+	if err != nil {
+		return err
+	}
+	r.storedBody = &bodyLocation{s.size, int64(n)}
+	r.Body = ""
+	s.size += int64(n)
+	return nil
+}
+
+// LoadBody returns the body of the requirement, read from the store if it was moved there, see
+// bodies. The bodies are read on each call rather than kept. If the store can't be read, the body
+// is marked unavailable, so the rest of the reports can still be generated, see ReadBody.
+func (r *Req) LoadBody() template.HTML {
+	body, err := r.ReadBody()
+	if err != nil {
+		log.Println(err)
+		return template.HTML(`<p class="text-warning">Unavailable, the body could not be read: ` + template.HTMLEscapeString(err.Error()) + `</p>`)
+	}
+	return body
+}
+
+// ReadBody returns the body of the requirement as LoadBody, or the error reading it from the
+// store.
+func (r *Req) ReadBody() (template.HTML, error) {
+	if r.storedBody == nil || bodies == nil {
+		return r.Body, nil
+	}
+	b := make([]byte, r.storedBody.length)
+	if _, err := bodies.f.ReadAt(b, r.storedBody.offset); err != nil {
+		return "", fmt.Errorf("Error while reading the body of %s from the store: %v", r.ID, err)
+	}
+	return template.HTML(b), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyStore(t *testing.T) {
+	store, err := newBodyStore()
+	assert.NoError(t, err)
+	bodies = store
+	defer func() { bodies = nil }()
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Body: "<p>The system shall fly.</p>"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Body: "<p>The software shall steer.</p>"}
+	empty := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH}
+	for _, r := range []*Req{sys, high, empty} {
+		assert.NoError(t, store.put(r))
+	}
+	assert.Equal(t, template.HTML(""), sys.Body)
+	assert.Equal(t, template.HTML("<p>The system shall fly.</p>"), sys.LoadBody())
+	assert.Equal(t, template.HTML("<p>The software shall steer.</p>"), high.LoadBody())
+	assert.Equal(t, template.HTML(""), empty.LoadBody())
+	assert.Nil(t, empty.storedBody)
+
+	// The reports read the bodies from the store.
+	var b bytes.Buffer
+	assert.NoError(t, reqGraph{sys.ID: sys}.ReportIssues(&b))
+	assert.Contains(t, b.String(), "<p>The system shall fly.</p>")
+
+	// A body which can't be read is marked unavailable, the reports still generated.
+	assert.NoError(t, store.f.Close())
+	_, err = sys.ReadBody()
+	assert.Error(t, err)
+	assert.Contains(t, string(sys.LoadBody()), "Unavailable, the body could not be read")
+	b.Reset()
+	assert.NoError(t, reqGraph{sys.ID: sys}.ReportIssues(&b))
+	assert.Contains(t, b.String(), "Unavailable, the body could not be read")
+
+	// Without the store, the bodies are those of the requirements.
+	bodies = nil
+	assert.Equal(t, template.HTML("<p>The software shall steer.</p>"), (&Req{Body: "<p>The software shall steer.</p>"}).LoadBody())
+}
@@ -0,0 +1,467 @@
+// @llr REQ-0-DDLN-SWL-015
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// codeLanguage is a language of the code files.
+type codeLanguage struct {
+	// references returns a function returning the low-level requirement referenced by the
+	// successive lines of a file, if any.
+	references func() func(line string) string
+	// comments is the syntax of the comments, in which the requirements are mentioned, see
+	// commentMentions.
+	comments commentSyntax
+}
+
+var (
+	adaLanguage          = &codeLanguage{adaLLRReferences, commentSyntax{line: []string{"--"}, quotes: `"`}}
+	asmLanguage          = &codeLanguage{asmLLRReferences, commentSyntax{line: []string{";", "#", "//"}, quotes: `"'`}}
+	blockCommentLanguage = &codeLanguage{blockCommentLLRReferences, commentSyntax{line: []string{"//"}, blocks: [][2]string{{"/*", "*/"}}, quotes: `"'`}}
+	cLanguage            = &codeLanguage{cLLRReferences, commentSyntax{line: []string{"//"}, blocks: [][2]string{{"/*", "*/"}}, quotes: `"'`, rawQuotes: "`"}}
+	hashCommentLanguage  = &codeLanguage{hashCommentLLRReferences, commentSyntax{line: []string{"#"}, quotes: `"'`}}
+	matlabLanguage       = &codeLanguage{matlabLLRReferences, commentSyntax{line: []string{"%"}, quotes: `"'`}}
+	pythonLanguage       = &codeLanguage{pythonLLRReferences, commentSyntax{line: []string{"#"}, blocks: [][2]string{{`"""`, `"""`}, {"'''", "'''"}}, quotes: `"'`}}
+	rustLanguage         = &codeLanguage{rustLLRReferences, commentSyntax{line: []string{"//"}, blocks: [][2]string{{"/*", "*/"}}, quotes: `"'`}}
+	// The Simulink models have no comments, the references being in the strings of their metadata.
+	simulinkLanguage = &codeLanguage{simulinkLLRReferences, commentSyntax{}}
+)
+
+// codeLanguages are the languages of the code files, by extension. The languages sharing a
+// comment syntax share its language, e.g. Verilog and SystemVerilog that of the // and /* */
+// comments of C, and VHDL that of the -- comments of Ada.
+var codeLanguages = map[string]*codeLanguage{
+	".adb":   adaLanguage,
+	".ads":   adaLanguage,
+	".asm":   asmLanguage,
+	".bash":  hashCommentLanguage,
+	".c":     blockCommentLanguage,
+	".cc":    blockCommentLanguage,
+	".cmake": hashCommentLanguage,
+	".go":    cLanguage,
+	".h":     blockCommentLanguage,
+	".hh":    blockCommentLanguage,
+	".java":  blockCommentLanguage,
+	".js":    blockCommentLanguage,
+	".kt":    blockCommentLanguage,
+	".m":     matlabLanguage,
+	".mdl":   simulinkLanguage,
+	".mk":    hashCommentLanguage,
+	".py":    pythonLanguage,
+	".rs":    rustLanguage,
+	".s":     asmLanguage,
+	".sh":    hashCommentLanguage,
+	".slx":   simulinkLanguage,
+	".sv":    blockCommentLanguage,
+	".ts":    blockCommentLanguage,
+	".tsx":   blockCommentLanguage,
+	".v":     blockCommentLanguage,
+	".vhd":   adaLanguage,
+}
+
+// codeFileNames are the languages of the code files without a telling extension, by file name.
+var codeFileNames = map[string]*codeLanguage{
+	"CMakeLists.txt": hashCommentLanguage,
+	"GNUmakefile":    hashCommentLanguage,
+	"Makefile":       hashCommentLanguage,
+	"makefile":       hashCommentLanguage,
+}
+
+// commentStyles are the comment syntaxes of the built-in languages, by name, which the configured
+// languages can use. "verilog" and "vhdl" are the same as "block" and "ada".
+var commentStyles = map[string]*codeLanguage{
+	"ada":      adaLanguage,
+	"asm":      asmLanguage,
+	"block":    blockCommentLanguage,
+	"c":        cLanguage,
+	"hash":     hashCommentLanguage,
+	"matlab":   matlabLanguage,
+	"python":   pythonLanguage,
+	"rust":     rustLanguage,
+	"simulink": simulinkLanguage,
+	"verilog":  blockCommentLanguage,
+	"vhdl":     adaLanguage,
+}
+
+// LanguageConf maps code files to a comment syntax, as one of the "languages" in attributes.json,
+// to add languages or to change the syntax of the built-in ones.
+type LanguageConf struct {
+	// Extensions are the extensions of the files, e.g. .lua, or their names if not starting with
+	// a dot, e.g. Jenkinsfile.
+	Extensions []string `json:"extensions"`
+	// Style is the name of a comment syntax of the built-in languages, e.g. c for // comments or
+	// hash for # comments, or none for the files not to be parsed.
+	Style string `json:"style"`
+	// Comment is the start of the line comments, e.g. --, if none of the styles fits.
+	Comment string `json:"comment"`
+}
+
+// @llr REQ-0-DDLN-SWL-034
+// registerLanguages adds the languages to the code languages, replacing the built-in ones for the
+// same extensions or file names.
+func registerLanguages(languages []LanguageConf) error {
+	for _, l := range languages {
+		name := strings.Join(l.Extensions, ", ")
+		if len(l.Extensions) == 0 {
+			return fmt.Errorf("Invalid language: no extensions")
+		}
+		var lang *codeLanguage
+		switch {
+		case l.Style != "" && l.Comment != "":
+			return fmt.Errorf("Invalid language %s: both a style and a comment", name)
+		case l.Comment != "":
+			lang = &codeLanguage{lineCommentLLRReferences(l.Comment), commentSyntax{line: []string{l.Comment}, quotes: `"`}}
+		case l.Style == "none":
+		default:
+			var ok bool
+			if lang, ok = commentStyles[l.Style]; !ok {
+				return fmt.Errorf("Invalid language %s: unknown style %q", name, l.Style)
+			}
+		}
+		for _, e := range l.Extensions {
+			languages := codeFileNames
+			if strings.HasPrefix(e, ".") {
+				languages = codeLanguages
+				e = strings.ToLower(e)
+			}
+			if lang == nil {
+				delete(languages, e)
+			} else {
+				languages[e] = lang
+			}
+		}
+	}
+	return nil
+}
+
+// codeReqID matches the IDs of the requirements referenced by code, of any type, the types
+// code may reference being checked by Resolve, see codeReferenceTypes.
+const codeReqID = `REQ-\d+-\w+-(?:SYS|SWH|SWL|HWH|HWL)-\d+`
+
+// codeReferenceTypes are the types of the requirements code may reference, the low-level ones by
+// default, e.g. SWL for software and HWL for hardware description languages.
+var codeReferenceTypes = map[string]bool{"SWL": true, "HWL": true}
+
+// @llr REQ-0-DDLN-SWL-046
+// registerCodeReferences sets the types of the requirements code may reference, e.g. to also
+// allow SWH for integration code, keeping the default ones if none.
+func registerCodeReferences(types []string) error {
+	if len(types) == 0 {
+		return nil
+	}
+	allowed := map[string]bool{}
+	for _, t := range types {
+		if _, ok := config.ReqTypeToReqLevel[t]; !ok {
+			return fmt.Errorf("Invalid code references: unknown requirement type %q", t)
+		}
+		allowed[t] = true
+	}
+	codeReferenceTypes = allowed
+	return nil
+}
+
+// checkCodeReference returns the error of a reference of the code file to a requirement of a type
+// it may not reference, empty if none.
+func checkCodeReference(code, r *Req) string {
+	if codeReferenceTypes[r.ReqType()] {
+		return ""
+	}
+	var types []string
+	for t := range codeReferenceTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return "Invalid reference in file " + code.Path + ": " + r.ID + " is a " + r.ReqType() + " requirement, code may only reference " + strings.Join(types, ", ") + ".\n"
+}
+
+// lineCommentLLRReferences returns a language finding the references in the line comments
+// starting with comment.
+func lineCommentLLRReferences(comment string) func() func(line string) string {
+	return func() func(line string) string {
+		// Compiled for each file, as the annotation tags may be registered after the languages.
+		re := regexp.MustCompile(referencePattern(regexp.QuoteMeta(comment) + `\s*`))
+		return func(line string) string {
+			if parts := re.FindStringSubmatch(line); len(parts) > 0 {
+				return parts[1]
+			}
+			return ""
+		}
+	}
+}
+
+// reCommentLLRReference matches a reference within a comment, see compileReferences.
+var reCommentLLRReference *regexp.Regexp
+
+// cLLRReferences finds the references in // comments.
+func cLLRReferences() func(line string) string {
+	return func(line string) string {
+		if parts := reLLRReference.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+// blockCommentLLRReferences finds the references in // comments and in /* */ comments, which may
+// span several lines, including Javadoc, KDoc and JSDoc comments and C file headers, e.g.
+//
+//	/**
+//	 * Does something.
+//	 *
+//	 * @llr REQ-0-DDLN-SWL-001
+//	 *      REQ-0-DDLN-SWL-002
+//	 */
+//
+// The requirements on the lines following a reference in a /* */ comment continue its list.
+func blockCommentLLRReferences() func(line string) string {
+	return blockCommentReferences(reCommentLLRReference, reLLRListItem)
+}
+
+// reLLRListItem matches the items of a list of references continued on the next lines of a /* */
+// comment.
+var reLLRListItem = regexp.MustCompile(`^\s*\**\s*(` + codeReqID + `)\s*,?\s*$`)
+
+// blockCommentReferences finds the references matched by re in // and /* */ comments, and the
+// items matched by item on the lines continuing a list of references in a /* */ comment.
+func blockCommentReferences(re, item *regexp.Regexp) func(line string) string {
+	inComment := false
+	// inList is whether the last line of the /* */ comment being read holds a reference.
+	inList := false
+	return func(line string) string {
+		ref := ""
+		found := func(s string) {
+			if parts := re.FindStringSubmatch(s); len(parts) > 0 && ref == "" {
+				ref = parts[1]
+			}
+		}
+		foundInComment := func(s string) {
+			if inList && ref == "" {
+				if parts := item.FindStringSubmatch(s); len(parts) > 0 {
+					ref = parts[1]
+					return
+				}
+			}
+			found(s)
+			inList = ref != ""
+		}
+		for rest := line; ; {
+			if inComment {
+				end := strings.Index(rest, "*/")
+				if end < 0 {
+					foundInComment(rest)
+					return ref
+				}
+				foundInComment(rest[:end])
+				rest = rest[end+2:]
+				inComment = false
+				inList = false
+				continue
+			}
+			start := strings.Index(rest, "/*")
+			if comment := strings.Index(rest, "//"); comment >= 0 && (start < 0 || comment < start) {
+				found(rest[comment+2:])
+				return ref
+			}
+			if start < 0 {
+				return ref
+			}
+			rest = rest[start+2:]
+			inComment = true
+		}
+	}
+}
+
+var reAdaLLRReference *regexp.Regexp
+
+// adaLLRReferences finds the references in -- comments, also used by SPARK and VHDL.
+func adaLLRReferences() func(line string) string {
+	return func(line string) string {
+		if parts := reAdaLLRReference.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+var reAsmLLRReference *regexp.Regexp
+
+// asmLLRReferences finds the references in the ; and # comments of assembly files, depending on
+// the assembler, and in the // comments of the preprocessed .S files.
+func asmLLRReferences() func(line string) string {
+	return func(line string) string {
+		if parts := reAsmLLRReference.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+var reHashCommentLLRReference *regexp.Regexp
+
+// hashCommentLLRReferences finds the references in the # comments of shell scripts, makefiles
+// and CMake files, for the requirements satisfied by the build and the packaging.
+func hashCommentLLRReferences() func(line string) string {
+	return func(line string) string {
+		if parts := reHashCommentLLRReference.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+var reMatlabLLRReference *regexp.Regexp
+
+// matlabLLRReferences finds the references in the % comments of MATLAB files, also within %{ %}
+// block comments, whose delimiters are alone on their lines and which may be nested.
+func matlabLLRReferences() func(line string) string {
+	depth := 0 // The number of block comments open.
+	return func(line string) string {
+		switch strings.TrimSpace(line) {
+		case "%{":
+			depth++
+			return ""
+		case "%}":
+			if depth > 0 {
+				depth--
+				return ""
+			}
+		}
+		re := reMatlabLLRReference
+		if depth > 0 {
+			re = reCommentLLRReference
+		}
+		if parts := re.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+// simulinkLLRReferences finds the references in the metadata of Simulink models, e.g. in the
+// descriptions of the blocks or in the annotations, which are strings of the model rather than
+// comments.
+func simulinkLLRReferences() func(line string) string {
+	return func(line string) string {
+		if parts := reCommentLLRReference.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+// codeReader returns the text of the code file read by r, in which its references are found.
+// The .slx Simulink models are zip archives, whose XML parts are read in turn.
+func codeReader(fileName string, r io.Reader) (io.Reader, error) {
+	if strings.ToLower(path.Ext(fileName)) != ".slx" {
+		return r, nil
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	var parts []io.Reader
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".xml") {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		part, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, bytes.NewReader(part), strings.NewReader("\n"))
+	}
+	return io.MultiReader(parts...), nil
+}
+
+var reRustLLRReference *regexp.Regexp
+
+// rustLLRReferences finds the references in // comments and in /// and //! doc comments.
+func rustLLRReferences() func(line string) string {
+	return func(line string) string {
+		if parts := reRustLLRReference.FindStringSubmatch(line); len(parts) > 0 {
+			return parts[1]
+		}
+		return ""
+	}
+}
+
+// pythonLLRReferences finds the references in # comments and in docstrings, i.e. in the strings
+// delimited by triple double or single quotes, which may span several lines, e.g.
+//
+//	def f():
+//	    """Does something.
+//
+//	    @llr REQ-0-DDLN-SWL-001
+//	    """
+//
+//	def g():
+//	    '''@llr REQ-0-DDLN-SWL-001'''
+func pythonLLRReferences() func(line string) string {
+	quote := "" // The delimiter of the docstring being read, if any.
+	return func(line string) string {
+		ref := ""
+		found := func(s string) {
+			if parts := reCommentLLRReference.FindStringSubmatch(s); len(parts) > 0 && ref == "" {
+				ref = parts[1]
+			}
+		}
+		for rest := line; ; {
+			if quote != "" {
+				end := strings.Index(rest, quote)
+				if end < 0 {
+					found(rest)
+					return ref
+				}
+				found(rest[:end])
+				rest = rest[end+len(quote):]
+				quote = ""
+				continue
+			}
+			// The # in the ordinary string literals don't start comments.
+			start, comment := -1, -1
+			for i := 0; i < len(rest) && start < 0 && comment < 0; i++ {
+				switch c := rest[i]; {
+				case c == '#':
+					comment = i
+				case strings.HasPrefix(rest[i:], `"""`) || strings.HasPrefix(rest[i:], `'''`):
+					start = i
+				case c == '"' || c == '\'':
+					for i++; i < len(rest) && rest[i] != c; i++ {
+						if rest[i] == '\\' {
+							i++
+						}
+					}
+				}
+			}
+			if comment >= 0 {
+				found(strings.TrimLeft(rest[comment:], "#"))
+				return ref
+			}
+			if start < 0 {
+				return ref
+			}
+			quote = rest[start : start+3]
+			rest = rest[start+3:]
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPythonLLRReferences(t *testing.T) {
+	lines := []string{
+		`# @llr REQ-0-TEST-SWL-001`,
+		`import os  #@llr REQ-0-TEST-SWL-002 and more`,
+		`def f():`,
+		`    """Does something.`,
+		``,
+		`    @llr REQ-0-TEST-SWL-003`,
+		`    """`,
+		`    x = "@llr REQ-0-TEST-SWL-004"`,
+		`    y = '''@llr REQ-0-TEST-SWL-005'''  # @llr REQ-0-TEST-SWL-006`,
+		`    z = """text""" # not a reference`,
+		`    '''`,
+		`    # @llr REQ-0-TEST-SWL-007 in a docstring`,
+		`    '''`,
+		`    w = 1 # ''' @llr REQ-0-TEST-SWL-008`,
+		`    """@llr REQ-0-TEST-SWL-009"""`,
+		`    s = "# not a comment @llr REQ-0-TEST-SWL-010"`,
+		`    t = 'it\'s # "quoted"' # @llr REQ-0-TEST-SWL-011`,
+	}
+	want := []string{
+		"REQ-0-TEST-SWL-001",
+		"REQ-0-TEST-SWL-002",
+		"",
+		"",
+		"",
+		"REQ-0-TEST-SWL-003",
+		"",
+		"",
+		"REQ-0-TEST-SWL-005",
+		"",
+		"",
+		"REQ-0-TEST-SWL-007",
+		"",
+		"REQ-0-TEST-SWL-008",
+		"REQ-0-TEST-SWL-009",
+		"",
+		"REQ-0-TEST-SWL-011",
+	}
+	llrRef := pythonLLRReferences()
+	var got []string
+	for _, l := range lines {
+		got = append(got, llrRef(l))
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestRustLLRReferences(t *testing.T) {
+	llrRef := rustLLRReferences()
+	assert.Equal(t, "REQ-0-TEST-SWL-001", llrRef("//"+" @llr REQ-0-TEST-SWL-001"))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", llrRef("///"+" @llr REQ-0-TEST-SWL-002"))
+	assert.Equal(t, "REQ-0-TEST-SWL-003", llrRef("//!"+"@llr REQ-0-TEST-SWL-003"))
+	assert.Equal(t, "REQ-0-TEST-SWL-004", llrRef("    pub fn f() {} ///"+" @llr REQ-0-TEST-SWL-004"))
+	assert.Equal(t, "", llrRef(`let s = "@llr REQ-0-TEST-SWL-005";`))
+}
+
+func TestAdaLLRReferences(t *testing.T) {
+	llrRef := adaLLRReferences()
+	assert.Equal(t, "REQ-0-TEST-SWL-001", llrRef("-- @llr REQ-0-TEST-SWL-001"))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", llrRef("   procedure Init;  --@llr REQ-0-TEST-SWL-002"))
+	assert.Equal(t, "", llrRef(`   Name : constant String := "@llr REQ-0-TEST-SWL-003";`))
+	assert.Equal(t, "", llrRef("-- Does something."))
+}
+
+func TestHDLLLRReferences(t *testing.T) {
+	vhdl := codeLanguages[".vhd"].references()
+	assert.Equal(t, "REQ-0-TEST-HWL-001", vhdl("-- @llr REQ-0-TEST-HWL-001"))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", vhdl("  signal ready : std_logic; --@llr REQ-0-TEST-SWL-002"))
+	// The types of the requirements referenced are checked by Resolve, see checkCodeReference.
+	assert.Equal(t, "REQ-0-TEST-HWH-003", vhdl("-- @llr REQ-0-TEST-HWH-003"))
+
+	verilog := codeLanguages[".sv"].references()
+	assert.Equal(t, "REQ-0-TEST-HWL-004", verilog("/"+"/ @llr REQ-0-TEST-HWL-004"))
+	assert.Equal(t, "", verilog("/*"))
+	assert.Equal(t, "REQ-0-TEST-HWL-005", verilog(" * @llr REQ-0-TEST-HWL-005 */ module fifo;"))
+	assert.Equal(t, "", verilog(`$display("@llr REQ-0-TEST-HWL-006");`))
+	assert.Equal(t, "REQ-0-TEST-HWL-007", codeLanguages[".v"].references()("/"+"/ @llr REQ-0-TEST-HWL-007"))
+}
+
+func TestAsmLLRReferences(t *testing.T) {
+	llrRef := asmLLRReferences()
+	assert.Equal(t, "REQ-0-TEST-SWL-001", llrRef("; @llr REQ-0-TEST-SWL-001"))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", llrRef("irq_handler:    # @llr REQ-0-TEST-SWL-002"))
+	assert.Equal(t, "REQ-0-TEST-SWL-003", llrRef("\tmov r0, #0 /"+"/ @llr REQ-0-TEST-SWL-003"))
+	assert.Equal(t, "", llrRef(`msg: .ascii "@llr REQ-0-TEST-SWL-004"`))
+	assert.NotNil(t, codeLanguages[".asm"])
+}
+
+func TestHashCommentLLRReferences(t *testing.T) {
+	llrRef := hashCommentLLRReferences()
+	assert.Equal(t, "REQ-0-TEST-SWL-001", llrRef("# @llr REQ-0-TEST-SWL-001"))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", llrRef("install: all  #@llr REQ-0-TEST-SWL-002"))
+	assert.Equal(t, "", llrRef(`echo "@llr REQ-0-TEST-SWL-003"`))
+	for _, fileName := range []string{"build.sh", "tools/release.bash", "Makefile", "rules.mk", "src/CMakeLists.txt", "Toolchain.cmake"} {
+		assert.NotNil(t, codeFileLanguage("", fileName), fileName)
+	}
+	assert.Nil(t, codeFileLanguage("", "notes.txt"))
+}
+
+func TestRegisterCodeReferences(t *testing.T) {
+	defer func(types map[string]bool) { codeReferenceTypes = types }(codeReferenceTypes)
+	graph := func() reqGraph {
+		sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM}
+		high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{sys.ID}, Position: 1}
+		low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, ParentIds: []string{high.ID}, Position: 2}
+		hardware := &Req{ID: "REQ-0-TEST-HWL-001", Level: config.LOW, ParentIds: []string{sys.ID}}
+		glue := &Req{ID: "glue.c", Path: "glue.c", Level: config.CODE, ParentIds: []string{low.ID, high.ID}}
+		fifo := &Req{ID: "fifo.v", Path: "fifo.v", Level: config.CODE, ParentIds: []string{hardware.ID}}
+		return reqGraph{sys.ID: sys, high.ID: high, low.ID: low, hardware.ID: hardware, glue.ID: glue, fifo.ID: fifo}
+	}
+
+	rg := graph()
+	assert.EqualError(t, rg.Resolve(), "Invalid reference in file glue.c: REQ-0-TEST-SWH-001 is a SWH requirement, code may only reference HWL, SWL.\n\n")
+	assert.Equal(t, []*Req{rg["REQ-0-TEST-SWL-001"]}, rg["REQ-0-TEST-SWH-001"].Children)
+
+	assert.NoError(t, registerCodeReferences(nil))
+	assert.Equal(t, map[string]bool{"SWL": true, "HWL": true}, codeReferenceTypes)
+	assert.NoError(t, registerCodeReferences([]string{"SWL", "SWH", "HWL"}))
+	rg = graph()
+	assert.NoError(t, rg.Resolve())
+	assert.Equal(t, []*Req{rg["REQ-0-TEST-SWH-001"], rg["REQ-0-TEST-SWL-001"]}, rg["glue.c"].Parents)
+
+	assert.EqualError(t, registerCodeReferences([]string{"LLR"}), `Invalid code references: unknown requirement type "LLR"`)
+}
+
+func TestRegisterLanguages(t *testing.T) {
+	defer func(languages, fileNames map[string]*codeLanguage) {
+		codeLanguages, codeFileNames = languages, fileNames
+	}(codeLanguages, codeFileNames)
+	codeLanguages = map[string]*codeLanguage{".js": blockCommentLanguage, ".c": cLanguage}
+	codeFileNames = map[string]*codeLanguage{}
+
+	assert.NoError(t, registerLanguages([]LanguageConf{
+		{Extensions: []string{".LUA"}, Comment: "--"},
+		{Extensions: []string{".groovy", "Jenkinsfile"}, Style: "c"},
+		{Extensions: []string{".js"}, Style: "none"},
+	}))
+	assert.Nil(t, codeFileLanguage("", "a.js"))
+	assert.NotNil(t, codeFileLanguage("", "a.c"))
+	assert.NotNil(t, codeFileLanguage("", "ci/Jenkinsfile"))
+	assert.Equal(t, "REQ-0-TEST-SWL-001", codeFileLanguage("", "a.groovy").references()("/"+"/ @llr REQ-0-TEST-SWL-001"))
+	lua := codeFileLanguage("", "a.lua").references()
+	assert.Equal(t, "REQ-0-TEST-SWL-002", lua("local x = 1 -- @llr REQ-0-TEST-SWL-002"))
+	assert.Equal(t, "", lua("/"+"/ @llr REQ-0-TEST-SWL-003"))
+
+	assert.EqualError(t, registerLanguages([]LanguageConf{{Style: "c"}}), "Invalid language: no extensions")
+	assert.EqualError(t, registerLanguages([]LanguageConf{{Extensions: []string{".x", ".y"}, Style: "cobol"}}),
+		`Invalid language .x, .y: unknown style "cobol"`)
+	assert.EqualError(t, registerLanguages([]LanguageConf{{Extensions: []string{".x"}, Style: "c", Comment: "--"}}),
+		"Invalid language .x: both a style and a comment")
+}
+
+func TestMatlabLLRReferences(t *testing.T) {
+	llrRef := matlabLLRReferences()
+	assert.Equal(t, "REQ-0-TEST-SWL-001", llrRef("% @llr REQ-0-TEST-SWL-001"))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", llrRef("y = filter(b, a, x); %@llr REQ-0-TEST-SWL-002"))
+	assert.Equal(t, "", llrRef(`disp('@llr REQ-0-TEST-SWL-003')`))
+	// In block comments, nested or not.
+	assert.Equal(t, "", llrRef("%{"))
+	assert.Equal(t, "REQ-0-TEST-SWL-004", llrRef("@llr REQ-0-TEST-SWL-004"))
+	assert.Equal(t, "", llrRef("  %{"))
+	assert.Equal(t, "REQ-0-TEST-SWL-005", llrRef("Filters. @llr REQ-0-TEST-SWL-005"))
+	assert.Equal(t, "", llrRef("  %}"))
+	assert.Equal(t, "REQ-0-TEST-SWL-006", llrRef("@llr REQ-0-TEST-SWL-006"))
+	assert.Equal(t, "", llrRef("%}"))
+	assert.Equal(t, "", llrRef(`disp('@llr REQ-0-TEST-SWL-007')`))
+	assert.NotNil(t, codeLanguages[".m"])
+}
+
+func TestSimulinkLLRReferences(t *testing.T) {
+	llrRef := simulinkLLRReferences()
+	assert.Equal(t, "REQ-0-TEST-SWL-001", llrRef(`    Description "Saturates the command. @llr REQ-0-TEST-SWL-001"`))
+	assert.Equal(t, "REQ-0-TEST-SWL-002", llrRef(`<P Name="Description">@llr REQ-0-TEST-SWL-002</P>`))
+	assert.Equal(t, "", llrRef(`<P Name="Name">Saturation</P>`))
+	assert.NotNil(t, codeLanguages[".mdl"])
+	assert.NotNil(t, codeLanguages[".slx"])
+}
+
+func TestCodeReader(t *testing.T) {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range map[string]string{
+		"simulink/blockdiagram.xml": `<P Name="Description">@llr REQ-0-TEST-SWL-001</P>`,
+		"simulink/thumbnail.png":    "@llr REQ-0-TEST-SWL-002",
+	} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	r, err := codeReader("model.slx", bytes.NewReader(b.Bytes()))
+	assert.NoError(t, err)
+	text, err := ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "<P Name=\"Description\">@llr REQ-0-TEST-SWL-001</P>\n", string(text))
+
+	_, err = codeReader("model.slx", bytes.NewReader([]byte("not a zip")))
+	assert.Error(t, err)
+
+	r, err = codeReader("model.mdl", bytes.NewReader([]byte("Model {")))
+	assert.NoError(t, err)
+	text, err = ioutil.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "Model {", string(text))
+}
+
+func TestBlockCommentLLRReferences(t *testing.T) {
+	lines := []string{
+		"/" + "/ @llr REQ-0-TEST-SWL-001",
+		`/**`,
+		` * Does something.`,
+		` *`,
+		` * @llr REQ-0-TEST-SWL-002`,
+		` */`,
+		`public void f() { String s = "@llr REQ-0-TEST-SWL-003"; }`,
+		`fun g() = 1 /* @llr REQ-0-TEST-SWL-004 */ + 2`,
+		`/* not a reference */ val x = "@llr REQ-0-TEST-SWL-005" /*`,
+		`@llr REQ-0-TEST-SWL-006 */`,
+	}
+	want := []string{
+		"REQ-0-TEST-SWL-001",
+		"",
+		"",
+		"",
+		"REQ-0-TEST-SWL-002",
+		"",
+		"",
+		"REQ-0-TEST-SWL-004",
+		"",
+		"REQ-0-TEST-SWL-006",
+	}
+	llrRef := blockCommentLLRReferences()
+	var got []string
+	for _, l := range lines {
+		got = append(got, llrRef(l))
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestBlockCommentLLRReferences_lists(t *testing.T) {
+	lines := []string{
+		`/*`,
+		` * Copyright 2020 Daedalean AG`,
+		` *`,
+		` * @llr REQ-0-TEST-SWL-001`,
+		` *      REQ-0-TEST-SWL-002,`,
+		` *`,
+		` * REQ-0-TEST-SWL-003 is not listed after the blank line.`,
+		` * REQ-0-TEST-SWL-004`,
+		` */`,
+		`/* @llr REQ-0-TEST-SWL-005`,
+		`   REQ-0-TEST-SWL-006 */`,
+		`REQ-0-TEST-SWL-007`,
+		`int f(void); /` + `/ @llr REQ-0-TEST-SWL-008`,
+		`/* @llr REQ-0-TEST-SWL-009 */`,
+		`/* REQ-0-TEST-SWL-010 */`,
+	}
+	want := []string{
+		"",
+		"",
+		"",
+		"REQ-0-TEST-SWL-001",
+		"REQ-0-TEST-SWL-002",
+		"",
+		"",
+		"",
+		"",
+		"REQ-0-TEST-SWL-005",
+		"REQ-0-TEST-SWL-006",
+		"",
+		"REQ-0-TEST-SWL-008",
+		"REQ-0-TEST-SWL-009",
+		"",
+	}
+	llrRef := codeLanguages[".c"].references()
+	var got []string
+	for _, l := range lines {
+		got = append(got, llrRef(l))
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestBlockCommentLLRReferences_JSDoc(t *testing.T) {
+	lines := []string{
+		`/** @llr REQ-0-TEST-SWL-001 */`,
+		`export function Altitude({ value }: Props) {`,
+		`  return <div>{/* @llr REQ-0-TEST-SWL-002 */ value}</div>; ` + "/" + "/ @llr REQ-0-TEST-SWL-003",
+		`}`,
+	}
+	want := []string{"REQ-0-TEST-SWL-001", "", "REQ-0-TEST-SWL-002", ""}
+	llrRef := codeLanguages[".tsx"].references()
+	var got []string
+	for _, l := range lines {
+		got = append(got, llrRef(l))
+	}
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,132 @@
+// @llr REQ-0-DDLN-SWL-051
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// codeRequirements is whether the low-level requirements can be defined in the doc comments of the
+// code, as set by the "codeRequirements" entry of attributes.json.
+var codeRequirements bool
+
+// The requirements defined in code are doc comments starting with the @requirement tag followed by
+// the ID and the title, then the body, in markdown, and the attributes after an Attributes: line:
+//
+//	// @requirement REQ-<project>-<abbreviation>-SWL-<number> Title
+//	// The RMT SHALL ...
+//	//
+//	// Attributes:
+//	// Parents: REQ-<project>-<abbreviation>-SWH-<number>
+//	// Verification: Unit test
+//
+// The comment ends at the first line which isn't a comment or ends a block comment. The code file
+// defining a requirement implements it.
+var (
+	reCodeRequirement = regexp.MustCompile(`^@requirement\s+(` + codeReqID + `)\s*(.*)$`)
+	// reCommentText captures the text of a comment line, after the comment marker of the line
+	// comments //, #, --, % and ;, or of the lines of a block comment, /* or *, and before the end
+	// of a block comment.
+	reCommentText   = regexp.MustCompile(`^\s*(?://[/!]?|#+|--|%+|;+|/\*+|\*+)(?:\s(.*?))?\s*?(\*/)?\s*$`)
+	reCodeAttribute = regexp.MustCompile(`^([\w ]+):\s*(.*)$`)
+)
+
+// codeRequirement is a requirement being read from a doc comment.
+type codeRequirement struct {
+	line       int
+	id, title  string
+	body       []string
+	attributes []tomlAttribute
+	// inAttributes is set after the Attributes: line.
+	inAttributes bool
+}
+
+// codeRequirementsReader reads the requirements defined in the doc comments of a code file, a line
+// at a time.
+type codeRequirementsReader struct {
+	current *codeRequirement
+	reqs    []*codeRequirement
+}
+
+// readLine reads the line of the code file at the line number n.
+func (cr *codeRequirementsReader) readLine(n int, line string) {
+	parts := reCommentText.FindStringSubmatch(line)
+	if parts == nil {
+		cr.end()
+		return
+	}
+	text := strings.TrimSpace(parts[1])
+	if m := reCodeRequirement.FindStringSubmatch(text); m != nil {
+		cr.end()
+		cr.current = &codeRequirement{line: n, id: m[1], title: strings.TrimSpace(m[2])}
+	} else if r := cr.current; r != nil {
+		switch {
+		case r.inAttributes && text == "":
+		case r.inAttributes:
+			if a := reCodeAttribute.FindStringSubmatch(text); a != nil {
+				r.attributes = append(r.attributes, tomlAttribute{strings.TrimSpace(a[1]), strings.TrimSpace(a[2])})
+			} else if len(r.attributes) > 0 {
+				// A continuation line of the value of the last attribute.
+				last := &r.attributes[len(r.attributes)-1]
+				last.Value += " " + text
+			}
+		case strings.EqualFold(text, "Attributes:"):
+			r.inAttributes = true
+		default:
+			r.body = append(r.body, parts[1])
+		}
+	}
+	if parts[2] != "" {
+		cr.end()
+	}
+}
+
+// end ends the requirement being read, if any.
+func (cr *codeRequirementsReader) end() {
+	if cr.current != nil {
+		cr.reqs = append(cr.reqs, cr.current)
+		cr.current = nil
+	}
+}
+
+// toReq converts the requirement read from the doc comment into a low-level requirement.
+func (c *codeRequirement) toReq() (*Req, error) {
+	tr := &tomlReq{ID: c.id, Title: c.title, Body: strings.TrimSpace(strings.Join(c.body, "\n")), line: c.line}
+	for _, a := range c.attributes {
+		if key := strings.ToUpper(a.Name); key == "PARENT" || key == "PARENTS" {
+			tr.Parents = append(tr.Parents, ReReqID.FindAllString(a.Value, -1)...)
+		} else {
+			tr.Attributes = append(tr.Attributes, a)
+		}
+	}
+	r, err := tr.toReq()
+	if err != nil {
+		return nil, err
+	}
+	if r.Level != config.LOW {
+		return nil, fmt.Errorf("requirement %s on line %d is a %s requirement, only low-level requirements can be defined in code", r.ID, c.line, r.ReqType())
+	}
+	return r, nil
+}
+
+// addCodeRequirements adds the requirements defined in the code file to the graph, in the order of
+// the file, and returns their IDs.
+func addCodeRequirements(fileName string, reqs []*codeRequirement, graph reqGraph) ([]string, error) {
+	var ids []string
+	for i, c := range reqs {
+		r, err := c.toReq()
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing the requirements defined in %s: %v", fileName, err)
+		}
+		r.Position = i
+		r.parseSections()
+		if err := graph.AddReq(r, fileName); err != nil {
+			return nil, err
+		}
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCode_codeRequirements(t *testing.T) {
+	defer func(enabled bool) { codeRequirements = enabled }(codeRequirements)
+	codeRequirements = true
+
+	dir, err := ioutil.TempDir("", "coderequirements")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "watchdog.go")
+	code := `package watchdog
+
+// @requirement REQ-0-TEST-SWL-001 Watchdog kick
+// The watchdog SHALL be kicked every 10 ms.
+//
+// Attributes:
+// Parents: REQ-0-TEST-SWH-001
+// Verification: Unit test
+// Safety Impact: The system resets
+// unexpectedly.
+func Kick() {}
+
+/* @requirement REQ-0-TEST-SWL-002 Watchdog timeout
+ * The watchdog SHALL time out after 50 ms.
+ *
+ * Attributes:
+ * Parents: REQ-0-TEST-SWH-001 */
+func Timeout() {}
+`
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(code), 0644))
+
+	rg := reqGraph{}
+	assert.NoError(t, parseCode("watchdog.go", fileName, codeLanguages[".go"].references(), rg))
+	kick := rg["REQ-0-TEST-SWL-001"]
+	if !assert.NotNil(t, kick) {
+		return
+	}
+	assert.Equal(t, "Watchdog kick", kick.Title)
+	assert.Equal(t, config.LOW, kick.Level)
+	assert.Equal(t, "The watchdog SHALL be kicked every 10 ms.", strings.TrimSpace(plainText(string(kick.Body))))
+	assert.Equal(t, []string{"REQ-0-TEST-SWH-001"}, kick.ParentIds)
+	assert.Equal(t, map[string]string{
+		"PARENTS":       "REQ-0-TEST-SWH-001",
+		"VERIFICATION":  "Unit test",
+		"SAFETY IMPACT": "The system resets unexpectedly.",
+	}, kick.Attributes)
+	assert.Equal(t, 0, kick.Position)
+
+	timeout := rg["REQ-0-TEST-SWL-002"]
+	if assert.NotNil(t, timeout) {
+		assert.Equal(t, "Watchdog timeout", timeout.Title)
+		assert.Equal(t, []string{"REQ-0-TEST-SWH-001"}, timeout.ParentIds)
+		assert.Equal(t, 1, timeout.Position)
+	}
+
+	// The code file implements the requirements it defines.
+	c := rg[fileName]
+	if assert.NotNil(t, c) {
+		assert.Equal(t, []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002"}, c.ParentIds)
+		if assert.Len(t, c.Functions, 2) {
+			assert.Equal(t, "Kick", c.Functions[0].Name)
+			assert.Equal(t, []string{"REQ-0-TEST-SWL-001"}, c.Functions[0].Reqs)
+		}
+	}
+
+	// Only the low-level requirements can be defined in code.
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte("# @requirement REQ-0-TEST-SWH-002 High\n"), 0644))
+	assert.EqualError(t, parseCode("watchdog.go", fileName, codeLanguages[".go"].references(), reqGraph{}),
+		"Error parsing the requirements defined in "+fileName+": requirement REQ-0-TEST-SWH-002 on line 1 is a SWH requirement, only low-level requirements can be defined in code")
+
+	// The doc comments are only parsed in the code requirements mode.
+	codeRequirements = false
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(code), 0644))
+	rg = reqGraph{}
+	assert.NoError(t, parseCode("watchdog.go", fileName, codeLanguages[".go"].references(), rg))
+	assert.Empty(t, rg)
+}
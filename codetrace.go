@@ -0,0 +1,99 @@
+// @llr REQ-0-DDLN-SWL-081
+package main
+
+import (
+	"io"
+	"log"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// The statuses of the hash of a code file recorded in the graph against the file at HEAD.
+const (
+	codeUnchanged    = "Unchanged"
+	codeModified     = "Modified"
+	codeNotCommitted = "Not committed"
+	// codeNotComparable is when the hash isn't in the object format of the repository, see
+	// setHashAlgorithm.
+	codeNotComparable = "Not comparable"
+	codeUnavailable   = "Unavailable"
+)
+
+// CodeTrace is a code file with the requirements it implements, partially implements and
+// verifies, by file and by function, and whether it changed since HEAD.
+type CodeTrace struct {
+	File *Req
+	// Head is the status of the hash of the file recorded in the graph against the file at HEAD:
+	// Unchanged, Modified, Not committed, Not comparable or Unavailable without the git history.
+	Head string
+}
+
+// CodeTraces returns the code files of the graph referencing requirements matching the filter and
+// the diffs, sorted by path, with the status of their hashes against HEAD.
+func (rg reqGraph) CodeTraces(f ReqFilter, diffs map[string][]string) []*CodeTrace {
+	format := "sha1"
+	if fo, err := git.ObjectFormat(); err == nil && hashAlgorithms[fo] != nil {
+		format = fo
+	}
+	_, headErr := git.HeadCommit()
+	if headErr != nil {
+		log.Printf("Could not read HEAD to compare the code files to: %v", headErr)
+	}
+
+	var traces []*CodeTrace
+	for _, c := range rg.CodeFilesByPosition() {
+		matches := false
+		for _, r := range append(append(append([]*Req{}, c.Parents...), c.PartiallyImplements...), c.Verifies...) {
+			if r.Matches(f, diffs) {
+				matches = true
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		t := &CodeTrace{File: c, Head: codeUnavailable}
+		if headErr == nil {
+			t.Head = headStatus(c, format)
+		}
+		traces = append(traces, t)
+	}
+	sort.Sort(byTraceID(traces))
+	return traces
+}
+
+// headStatus returns the status of the hash of the code file against the file at HEAD, given the
+// object format of the repository.
+func headStatus(c *Req, format string) string {
+	if fileHashAlgorithmOf(c.FileHash) != format {
+		return codeNotComparable
+	}
+	blob, err := git.BlobAt("HEAD", c.Path)
+	if err != nil {
+		return codeNotCommitted
+	}
+	if format+":"+blob != c.FileHash {
+		return codeModified
+	}
+	return codeUnchanged
+}
+
+// byTraceID sorts the code traces by the ID, the path, of the code file.
+type byTraceID []*CodeTrace
+
+func (a byTraceID) Len() int           { return len(a) }
+func (a byTraceID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTraceID) Less(i, j int) bool { return a[i].File.ID < a[j].File.ID }
+
+type codeReportData struct {
+	Traces []*CodeTrace
+	Filter ReqFilter
+}
+
+// ReportCode writes an HTML report of the code files referencing the requirements matching the
+// filter and the diffs, with the requirements they trace to, by file and by function, their hashes
+// and whether these still match HEAD.
+func (rg reqGraph) ReportCode(w io.Writer, f ReqFilter, diffs map[string][]string) error {
+	return reportTmpl.ExecuteTemplate(w, "CODETRACES", codeReportData{rg.CodeTraces(f, diffs), f})
+}
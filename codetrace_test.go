@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_CodeTraces(t *testing.T) {
+	committed := filepath.Join(git.RepoPath(), "hash.go")
+	hash, err := hashFile("sha1", committed)
+	assert.NoError(t, err)
+
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Hashing"},
+		{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "Filtered"},
+	} {
+		rg[r.ID] = r
+	}
+	for _, c := range []*Req{
+		{ID: "hash.go", Path: committed, FileHash: hash, ParentIds: []string{"REQ-0-TEST-SWL-001"},
+			Functions: []CodeFunction{{Name: "hashFile", Start: 3, End: 9, Reqs: []string{"REQ-0-TEST-SWL-001"}}}},
+		{ID: "modified.go", Path: committed, FileHash: "sha1:0123", ParentIds: []string{"REQ-0-TEST-SWL-001"}},
+		{ID: "new.go", Path: filepath.Join(git.RepoPath(), "not-committed.go"), FileHash: "sha1:0123", ParentIds: []string{"REQ-0-TEST-SWL-001"}},
+		{ID: "other.go", Path: committed, FileHash: "sha256:0123", VerifiesIds: []string{"REQ-0-TEST-SWL-001"}},
+		{ID: "filtered.go", Path: committed, FileHash: hash, ParentIds: []string{"REQ-0-TEST-SWL-002"}},
+	} {
+		c.Level = config.CODE
+		rg["/repo/"+c.ID] = c
+	}
+	// The orphans fail the resolution, but the links are made.
+	assert.Error(t, rg.Resolve())
+
+	diffs := map[string][]string{"REQ-0-TEST-SWL-001": nil}
+	var heads []string
+	for _, tr := range rg.CodeTraces(nil, diffs) {
+		heads = append(heads, tr.File.ID+" "+tr.Head)
+	}
+	assert.Equal(t, []string{
+		"hash.go Unchanged",
+		"modified.go Modified",
+		"new.go Not committed",
+		"other.go Not comparable",
+	}, heads)
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportCode(&b, nil, diffs))
+	report := b.String()
+	assert.Contains(t, report, "REQ-0-TEST-SWL-001 Hashing<br>")
+	assert.Contains(t, report, "REQ-0-TEST-SWL-001 Hashing <small>verified</small><br>")
+	assert.Contains(t, report, "<code>hashFile</code>, lines 3-9: REQ-0-TEST-SWL-001 <br>")
+	assert.Contains(t, report, `<span class="label label-warning">Not committed</span>`)
+	assert.NotContains(t, report, "filtered.go")
+}
@@ -0,0 +1,112 @@
+// @llr REQ-0-DDLN-SWL-023
+
+// Package confluence reads pages from a Confluence server through its REST API, see
+// https://developer.atlassian.com/cloud/confluence/rest/
+package confluence
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Page is a Confluence page, with its body in the storage format, which is XHTML.
+type Page struct {
+	ID      string
+	Title   string
+	Version int
+	Body    string
+	URL     string
+}
+
+// Client connects to the Confluence server at BaseURL, e.g. https://example.atlassian.net/wiki,
+// authenticating with User and Token (an API token or a password) if set.
+type Client struct {
+	BaseURL    string
+	User       string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// pageLimit is the number of pages requested at once.
+const pageLimit = 50
+
+type contentResponse struct {
+	Results []struct {
+		ID      string `json:"id"`
+		Title   string `json:"title"`
+		Version struct {
+			Number int `json:"number"`
+		} `json:"version"`
+		Body struct {
+			Storage struct {
+				Value string `json:"value"`
+			} `json:"storage"`
+		} `json:"body"`
+		Links struct {
+			WebUI string `json:"webui"`
+		} `json:"_links"`
+	} `json:"results"`
+	Size  int `json:"size"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"_links"`
+}
+
+// SpacePages returns all the pages of the space with the given key.
+func (c *Client) SpacePages(space string) ([]Page, error) {
+	var pages []Page
+	for start := 0; ; start += pageLimit {
+		q := url.Values{}
+		q.Set("spaceKey", space)
+		q.Set("type", "page")
+		q.Set("expand", "body.storage,version")
+		q.Set("start", fmt.Sprint(start))
+		q.Set("limit", fmt.Sprint(pageLimit))
+		var res contentResponse
+		if err := c.get("/rest/api/content?"+q.Encode(), &res); err != nil {
+			return nil, err
+		}
+		for _, r := range res.Results {
+			pages = append(pages, Page{
+				ID:      r.ID,
+				Title:   r.Title,
+				Version: r.Version.Number,
+				Body:    r.Body.Storage.Value,
+				URL:     strings.TrimSuffix(c.BaseURL, "/") + r.Links.WebUI,
+			})
+		}
+		if res.Links.Next == "" || len(res.Results) == 0 {
+			return pages, nil
+		}
+	}
+}
+
+func (c *Client) get(path string, v interface{}) error {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(c.BaseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.User != "" || c.Token != "" {
+		req.SetBasicAuth(c.User, c.Token)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Confluence request %s failed: %s", req.URL, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("Invalid Confluence response to %s: %v", req.URL, err)
+	}
+	return nil
+}
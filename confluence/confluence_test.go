@@ -0,0 +1,48 @@
+package confluence
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_SpacePages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, token, ok := r.BasicAuth(); !ok || user != "user" || token != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/wiki/rest/api/content" || r.FormValue("spaceKey") != "DDLN" || r.FormValue("expand") != "body.storage,version" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Two pages of results.
+		next := ""
+		if r.FormValue("start") == "0" {
+			next = "/rest/api/content?start=50"
+		}
+		fmt.Fprintf(w, `{"results": [{"id": "%s", "title": "Page %s", "version": {"number": 3},
+			"body": {"storage": {"value": "<p>Body</p>"}}, "_links": {"webui": "/spaces/DDLN/pages/%s"}}],
+			"size": 1, "_links": {"next": "%s"}}`, r.FormValue("start"), r.FormValue("start"), r.FormValue("start"), next)
+	}))
+	defer server.Close()
+
+	c := &Client{BaseURL: server.URL + "/wiki/", User: "user", Token: "token"}
+	pages, err := c.SpacePages("DDLN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Page{
+		{"0", "Page 0", 3, "<p>Body</p>", server.URL + "/wiki/spaces/DDLN/pages/0"},
+		{"50", "Page 50", 3, "<p>Body</p>", server.URL + "/wiki/spaces/DDLN/pages/50"},
+	}
+	if fmt.Sprint(pages) != fmt.Sprint(expected) {
+		t.Errorf("\nexpected %v,\n     got %v", expected, pages)
+	}
+
+	c.Token = "wrong"
+	if _, err := c.SpacePages("DDLN"); err == nil || err.Error() != fmt.Sprintf("Confluence request %s/wiki/rest/api/content?expand=body.storage%%2Cversion&limit=50&spaceKey=DDLN&start=0&type=page failed: 401 Unauthorized", server.URL) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
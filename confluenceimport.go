@@ -0,0 +1,101 @@
+// @llr REQ-0-DDLN-SWL-023
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/confluence"
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// ConfluenceConf is the configuration of the Confluence import, the "confluence" entry in
+// attributes.json.
+type ConfluenceConf struct {
+	URL   string `json:"url"`
+	Space string `json:"space"`
+	// Dir is where the pages are written, relative to the certification documents.
+	Dir string `json:"dir"`
+}
+
+const defaultConfluenceDir = "confluence"
+
+var reConfluenceVersion = regexp.MustCompile(`<meta name="confluence-version" content="(\d+)">`)
+
+// confluenceClient returns a client for the configured server. The credentials, if any, are
+// read from the git config.
+func (c ConfluenceConf) confluenceClient() *confluence.Client {
+	// Empty if not set, e.g. for public spaces.
+	user, _ := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.confluence-user"))
+	token, _ := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.confluence-token"))
+	return &confluence.Client{BaseURL: c.URL, User: user, Token: token}
+}
+
+// confluenceDocName returns the certification document name the page title starts with, e.g.
+// 0-DDLN-212-SDD for "0-DDLN-212-SDD Software Design", or "" if there's none.
+func confluenceDocName(title string) string {
+	fields := strings.Fields(title)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := strings.TrimRight(fields[0], ":")
+	if loc := reCertdoc.FindStringIndex(name); loc == nil || loc[0] != 0 || loc[1] != len(name) {
+		return ""
+	}
+	if IsValidDocName(name+".html") != nil {
+		return ""
+	}
+	return name
+}
+
+// importConfluencePages writes the pages having a certification document title as .html
+// certification documents in dir. Documents already imported from the same version of their
+// page are not rewritten. It returns the names of the files updated and of the files left
+// unchanged.
+func importConfluencePages(pages []confluence.Page, dir string) (updated, unchanged []string, err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	imported := map[string]string{}
+	for _, p := range pages {
+		name := confluenceDocName(p.Title)
+		if name == "" {
+			continue
+		}
+		if other, ok := imported[name]; ok {
+			return nil, nil, fmt.Errorf("Confluence pages '%s' and '%s' both define %s", other, p.Title, name)
+		}
+		imported[name] = p.Title
+
+		fileName := filepath.Join(dir, name+".html")
+		if b, err := ioutil.ReadFile(fileName); err == nil {
+			if m := reConfluenceVersion.FindSubmatch(b); m != nil && string(m[1]) == strconv.Itoa(p.Version) {
+				unchanged = append(unchanged, fileName)
+				continue
+			}
+		}
+		content := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<title>%s</title>
+<meta name="confluence-url" content="%s">
+<meta name="confluence-version" content="%d">
+</head>
+<body>
+%s
+</body>
+</html>
+`, html.EscapeString(p.Title), html.EscapeString(p.URL), p.Version, p.Body)
+		if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+			return nil, nil, err
+		}
+		updated = append(updated, fileName)
+	}
+	return updated, unchanged, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/confluence"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfluenceDocName(t *testing.T) {
+	assert.Equal(t, "0-DDLN-212-SDD", confluenceDocName("0-DDLN-212-SDD Software Design"))
+	assert.Equal(t, "0-DDLN-100-ORD", confluenceDocName("0-DDLN-100-ORD: Overall Requirements"))
+	assert.Equal(t, "", confluenceDocName("Meeting notes"))
+	assert.Equal(t, "", confluenceDocName("0-DDLN-211-SDD mismatched document type"))
+	assert.Equal(t, "", confluenceDocName(""))
+}
+
+func TestImportConfluencePages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "confluence")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pages := []confluence.Page{
+		{ID: "1", Title: "0-TEST-100-ORD System", Version: 1, URL: "https://wiki/1", Body: `<h2>REQ-0-TEST-SYS-001 First</h2>
+<p>Body.</p>
+<ul><li>Rationale: Because.</li><li>Verification: Test.</li><li>Safety impact: None.</li></ul>`},
+		{ID: "2", Title: "Index", Version: 1},
+	}
+	updated, unchanged, err := importConfluencePages(pages, dir)
+	assert.NoError(t, err)
+	fileName := filepath.Join(dir, "0-TEST-100-ORD.html")
+	assert.Equal(t, []string{fileName}, updated)
+	assert.Empty(t, unchanged)
+
+	reqs, err := ParseHTMLCertdoc(fileName)
+	if assert.NoError(t, err) && assert.Len(t, reqs, 1) {
+		assert.Equal(t, "REQ-0-TEST-SYS-001", reqs[0].ID)
+		assert.Equal(t, "<p>Body.</p>", string(reqs[0].Body))
+	}
+
+	// Same version, not rewritten.
+	updated, unchanged, err = importConfluencePages(pages, dir)
+	assert.NoError(t, err)
+	assert.Empty(t, updated)
+	assert.Equal(t, []string{fileName}, unchanged)
+
+	pages[0].Version = 2
+	updated, _, err = importConfluencePages(pages, dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{fileName}, updated)
+
+	_, _, err = importConfluencePages(append(pages, confluence.Page{Title: "0-TEST-100-ORD copy"}), dir)
+	assert.EqualError(t, err, "Confluence pages '0-TEST-100-ORD System' and '0-TEST-100-ORD copy' both define 0-TEST-100-ORD")
+}
@@ -0,0 +1,94 @@
+// @llr REQ-0-DDLN-SWL-036
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// reListTag matches the tags delimiting the HTML lists and their items.
+var reListTag = regexp.MustCompile(`(?i)<(/?)(ol|ul|li)\b[^>]*>`)
+
+// Criteria returns the acceptance criteria of r, the items of the first numbered list of its
+// Acceptance Criteria section. The nested lists are part of their item.
+func (r *Req) Criteria() []template.HTML {
+	var (
+		criteria []template.HTML
+		depth    int
+		start    int
+	)
+	ac := string(r.AcceptanceCriteria)
+	for _, m := range reListTag.FindAllStringSubmatchIndex(ac, -1) {
+		closing, tag := ac[m[2]:m[3]] == "/", strings.ToLower(ac[m[4]:m[5]])
+		switch {
+		case tag != "li" && !closing:
+			if depth == 0 && tag != "ol" {
+				continue
+			}
+			depth++
+		case tag != "li" && closing:
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				return criteria
+			}
+		case depth == 1 && !closing:
+			start = m[1]
+		case depth == 1 && closing:
+			criteria = append(criteria, template.HTML(strings.TrimSpace(ac[start:m[0]])))
+		}
+	}
+	return criteria
+}
+
+// CriterionCoverage is an acceptance criterion of a requirement with the code files verifying it.
+type CriterionCoverage struct {
+	// Number is the position of the criterion in its list, from 1.
+	Number int
+	Text   template.HTML
+	Files  []*Req
+}
+
+// CriteriaCoverage returns the acceptance criteria of r with the code files verifying each, or nil
+// if r has none.
+func (r *Req) CriteriaCoverage() []CriterionCoverage {
+	var coverage []CriterionCoverage
+	for i, text := range r.Criteria() {
+		c := CriterionCoverage{Number: i + 1, Text: text}
+		ref := fmt.Sprintf("%s#AC%d", r.ID, c.Number)
+		for _, code := range r.VerifiedBy {
+			for _, v := range code.VerifiedCriteria {
+				if v == ref {
+					c.Files = append(c.Files, code)
+					break
+				}
+			}
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage
+}
+
+// checkVerifiedCriteria returns the errors of the acceptance criteria referenced by the code file
+// which don't exist, one per line.
+func (rg reqGraph) checkVerifiedCriteria(code *Req) string {
+	var errs []string
+	for _, v := range code.VerifiedCriteria {
+		parts := strings.SplitN(v, "#AC", 2)
+		r := rg[parts[0]]
+		if r == nil {
+			// Reported as an invalid reference already.
+			continue
+		}
+		var n int
+		fmt.Sscan(parts[1], &n)
+		if n < 1 || n > len(r.Criteria()) {
+			errs = append(errs, "Invalid reference in file "+code.Path+": "+v+" does not exist.\n")
+		}
+	}
+	return strings.Join(errs, "")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReq_Criteria(t *testing.T) {
+	r := &Req{AcceptanceCriteria: `<ul><li>Not numbered</li></ul>
+<ol>
+<li>A dead link is reported.</li>
+<li><p>An ignored link is not checked:</p>
+<ol><li>Intranet</li><li>Login</li></ol></li>
+<li>The results are cached.</li>
+</ol>
+<ol><li>Another list</li></ol>`}
+	assert.Equal(t, []template.HTML{
+		"A dead link is reported.",
+		"<p>An ignored link is not checked:</p>\n<ol><li>Intranet</li><li>Login</li></ol>",
+		"The results are cached.",
+	}, r.Criteria())
+	assert.Empty(t, (&Req{Body: "<ol><li>Not a criterion</li></ol>"}).Criteria())
+}
+
+func TestParseCode_verifiedCriteria(t *testing.T) {
+	dir, err := ioutil.TempDir("", "criteria")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "links_test.go")
+	code := "/" + "/ @llr REQ-0-TEST-SWL-001\n" +
+		"func TestDead(t *testing.T) {} /" + "/ @" + "verifies REQ-0-TEST-SWL-001#AC1\n" +
+		"/" + "/ @" + "verifies REQ-0-TEST-SWL-002#AC1, @" + "verifies REQ-0-TEST-SWL-002#AC3\n"
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(code), 0644))
+
+	rg := reqGraph{}
+	assert.NoError(t, parseCode("links_test.go", fileName, codeLanguages[".go"].references(), rg))
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001"}, rg[fileName].ParentIds)
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002"}, rg[fileName].VerifiesIds)
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001#AC1", "REQ-0-TEST-SWL-002#AC1", "REQ-0-TEST-SWL-002#AC3"}, rg[fileName].VerifiedCriteria)
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{sys.ID}}
+	for i, ac := range []template.HTML{"<ol><li>Dead</li><li>Ignored</li></ol>", "<ol><li>Cached</li></ol>"} {
+		low := &Req{ID: fmt.Sprintf("REQ-0-TEST-SWL-%03d", i+1), Level: config.LOW, ParentIds: []string{high.ID}, Position: i,
+			AcceptanceCriteria: ac}
+		rg[low.ID] = low
+	}
+	rg[sys.ID], rg[high.ID] = sys, high
+	assert.EqualError(t, rg.Resolve(), "Invalid reference in file "+fileName+": REQ-0-TEST-SWL-002#AC3 does not exist.\n\n")
+
+	coverage := rg["REQ-0-TEST-SWL-001"].CriteriaCoverage()
+	if assert.Len(t, coverage, 2) {
+		assert.Equal(t, CriterionCoverage{1, "Dead", []*Req{rg[fileName]}}, coverage[0])
+		assert.Equal(t, CriterionCoverage{2, "Ignored", nil}, coverage[1])
+	}
+}
@@ -0,0 +1,76 @@
+// @llr REQ-0-DDLN-SWL-064
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func init() {
+	exporters["cypher"] = exportCypher
+}
+
+// exportCypher writes the graph as Cypher statements loading it into Neo4j, e.g. with
+// "cypher-shell -f graph.cypher", for the graph queries the filters don't cover, e.g. the shortest
+// paths from a system requirement to the code. The nodes and the edges are those of the json
+// export, see graphJSON: the requirements are labelled Requirement and their type, e.g. SWL, and
+// the code files Code, with the fields of NodeJSON as properties and the attributes as properties
+// of their name, and the edges are relationships of their kind, e.g. PARENT or
+// PARTIALLY_IMPLEMENTS. The statements merge on the IDs, so loading the graph again updates it.
+func exportCypher(rg reqGraph, w io.Writer) error {
+	g := rg.graphJSON()
+	labels := map[string]string{}
+	b := bufio.NewWriter(w)
+	for _, n := range g.Nodes {
+		label := "Requirement"
+		if n.Type == "CODE" {
+			label = "Code"
+		}
+		labels[n.ID] = label
+		var props []string
+		for _, p := range []struct{ name, value string }{
+			{"path", n.Path}, {"section", n.Section}, {"title", n.Title}, {"body", n.Body},
+			{"rationale", n.Rationale}, {"acceptanceCriteria", n.AcceptanceCriteria}, {"notes", n.Notes},
+			{"status", n.Status}, {"hash", n.Hash},
+		} {
+			if p.value != "" {
+				props = append(props, p.name+": "+cypherQuote(p.value))
+			}
+		}
+		if n.Deleted {
+			props = append(props, "deleted: true")
+		}
+		if n.TestEnv {
+			props = append(props, "testEnv: true")
+		}
+		names := make([]string, 0, len(n.Attributes))
+		for name := range n.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			props = append(props, "`"+strings.Replace(name, "`", "``", -1)+"`: "+cypherQuote(n.Attributes[name]))
+		}
+		fmt.Fprintf(b, "MERGE (n:%s {id: %s})", label, cypherQuote(n.ID))
+		if label == "Requirement" && n.Type != "" {
+			fmt.Fprintf(b, " SET n:%s", n.Type)
+		}
+		fmt.Fprintf(b, " SET n += {%s};\n", strings.Join(props, ", "))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(b, "MATCH (a:%s {id: %s}), (b:%s {id: %s}) MERGE (a)-[:%s]->(b);\n",
+			labels[e.From], cypherQuote(e.From), labels[e.To], cypherQuote(e.To),
+			strings.ToUpper(strings.Replace(e.Kind, "-", "_", -1)))
+	}
+	return b.Flush()
+}
+
+var cypherEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// cypherQuote returns s as a string literal of Cypher.
+func cypherQuote(s string) string {
+	return `"` + cypherEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportCypher(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "Fly"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Title: `Keep the "altitude"`,
+		Attributes: map[string]string{"VERIFICATION": "Test", "SAFETY IMPACT": "None"}, ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", ParentIds: []string{high.ID}}
+	code := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", FileHash: "sha1:aa", ParentIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high.ID: high, low.ID: low, code.Path: code}
+	assert.NoError(t, rg.Resolve())
+
+	var w bytes.Buffer
+	assert.NoError(t, rg.Export("cypher", &w))
+	assert.Equal(t, `MERGE (n:Requirement {id: "REQ-0-TEST-SWH-001"}) SET n:SWH SET n += {path: "certdocs/0-TEST-211-SRD.md", title: "Keep the \"altitude\"", status: "COMPLETED", `+"`SAFETY IMPACT`"+`: "None", `+"`VERIFICATION`"+`: "Test"};
+MERGE (n:Requirement {id: "REQ-0-TEST-SWL-001"}) SET n:SWL SET n += {path: "certdocs/0-TEST-212-SDD.md", status: "COMPLETED"};
+MERGE (n:Requirement {id: "REQ-0-TEST-SYS-001"}) SET n:SYS SET n += {path: "certdocs/0-TEST-100-ORD.md", title: "Fly", status: "COMPLETED"};
+MERGE (n:Code {id: "code/a.go"}) SET n += {path: "/repo/code/a.go", status: "COMPLETED", hash: "sha1:aa"};
+MATCH (a:Requirement {id: "REQ-0-TEST-SWH-001"}), (b:Requirement {id: "REQ-0-TEST-SYS-001"}) MERGE (a)-[:PARENT]->(b);
+MATCH (a:Requirement {id: "REQ-0-TEST-SWL-001"}), (b:Requirement {id: "REQ-0-TEST-SWH-001"}) MERGE (a)-[:PARENT]->(b);
+MATCH (a:Code {id: "code/a.go"}), (b:Requirement {id: "REQ-0-TEST-SWL-001"}) MERGE (a)-[:IMPLEMENTS]->(b);
+`, w.String())
+}
+
+func TestCypherQuote(t *testing.T) {
+	assert.Equal(t, `"a \"b\" \\ c\nd"`, cypherQuote("a \"b\" \\ c\nd"))
+}
@@ -0,0 +1,107 @@
+// @llr REQ-0-DDLN-SWL-074
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// The reasons of the dangling requirements.
+const (
+	danglingUnreachable = "not reachable from a system requirement"
+	danglingNoCode      = "no code references"
+)
+
+// DanglingReq is a requirement cut off from the traceability, with the reason.
+type DanglingReq struct {
+	Req    *Req
+	Reason string
+}
+
+// byDocumentPosition sorts the dangling requirements by document, then by position.
+type byDocumentPosition []DanglingReq
+
+func (a byDocumentPosition) Len() int      { return len(a) }
+func (a byDocumentPosition) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byDocumentPosition) Less(i, j int) bool {
+	if a[i].Req.Path != a[j].Req.Path {
+		return a[i].Req.Path < a[j].Req.Path
+	}
+	return a[i].Req.Position < a[j].Req.Position
+}
+
+// DanglingRequirements returns the requirements which are not reachable from any system
+// requirement through their parents, and the low-level requirements which no code file implements,
+// even partially, sorted by document and position. Unlike DanglingReqsByPosition, it doesn't need
+// the graph to resolve, so the requirements without parents are found too. The deleted
+// requirements are left out.
+func (rg reqGraph) DanglingRequirements() []DanglingReq {
+	reaches := map[*Req]bool{}
+	var reachesSystem func(r *Req, visiting map[*Req]bool) bool
+	reachesSystem = func(r *Req, visiting map[*Req]bool) bool {
+		if ok, known := reaches[r]; known {
+			return ok
+		}
+		if r.Level == config.SYSTEM {
+			return true
+		}
+		visiting[r] = true
+		ok := false
+		for _, p := range r.Parents {
+			if !visiting[p] && !p.IsDeleted() && reachesSystem(p, visiting) {
+				ok = true
+				break
+			}
+		}
+		delete(visiting, r)
+		reaches[r] = ok
+		return ok
+	}
+
+	var dangling []DanglingReq
+	for _, r := range rg {
+		if r.Level == config.CODE || r.IsDeleted() {
+			continue
+		}
+		if !reachesSystem(r, map[*Req]bool{}) {
+			dangling = append(dangling, DanglingReq{r, danglingUnreachable})
+			continue
+		}
+		if r.Level == config.LOW && !r.hasCode() {
+			dangling = append(dangling, DanglingReq{r, danglingNoCode})
+		}
+	}
+	sort.Sort(byDocumentPosition(dangling))
+	return dangling
+}
+
+// hasCode returns whether a code file implements the requirement, even partially.
+func (r *Req) hasCode() bool {
+	if len(r.PartiallyImplementedBy) > 0 {
+		return true
+	}
+	for _, c := range r.Children {
+		if c.Level == config.CODE {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteDangling writes the dangling requirements grouped by document, with their reasons, and
+// their number.
+func WriteDangling(w io.Writer, dangling []DanglingReq) {
+	doc := ""
+	for _, d := range dangling {
+		if path := strings.TrimPrefix(d.Req.Path, "/"); path != doc {
+			doc = path
+			fmt.Fprintf(w, "%s:\n", doc)
+		}
+		fmt.Fprintf(w, "\t%s %s: %s\n", d.Req.ID, d.Req.Title, d.Reason)
+	}
+	fmt.Fprintf(w, "%d dangling requirements\n", len(dangling))
+}
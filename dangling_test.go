@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_DanglingRequirements(t *testing.T) {
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SYS-001", Path: "/certdocs/0-TEST-100-ORD.md", Level: config.SYSTEM, Title: "DELETED"},
+		{ID: "REQ-0-TEST-SYS-002", Path: "/certdocs/0-TEST-100-ORD.md", Level: config.SYSTEM, Title: "Flight"},
+		{ID: "REQ-0-TEST-SWH-001", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, Title: "Steering", Position: 1, ParentIds: []string{"REQ-0-TEST-SYS-002"}},
+		{ID: "REQ-0-TEST-SWH-002", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, Title: "Braking", Position: 2, ParentIds: []string{"REQ-0-TEST-SYS-001"}},
+		{ID: "REQ-0-TEST-SWH-003", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, Title: "Orphan", Position: 3},
+		{ID: "REQ-0-TEST-SWL-001", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "Implemented", Position: 1, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		{ID: "REQ-0-TEST-SWL-002", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "Not implemented", Position: 2, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		{ID: "REQ-0-TEST-SWL-003", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "Under a deleted one", Position: 3, ParentIds: []string{"REQ-0-TEST-SWH-002"}},
+		{ID: "REQ-0-TEST-SWL-004", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "DELETED", Position: 4, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+	} {
+		rg[r.ID] = r
+	}
+	rg["/repo/a.go"] = &Req{ID: "a.go", Path: "a.go", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-003"}}
+	// The orphan fails the resolution, but the links are made.
+	assert.Error(t, rg.Resolve())
+
+	dangling := rg.DanglingRequirements()
+	var b bytes.Buffer
+	WriteDangling(&b, dangling)
+	assert.Equal(t, `certdocs/0-TEST-211-SRD.md:
+	REQ-0-TEST-SWH-002 Braking: not reachable from a system requirement
+	REQ-0-TEST-SWH-003 Orphan: not reachable from a system requirement
+certdocs/0-TEST-212-SDD.md:
+	REQ-0-TEST-SWL-002 Not implemented: no code references
+	REQ-0-TEST-SWL-003 Under a deleted one: not reachable from a system requirement
+4 dangling requirements
+`, b.String())
+
+	b.Reset()
+	WriteDangling(&b, nil)
+	assert.Equal(t, "0 dangling requirements\n", b.String())
+}
@@ -0,0 +1,135 @@
+// @llr REQ-0-DDLN-SWL-075
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// Deletion is a requirement marked DELETED, with the commit which marked it, and the requirements
+// and code files still referencing it.
+type Deletion struct {
+	Req *Req
+	// Commit, Date and Author are empty if the deletion isn't committed yet.
+	Commit string
+	Date   string
+	Author string
+	// References are the IDs of the requirements and code files which still reference the
+	// requirement, sorted.
+	References []string
+}
+
+// deletionCommits returns the versions of a certification document in which its requirements were
+// marked DELETED, by ID, out of its versions oldest first. A requirement deleted, then restored and
+// deleted again has the last of its deletions.
+func deletionCommits(versions []docVersion) map[string]docVersion {
+	deleted := map[string]docVersion{}
+	previous := map[string]*Req{}
+	for _, v := range versions {
+		current := map[string]*Req{}
+		for _, r := range v.Reqs {
+			current[r.ID] = r
+			if !r.IsDeleted() {
+				continue
+			}
+			// A requirement added as DELETED was deleted when added.
+			if p, ok := previous[r.ID]; !ok || !p.IsDeleted() {
+				deleted[r.ID] = v
+			}
+		}
+		previous = current
+	}
+	return deleted
+}
+
+// references returns the IDs of the requirements and code files of the graph which reference the
+// requirement, as a parent, as verified or through an annotation, sorted. The deleted requirements
+// are left out.
+func (rg reqGraph) references(id string) []string {
+	var refs []string
+	for _, n := range rg {
+		if n.IsDeleted() {
+			continue
+		}
+		var ids []string
+		ids = append(ids, n.ParentIds...)
+		ids = append(ids, n.VerifiesIds...)
+		for _, a := range n.Annotations {
+			ids = append(ids, a.ID)
+		}
+		for _, i := range ids {
+			if i == id {
+				refs = append(refs, n.ID)
+				break
+			}
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Deletions returns the requirements of the graph marked DELETED, sorted by ID, with the commit
+// which marked them, found in the git history of their certification documents, its author, and
+// what still references them.
+func (rg reqGraph) Deletions() ([]*Deletion, error) {
+	byPath := map[string][]*Req{}
+	for _, r := range rg {
+		if r.Level != config.CODE && r.IsDeleted() {
+			byPath[r.Path] = append(byPath[r.Path], r)
+		}
+	}
+
+	var deletions []*Deletion
+	for p, reqs := range byPath {
+		versions, err := certdocHistory(filepath.Join(git.RepoPath(), p))
+		if err != nil {
+			return nil, err
+		}
+		commits := deletionCommits(versions)
+		for _, r := range reqs {
+			d := &Deletion{Req: r, References: rg.references(r.ID)}
+			if v, ok := commits[r.ID]; ok {
+				d.Commit, d.Date = v.Commit, v.Date
+				if d.Author, err = git.CommitAuthor(v.Commit); err != nil {
+					return nil, err
+				}
+			}
+			deletions = append(deletions, d)
+		}
+	}
+	sort.Sort(byDeletionID(deletions))
+	return deletions, nil
+}
+
+// byDeletionID sorts the deletions by the ID of their requirement.
+type byDeletionID []*Deletion
+
+func (a byDeletionID) Len() int           { return len(a) }
+func (a byDeletionID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byDeletionID) Less(i, j int) bool { return a[i].Req.ID < a[j].Req.ID }
+
+type deletionsReportData struct {
+	Deletions []*Deletion
+	Filter    ReqFilter
+	Diffs     map[string][]string
+}
+
+// ReportDeletions writes an HTML report of the deleted requirements matching the filter and the
+// diffs, with when and by whom they were deleted and what still references them, for the
+// configuration management records.
+func (rg reqGraph) ReportDeletions(w io.Writer, f ReqFilter, diffs map[string][]string) error {
+	deletions, err := rg.Deletions()
+	if err != nil {
+		return err
+	}
+	return writeDeletionsReport(w, deletions, f, diffs)
+}
+
+// writeDeletionsReport writes the HTML report of the deletions.
+func writeDeletionsReport(w io.Writer, deletions []*Deletion, f ReqFilter, diffs map[string][]string) error {
+	return reportTmpl.ExecuteTemplate(w, "DELETIONS", deletionsReportData{deletions, f, diffs})
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeletionCommits(t *testing.T) {
+	versions := []docVersion{
+		{Commit: "c1", Date: "2020-01-01", Reqs: []*Req{{ID: "REQ-0-TEST-SWH-001", Title: "Steering"}, {ID: "REQ-0-TEST-SWH-002", Title: "Braking"}}},
+		{Commit: "c2", Date: "2020-01-02", Reqs: []*Req{{ID: "REQ-0-TEST-SWH-001", Title: "DELETED"}, {ID: "REQ-0-TEST-SWH-002", Title: "DELETED"}}},
+		{Commit: "c3", Date: "2020-01-03", Reqs: []*Req{{ID: "REQ-0-TEST-SWH-001", Title: "DELETED"}, {ID: "REQ-0-TEST-SWH-002", Title: "Braking"}, {ID: "REQ-0-TEST-SWH-003", Title: "DELETED"}}},
+		{Commit: "c4", Date: "2020-01-04", Reqs: []*Req{{ID: "REQ-0-TEST-SWH-001", Title: "DELETED"}, {ID: "REQ-0-TEST-SWH-002", Title: "DELETED Braking"}, {ID: "REQ-0-TEST-SWH-003", Title: "DELETED"}}},
+	}
+	commits := deletionCommits(versions)
+	assert.Len(t, commits, 3)
+	assert.Equal(t, "c2", commits["REQ-0-TEST-SWH-001"].Commit)
+	// Restored, then deleted again.
+	assert.Equal(t, "c4", commits["REQ-0-TEST-SWH-002"].Commit)
+	// Added as deleted.
+	assert.Equal(t, "c3", commits["REQ-0-TEST-SWH-003"].Commit)
+}
+
+func TestReqGraph_ReportDeletions(t *testing.T) {
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SWH-001", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, Title: "DELETED"},
+		{ID: "REQ-0-TEST-SWH-002", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, Title: "DELETED Braking"},
+		{ID: "REQ-0-TEST-SWL-001", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "Steering", ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		{ID: "REQ-0-TEST-SWL-002", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "DELETED", ParentIds: []string{"REQ-0-TEST-SWH-002"}},
+	} {
+		rg[r.ID] = r
+	}
+	rg["/repo/a_test.go"] = &Req{ID: "a_test.go", Level: config.CODE, VerifiesIds: []string{"REQ-0-TEST-SWH-001"}}
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001", "a_test.go"}, rg.references("REQ-0-TEST-SWH-001"))
+	// Only deleted requirements reference it.
+	assert.Empty(t, rg.references("REQ-0-TEST-SWH-002"))
+
+	deletions := []*Deletion{
+		{Req: rg["REQ-0-TEST-SWH-001"], Commit: "c2", Date: "2020-01-02", Author: "Jane Doe", References: rg.references("REQ-0-TEST-SWH-001")},
+		{Req: rg["REQ-0-TEST-SWH-002"]},
+	}
+	var b bytes.Buffer
+	assert.NoError(t, writeDeletionsReport(&b, deletions, nil, nil))
+	report := b.String()
+	assert.Contains(t, report, "<strong>REQ-0-TEST-SWH-001</strong>")
+	assert.Contains(t, report, "2020-01-02 <small>c2</small>")
+	assert.Contains(t, report, "Jane Doe")
+	assert.Contains(t, report, `<span class="text-danger">a_test.go</span>`)
+	assert.Contains(t, report, "Not committed")
+}
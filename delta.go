@@ -0,0 +1,204 @@
+// @llr REQ-0-DDLN-SWL-052
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+)
+
+func init() {
+	exporters["snapshot"] = func(rg reqGraph, w io.Writer) error {
+		b, err := rg.snapshot()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	// The delta is written by WriteDelta, registered so the matrices can't take the name.
+	exporters["delta"] = func(rg reqGraph, w io.Writer) error {
+		return fmt.Errorf("The delta format needs the snapshot the changes are relative to")
+	}
+}
+
+// GraphDelta is the difference between two snapshots of the requirement graph, to be applied to
+// the older one by the downstream tools, e.g. dashboards or DOORS sync jobs, instead of importing
+// the whole graph again.
+type GraphDelta struct {
+	// Added are the requirements and the code files not in the older snapshot, with all their
+	// fields as in the snapshots.
+	Added []map[string]interface{} `json:"added"`
+	// Changed are the fields changed of the others, the links apart.
+	Changed []NodeChange `json:"changed"`
+	// Removed are the IDs of the requirements and the code files not in the newer snapshot.
+	Removed []string `json:"removed"`
+	// AddedLinks and RemovedLinks are the links from the parents to the children added and
+	// removed, including those of the requirements added and removed.
+	AddedLinks   []DeltaLink `json:"addedLinks"`
+	RemovedLinks []DeltaLink `json:"removedLinks"`
+}
+
+// NodeChange are the changed fields of a requirement or a code file, with their new values, null
+// for the fields removed.
+type NodeChange struct {
+	ID     string                 `json:"id"`
+	Fields map[string]interface{} `json:"fields"`
+}
+
+// DeltaLink is a link of the graph, from a parent to a child.
+type DeltaLink struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+}
+
+// loadSnapshot reads a snapshot of the requirement graph, e.g. the graph.json of an archive or the
+// output of the snapshot export, keyed by ID.
+func loadSnapshot(fileName string) (map[string]map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeSnapshot(b, fileName)
+}
+
+func decodeSnapshot(b []byte, fileName string) (map[string]map[string]interface{}, error) {
+	var reqs []map[string]interface{}
+	if err := json.Unmarshal(b, &reqs); err != nil {
+		return nil, fmt.Errorf("Error while parsing the snapshot %s: %v", fileName, err)
+	}
+	nodes := map[string]map[string]interface{}{}
+	for i, r := range reqs {
+		id, ok := r["id"].(string)
+		if !ok {
+			return nil, fmt.Errorf("Error while parsing the snapshot %s: entry %d has no id", fileName, i)
+		}
+		nodes[id] = r
+	}
+	return nodes, nil
+}
+
+// Delta returns the difference between the snapshot old and the graph, sorted by ID. The hashes of
+// the code files are compared even if the snapshot has them of another algorithm, see
+// sameFileContent.
+func (rg reqGraph) Delta(old map[string]map[string]interface{}) (*GraphDelta, error) {
+	// The graph goes through json as the snapshots, so the values compare equal.
+	b, err := rg.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := decodeSnapshot(b, "of the graph")
+	if err != nil {
+		return nil, err
+	}
+
+	// The lists are empty rather than null in json when there are no changes.
+	d := &GraphDelta{Added: []map[string]interface{}{}, Changed: []NodeChange{}, Removed: []string{},
+		AddedLinks: []DeltaLink{}, RemovedLinks: []DeltaLink{}}
+	for _, id := range sortedNodeIDs(nodes) {
+		n, o := nodes[id], old[id]
+		if o == nil {
+			d.Added = append(d.Added, n)
+			continue
+		}
+		fields := map[string]interface{}{}
+		for name, v := range n {
+			if name == "hash" {
+				// The snapshots written before the hashes were exported can't tell the changes.
+				if h, ok := o[name].(string); ok && !sameFileContent(n["path"].(string), v.(string), h) {
+					fields[name] = v
+				}
+				continue
+			}
+			if name != "parents" && name != "children" && !reflect.DeepEqual(v, o[name]) {
+				fields[name] = v
+			}
+		}
+		for name := range o {
+			if _, ok := n[name]; !ok && name != "parents" && name != "children" {
+				fields[name] = nil
+			}
+		}
+		if len(fields) > 0 {
+			d.Changed = append(d.Changed, NodeChange{id, fields})
+		}
+	}
+	for _, id := range sortedNodeIDs(old) {
+		if nodes[id] == nil {
+			d.Removed = append(d.Removed, id)
+		}
+	}
+
+	newLinks, oldLinks := snapshotLinks(nodes), snapshotLinks(old)
+	for _, l := range sortedLinks(newLinks) {
+		if !oldLinks[l] {
+			d.AddedLinks = append(d.AddedLinks, l)
+		}
+	}
+	for _, l := range sortedLinks(oldLinks) {
+		if !newLinks[l] {
+			d.RemovedLinks = append(d.RemovedLinks, l)
+		}
+	}
+	return d, nil
+}
+
+// WriteDelta writes the difference between the snapshot old and the graph as json.
+func (rg reqGraph) WriteDelta(old map[string]map[string]interface{}, w io.Writer) error {
+	d, err := rg.Delta(old)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func sortedNodeIDs(nodes map[string]map[string]interface{}) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// snapshotLinks returns the links of the snapshot, from the parents of its nodes.
+func snapshotLinks(nodes map[string]map[string]interface{}) map[DeltaLink]bool {
+	links := map[DeltaLink]bool{}
+	for id, n := range nodes {
+		parents, _ := n["parents"].([]interface{})
+		for _, p := range parents {
+			if parent, ok := p.(string); ok {
+				links[DeltaLink{parent, id}] = true
+			}
+		}
+	}
+	return links
+}
+
+type byParentChild []DeltaLink
+
+func (a byParentChild) Len() int      { return len(a) }
+func (a byParentChild) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byParentChild) Less(i, j int) bool {
+	if a[i].Parent != a[j].Parent {
+		return a[i].Parent < a[j].Parent
+	}
+	return a[i].Child < a[j].Child
+}
+
+func sortedLinks(links map[DeltaLink]bool) []DeltaLink {
+	sorted := make([]DeltaLink, 0, len(links))
+	for l := range links {
+		sorted = append(sorted, l)
+	}
+	sort.Sort(byParentChild(sorted))
+	return sorted
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_Delta(t *testing.T) {
+	graph := func(reqs ...*Req) reqGraph {
+		rg := reqGraph{}
+		for _, r := range reqs {
+			rg[r.ID] = r
+		}
+		assert.NoError(t, rg.Resolve())
+		return rg
+	}
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "System"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Title: "High",
+		ParentIds: []string{sys.ID}}
+	removed := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Title: "Removed",
+		ParentIds: []string{sys.ID}}
+	var b bytes.Buffer
+	assert.NoError(t, graph(sys, high, removed).Export("snapshot", &b))
+	old, err := decodeSnapshot(b.Bytes(), "old.json")
+	assert.NoError(t, err)
+
+	sys = &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "System"}
+	sys2 := &Req{ID: "REQ-0-TEST-SYS-002", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "Other system"}
+	high = &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Title: "Higher",
+		ParentIds: []string{sys2.ID}}
+	d, err := graph(sys, sys2, high).Delta(old)
+	assert.NoError(t, err)
+
+	if assert.Len(t, d.Added, 1) {
+		assert.Equal(t, "REQ-0-TEST-SYS-002", d.Added[0]["id"])
+	}
+	// The status of the system requirement is that of its children.
+	assert.Equal(t, []NodeChange{
+		{"REQ-0-TEST-SWH-001", map[string]interface{}{"title": "Higher"}},
+		{"REQ-0-TEST-SYS-001", map[string]interface{}{"status": "NOT STARTED"}},
+	}, d.Changed)
+	assert.Equal(t, []string{"REQ-0-TEST-SWH-002"}, d.Removed)
+	assert.Equal(t, []DeltaLink{{"REQ-0-TEST-SYS-002", "REQ-0-TEST-SWH-001"}}, d.AddedLinks)
+	assert.Equal(t, []DeltaLink{{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SWH-001"}, {"REQ-0-TEST-SYS-001", "REQ-0-TEST-SWH-002"}},
+		d.RemovedLinks)
+
+	b.Reset()
+	assert.NoError(t, graph(sys, sys2, high).WriteDelta(old, &b))
+	var decoded GraphDelta
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, d.Removed, decoded.Removed)
+
+	_, err = decodeSnapshot([]byte(`[{"title": "No ID"}]`), "bad.json")
+	assert.EqualError(t, err, "Error while parsing the snapshot bad.json: entry 0 has no id")
+}
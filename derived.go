@@ -0,0 +1,199 @@
+// @llr REQ-0-DDLN-SWL-072
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// DerivedConf configures the traceability of the rationale of the derived requirements, from the
+// "derived" entry of attributes.json, e.g.:
+//
+//	"derived": {
+//		"attribute": "Derived", "value": "Yes",
+//		"rationale": "Rationale", "records": "DDR-[0-9]+",
+//		"dir": "design/decisions"
+//	}
+//
+// The rationale of each derived requirement must reference the records of the analyses or design
+// decisions justifying it, e.g. DDR-0042, each a file of the directory named after it, e.g.
+// design/decisions/DDR-0042-sensor-fusion.md. The empty fields take the values of
+// defaultDerivedConf, and without a directory the records aren't looked up.
+type DerivedConf struct {
+	// Attribute marks the derived requirements, whose value matches the regular expression Value
+	// entirely.
+	Attribute string `json:"attribute"`
+	Value     string `json:"value"`
+	// Rationale is the attribute justifying the derived requirements.
+	Rationale string `json:"rationale"`
+	// Records is the regular expression of the IDs of the records referenced in the rationale.
+	Records string `json:"records"`
+	// Dir is the directory of the records, relative to the repository root.
+	Dir string `json:"dir"`
+}
+
+var defaultDerivedConf = DerivedConf{
+	Attribute: "Derived",
+	Value:     "(?i)yes|true",
+	Rationale: "Rationale",
+	Records:   "DDR-[0-9]+",
+}
+
+// DerivedReq is a derived requirement with the records referenced by its rationale.
+type DerivedReq struct {
+	Req       *Req
+	Rationale string
+	Records   []DerivedRecord
+}
+
+// DerivedRecord is a record referenced by the rationale of a derived requirement, with the path of
+// its file relative to the repository root, empty if it wasn't found.
+type DerivedRecord struct {
+	ID   string
+	Path string
+}
+
+// withDefaults returns the configuration with the empty fields set to the defaults.
+func (c DerivedConf) withDefaults() DerivedConf {
+	if c.Attribute == "" {
+		c.Attribute = defaultDerivedConf.Attribute
+	}
+	if c.Value == "" {
+		c.Value = defaultDerivedConf.Value
+	}
+	if c.Rationale == "" {
+		c.Rationale = defaultDerivedConf.Rationale
+	}
+	if c.Records == "" {
+		c.Records = defaultDerivedConf.Records
+	}
+	return c
+}
+
+// DerivedRequirements returns the derived requirements of the graph, sorted by ID, with the records
+// referenced by their rationale looked up in the directory of the records of the repository at
+// repoPath. The code files and the deleted requirements are left out.
+func (rg reqGraph) DerivedRequirements(conf DerivedConf, repoPath string) ([]*DerivedReq, error) {
+	conf = conf.withDefaults()
+	derived, err := regexp.Compile("^(?:" + conf.Value + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regular expression %q of the derived attribute: %v", conf.Value, err)
+	}
+	records, err := regexp.Compile(conf.Records)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid regular expression %q of the records: %v", conf.Records, err)
+	}
+	var files []string
+	if conf.Dir != "" {
+		infos, err := ioutil.ReadDir(filepath.Join(repoPath, conf.Dir))
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading the records: %v", err)
+		}
+		for _, info := range infos {
+			if !info.IsDir() {
+				files = append(files, info.Name())
+			}
+		}
+	}
+
+	var reqs []*DerivedReq
+	for _, r := range rg {
+		if r.Level == config.CODE || r.IsDeleted() || !derived.MatchString(r.Attributes[strings.ToUpper(conf.Attribute)]) {
+			continue
+		}
+		d := &DerivedReq{Req: r, Rationale: r.Attributes[strings.ToUpper(conf.Rationale)]}
+		seen := map[string]bool{}
+		for _, id := range records.FindAllString(d.Rationale, -1) {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			record := DerivedRecord{ID: id}
+			for _, f := range files {
+				if isRecordFile(f, id) {
+					record.Path = filepath.ToSlash(filepath.Join(conf.Dir, f))
+					break
+				}
+			}
+			d.Records = append(d.Records, record)
+		}
+		reqs = append(reqs, d)
+	}
+	sort.Sort(byDerivedID(reqs))
+	return reqs, nil
+}
+
+// isRecordFile returns whether the file name is that of the record, named after its ID possibly
+// followed by a title or an extension, e.g. DDR-0042-sensor-fusion.md but not DDR-00421.md.
+func isRecordFile(name, id string) bool {
+	if !strings.HasPrefix(name, id) {
+		return false
+	}
+	if len(name) == len(id) {
+		return true
+	}
+	next := rune(name[len(id)])
+	return !unicode.IsLetter(next) && !unicode.IsDigit(next)
+}
+
+// CheckDerived returns the findings of the derived requirements without rationale, whose rationale
+// references no record, or references records not found when the directory of the records is
+// configured. Nothing is checked without configuration.
+func (rg reqGraph) CheckDerived(conf *DerivedConf, repoPath string) ([]error, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	reqs, err := rg.DerivedRequirements(*conf, repoPath)
+	if err != nil {
+		return nil, err
+	}
+	c := conf.withDefaults()
+	var errs []error
+	for _, d := range reqs {
+		switch {
+		case d.Rationale == "":
+			errs = append(errs, fmt.Errorf("Derived requirement '%s' has no attribute '%s'.\n", d.Req.ID, c.Rationale))
+		case len(d.Records) == 0:
+			errs = append(errs, fmt.Errorf("Attribute '%s' of derived requirement '%s' references no record matching %s.\n", c.Rationale, d.Req.ID, c.Records))
+		}
+		for _, record := range d.Records {
+			if record.Path == "" && c.Dir != "" {
+				errs = append(errs, fmt.Errorf("Derived requirement '%s' references record %s, not found in %s.\n", d.Req.ID, record.ID, c.Dir))
+			}
+		}
+	}
+	return errs, nil
+}
+
+type derivedReportData struct {
+	Reqs   []*DerivedReq
+	Conf   DerivedConf
+	Filter ReqFilter
+	Diffs  map[string][]string
+}
+
+// ReportDerived writes an HTML report of the derived requirements matching the filter and the
+// diffs, with their parents, their rationale and the records it references, for the safety
+// assessment.
+func (rg reqGraph) ReportDerived(w io.Writer, conf DerivedConf, repoPath string, f ReqFilter, diffs map[string][]string) error {
+	reqs, err := rg.DerivedRequirements(conf, repoPath)
+	if err != nil {
+		return err
+	}
+	return reportTmpl.ExecuteTemplate(w, "DERIVED", derivedReportData{reqs, conf.withDefaults(), f, diffs})
+}
+
+// byDerivedID sorts the derived requirements by ID.
+type byDerivedID []*DerivedReq
+
+func (a byDerivedID) Len() int           { return len(a) }
+func (a byDerivedID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byDerivedID) Less(i, j int) bool { return a[i].Req.ID < a[j].Req.ID }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_CheckDerived(t *testing.T) {
+	repo, err := ioutil.TempDir("", "reqtraq-derived-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(repo)
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, "design", "decisions"), 0755))
+	for _, name := range []string{"DDR-0042-sensor-fusion.md", "DDR-00431.md"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(repo, "design", "decisions", name), nil, 0644))
+	}
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{sys.ID}}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	for id, attributes := range map[string]map[string]string{
+		"REQ-0-TEST-SWL-001": {"DERIVED": "Yes", "RATIONALE": "See DDR-0042 and DDR-0042."},
+		"REQ-0-TEST-SWL-002": {"DERIVED": "Yes", "RATIONALE": "See DDR-0043."},
+		"REQ-0-TEST-SWL-003": {"DERIVED": "true", "RATIONALE": "Obvious."},
+		"REQ-0-TEST-SWL-004": {"DERIVED": "yes"},
+		"REQ-0-TEST-SWL-005": {"DERIVED": "No"},
+	} {
+		rg[id] = &Req{ID: id, Level: config.LOW, ParentIds: []string{high.ID}, Attributes: attributes}
+	}
+	assert.NoError(t, rg.Resolve())
+
+	errs, err := rg.CheckDerived(nil, repo)
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+
+	conf := &DerivedConf{Dir: "design/decisions"}
+	errs, err = rg.CheckDerived(conf, repo)
+	assert.NoError(t, err)
+	assert.Equal(t, []error{
+		errors.New("Derived requirement 'REQ-0-TEST-SWL-002' references record DDR-0043, not found in design/decisions.\n"),
+		errors.New("Attribute 'Rationale' of derived requirement 'REQ-0-TEST-SWL-003' references no record matching DDR-[0-9]+.\n"),
+		errors.New("Derived requirement 'REQ-0-TEST-SWL-004' has no attribute 'Rationale'.\n"),
+	}, errs)
+
+	reqs, err := rg.DerivedRequirements(*conf, repo)
+	assert.NoError(t, err)
+	assert.Len(t, reqs, 4)
+	assert.Equal(t, []DerivedRecord{{"DDR-0042", "design/decisions/DDR-0042-sensor-fusion.md"}}, reqs[0].Records)
+
+	_, err = rg.CheckDerived(&DerivedConf{Dir: "missing"}, repo)
+	assert.Error(t, err)
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportDerived(&b, *conf, repo, nil, nil))
+	report := b.String()
+	assert.Contains(t, report, "<strong>REQ-0-TEST-SWL-001</strong>")
+	assert.Contains(t, report, "DDR-0042 <small>design/decisions/DDR-0042-sensor-fusion.md</small>")
+	assert.Contains(t, report, `<span class="text-danger">DDR-0043 not found</span>`)
+	assert.NotContains(t, report, "REQ-0-TEST-SWL-005")
+}
@@ -1,4 +1,4 @@
-//@llr REQ-0-DDLN-SWL-008
+// @llr REQ-0-DDLN-SWL-008
 package main
 
 import (
@@ -86,7 +86,7 @@ func (r *Req) ChangedSince(pr *Req) (diffs []string) {
 		// only bother with the bodies if the titles are the same
 		// compare modulo spaces and punctuation, ie only the letters
 
-		if onlyLetters(string(r.Body)) != onlyLetters(string(pr.Body)) {
+		if onlyLetters(string(r.LoadBody())) != onlyLetters(string(pr.LoadBody())) {
 			diffs = append(diffs, fmt.Sprintf("Body changed"))
 		}
 	}
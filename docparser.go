@@ -0,0 +1,104 @@
+// @llr REQ-0-DDLN-SWL-001
+package main
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DocParser parses the certification documents of a format. The parsers register themselves
+// with RegisterDocParser, by extension or MIME type.
+type DocParser interface {
+	// ParseDoc returns the requirements of the document, in document order. The errors are those
+	// of the requirements which failed to parse, which are missing from the result, or a single
+	// error if the document could not be parsed at all.
+	ParseDoc(fileName string) ([]*Req, []error)
+}
+
+// DocParserFunc adapts a function parsing a whole document, and failing at the first error, to a
+// DocParser.
+type DocParserFunc func(fileName string) ([]*Req, error)
+
+func (f DocParserFunc) ParseDoc(fileName string) ([]*Req, []error) {
+	reqs, err := f(fileName)
+	if err != nil {
+		return nil, []error{fmt.Errorf("Error parsing %s: %v", fileName, err)}
+	}
+	return reqs, nil
+}
+
+// RawDocParserFunc adapts a function splitting a document into the text of its requirements,
+// each parsed with ParseReq, to a DocParser.
+type RawDocParserFunc func(fileName string) ([]string, error)
+
+func (f RawDocParserFunc) ParseDoc(fileName string) ([]*Req, []error) {
+	raw, err := f(fileName)
+	if err != nil {
+		return nil, []error{fmt.Errorf("Error parsing %s: %v", fileName, err)}
+	}
+	var (
+		reqs []*Req
+		errs []error
+	)
+	for _, v := range raw {
+		r, err := ParseReq(v)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, errs
+}
+
+var (
+	docParsersByExt  = map[string]DocParser{}
+	docParsersByType = map[string]DocParser{}
+)
+
+// RegisterDocParser registers p as the parser of the certification documents with the given
+// extensions, e.g. ".md", or MIME types, e.g. "text/markdown". The extensions take precedence
+// over the MIME types, which are found from the extensions as by mime.TypeByExtension. It panics
+// if an extension or a type is registered twice.
+func RegisterDocParser(p DocParser, extsOrTypes ...string) {
+	for _, e := range extsOrTypes {
+		parsers := docParsersByType
+		if strings.HasPrefix(e, ".") {
+			parsers = docParsersByExt
+		}
+		e = strings.ToLower(e)
+		if _, ok := parsers[e]; ok {
+			panic("certification document parser registered twice for " + e)
+		}
+		parsers[e] = p
+	}
+}
+
+// docParser returns the parser of the certification document, or nil if its format isn't
+// supported.
+func docParser(fileName string) DocParser {
+	ext := strings.ToLower(path.Ext(fileName))
+	if p, ok := docParsersByExt[ext]; ok {
+		return p
+	}
+	if ext == "" {
+		return nil
+	}
+	if t, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil {
+		return docParsersByType[t]
+	}
+	return nil
+}
+
+// docExtensions returns the extensions registered, sorted.
+func docExtensions() []string {
+	var exts []string
+	for e := range docParsersByExt {
+		exts = append(exts, e)
+	}
+	sort.Strings(exts)
+	return exts
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocParser(t *testing.T) {
+	assert.Equal(t, []string{".htm", ".html", ".lyx", ".md", ".odt", ".org", ".toml"}, docExtensions())
+	assert.NotNil(t, docParser("certdocs/0-DDLN-212-SDD.MD"))
+	assert.Nil(t, docParser("certdocs/0-DDLN-212-SDD.txt"))
+	assert.Nil(t, docParser("certdocs/README"))
+
+	// Found by MIME type.
+	assert.NoError(t, mime.AddExtensionType(".reqtraqtest", "text/x-reqtraq-test"))
+	p := DocParserFunc(func(string) ([]*Req, error) { return nil, errors.New("test") })
+	RegisterDocParser(p, "text/x-reqtraq-test")
+	defer delete(docParsersByType, "text/x-reqtraq-test")
+	_, errs := docParser("certdocs/0-DDLN-212-SDD.reqtraqtest").ParseDoc("0-DDLN-212-SDD.reqtraqtest")
+	assert.Equal(t, []error{errors.New("Error parsing 0-DDLN-212-SDD.reqtraqtest: test")}, errs)
+
+	assert.Panics(t, func() { RegisterDocParser(p, ".MD") })
+}
+
+func TestRawDocParserFunc(t *testing.T) {
+	p := RawDocParserFunc(func(string) ([]string, error) {
+		return []string{
+			"REQ-0-TEST-SWH-001 First\nBody\n###### Attributes:\n- Rationale: None\n",
+			"Not a requirement",
+			"REQ-0-TEST-SWH-003 Third\nBody\n###### Attributes:\n- Rationale: None\n",
+		}, nil
+	})
+	reqs, errs := p.ParseDoc("0-TEST-211-SRD.md")
+	assert.Len(t, errs, 1)
+	if assert.Len(t, reqs, 2) {
+		assert.Equal(t, "REQ-0-TEST-SWH-001", reqs[0].ID)
+		assert.Equal(t, "REQ-0-TEST-SWH-003", reqs[1].ID)
+	}
+}
@@ -0,0 +1,139 @@
+// @llr REQ-0-DDLN-SWL-025
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// The IBM DOORS CSV import creates one object per row in the module named in the first column.
+// Links are given as <module>/<absolute number>, the absolute number being the position of the
+// object in its module.
+
+var (
+	reBlankLines  = regexp.MustCompile(`\n\s*\n\s*`)
+	doorsColumns  = []string{"Module", "Absolute Number", "Object Identifier", "Object Heading", "Object Text"}
+	doorsLinkCols = []string{"In-links", "Out-links", "Code"}
+)
+
+// doorsObject is a requirement with its place in DOORS.
+type doorsObject struct {
+	index  int // in the exported rows
+	module string
+	number int
+}
+
+func (o *doorsObject) link() string {
+	return fmt.Sprintf("%s/%d", o.module, o.number)
+}
+
+// doorsModule returns the DOORS module of the requirements of a certification document, the
+// name of the document, e.g. 0-DDLN-211-SRD.
+func doorsModule(certdoc string) string {
+	base := filepath.Base(certdoc)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// doorsText converts the HTML body of a requirement to the text of a DOORS object.
+func doorsText(body string) string {
+	return reBlankLines.ReplaceAllString(plainText(body), "\n\n")
+}
+
+// exportDOORS writes the requirements as CSV for the IBM DOORS import, sorted by module and by
+// position. The deleted requirements are not exported.
+func exportDOORS(rg reqGraph, w io.Writer) error {
+	var reqs []*Req
+	attributes := map[string]bool{}
+	for _, r := range rg {
+		if r.Level == config.CODE || r.IsDeleted() {
+			continue
+		}
+		reqs = append(reqs, r)
+		for a := range r.Attributes {
+			if a != "PARENTS" {
+				attributes[a] = true
+			}
+		}
+	}
+	sort.Sort(byModulePosition(reqs))
+	var attrNames []string
+	for a := range attributes {
+		attrNames = append(attrNames, a)
+	}
+	sort.Strings(attrNames)
+
+	objects := map[*Req]*doorsObject{}
+	var last *doorsObject
+	for i, r := range reqs {
+		o := &doorsObject{index: i, module: doorsModule(r.Path), number: 1}
+		if last != nil && last.module == o.module {
+			o.number = last.number + 1
+		}
+		objects[r] = o
+		last = o
+	}
+
+	cw := csv.NewWriter(w)
+	header := append(append(append([]string{}, doorsColumns...), attrNames...), doorsLinkCols...)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range reqs {
+		o := objects[r]
+		row := []string{o.module, fmt.Sprint(o.number), r.ID, r.Title, doorsText(string(r.LoadBody()))}
+		for _, a := range attrNames {
+			row = append(row, r.Attributes[a])
+		}
+		var code []string
+		for _, c := range r.CodeFiles() {
+			code = append(code, c.Path)
+		}
+		sort.Strings(code)
+		row = append(row, doorsLinks(objects, r.Parents), doorsLinks(objects, r.Children), strings.Join(code, ", "))
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// doorsLinks returns the links to the exported requirements among reqs, in the order of the rows.
+func doorsLinks(objects map[*Req]*doorsObject, reqs []*Req) string {
+	var linked []*doorsObject
+	for _, r := range reqs {
+		if o, ok := objects[r]; ok {
+			linked = append(linked, o)
+		}
+	}
+	sort.Sort(byIndex(linked))
+	links := make([]string, len(linked))
+	for i, o := range linked {
+		links[i] = o.link()
+	}
+	return strings.Join(links, ", ")
+}
+
+type byIndex []*doorsObject
+
+func (a byIndex) Len() int           { return len(a) }
+func (a byIndex) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byIndex) Less(i, j int) bool { return a[i].index < a[j].index }
+
+type byModulePosition []*Req
+
+func (a byModulePosition) Len() int      { return len(a) }
+func (a byModulePosition) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byModulePosition) Less(i, j int) bool {
+	if mi, mj := doorsModule(a[i].Path), doorsModule(a[j].Path); mi != mj {
+		return mi < mj
+	}
+	return a[i].Position < a[j].Position
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportDOORS(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Position: 1,
+		Title: "System", Body: "<p>The system &amp; its parts.</p>\n\n\n<p>Second paragraph.</p>", Attributes: map[string]string{"RATIONALE": "Why"}}
+	high1 := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 3,
+		Title: "High 1", Attributes: map[string]string{"PARENTS": "REQ-0-TEST-SYS-001", "VERIFICATION": "Test"}}
+	high2 := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 7,
+		Title: "High 2", Attributes: map[string]string{"PARENTS": "REQ-0-TEST-SYS-001"}}
+	deleted := &Req{ID: "REQ-0-TEST-SWH-003", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 5,
+		Title: "DELETED High 3"}
+	code := &Req{ID: "a.go", Level: config.CODE, Path: "code/a.go"}
+	sys.Children = []*Req{high2, deleted, high1}
+	high1.Parents = []*Req{sys}
+	high1.Children = []*Req{code}
+	high2.Parents = []*Req{sys}
+	rg := reqGraph{sys.ID: sys, high1.ID: high1, high2.ID: high2, deleted.ID: deleted, code.Path: code}
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.Export("doors", &b))
+	assert.Equal(t, `Module,Absolute Number,Object Identifier,Object Heading,Object Text,RATIONALE,VERIFICATION,In-links,Out-links,Code
+0-TEST-100-ORD,1,REQ-0-TEST-SYS-001,System,"The system & its parts.
+
+Second paragraph.",Why,,,"0-TEST-211-SRD/1, 0-TEST-211-SRD/2",
+0-TEST-211-SRD,1,REQ-0-TEST-SWH-001,High 1,,,Test,0-TEST-100-ORD/1,,code/a.go
+0-TEST-211-SRD,2,REQ-0-TEST-SWH-002,High 2,,,,0-TEST-100-ORD/1,,
+`, b.String())
+
+	assert.EqualError(t, rg.Export("reqif", &b), `Unknown export format "reqif", expected one of: cypher, delta, doors, dot, json, plantuml, snapshot, trace, xlsx`)
+}
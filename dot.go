@@ -0,0 +1,119 @@
+// @llr REQ-0-DDLN-SWL-063
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+func init() {
+	exporters["dot"] = exportDOT
+}
+
+// dotShapes are the shapes of the nodes of each level in the DOT export.
+var dotShapes = map[config.RequirementLevel]string{
+	config.SYSTEM: "box3d",
+	config.HIGH:   "box",
+	config.LOW:    "box",
+	config.CODE:   "note",
+}
+
+// dotColors are the fill colors of the nodes of each status in the DOT export, as those of the
+// xlsx matrix.
+var dotColors = map[RequirementStatus]string{
+	NOT_STARTED: "#FFC7CE",
+	STARTED:     "#FFEB9C",
+	COMPLETED:   "#C6EFCE",
+}
+
+// dotTitleWidth is the width the titles are wrapped at in the labels of the nodes.
+const dotTitleWidth = 30
+
+// exportDOT writes the graph in the DOT language of GraphViz, e.g. to be rendered with
+// "dot -Tsvg": a node per requirement and code file, shaped by level, the high-level requirements
+// rounded, and filled by status, and an edge from each requirement or code file to its parents,
+// dashed to the requirements verified and dotted to those partially implemented. The levels are
+// ranked from the system requirements at the top down to the code. The deleted requirements are
+// left out.
+func exportDOT(rg reqGraph, w io.Writer) error {
+	reqs := make([]*Req, 0, len(rg))
+	for _, r := range rg {
+		if !r.IsDeleted() {
+			reqs = append(reqs, r)
+		}
+	}
+	sort.Sort(byIDOrPath(reqs))
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph reqtraq {")
+	fmt.Fprintln(b, "\trankdir=BT;")
+	fmt.Fprintln(b, `	node [style=filled, fontname="Helvetica", fontsize=10];`)
+	for _, level := range []config.RequirementLevel{config.SYSTEM, config.HIGH, config.LOW, config.CODE} {
+		fmt.Fprintln(b, "\t{\n\t\trank=same;")
+		for _, r := range reqs {
+			if r.Level != level {
+				continue
+			}
+			label := r.ID
+			if r.Title != "" {
+				label += "\n" + wrapWords(r.Title, dotTitleWidth)
+			}
+			style := "filled"
+			if r.Level == config.HIGH {
+				style = "filled,rounded"
+			}
+			fmt.Fprintf(b, "\t\t%s [label=%s, shape=%s, style=%q, fillcolor=%q];\n",
+				dotQuote(r.ID), dotQuote(label), dotShapes[r.Level], style, dotColors[r.Status])
+		}
+		fmt.Fprintln(b, "\t}")
+	}
+	for _, r := range reqs {
+		for _, e := range []struct {
+			style string
+			to    []*Req
+		}{
+			{"solid", r.Parents},
+			{"dotted", r.PartiallyImplements},
+			{"dashed", r.Verifies},
+		} {
+			for _, to := range e.to {
+				if to.IsDeleted() {
+					continue
+				}
+				fmt.Fprintf(b, "\t%s -> %s [style=%s];\n", dotQuote(r.ID), dotQuote(to.ID), e.style)
+			}
+		}
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+// dotQuote returns s as a quoted string of the DOT language, the line breaks as \n.
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + strings.Replace(s, "\n", `\n`, -1) + `"`
+}
+
+// wrapWords returns s with line breaks between the words so the lines are at most width long,
+// unless a single word is longer.
+func wrapWords(s string, width int) string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return strings.Join(append(lines, line), "\n")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportDOT(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "Fly"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: `Keep the "altitude" within the limits of the flight envelope`,
+		ParentIds: []string{sys.ID}}
+	deleted := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "DELETED", ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Climb", ParentIds: []string{high.ID}}
+	code := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", ParentIds: []string{low.ID}}
+	test := &Req{ID: "code/a_test.go", Level: config.CODE, Path: "/repo/code/a_test.go", VerifiesIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high.ID: high, deleted.ID: deleted, low.ID: low, code.Path: code, test.Path: test}
+	assert.NoError(t, rg.Resolve())
+
+	var w bytes.Buffer
+	assert.NoError(t, rg.Export("dot", &w))
+	assert.Equal(t, `digraph reqtraq {
+	rankdir=BT;
+	node [style=filled, fontname="Helvetica", fontsize=10];
+	{
+		rank=same;
+		"REQ-0-TEST-SYS-001" [label="REQ-0-TEST-SYS-001\nFly", shape=box3d, style="filled", fillcolor="#FFEB9C"];
+	}
+	{
+		rank=same;
+		"REQ-0-TEST-SWH-001" [label="REQ-0-TEST-SWH-001\nKeep the \"altitude\" within the\nlimits of the flight envelope", shape=box, style="filled,rounded", fillcolor="#C6EFCE"];
+	}
+	{
+		rank=same;
+		"REQ-0-TEST-SWL-001" [label="REQ-0-TEST-SWL-001\nClimb", shape=box, style="filled", fillcolor="#C6EFCE"];
+	}
+	{
+		rank=same;
+		"code/a.go" [label="code/a.go", shape=note, style="filled", fillcolor="#C6EFCE"];
+		"code/a_test.go" [label="code/a_test.go", shape=note, style="filled", fillcolor="#FFC7CE"];
+	}
+	"REQ-0-TEST-SWH-001" -> "REQ-0-TEST-SYS-001" [style=solid];
+	"REQ-0-TEST-SWL-001" -> "REQ-0-TEST-SWH-001" [style=solid];
+	"code/a.go" -> "REQ-0-TEST-SWL-001" [style=solid];
+	"code/a_test.go" -> "REQ-0-TEST-SWL-001" [style=dashed];
+}
+`, w.String())
+}
+
+func TestWrapWords(t *testing.T) {
+	assert.Equal(t, "", wrapWords("", 10))
+	assert.Equal(t, "one two\nthree", wrapWords("one two three", 10))
+	assert.Equal(t, "overlongword\nend", wrapWords("overlongword end", 5))
+}
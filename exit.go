@@ -0,0 +1,85 @@
+// @llr REQ-0-DDLN-SWL-047
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// The exit codes of the commands, by kind of failure, so scripts can tell them apart.
+const (
+	// exitInternal is for the unexpected errors, e.g. failing to write a report.
+	exitInternal = 1
+	// exitUsage is for an invalid command line or configuration, e.g. a missing file name or an
+	// invalid attributes json.
+	exitUsage = 2
+	// exitParse is for certification documents, code files or other inputs failing to parse.
+	exitParse = 3
+	// exitFindings is for the issues found in the requirements, e.g. invalid references, missing
+	// attributes, coverage gaps or dead links.
+	exitFindings = 4
+	// exitIntegration is for the failures of the tools and services reqtraq talks to, e.g. git,
+	// the task manager or Confluence.
+	exitIntegration = 5
+)
+
+// exitError is an error of a given kind, see exitCode.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// withExitCode returns err as an error of the kind of the exit code, nil if err is nil.
+func withExitCode(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code, err}
+}
+
+// graphError returns the issues found while building a graph, the parse errors taking precedence over the
+// findings.
+func graphError(parseErrors bool, errorResult string) error {
+	if parseErrors {
+		return withExitCode(exitParse, fmt.Errorf(errorResult))
+	}
+	return withExitCode(exitFindings, fmt.Errorf(errorResult))
+}
+
+// exitCode returns the exit code of the kind of err, or code if it has none.
+func exitCode(err error, code int) int {
+	if e, ok := err.(*exitError); ok {
+		return e.code
+	}
+	return code
+}
+
+// fatal prints its arguments as log.Fatal does and exits with the code, 0 for the findings with
+// --exit-zero-on-findings.
+func fatal(code int, v ...interface{}) {
+	log.Print(v...)
+	exit(code)
+}
+
+// fatalf prints its arguments as log.Fatalf does and exits with the code, see fatal.
+func fatalf(code int, format string, v ...interface{}) {
+	log.Printf(format, v...)
+	exit(code)
+}
+
+// fatalErr prints the error and exits with the code of its kind, or with code if it has none.
+func fatalErr(code int, err error) {
+	fatal(exitCode(err, code), err)
+}
+
+func exit(code int) {
+	if code == exitFindings && *fExitZeroOnFindings {
+		code = 0
+	}
+	os.Exit(code)
+}
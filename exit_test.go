@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	assert.Nil(t, withExitCode(exitParse, nil))
+
+	err := withExitCode(exitIntegration, errors.New("git failed"))
+	assert.Equal(t, "git failed", err.Error())
+	assert.Equal(t, exitIntegration, exitCode(err, exitInternal))
+	assert.Equal(t, exitInternal, exitCode(errors.New("other"), exitInternal))
+
+	assert.Equal(t, exitParse, exitCode(graphError(true, "malformed\n"), exitInternal))
+	assert.Equal(t, exitFindings, exitCode(graphError(false, "invalid parent\n"), exitInternal))
+}
+
+func TestPreCommitExitCode(t *testing.T) {
+	// Malformed requirements are parse errors, even with other issues.
+	err := precommit("/testdata/TestPreCommitCreateReqGraph", "/testdata/TestPreCommitCreateReqGraph", git.RepoPath()+"/certdocs/attributes.json")
+	assert.Equal(t, exitParse, exitCode(err, exitInternal))
+
+	// Invalid references are findings.
+	err = precommit("/testdata/TestPreCommitCheckReqReferences", "/testdata/TestPreCommitCheckReqReferences", git.RepoPath()+"/certdocs/attributes.json")
+	assert.Equal(t, exitFindings, exitCode(err, exitInternal))
+}
@@ -0,0 +1,33 @@
+// @llr REQ-0-DDLN-SWL-025
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// exporters write the requirement graph in the formats supported by the export command.
+var exporters = map[string]func(rg reqGraph, w io.Writer) error{
+	"doors": exportDOORS,
+}
+
+// exportFormats returns the names of the supported export formats, sorted.
+func exportFormats() []string {
+	var formats []string
+	for f := range exporters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Export writes the requirement graph to w in the given format.
+func (rg reqGraph) Export(format string, w io.Writer) error {
+	export, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("Unknown export format %q, expected one of: %s", format, strings.Join(exportFormats(), ", "))
+	}
+	return export(rg, w)
+}
@@ -0,0 +1,38 @@
+// @llr REQ-0-DDLN-SWL-062
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// externalGraph is the graph of another branch, e.g. the main one, the parents missing in the
+// graph of the current branch are looked up in, as set by precommit with --main_branch. The
+// parents added there after the current branch was created, or left out of a partial checkout or
+// sparse clone, are then external to the branch rather than missing.
+var externalGraph reqGraph
+
+// defines returns whether the graph has the requirement, not deleted.
+func (rg reqGraph) defines(id string) bool {
+	r := rg[id]
+	return r != nil && !r.IsDeleted()
+}
+
+// ExternalParents returns the findings of the parents external to the branch, see externalGraph,
+// sorted. They are informational and let the branch be checked before it's rebased.
+func (rg reqGraph) ExternalParents() []string {
+	var findings []string
+	for _, r := range rg {
+		for _, id := range r.ExternalParentIds {
+			if r.Level == config.CODE {
+				findings = append(findings, fmt.Sprintf("Reference in file %s to %s, external to this branch, defined in the main branch", r.Path, id))
+			} else {
+				findings = append(findings, fmt.Sprintf("Parent %s of requirement %s external to this branch, defined in the main branch", id, r.ID))
+			}
+		}
+	}
+	sort.Strings(findings)
+	return findings
+}
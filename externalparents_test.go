@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveExternalParents(t *testing.T) {
+	defer func() { externalGraph = nil }()
+	external := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH}
+	deleted := &Req{ID: "REQ-0-TEST-SWH-003", Level: config.HIGH, Title: "DELETED"}
+	externalGraph = reqGraph{external.ID: external, deleted.ID: deleted}
+
+	newGraph := func() reqGraph {
+		sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM}
+		high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{sys.ID}}
+		low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, ParentIds: []string{high.ID, external.ID}}
+		code := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", ParentIds: []string{"REQ-0-TEST-SWL-002"}}
+		return reqGraph{sys.ID: sys, high.ID: high, low.ID: low, code.Path: code}
+	}
+
+	rg := newGraph()
+	externalGraph["REQ-0-TEST-SWL-002"] = &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW}
+	assert.NoError(t, rg.Resolve())
+	low := rg["REQ-0-TEST-SWL-001"]
+	assert.Equal(t, []string{external.ID}, low.ExternalParentIds)
+	assert.Equal(t, []*Req{rg["REQ-0-TEST-SWH-001"]}, low.Parents)
+	assert.Equal(t, []string{
+		"Parent REQ-0-TEST-SWH-002 of requirement REQ-0-TEST-SWL-001 external to this branch, defined in the main branch",
+		"Reference in file /repo/code/a.go to REQ-0-TEST-SWL-002, external to this branch, defined in the main branch",
+	}, rg.ExternalParents())
+
+	// The parents deleted in the main branch remain errors.
+	rg = newGraph()
+	rg["REQ-0-TEST-SWL-001"].ParentIds = []string{deleted.ID}
+	assert.EqualError(t, rg.Resolve(), "Invalid parent of requirement REQ-0-TEST-SWL-001: REQ-0-TEST-SWH-003 does not exist.\n\n")
+
+	externalGraph = nil
+	rg = newGraph()
+	assert.Error(t, rg.Resolve())
+	assert.Empty(t, rg.ExternalParents())
+}
+
+func TestCheckFileReqReferencesExternalParents(t *testing.T) {
+	defer func() { externalGraph = nil }()
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "0-TEST-212-SDD.md")
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(`##### REQ-0-TEST-SWL-001 Climb
+Climbs, see REQ-0-TEST-SWH-002.
+- Parents: REQ-0-TEST-SWH-002
+`), 0644))
+
+	rg := reqGraph{"REQ-0-TEST-SWL-001": &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW}}
+	externalGraph = reqGraph{"REQ-0-TEST-SWH-002": &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH}}
+	// Only the parents may be external to the branch.
+	result, err := rg.checkFileReqReferences(fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "Invalid reference to inexistent requirement REQ-0-TEST-SWH-002 in "+fileName+":2\n", result)
+}
@@ -0,0 +1,337 @@
+// @llr REQ-0-DDLN-SWL-060
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// reMarkdownAttribute matches the attribute lines of the requirements of the .md certification
+// documents, e.g. "- Verification: Test.", the list marker being kept for the attributes added.
+var reMarkdownAttribute = regexp.MustCompile(`^(\s*[-*+]\s+)[^:]+:`)
+
+// Fix is the fix of a finding which needs no decision, see Fixes.
+type Fix struct {
+	// Finding is the issue fixed.
+	Finding string
+	// Change describes the change of the files, e.g. the lines replaced.
+	Change string
+	apply  func() error
+}
+
+// Apply applies the fix to the files.
+func (f Fix) Apply() error {
+	return f.apply()
+}
+
+// Fixes returns the fixes of the findings of the graph which can be resolved mechanically, by
+// file and by line:
+//
+// - the references to renamed requirements. A renamed requirement is deleted and references a
+// single other requirement in its title and body, the new one, e.g. "REQ-0-DDLN-SWL-003 DELETED
+// Renamed to REQ-0-DDLN-SWL-012".
+//
+// - the attributes missing in the requirements of the .md and .toml certification documents which
+// have a default value in the attribute specification, e.g. {"name": "Verification", "default":
+// "Unit test"}. The .toml documents are written in their canonical form.
+//
+// - the blocks of consecutive annotations of the code files not sorted by ID. The blocks
+// referencing renamed requirements are left to the run after they're renamed.
+func (rg reqGraph) Fixes(attributes []map[string]string, codePath string) ([]Fix, error) {
+	renames := rg.renames()
+	files := map[string][]*Req{}
+	for _, r := range rg {
+		if r.Path != "" {
+			files[r.Path] = append(files[r.Path], r)
+		}
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var fixes []Fix
+	for _, p := range paths {
+		fileName := p
+		if !strings.HasPrefix(fileName, git.RepoPath()) {
+			// The certification documents are relative to the repository root.
+			fileName = filepath.Join(git.RepoPath(), p)
+		}
+		b, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Split(string(b), "\n")
+		id := relativePathToRepo(fileName, git.RepoPath())
+		fixes = append(fixes, renameFixes(fileName, id, lines, renames)...)
+		reqs := files[p]
+		if reqs[0].Level == config.CODE {
+			if lang := codeFileLanguage(codePath, fileName); lang != nil {
+				fixes = append(fixes, annotationFixes(fileName, id, lines, lang.references(), renames)...)
+			}
+			continue
+		}
+		sort.Sort(byPosition(reqs))
+		for _, r := range reqs {
+			fixes = append(fixes, attributeFixes(fileName, id, lines, r, attributes)...)
+		}
+	}
+	return fixes, nil
+}
+
+// renames returns the new IDs of the renamed requirements, see Fixes.
+func (rg reqGraph) renames() map[string]string {
+	renames := map[string]string{}
+	for _, r := range rg {
+		if r.Level == config.CODE || !r.IsDeleted() {
+			continue
+		}
+		referenced := map[string]bool{}
+		for _, id := range ReReqID.FindAllString(r.Title+" "+string(r.LoadBody()), -1) {
+			if id != r.ID {
+				referenced[id] = true
+			}
+		}
+		if len(referenced) != 1 {
+			continue
+		}
+		for id := range referenced {
+			if to := rg[id]; to != nil && !to.IsDeleted() {
+				renames[r.ID] = id
+			}
+		}
+	}
+	return renames
+}
+
+// renameFixes returns the fixes of the references to the renamed requirements in the lines of a
+// file, the definitions of the deleted requirements apart.
+func renameFixes(fileName, id string, lines []string, renames map[string]string) []Fix {
+	var fixes []Fix
+	for i, line := range lines {
+		if ReReqDeleted.MatchString(line) {
+			continue
+		}
+		var renamed []string
+		fixed := ReReqID.ReplaceAllStringFunc(line, func(reqID string) string {
+			if to, ok := renames[reqID]; ok {
+				renamed = append(renamed, fmt.Sprintf("%s, now %s", reqID, to))
+				return to
+			}
+			return reqID
+		})
+		if len(renamed) > 0 {
+			fixes = append(fixes, lineFix(fmt.Sprintf("Reference to renamed requirement %s in %s:%d", strings.Join(renamed, ", "), id, i+1),
+				fileName, id, i, []string{line}, []string{fixed}))
+		}
+	}
+	return fixes
+}
+
+// annotationFixes returns the fixes of the blocks of consecutive annotations of a code file not
+// sorted by ID.
+func annotationFixes(fileName, id string, lines []string, llrRef func(line string) string, renames map[string]string) []Fix {
+	var fixes []Fix
+	for start := 0; start < len(lines); start++ {
+		end := start
+		for end < len(lines) && llrRef(lines[end]) != "" {
+			end++
+		}
+		if end-start < 2 {
+			continue
+		}
+		block := lines[start:end]
+		sorted := append([]string{}, block...)
+		sort.Stable(byAnnotationRef{sorted, llrRef})
+		renamed := false
+		for _, l := range block {
+			_, ok := renames[llrRef(l)]
+			renamed = renamed || ok
+		}
+		if !renamed && !sort.IsSorted(byAnnotationRef{block, llrRef}) {
+			fixes = append(fixes, lineFix(fmt.Sprintf("Annotations not sorted by ID in %s:%d", id, start+1),
+				fileName, id, start, block, sorted))
+		}
+		start = end
+	}
+	return fixes
+}
+
+type byAnnotationRef struct {
+	lines  []string
+	llrRef func(line string) string
+}
+
+func (a byAnnotationRef) Len() int           { return len(a.lines) }
+func (a byAnnotationRef) Swap(i, j int)      { a.lines[i], a.lines[j] = a.lines[j], a.lines[i] }
+func (a byAnnotationRef) Less(i, j int) bool { return a.llrRef(a.lines[i]) < a.llrRef(a.lines[j]) }
+
+// attributeFixes returns the fixes adding the attributes missing in the requirement which have a
+// default value.
+func attributeFixes(fileName, id string, lines []string, r *Req, attributes []map[string]string) []Fix {
+	if r.IsDeleted() {
+		return nil
+	}
+	var fixes []Fix
+	for _, a := range attributes {
+		name, value := a["name"], a["default"]
+		if _, ok := r.Attributes[strings.ToUpper(name)]; ok || value == "" {
+			continue
+		}
+		finding := fmt.Sprintf("Requirement %s is missing attribute %s, %q by default", r.ID, name, value)
+		switch strings.ToLower(filepath.Ext(fileName)) {
+		case ".toml":
+			fixes = append(fixes, Fix{finding, fmt.Sprintf("%s: add %s = %q to %s, writing the document in its canonical form", id, tomlKey(strings.ToLower(name)), value, r.ID),
+				func() error { return addTomlAttribute(fileName, r.ID, strings.ToLower(name), value) }})
+		case ".md":
+			if i := markdownLastAttribute(lines, r.ID); i >= 0 {
+				marker := reMarkdownAttribute.FindStringSubmatch(lines[i])[1]
+				fixes = append(fixes, lineFix(finding, fileName, id, i, lines[i:i+1], []string{lines[i], marker + name + ": " + value}))
+			}
+		}
+	}
+	return fixes
+}
+
+// markdownLastAttribute returns the index of the last attribute line of the requirement in the
+// lines of a .md certification document, -1 if it has none.
+func markdownLastAttribute(lines []string, reqID string) int {
+	last, level := -1, 0
+	for i, line := range lines {
+		parts := reATXHeading.FindStringSubmatch(line)
+		switch {
+		case level == 0:
+			if parts != nil && ReReqID.FindString(parts[3]) == reqID {
+				level = len(parts[1])
+			}
+		case parts != nil && len(parts[1]) <= level:
+			return last
+		case reMarkdownAttribute.MatchString(line):
+			last = i
+		}
+	}
+	return last
+}
+
+// addTomlAttribute adds the attribute to the requirement of the .toml certification document, or sets its value if
+// the requirement has it already.
+func addTomlAttribute(fileName, reqID, name, value string) error {
+	doc, err := readTomlDoc(fileName)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, r := range doc.Requirements {
+		if r.ID != reqID {
+			continue
+		}
+		found = true
+		set := false
+		for i := range r.Attributes {
+			if strings.EqualFold(r.Attributes[i].Name, name) {
+				r.Attributes[i].Value, set = value, true
+			}
+		}
+		if !set {
+			r.Attributes = append(r.Attributes, tomlAttribute{name, value})
+		}
+	}
+	if !found {
+		return fmt.Errorf("Requirement %s not found in %s, changed since", reqID, fileName)
+	}
+	o, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := doc.WriteTo(o); err != nil {
+		o.Close()
+		return err
+	}
+	return o.Close()
+}
+
+// lineFix returns the fix replacing the lines old, at index i of the file, with the lines new.
+// When applied, old is looked for again if the lines moved, e.g. after another fix added lines.
+func lineFix(finding, fileName, id string, i int, old, new []string) Fix {
+	old = append([]string{}, old...)
+	change := fmt.Sprintf("%s:%d\n", id, i+1)
+	for _, l := range old {
+		change += "- " + l + "\n"
+	}
+	for _, l := range new {
+		change += "+ " + l + "\n"
+	}
+	return Fix{finding, change, func() error {
+		b, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return err
+		}
+		lines := strings.Split(string(b), "\n")
+		at := -1
+		if i+len(old) <= len(lines) && equalLines(lines[i:i+len(old)], old) {
+			at = i
+		}
+		for j := 0; at < 0 && j+len(old) <= len(lines); j++ {
+			if equalLines(lines[j:j+len(old)], old) {
+				at = j
+			}
+		}
+		if at < 0 {
+			return fmt.Errorf("%s:%d changed since, run the fix again", id, i+1)
+		}
+		lines = append(lines[:at], append(append([]string{}, new...), lines[at+len(old):]...)...)
+		return ioutil.WriteFile(fileName, []byte(strings.Join(lines, "\n")), 0644)
+	}}
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ApplyFixes applies the fixes one by one, once confirmed from in, the user being asked on out,
+// until the end of in or until told to quit. It returns the number of fixes applied, the fixes
+// failing to apply being reported on out.
+func ApplyFixes(fixes []Fix, in io.Reader, out io.Writer) int {
+	answers := bufio.NewScanner(in)
+	applied := 0
+	for i, f := range fixes {
+		fmt.Fprintf(out, "[%d/%d] %s\n%s", i+1, len(fixes), f.Finding, f.Change)
+		fmt.Fprint(out, "Apply this fix? [y]es, [n]o, [q]uit: ")
+		if !answers.Scan() {
+			fmt.Fprintln(out)
+			break
+		}
+		answer := strings.ToLower(strings.TrimSpace(answers.Text()))
+		if answer == "q" || answer == "quit" {
+			break
+		}
+		if answer != "y" && answer != "yes" {
+			continue
+		}
+		if err := f.Apply(); err != nil {
+			fmt.Fprintln(out, "Not applied:", err)
+			continue
+		}
+		applied++
+	}
+	return applied
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rg := reqGraph{
+		"REQ-0-TEST-SWL-001": &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "DELETED Renamed to REQ-0-TEST-SWL-003"},
+		"REQ-0-TEST-SWL-002": &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "DELETED",
+			Body: "Split into REQ-0-TEST-SWL-003 and REQ-0-TEST-SWL-004."},
+		"REQ-0-TEST-SWL-003": &Req{ID: "REQ-0-TEST-SWL-003", Level: config.LOW},
+		"REQ-0-TEST-SWL-004": &Req{ID: "REQ-0-TEST-SWL-004", Level: config.LOW},
+	}
+	renames := rg.renames()
+	assert.Equal(t, map[string]string{"REQ-0-TEST-SWL-001": "REQ-0-TEST-SWL-003"}, renames)
+
+	// The annotations are split so they're not taken as references of this file.
+	llr := "/" + "/ @" + "llr "
+	code := filepath.Join(dir, "a.go")
+	src := "package a\n\n" + llr + "REQ-0-TEST-SWL-004\n" + llr + "REQ-0-TEST-SWL-001\n" + llr + "REQ-0-TEST-SWL-002\nfunc A() {}\n\n" +
+		llr + "REQ-0-TEST-SWL-004\n" + llr + "REQ-0-TEST-SWL-003\nfunc B() {}\n"
+	assert.NoError(t, ioutil.WriteFile(code, []byte(src), 0644))
+	lines := strings.Split(src, "\n")
+	fixes := renameFixes(code, "a.go", lines, renames)
+	// The block with the renamed requirement is sorted by the next run.
+	fixes = append(fixes, annotationFixes(code, "a.go", lines, codeLanguages[".go"].references(), renames)...)
+	if assert.Len(t, fixes, 2) {
+		assert.Equal(t, "Reference to renamed requirement REQ-0-TEST-SWL-001, now REQ-0-TEST-SWL-003 in a.go:4", fixes[0].Finding)
+		assert.Equal(t, "a.go:4\n- "+llr+"REQ-0-TEST-SWL-001\n+ "+llr+"REQ-0-TEST-SWL-003\n", fixes[0].Change)
+		assert.Equal(t, "Annotations not sorted by ID in a.go:8", fixes[1].Finding)
+	}
+
+	doc := filepath.Join(dir, "0-TEST-212-SDD.md")
+	md := `## REQ-0-TEST-SWL-003 Low
+
+Body.
+
+###### Attributes:
+- Rationale: Because.
+- Parents: REQ-0-TEST-SWH-001
+
+## REQ-0-TEST-SWL-004 Other
+`
+	assert.NoError(t, ioutil.WriteFile(doc, []byte(md), 0644))
+	attributes := []map[string]string{{"name": "Rationale"}, {"name": "Verification", "default": "Unit test"}}
+	r := &Req{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Attributes: map[string]string{"RATIONALE": "Because."}}
+	attributeFixes := attributeFixes(doc, "0-TEST-212-SDD.md", strings.Split(md, "\n"), r, attributes)
+	if assert.Len(t, attributeFixes, 1) {
+		assert.Equal(t, `Requirement REQ-0-TEST-SWL-003 is missing attribute Verification, "Unit test" by default`, attributeFixes[0].Finding)
+	}
+	fixes = append(fixes, attributeFixes...)
+
+	// The first fix is skipped, and the end of the answers leaves the others.
+	var out bytes.Buffer
+	assert.Equal(t, 2, ApplyFixes(fixes, strings.NewReader("n\ny\nyes\n"), &out))
+	assert.Contains(t, out.String(), "[3/3] Requirement REQ-0-TEST-SWL-003 is missing attribute Verification")
+	b, err := ioutil.ReadFile(code)
+	assert.NoError(t, err)
+	assert.Equal(t, "package a\n\n"+llr+"REQ-0-TEST-SWL-004\n"+llr+"REQ-0-TEST-SWL-001\n"+llr+"REQ-0-TEST-SWL-002\nfunc A() {}\n\n"+
+		llr+"REQ-0-TEST-SWL-003\n"+llr+"REQ-0-TEST-SWL-004\nfunc B() {}\n", string(b))
+	b, err = ioutil.ReadFile(doc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "- Parents: REQ-0-TEST-SWH-001\n- Verification: Unit test\n\n## REQ-0-TEST-SWL-004")
+
+	// Applied again, the lines aren't found.
+	assert.EqualError(t, fixes[1].Apply(), "a.go:8 changed since, run the fix again")
+}
+
+func TestAddTomlAttribute(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	doc := filepath.Join(dir, "0-TEST-212-SDD.toml")
+	assert.NoError(t, ioutil.WriteFile(doc, []byte("[[requirement]]\nid = \"REQ-0-TEST-SWL-001\"\ntitle = \"Low\"\n"), 0644))
+
+	assert.NoError(t, addTomlAttribute(doc, "REQ-0-TEST-SWL-001", "verification", "Unit test"))
+	b, err := ioutil.ReadFile(doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "[[requirement]]\nid = \"REQ-0-TEST-SWL-001\"\ntitle = \"Low\"\n\n[requirement.attributes]\nverification = \"Unit test\"\n", string(b))
+	assert.EqualError(t, addTomlAttribute(doc, "REQ-0-TEST-SWL-002", "verification", "Unit test"),
+		"Requirement REQ-0-TEST-SWL-002 not found in "+doc+", changed since")
+}
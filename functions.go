@@ -0,0 +1,236 @@
+// @llr REQ-0-DDLN-SWL-037
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// CodeFunction is a function of a code file referencing low-level requirements in its doc
+// comment or its body.
+type CodeFunction struct {
+	// Name is the name of the function, qualified by the receiver type for the Go methods or by
+	// the class for the C++ member functions defined out of their class, e.g. "reqGraph.Resolve".
+	Name string
+	// Start and End are the first and the last lines of the function, including its doc comment,
+	// numbered from 1.
+	Start, End int
+	Reqs       []string
+}
+
+// codeFunctionParsers find the functions of the code files, by extension, as the ranges of lines
+// they span. The references of the other code files apply to the whole file.
+var codeFunctionParsers = map[string]func(src []byte) []CodeFunction{
+	".c":  cFunctions,
+	".cc": cFunctions,
+	".go": goFunctions,
+	".h":  cFunctions,
+	".hh": cFunctions,
+}
+
+// codeFunctions returns the functions of the code file referencing the requirements found at
+// the lines of refLines, whose keys are line numbers. The references outside of the functions
+// are those of the file.
+func codeFunctions(parse func(src []byte) []CodeFunction, src []byte, refLines map[int][]string) []CodeFunction {
+	var functions []CodeFunction
+	for _, f := range parse(src) {
+		for line := f.Start; line <= f.End; line++ {
+			f.Reqs = append(f.Reqs, refLines[line]...)
+		}
+		if len(f.Reqs) > 0 {
+			functions = append(functions, f)
+		}
+	}
+	return functions
+}
+
+// goFunctions returns the functions and the methods of the Go source, or none if it doesn't parse.
+func goFunctions(src []byte) []CodeFunction {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	var functions []CodeFunction
+	for _, d := range file.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		f := CodeFunction{Name: fd.Name.Name, Start: fset.Position(fd.Pos()).Line, End: fset.Position(fd.End()).Line}
+		if fd.Doc != nil {
+			f.Start = fset.Position(fd.Doc.Pos()).Line
+		}
+		if fd.Recv != nil && len(fd.Recv.List) > 0 {
+			recv := fd.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			if id, ok := recv.(*ast.Ident); ok {
+				f.Name = id.Name + "." + f.Name
+			}
+		}
+		functions = append(functions, f)
+	}
+	return functions
+}
+
+var (
+	// reCFunctionHeader matches the text before the opening brace of a C or C++ function
+	// definition, capturing its name.
+	reCFunctionHeader = regexp.MustCompile(`(?s)([A-Za-z_~][\w:~]*)\s*\(.*\)[\s\w]*(->[^;]*)?(:[^;]*)?$`)
+	// reCScope matches the text before the opening brace of the scopes which may contain function
+	// definitions.
+	reCScope       = regexp.MustCompile(`\b(namespace|class|struct|extern\s*"C")\b[^()=]*$`)
+	reCNotFunction = regexp.MustCompile(`^(if|for|while|switch|catch|return|sizeof)$`)
+)
+
+// cFunctions returns the functions defined in the C or C++ source, found with a lightweight
+// parser: the comments, strings and preprocessor directives are skipped, and a function starts
+// where a block preceded by a parameter list opens outside of any other function.
+func cFunctions(src []byte) []CodeFunction {
+	var (
+		functions []CodeFunction
+		// header is the text since the end of the last statement or block, with headerLine the
+		// line where it starts.
+		header     []byte
+		headerLine int
+		line       = 1
+		// commentOnly are the lines holding only comments, which document the function below.
+		commentOnly           = map[int]bool{}
+		lineCode, lineComment bool
+		// scopes are the blocks open, the function being the innermost one if any.
+		scopes  []string
+		current *CodeFunction
+	)
+	endLine := func() {
+		if lineComment && !lineCode {
+			commentOnly[line] = true
+		}
+		line++
+		lineCode, lineComment = false, false
+	}
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			endLine()
+			if len(header) > 0 {
+				header = append(header, ' ')
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			lineComment = true
+			for i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			lineComment = true
+			for i++; i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/'); i++ {
+				if src[i] == '\n' {
+					endLine()
+					lineComment = true
+				}
+			}
+			i++
+		case c == '"' || c == '\'':
+			// The strings are kept in the header, e.g. for extern "C".
+			lineCode = true
+			if len(header) == 0 {
+				headerLine = line
+			}
+			start := i
+			for i++; i < len(src) && src[i] != c && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(src) && src[i] == '\n' {
+				i--
+			}
+			header = append(header, src[start:i+1]...)
+		case c == '#' && strings.TrimSpace(string(header)) == "" && !lineCode:
+			// Preprocessor directives, continued after a backslash.
+			lineCode = true
+			for i+1 < len(src) && (src[i+1] != '\n' || src[i] == '\\') {
+				if src[i+1] == '\n' {
+					i++
+					endLine()
+				}
+				i++
+			}
+		case c == '{':
+			lineCode = true
+			h := strings.TrimSpace(string(header))
+			switch {
+			case current != nil:
+				scopes = append(scopes, "block")
+			case reCScope.MatchString(h):
+				scopes = append(scopes, "scope")
+			default:
+				m := reCFunctionHeader.FindStringSubmatch(h)
+				if m == nil || reCNotFunction.MatchString(m[1]) {
+					scopes = append(scopes, "block")
+					break
+				}
+				start := headerLine
+				for commentOnly[start-1] {
+					start--
+				}
+				current = &CodeFunction{Name: m[1], Start: start}
+				scopes = append(scopes, "function")
+			}
+			header = nil
+		case c == '}':
+			lineCode = true
+			if len(scopes) > 0 {
+				if scopes[len(scopes)-1] == "function" {
+					current.End = line
+					functions = append(functions, *current)
+					current = nil
+				}
+				scopes = scopes[:len(scopes)-1]
+			}
+			header = nil
+		case c == ';':
+			lineCode = true
+			header = nil
+		case c == ' ' || c == '\t' || c == '\r':
+			if len(header) > 0 {
+				header = append(header, c)
+			}
+		default:
+			lineCode = true
+			if len(header) == 0 {
+				headerLine = line
+			}
+			header = append(header, c)
+		}
+	}
+	return functions
+}
+
+// FileFunction is a function of a code file.
+type FileFunction struct {
+	File *Req
+	CodeFunction
+}
+
+// ImplementingFunctions returns the functions of the code files implementing r which reference
+// it, in the order of the code files.
+func (r *Req) ImplementingFunctions() []FileFunction {
+	var functions []FileFunction
+	for _, c := range r.CodeFiles() {
+		for _, f := range c.Functions {
+			for _, id := range f.Reqs {
+				if id == r.ID {
+					functions = append(functions, FileFunction{c, f})
+					break
+				}
+			}
+		}
+	}
+	return functions
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// llr returns the annotation of the low-level requirement, split so it's not found in this file.
+func llr(id string) string {
+	return "/" + "/ @llr " + id
+}
+
+func TestGoFunctions(t *testing.T) {
+	src := strings.Join([]string{
+		llr("REQ-0-TEST-SWL-001"),
+		"package main",
+		"",
+		llr("REQ-0-TEST-SWL-002"),
+		"// parse parses.",
+		"func parse() {",
+		"}",
+		"",
+		"func (rg *reqGraph) resolve() {",
+		"	" + llr("REQ-0-TEST-SWL-003"),
+		"}",
+		"",
+		"func other() {}",
+	}, "\n")
+	assert.Equal(t, []CodeFunction{
+		{Name: "parse", Start: 4, End: 7},
+		{Name: "reqGraph.resolve", Start: 9, End: 11},
+		{Name: "other", Start: 13, End: 13},
+	}, goFunctions([]byte(src)))
+	assert.Empty(t, goFunctions([]byte("func {")))
+}
+
+func TestCFunctions(t *testing.T) {
+	src := strings.Join([]string{
+		"/* " + strings.TrimPrefix(llr("REQ-0-TEST-SWL-001"), "//") + " */",
+		`#include "a.h"`,
+		"#define MAX(a, b) \\",
+		"	((a) > (b) ? (a) : (b))",
+		"",
+		`extern "C" {`,
+		"/*",
+		" * Documented.",
+		" */",
+		"static int parse(const char *s)",
+		"{",
+		`	if (s[0] == '{') { return 1; }`,
+		`	return strlen("}");`,
+		"}",
+		"}",
+		"",
+		"namespace rq {",
+		"struct Point { int x; int y; };",
+		"int table[] = {1, 2};",
+		"Graph::Graph(int n) : n_(n) {}",
+		"std::vector<int> Graph::resolve() const {",
+		"	for (int i = 0; i < n_; i++) {",
+		"	}",
+		"}",
+		"}  // namespace rq",
+	}, "\n")
+	assert.Equal(t, []CodeFunction{
+		{Name: "parse", Start: 7, End: 14},
+		{Name: "Graph::Graph", Start: 20, End: 20},
+		{Name: "Graph::resolve", Start: 21, End: 24},
+	}, cFunctions([]byte(src)))
+}
+
+func TestParseCode_functions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "functions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "a.go")
+	src := strings.Join([]string{
+		llr("REQ-0-TEST-SWL-001"),
+		"package main",
+		"",
+		llr("REQ-0-TEST-SWL-002"),
+		"func parse() {}",
+		"",
+		"func resolve() {",
+		"	" + llr("REQ-0-TEST-SWL-002"),
+		"	" + llr("REQ-0-TEST-SWL-003"),
+		"}",
+		"",
+		"func other() {}",
+	}, "\n")
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte(src), 0644))
+
+	rg := reqGraph{}
+	assert.NoError(t, parseCode("a.go", fileName, codeLanguages[".go"].references(), rg))
+	code := rg[fileName]
+	assert.Equal(t, []CodeFunction{
+		{Name: "parse", Start: 4, End: 5, Reqs: []string{"REQ-0-TEST-SWL-002"}},
+		{Name: "resolve", Start: 7, End: 10, Reqs: []string{"REQ-0-TEST-SWL-002", "REQ-0-TEST-SWL-003"}},
+	}, code.Functions)
+
+	low := &Req{ID: "REQ-0-TEST-SWL-002", Children: []*Req{code}}
+	functions := low.ImplementingFunctions()
+	if assert.Len(t, functions, 2) {
+		assert.Equal(t, code, functions[0].File)
+		assert.Equal(t, "parse", functions[0].Name)
+		assert.Equal(t, "resolve", functions[1].Name)
+	}
+}
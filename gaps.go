@@ -0,0 +1,208 @@
+// @llr REQ-0-DDLN-SWL-022
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// GoGap is an exported Go function in a file with no @llr annotation, hence a candidate
+// traceability gap.
+type GoGap struct {
+	Package  string
+	Function string // for methods, (T).Name or (*T).Name
+	Path     string // relative to the repository root
+	Line     int
+}
+
+func (g GoGap) String() string {
+	return fmt.Sprintf("%s:%d: %s.%s has no @llr annotation", g.Path, g.Line, g.Package, g.Function)
+}
+
+// GeneratedConf configures the detection of generated Go files, the "generated" entry in
+// attributes.json. Files with the standard "// Code generated ... DO NOT EDIT." header, as
+// written by mockgen, protoc-gen-go or stringer, are always recognized.
+type GeneratedConf struct {
+	// Regular expressions matching the paths of generated files, relative to the repository root.
+	Paths []string `json:"paths"`
+	// Regular expressions matching a comment line before the package clause of generated files.
+	Headers []string `json:"headers"`
+}
+
+var reGeneratedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// generatedMatcher tells whether a parsed Go file is generated.
+type generatedMatcher struct {
+	paths, headers []*regexp.Regexp
+}
+
+func (c GeneratedConf) matcher() (*generatedMatcher, error) {
+	m := &generatedMatcher{headers: []*regexp.Regexp{reGeneratedHeader}}
+	for _, p := range c.Paths {
+		expr, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid generated file path pattern: %v", err)
+		}
+		m.paths = append(m.paths, expr)
+	}
+	for _, h := range c.Headers {
+		expr, err := regexp.Compile(h)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid generated file header pattern: %v", err)
+		}
+		m.headers = append(m.headers, expr)
+	}
+	return m, nil
+}
+
+func (m *generatedMatcher) matchPath(relPath string) bool {
+	for _, p := range m.paths {
+		if p.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *generatedMatcher) matchHeader(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			for _, h := range m.headers {
+				if h.MatchString(c.Text) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// FindGoGaps parses the Go files under codePath in the repository and returns the exported
+// functions of the files which don't reference any low-level requirement, either at the file
+// level or in the function documentation. In strict mode, the exported functions of the files
+// referencing requirements are returned too unless they reference one themselves, in their
+// documentation or their body, so the partially annotated files are found. Test files, generated
+// files and the files of the ignore file are skipped.
+func FindGoGaps(repoPath, codePath string, gen GeneratedConf, strict bool) ([]GoGap, error) {
+	generated, err := gen.matcher()
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := loadIgnoreRules(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	var gaps []GoGap
+	fset := token.NewFileSet()
+	err = filepath.Walk(filepath.Join(repoPath, codePath), ignore.walkFunc(repoPath, func(fileName string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || path.Ext(fileName) != ".go" || strings.HasSuffix(fileName, "_test.go") {
+			return nil
+		}
+		// Same as in CreateReqGraph.
+		if !strings.Contains(codePath, "testdata") && strings.Contains(fileName, "testdata") {
+			return nil
+		}
+		relPath, err := filepath.Rel(repoPath, fileName)
+		if err != nil {
+			return err
+		}
+		if generated.matchPath(filepath.ToSlash(relPath)) {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, fileName, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		if generated.matchHeader(f) {
+			return nil
+		}
+		for _, cg := range f.Comments {
+			if !strict && hasLLRReference(cg) {
+				return nil
+			}
+		}
+		for _, d := range f.Decls {
+			fd, ok := d.(*ast.FuncDecl)
+			if !ok || !fd.Name.IsExported() || funcHasLLRReference(f, fd) {
+				continue
+			}
+			name := fd.Name.Name
+			if fd.Recv != nil && len(fd.Recv.List) > 0 {
+				recv := receiverName(fd.Recv.List[0].Type)
+				if !ast.IsExported(strings.TrimPrefix(recv, "*")) {
+					continue
+				}
+				name = "(" + recv + ")." + name
+			}
+			gaps = append(gaps, GoGap{f.Name.Name, name, relPath, fset.Position(fd.Pos()).Line})
+		}
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byGapPosition(gaps))
+	return gaps, nil
+}
+
+func hasLLRReference(cg *ast.CommentGroup) bool {
+	if cg == nil {
+		return false
+	}
+	for _, c := range cg.List {
+		if reLLRReference.MatchString(c.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// funcHasLLRReference returns whether the function references a requirement in its documentation
+// or in the comments of its body.
+func funcHasLLRReference(f *ast.File, fd *ast.FuncDecl) bool {
+	if hasLLRReference(fd.Doc) {
+		return true
+	}
+	for _, cg := range f.Comments {
+		if cg.Pos() >= fd.Pos() && cg.End() <= fd.End() && hasLLRReference(cg) {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverName returns the name of the receiver type, prefixed with * for pointers.
+func receiverName(e ast.Expr) string {
+	if s, ok := e.(*ast.StarExpr); ok {
+		return "*" + receiverName(s.X)
+	}
+	if id, ok := e.(*ast.Ident); ok {
+		return id.Name
+	}
+	return ""
+}
+
+type byGapPosition []GoGap
+
+func (a byGapPosition) Len() int      { return len(a) }
+func (a byGapPosition) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byGapPosition) Less(i, j int) bool {
+	if a[i].Path != a[j].Path {
+		return a[i].Path < a[j].Path
+	}
+	return a[i].Line < a[j].Line
+}
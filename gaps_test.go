@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindGoGaps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The annotations are split so they are not taken as references of this file.
+	files := map[string]string{
+		"annotated.go": "// @llr " + "REQ-0-TEST-SWL-001\npackage a\n\nfunc Exported() {}\n",
+		"func_annotated.go": `package a
+
+// Documented is traced.
+// @llr ` + `REQ-0-TEST-SWL-002
+func Documented() {}
+
+func Body() {
+	// @llr ` + `REQ-0-TEST-SWL-003
+}
+
+func Missing() {}
+
+func unexported() {}
+`,
+		"b/gaps.go": `package b
+
+type T struct{}
+type t struct{}
+
+func unexported() {}
+
+func Exported() {}
+
+func (T) Value() {}
+
+func (*T) Pointer() {}
+
+func (t) Hidden() {}
+`,
+		"b/mock.go":        "// Code generated by MockGen. DO NOT EDIT.\n\npackage b\n\nfunc Mocked() {}\n",
+		"b/late_header.go": "package b\n\n// Code generated by MockGen. DO NOT EDIT.\nfunc Late() {}\n",
+		"b/custom.go":      "// Autogenerated by tool.\npackage b\n\nfunc Custom() {}\n",
+		"b/gen/path.go":    "package gen\n\nfunc Path() {}\n",
+		"b/gaps_test.go":   "package b\n\nfunc TestIgnored() {}\n",
+		"testdata/skip.go": "package c\n\nfunc Skipped() {}\n",
+		"b/not_go.txt":     "func NotGo() {}\n",
+		".reqtraqignore":   "vendor/\n",
+		"vendor/v/v.go":    "package v\n\nfunc Vendored() {}\n",
+	}
+	for name, content := range files {
+		fileName := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gaps, err := FindGoGaps(dir, "", GeneratedConf{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []GoGap{
+		{"b", "Custom", "b/custom.go", 4},
+		{"b", "Exported", "b/gaps.go", 8},
+		{"b", "(T).Value", "b/gaps.go", 10},
+		{"b", "(*T).Pointer", "b/gaps.go", 12},
+		{"gen", "Path", "b/gen/path.go", 3},
+		{"b", "Late", "b/late_header.go", 4},
+	}, gaps)
+
+	// Additional generators.
+	gaps, err = FindGoGaps(dir, "", GeneratedConf{Paths: []string{"/gen/"}, Headers: []string{"^// Autogenerated by"}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []GoGap{
+		{"b", "Exported", "b/gaps.go", 8},
+		{"b", "(T).Value", "b/gaps.go", 10},
+		{"b", "(*T).Pointer", "b/gaps.go", 12},
+		{"b", "Late", "b/late_header.go", 4},
+	}, gaps)
+	if len(gaps) > 0 {
+		assert.Equal(t, "b/gaps.go:8: b.Exported has no @llr annotation", gaps[0].String())
+	}
+
+	_, err = FindGoGaps(dir, "", GeneratedConf{Paths: []string{"("}}, false)
+	assert.Contains(t, err.Error(), "Invalid generated file path pattern")
+
+	// Files in testdata are considered when looking in testdata.
+	gaps, err = FindGoGaps(dir, "testdata", GeneratedConf{}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []GoGap{{"c", "Skipped", "testdata/skip.go", 3}}, gaps)
+
+	// In strict mode, the exported functions of the annotated files must be annotated too.
+	gaps, err = FindGoGaps(dir, "", GeneratedConf{Paths: []string{"/gen/"}, Headers: []string{"^// Autogenerated by"}}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []GoGap{
+		{"a", "Exported", "annotated.go", 4},
+		{"b", "Exported", "b/gaps.go", 8},
+		{"b", "(T).Value", "b/gaps.go", 10},
+		{"b", "(*T).Pointer", "b/gaps.go", 12},
+		{"b", "Late", "b/late_header.go", 4},
+		{"a", "Missing", "func_annotated.go", 11},
+	}, gaps)
+}
@@ -1,4 +1,4 @@
-//@llr REQ-0-DDLN-SWL-001
+// @llr REQ-0-DDLN-SWL-001
 package git
 
 import (
@@ -203,3 +203,98 @@ func Clone() (string, error) {
 func Checkout(commit string) error {
 	return linepipes.Out(linepipes.Run("git", "checkout", commit))
 }
+
+// FileCommits returns the SHA-1s of the commits touching the given file, most recent first.
+func FileCommits(filePath string) ([]string, error) {
+	commits := make([]string, 0)
+	lines, errs := linepipes.Run("git", "-C", filepath.Dir(filePath), "log", "--format=%H", "--", filePath)
+	for line := range lines {
+		commits = append(commits, line)
+	}
+	if err := <-errs; err != nil {
+		return commits, fmt.Errorf("Failed to get the list of commits for %s: %s", filePath, err)
+	}
+	return commits, nil
+}
+
+// FileAt returns the content of the given file at the given commit.
+func FileAt(commit, filePath string) (string, error) {
+	content, err := linepipes.All(linepipes.Run("git", "-C", filepath.Dir(filePath), "show", commit+":./"+filepath.Base(filePath)))
+	if err != nil {
+		return "", fmt.Errorf("Failed to get %s at %s: %s", filePath, commit, err)
+	}
+	return content, nil
+}
+
+// BlobAt returns the object ID of the given file at the given commit, the hash of its content in the
+// object format of the repository.
+func BlobAt(commit, filePath string) (string, error) {
+	return linepipes.Single(linepipes.Run("git", "-C", filepath.Dir(filePath), "rev-parse", commit+":./"+filepath.Base(filePath)))
+}
+
+// CommitDate returns the date of the given commit, formatted as YYYY-MM-DD.
+func CommitDate(commit string) (string, error) {
+	return linepipes.Single(linepipes.Run("git", "show", "-s", "--format=%cd", "--date=short", commit))
+}
+
+// CommitAuthor returns the name of the author of the given commit.
+func CommitAuthor(commit string) (string, error) {
+	return linepipes.Single(linepipes.Run("git", "show", "-s", "--format=%an", commit))
+}
+
+// Dir returns the full path of the git directory of the current repository, e.g. /path/to/repo/.git, or the repository
+// itself if it is bare.
+func Dir() (string, error) {
+	dir, err := linepipes.Single(linepipes.Run("git", "rev-parse", "--git-dir"))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(dir)
+}
+
+// ObjectFormat returns the hash algorithm of the objects of the current repository, sha1 or sha256.
+func ObjectFormat() (string, error) {
+	return linepipes.Single(linepipes.Run("git", "rev-parse", "--show-object-format"))
+}
+
+// Tag creates the annotated tag with the given name and message of the commit checked out in the current repository. It
+// fails if the tag exists.
+func Tag(name, message string) error {
+	return linepipes.Out(linepipes.Run("git", "tag", "-a", "-m", message, name))
+}
+
+// DeleteTag deletes the tag with the given name.
+func DeleteTag(name string) error {
+	return linepipes.Out(linepipes.Run("git", "tag", "-d", name))
+}
+
+// HeadCommit returns the commit checked out in the current repository.
+func HeadCommit() (string, error) {
+	return linepipes.Single(linepipes.Run("git", "rev-parse", "HEAD"))
+}
+
+// FilesChangedInWorkTree returns the paths of the files changed in the index or in the working tree since HEAD, including
+// the deleted and the untracked files. For renamed files both paths are returned. The paths are relative to the repo root
+// dir.
+func FilesChangedInWorkTree() ([]string, error) {
+	lines, errors := linepipes.Run("git", "status", "--porcelain", "--untracked-files=all")
+	res := make([]string, 0)
+	for line := range lines {
+		if len(line) < 4 {
+			continue
+		}
+		// See "Short Format" in https://git-scm.com/docs/git-status
+		path := line[3:]
+		if line[0] == 'R' || line[0] == 'C' {
+			if i := strings.Index(path, " -> "); i >= 0 {
+				res = append(res, path[:i])
+				path = path[i+len(" -> "):]
+			}
+		}
+		res = append(res, path)
+	}
+	if err, _ := <-errors; err != nil {
+		return res, fmt.Errorf("Failed to get changed files in the working tree: %s", err)
+	}
+	return res, nil
+}
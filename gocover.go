@@ -0,0 +1,158 @@
+// @llr REQ-0-DDLN-SWL-040
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// coverBlock is a block of statements of a Go coverage profile, as written by
+// go test -coverprofile.
+type coverBlock struct {
+	StartLine, EndLine int
+	Count              int
+}
+
+// coverProfile are the blocks of a coverage profile, by file name, e.g.
+// "github.com/daedaleanai/reqtraq/req.go".
+type coverProfile map[string][]coverBlock
+
+// parseCoverProfile reads a coverage profile, whose lines are
+//
+//	name.go:line.column,line.column numberOfStatements count
+//
+// after the mode line.
+func parseCoverProfile(r io.Reader) (coverProfile, error) {
+	profile := coverProfile{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		i := strings.LastIndex(line, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("line %d: invalid block %q", n, line)
+		}
+		var (
+			b                            coverBlock
+			startCol, endCol, statements int
+		)
+		if _, err := fmt.Sscanf(line[i+1:], "%d.%d,%d.%d %d %d", &b.StartLine, &startCol, &b.EndLine, &endCol, &statements, &b.Count); err != nil {
+			return nil, fmt.Errorf("line %d: invalid block %q: %v", n, line, err)
+		}
+		profile[line[:i]] = append(profile[line[:i]], b)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// blocks returns the blocks of the code file at relPath, relative to the repository root, found
+// as the file of the profile whose import path ends with it, and whether the file was found.
+func (p coverProfile) blocks(relPath string) ([]coverBlock, bool) {
+	for name, blocks := range p {
+		if name == relPath || strings.HasSuffix(name, "/"+relPath) {
+			return blocks, true
+		}
+	}
+	return nil, false
+}
+
+// executed returns whether one of the blocks within the lines start to end was executed. All the
+// lines are considered if end is 0.
+func executed(blocks []coverBlock, start, end int) bool {
+	for _, b := range blocks {
+		if b.Count > 0 && (end == 0 || b.StartLine <= end && b.EndLine >= start) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnexecutedLLR is a low-level requirement whose Go code was never executed by the tests of a
+// coverage profile.
+type UnexecutedLLR struct {
+	Req *Req
+	// Code are the code files, or the functions as file:function, implementing the requirement.
+	Code []string
+}
+
+func (u UnexecutedLLR) String() string {
+	return fmt.Sprintf("%s %s: never executed by the tests: %s", u.Req.ID, u.Req.Title, strings.Join(u.Code, ", "))
+}
+
+// UnexecutedLLRs returns the low-level requirements implemented by Go code of which none was
+// executed according to the coverage profile, sorted by ID. The code of a requirement is the
+// functions of its code files referencing it, or the whole files referencing it outside of any
+// function. The test files and the files missing from the profile are skipped.
+func (rg reqGraph) UnexecutedLLRs(profile coverProfile) []UnexecutedLLR {
+	var unexecuted []UnexecutedLLR
+	for _, r := range rg {
+		if r.Level != config.LOW || r.IsDeleted() {
+			continue
+		}
+		var (
+			code []string
+			run  bool
+		)
+		functions := map[*Req][]CodeFunction{}
+		for _, f := range r.ImplementingFunctions() {
+			functions[f.File] = append(functions[f.File], f.CodeFunction)
+		}
+		for _, c := range r.CodeFiles() {
+			if !strings.HasSuffix(c.ID, ".go") || strings.HasSuffix(c.ID, "_test.go") {
+				continue
+			}
+			blocks, ok := profile.blocks(c.ID)
+			if !ok {
+				continue
+			}
+			if !fileReferences(c, r.ID) && len(functions[c]) > 0 {
+				for _, f := range functions[c] {
+					code = append(code, c.ID+":"+f.Name)
+					run = run || executed(blocks, f.Start, f.End)
+				}
+				continue
+			}
+			code = append(code, c.ID)
+			run = run || executed(blocks, 0, 0)
+		}
+		if len(code) > 0 && !run {
+			unexecuted = append(unexecuted, UnexecutedLLR{r, code})
+		}
+	}
+	sort.Sort(byUnexecutedID(unexecuted))
+	return unexecuted
+}
+
+// fileReferences returns whether the code file references the requirement outside of its
+// functions.
+func fileReferences(code *Req, id string) bool {
+	n := 0
+	for _, p := range code.ParentIds {
+		if p == id {
+			n++
+		}
+	}
+	for _, f := range code.Functions {
+		for _, ref := range f.Reqs {
+			if ref == id {
+				n--
+			}
+		}
+	}
+	return n > 0
+}
+
+type byUnexecutedID []UnexecutedLLR
+
+func (a byUnexecutedID) Len() int           { return len(a) }
+func (a byUnexecutedID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byUnexecutedID) Less(i, j int) bool { return a[i].Req.ID < a[j].Req.ID }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCoverProfile(t *testing.T) {
+	profile, err := parseCoverProfile(strings.NewReader(`mode: set
+github.com/daedaleanai/reqtraq/links.go:20.2,22.16 2 1
+github.com/daedaleanai/reqtraq/links.go:30.2,31.3 1 0
+github.com/daedaleanai/reqtraq/git/git.go:5.40,7.2 1 0
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, coverProfile{
+		"github.com/daedaleanai/reqtraq/links.go":   {{20, 22, 1}, {30, 31, 0}},
+		"github.com/daedaleanai/reqtraq/git/git.go": {{5, 7, 0}},
+	}, profile)
+	blocks, ok := profile.blocks("git/git.go")
+	assert.True(t, ok)
+	assert.Len(t, blocks, 1)
+	_, ok = profile.blocks("it.go")
+	assert.False(t, ok)
+
+	_, err = parseCoverProfile(strings.NewReader("mode: set\nlinks.go 2 1\n"))
+	assert.EqualError(t, err, `line 2: invalid block "links.go 2 1"`)
+	_, err = parseCoverProfile(strings.NewReader("mode: set\nlinks.go:20.2,22 2 1\n"))
+	assert.Error(t, err)
+}
+
+func TestReqGraph_UnexecutedLLRs(t *testing.T) {
+	low := func(id string) *Req { return &Req{ID: id, Level: config.LOW, Title: "Low"} }
+	checked, called, uncalled, file, untested := low("REQ-0-TEST-SWL-001"), low("REQ-0-TEST-SWL-002"), low("REQ-0-TEST-SWL-003"), low("REQ-0-TEST-SWL-004"), low("REQ-0-TEST-SWL-005")
+	links := &Req{ID: "links.go", Level: config.CODE, ParentIds: []string{checked.ID, called.ID, uncalled.ID},
+		Functions: []CodeFunction{
+			{Name: "check", Start: 10, End: 25, Reqs: []string{called.ID}},
+			{Name: "request", Start: 28, End: 40, Reqs: []string{uncalled.ID}},
+		}}
+	git := &Req{ID: "git/git.go", Level: config.CODE, ParentIds: []string{file.ID}}
+	test := &Req{ID: "links_test.go", Level: config.CODE, ParentIds: []string{untested.ID}}
+	checked.Children = []*Req{links}
+	called.Children = []*Req{links}
+	uncalled.Children = []*Req{links}
+	file.Children = []*Req{git}
+	untested.Children = []*Req{test}
+	rg := reqGraph{checked.ID: checked, called.ID: called, uncalled.ID: uncalled, file.ID: file, untested.ID: untested}
+
+	profile := coverProfile{
+		"github.com/daedaleanai/reqtraq/links.go":   {{20, 22, 1}, {30, 31, 0}},
+		"github.com/daedaleanai/reqtraq/git/git.go": {{5, 7, 0}},
+	}
+	var summaries []string
+	for _, u := range rg.UnexecutedLLRs(profile) {
+		summaries = append(summaries, u.String())
+	}
+	assert.Equal(t, []string{
+		"REQ-0-TEST-SWL-003 Low: never executed by the tests: links.go:request",
+		"REQ-0-TEST-SWL-004 Low: never executed by the tests: git/git.go",
+	}, summaries)
+}
@@ -0,0 +1,109 @@
+// @llr REQ-0-DDLN-SWL-057
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// graphJSONVersion is the version of the schema of the json export, incremented when fields are
+// removed or change meaning. Fields are added without changing it, so the consumers should ignore
+// the fields they don't know.
+const graphJSONVersion = 1
+
+func init() {
+	exporters["json"] = exportJSON
+}
+
+// GraphJSON is the json export of the requirement graph, for the downstream tools, e.g.
+// dashboards or custom checks.
+type GraphJSON struct {
+	Version int        `json:"version"`
+	Nodes   []NodeJSON `json:"nodes"`
+	Edges   []EdgeJSON `json:"edges"`
+}
+
+// NodeJSON is a requirement or a code file. The code files are identified by their path relative
+// to the repository root.
+type NodeJSON struct {
+	ID string `json:"id"`
+	// Type is SYS, SWH, SWL, HWH, HWL or CODE.
+	Type string `json:"type"`
+	// Path is the certification document defining the requirement, or the code file.
+	Path    string `json:"path"`
+	Section string `json:"section,omitempty"`
+	Title   string `json:"title,omitempty"`
+	// Body, Rationale, AcceptanceCriteria and Notes are HTML.
+	Body               string            `json:"body,omitempty"`
+	Rationale          string            `json:"rationale,omitempty"`
+	AcceptanceCriteria string            `json:"acceptanceCriteria,omitempty"`
+	Notes              string            `json:"notes,omitempty"`
+	Attributes         map[string]string `json:"attributes,omitempty"`
+	// Status is NOT STARTED, STARTED or COMPLETED.
+	Status  string `json:"status"`
+	Deleted bool   `json:"deleted,omitempty"`
+	// Hash is the hash of the content of a code file, see formatFileHash.
+	Hash string `json:"hash,omitempty"`
+	// TestEnv is set for the code files of the test environments and simulation models.
+	TestEnv bool `json:"testEnv,omitempty"`
+}
+
+// EdgeJSON is a link of the graph, from a requirement or a code file to the requirement it
+// traces to.
+type EdgeJSON struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Kind is parent, from a requirement to its parent, implements, partially-implements or
+	// verifies, from a code file to a requirement.
+	Kind string `json:"kind"`
+}
+
+// graphJSON returns the nodes of the graph sorted by ID, and their edges in the order of the nodes.
+func (rg reqGraph) graphJSON() *GraphJSON {
+	reqs := make([]*Req, 0, len(rg))
+	for _, r := range rg {
+		reqs = append(reqs, r)
+	}
+	sort.Sort(byIDOrPath(reqs))
+
+	// The lists are empty rather than null in json for an empty graph.
+	g := &GraphJSON{Version: graphJSONVersion, Nodes: []NodeJSON{}, Edges: []EdgeJSON{}}
+	for _, r := range reqs {
+		n := NodeJSON{ID: r.ID, Type: r.ReqType(), Path: r.Path, Section: r.Section, Title: r.Title,
+			Body: string(r.LoadBody()), Rationale: string(r.Rationale), AcceptanceCriteria: string(r.AcceptanceCriteria),
+			Notes: string(r.Notes), Attributes: r.Attributes, Status: r.Status.String(), Deleted: r.IsDeleted(),
+			Hash: r.FileHash, TestEnv: r.TestEnv}
+		kind := "parent"
+		if r.Level == config.CODE {
+			n.Type = "CODE"
+			kind = "implements"
+		}
+		g.Nodes = append(g.Nodes, n)
+		for _, e := range []struct {
+			kind string
+			to   []*Req
+		}{
+			{kind, r.Parents},
+			{"partially-implements", r.PartiallyImplements},
+			{"verifies", r.Verifies},
+		} {
+			for _, to := range e.to {
+				g.Edges = append(g.Edges, EdgeJSON{r.ID, to.ID, e.kind})
+			}
+		}
+	}
+	return g
+}
+
+// exportJSON writes the graph as json, see GraphJSON.
+func exportJSON(rg reqGraph, w io.Writer) error {
+	b, err := json.MarshalIndent(rg.graphJSON(), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportJSON(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "System"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Title: "High",
+		Section: "2.1", ParentIds: []string{sys.ID}, Attributes: map[string]string{"VERIFICATION": "Test"}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Title: "Low",
+		ParentIds: []string{high.ID}}
+	code := &Req{ID: "a.go", Level: config.CODE, Path: "/repo/a.go", FileHash: "sha1:ce01", ParentIds: []string{low.ID}}
+	test := &Req{ID: "a_test.go", Level: config.CODE, Path: "/repo/a_test.go", VerifiesIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high.ID: high, low.ID: low, code.Path: code, test.Path: test}
+	assert.NoError(t, rg.Resolve())
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.Export("json", &b))
+	var g GraphJSON
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &g))
+	assert.Equal(t, graphJSONVersion, g.Version)
+	var ids, types []string
+	for _, n := range g.Nodes {
+		ids = append(ids, n.ID)
+		types = append(types, n.Type)
+	}
+	assert.Equal(t, []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-001", "REQ-0-TEST-SYS-001", "a.go", "a_test.go"}, ids)
+	assert.Equal(t, []string{"SWH", "SWL", "SYS", "CODE", "CODE"}, types)
+	assert.Equal(t, NodeJSON{ID: "REQ-0-TEST-SWH-001", Type: "SWH", Path: "certdocs/0-TEST-211-SRD.md", Section: "2.1",
+		Title: "High", Attributes: map[string]string{"VERIFICATION": "Test"}, Status: "COMPLETED"}, g.Nodes[0])
+	assert.Equal(t, "sha1:ce01", g.Nodes[3].Hash)
+	assert.Equal(t, []EdgeJSON{
+		{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SYS-001", "parent"},
+		{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWH-001", "parent"},
+		{"a.go", "REQ-0-TEST-SWL-001", "implements"},
+		{"a_test.go", "REQ-0-TEST-SWL-001", "verifies"},
+	}, g.Edges)
+
+	// The lists are empty rather than null.
+	b.Reset()
+	assert.NoError(t, reqGraph{}.Export("json", &b))
+	assert.Equal(t, "{\n  \"version\": 1,\n  \"nodes\": [],\n  \"edges\": []\n}", b.String())
+}
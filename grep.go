@@ -0,0 +1,160 @@
+// @llr REQ-0-DDLN-SWL-048
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// GrepMatch is a match of the grep command.
+type GrepMatch struct {
+	// Path and Line locate the match, relative to the repository root: the certification document
+	// and the line defining the requirement for the matches of its title, body or attributes, the
+	// code file and the line of the annotation for the matches of the code. Line is 0 if unknown,
+	// e.g. for the documents which aren't text files.
+	Path string
+	Line int
+	// Field is title, body, attribute:<name> or code.
+	Field string
+	// Text is the line of the field matching, as plain text.
+	Text string
+}
+
+func (m GrepMatch) String() string {
+	location := m.Path
+	if m.Line > 0 {
+		location += fmt.Sprintf(":%d", m.Line)
+	}
+	return fmt.Sprintf("%s: %s: %s", location, m.Field, m.Text)
+}
+
+// GrepResult are the matches of a requirement.
+type GrepResult struct {
+	Req     *Req
+	Matches []GrepMatch
+}
+
+// Grep searches the titles, the bodies and the attributes of the requirements, and the lines of
+// the code files referencing them, for re. The bodies are searched as plain text, a line at a
+// time, so the matches don't depend on the markup of the document formats. The results are in
+// the order of the documents and of the requirements in them, the matches of a requirement in the
+// order of its fields and then of the code files.
+func (rg reqGraph) Grep(re *regexp.Regexp) ([]GrepResult, error) {
+	byReq := map[*Req][]GrepMatch{}
+	lines := map[string]map[string]int{}
+	for _, r := range rg {
+		if r.Level == config.CODE {
+			continue
+		}
+		path := strings.TrimPrefix(r.Path, "/")
+		if lines[path] == nil {
+			var err error
+			if lines[path], err = reqLines(filepath.Join(git.RepoPath(), path)); err != nil {
+				return nil, err
+			}
+		}
+		match := func(field, text string) {
+			for _, l := range strings.Split(text, "\n") {
+				if l = strings.TrimSpace(l); re.MatchString(l) {
+					byReq[r] = append(byReq[r], GrepMatch{path, lines[path][r.ID], field, l})
+				}
+			}
+		}
+		match("title", r.Title)
+		match("body", plainText(string(r.LoadBody())))
+		var names []string
+		for name := range r.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			match("attribute:"+name, r.Attributes[name])
+		}
+	}
+
+	for _, c := range rg.CodeFilesByPosition() {
+		f, err := os.Open(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		matches, err := grepCode(c.ID, f, re)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %v", c.Path, err)
+		}
+		for id, m := range matches {
+			if r := rg[id]; r != nil && r.Level != config.CODE {
+				byReq[r] = append(byReq[r], m...)
+			}
+		}
+	}
+
+	var reqs []*Req
+	for r := range byReq {
+		reqs = append(reqs, r)
+	}
+	sort.Sort(byModulePosition(reqs))
+	var results []GrepResult
+	for _, r := range reqs {
+		results = append(results, GrepResult{r, byReq[r]})
+	}
+	return results, nil
+}
+
+// reqLines returns the lines of the document at path where the requirements first appear, by ID,
+// which is where they're defined as a document only references the requirements of the documents
+// above it. It's empty if the document can't be read as text, e.g. for an odt document.
+func reqLines(path string) (map[string]int, error) {
+	lines := map[string]int{}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		for _, id := range ReReqID.FindAllString(scanner.Text(), -1) {
+			if lines[id] == 0 {
+				lines[id] = n
+			}
+		}
+	}
+	// The lines of the documents which aren't text files, e.g. too long, are unknown.
+	return lines, nil
+}
+
+// grepCode returns the matches of re in the lines of the code file referencing requirements,
+// e.g. the @llr and @verifies annotations, by ID of the requirements referenced.
+func grepCode(id string, r io.Reader, re *regexp.Regexp) (map[string][]GrepMatch, error) {
+	matches := map[string][]GrepMatch{}
+	cr, err := codeReader(id, r)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(cr)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		ids := ReReqID.FindAllString(line, -1)
+		if len(ids) == 0 || !re.MatchString(line) {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, reqID := range ids {
+			if !seen[reqID] {
+				seen[reqID] = true
+				matches[reqID] = append(matches[reqID], GrepMatch{id, n, "code", line})
+			}
+		}
+	}
+	return matches, scanner.Err()
+}
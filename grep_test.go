@@ -0,0 +1,57 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_Grep(t *testing.T) {
+	const dir = "/testdata/TestCreateReqGraphTestEnv"
+	rg, err := CreateReqGraph(dir, dir, dir+"/sim")
+	assert.NoError(t, err)
+
+	results, err := rg.Grep(regexp.MustCompile(`(?i)only VERIFIED`))
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "REQ-0-TEST-SYS-002", results[0].Req.ID)
+		assert.Equal(t, []GrepMatch{{"testdata/TestCreateReqGraphTestEnv/0-TEST-100-ORD.md", 16, "title", "Only verified"}},
+			results[0].Matches)
+		assert.Equal(t, "REQ-0-TEST-SWL-002", results[1].Req.ID)
+	}
+
+	results, err = rg.Grep(regexp.MustCompile(`SWL-002`))
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "REQ-0-TEST-SWL-002", results[0].Req.ID)
+		if assert.Len(t, results[0].Matches, 1) {
+			assert.Equal(t, "testdata/TestCreateReqGraphTestEnv/sim/b.go:2: code: /"+"/ @llr REQ-0-TEST-SWL-002",
+				results[0].Matches[0].String())
+		}
+	}
+
+	results, err = rg.Grep(regexp.MustCompile(`Demonstration`))
+	assert.NoError(t, err)
+	assert.Len(t, results, 4)
+	for _, r := range results {
+		assert.Equal(t, "attribute:VERIFICATION", r.Matches[0].Field)
+	}
+}
+
+func TestGrepCode(t *testing.T) {
+	src := "/" + "/ @llr REQ-0-TEST-SWL-001, REQ-0-TEST-SWL-002\n" +
+		"func watchdog() {}\n" +
+		"/" + "/ @llr REQ-0-TEST-SWL-001 kicks the watchdog\n"
+	matches, err := grepCode("a.go", strings.NewReader(src), regexp.MustCompile(`watchdog`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]GrepMatch{
+		"REQ-0-TEST-SWL-001": {{"a.go", 3, "code", "/" + "/ @llr REQ-0-TEST-SWL-001 kicks the watchdog"}},
+	}, matches)
+
+	matches, err = grepCode("a.go", strings.NewReader(src), regexp.MustCompile(`SWL`))
+	assert.NoError(t, err)
+	assert.Len(t, matches["REQ-0-TEST-SWL-001"], 2)
+	assert.Len(t, matches["REQ-0-TEST-SWL-002"], 1)
+}
@@ -0,0 +1,99 @@
+// @llr REQ-0-DDLN-SWL-056
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// hashAlgorithms are the algorithms of the hashes of the code files, named as the git object
+// formats.
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+}
+
+// fileHashAlgorithm is the algorithm of the hashes of the code files, see setHashAlgorithm.
+var fileHashAlgorithm = "sha1"
+
+// setHashAlgorithm sets the algorithm of the hashes of the code files. If name is empty, it is
+// the object format of the repository, so the hashes are those of the git blobs.
+func setHashAlgorithm(name string) error {
+	if name == "" {
+		name = "sha1"
+		// Older versions of git only have SHA-1 repositories and print the option back.
+		if format, err := git.ObjectFormat(); err == nil && hashAlgorithms[format] != nil {
+			name = format
+		}
+	}
+	if hashAlgorithms[name] == nil {
+		return fmt.Errorf("Unknown hash algorithm %q, expected one of: sha1, sha256", name)
+	}
+	fileHashAlgorithm = name
+	return nil
+}
+
+// newFileHash returns the git compatible hash of a file of the given size: the header of the blob
+// is written, the content is to be written next.
+func newFileHash(algorithm string, size int64) hash.Hash {
+	h := hashAlgorithms[algorithm]()
+	fmt.Fprintf(h, "blob %d", size)
+	h.Write([]byte{0})
+	return h
+}
+
+// formatFileHash returns the content-addressed reference of a file from its hash, the algorithm
+// and the hex digest, e.g. sha256:3b18e5....
+func formatFileHash(algorithm string, sum []byte) string {
+	return algorithm + ":" + hex.EncodeToString(sum)
+}
+
+// fileHashAlgorithmOf returns the algorithm of the content-addressed reference of a file, empty
+// if it has none.
+func fileHashAlgorithmOf(fileHash string) string {
+	if i := strings.Index(fileHash, ":"); i >= 0 {
+		return fileHash[:i]
+	}
+	return ""
+}
+
+// hashFile returns the content-addressed reference of the file with the algorithm.
+func hashFile(algorithm, fileName string) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	h := newFileHash(algorithm, s.Size())
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return formatFileHash(algorithm, h.Sum(nil)), nil
+}
+
+// sameFileContent returns whether the hashes are of the same content of the file. The hashes of
+// different algorithms, e.g. of a baseline written before the repository moved to SHA-256, are
+// compared by hashing the file again with the algorithm of the old one.
+func sameFileContent(fileName, newHash, oldHash string) bool {
+	if newHash == oldHash {
+		return true
+	}
+	algorithm := fileHashAlgorithmOf(oldHash)
+	if algorithm == fileHashAlgorithmOf(newHash) || hashAlgorithms[algorithm] == nil {
+		return false
+	}
+	h, err := hashFile(algorithm, fileName)
+	return err == nil && h == oldHash
+}
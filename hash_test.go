@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "a.go")
+	assert.NoError(t, ioutil.WriteFile(fileName, []byte("hello\n"), 0644))
+
+	// As git hash-object, with SHA-1 and SHA-256 repositories.
+	sha1Hash, err := hashFile("sha1", fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha1:ce013625030ba8dba906f756967f9e9ca394464a", sha1Hash)
+	sha256Hash, err := hashFile("sha256", fileName)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:2cf8d83d9ee29543b34a87727421fdecb7e3f3a183d337639025de576db9ebb4", sha256Hash)
+	assert.Equal(t, "sha256", fileHashAlgorithmOf(sha256Hash))
+
+	assert.True(t, sameFileContent(fileName, sha256Hash, sha256Hash))
+	assert.True(t, sameFileContent(fileName, sha256Hash, sha1Hash))
+	assert.False(t, sameFileContent(fileName, sha256Hash, "sha1:0000000000000000000000000000000000000000"))
+	assert.False(t, sameFileContent(fileName, sha256Hash, "sha256:00"))
+
+	defer func(old string) { fileHashAlgorithm = old }(fileHashAlgorithm)
+	assert.NoError(t, setHashAlgorithm("sha256"))
+	assert.Equal(t, "sha256", fileHashAlgorithm)
+	assert.EqualError(t, setHashAlgorithm("md5"), `Unknown hash algorithm "md5", expected one of: sha1, sha256`)
+}
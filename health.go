@@ -0,0 +1,101 @@
+// @llr REQ-0-DDLN-SWL-045
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// version is the commit reqtraq was built from, set when building, e.g. with
+//
+//	go build -ldflags "-X main.version=$(git rev-parse HEAD)"
+var version string
+
+// buildInfo is the metadata of the data served by the web server, returned by /version.
+type buildInfo struct {
+	// Version is the commit reqtraq was built from, "unknown" if not set when building.
+	Version string `json:"version"`
+	// Config is the SHA-256 of the attributes json, empty if there is none.
+	Config string `json:"config"`
+	// Graph is the SHA-256 of the snapshot of the last graph built from the working tree, as
+	// archived in graph.json, and Rebuilt the time it was built. They are empty until the first
+	// report of the current requirements or readiness check.
+	Graph   string     `json:"graph,omitempty"`
+	Rebuilt *time.Time `json:"rebuilt,omitempty"`
+}
+
+// lastBuild is the last graph built by the web server from the working tree.
+var lastBuild struct {
+	sync.Mutex
+	graph   string
+	rebuilt time.Time
+}
+
+// recordBuild sets the graph as the last one built from the working tree.
+func recordBuild(rg reqGraph) error {
+	b, err := rg.snapshot()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	lastBuild.Lock()
+	defer lastBuild.Unlock()
+	lastBuild.graph = hex.EncodeToString(sum[:])
+	lastBuild.rebuilt = time.Now()
+	return nil
+}
+
+// currentBuildInfo returns the metadata of the data served, the attributes json being read at
+// configPath.
+func currentBuildInfo(configPath string) (buildInfo, error) {
+	info := buildInfo{Version: version}
+	if info.Version == "" {
+		info.Version = "unknown"
+	}
+	b, err := ioutil.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return info, err
+	}
+	if err == nil {
+		sum := sha256.Sum256(b)
+		info.Config = hex.EncodeToString(sum[:])
+	}
+	lastBuild.Lock()
+	defer lastBuild.Unlock()
+	if lastBuild.graph != "" {
+		rebuilt := lastBuild.rebuilt
+		info.Graph, info.Rebuilt = lastBuild.graph, &rebuilt
+	}
+	return info, nil
+}
+
+// serveVersion writes the metadata of the data served as json.
+func serveVersion(w http.ResponseWriter) error {
+	info, err := currentBuildInfo(*fReportJsonConfPath)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(info)
+}
+
+// serveReady builds the graph of the working tree and replies 200 if it has no issues, or
+// 503 with the issues otherwise, e.g. while a document is being fixed.
+func serveReady(w http.ResponseWriter) {
+	rg, _, err := buildGraph("")
+	if err == nil {
+		err = recordBuild(rg)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_healthz(t *testing.T) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok\n", w.Body.String())
+}
+
+func TestCurrentBuildInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "health")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configPath := filepath.Join(dir, "attributes.json")
+
+	info, err := currentBuildInfo(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "unknown", info.Version)
+	assert.Empty(t, info.Config)
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte("{}\n"), 0644))
+	defer func(v string) { version = v }(version)
+	version = "4b36b3e"
+	rg := reqGraph{"REQ-0-TEST-SYS-001": &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "First"}}
+	assert.NoError(t, recordBuild(rg))
+	info, err = currentBuildInfo(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "4b36b3e", info.Version)
+	// The SHA-256 of "{}\n".
+	assert.Equal(t, "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356", info.Config)
+	assert.Len(t, info.Graph, 64)
+	assert.NotNil(t, info.Rebuilt)
+	graph := info.Graph
+
+	rg["REQ-0-TEST-SYS-001"].Title = "Renamed"
+	assert.NoError(t, recordBuild(rg))
+	info, err = currentBuildInfo(configPath)
+	assert.NoError(t, err)
+	assert.NotEqual(t, graph, info.Graph)
+}
@@ -0,0 +1,215 @@
+// @llr REQ-0-DDLN-SWL-031
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// HistoryConf is the configuration of the attribute history, the "history" entry in attributes.json.
+type HistoryConf struct {
+	Attributes []HistoryAttributeConf `json:"attributes"`
+}
+
+// HistoryAttributeConf defines an attribute whose values are tracked over the git history, such as
+// a safety classification. Values are regular expressions matching the classifications, from the
+// most to the least critical one. A change to a less critical classification is a downgrade, which
+// the safety assessment must justify.
+type HistoryAttributeConf struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// defaultHistoryConf is used when attributes.json doesn't configure the attribute history: the
+// failure condition classifications of ARP4761 and the development assurance levels of DO-178C.
+var defaultHistoryConf = HistoryConf{
+	Attributes: []HistoryAttributeConf{
+		{Name: "Safety Impact", Values: []string{`(?i)catastrophic`, `(?i)hazardous`, `(?i)major`, `(?i)minor`, `(?i)^\s*(no|none)\b`}},
+		{Name: "DAL", Values: []string{`(?i)^\s*(DAL\s*)?A\s*$`, `(?i)^\s*(DAL\s*)?B\s*$`, `(?i)^\s*(DAL\s*)?C\s*$`, `(?i)^\s*(DAL\s*)?D\s*$`, `(?i)^\s*(DAL\s*)?E\s*$`}},
+	},
+}
+
+// historyConf returns the attribute history configuration, or the default one if there's none.
+func (c JsonConf) historyConf() HistoryConf {
+	if c.History == nil {
+		return defaultHistoryConf
+	}
+	return *c.History
+}
+
+// AttributeChange is a change of the value of a tracked attribute of a requirement, in a commit.
+type AttributeChange struct {
+	Req       *Req
+	Attribute string
+	Commit    string
+	Date      string
+	From, To  string
+	// Downgrade is whether the value changed to a less critical classification.
+	Downgrade bool
+}
+
+// docVersion is a certification document as of a commit.
+type docVersion struct {
+	Commit string
+	Date   string
+	Reqs   []*Req
+}
+
+// rank returns the position of the classification matching the value among the values of the
+// attribute, or -1 if none matches.
+func (a HistoryAttributeConf) rank(value string) (int, error) {
+	for i, v := range a.Values {
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return -1, fmt.Errorf("Invalid value %q of the history attribute %s: %v", v, a.Name, err)
+		}
+		if re.MatchString(value) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// changes returns the changes of the tracked attributes between the successive versions of a
+// certification document, oldest first.
+func (c HistoryConf) changes(versions []docVersion) ([]*AttributeChange, error) {
+	var changes []*AttributeChange
+	previous := map[string]*Req{}
+	for _, v := range versions {
+		current := map[string]*Req{}
+		for _, r := range v.Reqs {
+			current[r.ID] = r
+			p, ok := previous[r.ID]
+			if !ok {
+				continue
+			}
+			for _, a := range c.Attributes {
+				name := strings.ToUpper(a.Name)
+				from, to := p.Attributes[name], r.Attributes[name]
+				if strings.TrimSpace(from) == strings.TrimSpace(to) {
+					continue
+				}
+				fromRank, err := a.rank(from)
+				if err != nil {
+					return nil, err
+				}
+				toRank, err := a.rank(to)
+				if err != nil {
+					return nil, err
+				}
+				// Removing a classification downgrades it as well.
+				downgrade := fromRank != -1 && (toRank > fromRank || strings.TrimSpace(to) == "")
+				changes = append(changes, &AttributeChange{r, a.Name, v.Commit, v.Date, from, to, downgrade})
+			}
+		}
+		previous = current
+	}
+	return changes, nil
+}
+
+// certdocHistory returns the versions of the certification document committed, oldest first.
+func certdocHistory(fileName string) ([]docVersion, error) {
+	commits, err := git.FileCommits(fileName)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	versionName := filepath.Join(dir, filepath.Base(fileName))
+
+	var versions []docVersion
+	for i := len(commits) - 1; i >= 0; i-- {
+		content, err := git.FileAt(commits[i], fileName)
+		if err != nil {
+			// The document was deleted in the commit.
+			versions = append(versions, docVersion{Commit: commits[i]})
+			continue
+		}
+		if err := ioutil.WriteFile(versionName, []byte(content), 0644); err != nil {
+			return nil, err
+		}
+		date, err := git.CommitDate(commits[i])
+		if err != nil {
+			return nil, err
+		}
+		// The requirements which failed to parse in a version are missing from it.
+		reqs, _ := docParser(versionName).ParseDoc(versionName)
+		versions = append(versions, docVersion{commits[i], date, reqs})
+	}
+	return versions, nil
+}
+
+// AttributeHistory returns the changes of the tracked attributes of the requirements of the graph
+// over the git history of their certification documents, by document and oldest first.
+func (rg reqGraph) AttributeHistory(conf HistoryConf) ([]*AttributeChange, error) {
+	paths := map[string]bool{}
+	for _, r := range rg {
+		if r.Level != config.CODE {
+			paths[r.Path] = true
+		}
+	}
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var changes []*AttributeChange
+	for _, p := range sorted {
+		versions, err := certdocHistory(filepath.Join(git.RepoPath(), p))
+		if err != nil {
+			return nil, err
+		}
+		c, err := conf.changes(versions)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c...)
+	}
+	// The changes show the requirements as they are in the graph.
+	for _, c := range changes {
+		if r, ok := rg[c.Req.ID]; ok {
+			c.Req = r
+		}
+	}
+	return changes, nil
+}
+
+type historyReportData struct {
+	Downgrades []*AttributeChange
+	Changes    []*AttributeChange
+	Filter     ReqFilter
+	Diffs      map[string][]string
+}
+
+// ReportHistory writes an HTML report of the changes of the tracked attributes of the requirements
+// matching the filter and the diffs, listing the downgrades first.
+func (rg reqGraph) ReportHistory(w io.Writer, conf HistoryConf, f ReqFilter, diffs map[string][]string) error {
+	changes, err := rg.AttributeHistory(conf)
+	if err != nil {
+		return err
+	}
+	data := historyReportData{Filter: f, Diffs: diffs}
+	for _, c := range changes {
+		if !c.Req.Matches(f, diffs) {
+			continue
+		}
+		if c.Downgrade {
+			data.Downgrades = append(data.Downgrades, c)
+		}
+		data.Changes = append(data.Changes, c)
+	}
+	return reportTmpl.ExecuteTemplate(w, "HISTORY", data)
+}
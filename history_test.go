@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryConf_changes(t *testing.T) {
+	version := func(commit string, impacts ...string) docVersion {
+		var reqs []*Req
+		for i, impact := range impacts {
+			id := []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SYS-002"}[i]
+			reqs = append(reqs, &Req{ID: id, Attributes: map[string]string{"SAFETY IMPACT": impact, "DAL": "B"}})
+		}
+		return docVersion{Commit: commit, Date: "2020-01-0" + commit, Reqs: reqs}
+	}
+	versions := []docVersion{
+		version("1", "Major", "Minor"),
+		version("2", "Hazardous", "Minor"),
+		version("3", "Minor", "Minor "),
+		version("4", "", "Catastrophic"),
+		version("5"),
+		version("6", "None"),
+	}
+	changes, err := defaultHistoryConf.changes(versions)
+	assert.NoError(t, err)
+
+	var summaries []string
+	for _, c := range changes {
+		summary := c.Commit + " " + c.Req.ID + " " + c.From + " -> " + c.To
+		if c.Downgrade {
+			summary += " downgrade"
+		}
+		summaries = append(summaries, summary)
+	}
+	assert.Equal(t, []string{
+		"2 REQ-0-TEST-SYS-001 Major -> Hazardous",
+		"3 REQ-0-TEST-SYS-001 Hazardous -> Minor downgrade",
+		"4 REQ-0-TEST-SYS-001 Minor ->  downgrade",
+		"4 REQ-0-TEST-SYS-002 Minor  -> Catastrophic",
+	}, summaries)
+	assert.Equal(t, "Safety Impact", changes[0].Attribute)
+	assert.Equal(t, "2020-01-02", changes[0].Date)
+
+	conf := HistoryConf{Attributes: []HistoryAttributeConf{{Name: "DAL", Values: []string{"("}}}}
+	_, err = conf.changes([]docVersion{version("1", "Major"), {Reqs: []*Req{{ID: "REQ-0-TEST-SYS-001"}}}})
+	assert.EqualError(t, err, "Invalid value \"(\" of the history attribute DAL: error parsing regexp: missing closing ): `(`")
+}
+
+func TestHistoryAttributeConf_rank(t *testing.T) {
+	dal := defaultHistoryConf.Attributes[1]
+	for value, rank := range map[string]int{"A": 0, "DAL B": 1, " c ": 2, "E": 4, "Level A": -1, "": -1} {
+		r, err := dal.rank(value)
+		assert.NoError(t, err)
+		assert.Equal(t, rank, r, value)
+	}
+}
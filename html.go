@@ -0,0 +1,256 @@
+// @llr REQ-0-DDLN-SWL-001
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+func init() {
+	RegisterDocParser(DocParserFunc(ParseHTMLCertdoc), ".htm", ".html")
+}
+
+// In .html certification documents, typically exported from other tools, each h1-h6 heading
+// starting with a requirement ID defines a requirement. As for .md documents, the requirement
+// headings of a section must all be at the same level, and the deeper headings are part of the
+// requirement.
+//
+// The attributes start at the first block, e.g. a paragraph or a list item, starting with an
+// attribute name followed by a colon, or at a deeper heading reading "Attributes:". Everything
+// before is the body of the requirement, kept as HTML.
+
+var (
+	reHTMLHeading    = regexp.MustCompile(`^h([1-6])$`)
+	reHTMLAttributes = regexp.MustCompile(`(?i)^\s*attributes:?\s*$`)
+	htmlBlocks       = map[string]bool{
+		"blockquote": true, "br": true, "dd": true, "div": true, "dl": true, "dt": true, "li": true,
+		"ol": true, "p": true, "pre": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+	}
+)
+
+// htmlReq is a requirement being parsed from a .html certification document.
+type htmlReq struct {
+	heading   string // text of the heading
+	line      int    // line of the heading
+	bodyStart int    // offset of the end of the heading
+	end       int    // offset of the end of the requirement
+	attrStart int    // offset of the "Attributes:" heading, if any
+	text      bytes.Buffer
+	blocks    []htmlBlock
+}
+
+// htmlBlock is the start of a block element, as an offset in the document and in the text.
+type htmlBlock struct {
+	offset, text int
+}
+
+// ParseHTMLCertdoc parses the .html certification document f and returns its requirements, in
+// document order.
+func ParseHTMLCertdoc(f string) ([]*Req, error) {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return parseHTMLReqs(b)
+}
+
+// parseHTMLReqs parses the requirements of the HTML document b.
+func parseHTMLReqs(b []byte) ([]*Req, error) {
+	lineAt := func(offset int) int { return bytes.Count(b[:offset], []byte("\n")) + 1 }
+
+	d := xml.NewDecoder(bytes.NewReader(b))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	var (
+		htmlReqs []*htmlReq
+		current  *htmlReq
+
+		headingLevel int // The level of the heading being read, 0 outside of headings.
+		headingStart int
+		heading      bytes.Buffer
+		skip         int // The depth within script and style elements.
+
+		lastHeadingLevel int // The level of the last heading.
+		lastHeadingLine  int // The line number of the last heading.
+		reqLevel         int // The level of the heading starting the current requirement.
+	)
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineAt(start), err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if name == "script" || name == "style" {
+				skip++
+			}
+			if m := reHTMLHeading.FindStringSubmatch(name); m != nil && headingLevel == 0 {
+				headingLevel = int(m[1][0] - '0')
+				headingStart = start
+				heading.Reset()
+				if current != nil {
+					current.startBlock(start)
+				}
+			} else if htmlBlocks[name] && current != nil {
+				current.startBlock(start)
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			if (name == "script" || name == "style") && skip > 0 {
+				skip--
+			}
+			if htmlBlocks[name] && current != nil {
+				current.text.WriteString("\n")
+			}
+			if m := reHTMLHeading.FindStringSubmatch(name); m == nil || headingLevel != int(m[1][0]-'0') {
+				continue
+			}
+			level := headingLevel
+			headingLevel = 0
+			text := strings.Join(strings.Fields(heading.String()), " ")
+			line := lineAt(headingStart)
+
+			reqIDs := ReReqID.FindAllString(text, -1)
+			if len(reqIDs) > 1 {
+				return nil, fmt.Errorf("malformed requirement heading: too many IDs on line %d: %q", line, text)
+			}
+			if len(reqIDs) == 1 {
+				switch {
+				case current != nil && level != reqLevel:
+					return nil, fmt.Errorf("requirement heading on line %d must be at same level as requirement heading on line %d (%d != %d): %q", line, current.line, level, reqLevel, text)
+				case current == nil && level == lastHeadingLevel:
+					return nil, fmt.Errorf("requirement heading on line %d at same level as previous heading on line %d (%d): %q", line, lastHeadingLine, level, text)
+				}
+				if current != nil {
+					current.end = headingStart
+				}
+				current = &htmlReq{heading: text, line: line, bodyStart: int(d.InputOffset()), attrStart: -1}
+				htmlReqs = append(htmlReqs, current)
+				reqLevel = level
+			} else if current != nil {
+				switch {
+				case level == reqLevel:
+					return nil, fmt.Errorf("non-requirement heading on line %d at same level as requirement heading on line %d (%d): %q", line, current.line, level, text)
+				case level < reqLevel:
+					// Higher-level heading, ends the current requirement.
+					current.end = headingStart
+					current = nil
+				default:
+					// Lower-level heading, part of the current requirement.
+					if current.attrStart < 0 && reHTMLAttributes.MatchString(text) {
+						current.attrStart = headingStart
+					}
+					current.text.WriteString("\n")
+				}
+			}
+			lastHeadingLevel = level
+			lastHeadingLine = line
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if headingLevel > 0 {
+				heading.Write(t)
+			} else if current != nil {
+				current.text.Write(t)
+			}
+		}
+	}
+	if current != nil {
+		current.end = len(b)
+	}
+
+	var reqs []*Req
+	for _, hr := range htmlReqs {
+		r, err := hr.toReq(b)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, nil
+}
+
+func (hr *htmlReq) startBlock(offset int) {
+	hr.text.WriteString("\n")
+	hr.blocks = append(hr.blocks, htmlBlock{offset, hr.text.Len()})
+}
+
+// toReq converts hr into a Req, splitting its body and its attributes.
+func (hr *htmlReq) toReq(doc []byte) (*Req, error) {
+	loc := ReReqID.FindStringIndex(hr.heading)
+	if loc[0] != 0 {
+		return nil, fmt.Errorf("malformed requirement: ID must be at the start of the heading on line %d: %q", hr.line, hr.heading)
+	}
+	r := &Req{ID: hr.heading[loc[0]:loc[1]], Attributes: map[string]string{}}
+	r.Title = strings.TrimLeftFunc(strings.TrimLeftFunc(hr.heading[loc[1]:], unicode.IsPunct), unicode.IsSpace)
+
+	// The attributes start with the first keyword at the start of a line.
+	text := hr.text.String()
+	kwdMatches := reReqKWD.FindAllStringSubmatchIndex(text, -1)
+	for len(kwdMatches) > 0 {
+		lineStart := strings.LastIndex(text[:kwdMatches[0][0]], "\n") + 1
+		if strings.TrimSpace(text[lineStart:kwdMatches[0][0]]) == "" {
+			break
+		}
+		kwdMatches = kwdMatches[1:]
+	}
+	if len(kwdMatches) == 0 {
+		return nil, fmt.Errorf("requirement %s contains no attributes", r.ID)
+	}
+	for i, v := range kwdMatches {
+		key := strings.ToUpper(text[v[4]:v[5]])
+		if key == "PARENT" { // accept both, output only PARENTS
+			key = "PARENTS"
+		}
+		e := len(text)
+		if i < len(kwdMatches)-1 {
+			e = kwdMatches[i+1][0]
+		}
+		if _, ok := r.Attributes[key]; ok {
+			return nil, fmt.Errorf("requirement %s contains duplicate attribute: %q", r.ID, key)
+		}
+		r.Attributes[key] = strings.Join(strings.Fields(text[v[1]:e]), " ")
+	}
+
+	// The body ends at the block containing the first attribute, or at the "Attributes:" heading.
+	bodyEnd := hr.end
+	for _, b := range hr.blocks {
+		if b.text <= kwdMatches[0][0] && strings.TrimSpace(text[b.text:kwdMatches[0][0]]) == "" {
+			bodyEnd = b.offset
+			break
+		}
+	}
+	if hr.attrStart >= 0 && hr.attrStart < bodyEnd {
+		bodyEnd = hr.attrStart
+	}
+	r.Body = template.HTML(strings.TrimSpace(string(doc[hr.bodyStart:bodyEnd])))
+
+	var err error
+	if r.ParentIds, err = parseParentIds(r.ID, r.Attributes["PARENTS"]); err != nil {
+		return nil, err
+	}
+	level, ok := config.ReqTypeToReqLevel[r.ReqType()]
+	if !ok {
+		return nil, fmt.Errorf("Invalid request type: %q", r.ReqType())
+	}
+	r.Level = level
+	return r, nil
+}
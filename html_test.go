@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseHTMLContent(t *testing.T, content string) ([]*Req, error) {
+	f, err := createTempFile(content, "parseHTML")
+	if f != nil {
+		defer os.Remove(f.Name())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseHTMLCertdoc(f.Name())
+}
+
+func TestParseHTMLCertdoc(t *testing.T) {
+	reqs, err := parseHTMLContent(t, `<html><body>
+<h1>Requirements</h1>
+<h2><a name="x"></a>REQ-0-TEST-SWH-001: A &amp; B
+</h2>
+<p>The <b>body</b>, mentioning the rationale: in a sentence.</p>
+<p>More body.
+<h3>Attributes:</h3>
+<dl><dt>Rationale: Because
+of this.</dt><dt>Parents: REQ-0-TEST-SYS-001, REQ-0-TEST-SYS-002</dt></dl>
+<h2>REQ-0-TEST-SWH-002 Second</h2>
+<ul><li>Rationale: None</li></ul>
+<h1>Other section</h1>
+<p>Safety impact: not an attribute of REQ-0-TEST-SWH-002.</p>
+</body></html>`)
+	if !assert.NoError(t, err) || !assert.Len(t, reqs, 2) {
+		return
+	}
+	assert.Equal(t, "REQ-0-TEST-SWH-001", reqs[0].ID)
+	assert.Equal(t, "A & B", reqs[0].Title)
+	assert.Equal(t, "<p>The <b>body</b>, mentioning the rationale: in a sentence.</p>\n<p>More body.", string(reqs[0].Body))
+	assert.Equal(t, map[string]string{
+		"RATIONALE": "Because of this.",
+		"PARENTS":   "REQ-0-TEST-SYS-001, REQ-0-TEST-SYS-002",
+	}, reqs[0].Attributes)
+	assert.Equal(t, []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SYS-002"}, reqs[0].ParentIds)
+	assert.Equal(t, "", string(reqs[1].Body))
+	assert.Equal(t, map[string]string{"RATIONALE": "None"}, reqs[1].Attributes)
+
+	for _, c := range []struct {
+		content, expectedError string
+	}{
+		{"<h1>REQ-0-TEST-SYS-001 REQ-0-TEST-SYS-002</h1>", "too many IDs on line 1"},
+		{"<h1>REQ-0-TEST-SYS-001 A</h1><p>Rationale: x</p>\n<h2>REQ-0-TEST-SYS-002 B</h2>", "requirement heading on line 2 must be at same level as requirement heading on line 1"},
+		{"<h1>Intro</h1>\n<h1>REQ-0-TEST-SYS-001 A</h1>", "requirement heading on line 2 at same level as previous heading on line 1"},
+		{"<h1>REQ-0-TEST-SYS-001 A</h1><p>Rationale: x</p>\n<h1>Notes</h1>", "non-requirement heading on line 2 at same level as requirement heading on line 1"},
+		{"<h1>Intro REQ-0-TEST-SYS-001</h1>", "ID must be at the start of the heading on line 1"},
+		{"<h1>REQ-0-TEST-SYS-001 A</h1><p>No attributes, even with a rationale: here.</p>", "requirement REQ-0-TEST-SYS-001 contains no attributes"},
+		{"<h1>REQ-0-TEST-SYS-001 A</h1><p>Parent: x</p><p>Parents: y</p>", "duplicate attribute: \"PARENTS\""},
+	} {
+		_, err := parseHTMLContent(t, c.content)
+		if err == nil {
+			t.Errorf("content `%s` does not generate error `%s`", c.content, c.expectedError)
+			continue
+		}
+		assert.Contains(t, err.Error(), c.expectedError)
+	}
+}
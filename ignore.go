@@ -0,0 +1,168 @@
+// @llr REQ-0-DDLN-SWL-044
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreFileName is the file at the repository root listing the files and directories skipped
+// when walking the certification documents and the code, e.g. generated code, vendored
+// third-party sources or templates of documents, in gitignore syntax.
+const ignoreFileName = ".reqtraqignore"
+
+// ignoreRule is a pattern of the ignore file.
+type ignoreRule struct {
+	re *regexp.Regexp
+	// negate is set for the patterns starting with !, which include again the files excluded by
+	// the previous patterns.
+	negate bool
+	// dirOnly is set for the patterns ending with /, which only match directories.
+	dirOnly bool
+}
+
+// ignoreRules are the patterns of an ignore file, in order, the last one matching a path
+// deciding whether it is ignored.
+type ignoreRules []ignoreRule
+
+// parseIgnoreRules reads the patterns of an ignore file, one per line, skipping the blank lines
+// and the comments starting with #.
+func parseIgnoreRules(r io.Reader) (ignoreRules, error) {
+	var rules ignoreRules
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// An escaped leading ! or #.
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		// A pattern with a slash other than at its end is relative to the repository root,
+		// otherwise it matches at any depth.
+		prefix := `^(?:.*/)?`
+		if strings.Contains(line, "/") {
+			prefix = "^"
+			line = strings.TrimPrefix(line, "/")
+		}
+		re, err := regexp.Compile(prefix + globToRegexp(line) + "$")
+		if err != nil || line == "" {
+			return nil, fmt.Errorf("Invalid pattern in %s line %d: %q", ignoreFileName, n, scanner.Text())
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// globToRegexp translates a gitignore pattern to a regular expression: * matches anything but
+// a slash, ? a single character other than a slash, [...] one of the characters listed, and **
+// any number of directories.
+func globToRegexp(glob string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString(`(?:.*/)?`)
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(`.*`)
+			i++
+		case c == '*':
+			b.WriteString(`[^/]*`)
+		case c == '?':
+			b.WriteString(`[^/]`)
+		case c == '[':
+			end := strings.Index(glob[i+1:], "]")
+			if end == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match returns whether the path, relative to the repository root, is ignored by the patterns
+// themselves, regardless of its parent directories.
+func (rules ignoreRules) match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if (!r.dirOnly || isDir) && r.re.MatchString(relPath) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// Ignored returns whether the file or directory at relPath, relative to the repository root, is
+// ignored. As with git, a file can't be included again if one of its parent directories is
+// ignored.
+func (rules ignoreRules) Ignored(relPath string, isDir bool) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	relPath = filepath.ToSlash(relPath)
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if rules.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.match(relPath, isDir)
+}
+
+// loadIgnoreRules reads the ignore file of the repository, none if it doesn't exist.
+func loadIgnoreRules(repoPath string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(repoPath, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIgnoreRules(f)
+}
+
+// walkFunc returns a filepath.WalkFunc calling fn for the files and directories under repoPath
+// which aren't ignored, skipping the ignored directories.
+func (rules ignoreRules) walkFunc(repoPath string, fn filepath.WalkFunc) filepath.WalkFunc {
+	return func(fileName string, info os.FileInfo, err error) error {
+		if err == nil {
+			if rel, relErr := filepath.Rel(repoPath, fileName); relErr == nil && rel != "." && rules.Ignored(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		return fn(fileName, info, err)
+	}
+}
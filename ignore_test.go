@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIgnoreRules_Ignored(t *testing.T) {
+	rules, err := parseIgnoreRules(strings.NewReader(`# Generated and vendored code.
+*.pb.go
+vendor/
+/build
+third_party/**/*.c
+!third_party/**/patched.c
+certdocs/templates/*.md
+\#notes.md
+`))
+	assert.NoError(t, err)
+	for path, ignored := range map[string]bool{
+		"api.pb.go":                        true,
+		"pkg/api/api.pb.go":                true,
+		"pkg/api/api.go":                   false,
+		"vendor/lib/lib.go":                true,
+		"pkg/vendor/lib.go":                true,
+		"build/gen.c":                      true,
+		"pkg/build/gen.c":                  false,
+		"third_party/zlib/inflate.c":       true,
+		"third_party/inflate.c":            true,
+		"third_party/zlib/patched.c":       false,
+		"third_party/zlib/inflate.h":       false,
+		"certdocs/templates/0-TEST-SRD.md": true,
+		"certdocs/0-TEST-211-SRD.md":       false,
+		"#notes.md":                        true,
+	} {
+		assert.Equal(t, ignored, rules.Ignored(path, false), path)
+	}
+	// A directory pattern doesn't match files.
+	assert.False(t, rules.Ignored("vendor", false))
+	assert.True(t, rules.Ignored("vendor", true))
+
+	// Files can't be included again if their directory is ignored.
+	rules, err = parseIgnoreRules(strings.NewReader("gen/\n!gen/keep.go\n"))
+	assert.NoError(t, err)
+	assert.True(t, rules.Ignored("gen/keep.go", false))
+
+	_, err = parseIgnoreRules(strings.NewReader("ok\n/\n"))
+	assert.EqualError(t, err, `Invalid pattern in .reqtraqignore line 2: "/"`)
+}
+
+func TestIgnoreRules_walkFunc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a.go", "gen/b.go", "c/d.pb.go", "c/e.go"} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ignoreFileName), []byte("gen/\n*.pb.go\n"), 0644))
+	rules, err := loadIgnoreRules(dir)
+	assert.NoError(t, err)
+
+	var walked []string
+	assert.NoError(t, filepath.Walk(dir, rules.walkFunc(dir, func(fileName string, info os.FileInfo, err error) error {
+		if !info.IsDir() {
+			walked = append(walked, fileName[len(dir)+1:])
+		}
+		return nil
+	})))
+	assert.Equal(t, []string{ignoreFileName, "a.go", filepath.Join("c", "e.go")}, walked)
+
+	rules, err = loadIgnoreRules(filepath.Join(dir, "c"))
+	assert.NoError(t, err)
+	assert.Nil(t, rules)
+}
@@ -0,0 +1,144 @@
+// @llr REQ-0-DDLN-SWL-071
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// Impact are the requirements, the certification documents and the code files potentially
+// affected by a change, to scope the verification to do again.
+type Impact struct {
+	// Changed are the requirements and the code files changed, by ID.
+	Changed      []string
+	Requirements []*Req
+	// Documents are the paths of the certification documents of the requirements, relative to
+	// the repository.
+	Documents []string
+	Code      []*Req
+}
+
+// Impact returns what the changed requirements and code files potentially affect: their
+// ancestors, which they no longer fulfil, their descendants down to the code, which may no longer
+// fulfil them, and the code files verifying any of these requirements. The changed nodes are
+// included, the deleted requirements are not.
+func (rg reqGraph) Impact(changed []*Req) Impact {
+	affected := map[*Req]bool{}
+	var up, down func(r *Req)
+	up = func(r *Req) {
+		affected[r] = true
+		for _, p := range r.Parents {
+			if !affected[p] && !p.IsDeleted() {
+				up(p)
+			}
+		}
+	}
+	down = func(r *Req) {
+		affected[r] = true
+		for _, c := range r.Children {
+			if !affected[c] && !c.IsDeleted() {
+				down(c)
+			}
+		}
+		for _, c := range r.PartiallyImplementedBy {
+			affected[c] = true
+		}
+	}
+	var impact Impact
+	for _, r := range changed {
+		impact.Changed = append(impact.Changed, r.ID)
+		up(r)
+		down(r)
+	}
+	sort.Strings(impact.Changed)
+	for r := range affected {
+		for _, c := range r.VerifiedBy {
+			affected[c] = true
+		}
+	}
+
+	docs := map[string]bool{}
+	for r := range affected {
+		switch {
+		case r.Level == config.CODE:
+			impact.Code = append(impact.Code, r)
+		case !r.IsDeleted():
+			impact.Requirements = append(impact.Requirements, r)
+			docs[strings.TrimPrefix(r.Path, "/")] = true
+		}
+	}
+	sort.Sort(byIDOrPath(impact.Requirements))
+	sort.Sort(byIDOrPath(impact.Code))
+	for doc := range docs {
+		impact.Documents = append(impact.Documents, doc)
+	}
+	sort.Strings(impact.Documents)
+	return impact
+}
+
+// changedSince returns the requirements and the code files of the graph changed since the graph
+// prg, e.g. at the start of a branch: the requirements added or changed, see
+// (reqGraph).ChangedSince, and the code files of the paths given, relative to the repository,
+// e.g. changed according to git. The requirements removed are replaced by their former parents
+// and children still in the graph.
+func (rg reqGraph) changedSince(prg reqGraph, paths []string) []*Req {
+	seen := map[*Req]bool{}
+	var changed []*Req
+	add := func(r *Req) {
+		if r != nil && !seen[r] {
+			seen[r] = true
+			changed = append(changed, r)
+		}
+	}
+	// The code files of the graphs are keyed by path in their own checkout, so only the
+	// requirements of the diff are taken.
+	for id := range rg.ChangedSince(prg) {
+		if r := rg[id]; r != nil && r.Level != config.CODE {
+			add(r)
+		} else if p := prg[id]; r == nil && p != nil && p.Level != config.CODE {
+			for _, q := range append(append([]*Req{}, p.Parents...), p.Children...) {
+				add(rg.current(q))
+			}
+		}
+	}
+	for _, p := range paths {
+		add(rg[filepath.Join(git.RepoPath(), p)])
+	}
+	sort.Sort(byIDOrPath(changed))
+	return changed
+}
+
+// current returns the node of the graph of the requirement or code file of another graph.
+func (rg reqGraph) current(r *Req) *Req {
+	if r.Level == config.CODE {
+		return rg[filepath.Join(git.RepoPath(), r.ID)]
+	}
+	return rg[r.ID]
+}
+
+// Write writes the impact, the changed nodes then the requirements by ID with their titles, the
+// documents and the code files.
+func (i Impact) Write(w io.Writer) {
+	fmt.Fprintln(w, "Changed:")
+	for _, id := range i.Changed {
+		fmt.Fprintf(w, "\t%s\n", id)
+	}
+	fmt.Fprintln(w, "Requirements potentially affected:")
+	for _, r := range i.Requirements {
+		fmt.Fprintf(w, "\t%s %s\n", r.ID, r.Title)
+	}
+	fmt.Fprintln(w, "Documents:")
+	for _, doc := range i.Documents {
+		fmt.Fprintf(w, "\t%s\n", doc)
+	}
+	fmt.Fprintln(w, "Code files:")
+	for _, c := range i.Code {
+		fmt.Fprintf(w, "\t%s\n", c.ID)
+	}
+}
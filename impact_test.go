@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/stretchr/testify/assert"
+)
+
+// impactGraph returns a graph of a system requirement, two high-level requirements and their
+// low-level requirements with their code and tests.
+func impactGraph(t *testing.T, llrBody template.HTML, swl3 bool) reqGraph {
+	rg := reqGraph{}
+	add := func(r *Req) {
+		if r.Level == config.CODE {
+			r.Path = r.ID
+			rg[filepath.Join(git.RepoPath(), r.ID)] = r
+		} else {
+			r.Path = "/certdocs/" + map[config.RequirementLevel]string{config.SYSTEM: "0-TEST-100-ORD.md", config.HIGH: "0-TEST-211-SRD.md", config.LOW: "0-TEST-212-SDD.md"}[r.Level]
+			rg[r.ID] = r
+		}
+	}
+	add(&Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "Flight"})
+	add(&Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "Steering", ParentIds: []string{"REQ-0-TEST-SYS-001"}})
+	add(&Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "Braking", ParentIds: []string{"REQ-0-TEST-SYS-001"}})
+	add(&Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Steering", Body: "<p>" + llrBody + "</p>", ParentIds: []string{"REQ-0-TEST-SWH-001"}})
+	add(&Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "Steering limits", ParentIds: []string{"REQ-0-TEST-SWH-001"}})
+	add(&Req{ID: "code/a.go", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001"}})
+	add(&Req{ID: "code/a_test.go", Level: config.CODE, VerifiesIds: []string{"REQ-0-TEST-SWL-001"}})
+	add(&Req{ID: "code/b.go", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-002"}})
+	if swl3 {
+		add(&Req{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Title: "Braking", ParentIds: []string{"REQ-0-TEST-SWH-002"}})
+	}
+	assert.NoError(t, rg.Resolve())
+	return rg
+}
+
+func TestReqGraph_Impact(t *testing.T) {
+	rg := impactGraph(t, "The software shall steer.", false)
+
+	impact := rg.Impact([]*Req{rg["REQ-0-TEST-SWL-001"]})
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001"}, impact.Changed)
+	var ids []string
+	for _, r := range impact.Requirements {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-001", "REQ-0-TEST-SYS-001"}, ids)
+	assert.Equal(t, []string{"certdocs/0-TEST-100-ORD.md", "certdocs/0-TEST-211-SRD.md", "certdocs/0-TEST-212-SDD.md"}, impact.Documents)
+
+	var b bytes.Buffer
+	impact.Write(&b)
+	assert.Equal(t, `Changed:
+	REQ-0-TEST-SWL-001
+Requirements potentially affected:
+	REQ-0-TEST-SWH-001 Steering
+	REQ-0-TEST-SWL-001 Steering
+	REQ-0-TEST-SYS-001 Flight
+Documents:
+	certdocs/0-TEST-100-ORD.md
+	certdocs/0-TEST-211-SRD.md
+	certdocs/0-TEST-212-SDD.md
+Code files:
+	code/a.go
+	code/a_test.go
+`, b.String())
+}
+
+func TestReqGraph_ImpactChangedSince(t *testing.T) {
+	prg := impactGraph(t, "The software shall steer.", true)
+	rg := impactGraph(t, "The software shall steer and brake.", false)
+
+	var ids []string
+	for _, r := range rg.changedSince(prg, []string{"code/b.go", "code/removed.go"}) {
+		ids = append(ids, r.ID)
+	}
+	// SWL-003 was removed, its parent SWH-002 is affected.
+	assert.Equal(t, []string{"REQ-0-TEST-SWH-002", "REQ-0-TEST-SWL-001", "code/b.go"}, ids)
+}
@@ -0,0 +1,282 @@
+// @llr REQ-0-DDLN-SWL-043
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// lcovBranch identifies a branch of an lcov trace file, as its line, block and branch numbers.
+type lcovBranch struct {
+	Line, Block, Branch int
+}
+
+// CodeCoverage is the structural coverage of a code file, as recorded in lcov trace files, e.g.
+// by gcov and lcov --capture.
+type CodeCoverage struct {
+	// Lines are the execution counts of the instrumented lines, by line number.
+	Lines map[int]int
+	// Branches are the number of times the branches were taken, 0 if their block was never
+	// executed.
+	Branches map[lcovBranch]int
+}
+
+func newCodeCoverage() *CodeCoverage {
+	return &CodeCoverage{Lines: map[int]int{}, Branches: map[lcovBranch]int{}}
+}
+
+// merge adds the counts of o, e.g. of the trace file of another test suite.
+func (c *CodeCoverage) merge(o *CodeCoverage) {
+	for line, n := range o.Lines {
+		c.Lines[line] += n
+	}
+	for b, n := range o.Branches {
+		c.Branches[b] += n
+	}
+}
+
+// LinesFound returns the number of instrumented lines.
+func (c *CodeCoverage) LinesFound() int {
+	return len(c.Lines)
+}
+
+// LinesCovered returns the number of instrumented lines executed.
+func (c *CodeCoverage) LinesCovered() int {
+	n := 0
+	for _, count := range c.Lines {
+		if count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// BranchesFound returns the number of branches.
+func (c *CodeCoverage) BranchesFound() int {
+	return len(c.Branches)
+}
+
+// BranchesCovered returns the number of branches taken.
+func (c *CodeCoverage) BranchesCovered() int {
+	n := 0
+	for _, count := range c.Branches {
+		if count > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// within returns the coverage of the lines start to end, all the lines if end is 0.
+func (c *CodeCoverage) within(start, end int) *CodeCoverage {
+	if end == 0 {
+		return c
+	}
+	w := newCodeCoverage()
+	for line, n := range c.Lines {
+		if line >= start && line <= end {
+			w.Lines[line] = n
+		}
+	}
+	for b, n := range c.Branches {
+		if b.Line >= start && b.Line <= end {
+			w.Branches[b] = n
+		}
+	}
+	return w
+}
+
+// uncoveredLines returns the instrumented lines never executed, in order.
+func (c *CodeCoverage) uncoveredLines() []int {
+	var lines []int
+	for line, n := range c.Lines {
+		if n == 0 {
+			lines = append(lines, line)
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// parseLcov reads an lcov trace file and returns the coverage of its source files, by path as
+// recorded by the SF lines. Only the line and branch coverage records, DA and BRDA, are read.
+func parseLcov(r io.Reader) (map[string]*CodeCoverage, error) {
+	traces := map[string]*CodeCoverage{}
+	var c *CodeCoverage
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.Index(line, ":")
+		if line == "end_of_record" {
+			c = nil
+			continue
+		}
+		if i == -1 {
+			if line == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid record %q", n, line)
+		}
+		kind, fields := line[:i], strings.Split(line[i+1:], ",")
+		if kind == "SF" {
+			path := line[i+1:]
+			if traces[path] == nil {
+				traces[path] = newCodeCoverage()
+			}
+			c = traces[path]
+			continue
+		}
+		if kind != "DA" && kind != "BRDA" {
+			continue
+		}
+		if c == nil {
+			return nil, fmt.Errorf("line %d: %s record outside of a source file", n, kind)
+		}
+		invalid := fmt.Errorf("line %d: invalid record %q", n, line)
+		switch kind {
+		case "DA":
+			// The line number, the execution count and an optional checksum.
+			if len(fields) < 2 {
+				return nil, invalid
+			}
+			l, err1 := strconv.Atoi(fields[0])
+			count, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil {
+				return nil, invalid
+			}
+			c.Lines[l] += count
+		case "BRDA":
+			// The line, block and branch numbers, and the number of times the branch was taken, or
+			// - if its block was never executed.
+			if len(fields) != 4 {
+				return nil, invalid
+			}
+			var numbers [4]int
+			for j := range numbers {
+				if j == 3 && fields[j] == "-" {
+					break
+				}
+				var err error
+				if numbers[j], err = strconv.Atoi(fields[j]); err != nil {
+					return nil, invalid
+				}
+			}
+			c.Branches[lcovBranch{numbers[0], numbers[1], numbers[2]}] += numbers[3]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+// MergeLcov reads the lcov trace files, relative to the repository root if not absolute, and adds their coverage
+// to the code files of the graph. A source file of a trace file is a code file if its path is the
+// path of the code file or ends with it, relative to the repository root. The other source files,
+// e.g. the system headers, are ignored.
+func (rg reqGraph) MergeLcov(paths []string) error {
+	for _, p := range paths {
+		path := p
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(git.RepoPath(), p)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		traces, err := parseLcov(f)
+		f.Close()
+		if err != nil {
+			return withExitCode(exitParse, fmt.Errorf("Error parsing lcov trace file %s: %v", p, err))
+		}
+		for _, r := range rg {
+			if r.Level != config.CODE {
+				continue
+			}
+			for sf, c := range traces {
+				if sf == r.Path || sf == r.ID || strings.HasSuffix(sf, "/"+r.ID) {
+					if r.Coverage == nil {
+						r.Coverage = newCodeCoverage()
+					}
+					r.Coverage.merge(c)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// UncoveredCode is code traced to requirements whose lines or branches aren't all covered.
+type UncoveredCode struct {
+	Code *Req
+	// Function is the name of the function, empty for the whole file.
+	Function string
+	// Reqs are the requirements the code is traced to.
+	Reqs     []string
+	Coverage *CodeCoverage
+}
+
+func (u UncoveredCode) String() string {
+	name := u.Code.ID
+	if u.Function != "" {
+		name += ":" + u.Function
+	}
+	var lines []string
+	for _, l := range u.Coverage.uncoveredLines() {
+		lines = append(lines, strconv.Itoa(l))
+	}
+	s := fmt.Sprintf("%s: %d of %d lines, %d of %d branches covered", name,
+		u.Coverage.LinesCovered(), u.Coverage.LinesFound(), u.Coverage.BranchesCovered(), u.Coverage.BranchesFound())
+	if len(lines) > 0 {
+		s += ", lines " + strings.Join(lines, ", ") + " not executed"
+	}
+	return s + ", traced to " + strings.Join(u.Reqs, ", ")
+}
+
+// UncoveredCode returns the code traced to requirements whose structural coverage is incomplete,
+// in the order of the bottom-up report. As for UnexecutedLLRs, the code of a file is its functions referencing
+// requirements unless it references requirements outside of them. The code files without
+// coverage, of the test environments, or only verifying requirements, e.g. tests, are skipped.
+func (rg reqGraph) UncoveredCode() []UncoveredCode {
+	var uncovered []UncoveredCode
+	for _, c := range rg.CodeFilesByPosition() {
+		if c.Coverage == nil || c.TestEnv || len(c.ParentIds) == 0 {
+			continue
+		}
+		whole := len(c.Functions) == 0
+		for _, id := range c.ParentIds {
+			whole = whole || fileReferences(c, id)
+		}
+		var code []UncoveredCode
+		if whole {
+			var reqs []string
+			seen := map[string]bool{}
+			for _, id := range c.ParentIds {
+				if !seen[id] {
+					seen[id] = true
+					reqs = append(reqs, id)
+				}
+			}
+			code = append(code, UncoveredCode{c, "", reqs, c.Coverage})
+		} else {
+			for _, f := range c.Functions {
+				code = append(code, UncoveredCode{c, f.Name, f.Reqs, c.Coverage.within(f.Start, f.End)})
+			}
+		}
+		for _, u := range code {
+			if u.Coverage.LinesCovered() < u.Coverage.LinesFound() || u.Coverage.BranchesCovered() < u.Coverage.BranchesFound() {
+				uncovered = append(uncovered, u)
+			}
+		}
+	}
+	return uncovered
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const testLcov = `TN:unit
+SF:/home/build/project/src/parse.c
+FN:10,parse
+FNDA:1,parse
+DA:11,1
+DA:12,1
+DA:14,0,Xh4sm1mLYJdA6DEHiG5wJQ
+BRDA:12,0,0,1
+BRDA:12,0,1,0
+BRDA:14,1,0,-
+LF:3
+LH:2
+end_of_record
+SF:/usr/include/stdio.h
+DA:1,4
+end_of_record
+`
+
+func TestParseLcov(t *testing.T) {
+	traces, err := parseLcov(strings.NewReader(testLcov))
+	assert.NoError(t, err)
+	assert.Len(t, traces, 2)
+	c := traces["/home/build/project/src/parse.c"]
+	assert.Equal(t, map[int]int{11: 1, 12: 1, 14: 0}, c.Lines)
+	assert.Equal(t, map[lcovBranch]int{{12, 0, 0}: 1, {12, 0, 1}: 0, {14, 1, 0}: 0}, c.Branches)
+	assert.Equal(t, 2, c.LinesCovered())
+	assert.Equal(t, 3, c.LinesFound())
+	assert.Equal(t, 1, c.BranchesCovered())
+	assert.Equal(t, 3, c.BranchesFound())
+	assert.Equal(t, []int{14}, c.uncoveredLines())
+
+	_, err = parseLcov(strings.NewReader("DA:1,1\n"))
+	assert.EqualError(t, err, "line 1: DA record outside of a source file")
+	_, err = parseLcov(strings.NewReader("SF:a.c\nBRDA:1,0,x,1\n"))
+	assert.EqualError(t, err, `line 2: invalid record "BRDA:1,0,x,1"`)
+	_, err = parseLcov(strings.NewReader("SF:a.c\ngarbage\n"))
+	assert.EqualError(t, err, `line 2: invalid record "garbage"`)
+}
+
+func TestReqGraph_MergeLcov(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lcov")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	unit, integration := filepath.Join(dir, "unit.info"), filepath.Join(dir, "integration.info")
+	assert.NoError(t, ioutil.WriteFile(unit, []byte(testLcov), 0644))
+	assert.NoError(t, ioutil.WriteFile(integration, []byte("SF:src/parse.c\nDA:14,2\nBRDA:12,0,1,1\nend_of_record\n"), 0644))
+
+	parse := &Req{ID: "src/parse.c", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001"}}
+	other := &Req{ID: "src/other.c", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001"}}
+	rg := reqGraph{parse.ID: parse, other.ID: other}
+	assert.NoError(t, rg.MergeLcov([]string{unit}))
+	assert.Nil(t, other.Coverage)
+	var summaries []string
+	for _, u := range rg.UncoveredCode() {
+		summaries = append(summaries, u.String())
+	}
+	assert.Equal(t, []string{
+		"src/parse.c: 2 of 3 lines, 1 of 3 branches covered, lines 14 not executed, traced to REQ-0-TEST-SWL-001",
+	}, summaries)
+
+	assert.NoError(t, rg.MergeLcov([]string{integration}))
+	assert.Equal(t, 3, parse.Coverage.LinesCovered())
+	assert.Equal(t, 2, parse.Coverage.BranchesCovered())
+
+	err = rg.MergeLcov([]string{filepath.Join(dir, "missing.info")})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReqGraph_UncoveredCode_functions(t *testing.T) {
+	traces, err := parseLcov(strings.NewReader(testLcov))
+	assert.NoError(t, err)
+	parse := &Req{ID: "src/parse.c", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002"},
+		Functions: []CodeFunction{
+			{Name: "parse", Start: 10, End: 12, Reqs: []string{"REQ-0-TEST-SWL-001"}},
+			{Name: "resolve", Start: 13, End: 15, Reqs: []string{"REQ-0-TEST-SWL-002"}},
+		},
+		Coverage: traces["/home/build/project/src/parse.c"]}
+	test := &Req{ID: "src/parse_test.c", Level: config.CODE, VerifiesIds: []string{"REQ-0-TEST-SWL-001"}, Coverage: newCodeCoverage()}
+	test.Coverage.Lines[1] = 0
+	rg := reqGraph{parse.ID: parse, test.ID: test}
+	var summaries []string
+	for _, u := range rg.UncoveredCode() {
+		summaries = append(summaries, u.String())
+	}
+	// The branch not taken of parse is reported, and the uncovered line of resolve.
+	assert.Equal(t, []string{
+		"src/parse.c:parse: 2 of 2 lines, 1 of 2 branches covered, traced to REQ-0-TEST-SWL-001",
+		"src/parse.c:resolve: 0 of 1 lines, 0 of 1 branches covered, lines 14 not executed, traced to REQ-0-TEST-SWL-002",
+	}, summaries)
+}
@@ -0,0 +1,254 @@
+// @llr REQ-0-DDLN-SWL-033
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// LinksConf configures the checklinks command, the "links" entry in attributes.json.
+type LinksConf struct {
+	// Timeout of each request, in seconds.
+	Timeout float64 `json:"timeout"`
+	// Interval between two requests to the same host, in seconds.
+	Interval float64 `json:"interval"`
+	// MaxAge is how long the result of checking a link is reused, in hours.
+	MaxAge float64 `json:"max_age"`
+	// Ignore are regular expressions matching the URLs which are not checked, e.g. those of an
+	// intranet or requiring to log in.
+	Ignore []string `json:"ignore"`
+}
+
+// defaultLinksConf is used when attributes.json doesn't configure the link checks.
+var defaultLinksConf = LinksConf{Timeout: 10, Interval: 1, MaxAge: 24}
+
+// linksConf returns the link checks configuration, or the default one if there's none.
+func (c JsonConf) linksConf() LinksConf {
+	if c.Links == nil {
+		return defaultLinksConf
+	}
+	return *c.Links
+}
+
+var reURL = regexp.MustCompile(`https?://[^\s<>"'()\[\]{}]+`)
+
+// links returns the URLs found in the bodies and the attributes of the requirements, except those
+// ignored, with the IDs of the requirements referencing them, sorted.
+func (rg reqGraph) links(ignore []*regexp.Regexp) map[string][]string {
+	links := map[string][]string{}
+	for _, r := range rg {
+		if r.IsDeleted() {
+			continue
+		}
+		texts := []string{string(r.LoadBody())}
+		for _, v := range r.Attributes {
+			texts = append(texts, v)
+		}
+		seen := map[string]bool{}
+		for _, text := range texts {
+			for _, u := range reURL.FindAllString(text, -1) {
+				u = strings.TrimRight(u, ".,;:!?")
+				if seen[u] || isIgnored(u, ignore) {
+					continue
+				}
+				seen[u] = true
+				links[u] = append(links[u], r.ID)
+			}
+		}
+	}
+	for _, ids := range links {
+		sort.Strings(ids)
+	}
+	return links
+}
+
+func isIgnored(u string, ignore []*regexp.Regexp) bool {
+	for _, re := range ignore {
+		if re.MatchString(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkResult is the result of checking a link.
+type linkResult struct {
+	Checked time.Time `json:"checked"`
+	Status  int       `json:"status"`
+	Error   string    `json:"error"`
+}
+
+func (r linkResult) isDead() bool {
+	return r.Error != "" || r.Status >= 400
+}
+
+func (r linkResult) String() string {
+	if r.Error != "" {
+		return r.Error
+	}
+	return fmt.Sprintf("HTTP %d %s", r.Status, http.StatusText(r.Status))
+}
+
+// linkCache holds the results of the previous checks, by URL.
+type linkCache map[string]linkResult
+
+// linkCachePath returns the path of the file holding the link cache of the repository, next to
+// the parse cache.
+func linkCachePath() (string, error) {
+	dir, err := git.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reqtraq", "links.json"), nil
+}
+
+// loadLinkCache reads the link cache from the given file, empty if it doesn't exist.
+func loadLinkCache(path string) (linkCache, error) {
+	c := linkCache{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// save writes the link cache to the given file.
+func (c linkCache) save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// linkChecker checks links, reusing the recent results of the cache and waiting between the
+// requests to the same host.
+type linkChecker struct {
+	client   *http.Client
+	interval time.Duration
+	maxAge   time.Duration
+	cache    linkCache
+	// last is when the last request to each host was sent.
+	last  map[string]time.Time
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func newLinkChecker(conf LinksConf, cache linkCache) *linkChecker {
+	return &linkChecker{
+		client:   &http.Client{Timeout: time.Duration(conf.Timeout * float64(time.Second))},
+		interval: time.Duration(conf.Interval * float64(time.Second)),
+		maxAge:   time.Duration(conf.MaxAge * float64(time.Hour)),
+		cache:    cache,
+		last:     map[string]time.Time{},
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// check returns the result of checking the link, from the cache if it was alive recently enough,
+// as dead links are checked again in case they failed temporarily. The link is requested with
+// HEAD, then with GET if it fails, as some servers don't implement HEAD.
+func (c *linkChecker) check(link string) linkResult {
+	if r, ok := c.cache[link]; ok && !r.isDead() && c.now().Sub(r.Checked) < c.maxAge {
+		return r
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return linkResult{Checked: c.now(), Error: err.Error()}
+	}
+	r := c.request("HEAD", link, u.Host)
+	if r.isDead() {
+		r = c.request("GET", link, u.Host)
+	}
+	c.cache[link] = r
+	return r
+}
+
+func (c *linkChecker) request(method, link, host string) linkResult {
+	if last, ok := c.last[host]; ok {
+		if wait := c.interval - c.now().Sub(last); wait > 0 {
+			c.sleep(wait)
+		}
+	}
+	c.last[host] = c.now()
+	req, err := http.NewRequest(method, link, nil)
+	if err != nil {
+		return linkResult{Checked: c.now(), Error: err.Error()}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return linkResult{Checked: c.now(), Error: err.Error()}
+	}
+	resp.Body.Close()
+	return linkResult{Checked: c.now(), Status: resp.StatusCode}
+}
+
+// DeadLink is a link which couldn't be resolved, with the requirements referencing it.
+type DeadLink struct {
+	URL    string
+	Reqs   []string
+	Result linkResult
+}
+
+func (d DeadLink) String() string {
+	return fmt.Sprintf("%s: %s, referenced by %s", d.URL, d.Result, strings.Join(d.Reqs, ", "))
+}
+
+// CheckLinks resolves the links of the requirements of the graph and returns the dead ones,
+// sorted by URL. The results are cached in the file at cachePath.
+func (rg reqGraph) CheckLinks(conf LinksConf, cachePath string) ([]DeadLink, error) {
+	var ignore []*regexp.Regexp
+	for _, i := range conf.Ignore {
+		re, err := regexp.Compile(i)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ignored link %q: %v", i, err)
+		}
+		ignore = append(ignore, re)
+	}
+	cache, err := loadLinkCache(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	links := rg.links(ignore)
+	var sorted []string
+	for u := range links {
+		sorted = append(sorted, u)
+	}
+	sort.Strings(sorted)
+
+	checker := newLinkChecker(conf, cache)
+	var dead []DeadLink
+	for _, u := range sorted {
+		if r := checker.check(u); r.isDead() {
+			dead = append(dead, DeadLink{u, links[u], r})
+		}
+	}
+	// The links not referenced anymore are forgotten.
+	for u := range cache {
+		if _, ok := links[u]; !ok {
+			delete(cache, u)
+		}
+	}
+	return dead, cache.save(cachePath)
+}
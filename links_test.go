@@ -0,0 +1,91 @@
+package main
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_links(t *testing.T) {
+	rg := reqGraph{
+		"REQ-0-TEST-SYS-001": {ID: "REQ-0-TEST-SYS-001", Body: `See <a href="https://example.com/a">A</a> and https://example.com/b.`,
+			Attributes: map[string]string{"RATIONALE": "(https://example.com/a), http://intranet/c"}},
+		"REQ-0-TEST-SYS-002": {ID: "REQ-0-TEST-SYS-002", Body: "As https://example.com/a, says."},
+		"REQ-0-TEST-SYS-003": {ID: "REQ-0-TEST-SYS-003", Title: "DELETED", Body: "https://example.com/d"},
+	}
+	assert.Equal(t, map[string][]string{
+		"https://example.com/a": {"REQ-0-TEST-SYS-001", "REQ-0-TEST-SYS-002"},
+		"https://example.com/b": {"REQ-0-TEST-SYS-001"},
+	}, rg.links([]*regexp.Regexp{regexp.MustCompile(`^http://intranet`)}))
+}
+
+func TestReqGraph_CheckLinks(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/get-only" && r.Method == "HEAD":
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "links")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "reqtraq", "links.json")
+
+	rg := reqGraph{"REQ-0-TEST-SYS-001": {ID: "REQ-0-TEST-SYS-001",
+		Body: template.HTML("See " + server.URL + "/ok, " + server.URL + "/get-only and " + server.URL + "/missing.")}}
+	conf := LinksConf{Timeout: 5, MaxAge: 1}
+	dead, err := rg.CheckLinks(conf, cachePath)
+	assert.NoError(t, err)
+	if assert.Len(t, dead, 1) {
+		assert.Equal(t, server.URL+"/missing: HTTP 404 Not Found, referenced by REQ-0-TEST-SYS-001", dead[0].String())
+	}
+	assert.Equal(t, []string{"HEAD /get-only", "GET /get-only", "HEAD /missing", "GET /missing", "HEAD /ok"}, requests)
+
+	// The links found alive are not requested again while cached.
+	requests = nil
+	dead, err = rg.CheckLinks(conf, cachePath)
+	assert.NoError(t, err)
+	assert.Len(t, dead, 1)
+	assert.Equal(t, []string{"HEAD /missing", "GET /missing"}, requests)
+
+	cache, err := loadLinkCache(cachePath)
+	assert.NoError(t, err)
+	assert.Len(t, cache, 3)
+	_, err = rg.CheckLinks(LinksConf{Ignore: []string{"("}}, cachePath)
+	assert.EqualError(t, err, "Invalid ignored link \"(\": error parsing regexp: missing closing ): `(`")
+}
+
+func TestLinkChecker_interval(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	var slept []time.Duration
+	c := newLinkChecker(LinksConf{Timeout: 5, Interval: 2}, linkCache{})
+	c.now = func() time.Time { return now }
+	c.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+	assert.False(t, c.check(server.URL+"/a").isDead())
+	now = now.Add(500 * time.Millisecond)
+	assert.False(t, c.check(server.URL+"/b").isDead())
+	assert.False(t, c.check(other.URL+"/c").isDead())
+	assert.Equal(t, []time.Duration{1500 * time.Millisecond}, slept)
+}
@@ -0,0 +1,95 @@
+// @llr REQ-0-DDLN-SWL-042
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// writingCommands are the commands writing to the certification documents, to the tags or to the
+// state of reqtraq kept in the git directory, e.g. the parse cache, with their subcommand if any,
+// e.g. "baseline create". They are refused in read-only mode.
+var writingCommands = map[string]bool{
+	"approvals":       true,
+	"baseline create": true,
+	"checklinks":      true,
+	"confluence":      true,
+	"fix":             true,
+	"fmt":             true,
+	"prepush":         true,
+	"quickcheck":      true,
+	"updatetasks":     true,
+}
+
+// repoLock is an advisory lock of the repository, held shared by the processes reading the
+// certification documents and the code, and exclusively by the ones writing to them, so that e.g.
+// the web server doesn't build a graph from a document being formatted.
+type repoLock struct {
+	f *os.File
+}
+
+// lockPath returns the path of the lock file of the repository, next to the parse cache.
+func lockPath() (string, error) {
+	dir, err := git.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reqtraq", "lock"), nil
+}
+
+// lockRepo locks the repository, waiting for the processes holding the lock if needed. In
+// read-only mode the lock file isn't created, see lockFile.
+func lockRepo(exclusive bool) (*repoLock, error) {
+	path, err := lockPath()
+	if err != nil {
+		return nil, err
+	}
+	return lockFile(path, exclusive, !*fReadOnly)
+}
+
+// lockFile locks the file at path, creating it if create is set. Otherwise a shared lock only
+// needs the file to be readable, e.g. for a web server running as a user who can't write to the
+// repository, and nothing is locked if the file doesn't exist, no process having written to the
+// repository yet.
+func lockFile(path string, exclusive, create bool) (*repoLock, error) {
+	how, flags := syscall.LOCK_SH, os.O_RDONLY
+	if exclusive {
+		how, flags = syscall.LOCK_EX, os.O_RDWR
+	}
+	if create {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		flags |= os.O_CREATE
+	}
+	f, err := os.OpenFile(path, flags, 0666)
+	if os.IsNotExist(err) && !create {
+		return &repoLock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		log.Printf("Waiting for the other reqtraq processes locking %s", path)
+		err = syscall.Flock(int(f.Fd()), how)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Error locking %s: %v", path, err)
+	}
+	return &repoLock{f}, nil
+}
+
+// Unlock releases the lock.
+func (l *repoLock) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reqtraq", "lock")
+
+	// Without the lock file, nothing is locked in read-only mode.
+	l, err := lockFile(path, false, false)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Unlock())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	r1, err := lockFile(path, false, true)
+	assert.NoError(t, err)
+	r2, err := lockFile(path, false, false)
+	assert.NoError(t, err)
+
+	locked := make(chan *repoLock)
+	go func() {
+		w, err := lockFile(path, true, true)
+		assert.NoError(t, err)
+		locked <- w
+	}()
+	select {
+	case <-locked:
+		t.Fatal("exclusive lock acquired while shared locks are held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, r1.Unlock())
+	assert.NoError(t, r2.Unlock())
+	w := <-locked
+
+	go func() {
+		r, err := lockFile(path, false, false)
+		assert.NoError(t, err)
+		locked <- r
+	}()
+	select {
+	case <-locked:
+		t.Fatal("shared lock acquired while the exclusive lock is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.NoError(t, w.Unlock())
+	assert.NoError(t, (<-locked).Unlock())
+}
@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -15,6 +16,10 @@ import (
 	"github.com/daedaleanai/reqtraq/git"
 )
 
+func init() {
+	RegisterDocParser(RawDocParserFunc(func(f string) ([]string, error) { return ParseLyx(f, ioutil.Discard) }), ".lyx")
+}
+
 var (
 	reCertdoc = regexp.MustCompile(`(\d+)-(\w+)-(\d+)-(\w+)`) // project number, project abbreviation, certdoc type number, certdoc type
 	reStart   = regexp.MustCompile(`(?i)^\s*req:\s*$`)        // 'req:' standalone on a line
@@ -9,14 +9,19 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/daedaleanai/reqtraq/git"
 	"github.com/daedaleanai/reqtraq/linepipes"
+	"github.com/daedaleanai/reqtraq/taskmgr"
 )
 
 var (
@@ -26,11 +31,32 @@ var (
 	fReportBodyFilterString  = flag.String("body_filter", "", "regular expression to filter by requirement body.")
 	fReportJsonConfPath      = flag.String("attributes", git.RepoPath()+"/certdocs/attributes.json", "path to json with requirement attribute specification.")
 	addr                     = flag.String("addr", ":8080", "The ip:port where to serve.")
-	since                    = flag.String("since", "", "The commit representing the start of the range.")
+	since                    = flag.String("since", "", "The commit representing the start of the range, or the snapshot the delta export is relative to.")
 	at                       = flag.String("at", "", "The commit representing the end of the range.")
 	fCertdocPath             = flag.String("certdoc_path", "certdocs", "Location of certification documents within the *root* of the current repository.")
 	fCodePath                = flag.String("code_path", "", "Location of code files within the current repository")
 	fVerbose                 = flag.Bool("v", false, "Enable verbose logs.")
+	fExportFormat            = flag.String("format", "", "The export format.")
+	fForce                   = flag.Bool("force", false, "Overwrite the task titles and descriptions edited in the task manager.")
+	fDryRun                  = flag.Bool("dry-run", false, "For updatetasks and prepush, report the changes of the tasks rather than making them.")
+	fQueue                   = flag.Bool("queue", false, "For updatetasks and prepush, plan the changes of the tasks in a journal before making them, so the sync can be resumed.")
+	fResume                  = flag.Bool("resume", false, "For updatetasks, resume the queued sync of the tasks interrupted, rather than syncing them.")
+	fTaskProgress            = flag.Bool("task-progress", false, "For the report<type> commands, show the status, the assignee and the comments of the tasks, read back from the task manager.")
+	fAudience                = flag.String("audience", "", "The audience of the top-down report, e.g. engineer, manager or customer.")
+	fReadOnly                = flag.Bool("readonly", false, "Never write to the repository, refusing the commands which do, e.g. for a web server sharing a checkout.")
+	fStrict                  = flag.Bool("strict", false, "For gaps, also report the exported functions without @llr annotation of the files referencing requirements.")
+	fPDF                     = flag.Bool("pdf", false, "Also convert the HTML reports to PDF, next to them, with wkhtmltopdf.")
+	fInteractive             = flag.Bool("interactive", false, "Ask for each fix whether to apply it.")
+	fRoot                    = flag.String("root", "", "For the plantuml export, the requirement whose hierarchy is exported.")
+	fDocument                = flag.String("document", "", "For the plantuml export, the certification document whose requirements are exported, e.g. 0-DDLN-212-SDD.")
+	fDoc                     = flag.String("doc", "", "For the report<type> commands, export and updatetasks, the certification document the requirements are scoped to, e.g. 0-DDLN-212-SDD.")
+	fUnder                   = flag.String("under", "", "For the report<type> commands, export and updatetasks, the requirement whose subtree the requirements are scoped to.")
+	fTypes                   = flag.String("types", "", "For updatetasks, the comma-separated types of the requirements whose tasks are synced, e.g. SWH,HWH.")
+	fSplit                   = flag.Bool("split", false, "For reportdown, write the report as an index and a page per document in a directory.")
+	fPageSize                = flag.Int("page_size", 1000, "For reportdown --split, the maximum number of requirements per page, 0 for no limit.")
+	fMainBranch              = flag.String("main_branch", "", "For precommit, the branch the parents missing in the current one are looked up in, e.g. origin/master.")
+	fLowMemory               = flag.Bool("low-memory", false, "Keep the bodies of the requirements in a temporary file rather than in memory, for the largest repositories.")
+	fExitZeroOnFindings      = flag.Bool("exit-zero-on-findings", false, "Exit with 0 when only issues were found in the requirements, e.g. to publish the reports in CI before failing.")
 )
 
 const usage = `
@@ -45,20 +71,62 @@ usually in a git repo.  The certification documents are scanned for requirements
 and the source code for references to them.
 
 command is one of:
+	approvals	reads back the approval status of the requirements from the task manager into their attributes, e.g. from Polarion
+	archive		packages the graph, the reports and the certification records in an archive, e.g. at project closure
+	baseline	creates a requirements baseline, e.g. at a certification milestone, or compares two baselines, e.g. for the change control board
+	badges		writes SVG badges of the traceability health, e.g. for the dashboards and the repository landing page
+	checklinks	checks the URLs in the requirement bodies and attributes and lists the dead links
+	confluence	imports the certification documents of a Confluence space
+	coverage	lists the requirements which are not both implemented and verified
+	dangling	lists the requirements not reachable from a system requirement, and the low-level ones without code
+	diff		lists the requirements added, deleted and modified between two git refs, with the changes of their fields
+	export		writes the requirements in the format of another requirements tool, e.g. IBM DOORS
+	fix		fixes the issues which need no decision, e.g. the references to renamed requirements, one by one
+	fmt		rewrites a .toml certification document in its canonical form
+	graph		writes a Mermaid flowchart of the ancestors and the descendants of a requirement
+	gaps		lists the exported Go functions in files not referencing any low-level requirement
+	gocover		lists the low-level requirements whose Go code is never executed by the tests of a coverage profile
+	grep		searches the requirements and the code annotations referencing them for a pattern
 	help		prints this help message
+	impact		lists the requirements, documents and code files potentially affected by a change, to scope re-verification
+	lcov		lists the code traced to requirements whose lines or branches are not covered according to lcov trace files
 	linkify		changes the lyx content by adding named destinations and links to parent requirements
 	list    	parses and lists the requirements found in certification documents
+	metrics		writes the numbers of requirements by status, referenced by code and verified, per document and type
 	nextid		generates the next requirement id for the given document
+	notify		posts the lifecycle events of the requirements since a snapshot to the quality management system
 	precommit	runs the precommit checks for the requirement documents in the current repository
 	prepush		runs the prepush checks for the requirement documents in the current repository
+	quickcheck	runs the precommit checks only on the files changed since the last run, e.g. on save
+	report		creates a self-contained HTML traceability report of the whole graph, e.g. for a certification data package
+	reportattributes	creates an HTML report of the distributions of the attribute values, and of the requirements missing required attributes
 	reportdown 	creates an HTML traceability report from system requirements down to code
+	reportcode	creates an HTML report of the requirements each code file traces to, and whether it changed since HEAD
+	reportderived	creates an HTML report of the derived requirements with the records justifying them
+	reportdeleted	creates an HTML report of the deleted requirements, when and by whom, and what still references them
+	reporthistory	creates an HTML report of the changes of the safety classifications, and of their downgrades
 	reportissues	creates an HTML report with all issues found in the requirement documents
+	reportrisk	creates an HTML report ranking the requirements by risk
 	reportup 	creates an HTML traceability report from code, to LLRs, to HLRs and to system requirements
-	updatetasks	updates the tasks associated with the given requirements (requires a Phabricator/JIRA/Bugzilla instance)
+	reportverification	creates an HTML report of the tests and test cases verifying each requirement, with their results
+	suggest		suggests the likely parents of a requirement, or the likely requirements of a code file
+	updatetasks	updates the tasks associated with the given requirements (requires a Phabricator, Jira, GitHub, GitLab, Azure DevOps or Polarion instance)
 	web		starts a local web server to facilitate interaction with reqtraq
 
 
 
+The exit code tells the kind of failure:
+	0	success
+	1	internal error, e.g. a report could not be written
+	2	invalid command line or configuration
+	3	a certification document, code file or other input could not be parsed
+	4	issues found in the requirements, e.g. invalid references or coverage gaps (0 with --exit-zero-on-findings)
+	5	git, the task manager or Confluence failed
+
+With --low-memory the bodies of the requirements are kept in a temporary file rather than in memory, read
+again when needed, e.g. to run on constrained CI runners against the largest repositories. The reports are
+written to their files as they are generated in any case.
+
 Invoking reqtraq without arguments prints a short help message.
 Run
 	reqtraq help <command>
@@ -71,10 +139,451 @@ Parameters:
 	<output_lyx_filename>	linkified Lyx file
 `
 
+const approvalsUsage = `Reads back the approval status of the requirements from the task manager keeping the approvals, Polarion,
+into an attribute of the requirements, asking for each requirement whether to set it. Usage:
+	reqtraq approvals --interactive --attributes=<path_to_attributes_json> --certdoc_path=<path>
+Parameters:
+	--interactive: ask for each requirement whether to set the attribute, otherwise the changes are only listed.
+	--attributes: path to json with the Polarion project, see reqtraq help updatetasks
+	--certdoc_path: location of certification documents within the current repository
+
+The approval status of a work item is disapproved if any approver disapproved it, waiting if any approval is still
+expected and approved once all the approvers approved it. It is set in the "Approval" attribute of the requirement,
+or the "approvalAttribute" of the "polarion" entry of the attributes json, in the .md and .toml certification
+documents. The requirements whose work item has no approvers, or has the status of the attribute already, are left
+unchanged. As with reqtraq fix, each change shows the lines changed, and is applied once answered y, skipped if
+answered n. The changes left are skipped once answered q.
+`
+
+const archiveUsage = `Packages the requirements and their evidence in a single archive, for the long-term retention of the
+certification records. Usage:
+	reqtraq archive <output_filename> --attributes=<path_to_attributes_json> --certdoc_path=<path> --code_path=<path>
+Parameters:
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	<output_filename>	archive to be written, a .zip, .tar, .tar.gz or .tgz file
+
+The archive holds a snapshot of the requirement graph as graph.json, the down, up, issues and risk reports
+under reports/, and the files and directories listed in the "archive" entry of the attributes json under files/,
+e.g. the baselines, the review records and the waivers:
+	"archive": {
+		"include": ["certdocs", "reviews", "waivers"]
+	}
+Its first file, manifest.json, gives the commit archived and the size and SHA-256 of every other file.
+With a webhook configured, the baselined event is posted with the name of the archive, see reqtraq help notify.
+`
+
+const baselineUsage = `Creates a requirements baseline, formally identifying the requirements of a certification milestone,
+or compares two baselines. Usage:
+	reqtraq baseline create <name> [<output_filename>] --attributes=<path_to_attributes_json> --certdoc_path=<path> --code_path=<path>
+	reqtraq baseline compare <from> <to>
+Parameters:
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	<name>	name of the baseline, letters, digits, dots, dashes and underscores, e.g. SOI-2
+	<output_filename>	artifact of the baseline to be written, <name>.baseline.json by default
+	<from>, <to>	artifacts of the baselines compared, or the names of the baselines whose <name>.baseline.json
+		artifacts are compared
+
+The head commit is tagged baseline/<name>, and the artifact gives the name, the tag, the commit, the creation time,
+the size and SHA-256 of the certification documents and the code files of the graph, and the snapshot of the graph
+as in the archives, see reqtraq help export. The message of the tag gives the SHA-256 of the artifact, so the
+artifact kept in the certification records can be checked against the tag:
+	$ git tag -n3 baseline/SOI-2
+The working tree must be clean, so the artifact describes the commit tagged, and the tag must not exist. The
+artifacts aren't meant to be committed, the tag identifies the baseline in the repository: the files ending with
+.baseline.json and the artifact written are ignored by the check, and are kept in the certification records.
+With a webhook configured, the baselined event is posted with the name of the baseline, see reqtraq help notify.
+
+The comparison lists the changes of the baseline <to> since the baseline <from>, for the change control board of a
+release: the requirements new, deleted, marked DELETED or removed, and modified, with the changes of their fields as
+in reqtraq diff, the requirements re-parented, with their old and new parents, and the code files and the
+certification documents added, removed or changed, as identified by their SHA-256:
+	$ reqtraq baseline compare SOI-2 SOI-3
+	Baseline SOI-3 (commit 8154e06...) compared with baseline SOI-2 (commit 2884061...)
+
+	Re-parented requirements (1)
+		REQ-0-DDLN-SWL-012 Filtering
+			parents: "REQ-0-DDLN-SWH-003" -> "REQ-0-DDLN-SWH-003, REQ-0-DDLN-SWH-004"
+	...
+`
+
+const badgesUsage = `Writes SVG badges of the traceability health, to be embedded in the dashboards and the landing page
+of the repository. Usage:
+	reqtraq badges <output_dir> --attributes=<path_to_attributes_json> --certdoc_path=<path> --code_path=<path>
+Parameters:
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	<output_dir>	directory the badges are written to
+
+The badges are:
+	llr-coverage.svg	the share of the low-level requirements implemented by code
+	llr-verification.svg	the share of the low-level requirements verified by tests or test cases
+	validation.svg	passing if the requirements have no issues, failing otherwise
+The shares are green from 90% and yellow from 75%. The web server serves them too, e.g. /badge/llr-coverage.svg.
+`
+
+const confluenceUsage = `Imports the certification documents of a Confluence space. Usage:
+	reqtraq confluence --attributes=<path_to_attributes_json> --certdoc_path=<path>
+Parameters:
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+
+The server and the space are configured in the "confluence" entry of the attributes json:
+	"confluence": {
+		"url": "https://example.atlassian.net/wiki",
+		"space": "DDLN",
+		"dir": "confluence"
+	}
+Each page whose title starts with a certification document name, e.g. "0-DDLN-212-SDD Software Design",
+is written as an .html certification document in "dir" (relative to the certification documents, by default
+"confluence"), unless it was already imported from the same page version. The requirements are then checked
+like those of any other certification document.
+
+The credentials, if needed, are read from the git config:
+	git config --local --replace-all daedalean.confluence-user <USER>
+	git config --local --replace-all daedalean.confluence-token <API_TOKEN>
+`
+
+const exportUsage = `Writes the requirements in the format of another requirements tool. Usage:
+	reqtraq export <output_filename> --format=<format> --doc=<name> --under=<id> --certdoc_path=<path> --code_path=<path>
+	reqtraq export <output_filename> --format=delta --since=<snapshot> --certdoc_path=<path> --code_path=<path>
+	reqtraq export <output_filename> --format=plantuml --root=<id> --document=<name> --certdoc_path=<path>
+Parameters:
+	--format: the format of the output, one of:
+		cypher	Cypher statements loading the graph into Neo4j, see below
+		doors	CSV for the IBM DOORS import
+		dot	GraphViz DOT of the requirements, the code files and the links between them, see below
+		json	json of the requirements, the code files and the links between them, see below
+		snapshot	json of the requirements and the code files with all their fields, as graph.json in the archives
+		delta	json of the changes of the graph since the snapshot given with --since, see below
+		trace	CSV of the traceability matrix from the system requirements down to the code, see below
+		xlsx	Excel workbook of the traceability matrix, a sheet per level, see below
+		plantuml	PlantUML diagram of the hierarchy of the requirements, see below
+		srs	HTML of the Software Requirements Data of a certification submission, see below
+		sdd	HTML of the Software Design Description
+		svcp	HTML of the Software Verification Cases and Procedures
+		<name>	CSV of a matrix, or HTML of a data item, defined in attributes.json, see below
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--since: for the delta format, the snapshot file the changes are relative to
+	--root: for the plantuml format, optional, the requirement whose hierarchy is exported
+	--document: for the plantuml format, optional, the certification document whose requirements are exported
+	--doc: optional, the certification document the export is scoped to, with the code files referencing its
+		requirements, except for the delta format
+	--under: optional, the requirement whose subtree the export is scoped to, with the code files referencing it
+	<output_filename>	file to be written
+
+The delta format lets the downstream tools, e.g. dashboards or DOORS sync jobs, update their copy of the graph
+incrementally. It lists the requirements and code files added, with all their fields, the fields changed of the
+others with their new values, null if removed, the IDs of those removed, and the links from parents to children added
+and removed:
+	{"added": [...], "changed": [{"id": "REQ-0-DDLN-SWL-001", "fields": {"title": "..."}}], "removed": [...],
+	 "addedLinks": [{"parent": "REQ-0-DDLN-SWH-001", "child": "REQ-0-DDLN-SWL-001"}], "removedLinks": [...]}
+Exporting the snapshot format at the same time gives the snapshot of the next delta.
+
+The json format has a stable schema, for the downstream tools consuming the graph, e.g. dashboards or custom checks.
+Fields are only added in the same version, so the unknown ones are to be ignored:
+	{"version": 1,
+	 "nodes": [{"id": "REQ-0-DDLN-SWL-001", "type": "SWL", "path": "certdocs/0-DDLN-212-SDD.md", "section": "3.1",
+	            "title": "...", "body": "<p>...</p>", "rationale": "...", "acceptanceCriteria": "...", "notes": "...",
+	            "attributes": {"Verification": "Unit test"}, "status": "COMPLETED"},
+	           {"id": "parsing.go", "type": "CODE", "path": "/repo/parsing.go", "status": "COMPLETED",
+	            "hash": "sha1:..."}],
+	 "edges": [{"from": "REQ-0-DDLN-SWL-001", "to": "REQ-0-DDLN-SWH-001", "kind": "parent"},
+	           {"from": "parsing.go", "to": "REQ-0-DDLN-SWL-001", "kind": "implements"}]}
+The type is SYS, SWH, SWL, HWH, HWL or CODE, the status NOT STARTED, STARTED or COMPLETED, and the bodies are HTML.
+The edges go from the requirements to their parents, and from the code files to the requirements they implement,
+partially implement or verify, of kind parent, implements, partially-implements or verifies. The empty fields are
+omitted, e.g. deleted and testEnv, set for the deleted requirements and for the code of the test environments.
+
+The doors format has one row per requirement, with the certification document as module, the position of the
+requirement in its document as absolute number, the ID as object identifier, the title as object heading, the body
+as object text, one column per attribute, the parents and children as in-links and out-links, given as
+<module>/<absolute number>, and the code files implementing the requirement. Deleted requirements are not exported.
+
+The cypher format loads the graph into Neo4j, e.g. with "cypher-shell -f graph.cypher", for the graph queries, e.g.
+the shortest paths from the system requirements to the code or the centrality of the requirements. The nodes and the
+edges are those of the json format: the requirements are labelled Requirement and their type, e.g. SWL, the code
+files Code, with the fields as properties and the attributes as properties of their name, and the edges are
+relationships of their kind in upper case, e.g. PARENT or PARTIALLY_IMPLEMENTS:
+	MERGE (n:Requirement {id: "REQ-0-DDLN-SWL-001"}) SET n:SWL SET n += {path: "...", title: "...", status: "COMPLETED"};
+	MATCH (a:Code {id: "parsing.go"}), (b:Requirement {id: "REQ-0-DDLN-SWL-001"}) MERGE (a)-[:IMPLEMENTS]->(b);
+The statements merge on the IDs, so loading the graph again updates it.
+
+The dot format draws the graph for GraphViz, e.g. "dot -Tsvg graph.dot -o graph.svg", to inspect it or to embed it
+in design documents: a node per requirement and code file, labelled with the ID and the title, shaped by level, a 3D
+box for the system requirements, a rounded box for the high-level ones, a box for the low-level ones and a note for
+the code, and filled by status, NOT STARTED red, STARTED yellow and COMPLETED green. The edges go from the
+requirements to their parents and from the code files to the requirements they implement, dotted to those they
+partially implement and dashed to those they verify. Deleted requirements are not exported.
+
+The plantuml format draws the hierarchy of the requirements for PlantUML, e.g. "plantuml -tsvg hierarchy.puml", to be
+rendered with the other architecture diagrams: a rectangle per requirement, with the ID, the title, the type as
+stereotype and the color of the status as in the dot format, in a package per certification document, and an arrow
+from each requirement to its children. With --root, only the requirement and its descendants are exported, and with
+--document, e.g. 0-DDLN-212-SDD, only the requirements of the document, with the links between them. Code files and
+deleted requirements are not exported.
+
+The trace format has a row per chain of links from a requirement without parents, usually a system requirement,
+down to a code file or to a requirement without children, with the ID and the status of the system, high-level and
+low-level requirements of the chain and the path and the hash of the code file, e.g. for the reviews in spreadsheets:
+	System Requirement,Status,High-Level Requirement,Status,Low-Level Requirement,Status,Code,Hash
+	REQ-0-DDLN-SYS-001,COMPLETED,REQ-0-DDLN-SWH-001,COMPLETED,REQ-0-DDLN-SWL-001,COMPLETED,parsing.go,sha1:5e2b...
+Deleted requirements are not exported.
+
+The xlsx format has the traceability matrix as an Excel workbook, with a sheet per transition between two levels:
+SYS-SWH, SWH-SWL and SWL-Code, and SYS-HWH, HWH-HWL and HWL-Code when there are hardware requirements. Each sheet has
+a row per link, with the ID, the title and the status of the parent and of the child, or the path and the hash of the
+code file. The parents without children and the children without parents have a row too, with the other side empty.
+The header row is frozen and the statuses colored, NOT STARTED red, STARTED yellow and COMPLETED green.
+Deleted requirements are not exported.
+
+The matrices, e.g. the ones promised by the verification plan, are defined in the "matrices" entry of attributes.json:
+	"matrices": [{
+		"name": "hlr-tests",
+		"rows": "SWH",
+		"columns": [
+			{"header": "Requirement", "field": "id"},
+			{"header": "Verification", "field": "attribute:Verification"},
+			{"header": "Code", "links": ["children", "children"], "type": "CODE", "field": "path"},
+			{"header": "Changes", "links": ["children"], "type": "SWL", "field": "changelists"}
+		]
+	}]
+Each matrix has a row per requirement of the rows type (SYS, SWH, SWL, HWH, HWL or CODE), all the requirements if
+no type is given. Each cell shows the field of the requirements reached from the one of the row by following the
+links in turn, each one parents or children, and keeping the ones of the column type, if any. The fields are id,
+title, body, rationale, acceptance_criteria, notes, path, document, section, status, attribute:<name>, and the evidence
+records changelists and tasks.
+
+The srs, sdd and svcp formats write the data items of DO-178C submitted to the certification authority as HTML
+documents to be printed: a numbered section per certification document, and a numbered subsection per high-level,
+low-level, or high and low-level requirement respectively, with the parts expected in the data item, e.g. the body,
+the rationale and the parents of the requirements. The data items can be changed, or others added, in the
+"submissions" entry of attributes.json:
+	"submissions": {
+		"srs": {
+			"title": "Software Requirements Data",
+			"types": ["SWH"],
+			"sections": ["body", "rationale", "attribute:Safety Impact", "parents"],
+			"numbering": "decimal",
+			"header": "ACME-SRD-001 Issue 2",
+			"footer": "Company Confidential"
+		}
+	}
+The sections are body, rationale, acceptance_criteria, notes, attribute:<name>, parents, children, code, and
+verification for the code files and the test cases verifying the requirement. The numbering is decimal or none.
+`
+
+const danglingUsage = `Lists the requirements cut off from the traceability, grouped by certification document. Usage:
+	reqtraq dangling --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+The requirements listed are those not reachable from any system requirement through their parents, including those
+without parents, and the low-level requirements which no code file implements, even partially. Deleted requirements
+are left out. The command fails if requirements are listed.
+`
+
+const diffUsage = `Lists the requirements added, deleted and modified between two git refs, for the change control board. Usage:
+	reqtraq diff <ref1> <ref2> --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	<ref1>	the git ref the changes are from, e.g. the last baseline
+	<ref2>	the git ref the changes are to, e.g. HEAD
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+The graph is built at both refs. A requirement is deleted when it is removed or marked DELETED. For each requirement
+modified, the changes of its title, of its attributes and of its parents are given with their old and new values,
+and those of its body as the lines of its plain text removed, prefixed with "-", and added, prefixed with "+":
+	Modified REQ-0-DDLN-SWL-012 Filtering
+		attribute VERIFICATION: "Test" -> "Unit test"
+		body:
+			 The RMT SHALL filter the requirements
+			-by title.
+			+by title and body.
+`
+
+const fmtUsage = `Rewrites a .toml certification document in its canonical form. Usage:
+	reqtraq fmt <input_toml_filename>
+Parameters:
+	<input_toml_filename>	.toml file to be formatted in place
+
+The document is checked against the .toml certification document schema before being rewritten.
+Comments are not preserved.
+`
+
+const coverageUsage = `Lists the high and low-level requirements which are not both implemented and verified. Usage:
+	reqtraq coverage --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+A requirement is implemented when all the code implementing it is complete, i.e. its status is COMPLETED.
+It is verified when code files, e.g. tests, reference it with an annotation such as
+	// @verifies <requirement ID>
+and none of the test cases imported from the test management tools in the "testresults" entry of the
+attributes json failed, or when these test cases all passed. The command fails if requirements are
+missing coverage.
+`
+
+const impactUsage = `Lists the requirements, certification documents and code files potentially affected by a change. Usage:
+	reqtraq impact [<requirement_id>] [--since=<commit>] --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	<requirement_id>	requirement changed
+	--since: the commit the requirements and the code files of the working tree changed since, e.g. the start of the branch
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+At least one of <requirement_id> and --since is needed. The requirements changed since the commit are those added or
+whose title, body, attributes or links changed, and the code files those changed according to git. A change potentially
+affects the ancestors of the requirements and code files changed, which they may no longer fulfil, their descendants
+down to the code, which may no longer fulfil them, and the code files verifying any of these requirements, e.g. tests,
+all to be verified again. The requirements removed since the commit affect their former parents and children. Deleted
+requirements are left out.
+`
+
+const metricsUsage = `Writes the coverage metrics of the requirements, per certification document and type. Usage:
+	reqtraq metrics --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+A row per certification document and type of requirements, e.g. SWL, then the totals per type and over all the types,
+gives the number of requirements, of those NOT STARTED, STARTED and COMPLETED, of the low-level ones referenced by
+code, and of those verified by code files, e.g. tests, or by test cases, with their shares of the requirements of the
+row. The deleted requirements are not counted.
+`
+
+const gocoverUsage = `Lists the low-level requirements whose Go code is never executed by the tests. Usage:
+	reqtraq gocover <coverage_profile> --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	<coverage_profile>: the coverage profile written by go test -coverprofile=<coverage_profile>, e.g. for ./...
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+The code of a requirement is the functions referencing it, or the whole file if a file references it outside
+of its functions. The test files and the files missing from the profile, e.g. of untested packages when the
+profile was written for some of the packages only, are skipped.
+
+If the binary exits with a 0 exitcode, the code of all the low-level requirements was executed. A non-zero exit
+code signals requirements whose code was never executed, which are printed to stderr.
+`
+
+const grepUsage = `Searches the requirements and the code annotations referencing them for a pattern. Usage:
+	reqtraq grep <pattern> --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	<pattern>: a regular expression, e.g. (?i)watchdog for a case-insensitive search
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+The titles, the bodies and the attributes of the requirements are searched as plain text, a line at a time,
+so the matches don't depend on the markup of the documents, e.g. the LyX insets. The lines of the code files
+referencing requirements are searched too. The matches are grouped by requirement, each one with the document
+or the code file and the line where it was found.
+`
+
+const lcovUsage = `Lists the code traced to requirements whose lines or branches are not all covered by the tests. Usage:
+	reqtraq lcov [<trace_file>] --certdoc_path=<path> --code_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	<trace_file>: an lcov trace file, e.g. written by lcov --capture, in addition to the "lcov" entry of the
+	attributes json
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+The trace files listed in the "lcov" entry of the attributes json, relative to the repository root, are also
+shown in the reports, with the line and branch coverage of each code file:
+	"lcov": ["build/unit.info", "build/integration.info"]
+The counts of the trace files are added up. As for gocover, the code of a requirement is the functions
+referencing it, or the whole file if a file references it outside of its functions. The test files, which
+only verify requirements, the test environments and the files missing from the trace files are skipped.
+
+If the binary exits with a 0 exitcode, all the code traced to requirements is covered. A non-zero exit code
+signals code which is not, printed to stderr with its uncovered lines, for the structural coverage analysis.
+`
+
+const gapsUsage = `Lists the exported Go functions in files not referencing any low-level requirement. Usage:
+	reqtraq gaps --code_path=<path> --attributes=<path_to_attributes_json> [--strict]
+Parameters:
+	--code_path: location of code files within the current repository
+	--attributes: path to json with requirement attribute specification.
+	--strict: also check the files referencing requirements
+
+The files and the functions are parsed, and a function is reported when neither its file nor its documentation
+contain an @llr annotation. The functions found are candidate traceability gaps.
+
+With --strict, once a file contains an @llr annotation, each of its exported functions must carry one, in its
+documentation or its body, so the partially annotated files are reported too. The binary then exits with a
+non-zero exit code if any function is reported.
+
+Test files and generated files are ignored. Generated files are recognized by the standard
+"// Code generated ... DO NOT EDIT." header, as written by mockgen, protoc-gen-go or stringer. Other generators
+can be recognized with regular expressions matching the paths or the header comments in the "generated" entry
+of the attributes json:
+	"generated": {
+		"paths": ["_mock\\.go$", "^gen/"],
+		"headers": ["^// Autogenerated by"]
+	}
+`
+
 const listUsage = `Parses and lists all requirements found in certification documents. Usage:
 	reqtraq list <input_lyx_filename>
 Parameters:
-	<input_lyx_filename>	Lyx file to be parsed
+	<input_lyx_filename>	Lyx, Markdown, Org, TOML, HTML or OpenDocument file to be parsed
+`
+
+const notifyUsage = `Posts the lifecycle events of the requirements since a snapshot to the webhook of the quality management
+system, so its records stay synchronized. Usage:
+	reqtraq notify --since=<snapshot> --attributes=<path_to_attributes_json> --certdoc_path=<path> --code_path=<path>
+Parameters:
+	--since: the snapshot the events are relative to, e.g. written by the snapshot export after the last notify
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+
+The webhook is configured in the "webhook" entry of the attributes json:
+	"webhook": {
+		"url": "https://qms.example.com/api/requirements/events",
+		"events": ["approved", "baselined", "deleted", "suspect-link"],
+		"approved": {"attribute": "Approval", "value": "Approved"}
+	}
+The events are those listed, all by default:
+	approved	a requirement whose approval attribute matches the regular expression, and didn't
+	deleted	a requirement deleted or removed
+	suspect-link	a link from a requirement whose title, body, sections or attributes changed to a child which
+		didn't change, to be reviewed again
+	baselined	posted by the archive and baseline commands, with the name of the archive or the baseline
+They are posted as json, with the commit and the time:
+	{"commit": "...", "time": "2020-03-01T10:00:00Z", "events": [{"kind": "approved", "id": "REQ-0-DDLN-SWL-001"},
+	 {"kind": "suspect-link", "parent": "REQ-0-DDLN-SWH-001", "child": "REQ-0-DDLN-SWL-001"}]}
+The X-Reqtraq-Signature header is the HMAC-SHA256 of the body, as sha256=<hex>, with the secret of the git config
+daedalean.webhook-secret.
+
+Other webhooks, e.g. of chat bots and dashboards, are configured alike in the "webhooks" entry, each posted the events
+of its kinds:
+	"webhooks": [
+		{"url": "https://bots.example.com/reqtraq", "events": ["created", "deleted", "validation-failed"]}
+	]
+All the webhooks are also posted the events of the other commands as they run, the failures to post them being
+logged without failing the commands:
+	created, updated, deleted	by updatetasks and prepush, for the task of a requirement created, updated, with the
+		fields updated, or marked invalid, e.g. {"kind": "updated", "id": "REQ-0-DDLN-SWL-001", "task": "123",
+		"fields": "title, parents"}
+	validation-failed	by precommit and quickcheck, when they find issues, e.g. {"kind": "validation-failed",
+		"check": "precommit", "issues": ["Invalid reference to non existent requirement REQ-0-DDLN-SWH-009"]}
+	baselined	by archive and baseline create
 `
 
 const nextidUsage = `Generates the next requirement id for the given document. Usage:
@@ -84,31 +593,145 @@ Parameters:
 `
 
 const precommitUsage = `Runs the pre-commit checks for the requirement documents in the current repository. Usage:
-	reqtraq precommit --certdoc_path=<path>
+	reqtraq precommit --certdoc_path=<path> --main_branch=<branch>
 Parameters:
 	--certdoc_path: location of certification documents within the current repository
+	--main_branch: optional, the branch the parents missing in the current one are looked up in, e.g. origin/master
 
 If the binary exits with a 0 exitcode, the requirement documents are correct. A non-zero exit code signals one or more
 problems, which are printed to stderr.
+
+The requirement IDs mentioned in the comments of the code, outside of the @llr and @verifies annotations, which don't
+exist or are deleted are printed to stdout, e.g. "Mention of deleted requirement REQ-0-DDLN-SWL-003 in main.go:12".
+They are informational and don't change the exit code.
+
+The attributes of the requirements are checked against their specification and the conditional rules of the "rules"
+entry of the attributes json. A rule applies to the requirements whose attributes match all the regular expressions of
+its "if", and requires the attributes of its "then" to match their regular expression, or to list at least "min"
+distinct values separated by commas, e.g. the reviewers who signed off. The regular expressions match the whole value:
+	"rules": [{"name": "high-safety-impact", "if": {"Safety Impact": "High"},
+	           "then": [{"attribute": "Verification", "value": "Test"}, {"attribute": "Reviewers", "min": 2}]}]
+
+With a "derived" entry in the attributes json, the rationale of each derived requirement must reference the records
+justifying it, which must exist in the directory of the records, see reqtraq help reportderived.
+
+With --main_branch, the parents which don't exist in the current branch but in the main one, e.g. added there after
+the branch was created or left out of a sparse checkout, are not errors: they are printed to stdout as external to
+the branch, e.g. "Parent REQ-0-DDLN-SWH-020 of requirement REQ-0-DDLN-SWL-061 external to this branch, defined in the
+main branch". The parents deleted in the main branch remain errors.
 `
 
 const prepushUsage = `Runs the pre-push checks for the requirement documents in the current repository. Usage:
-	reqtraq prepush --certdoc_path=<path>
+	reqtraq prepush --certdoc_path=<path> --force --dry-run
 Parameters:
 	--certdoc_path: location of certification documents within the current repository
+	--force: overwrite the task titles and descriptions edited in the task manager
+	--dry-run: only report the changes of the tasks, see reqtraq help updatetasks
 
 If the binary exits with a 0 exitcode, the pre-push ran successfully. A non-zero exit code signals one or more
 problems, which are printed to stderr.
 `
 
+const checklinksUsage = `Checks the URLs found in the bodies and the attributes of the requirements, e.g. referencing
+standards and other documents, and lists the dead ones. Usage:
+	reqtraq checklinks --certdoc_path=<path> --attributes=<path_to_attributes_json>
+Parameters:
+	--certdoc_path: location of certification documents within the current repository
+	--attributes: path to json with requirement attribute specification.
+
+The links are requested with HEAD, or GET if HEAD fails. The timeout of the requests, the interval between two requests
+to the same host, how long the results are reused and the links which are not checked, e.g. those of an intranet, can be
+configured in the "links" entry of the attributes json, in seconds and hours:
+	"links": { "timeout": 10, "interval": 1, "max_age": 24, "ignore": ["^https?://intranet\\."] }
+The results are kept in .git/reqtraq/links.json.
+
+If the binary exits with a 0 exitcode, all the links could be resolved. A non-zero exit code signals dead links, which
+are printed to stderr.
+`
+
+const graphUsage = `Writes a diagram of a requirement, its ancestors and its descendants down to the code. Usage:
+	reqtraq graph <requirement_id> --format=mermaid --certdoc_path=<path> --code_path=<path>
+Parameters:
+	<requirement_id>	requirement to draw the neighbourhood of
+	--format: the format of the diagram, mermaid by default
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+
+The mermaid format is a flowchart to be pasted in Markdown, e.g. in design notes or merge request descriptions, in a
+"mermaid" code block. Each requirement has a node with its ID and title, and each code file a rounded one, colored by
+status, NOT STARTED red, STARTED yellow and COMPLETED green, the given requirement outlined. The arrows go from the
+parents to their children. Deleted requirements are left out.
+`
+
+const fixUsage = `Fixes the issues of the requirements which need no decision, asking for each fix whether to apply it.
+Usage:
+	reqtraq fix --interactive --attributes=<path_to_attributes_json> --certdoc_path=<path> --code_path=<path>
+Parameters:
+	--interactive: ask for each fix whether to apply it, otherwise the fixes are only listed.
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+
+The issues fixed are:
+	- the references to renamed requirements, in the certification documents and in the code. A renamed requirement
+	  is deleted and references a single other requirement, the new one, e.g.
+	  "REQ-0-DDLN-SWL-003 DELETED Renamed to REQ-0-DDLN-SWL-012".
+	- the attributes missing in the requirements of the .md and .toml certification documents which have a default
+	  value in the attributes json, e.g. {"name": "Verification", "default": "Unit test"}. The .toml documents are
+	  written in their canonical form, as by fmt.
+	- the consecutive annotations of the code which are not sorted by ID.
+Each fix shows the lines changed, and is applied once answered y, skipped if answered n. The fixes left are skipped
+once answered q.
+`
+
+const quickcheckUsage = `Runs the pre-commit checks, only parsing again the certification documents and code files changed
+in the working tree or in the commits since the last run. Usage:
+	reqtraq quickcheck --certdoc_path=<path> --code_path=<path>
+Parameters:
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of the code within the current repository
+
+What was parsed is kept in .git/reqtraq/cache.json. Only the references in the changed certification documents are
+checked, so run precommit for a complete check. Meant to be bound to the save hook of an editor, as it typically returns
+in well under a second.
+
+If the binary exits with a 0 exitcode, the requirement documents are correct. A non-zero exit code signals one or more
+problems, which are printed to stderr.
+`
+
+const standaloneReportUsage = `Creates a self-contained HTML traceability report of the whole requirement graph, to be attached to a
+certification data package. Usage:
+	reqtraq report <output_filename> --attributes=<path_to_attributes_json> --at=<commit> --certdoc_path=<path> --code_path=<path>
+		--pdf
+Parameters:
+	--attributes: path to json with requirement attribute specification.
+	--at: the commit reported, the working tree if not given.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+	--pdf: also convert the report to PDF, the output filename with the .pdf extension.
+	<output_filename>	HTML file to be written
+
+The report has the counts of the requirements by type and status, the hierarchy from the system requirements down
+to the code with the statuses, attributes, code references and changelists of the requirements, the code files with
+the requirements they implement and verify, and the dangling requirements. It loads no stylesheet nor script, so it
+reads offline, and the equations are left as TeX.
+`
+
 const reportUsage = `
+	reportattributes	creates an HTML report of the distributions of the attribute values, and of the requirements missing required attributes
 	reportdown 	creates an HTML traceability report from system requirements down to code
+	reportcode	creates an HTML report of the requirements each code file traces to, and whether it changed since HEAD
+	reportderived	creates an HTML report of the derived requirements with the records justifying them
+	reportdeleted	creates an HTML report of the deleted requirements, when and by whom, and what still references them
+	reporthistory	creates an HTML report of the changes of the safety classifications, and of their downgrades
 	reportissues	creates an HTML report with all issues found in the requirement documents
+	reportrisk	creates an HTML report ranking the requirements by risk
 	reportup 	creates an HTML traceability report from code, to LLRs, to HLRs and to system requirements
+	reportverification	creates an HTML report of the tests and test cases verifying each requirement, with their results
 Usage:
 	reqtraq report<type> --pfx=<reportfile-prefix> --title_filter=<regexp> --id_filter=<regexp>
 		--body_filter=<regexp> --attributes=<path_to_attributes_json> --since=<start_commid> --at=<end_commit>
-		--certdoc_path=<path>
+		--certdoc_path=<path> --audience=<name> --doc=<name> --under=<id> --pdf --split --page_size=<n> --task-progress
 Parameters:
 	--pfx: path and filename prefix for reports.
 	--title_filter: regular expression to filter by requirement title.
@@ -118,34 +741,375 @@ Parameters:
 	--since: the Git commit SHA-1 representing the start of the range.
 	--at: the commit representing the end of the range.
 	--certdoc_path: location of certification documents within the current repository
+	--audience: the audience of the reportdown variant, one of engineer, manager, customer or those in
+		the "audiences" entry of the attributes json.
+	--doc: optional, the certification document the reports are scoped to, e.g. 0-DDLN-212-SDD.
+	--under: optional, the requirement whose subtree, the requirement and its descendants, the reports are scoped to.
+	--pdf: also convert the reports to PDF, e.g. req-down.pdf next to req-down.html.
+	--split: for reportdown, write the report as a directory, e.g. req-down/, of HTML pages.
+	--page_size: for reportdown --split, the maximum number of requirements per page, 1000 by default, 0 for no limit.
+	--task-progress: also show the status, the assignee and the comments of the task of each requirement, read back
+		from the task manager, e.g. Phabricator, next to the status derived from its children.
+
+The audience variants show the same graph to the engineers, with the bodies and the code links of all
+the requirements, to the managers, with the statuses and the counts only, or to the customers, with the
+system and high-level requirements only and without their attributes, code and evidence. The audiences
+can be configured in the attributes json, each with the tree or the summary template, the types of the
+requirements shown and the fields shown, among body, attributes, attribute:<name>, code, changelists
+and tasks, e.g.:
+	"audiences": {
+		"customer": { "template": "tree", "types": ["SYS", "SWH"], "fields": ["body", "attribute:Rationale"] }
+	}
+
+The history report lists the changes of the attributes configured in the "history" entry of the
+attributes json over the git history of the certification documents, by default the safety impact and
+the DAL, and the downgrades to less critical classifications. The classifications are regular
+expressions, from the most to the least critical, e.g.:
+	"history": {
+		"attributes": [
+			{ "name": "DAL", "values": ["(?i)^\\s*A\\s*$", "(?i)^\\s*B\\s*$", "(?i)^\\s*C\\s*$", "(?i)^\\s*D\\s*$"] }
+		]
+	}
+
+The risk score of a requirement combines the factors configured in the "risk" entry of the attributes
+json: the value of attributes such as the safety impact or the verification method, the number of commits
+touching the implementing code (churn), and the number of decision points in the implementing code
+(complexity). For example:
+	"risk": {
+		"attributes": [
+			{ "name": "Safety Impact", "weight": 3, "values": [{ "value": "(?i)none", "score": 0 }], "default": 1 }
+		],
+		"churn_weight": 1,
+		"complexity_weight": 1
+	}
+
+The derived report lists the derived requirements, whose "Derived" attribute is yes or true, with their parents,
+their "Rationale" attribute and the records of the analyses or design decisions it references, e.g. DDR-0042,
+looked up in a directory of the repository as files named after them, e.g. DDR-0042-sensor-fusion.md. These can be
+configured in the "derived" entry of the attributes json, the precommit checks then requiring the rationale of each
+derived requirement to reference existing records:
+	"derived": {
+		"attribute": "Derived", "value": "(?i)yes|true",
+		"rationale": "Rationale", "records": "DDR-[0-9]+",
+		"dir": "design/decisions"
+	}
+
+The attributes report counts the values of each attribute of the requirements, e.g. of the safety impact
+or of the verification method, and the requirements having it, those required in the "attributes" entry of
+the attributes json first, with the invalid values and the requirements missing required attributes.
+
+The code report lists the code files referencing the requirements, with the requirements they implement,
+partially implement and verify, those referenced by each of their functions, the hashes of their contents
+recorded in the graph, and whether these still match the files at HEAD: Unchanged, Modified, Not committed, or
+Not comparable if the hash algorithm isn't the object format of the repository.
+
+The verification report lists the requirements with a "Verification" attribute, with the code files verifying
+them with @verifies annotations, the test cases imported from the "testresults" entry of the attributes json
+and the results of their last execution, and their verification status: Failed if a test case failed, Passed
+if all passed, Incomplete otherwise, "No results" if only code files verify them and "Not verified" if nothing does.
+
+For the largest graphs, reportdown --split writes the top-down report as a directory, e.g. req-down/, with an
+index.html of the certification documents, a page per document, split into pages of at most --page_size
+requirements, and pages of the code files. The parents, the children and the code files link to their pages.
+The filters apply to all the pages.
+
+The HTML reports are written with Go html/template templates. Those of the directory given in the "templates"
+entry of the attributes json, relative to the repository root and outside the certification documents, the
+files ending in .html, replace the built-in templates they define, e.g. HEADER for the branding or REQUIREMENT
+for the fields shown, and can define others:
+	"templates": "templates"
+
+The deleted report lists the requirements whose title starts with DELETED, with the commit which marked
+them, found in the git history of their certification documents, its date and author, and the requirements
+and code files still referencing them, for the configuration management records.
+`
+
+const suggestUsage = `Suggests the likely parents of a requirement, or the likely requirements implemented by a code file. Usage:
+	reqtraq suggest <requirement_id_or_code_filename> --attributes=<path_to_attributes_json> --certdoc_path=<path> --code_path=<path>
+Parameters:
+	<requirement_id_or_code_filename>	requirement to suggest parents for, or code file to suggest requirements for,
+		under the code path
+	--attributes: path to json with requirement attribute specification.
+	--certdoc_path: location of certification documents within the current repository
+	--code_path: location of code files within the current repository
+
+The suggestions are ranked, and are not applied. By default they are ranked by word similarity. An external
+service or model can be used instead by configuring a command in the "suggest" entry of the attributes json:
+	"suggest": {
+		"command": ["python3", "tools/suggest.py"],
+		"max": 5
+	}
+The command reads the query as json on stdin: its "kind" ("parents" or "requirements"), the "id", "title" and
+"body" of the requirement or the "path" and "code" of the code file, and the "candidates", each with an "id", a
+"title" and a "body". It writes the suggestions as a json list on stdout, for example:
+	[{ "id": "REQ-0-DDLN-SWH-001", "score": 0.9 }, { "id": "REQ-0-DDLN-SWH-004", "score": 0.4 }]
 `
 
-const updateTaskUsage = `Updates the tasks associated with the given requirements (requires a Phabricator/JIRA/Bugzilla instance). Usage:
-	reqtraq updatetasks --certdoc_path=<path>
+const updateTaskUsage = `Updates the tasks associated with the given requirements (requires a Phabricator, Jira, GitHub, GitLab, Azure DevOps or Polarion instance). Usage:
+	reqtraq updatetasks --certdoc_path=<path> --attributes=<path_to_attributes_json> --force --dry-run --queue
+		--types=<types> --doc=<name> --under=<id> --title_filter=<regexp> --id_filter=<regexp> --body_filter=<regexp>
+		--since=<commit>
+	reqtraq updatetasks --resume --attributes=<path_to_attributes_json>
 Parameters:
 	--certdoc_path: location of certification documents within the current repository
+	--attributes: path to json with the tags of the requirement attributes
+	--types: optional, the comma-separated types of the requirements whose tasks are synced, e.g. SWH,HWH
+	--doc: optional, the certification document whose requirements are synced, e.g. 0-DDLN-211-SRD
+	--under: optional, the requirement whose subtree, the requirement and its descendants, is synced
+	--title_filter, --id_filter, --body_filter: optional, the regular expressions the titles, IDs and bodies of the
+	  requirements synced match
+	--since: optional, the commit since which the requirements synced changed
+	--queue: optional, plan the changes of the tasks in a journal before making them, see below
+	--resume: resume the queued sync interrupted, making its changes left, rather than syncing the tasks
+	--force: overwrite the task titles and descriptions edited in the task manager
+	--dry-run: only report the projects and the tasks which would be created, the fields of the tasks which would
+	  be updated, with the lines of the title and the description removed and added, and the tasks which would be
+	  set as INVALID or tagged, without changing the task manager nor the state of the last sync
+
+The requirements synced are all of them by default, or those selected by all the options given, e.g. only the
+high-level requirements of the SRD changed since the last release:
+	reqtraq updatetasks --types=SWH --doc=0-DDLN-211-SRD --since=v1.2
+The tasks of the requirements not selected are still found, as the parents of those selected, but left unchanged.
+
+With --queue, the changes of the tasks are planned first, and written to a journal in the git directory of the
+clone, then made in order, each change made recorded in the journal. A sync interrupted, e.g. by a network failure,
+stops at the change failing and is resumed where it stopped with:
+	reqtraq updatetasks --resume
+rather than leaving the tasks half updated. No other sync is made until the sync pending is resumed.
 
 For each requirement the method will:
 	- find the task associated with the requirement, by searching for the requirement ID in the task title using the taskmgr API
-	- if a task was found and the requirement was not deleted, its title and description are updated
+	- if a task was found and the requirement was not deleted, its title, description and parents are updated, if
+	  they changed since they were last written, leaving the edits made in the task manager to the others
+	- if the title or description to be updated was also edited in the task manager since it was last written, the
+	  conflict is reported and the field is left unchanged, unless --force is given. What was last written is
+	  recorded in the sync state of the clone and, as hashes, in the last line of the descriptions of the tasks,
+	  "reqtraq-sync: <title hash> <description hash>", so the edits are detected in any clone
 	- if a task was found and the requirement was deleted, the task is set as INVALID
 	- if the task was not found, it is created and filled in with the following values:
 	 	Title: <Req ID> <Req Title>
-		Description: <Requirement Body>
+		Description: <Requirement Body>, followed by the reqtraq-sync line
 		Status: Open
-		Tags: Project Abbreviation (e.g. DDLN, VXU, etc.)
-      		Parents: the first parent task (Phabricator doesn't yet support multiple parents in the api)
+		Tags: Project Abbreviation (e.g. DDLN, VXU, etc.), and the tags of the requirement attributes
+      		Parents: the tasks of all the parents in Phabricator, see below for the other task managers
+
+The tags of the requirement attributes are configured in the "tags" entry of the attributes json, each rule tagging
+the tasks of the requirements having an attribute, optionally with a value matching a regular expression:
+	"tags": [
+		{ "attribute": "MODE" },
+		{ "attribute": "SAFETY IMPACT", "value": "High", "tag": "Safety critical" }
+	]
+By default the tag is named after the attribute and its value, e.g. "MODE: Automatic". The tags are kept up to date
+as the attributes change, the other tags of the tasks are left unchanged.
+
+A requirement whose task fails to sync doesn't stop the sync of the others, nor of its children if its task exists:
+the failures are summarized at the end. The calls failing transiently, e.g. timing out or refused with 429 or 503,
+are retried with exponential backoff, and their rate limited as configured in the "taskCalls" entry:
+	"taskCalls": { "attempts": 5, "backoff": "2s", "requestsPerSecond": 10 }
+The attempts are 3 and the first backoff 1s by default, doubled before each next retry, and the rate unlimited. The
+Phabricator tasks of a project are listed at once, a page of 100 at a time, rather than searched one by one.
+
+The attributes mapped to custom fields of the tasks are written to them rather than left to the tags, and read back
+on the next syncs: they are updated when the attributes change, unless also edited in the task manager, which is
+reported as a conflict of the attributes as for the titles and descriptions. The Maniphest custom fields are those
+of the "phabricator" entry, by attribute name, keyed as in maniphest.custom-field-definitions, which also gives the
+server if not https://p.daedalean.ai:
+	"phabricator": {
+		"url": "https://p.daedalean.ai",
+		"customFields": { "SAFETY IMPACT": "daedalean:safety-impact", "VERIFICATION": "daedalean:verification" }
+	}
+
+The tasks are Phabricator tasks, unless a Jira project is configured in the "jira" entry of the attributes json:
+	"jira": {
+		"url": "https://example.atlassian.net",
+		"project": "DDLN",
+		"issueTypes": { "SYS": "Epic", "SWH": "Story", "SWL": "Task" },
+		"customFields": { "SAFETY IMPACT": "customfield_10042" },
+		"linkType": "Blocks",
+		"invalidStatus": "Invalid"
+	}
+The tasks are then issues of the project, of the issue type of the requirement type (Epic for SYS, Story for SWH and
+HWH and Task for the others by default), with the "customFields" set to the values of the attributes. All the parents
+are linked, the task blocking them by default, the projects and the tags are labels, and the tasks of the deleted
+requirements are transitioned to the invalid status. The credentials are read from the git config:
+	git config --local --replace-all daedalean.jira-user <USER>
+	git config --local --replace-all daedalean.jira-token <API_TOKEN>
+
+The tasks are the issues of a GitHub repository instead if configured in the "github" entry of the attributes json,
+with "url" being that of the API for GitHub Enterprise:
+	"github": {
+		"repository": "daedaleanai/reqtraq"
+	}
+The projects, one per requirement level, and the tags are labels, the bodies of the issues of the parents end with a
+task list of the issues of their children, and the issues of the deleted requirements are closed as not planned. The
+token is read from the git config:
+	git config --local --replace-all daedalean.github-token <TOKEN>
+
+The tasks are the epics of a GitLab group and the issues of a GitLab project if configured in the "gitlab" entry of
+the attributes json, with "url" being that of the server if not gitlab.com:
+	"gitlab": {
+		"project": "daedalean/reqtraq",
+		"group": "daedalean",
+		"epicTypes": ["SYS"]
+	}
+The tasks of the requirement types in "epicTypes", SYS by default, are epics, as is the parent of all the tasks, and
+the others issues, all of them issues without a group. An issue belongs to the epic of its first epic parent and
+blocks the issues of its other parents. The projects, one per requirement level, and the tags are labels, and the
+tasks of the deleted requirements are closed with the label "Invalid", or the "invalidLabel". The token is read from
+the git config:
+	git config --local --replace-all daedalean.gitlab-token <TOKEN>
+
+The tasks are the work items of an Azure DevOps project if configured in the "azure" entry of the attributes json:
+	"azure": {
+		"organization": "https://dev.azure.com/daedalean",
+		"project": "Avionics",
+		"areaPath": "Avionics\\Reqtraq",
+		"workItemTypes": { "SYS": "Epic", "SWH": "Feature", "SWL": "Task" },
+		"removedState": "Removed"
+	}
+The work items are of the type of the requirement type (Epic for SYS and for the parent of all the tasks, Feature
+for SWH and HWH and User Story for the others by default), in the area path if set. A work item has the work item of
+its first parent as parent, the projects, one per requirement level, and the tags are tags, and the work items of
+the deleted requirements are set to the removed state. The personal access token is read from the git config:
+	git config --local --replace-all daedalean.azure-token <TOKEN>
+
+The tasks are the work items of a Polarion ALM project if configured in the "polarion" entry of the attributes json:
+	"polarion": {
+		"url": "https://polarion.example.com",
+		"project": "Avionics",
+		"workItemTypes": { "SYS": "systemRequirement", "SWH": "softwareRequirement" },
+		"tagsField": "reqtraqTags",
+		"parentRole": "parent",
+		"invalidStatus": "obsolete",
+		"approvalAttribute": "Approval"
+	}
+The work items are of the type of the requirement type, requirement by default, and are linked to the work items of
+all their parents with the parent role. The projects, one per requirement level, and the tags are kept in the custom
+string field "tagsField" of the work items, which is to be defined in the project, and the work items of the deleted
+requirements are set to the invalid status. The approvals of the work items are read back by reqtraq approvals. The
+personal access token is read from the git config:
+	git config --local --replace-all daedalean.polarion-token <TOKEN>
 `
 
 const webUsage = `Starts a local web server to facilitate interaction with reqtraq. Usage:
-	reqtraq web --addr="hostport" --certdoc_path=<path>
+	reqtraq web --addr="hostport" --certdoc_path=<path> [--readonly]
 Parameters:
 	--addr: the ip:port where to serve.
 	--certdoc_path: location of certification documents within the current repository.
+	--readonly: never write to the repository, not even the lock file, e.g. when serving as a user who can't.
+
+Each request holds a shared lock of the repository while reading it, .git/reqtraq/lock, so the commands writing to
+the certification documents or to the state of reqtraq, e.g. fmt, confluence or quickcheck, wait for the reports
+being built, and the requests wait for the commands. The other commands hold the lock for as long as they run.
+With --readonly the commands writing to the repository are refused.
+
+For the deployment tooling, the server also replies to
+	/healthz	with 200 as long as it runs
+	/readyz		with 200 if the graph of the working tree builds without issues, 503 and the issues otherwise
+	/version	with the commit reqtraq was built from, the SHA-256 of the attributes json and of the snapshot of
+			the last graph built from the working tree, as archived, and the time it was built, as json
+	/badge/<name>.svg	with the badge of the working tree, see reqtraq help badges
 `
 
 type JsonConf struct {
 	Attributes []map[string]string
+	Risk       *RiskConf
+	Suggest    *SuggestConf
+	Confluence *ConfluenceConf
+	Generated  GeneratedConf
+	Tags       []TagRule
+	Matrices   []MatrixConf
+	Archive    ArchiveConf
+	Audiences  map[string]AudienceConf
+	History    *HistoryConf
+	Links      *LinksConf
+	Languages  []LanguageConf
+	Webhook    *WebhookConf
+	// Webhooks are the webhooks notified in addition to the "webhook" one, e.g. of chat bots
+	// and dashboards.
+	Webhooks []WebhookConf
+	// Derived is the traceability of the rationale of the derived requirements, checked if set.
+	Derived *DerivedConf
+	// CodeReferences are the types of the requirements code may reference, SWL and HWL by default.
+	CodeReferences []string
+	// Annotations are the tags of the code annotations in addition to @llr and @verifies.
+	Annotations []AnnotationConf
+	// CodeRequirements enables the low-level requirements defined in the doc comments of the code.
+	CodeRequirements bool
+	// HashAlgorithm is that of the hashes of the code files, sha1 or sha256, the object format of
+	// the repository by default.
+	HashAlgorithm string
+	// Lcov are the lcov trace files of the structural coverage of the code, relative to the repository root.
+	Lcov []string
+	// Rules are the conditional rules of the attributes, see RuleConf.
+	Rules []RuleConf
+	// Submissions lay out the data items of the certification submissions, see SubmissionConf.
+	Submissions map[string]SubmissionConf
+	// TestResults are the exports of the test management tools, see ImportTestResults.
+	TestResults []TestResultsConf
+	// TestEnv are the directories of the test environments and simulation models, relative to the repository root.
+	TestEnv []string
+	// Templates is the directory of the templates overriding those of the HTML reports, relative to
+	// the repository root, see loadReportTemplates.
+	Templates string
+	// TaskCalls are the retries and the rate of the calls to the task manager of a sync of the tasks.
+	TaskCalls taskmgr.CallConf
+	// Phabricator configures the Phabricator task manager, the default one, e.g. its custom fields.
+	Phabricator *taskmgr.PhabricatorConf
+	// Jira is the Jira project of the tasks, used rather than Phabricator if set.
+	Jira *taskmgr.JiraConf
+	// GitHub is the GitHub repository of the issues of the tasks, used rather than Phabricator if set.
+	GitHub *taskmgr.GitHubConf
+	// GitLab is the GitLab group and project of the epics and the issues of the tasks, used rather than Phabricator if
+	// set.
+	GitLab *taskmgr.GitLabConf
+	// Azure is the Azure DevOps project of the work items of the tasks, used rather than Phabricator if set.
+	Azure *taskmgr.AzureConf
+	// Polarion is the Polarion project of the work items of the tasks, used rather than Phabricator if set, and the
+	// attribute their approval status is read back into.
+	Polarion *taskmgr.PolarionConf
+}
+
+// loadJsonConf reads the reqtraq configuration from the json file at the given path.
+func loadJsonConf(path string) (JsonConf, error) {
+	var conf JsonConf
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return conf, err
+	}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return conf, fmt.Errorf("Error while parsing %s: %v", path, err)
+	}
+	return conf, nil
+}
+
+// riskConf returns the risk configuration, or the default one if there's none.
+func (c JsonConf) riskConf() RiskConf {
+	if c.Risk == nil {
+		return defaultRiskConf
+	}
+	return *c.Risk
+}
+
+// derivedConf returns the configuration of the derived requirements, or the default one if there's
+// none.
+func (c JsonConf) derivedConf() DerivedConf {
+	if c.Derived == nil {
+		return defaultDerivedConf
+	}
+	return *c.Derived
+}
+
+// suggestConf returns the suggestion configuration, or the default one if there's none.
+func (c JsonConf) suggestConf() SuggestConf {
+	conf := SuggestConf{}
+	if c.Suggest != nil {
+		conf = *c.Suggest
+	}
+	if conf.Max == 0 {
+		conf.Max = defaultSuggestMax
+	}
+	return conf
 }
 
 func showHelp() {
@@ -156,18 +1120,64 @@ func showHelp() {
 	switch subCommand {
 	case "help", "": // general help
 		fmt.Println(usage)
+	case "approvals":
+		fmt.Println(approvalsUsage)
+	case "archive":
+		fmt.Println(archiveUsage)
+	case "badges":
+		fmt.Println(badgesUsage)
+	case "baseline":
+		fmt.Println(baselineUsage)
+	case "confluence":
+		fmt.Println(confluenceUsage)
+	case "coverage":
+		fmt.Println(coverageUsage)
+	case "dangling":
+		fmt.Println(danglingUsage)
+	case "diff":
+		fmt.Println(diffUsage)
+	case "export":
+		fmt.Println(exportUsage)
+	case "fix":
+		fmt.Println(fixUsage)
+	case "fmt":
+		fmt.Println(fmtUsage)
+	case "gaps":
+		fmt.Println(gapsUsage)
+	case "graph":
+		fmt.Println(graphUsage)
+	case "gocover":
+		fmt.Println(gocoverUsage)
+	case "grep":
+		fmt.Println(grepUsage)
+	case "impact":
+		fmt.Println(impactUsage)
+	case "lcov":
+		fmt.Println(lcovUsage)
 	case "linkify":
 		fmt.Println(linkifyUsage)
 	case "list":
 		fmt.Println(listUsage)
+	case "metrics":
+		fmt.Println(metricsUsage)
 	case "nextid":
 		fmt.Println(nextidUsage)
+	case "notify":
+		fmt.Println(notifyUsage)
 	case "precommit":
 		fmt.Println(precommitUsage)
 	case "prepush":
 		fmt.Println(prepushUsage)
-	case "reportup", "reportdown", "reportissues":
+	case "checklinks":
+		fmt.Println(checklinksUsage)
+	case "quickcheck":
+		fmt.Println(quickcheckUsage)
+	case "report":
+		fmt.Println(standaloneReportUsage)
+	case "reportup", "reportattributes", "reportcode", "reportdown", "reportderived", "reportdeleted", "reporthistory", "reportissues", "reportrisk", "reportverification":
 		fmt.Println(reportUsage)
+	case "suggest":
+		fmt.Println(suggestUsage)
 	case "updatetasks":
 		fmt.Println(updateTaskUsage)
 	case "web":
@@ -196,38 +1206,131 @@ func main() {
 		if !strings.HasPrefix(remainingArgs[1], "-") {
 			f = remainingArgs[1]
 		}
-		// See maybe there are more flags after the `action`.
+		// See maybe there are more flags after the `action`, or after the file name.
 		os.Args = append(os.Args[:1], remainingArgs[1:]...)
-		flag.Parse()
+		args := os.Args[1:]
+		if f != "" {
+			args = args[1:]
+		}
+		flag.CommandLine.Parse(args)
+	}
+	// The commands taking more arguments, e.g. the second ref of diff, have the flags between and
+	// after them parsed too, before the configuration is loaded.
+	var moreArgs []string
+	if command == "baseline" || command == "diff" {
+		for flag.Arg(0) != "" && !strings.HasPrefix(flag.Arg(0), "-") {
+			moreArgs = append(moreArgs, flag.Arg(0))
+			flag.CommandLine.Parse(flag.Args()[1:])
+		}
+	}
+
+	writing := writingCommands[command] || writingCommands[command+" "+f]
+	if *fReadOnly && writing {
+		fatalf(exitUsage, "%s writes to the repository, refused in read-only mode", command)
+	}
+	// The web server locks the repository for each request instead.
+	if command != "help" && command != "web" {
+		lock, err := lockRepo(writing)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer lock.Unlock()
+	}
+
+	// The configuration is loaded once for all the commands, the configured languages applying to
+	// all those parsing the code.
+	conf, confErr := loadJsonConf(*fReportJsonConfPath)
+	if confErr != nil && !os.IsNotExist(confErr) {
+		fatal(exitUsage, confErr)
+	}
+	if err := registerLanguages(conf.Languages); err != nil {
+		fatal(exitUsage, err)
+	}
+	if err := registerCodeReferences(conf.CodeReferences); err != nil {
+		fatal(exitUsage, err)
+	}
+	if err := registerAnnotations(conf.Annotations); err != nil {
+		fatal(exitUsage, err)
+	}
+	codeRequirements = conf.CodeRequirements
+	if err := setHashAlgorithm(conf.HashAlgorithm); err != nil {
+		fatal(exitUsage, err)
+	}
+	taskManagers := 0
+	for _, configured := range []bool{conf.Phabricator != nil, conf.Jira != nil, conf.GitHub != nil, conf.GitLab != nil, conf.Azure != nil, conf.Polarion != nil} {
+		if configured {
+			taskManagers++
+		}
+	}
+	if taskManagers > 1 {
+		fatalf(exitUsage, "Several task managers configured in %s, among Phabricator, Jira, GitHub, GitLab, Azure DevOps and Polarion", *fReportJsonConfPath)
+	}
+	if conf.Phabricator != nil {
+		taskmgr.TaskMgr = taskmgr.NewPhabricatorTaskManager(*conf.Phabricator)
+	}
+	if conf.Jira != nil {
+		if conf.Jira.URL == "" || conf.Jira.Project == "" {
+			fatalf(exitUsage, "No Jira url and project configured in %s", *fReportJsonConfPath)
+		}
+		taskmgr.TaskMgr = taskmgr.NewJiraTaskManager(*conf.Jira)
+	}
+	if conf.GitHub != nil {
+		if conf.GitHub.Repository == "" {
+			fatalf(exitUsage, "No GitHub repository configured in %s", *fReportJsonConfPath)
+		}
+		taskmgr.TaskMgr = taskmgr.NewGitHubTaskManager(*conf.GitHub)
+	}
+	if conf.GitLab != nil {
+		if conf.GitLab.Project == "" {
+			fatalf(exitUsage, "No GitLab project configured in %s", *fReportJsonConfPath)
+		}
+		taskmgr.TaskMgr = taskmgr.NewGitLabTaskManager(*conf.GitLab)
+	}
+	if conf.Azure != nil {
+		if conf.Azure.Organization == "" || conf.Azure.Project == "" {
+			fatalf(exitUsage, "No Azure DevOps organization and project configured in %s", *fReportJsonConfPath)
+		}
+		taskmgr.TaskMgr = taskmgr.NewAzureTaskManager(*conf.Azure)
+	}
+	if conf.Polarion != nil {
+		if conf.Polarion.URL == "" || conf.Polarion.Project == "" {
+			fatalf(exitUsage, "No Polarion url and project configured in %s", *fReportJsonConfPath)
+		}
+		taskmgr.TaskMgr = taskmgr.NewPolarionTaskManager(*conf.Polarion)
+	}
+	if *fLowMemory {
+		if bodies, err = newBodyStore(); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	filter := ReqFilter{} // Filter for report generation
 	switch command {
-	case "reportdown", "reportup", "reportissues":
+	case "reportattributes", "reportcode", "reportdown", "reportderived", "reportdeleted", "reporthistory", "reportup", "reportissues", "reportrisk", "reportverification", "updatetasks":
 		if len(*fReportTitleFilterString) > 0 {
 			filter[TitleFilter], err = regexp.Compile(*fReportTitleFilterString)
 			if err != nil {
-				log.Fatal(err)
+				fatal(exitUsage, err)
 			}
 		}
 		if len(*fReportIdFilterString) > 0 {
 			filter[IdFilter], err = regexp.Compile(*fReportIdFilterString)
 			if err != nil {
-				log.Fatal(err)
+				fatal(exitUsage, err)
 			}
 		}
 		if len(*fReportBodyFilterString) > 0 {
 			filter[BodyFilter], err = regexp.Compile(*fReportBodyFilterString)
 			if err != nil {
-				log.Fatal(err)
+				fatal(exitUsage, err)
 			}
 		}
 	case "help":
 		showHelp()
 		os.Exit(0)
-	case "linkify", "list", "nextid":
+	case "archive", "badges", "export", "fmt", "linkify", "list", "nextid", "report", "suggest":
 		if f == "" {
-			log.Fatal("Missing file name")
+			fatal(exitUsage, "Missing file name")
 		}
 	}
 
@@ -236,13 +1339,29 @@ func main() {
 		diffs   map[string][]string
 	)
 	switch command {
-	case "reportdown", "reportup", "reportissues", "prepush":
+	case "report", "reportattributes", "reportcode", "reportdown", "reportderived", "reportdeleted", "reporthistory", "reportup", "reportissues", "reportrisk", "reportverification", "prepush":
+		if err := useReportTemplates(*fReportJsonConfPath); err != nil {
+			fatal(exitUsage, err)
+		}
 		var dir string
 		rg, dir, err = buildGraph(*at)
 		if err != nil {
-			log.Fatal(err)
+			// The references to the deleted requirements fail the graph, but the deleted report lists them.
+			if command != "reportdeleted" || rg == nil {
+				fatalErr(exitInternal, err)
+			}
+			log.Println(err)
 		}
 		defer os.RemoveAll(dir)
+		if *fTaskProgress {
+			progress, ok := taskmgr.TaskMgr.(taskmgr.ProgressReader)
+			if !ok {
+				fatalf(exitUsage, "The task manager configured in %s doesn't provide the progress of the tasks", *fReportJsonConfPath)
+			}
+			if err := rg.ImportTaskProgress(progress); err != nil {
+				fatal(exitIntegration, err)
+			}
+		}
 
 		if *since != "" {
 			var dir string
@@ -253,9 +1372,20 @@ func main() {
 			defer os.RemoveAll(dir)
 		}
 		diffs = rg.ChangedSince(prg)
-	}
 
-	switch command {
+		if scope := (Scope{*fDoc, *fUnder}); !scope.IsZero() {
+			if command == "report" || command == "prepush" {
+				fatal(exitUsage, "--doc and --under only apply to the report<type> commands and export")
+			}
+			ids, err := rg.ScopeIDs(scope)
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			diffs = scopeDiffs(diffs, ids)
+		}
+	}
+
+	switch command {
 	case "nextid":
 		nextID, err := NextId(f)
 		if err != nil {
@@ -263,18 +1393,15 @@ func main() {
 		}
 		fmt.Println(nextID)
 	case "list":
-		reqs, err := ParseCertdoc(f)
-		if err != nil {
-			log.Fatal(err)
+		if err := IsValidDocName(f); err != nil {
+			fatal(exitUsage, err)
 		}
-		failureCount := 0
-		for _, v := range reqs {
-			r, err2 := ParseReq(v)
-			if err2 != nil {
-				log.Printf("Requirement failed to parse: %q\n%s", err2, v)
-				failureCount++
-				continue
-			}
+		parsed, errs := docParser(f).ParseDoc(f)
+		for _, err2 := range errs {
+			log.Printf("Requirement failed to parse: %q", err2)
+		}
+		failureCount := len(errs)
+		for _, r := range parsed {
 			body := make([]string, 0)
 			lines := strings.Split(string(r.Body), "\n")
 			for _, line := range lines {
@@ -283,15 +1410,351 @@ func main() {
 				}
 				body = append(body, line)
 			}
+			if len(body) == 0 {
+				body = append(body, "")
+			}
 			fmt.Printf("Requirement %s %s\n%s…\n\n", r.ID, r.Title, body[0])
 		}
 		if failureCount > 0 {
-			log.Fatalf("Requirements failed to parse: %d", failureCount)
+			fatalf(exitParse, "Requirements failed to parse: %d", failureCount)
+		}
+	case "fmt":
+		if strings.ToLower(path.Ext(f)) != ".toml" {
+			fatalf(exitUsage, "Only .toml certification documents can be formatted, got %s", f)
+		}
+		if err := IsValidDocName(f); err != nil {
+			fatal(exitUsage, err)
+		}
+		doc, err := readTomlDoc(f)
+		if err != nil {
+			fatal(exitParse, err)
+		}
+		if _, err := doc.Reqs(); err != nil {
+			fatal(exitParse, err)
+		}
+		o, err := os.Create(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, err := doc.WriteTo(o); err != nil {
+			log.Fatal(err)
+		}
+		if err := o.Close(); err != nil {
+			log.Fatal(err)
+		}
+	case "approvals":
+		approvals, ok := taskmgr.TaskMgr.(taskmgr.ApprovalReader)
+		if !ok || conf.Polarion == nil {
+			fatalf(exitUsage, "No task manager keeping the approvals, e.g. Polarion, configured in %s", *fReportJsonConfPath)
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		fixes, err := rg.ApprovalFixes(conf.Polarion.ApprovalAttributeName(), approvals)
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		if !*fInteractive {
+			for _, f := range fixes {
+				fmt.Printf("%s\n%s", f.Finding, f.Change)
+			}
+			fmt.Printf("%d approvals changed, run with --interactive to set them\n", len(fixes))
+			break
+		}
+		applied := ApplyFixes(fixes, os.Stdin, os.Stdout)
+		fmt.Printf("%d of %d approvals set\n", applied, len(fixes))
+	case "badges":
+		// The badges show the issues rather than failing on them.
+		rg, _, err := buildGraph("")
+		if rg == nil {
+			fatalErr(exitInternal, err)
+		}
+		if err := rg.WriteBadges(f, err == nil); err != nil {
+			log.Fatal(err)
+		}
+	case "archive":
+		format, err := archiveFormat(f)
+		if err != nil {
+			fatal(exitUsage, err)
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		files, err := rg.archiveFiles(git.RepoPath(), conf.Archive, conf.riskConf())
+		if err != nil {
+			log.Fatal(err)
+		}
+		commit, err := git.HeadCommit()
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		o, err := os.Create(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeArchive(o, format, commit, time.Now(), files); err != nil {
+			log.Fatal(err)
+		}
+		if err := o.Close(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d files archived in %s\n", len(files)+1, f) // with the manifest
+		for _, c := range conf.webhooks() {
+			if !c.posts(eventBaselined) {
+				continue
+			}
+			secret, err := webhookSecret()
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			if err := c.PostEvents(secret, commit, time.Now(), []LifecycleEvent{{Kind: eventBaselined, Baseline: filepath.Base(f)}}); err != nil {
+				fatal(exitIntegration, err)
+			}
+		}
+	case "baseline":
+		if f == "compare" {
+			names := moreArgs
+			if len(names) < 2 {
+				fatal(exitUsage, "Missing baselines to compare")
+			}
+			from, err := loadBaseline(names[0])
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			to, err := loadBaseline(names[1])
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			c, err := CompareBaselines(from, to)
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			c.Write(os.Stdout)
+			break
+		}
+		if f != "create" {
+			fatalf(exitUsage, "Unknown baseline command '%s', expected create or compare", f)
+		}
+		name, output := "", ""
+		if len(moreArgs) > 0 {
+			name = moreArgs[0]
+		}
+		if err := validBaselineName(name); err != nil {
+			fatal(exitUsage, err)
+		}
+		if len(moreArgs) > 1 {
+			output = moreArgs[1]
+		}
+		if output == "" {
+			output = name + baselineArtifactSuffix
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		b, err := rg.CreateBaseline(name, output)
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		fmt.Printf("Baseline %s of commit %s tagged %s, %d files identified in %s\n", name, b.Commit, b.Tag, len(b.Files), output)
+		notifyWebhooks(conf.webhooks(), []LifecycleEvent{{Kind: eventBaselined, Baseline: name}})
+	case "notify":
+		if len(conf.webhooks()) == 0 {
+			fatalf(exitUsage, "No webhook url configured in %s", *fReportJsonConfPath)
+		}
+		if *since == "" {
+			fatal(exitUsage, "Missing --since snapshot")
+		}
+		secret, err := webhookSecret()
+		if err != nil {
+			fatal(exitUsage, err)
+		}
+		old, err := loadSnapshot(*since)
+		if err != nil {
+			fatal(exitParse, err)
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		commit, err := git.HeadCommit()
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		for _, c := range conf.webhooks() {
+			events, err := rg.LifecycleEvents(old, c)
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			if err := c.PostEvents(secret, commit, time.Now(), events); err != nil {
+				fatal(exitIntegration, err)
+			}
+			fmt.Printf("%d events posted to %s\n", len(events), c.URL)
+		}
+	case "confluence":
+		if confErr != nil {
+			fatal(exitUsage, confErr)
+		}
+		if conf.Confluence == nil || conf.Confluence.URL == "" || conf.Confluence.Space == "" {
+			fatalf(exitUsage, "No Confluence url and space configured in %s", *fReportJsonConfPath)
+		}
+		pages, err := conf.Confluence.confluenceClient().SpacePages(conf.Confluence.Space)
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		dir := conf.Confluence.Dir
+		if dir == "" {
+			dir = defaultConfluenceDir
+		}
+		updated, unchanged, err := importConfluencePages(pages, filepath.Join(git.RepoPath(), *fCertdocPath, dir))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, f := range updated {
+			fmt.Println("Imported", f)
+		}
+		fmt.Printf("%d documents imported, %d unchanged\n", len(updated), len(unchanged))
+		if _, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...); err != nil {
+			fatalErr(exitInternal, err)
+		}
+	case "coverage":
+		rg, _, err := buildGraph("")
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		rg.ReportCoverage(os.Stdout)
+		if gaps, _ := rg.CoverageGaps(); len(gaps) > 0 {
+			fatalf(exitFindings, "%d requirements not implemented or verified", len(gaps))
+		}
+	case "impact":
+		if f == "" && *since == "" {
+			fatal(exitUsage, "Missing requirement ID or --since")
+		}
+		rg, _, err := buildGraph("")
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		var changed []*Req
+		if f != "" {
+			r := rg[f]
+			if r == nil || r.IsDeleted() {
+				fatalf(exitUsage, "Unknown requirement %s", f)
+			}
+			changed = append(changed, r)
+		}
+		if *since != "" {
+			prg, dir, err := buildGraph(*since)
+			defer os.RemoveAll(dir)
+			if prg == nil {
+				fatalErr(exitIntegration, err)
+			}
+			files, deleted, err := git.FilesChanged(*since)
+			if err != nil {
+				fatalErr(exitIntegration, err)
+			}
+			changed = append(changed, rg.changedSince(prg, append(files, deleted...))...)
+		}
+		rg.Impact(changed).Write(os.Stdout)
+	case "metrics":
+		rg, _, err := buildGraph("")
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		if err := rg.WriteMetrics(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	case "gaps":
+		gaps, err := FindGoGaps(git.RepoPath(), *fCodePath, conf.Generated, *fStrict)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, g := range gaps {
+			fmt.Println(g)
+		}
+		fmt.Printf("%d exported functions without @llr annotation\n", len(gaps))
+		if *fStrict && len(gaps) > 0 {
+			exit(exitFindings)
+		}
+	case "gocover":
+		if f == "" {
+			fatal(exitUsage, "Missing coverage profile")
+		}
+		r, err := os.Open(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		profile, err := parseCoverProfile(r)
+		r.Close()
+		if err != nil {
+			fatalf(exitParse, "Error parsing %s: %v", f, err)
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		unexecuted := rg.UnexecutedLLRs(profile)
+		for _, u := range unexecuted {
+			log.Println(u)
+		}
+		if len(unexecuted) > 0 {
+			fatalf(exitFindings, "%d low-level requirements never executed", len(unexecuted))
+		}
+	case "grep":
+		if f == "" {
+			fatal(exitUsage, "Missing pattern")
+		}
+		re, err := regexp.Compile(f)
+		if err != nil {
+			fatalf(exitUsage, "Invalid pattern: %v", err)
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			// The requirements which parsed can still be searched.
+			log.Println(err)
+		}
+		results, err := rg.Grep(re)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range results {
+			fmt.Println(r.Req.ID, r.Req.Title)
+			for _, m := range r.Matches {
+				fmt.Println("\t" + m.String())
+			}
+		}
+		fmt.Printf("%d requirements matching\n", len(results))
+	case "lcov":
+		traces := conf.Lcov
+		if f != "" {
+			path, err := filepath.Abs(f)
+			if err != nil {
+				log.Fatal(err)
+			}
+			traces = append(traces, path)
+		}
+		if len(traces) == 0 {
+			fatal(exitUsage, "Missing lcov trace file")
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		if err := rg.MergeLcov(traces); err != nil {
+			fatalErr(exitInternal, err)
+		}
+		uncovered := rg.UncoveredCode()
+		for _, u := range uncovered {
+			log.Println(u)
+		}
+		if len(uncovered) > 0 {
+			fatalf(exitFindings, "%d code files or functions traced but not covered", len(uncovered))
 		}
 	case "linkify":
 		output := flag.Arg(1)
 		if output == "" {
-			log.Fatal("Missing output file name")
+			fatal(exitUsage, "Missing output file name")
 		}
 		o, err := os.Create(output)
 		if err != nil {
@@ -300,18 +1763,57 @@ func main() {
 		_, err = ParseLyx(f, o)
 
 		if err != nil {
+			fatal(exitParse, err)
+		}
+	case "report":
+		commit := *at
+		if commit == "" {
+			if commit, err = git.HeadCommit(); err != nil {
+				log.Println(err)
+			}
+		}
+		o, err := os.Create(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(o.Name())
+		if err := rg.ReportStandalone(o, commit, time.Now()); err != nil {
 			log.Fatal(err)
 		}
+		closeReport(o)
 	case "reportdown":
+		if *fSplit {
+			if *fAudience != "" || *fPDF {
+				fatal(exitUsage, "--split doesn't apply with --audience or --pdf")
+			}
+			dir := *fReportPrefix + "down"
+			logFileCreate(filepath.Join(dir, splitIndex))
+			if err := rg.ReportSplit(dir, filter, diffs, *fPageSize); err != nil {
+				log.Fatal(err)
+			}
+			break
+		}
+		reportDown := rg.ReportDown
+		reportDownFiltered := rg.ReportDownFiltered
+		if *fAudience != "" {
+			a, err := conf.audienceConf(*fAudience)
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			reportDown = func(w io.Writer) error { return rg.ReportAudience(w, *fAudience, a, nil, nil) }
+			reportDownFiltered = func(w io.Writer, f ReqFilter, diffs map[string][]string) error {
+				return rg.ReportAudience(w, *fAudience, a, f, diffs)
+			}
+		}
 		of, err := os.Create(*fReportPrefix + "down.html")
 		if err != nil {
 			log.Fatal(err)
 		}
 		logFileCreate(of.Name())
-		if err := rg.ReportDown(of); err != nil {
+		if err := reportDown(of); err != nil {
 			log.Fatal(err)
 		}
-		of.Close()
+		closeReport(of)
 
 		if len(filter) > 0 || diffs != nil {
 			of, err := os.Create(*fReportPrefix + "down-filtered.html")
@@ -319,10 +1821,10 @@ func main() {
 				log.Fatal(err)
 			}
 			logFileCreate(of.Name())
-			if err := rg.ReportDownFiltered(of, filter, diffs); err != nil {
+			if err := reportDownFiltered(of, filter, diffs); err != nil {
 				log.Fatal(err)
 			}
-			of.Close()
+			closeReport(of)
 		}
 	case "reportup":
 		of, err := os.Create(*fReportPrefix + "up.html")
@@ -333,7 +1835,7 @@ func main() {
 		if err = rg.ReportUp(of); err != nil {
 			log.Fatal(err)
 		}
-		of.Close()
+		closeReport(of)
 
 		if len(filter) > 0 || diffs != nil {
 			of, err := os.Create(*fReportPrefix + "up-filtered.html")
@@ -344,7 +1846,7 @@ func main() {
 			if err := rg.ReportUpFiltered(of, filter, diffs); err != nil {
 				log.Fatal(err)
 			}
-			of.Close()
+			closeReport(of)
 		}
 	case "reportissues":
 		of, err := os.Create(*fReportPrefix + "issues.html")
@@ -355,7 +1857,7 @@ func main() {
 		if err := rg.ReportIssues(of); err != nil {
 			log.Fatal(err)
 		}
-		of.Close()
+		closeReport(of)
 		if len(filter) > 0 || diffs != nil {
 			of, err := os.Create(*fReportPrefix + "issues-filtered.html")
 			if err != nil {
@@ -365,39 +1867,316 @@ func main() {
 			if err := rg.ReportIssuesFiltered(of, filter, diffs); err != nil {
 				log.Fatal(err)
 			}
-			of.Close()
+			closeReport(of)
+		}
+	case "reporthistory":
+		of, err := os.Create(*fReportPrefix + "history.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportHistory(of, conf.historyConf(), filter, diffs); err != nil {
+			log.Fatal(err)
+		}
+		closeReport(of)
+	case "reportderived":
+		of, err := os.Create(*fReportPrefix + "derived.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportDerived(of, conf.derivedConf(), git.RepoPath(), filter, diffs); err != nil {
+			fatal(exitUsage, err)
+		}
+		closeReport(of)
+	case "reportcode":
+		of, err := os.Create(*fReportPrefix + "code.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportCode(of, filter, diffs); err != nil {
+			log.Fatal(err)
+		}
+		closeReport(of)
+	case "reportverification":
+		of, err := os.Create(*fReportPrefix + "verification.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportVerification(of, filter, diffs); err != nil {
+			log.Fatal(err)
+		}
+		closeReport(of)
+	case "reportattributes":
+		of, err := os.Create(*fReportPrefix + "attributes.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportAttributes(of, conf.Attributes, filter, diffs); err != nil {
+			fatal(exitUsage, err)
+		}
+		closeReport(of)
+	case "reportdeleted":
+		of, err := os.Create(*fReportPrefix + "deleted.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportDeletions(of, filter, diffs); err != nil {
+			log.Fatal(err)
+		}
+		closeReport(of)
+	case "reportrisk":
+		of, err := os.Create(*fReportPrefix + "risk.html")
+		if err != nil {
+			log.Fatal(err)
+		}
+		logFileCreate(of.Name())
+		if err := rg.ReportRisk(of, conf.riskConf(), filter, diffs); err != nil {
+			log.Fatal(err)
+		}
+		closeReport(of)
+	case "suggest":
+		// The graph is used even if it has issues, e.g. the requirement has no parents yet.
+		rg, _ := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		suggestions, err := rg.SuggestFor(conf.suggestConf(), f, filepath.Join(git.RepoPath(), *fCodePath))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(suggestions) == 0 {
+			fmt.Println("No suggestions.")
+		}
+		for _, s := range suggestions {
+			linked := ""
+			if s.Linked {
+				linked = " (already linked)"
+			}
+			fmt.Printf("%.2f %s %s%s\n", s.Score, s.ID, s.Req.Title, linked)
 		}
 	case "web":
+		if err := useReportTemplates(*fReportJsonConfPath); err != nil {
+			fatal(exitUsage, err)
+		}
 		err := serve(*addr)
 		if err != nil {
 			log.Fatal(err)
 		}
 	case "precommit":
+		if *fMainBranch != "" {
+			mrg, dir, err := buildGraph(*fMainBranch)
+			os.RemoveAll(dir)
+			if err != nil {
+				fatalErr(exitIntegration, fmt.Errorf("Error while building the graph of the main branch %s: %v", *fMainBranch, err))
+			}
+			externalGraph = mrg
+		}
 		err := precommit(*fCertdocPath, *fCodePath, *fReportJsonConfPath)
+		if code := exitCode(err, exitInternal); code == exitFindings || code == exitParse {
+			notifyWebhooks(conf.webhooks(), []LifecycleEvent{validationFailedEvent(command, err)})
+		}
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+	case "checklinks":
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		cachePath, err := linkCachePath()
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		dead, err := rg.CheckLinks(conf.linksConf(), cachePath)
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		for _, d := range dead {
+			log.Println(d)
+		}
+		if len(dead) > 0 {
+			fatalf(exitFindings, "%d dead links", len(dead))
+		}
+	case "dangling":
+		// The requirements without parents, which break the graph, are listed too.
+		rg, _, err := buildGraph("")
+		if rg == nil {
+			fatalErr(exitInternal, err)
+		}
+		dangling := rg.DanglingRequirements()
+		WriteDangling(os.Stdout, dangling)
+		if len(dangling) > 0 {
+			fatalf(exitFindings, "%d dangling requirements", len(dangling))
+		}
+	case "diff":
+		to := ""
+		if len(moreArgs) > 0 {
+			to = moreArgs[0]
+		}
+		if f == "" || to == "" {
+			fatal(exitUsage, "Missing git refs")
+		}
+		old, dir, err := buildGraph(f)
+		defer os.RemoveAll(dir)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		rg, dir, err := buildGraph(to)
+		defer os.RemoveAll(dir)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		WriteDiff(os.Stdout, f, to, rg.DiffFrom(old))
+	case "graph":
+		if f == "" {
+			fatal(exitUsage, "Missing requirement ID")
+		}
+		format := *fExportFormat
+		if format == "" {
+			format = "mermaid"
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		if err := rg.WriteGraph(format, f, os.Stdout); err != nil {
+			fatal(exitUsage, err)
+		}
+	case "fix":
+		// The graph is fixed because it has issues.
+		rg, _ := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		fixes, err := rg.Fixes(conf.Attributes, *fCodePath)
 		if err != nil {
 			log.Fatal(err)
 		}
+		if !*fInteractive {
+			for _, f := range fixes {
+				fmt.Printf("%s\n%s", f.Finding, f.Change)
+			}
+			fmt.Printf("%d fixes, run with --interactive to apply them\n", len(fixes))
+			break
+		}
+		applied := ApplyFixes(fixes, os.Stdin, os.Stdout)
+		fmt.Printf("%d of %d fixes applied\n", applied, len(fixes))
+	case "quickcheck":
+		if err := QuickCheck(*fCertdocPath, *fCodePath, conf.Attributes, conf.Rules, conf.Derived); err != nil {
+			if code := exitCode(err, exitInternal); code == exitFindings || code == exitParse {
+				notifyWebhooks(conf.webhooks(), []LifecycleEvent{validationFailedEvent(command, err)})
+			}
+			fatalErr(exitInternal, err)
+		}
 	case "prepush":
 		changedReqIds := map[string]bool{}
 		for k := range diffs {
 			changedReqIds[k] = true
 			fmt.Println("Changed requirement ", k)
 		}
-		if err := rg.UpdateTasks(changedReqIds); err != nil {
-			log.Fatal(err)
+		conflicts, events, err := rg.UpdateTasks(changedReqIds, conf.Tags, conf.TaskCalls, *fForce, *fDryRun, *fQueue, os.Stdout)
+		notifyWebhooks(conf.webhooks(), events)
+		if err != nil {
+			fatal(exitIntegration, err)
 		}
-	case "updatetasks": // update all task title/descriptions/attributes based on the requirement documents
-		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath)
+		// Edits in the task manager don't prevent the push.
+		for _, c := range conflicts {
+			log.Printf("Warning: %s, not overwritten", c)
+		}
+	case "export":
+		if err := registerSubmissions(conf.Submissions); err != nil {
+			fatal(exitUsage, err)
+		}
+		if err := registerMatrices(conf.Matrices); err != nil {
+			fatal(exitUsage, err)
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		if (*fRoot != "" || *fDocument != "") && *fExportFormat != "plantuml" {
+			fatal(exitUsage, "--root and --document only apply to the plantuml format")
+		}
+		plantUMLSelection.Root, plantUMLSelection.Document = *fRoot, *fDocument
+		if scope := (Scope{*fDoc, *fUnder}); !scope.IsZero() {
+			if *fExportFormat == "delta" {
+				fatal(exitUsage, "--doc and --under don't apply to the delta format")
+			}
+			ids, err := rg.ScopeIDs(scope)
+			if err != nil {
+				fatal(exitUsage, err)
+			}
+			rg = rg.Scoped(ids)
+		}
+		var old map[string]map[string]interface{}
+		if *fExportFormat == "delta" {
+			if *since == "" {
+				fatal(exitUsage, "Missing --since snapshot for the delta format")
+			}
+			if old, err = loadSnapshot(*since); err != nil {
+				fatal(exitParse, err)
+			}
+		}
+		o, err := os.Create(f)
 		if err != nil {
 			log.Fatal(err)
 		}
-		reqIds := map[string]bool{}
-		for k := range rg {
-			reqIds[k] = true
+		if old != nil {
+			err = rg.WriteDelta(old, o)
+		} else {
+			err = rg.Export(*fExportFormat, o)
+		}
+		if err != nil {
+			log.Fatal(err)
 		}
-		if err := rg.UpdateTasks(reqIds); err != nil {
+		if err := o.Close(); err != nil {
 			log.Fatal(err)
 		}
+	case "updatetasks": // update all task title/descriptions/attributes based on the requirement documents
+		if *fResume {
+			events, err := ResumeTaskSync(conf.TaskCalls)
+			notifyWebhooks(conf.webhooks(), events)
+			if err != nil {
+				fatal(exitIntegration, err)
+			}
+			break
+		}
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			fatalErr(exitInternal, err)
+		}
+		selection := TaskSelection{Scope: Scope{*fDoc, *fUnder}, Filter: filter}
+		if *fTypes != "" {
+			selection.Types = strings.Split(*fTypes, ",")
+		}
+		if *since != "" {
+			prg, dir, err := buildGraph(*since)
+			defer os.RemoveAll(dir)
+			if prg == nil {
+				fatalErr(exitInternal, err)
+			}
+			// Nothing is selected when nothing changed.
+			if selection.Diffs = rg.ChangedSince(prg); selection.Diffs == nil {
+				selection.Diffs = map[string][]string{}
+			}
+		}
+		reqIds, err := rg.SelectTaskIDs(selection)
+		if err != nil {
+			fatal(exitUsage, err)
+		}
+		conflicts, events, err := rg.UpdateTasks(reqIds, conf.Tags, conf.TaskCalls, *fForce, *fDryRun, *fQueue, os.Stdout)
+		notifyWebhooks(conf.webhooks(), events)
+		if err != nil {
+			fatal(exitIntegration, err)
+		}
+		for _, c := range conflicts {
+			log.Print(c)
+		}
+		if len(conflicts) > 0 {
+			fatalf(exitIntegration, "%d tasks not updated, use --force to overwrite them", len(conflicts))
+		}
+	default:
+		fmt.Println(usage)
+		fatalf(exitUsage, "Unknown command '%s'", command)
 	}
 }
 
@@ -405,6 +2184,20 @@ func logFileCreate(fileName string) {
 	log.Print("Creating ", fileName, " (this may take a while)...")
 }
 
+// closeReport closes the HTML report of and, with --pdf, converts it to PDF.
+func closeReport(of *os.File) {
+	if err := of.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if !*fPDF {
+		return
+	}
+	logFileCreate(pdfFileName(of.Name()))
+	if _, err := writePDF(of.Name()); err != nil {
+		fatal(exitIntegration, err)
+	}
+}
+
 func precommit(certdocPath, codePath, reportJsonConfPath string) error {
 	var reportConf JsonConf
 	b, err := ioutil.ReadFile(reportJsonConfPath)
@@ -414,16 +2207,16 @@ func precommit(certdocPath, codePath, reportJsonConfPath string) error {
 		reportConf = JsonConf{}
 	} else {
 		if err := json.Unmarshal(b, &reportConf); err != nil {
-			return fmt.Errorf("Error while parsing attributes: ", err)
+			return withExitCode(exitUsage, fmt.Errorf("Error while parsing attributes: ", err))
 		}
 	}
 
-	rg, err := CreateReqGraph(certdocPath, codePath)
+	rg, err := CreateReqGraph(certdocPath, codePath, reportConf.TestEnv...)
 	if err != nil {
 		return err
 	}
 	errorResult := ""
-	err = rg.checkReqReferences(certdocPath)
+	err = rg.checkReqReferences(git.RepoPath(), certdocPath)
 	if err != nil {
 		errorResult += err.Error()
 	}
@@ -433,17 +2226,52 @@ func precommit(certdocPath, codePath, reportJsonConfPath string) error {
 			errorResult += e.Error()
 		}
 	}
+	errs, err := rg.CheckRules(reportConf.Rules)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	for _, e := range errs {
+		errorResult += e.Error()
+	}
+	if errs, err = rg.CheckDerived(reportConf.Derived, git.RepoPath()); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	for _, e := range errs {
+		errorResult += e.Error()
+	}
+	// The stale mentions in the comments of the code are informational, they don't fail the check.
+	mentions, err := rg.CheckCodeMentions(codePath, reportConf.TestEnv...)
+	if err != nil {
+		return err
+	}
+	for _, m := range mentions {
+		fmt.Println(m)
+	}
+	for _, p := range rg.ExternalParents() {
+		fmt.Println(p)
+	}
 	if errorResult == "" {
 		return nil
 	} else {
-		return fmt.Errorf(errorResult)
+		return graphError(false, errorResult)
 	}
 }
 
 func buildGraph(commit string) (reqGraph, string, error) {
+	conf, err := loadJsonConf(*fReportJsonConfPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
 	if commit == "" {
-		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath)
-		return rg, "", err
+		rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		if err != nil {
+			return rg, "", err
+		}
+		// The test results and the coverage are those of the current requirements and code only.
+		if err := rg.ImportTestResults(conf.TestResults); err != nil {
+			return rg, "", err
+		}
+		return rg, "", rg.MergeLcov(conf.Lcov)
 	}
 
 	cwd, err := os.Getwd()
@@ -452,12 +2280,12 @@ func buildGraph(commit string) (reqGraph, string, error) {
 	}
 	dir, err := git.Clone()
 	if err != nil {
-		return nil, dir, err
+		return nil, dir, withExitCode(exitIntegration, err)
 	}
 	if err = git.Checkout(commit); err != nil {
-		return nil, dir, err
+		return nil, dir, withExitCode(exitIntegration, err)
 	}
-	rg, err := CreateReqGraph(*fCertdocPath, *fCodePath)
+	rg, err := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
 	if err != nil {
 		return nil, dir, err
 	}
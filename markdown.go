@@ -8,6 +8,10 @@ import (
 	"regexp"
 )
 
+func init() {
+	RegisterDocParser(RawDocParserFunc(ParseMarkdown), ".md")
+}
+
 var (
 	// For detecting ATX Headings, see http://spec.commonmark.org/0.27/#atx-headings
 	reATXHeading = regexp.MustCompile(`(?m)^ {0,3}(#{1,6})( +(.*)( #* *)?)?$`)
@@ -0,0 +1,208 @@
+// @llr REQ-0-DDLN-SWL-027
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// MatrixConf defines a traceability matrix, e.g. one promised by the verification plan, as one
+// of the "matrices" in attributes.json. The matrices are exported as CSV by the export command,
+// with their name as format.
+type MatrixConf struct {
+	Name string `json:"name"`
+	// Rows is the type of the requirements having a row, e.g. SWH, CODE for the code files or
+	// TESTENV for the files of the test environments. All the requirements have a row if empty.
+	Rows    string         `json:"rows"`
+	Columns []MatrixColumn `json:"columns"`
+}
+
+// MatrixColumn defines a column of a matrix. Its cells show the field of the requirements reached
+// from the requirement of the row by following the links, separated by commas.
+type MatrixColumn struct {
+	Header string `json:"header"`
+	// Links are followed in turn from the requirement of the row, each one of parents or
+	// children, e.g. [children, children] from a high-level requirement for the code
+	// implementing it. The cells show the requirement of the row itself if empty.
+	Links []string `json:"links"`
+	// Type keeps only the requirements reached of a type, e.g. CODE.
+	Type string `json:"type"`
+	// Field is one of id, title, body, the body sections rationale, acceptance_criteria and notes,
+	// path, document, section, status, attribute:<name>, or one of the evidence records: changelists, the
+	// changes of the code of low-level requirements and the commits with an Implements-Req: trailer,
+	// and tasks.
+	Field string `json:"field"`
+}
+
+// matrixFields return the value of a field of a requirement, as shown in a matrix.
+var matrixFields = map[string]func(r *Req) []string{
+	"id":        func(r *Req) []string { return []string{r.ID} },
+	"title":     func(r *Req) []string { return []string{r.Title} },
+	"body":      func(r *Req) []string { return []string{doorsText(string(r.LoadBody()))} },
+	"rationale": func(r *Req) []string { return []string{doorsText(string(r.Rationale))} },
+	"acceptance_criteria": func(r *Req) []string {
+		return []string{doorsText(string(r.AcceptanceCriteria))}
+	},
+	"notes":    func(r *Req) []string { return []string{doorsText(string(r.Notes))} },
+	"path":     func(r *Req) []string { return []string{r.Path} },
+	"document": func(r *Req) []string { return []string{doorsModule(r.Path)} },
+	"section":  func(r *Req) []string { return []string{r.Section} },
+	"status":   func(r *Req) []string { return []string{r.Status.String()} },
+	"changelists": func(r *Req) []string {
+		cl := r.Changelists()
+		if cl.Unavailable != "" {
+			return []string{"Unavailable: " + cl.Unavailable}
+		}
+		var urls []string
+		for rev, url := range cl.URLs {
+			if url == "" {
+				url = rev
+			}
+			urls = append(urls, url)
+		}
+		return urls
+	},
+	"tasks": func(r *Req) []string {
+		tl := r.Tasklists()
+		if tl.Unavailable != "" {
+			return []string{"Unavailable: " + tl.Unavailable}
+		}
+		var tasks []string
+		for id := range tl.Tasks {
+			tasks = append(tasks, "T"+id)
+		}
+		return tasks
+	},
+}
+
+const matrixAttributePrefix = "attribute:"
+
+// isOfType returns whether r is of the requirement type t, which is CODE for the code files and
+// TESTENV for the files of the test environments.
+func isOfType(r *Req, t string) bool {
+	switch t {
+	case "CODE":
+		return r.Level == config.CODE && !r.TestEnv
+	case "TESTENV":
+		return r.TestEnv
+	}
+	return r.Level != config.CODE && r.ReqType() == t
+}
+
+// validate checks the types, the links and the fields of the matrix.
+func (m MatrixConf) validate() error {
+	isType := func(t string) bool {
+		_, ok := config.ReqTypeToReqLevel[t]
+		return t == "" || t == "CODE" || t == "TESTENV" || ok
+	}
+	if m.Name == "" {
+		return fmt.Errorf("Invalid matrix: no name")
+	}
+	if !isType(m.Rows) {
+		return fmt.Errorf("Invalid matrix %s: unknown requirement type %q", m.Name, m.Rows)
+	}
+	for _, c := range m.Columns {
+		if !isType(c.Type) {
+			return fmt.Errorf("Invalid matrix %s: unknown requirement type %q in column %q", m.Name, c.Type, c.Header)
+		}
+		for _, l := range c.Links {
+			if l != "parents" && l != "children" {
+				return fmt.Errorf("Invalid matrix %s: unknown link %q in column %q, expected parents or children", m.Name, l, c.Header)
+			}
+		}
+		if _, ok := matrixFields[c.Field]; !ok && !strings.HasPrefix(c.Field, matrixAttributePrefix) {
+			return fmt.Errorf("Invalid matrix %s: unknown field %q in column %q", m.Name, c.Field, c.Header)
+		}
+	}
+	return nil
+}
+
+// registerMatrices adds the matrices to the export formats.
+func registerMatrices(matrices []MatrixConf) error {
+	for _, m := range matrices {
+		if err := m.validate(); err != nil {
+			return err
+		}
+		if _, ok := exporters[m.Name]; ok {
+			return fmt.Errorf("Invalid matrix %s: the export format already exists", m.Name)
+		}
+		exporters[m.Name] = m.export
+	}
+	return nil
+}
+
+// export writes the matrix as CSV, with a row per requirement of the rows type, sorted by
+// certification document and by position. The deleted requirements have no row.
+func (m MatrixConf) export(rg reqGraph, w io.Writer) error {
+	var reqs []*Req
+	for _, r := range rg {
+		if r.IsDeleted() || (m.Rows != "" && !isOfType(r, m.Rows)) {
+			continue
+		}
+		reqs = append(reqs, r)
+	}
+	sort.Sort(byModulePosition(reqs))
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(m.Columns))
+	for i, c := range m.Columns {
+		header[i] = c.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range reqs {
+		row := make([]string, len(m.Columns))
+		for i, c := range m.Columns {
+			row[i] = c.cell(r)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// cell returns the content of the column for the row of r.
+func (c MatrixColumn) cell(r *Req) string {
+	reached := []*Req{r}
+	for _, l := range c.Links {
+		seen := map[*Req]bool{}
+		var next []*Req
+		for _, n := range reached {
+			linked := n.Children
+			if l == "parents" {
+				linked = n.Parents
+			}
+			for _, ln := range linked {
+				if !seen[ln] {
+					seen[ln] = true
+					next = append(next, ln)
+				}
+			}
+		}
+		reached = next
+	}
+
+	var values []string
+	for _, n := range reached {
+		if n.IsDeleted() || (c.Type != "" && !isOfType(n, c.Type)) {
+			continue
+		}
+		if strings.HasPrefix(c.Field, matrixAttributePrefix) {
+			if v := n.Attributes[strings.ToUpper(strings.TrimPrefix(c.Field, matrixAttributePrefix))]; v != "" {
+				values = append(values, v)
+			}
+			continue
+		}
+		values = append(values, matrixFields[c.Field](n)...)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatrixConf_export(t *testing.T) {
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 1,
+		Title: "High", Attributes: map[string]string{"VERIFICATION": "Test"}}
+	low1 := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 1, Title: "Low 1"}
+	low2 := &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 2, Title: "Low 2"}
+	deleted := &Req{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 3, Title: "DELETED Low 3"}
+	codeA := &Req{ID: "a.go", Level: config.CODE, Path: "code/a.go"}
+	codeB := &Req{ID: "b.go", Level: config.CODE, Path: "code/b.go"}
+	high.Children = []*Req{low2, deleted, low1}
+	low1.Parents = []*Req{high}
+	low1.Children = []*Req{codeB, codeA}
+	low2.Parents = []*Req{high}
+	low2.Children = []*Req{codeA}
+	codeA.Parents = []*Req{low2, low1}
+	codeB.Parents = []*Req{low1}
+	rg := reqGraph{high.ID: high, low1.ID: low1, low2.ID: low2, deleted.ID: deleted, codeA.Path: codeA, codeB.Path: codeB}
+
+	m := MatrixConf{Name: "hlr", Rows: "SWH", Columns: []MatrixColumn{
+		{Header: "Requirement", Field: "id"},
+		{Header: "Verification", Field: "attribute:Verification"},
+		{Header: "Design", Links: []string{"children"}, Field: "title"},
+		{Header: "Code", Links: []string{"children", "children"}, Type: "CODE", Field: "path"},
+	}}
+	var b bytes.Buffer
+	assert.NoError(t, m.export(rg, &b))
+	assert.Equal(t, `Requirement,Verification,Design,Code
+REQ-0-TEST-SWH-001,Test,"Low 1, Low 2","code/a.go, code/b.go"
+`, b.String())
+
+	m = MatrixConf{Name: "code", Rows: "CODE", Columns: []MatrixColumn{
+		{Header: "File", Field: "id"},
+		{Header: "SWH", Links: []string{"parents", "parents"}, Type: "SWH", Field: "id"},
+		{Header: "Document", Links: []string{"parents"}, Field: "document"},
+	}}
+	b.Reset()
+	assert.NoError(t, m.export(rg, &b))
+	assert.Equal(t, `File,SWH,Document
+a.go,REQ-0-TEST-SWH-001,"0-TEST-212-SDD, 0-TEST-212-SDD"
+b.go,REQ-0-TEST-SWH-001,0-TEST-212-SDD
+`, b.String())
+}
+
+func TestMatrixConf_exportTasks(t *testing.T) {
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{
+		"7": {ID: "7", Title: "REQ-0-TEST-SYS-001: System"},
+	}}
+	taskmgr.TaskMgr = tm
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "System"}
+	rg := reqGraph{sys.ID: sys}
+
+	m := MatrixConf{Name: "tasks", Columns: []MatrixColumn{{Header: "ID", Field: "id"}, {Header: "Tasks", Field: "tasks"}}}
+	var b bytes.Buffer
+	assert.NoError(t, m.export(rg, &b))
+	assert.Equal(t, "ID,Tasks\nREQ-0-TEST-SYS-001,T7\n", b.String())
+
+	tm.err = errors.New("connection refused")
+	b.Reset()
+	assert.NoError(t, m.export(rg, &b))
+	assert.Equal(t, "ID,Tasks\nREQ-0-TEST-SYS-001,Unavailable: connection refused\n", b.String())
+}
+
+func TestRegisterMatrices(t *testing.T) {
+	defer delete(exporters, "hlr")
+	assert.NoError(t, registerMatrices([]MatrixConf{{Name: "hlr", Columns: []MatrixColumn{{Header: "ID", Field: "id"}}}}))
+	assert.Equal(t, []string{"cypher", "delta", "doors", "dot", "hlr", "json", "plantuml", "snapshot", "trace", "xlsx"}, exportFormats())
+
+	assert.EqualError(t, registerMatrices([]MatrixConf{{Name: "doors"}}), "Invalid matrix doors: the export format already exists")
+	assert.EqualError(t, registerMatrices([]MatrixConf{{Columns: []MatrixColumn{{Field: "id"}}}}), "Invalid matrix: no name")
+	assert.EqualError(t, registerMatrices([]MatrixConf{{Name: "m", Rows: "LLR"}}), `Invalid matrix m: unknown requirement type "LLR"`)
+	assert.EqualError(t, registerMatrices([]MatrixConf{{Name: "m", Columns: []MatrixColumn{{Header: "Up", Links: []string{"up"}, Field: "id"}}}}),
+		`Invalid matrix m: unknown link "up" in column "Up", expected parents or children`)
+	assert.EqualError(t, registerMatrices([]MatrixConf{{Name: "m", Columns: []MatrixColumn{{Header: "Owner", Field: "owner"}}}}),
+		`Invalid matrix m: unknown field "owner" in column "Owner"`)
+}
@@ -0,0 +1,205 @@
+// @llr REQ-0-DDLN-SWL-053
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// commentSyntax is the syntax of the comments of a language, and of the string literals in which
+// the comment markers are skipped.
+type commentSyntax struct {
+	// line are the starts of the line comments, e.g. //.
+	line []string
+	// blocks are the starts and the ends of the block comments, which may span several lines,
+	// e.g. /* and */.
+	blocks [][2]string
+	// quotes are the characters delimiting the string and character literals, only taken as such
+	// when closed on the same line, e.g. not the ' of the Verilog sized numbers.
+	quotes string
+	// rawQuotes are the characters delimiting the raw string literals, which may span several
+	// lines, e.g. the back quote of Go.
+	rawQuotes string
+}
+
+// starts returns a function returning the index of the text of the comment of the successive
+// lines of a file, -1 if none.
+func (s commentSyntax) starts() func(line string) int {
+	end := ""    // The end of the block comment being read, if any.
+	var raw byte // The quote of the raw string literal being read, if any.
+	return func(line string) int {
+		start := -1
+		for i := 0; i < len(line); {
+			switch {
+			case end != "":
+				if start < 0 {
+					start = i
+				}
+				j := strings.Index(line[i:], end)
+				if j < 0 {
+					return start
+				}
+				i += j + len(end)
+				end = ""
+			case raw != 0:
+				j := strings.IndexByte(line[i:], raw)
+				if j < 0 {
+					return start
+				}
+				i += j + 1
+				raw = 0
+			default:
+				if m := s.lineComment(line[i:]); m != "" {
+					if start < 0 {
+						start = i + len(m)
+					}
+					return start
+				}
+				if b := s.blockComment(line[i:]); b != nil {
+					i += len(b[0])
+					end = b[1]
+					continue
+				}
+				c := line[i]
+				i++
+				if strings.IndexByte(s.rawQuotes, c) >= 0 {
+					raw = c
+				} else if strings.IndexByte(s.quotes, c) >= 0 {
+					if j := closingQuote(line[i:], c); j >= 0 {
+						i += j + 1
+					}
+				}
+			}
+		}
+		return start
+	}
+}
+
+// lineComment returns the start of the line comment text starts with, empty if none.
+func (s commentSyntax) lineComment(text string) string {
+	for _, m := range s.line {
+		if strings.HasPrefix(text, m) {
+			return m
+		}
+	}
+	return ""
+}
+
+// blockComment returns the start and the end of the block comment text starts with, nil if none.
+func (s commentSyntax) blockComment(text string) *[2]string {
+	for i, b := range s.blocks {
+		if strings.HasPrefix(text, b[0]) {
+			return &s.blocks[i]
+		}
+	}
+	return nil
+}
+
+// closingQuote returns the index of the quote closing the literal text follows, the quotes
+// escaped with a backslash skipped, -1 if it isn't closed.
+func closingQuote(text string, quote byte) int {
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// commentMentions returns the requirement IDs mentioned in the comments of the code, the formal
+// references apart: the lines referencing requirements with an annotation, verifying them or
+// defining them aren't mentions. The mentions are returned by line number.
+func commentMentions(fileName string, r io.Reader, lang *codeLanguage) (map[int][]string, error) {
+	mentions := map[int][]string{}
+	cr, err := codeReader(fileName, r)
+	if err != nil {
+		return nil, err
+	}
+	var defined codeRequirementsReader
+	llrRef := lang.references()
+	commentStart := lang.comments.starts()
+	scanner := bufio.NewScanner(cr)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		formal := llrRef(text) != "" || len(verifiesReferences(text)) > 0
+		if codeRequirements {
+			defined.readLine(line, text)
+			formal = formal || defined.current != nil
+		}
+		if start := commentStart(text); start >= 0 && !formal {
+			if ids := ReReqID.FindAllString(text[start:], -1); len(ids) > 0 {
+				mentions[line] = ids
+			}
+		}
+	}
+	return mentions, scanner.Err()
+}
+
+// CheckCodeMentions returns the mentions, in the comments of the code files under codePath and
+// the testEnvPaths, of requirements which don't exist or are deleted, one per mention. Stale IDs
+// mislead the maintainers even if the formal references are correct, but they're only
+// informational findings.
+func (rg reqGraph) CheckCodeMentions(codePath string, testEnvPaths ...string) ([]string, error) {
+	ignore, err := loadIgnoreRules(git.RepoPath())
+	if err != nil {
+		return nil, err
+	}
+	var findings []string
+	seen := map[string]bool{}
+	for _, p := range append([]string{codePath}, testEnvPaths...) {
+		err := filepath.Walk(filepath.Join(git.RepoPath(), p), ignore.walkFunc(git.RepoPath(), func(fileName string, info os.FileInfo, err error) error {
+			lang := codeFileLanguage(p, fileName)
+			if err != nil || lang == nil || seen[fileName] {
+				return nil
+			}
+			seen[fileName] = true
+			f, err := os.Open(fileName)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			id := relativePathToRepo(fileName, git.RepoPath())
+			mentions, err := commentMentions(fileName, f, lang)
+			if err != nil {
+				return fmt.Errorf("Error reading %s: %v", fileName, err)
+			}
+			findings = append(findings, rg.staleMentions(id, mentions)...)
+			return nil
+		}))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return findings, nil
+}
+
+// staleMentions returns the mentions of the code file of requirements which don't exist or are
+// deleted, in the order of the lines.
+func (rg reqGraph) staleMentions(id string, mentions map[int][]string) []string {
+	lines := make([]int, 0, len(mentions))
+	for l := range mentions {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	var findings []string
+	for _, l := range lines {
+		for _, reqID := range mentions[l] {
+			if r := rg[reqID]; r == nil {
+				findings = append(findings, fmt.Sprintf("Mention of inexistent requirement %s in %s:%d", reqID, id, l))
+			} else if r.IsDeleted() {
+				findings = append(findings, fmt.Sprintf("Mention of deleted requirement %s in %s:%d", reqID, id, l))
+			}
+		}
+	}
+	return findings
+}
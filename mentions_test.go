@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommentMentions(t *testing.T) {
+	// The annotation is split so it is not taken as a reference of this file.
+	src := `package watchdog
+
+// Kick kicks the watchdog, see also REQ-0-TEST-SWL-002 and REQ-0-TEST-SWL-003.
+` + "/" + "/ @" + "llr REQ-0-TEST-SWL-001" + `
+func Kick() {
+	log.Print("// REQ-0-TEST-SWL-004 is in a string")
+	kick() // Was REQ-0-TEST-SWL-005.
+}
+
+/* The timeout of
+ * REQ-0-TEST-SWL-006 */
+`
+	mentions, err := commentMentions("watchdog.go", strings.NewReader(src), codeLanguages[".go"])
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{
+		3:  {"REQ-0-TEST-SWL-002", "REQ-0-TEST-SWL-003"},
+		7:  {"REQ-0-TEST-SWL-005"},
+		11: {"REQ-0-TEST-SWL-006"},
+	}, mentions)
+
+	rg := reqGraph{
+		"REQ-0-TEST-SWL-002": &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW},
+		"REQ-0-TEST-SWL-003": &Req{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Title: "DELETED"},
+		"REQ-0-TEST-SWL-006": &Req{ID: "REQ-0-TEST-SWL-006", Level: config.LOW},
+	}
+	assert.Equal(t, []string{
+		"Mention of deleted requirement REQ-0-TEST-SWL-003 in watchdog.go:3",
+		"Mention of inexistent requirement REQ-0-TEST-SWL-005 in watchdog.go:7",
+	}, rg.staleMentions("watchdog.go", mentions))
+
+	// The comment syntax is that of the language.
+	src = `#include "watchdog.h"
+#define WATCHDOG_REQ "REQ-0-TEST-SWL-007"
+
+static char quote = '"'; ` + "/" + `/ Was REQ-0-TEST-SWL-008.
+
+/*
+ * The timeout of REQ-0-TEST-SWL-009.
+ */
+void kick(int *p) {
+	*p = lookup("REQ-0-TEST-SWL-010");
+}
+`
+	mentions, err = commentMentions("watchdog.c", strings.NewReader(src), codeLanguages[".c"])
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{
+		4: {"REQ-0-TEST-SWL-008"},
+		7: {"REQ-0-TEST-SWL-009"},
+	}, mentions)
+	mentions, err = commentMentions("fifo.v", strings.NewReader("assign ready = 4'b1010; "+"/"+"/ Was REQ-0-TEST-HWL-001.\n"), codeLanguages[".v"])
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{1: {"REQ-0-TEST-HWL-001"}}, mentions)
+	mentions, err = commentMentions("build.sh", strings.NewReader("echo \"# REQ-0-TEST-SWL-011\" # Was REQ-0-TEST-SWL-012.\n"), codeLanguages[".sh"])
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]string{1: {"REQ-0-TEST-SWL-012"}}, mentions)
+}
@@ -0,0 +1,107 @@
+// @llr REQ-0-DDLN-SWL-067
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// graphFormats are the formats of the graph command, writing the neighbourhood of a requirement.
+var graphFormats = map[string]func(rg reqGraph, id string, w io.Writer) error{
+	"mermaid": writeMermaid,
+}
+
+// mermaidClasses are the classes of the nodes of each status in the Mermaid flowcharts, with the
+// colors of the DOT export.
+var mermaidClasses = map[RequirementStatus]string{
+	NOT_STARTED: "notStarted",
+	STARTED:     "started",
+	COMPLETED:   "completed",
+}
+
+// mermaidEscaper escapes the labels of the Mermaid nodes, which may contain HTML.
+var mermaidEscaper = strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+// WriteGraph writes the ancestors and the descendants of the requirement in the given format, see
+// graphFormats.
+func (rg reqGraph) WriteGraph(format, id string, w io.Writer) error {
+	write, ok := graphFormats[format]
+	if !ok {
+		formats := make([]string, 0, len(graphFormats))
+		for f := range graphFormats {
+			formats = append(formats, f)
+		}
+		sort.Strings(formats)
+		return fmt.Errorf("Unknown graph format %q, expected one of: %s", format, strings.Join(formats, ", "))
+	}
+	return write(rg, id, w)
+}
+
+// writeMermaid writes a Mermaid flowchart of the requirement, its ancestors and its descendants,
+// down to the code, to be pasted in Markdown, e.g. in design notes or merge request descriptions:
+// a node per requirement, with the ID and the title, and per code file, colored by status, the
+// requirement outlined, and an arrow from each parent to its children. The deleted requirements
+// are left out.
+func writeMermaid(rg reqGraph, id string, w io.Writer) error {
+	req := rg[id]
+	if req == nil || req.IsDeleted() {
+		return fmt.Errorf("Unknown requirement %s", id)
+	}
+	nodes := map[*Req]bool{}
+	var up, down func(r *Req)
+	up = func(r *Req) {
+		nodes[r] = true
+		for _, p := range r.Parents {
+			if !p.IsDeleted() {
+				up(p)
+			}
+		}
+	}
+	down = func(r *Req) {
+		nodes[r] = true
+		for _, c := range r.Children {
+			if !c.IsDeleted() {
+				down(c)
+			}
+		}
+	}
+	up(req)
+	down(req)
+	reqs := make([]*Req, 0, len(nodes))
+	for r := range nodes {
+		reqs = append(reqs, r)
+	}
+	sort.Sort(byIDOrPath(reqs))
+
+	alias := func(r *Req) string { return rePlantUMLAlias.ReplaceAllString(r.ID, "_") }
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "flowchart TD")
+	for _, r := range reqs {
+		if r.Level == config.CODE {
+			fmt.Fprintf(b, "    %s([\"%s\"]):::%s\n", alias(r), mermaidEscaper.Replace(r.ID), mermaidClasses[r.Status])
+			continue
+		}
+		label := mermaidEscaper.Replace(r.ID)
+		if r.Title != "" {
+			label += "<br/>" + mermaidEscaper.Replace(r.Title)
+		}
+		fmt.Fprintf(b, "    %s[\"%s\"]:::%s\n", alias(r), label, mermaidClasses[r.Status])
+	}
+	for _, r := range reqs {
+		for _, c := range r.Children {
+			if nodes[c] {
+				fmt.Fprintf(b, "    %s --> %s\n", alias(r), alias(c))
+			}
+		}
+	}
+	for _, s := range []RequirementStatus{NOT_STARTED, STARTED, COMPLETED} {
+		fmt.Fprintf(b, "    classDef %s fill:%s\n", mermaidClasses[s], dotColors[s])
+	}
+	fmt.Fprintf(b, "    style %s stroke-width:3px\n", alias(req))
+	return b.Flush()
+}
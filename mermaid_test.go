@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMermaid(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "Fly"}
+	high1 := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: `Keep the "altitude" < 1000m`, ParentIds: []string{sys.ID}}
+	high2 := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "Land", ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Climb", ParentIds: []string{high1.ID}}
+	deleted := &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "DELETED", ParentIds: []string{high1.ID}}
+	code := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", ParentIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high1.ID: high1, high2.ID: high2, low.ID: low, deleted.ID: deleted, code.Path: code}
+	assert.NoError(t, rg.Resolve())
+
+	var w bytes.Buffer
+	assert.NoError(t, rg.WriteGraph("mermaid", high1.ID, &w))
+	// The sibling SWH-002 is neither an ancestor nor a descendant.
+	assert.Equal(t, `flowchart TD
+    REQ_0_TEST_SWH_001["REQ-0-TEST-SWH-001<br/>Keep the #quot;altitude#quot; #lt; 1000m"]:::started
+    REQ_0_TEST_SWL_001["REQ-0-TEST-SWL-001<br/>Climb"]:::completed
+    REQ_0_TEST_SYS_001["REQ-0-TEST-SYS-001<br/>Fly"]:::started
+    code_a_go(["code/a.go"]):::completed
+    REQ_0_TEST_SWH_001 --> REQ_0_TEST_SWL_001
+    REQ_0_TEST_SWL_001 --> code_a_go
+    REQ_0_TEST_SYS_001 --> REQ_0_TEST_SWH_001
+    classDef notStarted fill:#FFC7CE
+    classDef started fill:#FFEB9C
+    classDef completed fill:#C6EFCE
+    style REQ_0_TEST_SWH_001 stroke-width:3px
+`, w.String())
+
+	assert.EqualError(t, rg.WriteGraph("mermaid", deleted.ID, &w), "Unknown requirement REQ-0-TEST-SWL-002")
+	assert.EqualError(t, rg.WriteGraph("dot", high1.ID, &w), `Unknown graph format "dot", expected one of: mermaid`)
+}
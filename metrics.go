@@ -0,0 +1,129 @@
+// @llr REQ-0-DDLN-SWL-069
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// LevelMetrics are the counts of the requirements of a type, e.g. SWL, in a certification
+// document, or in all of them for the totals.
+type LevelMetrics struct {
+	// Document is the name of the certification document, e.g. 0-DDLN-212-SDD, empty for the
+	// totals.
+	Document string
+	// Type is the type of the requirements, e.g. SWL, empty for the totals of all the types.
+	Type         string
+	Level        config.RequirementLevel
+	Requirements int
+	// Statuses are the numbers of requirements by status.
+	Statuses map[RequirementStatus]int
+	// Code is the number of low-level requirements referenced by code, including those only
+	// partially implemented.
+	Code int
+	// Verified is the number of requirements verified by code or test cases, see Badges.
+	Verified int
+}
+
+// add counts the requirement.
+func (m *LevelMetrics) add(r *Req) {
+	m.Requirements++
+	m.Statuses[r.Status]++
+	for _, c := range r.Children {
+		if c.Level == config.CODE {
+			m.Code++
+			break
+		}
+	}
+	if len(r.VerifiedBy) > 0 || len(r.TestCases) > 0 {
+		m.Verified++
+	}
+}
+
+// byDocumentAndLevel sorts the metrics by document, then by level and type.
+type byDocumentAndLevel []*LevelMetrics
+
+func (a byDocumentAndLevel) Len() int      { return len(a) }
+func (a byDocumentAndLevel) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byDocumentAndLevel) Less(i, j int) bool {
+	if a[i].Document != a[j].Document {
+		return a[i].Document < a[j].Document
+	}
+	if a[i].Level != a[j].Level {
+		return a[i].Level < a[j].Level
+	}
+	return a[i].Type < a[j].Type
+}
+
+// Metrics returns the counts of the requirements per certification document and type, sorted, and
+// their totals per type over all the documents, then over all the types. The code files and the
+// deleted requirements aren't counted.
+func (rg reqGraph) Metrics() (perDocument, totals []*LevelMetrics) {
+	docs := map[string]*LevelMetrics{}
+	types := map[string]*LevelMetrics{}
+	all := &LevelMetrics{Statuses: map[RequirementStatus]int{}}
+	for _, r := range rg {
+		if r.Level == config.CODE || r.IsDeleted() {
+			continue
+		}
+		doc := strings.TrimSuffix(filepath.Base(r.Path), filepath.Ext(r.Path))
+		key := doc + "\x00" + r.ReqType()
+		if docs[key] == nil {
+			docs[key] = &LevelMetrics{Document: doc, Type: r.ReqType(), Level: r.Level, Statuses: map[RequirementStatus]int{}}
+			perDocument = append(perDocument, docs[key])
+		}
+		if types[r.ReqType()] == nil {
+			types[r.ReqType()] = &LevelMetrics{Type: r.ReqType(), Level: r.Level, Statuses: map[RequirementStatus]int{}}
+			totals = append(totals, types[r.ReqType()])
+		}
+		docs[key].add(r)
+		types[r.ReqType()].add(r)
+		all.add(r)
+	}
+	sort.Sort(byDocumentAndLevel(perDocument))
+	sort.Sort(byDocumentAndLevel(totals))
+	return perDocument, append(totals, all)
+}
+
+// WriteMetrics writes the metrics as a table, a row per certification document and type then the
+// totals, with the numbers of requirements by status, of low-level requirements referenced by code
+// and of requirements verified, and their shares of the requirements of the row.
+func (rg reqGraph) WriteMetrics(w io.Writer) error {
+	perDocument, totals := rg.Metrics()
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOCUMENT\tTYPE\tREQUIREMENTS\tNOT STARTED\tSTARTED\tCOMPLETED\tWITH CODE\tVERIFIED")
+	for _, m := range append(perDocument, totals...) {
+		doc, typ := m.Document, m.Type
+		if doc == "" {
+			doc = "Total"
+		}
+		if typ == "" {
+			typ = "all"
+		}
+		code := "-"
+		if m.Level == config.LOW {
+			code = metricsShare(m.Code, m.Requirements)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", doc, typ, m.Requirements,
+			metricsShare(m.Statuses[NOT_STARTED], m.Requirements),
+			metricsShare(m.Statuses[STARTED], m.Requirements),
+			metricsShare(m.Statuses[COMPLETED], m.Requirements),
+			code, metricsShare(m.Verified, m.Requirements))
+	}
+	return tw.Flush()
+}
+
+// metricsShare returns n and its share of total, e.g. "3 (75%)", the share rounded down as in the
+// badges.
+func metricsShare(n, total int) string {
+	if total == 0 {
+		return fmt.Sprintf("%d", n)
+	}
+	return fmt.Sprintf("%d (%d%%)", n, n*100/total)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_Metrics(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Path: "certdocs/0-TEST-100-ORD.md", Level: config.SYSTEM}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Path: "certdocs/0-TEST-211-SRD.md", Level: config.HIGH, ParentIds: []string{sys.ID}}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	for i, id := range []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002", "REQ-0-TEST-SWL-003", "REQ-0-TEST-SWL-004"} {
+		rg[id] = &Req{ID: id, Path: "certdocs/0-TEST-212-SDD.md", Level: config.LOW, ParentIds: []string{high.ID}}
+		if i < 3 {
+			rg["code/"+id+".go"] = &Req{ID: id + ".go", Path: "code/" + id + ".go", Level: config.CODE, ParentIds: []string{id}}
+		}
+		if i < 1 {
+			rg["code/"+id+"_test.go"] = &Req{ID: id + "_test.go", Path: "code/" + id + "_test.go", Level: config.CODE, VerifiesIds: []string{id}}
+		}
+	}
+	rg["REQ-0-TEST-SWL-005"] = &Req{ID: "REQ-0-TEST-SWL-005", Path: "certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "DELETED", ParentIds: []string{high.ID}}
+	assert.NoError(t, rg.Resolve())
+
+	perDocument, totals := rg.Metrics()
+	assert.Len(t, perDocument, 3)
+	assert.Len(t, totals, 4)
+	llrs := perDocument[2]
+	assert.Equal(t, "0-TEST-212-SDD", llrs.Document)
+	assert.Equal(t, "SWL", llrs.Type)
+	assert.Equal(t, 4, llrs.Requirements)
+	assert.Equal(t, map[RequirementStatus]int{COMPLETED: 3, NOT_STARTED: 1}, llrs.Statuses)
+	assert.Equal(t, 3, llrs.Code)
+	assert.Equal(t, 1, llrs.Verified)
+	all := totals[3]
+	assert.Equal(t, "", all.Type)
+	assert.Equal(t, 6, all.Requirements)
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.WriteMetrics(&b))
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(t, lines, 8)
+	assert.Equal(t, []string{"DOCUMENT", "TYPE", "REQUIREMENTS", "NOT", "STARTED", "STARTED", "COMPLETED", "WITH", "CODE", "VERIFIED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"0-TEST-100-ORD", "SYS", "1", "0", "(0%)", "1", "(100%)", "0", "(0%)", "-", "0", "(0%)"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"0-TEST-212-SDD", "SWL", "4", "1", "(25%)", "0", "(0%)", "3", "(75%)", "3", "(75%)", "1", "(25%)"}, strings.Fields(lines[3]))
+	assert.Equal(t, []string{"Total", "all", "6", "1", "(16%)", "2", "(33%)", "3", "(50%)", "-", "1", "(16%)"}, strings.Fields(lines[7]))
+}
@@ -0,0 +1,222 @@
+// @llr REQ-0-DDLN-SWL-050
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// docHeadings find the headings of the certification documents, by extension, for the section
+// numbers of their requirements. Each returns a function returning the level of the heading
+// starting at the successive lines of a document, 0 if none, and whether the line is part of a
+// heading. The documents of the other formats, e.g. .toml or .odt, have no section numbers.
+var docHeadings = map[string]func() func(line string) (level int, inHeading bool){
+	".htm":  htmlHeadings,
+	".html": htmlHeadings,
+	".lyx":  lyxHeadings,
+	".md":   markdownHeadings,
+	".org":  orgHeadings,
+}
+
+// markdownHeadings finds the ATX headings, skipping the fenced code blocks.
+func markdownHeadings() func(line string) (int, bool) {
+	fence := ""
+	return func(line string) (int, bool) {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			return 0, false
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			return 0, false
+		}
+		if parts := reATXHeading.FindStringSubmatch(line); parts != nil {
+			return len(parts[1]), true
+		}
+		return 0, false
+	}
+}
+
+// lyxLayoutLevels are the levels of the numbered sectioning layouts of LyX, the starred ones
+// being unnumbered.
+var lyxLayoutLevels = map[string]int{
+	"Chapter":       1,
+	"Section":       2,
+	"Subsection":    3,
+	"Subsubsection": 4,
+	"Paragraph":     5,
+	"Subparagraph":  6,
+}
+
+// lyxHeadings finds the sectioning layouts, whose text is on the lines up to their \end_layout,
+// which may close nested layouts first.
+func lyxHeadings() func(line string) (int, bool) {
+	depth := 0
+	return func(line string) (int, bool) {
+		switch {
+		case strings.HasPrefix(line, `\begin_layout `):
+			if depth > 0 {
+				depth++
+				return 0, true
+			}
+			level := lyxLayoutLevels[strings.TrimSpace(strings.TrimPrefix(line, `\begin_layout `))]
+			if level > 0 {
+				depth = 1
+			}
+			return level, level > 0
+		case strings.HasPrefix(line, `\end_layout`) && depth > 0:
+			depth--
+			return 0, true
+		}
+		return 0, depth > 0
+	}
+}
+
+// orgHeadings finds the headings, whose level is their number of stars.
+func orgHeadings() func(line string) (int, bool) {
+	return func(line string) (int, bool) {
+		if parts := reOrgHeading.FindStringSubmatch(line); parts != nil {
+			return len(parts[1]), true
+		}
+		return 0, false
+	}
+}
+
+var reHTMLHeadingTag = regexp.MustCompile(`(?i)<h([1-6])\b`)
+
+// htmlHeadings finds the h1-h6 headings, one per line at most.
+func htmlHeadings() func(line string) (int, bool) {
+	return func(line string) (int, bool) {
+		if parts := reHTMLHeadingTag.FindStringSubmatch(line); parts != nil {
+			level, _ := strconv.Atoi(parts[1])
+			return level, true
+		}
+		return 0, false
+	}
+}
+
+// docHeading is a heading of a certification document, with its section number.
+type docHeading struct {
+	level  int
+	number string
+}
+
+// numberHeadings sets the section numbers of the headings, in document order, as 1, 1.1, 1.2, 2...
+// The levels skipped, e.g. a level 4 heading right below a level 2 one, aren't numbered. A first
+// heading which is the only one of the top level is the title of the document, and isn't
+// numbered either.
+func numberHeadings(headings []docHeading) {
+	if len(headings) == 0 {
+		return
+	}
+	top, nTop := headings[0].level, 0
+	for _, h := range headings {
+		if h.level < top {
+			top = h.level
+		}
+	}
+	for _, h := range headings {
+		if h.level == top {
+			nTop++
+		}
+	}
+	first := 0
+	if headings[0].level == top && nTop == 1 && len(headings) > 1 {
+		first = 1
+	}
+	type counter struct{ level, n int }
+	var stack []counter
+	for i := first; i < len(headings); i++ {
+		h := &headings[i]
+		for len(stack) > 0 && stack[len(stack)-1].level > h.level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 && stack[len(stack)-1].level == h.level {
+			stack[len(stack)-1].n++
+		} else {
+			stack = append(stack, counter{h.level, 1})
+		}
+		numbers := make([]string, len(stack))
+		for j, c := range stack {
+			numbers[j] = strconv.Itoa(c.n)
+		}
+		h.number = strings.Join(numbers, ".")
+	}
+}
+
+// setSections sets the sections of the requirements of the certification document, the numbers of
+// the headings they are in, or of their own headings, e.g. "3.2.4". A requirement is taken to be
+// at the first heading holding its ID, or else where its ID first appears. The sections are left
+// empty if the format has no headings.
+func setSections(fileName string, reqs []*Req) error {
+	headingOf, ok := docHeadings[strings.ToLower(filepath.Ext(fileName))]
+	if !ok || len(reqs) == 0 {
+		return nil
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ids := map[string]bool{}
+	for _, r := range reqs {
+		ids[r.ID] = true
+	}
+	var headings []docHeading
+	// reqHeadings are the index of the heading of each requirement, -1 if none, and onHeading
+	// whether its ID was found on the heading itself.
+	reqHeadings := map[string]int{}
+	onHeading := map[string]bool{}
+	heading := headingOf()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		level, inHeading := heading(scanner.Text())
+		if level > 0 {
+			headings = append(headings, docHeading{level: level})
+		}
+		for _, id := range ReReqID.FindAllString(scanner.Text(), -1) {
+			// The heading of a requirement wins over the references to it before its definition.
+			if _, seen := reqHeadings[id]; ids[id] && (!seen || (inHeading && !onHeading[id])) {
+				reqHeadings[id] = len(headings) - 1
+				onHeading[id] = inHeading
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	numberHeadings(headings)
+	for _, r := range reqs {
+		if i, ok := reqHeadings[r.ID]; ok && i >= 0 {
+			r.Section = headings[i].number
+		}
+	}
+	return nil
+}
+
+// Location returns the path of the certification document of the requirement, with its section
+// if known, e.g. "/certdocs/0-DDLN-212-SDD.md section 3.2".
+func (r *Req) Location() string {
+	if r.Section == "" {
+		return r.Path
+	}
+	return r.Path + " section " + r.Section
+}
+
+// taskDescription returns the description of the task of the requirement, its body after the
+// section of its certification document, if known, so the task cites where the requirement lives.
+func (r *Req) taskDescription() string {
+	if r.Section == "" {
+		return string(r.LoadBody())
+	}
+	return "Section " + r.Section + " of " + filepath.Base(r.Path) + ".\n\n" + string(r.LoadBody())
+}
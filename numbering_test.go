@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberHeadings(t *testing.T) {
+	numbers := func(levels ...int) []string {
+		var headings []docHeading
+		for _, l := range levels {
+			headings = append(headings, docHeading{level: l})
+		}
+		numberHeadings(headings)
+		var numbers []string
+		for _, h := range headings {
+			numbers = append(numbers, h.number)
+		}
+		return numbers
+	}
+	assert.Equal(t, []string{"1", "1.1", "1.2", "1.2.1", "2", "2.1"}, numbers(1, 2, 2, 3, 1, 2))
+	// A single top level heading at the start is the title.
+	assert.Equal(t, []string{"", "1", "2", "2.1"}, numbers(1, 2, 2, 3))
+	// The levels skipped aren't numbered.
+	assert.Equal(t, []string{"1", "1.1", "1.2", "2"}, numbers(2, 4, 4, 2))
+	assert.Equal(t, []string{"1"}, numbers(3))
+}
+
+func TestSetSections(t *testing.T) {
+	dir, err := ioutil.TempDir("", "numbering")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sections := func(name, content string) map[string]string {
+		fileName := filepath.Join(dir, name)
+		assert.NoError(t, ioutil.WriteFile(fileName, []byte(content), 0644))
+		reqs := []*Req{{ID: "REQ-0-TEST-SWH-001"}, {ID: "REQ-0-TEST-SWH-002"}, {ID: "REQ-0-TEST-SWH-003"}}
+		assert.NoError(t, setSections(fileName, reqs))
+		s := map[string]string{}
+		for _, r := range reqs {
+			s[r.ID] = r.Section
+		}
+		return s
+	}
+
+	assert.Equal(t, map[string]string{"REQ-0-TEST-SWH-001": "1.1", "REQ-0-TEST-SWH-002": "2", "REQ-0-TEST-SWH-003": ""},
+		sections("0-TEST-211-SRD.md", `# Title
+## Introduction
+### REQ-0-TEST-SWH-001 First
+Not to be confused with REQ-0-TEST-SWH-002.
+`+"```"+`
+# not a heading
+`+"```"+`
+## REQ-0-TEST-SWH-002 Second
+`))
+
+	assert.Equal(t, map[string]string{"REQ-0-TEST-SWH-001": "1", "REQ-0-TEST-SWH-002": "1.1", "REQ-0-TEST-SWH-003": "2"},
+		sections("0-TEST-211-SRD.org", `* Requirements
+REQ-0-TEST-SWH-001 is below.
+** REQ-0-TEST-SWH-002 Second
+* Other
+REQ-0-TEST-SWH-003 Third
+`))
+
+	assert.Equal(t, map[string]string{"REQ-0-TEST-SWH-001": "1.1", "REQ-0-TEST-SWH-002": "2", "REQ-0-TEST-SWH-003": ""},
+		sections("0-TEST-211-SRD.lyx", `\begin_layout Section
+Requirements
+\end_layout
+\begin_layout Subsection
+\begin_inset Flex Custom
+\begin_layout Plain Layout
+\end_layout
+\end_inset
+REQ-0-TEST-SWH-001 First
+\end_layout
+\begin_layout Section*
+Unnumbered
+\end_layout
+\begin_layout Section
+REQ-0-TEST-SWH-002 Second
+\end_layout
+`))
+
+	// The documents of the other formats have no section numbers.
+	assert.Equal(t, map[string]string{"REQ-0-TEST-SWH-001": "", "REQ-0-TEST-SWH-002": "", "REQ-0-TEST-SWH-003": ""},
+		sections("0-TEST-211-SRD.toml", "[REQ-0-TEST-SWH-001]\n"))
+}
+
+func TestReq_Location(t *testing.T) {
+	r := &Req{ID: "REQ-0-TEST-SWH-001", Path: "certdocs/0-TEST-211-SRD.md", Body: "The RMT SHALL ..."}
+	assert.Equal(t, "certdocs/0-TEST-211-SRD.md", r.Location())
+	assert.Equal(t, "The RMT SHALL ...", r.taskDescription())
+	r.Section = "3.2"
+	assert.Equal(t, "certdocs/0-TEST-211-SRD.md section 3.2", r.Location())
+	assert.Equal(t, "Section 3.2 of 0-TEST-211-SRD.md.\n\nThe RMT SHALL ...", r.taskDescription())
+}
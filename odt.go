@@ -0,0 +1,144 @@
+// @llr REQ-0-DDLN-SWL-001
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterDocParser(DocParserFunc(ParseODTCertdoc), ".odt")
+}
+
+// .odt certification documents, e.g. written with LibreOffice, are converted to HTML and parsed
+// as .html documents: each heading starting with a requirement ID defines a requirement, and
+// the attributes start at the first paragraph, list item or table cell starting with an
+// attribute name. Each paragraph, heading, list item or table row of the document is converted
+// on its own line, so the line numbers in the errors count them.
+
+const (
+	odtTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odtTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odtOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odtXlinkNS  = "http://www.w3.org/1999/xlink"
+)
+
+// ParseODTCertdoc parses the .odt certification document f and returns its requirements, in
+// document order.
+func ParseODTCertdoc(f string) ([]*Req, error) {
+	zr, err := zip.OpenReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	for _, zf := range zr.File {
+		if zf.Name != "content.xml" {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		b, err := odtContentToHTML(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content.xml: %v", err)
+		}
+		return parseHTMLReqs(b)
+	}
+	return nil, fmt.Errorf("no content.xml found, not an OpenDocument file")
+}
+
+// odtContentToHTML converts the text of the content.xml of an OpenDocument file to HTML.
+func odtContentToHTML(r io.Reader) ([]byte, error) {
+	var (
+		out    bytes.Buffer
+		closes []string // closing tags of the open elements
+		inText bool     // within office:text
+	)
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == odtOfficeNS && t.Name.Local == "text" {
+				inText = true
+			}
+			open, close := "", ""
+			switch t.Name.Space + " " + t.Name.Local {
+			case odtTextNS + " h":
+				level := 1
+				if l, err := strconv.Atoi(odtAttr(t, odtTextNS, "outline-level")); err == nil && l > 1 {
+					level = l
+				}
+				if level > 6 {
+					level = 6
+				}
+				open, close = fmt.Sprintf("<h%d>", level), fmt.Sprintf("</h%d>\n", level)
+			case odtTextNS + " p":
+				open, close = "<p>", "</p>\n"
+			case odtTextNS + " list":
+				open, close = "<ul>\n", "</ul>\n"
+			case odtTextNS + " list-item":
+				open, close = "<li>", "</li>\n"
+			case odtTextNS + " a":
+				open, close = `<a href="`+html.EscapeString(odtAttr(t, odtXlinkNS, "href"))+`">`, "</a>"
+			case odtTextNS + " s":
+				n, err := strconv.Atoi(odtAttr(t, odtTextNS, "c"))
+				if err != nil || n < 1 {
+					n = 1
+				}
+				open = strings.Repeat(" ", n)
+			case odtTextNS + " tab":
+				open = "\t"
+			case odtTextNS + " line-break":
+				open = "<br>"
+			case odtTableNS + " table":
+				open, close = "<table>\n", "</table>\n"
+			case odtTableNS + " table-row":
+				open, close = "<tr>", "</tr>\n"
+			case odtTableNS + " table-cell":
+				open, close = "<td>", "</td>"
+			}
+			if inText {
+				out.WriteString(open)
+			}
+			closes = append(closes, close)
+		case xml.EndElement:
+			if t.Name.Space == odtOfficeNS && t.Name.Local == "text" {
+				inText = false
+			}
+			if len(closes) > 0 {
+				if inText {
+					out.WriteString(closes[len(closes)-1])
+				}
+				closes = closes[:len(closes)-1]
+			}
+		case xml.CharData:
+			if inText {
+				out.WriteString(html.EscapeString(string(t)))
+			}
+		}
+	}
+}
+
+func odtAttr(t xml.StartElement, space, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
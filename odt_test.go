@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOdtContentToHTML(t *testing.T) {
+	b, err := odtContentToHTML(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	xmlns:xlink="http://www.w3.org/1999/xlink">
+<office:automatic-styles><text:p>Not text</text:p></office:automatic-styles>
+<office:body><office:text><text:h text:outline-level="3">REQ-0-TEST-SWH-001 A &amp; B</text:h><text:p>Two<text:s text:c="2"/>spaces,<text:tab/>a <text:span>span</text:span> and <text:a xlink:href="https://x?a&amp;b">a link</text:a>.</text:p><table:table><table:table-row><table:table-cell><text:p>Cell</text:p></table:table-cell></table:table-row></table:table><text:p>Rationale: R<text:line-break/>Parents: REQ-0-TEST-SYS-001</text:p><text:h text:outline-level="9">Deep</text:h></office:text></office:body>
+</office:document-content>`))
+	assert.NoError(t, err)
+	assert.Equal(t, `<h3>REQ-0-TEST-SWH-001 A &amp; B</h3>
+<p>Two  spaces,	a span and <a href="https://x?a&amp;b">a link</a>.</p>
+<table>
+<tr><td><p>Cell</p>
+</td></tr>
+</table>
+<p>Rationale: R<br>Parents: REQ-0-TEST-SYS-001</p>
+<h6>Deep</h6>
+`, string(b))
+
+	reqs, err := parseHTMLReqs(b)
+	if assert.NoError(t, err) && assert.Len(t, reqs, 1) {
+		assert.Equal(t, "A & B", reqs[0].Title)
+		assert.Equal(t, map[string]string{"RATIONALE": "R", "PARENTS": "REQ-0-TEST-SYS-001"}, reqs[0].Attributes)
+	}
+
+	_, err = ParseODTCertdoc("testdata/valid_system_requirement/123-TEST-100-ORD.md")
+	assert.Error(t, err)
+}
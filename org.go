@@ -0,0 +1,174 @@
+// @llr REQ-0-DDLN-SWL-001
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+func init() {
+	RegisterDocParser(DocParserFunc(ParseOrgCertdoc), ".org")
+}
+
+// In .org certification documents each heading starting with a requirement ID defines a
+// requirement, for example:
+//
+//	* Requirements
+//	** REQ-0-DDLN-SWH-001 Title
+//	   :PROPERTIES:
+//	   :RATIONALE: ...
+//	   :PARENTS:  REQ-0-DDLN-SYS-001, REQ-0-DDLN-SYS-002
+//	   :SAFETY_IMPACT: None
+//	   :END:
+//	   Body, in org markup.
+//
+// The attributes are the properties of the heading. Since property names can't contain spaces,
+// underscores in the names are replaced by spaces. As for .md documents, the requirement
+// headings of a section must all be at the same level, and the deeper headings are part of the
+// body of the requirement.
+
+var (
+	reOrgHeading  = regexp.MustCompile(`^(\*+)(?:[ \t]+(.*?))?[ \t]*$`)
+	reOrgTags     = regexp.MustCompile(`[ \t]+:[[:alnum:]_@#%:]+:$`)
+	reOrgProperty = regexp.MustCompile(`^[ \t]*:([^:\s]+):(?:[ \t]+(.*?))?[ \t]*$`)
+)
+
+// orgReq is a requirement heading and the lines below it.
+type orgReq struct {
+	heading string // without the stars and the tags
+	line    int    // line of the heading
+	lines   []string
+}
+
+// ParseOrgCertdoc parses the .org certification document f and returns its requirements, in
+// document order.
+func ParseOrgCertdoc(f string) ([]*Req, error) {
+	r, err := os.Open(f)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		orgReqs []*orgReq
+		current *orgReq
+
+		lastHeadingLevel int // The level of the last heading.
+		lastHeadingLine  int // The line number of the last heading.
+		reqLevel         int // The level of the heading starting the current requirement.
+	)
+	scan := bufio.NewScanner(r)
+	for lno := 1; scan.Scan(); lno++ {
+		line := scan.Text()
+		parts := reOrgHeading.FindStringSubmatch(line)
+		if parts == nil {
+			if current != nil {
+				current.lines = append(current.lines, line)
+			}
+			continue
+		}
+
+		level := len(parts[1])
+		heading := reOrgTags.ReplaceAllString(parts[2], "")
+		reqIDs := ReReqID.FindAllString(heading, -1)
+		if len(reqIDs) > 1 {
+			return nil, fmt.Errorf("malformed requirement heading: too many IDs on line %d: %q", lno, line)
+		}
+		if len(reqIDs) == 1 {
+			switch {
+			case current != nil && level != reqLevel:
+				return nil, fmt.Errorf("requirement heading on line %d must be at same level as requirement heading on line %d (%d != %d): %q", lno, current.line, level, reqLevel, line)
+			case current == nil && level == lastHeadingLevel:
+				return nil, fmt.Errorf("requirement heading on line %d at same level as previous heading on line %d (%d): %q", lno, lastHeadingLine, level, line)
+			}
+			current = &orgReq{heading: heading, line: lno}
+			orgReqs = append(orgReqs, current)
+			reqLevel = level
+		} else if current != nil {
+			switch {
+			case level == reqLevel:
+				return nil, fmt.Errorf("non-requirement heading on line %d at same level as requirement heading on line %d (%d): %q", lno, current.line, level, line)
+			case level < reqLevel:
+				// Higher-level heading, ends the current requirement.
+				current = nil
+			default:
+				// Lower-level heading, part of the current requirement.
+				current.lines = append(current.lines, line)
+			}
+		}
+		lastHeadingLevel = level
+		lastHeadingLine = lno
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	var reqs []*Req
+	for _, or := range orgReqs {
+		req, err := or.toReq()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// toReq converts the heading, the properties drawer and the body of or into a Req.
+func (or *orgReq) toReq() (*Req, error) {
+	loc := ReReqID.FindStringIndex(or.heading)
+	if loc[0] != 0 {
+		return nil, fmt.Errorf("malformed requirement: ID must be at the start of the heading on line %d: %q", or.line, or.heading)
+	}
+	r := &Req{ID: or.heading[loc[0]:loc[1]], Attributes: map[string]string{}}
+	r.Title = strings.TrimLeftFunc(strings.TrimLeftFunc(or.heading[loc[1]:], unicode.IsPunct), unicode.IsSpace)
+
+	// The properties drawer must be the first thing below the heading.
+	body := or.lines
+	for len(body) > 0 && strings.TrimSpace(body[0]) == "" {
+		body = body[1:]
+	}
+	if len(body) == 0 || !strings.EqualFold(strings.TrimSpace(body[0]), ":PROPERTIES:") {
+		return nil, fmt.Errorf("requirement %s contains no attributes", r.ID)
+	}
+	end := -1
+	for i, line := range body[1:] {
+		if strings.EqualFold(strings.TrimSpace(line), ":END:") {
+			end = i + 1
+			break
+		}
+		parts := reOrgProperty.FindStringSubmatch(line)
+		if parts == nil {
+			return nil, fmt.Errorf("requirement %s: malformed property on line %d: %q", r.ID, or.line+len(or.lines)-len(body)+i+2, line)
+		}
+		key := strings.ToUpper(strings.Replace(parts[1], "_", " ", -1))
+		if key == "PARENT" { // accept both, output only PARENTS
+			key = "PARENTS"
+		}
+		if _, ok := r.Attributes[key]; ok {
+			return nil, fmt.Errorf("requirement %s contains duplicate attribute: %q", r.ID, key)
+		}
+		r.Attributes[key] = parts[2]
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("requirement %s: properties drawer is not closed by :END:", r.ID)
+	}
+
+	var err error
+	if r.ParentIds, err = parseParentIds(r.ID, r.Attributes["PARENTS"]); err != nil {
+		return nil, err
+	}
+	level, ok := config.ReqTypeToReqLevel[r.ReqType()]
+	if !ok {
+		return nil, fmt.Errorf("Invalid request type: %q", r.ReqType())
+	}
+	r.Level = level
+	r.Body = pandocToHTML(strings.Join(body[end+1:], "\n"), "--mathjax", "--from=org")
+	return r, nil
+}
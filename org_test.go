@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseOrgContent(t *testing.T, content string) ([]*Req, error) {
+	f, err := createTempFile(content, "parseOrg")
+	if f != nil {
+		defer os.Remove(f.Name())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ParseOrgCertdoc(f.Name())
+}
+
+func TestParseOrgCertdoc(t *testing.T) {
+	reqs, err := parseOrgContent(t, `* Requirements
+** REQ-0-TEST-SWH-001: First :tag1:tag2:
+   :PROPERTIES:
+   :Rationale: Because.
+   :PARENT:   REQ-0-TEST-SYS-001, REQ-0-TEST-SYS-002
+   :SAFETY_IMPACT: None
+   :EMPTY:
+   :END:
+   Body.
+*** Notes
+    More body.
+** REQ-0-TEST-SWH-002 Second
+
+   :PROPERTIES:
+   :RATIONALE: Because.
+   :END:
+* Other section
+Not part of any requirement.
+`)
+	if !assert.NoError(t, err) || !assert.Len(t, reqs, 2) {
+		return
+	}
+	assert.Equal(t, "REQ-0-TEST-SWH-001", reqs[0].ID)
+	assert.Equal(t, "First", reqs[0].Title)
+	assert.Equal(t, []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SYS-002"}, reqs[0].ParentIds)
+	assert.Equal(t, map[string]string{
+		"RATIONALE":     "Because.",
+		"PARENTS":       "REQ-0-TEST-SYS-001, REQ-0-TEST-SYS-002",
+		"SAFETY IMPACT": "None",
+		"EMPTY":         "",
+	}, reqs[0].Attributes)
+	assert.Contains(t, string(reqs[0].Body), "Body.")
+	assert.Contains(t, string(reqs[0].Body), "More body.")
+	assert.Equal(t, "Second", reqs[1].Title)
+	assert.NotContains(t, string(reqs[1].Body), "Not part")
+
+	for _, c := range []struct {
+		content, expectedError string
+	}{
+		{"* REQ-0-TEST-SYS-001 REQ-0-TEST-SYS-002\n", "too many IDs on line 1"},
+		{"* REQ-0-TEST-SYS-001 A\n:PROPERTIES:\n:END:\n** REQ-0-TEST-SYS-002 B\n", "requirement heading on line 4 must be at same level as requirement heading on line 1"},
+		{"* Intro\n* REQ-0-TEST-SYS-001 A\n", "requirement heading on line 2 at same level as previous heading on line 1"},
+		{"* REQ-0-TEST-SYS-001 A\n:PROPERTIES:\n:END:\n* Notes\n", "non-requirement heading on line 4 at same level as requirement heading on line 1"},
+		{"* Intro REQ-0-TEST-SYS-001\n", "ID must be at the start of the heading on line 1"},
+		{"* REQ-0-TEST-SYS-001 A\nBody first\n:PROPERTIES:\n:END:\n", "requirement REQ-0-TEST-SYS-001 contains no attributes"},
+		{"* REQ-0-TEST-SYS-001 A\n:PROPERTIES:\n:RATIONALE: x\nnot a property\n:END:\n", "malformed property on line 4"},
+		{"* REQ-0-TEST-SYS-001 A\n:PROPERTIES:\n:RATIONALE: x\n", "properties drawer is not closed by :END:"},
+		{"* REQ-0-TEST-SYS-001 A\n:PROPERTIES:\n:PARENT: x\n:PARENTS: x\n:END:\n", "duplicate attribute: \"PARENTS\""},
+		{"* REQ-0-TEST-SWH-001 A\n:PROPERTIES:\n:PARENTS: REQ-0-TEST-SYS-001 and REQ-0-TEST-SYS-002\n:END:\n", "unparseable as list of requirement ids"},
+	} {
+		_, err := parseOrgContent(t, c.content)
+		if err == nil {
+			t.Errorf("content `%s` does not generate error `%s`", c.content, c.expectedError)
+			continue
+		}
+		assert.Contains(t, err.Error(), c.expectedError)
+	}
+}
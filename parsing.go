@@ -26,8 +26,13 @@ var (
 
 // @llr REQ-0-DDLN-SWL-019
 // Given a string containing markdown, convert it to HTML using pandoc
-func formatBodyAsHTML(txt string) (template.HTML) {
-	cmd := exec.Command("pandoc", "--mathjax")
+func formatBodyAsHTML(txt string) template.HTML {
+	return pandocToHTML(txt, "--mathjax")
+}
+
+// pandocToHTML converts txt to HTML using pandoc with the given arguments.
+func pandocToHTML(txt string, args ...string) template.HTML {
+	cmd := exec.Command("pandoc", args...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		log.Fatal("Couldn't get input pipe for pandoc: ", err)
@@ -49,15 +54,16 @@ func formatBodyAsHTML(txt string) (template.HTML) {
 // ParseReq finds the first REQ-XXX tag and the reserved words and distills a Req from it.
 //
 // ParseReq parses according to the 'soft' format defined in the SRS:
-//            REQ-ID (text)
-//            [Rationale:....]
-//            [Parent[s]: REQ-ID[, REQ-ID...]]
-//            [Safety Impact:...]
-//            [Verification:...]
-//            [Urgent:...]
-//            [Important:...]
-//            [Mode:...]
-//            [Provenance:...]
+//
+//	REQ-ID (text)
+//	[Rationale:....]
+//	[Parent[s]: REQ-ID[, REQ-ID...]]
+//	[Safety Impact:...]
+//	[Verification:...]
+//	[Urgent:...]
+//	[Important:...]
+//	[Mode:...]
+//	[Provenance:...]
 //
 // ParseReq does NOT validate the values or check if the mandatory attributes are set; use
 // the Req.Check() method for that.
@@ -125,18 +131,9 @@ func ParseReq(txt string) (*Req, error) {
 	// TEXT is anything up to the first keyword we found
 	txt = txt[:attributesStart]
 
-	// PARENTS must be punctuation/space separated list of parseable req-ids.
-	parents := r.Attributes["PARENTS"]
-	parmatch := ReReqID.FindAllStringSubmatchIndex(parents, -1)
-	for i, ids := range parmatch {
-		val := parents[ids[0]:ids[1]]
-		r.ParentIds = append(r.ParentIds, val)
-		if i > 0 {
-			sep := parents[parmatch[i-1][1]:ids[0]]
-			if strings.TrimFunc(sep, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }) != "" {
-				return nil, fmt.Errorf("requirement %s parents: unparseable as list of requirement ids: %q in %q", r.ID, sep, parents)
-			}
-		}
+	var err error
+	if r.ParentIds, err = parseParentIds(r.ID, r.Attributes["PARENTS"]); err != nil {
+		return nil, err
 	}
 
 	level, ok := config.ReqTypeToReqLevel[r.ReqType()]
@@ -150,3 +147,20 @@ func ParseReq(txt string) (*Req, error) {
 	r.Body = formatBodyAsHTML(parts[1])
 	return r, nil
 }
+
+// parseParentIds parses the value of the PARENTS attribute of requirement id, which must be a
+// punctuation/space separated list of parseable req-ids.
+func parseParentIds(id, parents string) ([]string, error) {
+	var ids []string
+	parmatch := ReReqID.FindAllStringSubmatchIndex(parents, -1)
+	for i, m := range parmatch {
+		ids = append(ids, parents[m[0]:m[1]])
+		if i > 0 {
+			sep := parents[parmatch[i-1][1]:m[0]]
+			if strings.TrimFunc(sep, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) }) != "" {
+				return nil, fmt.Errorf("requirement %s parents: unparseable as list of requirement ids: %q in %q", id, sep, parents)
+			}
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,33 @@
+// @llr REQ-0-DDLN-SWL-055
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pdfConverter is the command converting an HTML file, given as the first argument after these,
+// to a PDF file, given as the second one.
+var pdfConverter = []string{"wkhtmltopdf", "--quiet", "--print-media-type"}
+
+// pdfFileName returns the name of the PDF of the HTML report htmlFile, next to it.
+func pdfFileName(htmlFile string) string {
+	return strings.TrimSuffix(htmlFile, filepath.Ext(htmlFile)) + ".pdf"
+}
+
+// writePDF converts the HTML report htmlFile to a PDF, see pdfFileName, returning the name of the
+// PDF. The certification authorities want deliverables which can be signed, not web pages.
+func writePDF(htmlFile string) (string, error) {
+	pdf := pdfFileName(htmlFile)
+	args := append(append([]string{}, pdfConverter[1:]...), htmlFile, pdf)
+	out, err := exec.Command(pdfConverter[0], args...).CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			return "", fmt.Errorf("Error while creating %s, %s is needed to convert the reports to PDF: %v", pdf, pdfConverter[0], err)
+		}
+		return "", fmt.Errorf("Error while converting %s to PDF: %v\n%s", htmlFile, err, out)
+	}
+	return pdf, nil
+}
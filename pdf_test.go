@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePDF(t *testing.T) {
+	defer func(old []string) { pdfConverter = old }(pdfConverter)
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	htmlFile := filepath.Join(dir, "req-down.html")
+	assert.NoError(t, ioutil.WriteFile(htmlFile, []byte("<html>report</html>"), 0644))
+
+	// Stands for the converter, copying the HTML file to the PDF file.
+	pdfConverter = []string{"sh", "-c", `cp "$0" "$1"`}
+	pdf, err := writePDF(htmlFile)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "req-down.pdf"), pdf)
+	b, err := ioutil.ReadFile(pdf)
+	assert.NoError(t, err)
+	assert.Equal(t, "<html>report</html>", string(b))
+
+	pdfConverter = []string{"sh", "-c", "echo broken page; exit 1"}
+	_, err = writePDF(htmlFile)
+	if assert.Error(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "Error while converting "+htmlFile+" to PDF"))
+		assert.Contains(t, err.Error(), "broken page")
+	}
+
+	pdfConverter = []string{"reqtraq-no-such-converter"}
+	_, err = writePDF(htmlFile)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "reqtraq-no-such-converter is needed to convert the reports to PDF")
+	}
+}
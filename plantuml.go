@@ -0,0 +1,97 @@
+// @llr REQ-0-DDLN-SWL-066
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+func init() {
+	exporters["plantuml"] = exportPlantUML
+}
+
+// plantUMLSelection restricts the PlantUML export to the requirement Root and its descendants, and
+// to the requirements of the certification document Document, e.g. 0-DDLN-212-SDD, as set with
+// --root and --document. Empty fields don't restrict it.
+var plantUMLSelection struct {
+	Root, Document string
+}
+
+var rePlantUMLAlias = regexp.MustCompile(`\W`)
+
+// exportPlantUML writes the hierarchy of the requirements as a PlantUML diagram, e.g. to be
+// rendered with "plantuml -tsvg": a rectangle per requirement, with the ID and the title, its type
+// as stereotype and colored by status as the DOT export, in a package per certification document,
+// and an arrow from each parent to its children. The selection, see plantUMLSelection, keeps the
+// links between the requirements selected only. The code files and the deleted requirements are
+// left out.
+func exportPlantUML(rg reqGraph, w io.Writer) error {
+	selected := map[*Req]bool{}
+	if id := plantUMLSelection.Root; id != "" {
+		root := rg[id]
+		if root == nil || root.IsDeleted() {
+			return fmt.Errorf("Unknown requirement %s to export the hierarchy of", id)
+		}
+		var walk func(r *Req)
+		walk = func(r *Req) {
+			selected[r] = true
+			for _, c := range r.Children {
+				walk(c)
+			}
+		}
+		walk(root)
+	} else {
+		for _, r := range rg {
+			selected[r] = true
+		}
+	}
+	docs := map[string][]*Req{}
+	for r := range selected {
+		doc := scopeDocument(r)
+		if r.Level == config.CODE || r.IsDeleted() || plantUMLSelection.Document != "" && doc != plantUMLSelection.Document {
+			delete(selected, r)
+			continue
+		}
+		docs[doc] = append(docs[doc], r)
+	}
+	names := make([]string, 0, len(docs))
+	for doc := range docs {
+		names = append(names, doc)
+	}
+	sort.Strings(names)
+
+	alias := func(r *Req) string { return rePlantUMLAlias.ReplaceAllString(r.ID, "_") }
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "@startuml")
+	fmt.Fprintln(b, "skinparam rectangle {\n\tFontName Helvetica\n\tFontSize 10\n}")
+	for _, doc := range names {
+		reqs := docs[doc]
+		sort.Sort(byPosition(reqs))
+		fmt.Fprintf(b, "package %q {\n", doc)
+		for _, r := range reqs {
+			label := r.ID
+			if r.Title != "" {
+				label += `\n` + strings.Replace(wrapWords(r.Title, dotTitleWidth), "\n", `\n`, -1)
+			}
+			fmt.Fprintf(b, "\trectangle \"%s\" <<%s>> as %s %s\n", strings.Replace(label, `"`, "'", -1), r.ReqType(), alias(r), dotColors[r.Status])
+		}
+		fmt.Fprintln(b, "}")
+	}
+	for _, doc := range names {
+		for _, r := range docs[doc] {
+			for _, c := range r.Children {
+				if selected[c] {
+					fmt.Fprintf(b, "%s --> %s\n", alias(r), alias(c))
+				}
+			}
+		}
+	}
+	fmt.Fprintln(b, "@enduml")
+	return b.Flush()
+}
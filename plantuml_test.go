@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportPlantUML(t *testing.T) {
+	defer func() { plantUMLSelection.Root, plantUMLSelection.Document = "", "" }()
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "/certdocs/0-TEST-100-ORD.md", Position: 1, Title: "Fly"}
+	high1 := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "/certdocs/0-TEST-211-SRD.md", Position: 2,
+		Title: `Keep the "altitude"`, ParentIds: []string{sys.ID}}
+	high2 := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "/certdocs/0-TEST-211-SRD.md", Position: 1,
+		Title: "Land", ParentIds: []string{sys.ID}}
+	deleted := &Req{ID: "REQ-0-TEST-SWH-003", Level: config.HIGH, Path: "/certdocs/0-TEST-211-SRD.md", Position: 3,
+		Title: "DELETED", ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "/certdocs/0-TEST-212-SDD.md", Position: 1,
+		Title: "Climb", ParentIds: []string{high1.ID}}
+	code := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", ParentIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high1.ID: high1, high2.ID: high2, deleted.ID: deleted, low.ID: low, code.Path: code}
+	assert.NoError(t, rg.Resolve())
+
+	var w bytes.Buffer
+	assert.NoError(t, rg.Export("plantuml", &w))
+	assert.Equal(t, `@startuml
+skinparam rectangle {
+	FontName Helvetica
+	FontSize 10
+}
+package "0-TEST-100-ORD" {
+	rectangle "REQ-0-TEST-SYS-001\nFly" <<SYS>> as REQ_0_TEST_SYS_001 #FFEB9C
+}
+package "0-TEST-211-SRD" {
+	rectangle "REQ-0-TEST-SWH-002\nLand" <<SWH>> as REQ_0_TEST_SWH_002 #FFC7CE
+	rectangle "REQ-0-TEST-SWH-001\nKeep the 'altitude'" <<SWH>> as REQ_0_TEST_SWH_001 #C6EFCE
+}
+package "0-TEST-212-SDD" {
+	rectangle "REQ-0-TEST-SWL-001\nClimb" <<SWL>> as REQ_0_TEST_SWL_001 #C6EFCE
+}
+REQ_0_TEST_SYS_001 --> REQ_0_TEST_SWH_002
+REQ_0_TEST_SYS_001 --> REQ_0_TEST_SWH_001
+REQ_0_TEST_SWH_001 --> REQ_0_TEST_SWL_001
+@enduml
+`, w.String())
+
+	plantUMLSelection.Root = high1.ID
+	w.Reset()
+	assert.NoError(t, rg.Export("plantuml", &w))
+	assert.Contains(t, w.String(), `package "0-TEST-211-SRD" {
+	rectangle "REQ-0-TEST-SWH-001\nKeep the 'altitude'" <<SWH>> as REQ_0_TEST_SWH_001 #C6EFCE
+}
+package "0-TEST-212-SDD" {`)
+	assert.NotContains(t, w.String(), "SYS-001")
+	assert.Contains(t, w.String(), "REQ_0_TEST_SWH_001 --> REQ_0_TEST_SWL_001\n")
+
+	plantUMLSelection.Root, plantUMLSelection.Document = "", "0-TEST-211-SRD"
+	w.Reset()
+	assert.NoError(t, rg.Export("plantuml", &w))
+	assert.NotContains(t, w.String(), "SYS-001")
+	assert.NotContains(t, w.String(), "SWL-001")
+	assert.NotContains(t, w.String(), "-->")
+
+	plantUMLSelection.Document = ""
+	plantUMLSelection.Root = deleted.ID
+	assert.EqualError(t, rg.Export("plantuml", &w), "Unknown requirement REQ-0-TEST-SWH-003 to export the hierarchy of")
+}
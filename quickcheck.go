@@ -0,0 +1,344 @@
+// @llr REQ-0-DDLN-SWL-026
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// parseCache holds the requirements and the code references parsed from each certification
+// document and code file, so quickcheck only parses again the files changed since. It is only
+// valid for the repository location and the paths it was written for.
+type parseCache struct {
+	RepoPath    string `json:"repoPath"`
+	CertdocPath string `json:"certdocPath"`
+	CodePath    string `json:"codePath"`
+	// Commit is the HEAD when the cache was written, the files changed in the commits since
+	// are parsed again.
+	Commit string `json:"commit"`
+	// Files are keyed by their path relative to the repository root.
+	Files map[string]*cachedFile `json:"files"`
+	// Ignore is the content of the ignore file, everything is parsed again when it changes.
+	Ignore string `json:"ignore,omitempty"`
+	// HashAlgorithm is that of the hashes of the code files, see setHashAlgorithm.
+	HashAlgorithm string `json:"hashAlgorithm,omitempty"`
+	ignore        ignoreRules
+}
+
+// cachedFile is what was parsed from a file.
+type cachedFile struct {
+	Certdoc bool        `json:"certdoc,omitempty"`
+	Reqs    []cachedReq `json:"reqs,omitempty"`
+	Errors  []string    `json:"errors,omitempty"`
+	// Dirty is set for files parsed with changes not committed yet, which are parsed again
+	// even if the changes are reverted meanwhile.
+	Dirty bool `json:"dirty,omitempty"`
+}
+
+// cachedReq is a requirement or a code file before being resolved.
+type cachedReq struct {
+	ID         string                  `json:"id"`
+	Level      config.RequirementLevel `json:"level"`
+	Path       string                  `json:"path"`
+	FileHash   string                  `json:"fileHash"`
+	ParentIds  []string                `json:"parentIds"`
+	Title      string                  `json:"title"`
+	Body       template.HTML           `json:"body"`
+	Attributes map[string]string       `json:"attributes"`
+	Position   int                     `json:"position"`
+	// VerifiesIds and VerifiedCriteria are those of the code files.
+	VerifiesIds      []string     `json:"verifiesIds,omitempty"`
+	VerifiedCriteria []string     `json:"verifiedCriteria,omitempty"`
+	Annotations      []Annotation `json:"annotations,omitempty"`
+	Section          string       `json:"section,omitempty"`
+}
+
+// parseCachePath returns the path of the file holding the parse cache of the repository, next
+// to the task sync state.
+func parseCachePath() (string, error) {
+	dir, err := git.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reqtraq", "cache.json"), nil
+}
+
+// loadParseCache reads the parse cache from the given file, empty if it doesn't exist.
+func loadParseCache(path string) (*parseCache, error) {
+	c := &parseCache{Files: map[string]*cachedFile{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	if c.Files == nil {
+		c.Files = map[string]*cachedFile{}
+	}
+	return c, nil
+}
+
+func (c *parseCache) save(path string) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// QuickCheck runs the precommit checks using the parse cache, only parsing again the
+// certification documents and code files changed in the working tree or in the commits since
+// the cache was written. The references to other requirements are only checked in the
+// documents parsed again. Without a cache, all the files are parsed, as by precommit.
+func QuickCheck(certdocPath, codePath string, attributes []map[string]string, attributeRules []RuleConf, derived *DerivedConf) error {
+	repoPath := git.RepoPath()
+	cachePath, err := parseCachePath()
+	if err != nil {
+		return err
+	}
+	cache, err := loadParseCache(cachePath)
+	if err != nil {
+		return err
+	}
+	// Empty in a repository without commits, in which case everything is parsed each time.
+	head, _ := git.HeadCommit()
+	ignore, err := ioutil.ReadFile(filepath.Join(repoPath, ignoreFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	rules, err := parseIgnoreRules(bytes.NewReader(ignore))
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	valid := cache.RepoPath == repoPath && cache.CertdocPath == certdocPath && cache.CodePath == codePath && cache.Commit != "" && cache.Ignore == string(ignore) &&
+		cache.HashAlgorithm == fileHashAlgorithm
+	if valid && cache.Commit != head {
+		changed, deleted, err := git.FilesChangedBetween(cache.Commit, head)
+		// Fails e.g. if the commit was rebased away and garbage collected.
+		valid = err == nil
+		paths = append(changed, deleted...)
+	}
+	if !valid {
+		cache = &parseCache{RepoPath: repoPath, CertdocPath: certdocPath, CodePath: codePath, Files: map[string]*cachedFile{}, Ignore: string(ignore),
+			HashAlgorithm: fileHashAlgorithm}
+		if paths, err = cache.allFiles(); err != nil {
+			return err
+		}
+	}
+	cache.Commit = head
+	cache.ignore = rules
+
+	inWorkTree, err := git.FilesChangedInWorkTree()
+	if err != nil {
+		return err
+	}
+	dirty := map[string]bool{}
+	for _, p := range inWorkTree {
+		dirty[filepath.FromSlash(p)] = true
+	}
+	for p, f := range cache.Files {
+		if f.Dirty {
+			paths = append(paths, p)
+		}
+	}
+	for p := range dirty {
+		paths = append(paths, p)
+	}
+
+	parsed := cache.update(paths, dirty)
+	checkErr := cache.check(parsed, attributes, attributeRules, derived)
+	if err := cache.save(cachePath); err != nil {
+		return err
+	}
+	return checkErr
+}
+
+// allFiles returns the certification documents and the code files of the repository.
+func (c *parseCache) allFiles() ([]string, error) {
+	var paths []string
+	for _, dir := range []string{c.CertdocPath, c.CodePath} {
+		err := filepath.Walk(filepath.Join(c.RepoPath, dir), func(fileName string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			relPath, err := filepath.Rel(c.RepoPath, fileName)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, relPath)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// update parses the files at the given paths again, relative to the repository root, and
+// removes the deleted ones and the ones which are not certification documents or code files. It
+// returns the paths of the certification documents parsed.
+func (c *parseCache) update(paths []string, dirty map[string]bool) []string {
+	var certdocs []string
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		f := c.parseFile(p)
+		if f == nil {
+			delete(c.Files, p)
+			continue
+		}
+		f.Dirty = dirty[p]
+		c.Files[p] = f
+		if f.Certdoc {
+			certdocs = append(certdocs, p)
+		}
+	}
+	sort.Strings(certdocs)
+	return certdocs
+}
+
+// parseFile parses the file at relPath as CreateReqGraph does. It returns nil if the file
+// doesn't exist, is ignored, or is neither a certification document nor a code file.
+func (c *parseCache) parseFile(relPath string) *cachedFile {
+	fileName := filepath.Join(c.RepoPath, relPath)
+	if info, err := os.Stat(fileName); err != nil || info.IsDir() || c.ignore.Ignored(relPath, false) {
+		return nil
+	}
+	rg := reqGraph{}
+	f := &cachedFile{Certdoc: isWithin(filepath.Join(c.RepoPath, c.CertdocPath), fileName) && docParser(fileName) != nil}
+	if f.Certdoc {
+		for _, err := range parseCertdocToGraph(fileName, rg) {
+			f.Errors = append(f.Errors, err.Error())
+		}
+	} else if lang := codeFileLanguage(c.CodePath, fileName); lang != nil && isWithin(filepath.Join(c.RepoPath, c.CodePath), fileName) {
+		if err := parseCode(relPath, fileName, lang.references(), rg); err != nil {
+			f.Errors = append(f.Errors, err.Error())
+		}
+	} else {
+		return nil
+	}
+	reqs := make([]*Req, 0, len(rg))
+	for _, r := range rg {
+		reqs = append(reqs, r)
+	}
+	sort.Sort(byPosition(reqs))
+	for _, r := range reqs {
+		body, err := r.ReadBody()
+		if err != nil {
+			f.Errors = append(f.Errors, err.Error())
+		}
+		f.Reqs = append(f.Reqs, cachedReq{r.ID, r.Level, r.Path, r.FileHash, r.ParentIds, r.Title, body, r.Attributes, r.Position, r.VerifiesIds, r.VerifiedCriteria, r.Annotations, r.Section})
+	}
+	return f
+}
+
+// isWithin returns whether fileName is in the directory dir.
+func isWithin(dir, fileName string) bool {
+	rel, err := filepath.Rel(dir, fileName)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// graph returns the requirement graph of the cached files, not resolved yet, and the problems
+// found while parsing them, as reported by CreateReqGraph.
+func (c *parseCache) graph() (reqGraph, string) {
+	relPaths := make([]string, 0, len(c.Files))
+	for p := range c.Files {
+		relPaths = append(relPaths, p)
+	}
+	sort.Strings(relPaths)
+
+	rg := reqGraph{}
+	errorResult := ""
+	for _, p := range relPaths {
+		f := c.Files[p]
+		for _, cr := range f.Reqs {
+			r := &Req{ID: cr.ID, Level: cr.Level, Path: cr.Path, FileHash: cr.FileHash, ParentIds: cr.ParentIds,
+				Title: cr.Title, Body: cr.Body, Attributes: cr.Attributes, Position: cr.Position,
+				VerifiesIds: cr.VerifiesIds, VerifiedCriteria: cr.VerifiedCriteria, Annotations: cr.Annotations, Section: cr.Section}
+			r.parseSections()
+			if r.Level == config.CODE {
+				rg[r.Path] = r
+			} else if rg[r.ID] == nil {
+				// As AddReq, the first definition wins.
+				rg[r.ID] = r
+			}
+		}
+		if len(f.Errors) == 0 {
+			continue
+		}
+		if !f.Certdoc {
+			errorResult += strings.Join(f.Errors, "\n") + "\n"
+			continue
+		}
+		errorResult += "Problems found while parsing " + filepath.Join(c.RepoPath, p) + ":\n"
+		for _, e := range f.Errors {
+			errorResult += "\t" + e + "\n"
+		}
+		errorResult += "\n"
+	}
+	return rg, errorResult
+}
+
+// check resolves the graph of the cached files and, if it is valid, checks the references in the
+// given certification documents and the attributes of all the requirements, including the rules and
+// the rationale of the derived requirements.
+func (c *parseCache) check(certdocs []string, attributes []map[string]string, rules []RuleConf, derived *DerivedConf) error {
+	rg, errorResult := c.graph()
+	parseErrors := errorResult != ""
+	if err := rg.Resolve(); err != nil {
+		errorResult += err.Error()
+	}
+	// As precommit, the other checks need a valid graph.
+	if errorResult != "" {
+		return graphError(parseErrors, errorResult)
+	}
+	for _, p := range certdocs {
+		result, err := rg.checkFileReqReferences(filepath.Join(c.RepoPath, p))
+		if err != nil {
+			return err
+		}
+		errorResult += result
+	}
+	for _, e := range rg.CheckAttributes(attributes) {
+		errorResult += e.Error()
+	}
+	errs, err := rg.CheckRules(rules)
+	if err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	for _, e := range errs {
+		errorResult += e.Error()
+	}
+	if errs, err = rg.CheckDerived(derived, c.RepoPath); err != nil {
+		return withExitCode(exitUsage, err)
+	}
+	for _, e := range errs {
+		errorResult += e.Error()
+	}
+	if errorResult != "" {
+		return graphError(false, errorResult)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/stretchr/testify/assert"
+)
+
+func sortedLines(err error) []string {
+	lines := strings.Split(err.Error(), "\n")
+	sort.Strings(lines)
+	return lines
+}
+
+func TestParseCache_checkAsPrecommit(t *testing.T) {
+	for _, dir := range []string{"/testdata/TestPreCommitCreateReqGraph", "/testdata/TestPreCommitCheckReqReferencesMarkdown"} {
+		expected := precommit(dir, dir, git.RepoPath()+"/certdocs/attributes.json")
+		assert.Error(t, expected, dir)
+
+		conf, err := loadJsonConf(git.RepoPath() + "/certdocs/attributes.json")
+		assert.NoError(t, err)
+		c := &parseCache{RepoPath: git.RepoPath(), CertdocPath: dir, CodePath: dir, Files: map[string]*cachedFile{}}
+		paths, err := c.allFiles()
+		assert.NoError(t, err)
+		err = c.check(c.update(paths, nil), conf.Attributes, conf.Rules, conf.Derived)
+		assert.Error(t, err, dir)
+		assert.Equal(t, sortedLines(expected), sortedLines(err), dir)
+	}
+}
+
+const quickcheckORD = `# Test
+
+### REQ-0-TEST-SYS-001 First
+
+Body of requirement 1.
+
+###### Attributes:
+- Rationale: Rationale 1
+- Verification: Test 1
+- Safety impact: Impact 1
+`
+
+func TestParseCache_update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quickcheck")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "certdocs"), 0755))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "code"), 0755))
+	ord := filepath.Join("certdocs", "0-TEST-100-ORD.md")
+	code := filepath.Join("code", "a.go")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ord), []byte(quickcheckORD), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, code), []byte("/"+"/ @llr REQ-0-TEST-SWL-001\npackage a\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "code", "README"), []byte("REQ-0-TEST-SWL-002\n"), 0644))
+
+	c := &parseCache{RepoPath: dir, CertdocPath: "certdocs", CodePath: "code", Files: map[string]*cachedFile{}}
+	paths, err := c.allFiles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ord}, c.update(paths, map[string]bool{code: true}))
+	assert.Len(t, c.Files, 2)
+	assert.Empty(t, c.Files[ord].Errors)
+	assert.True(t, c.Files[code].Dirty)
+
+	rg, errs := c.graph()
+	assert.Empty(t, errs)
+	assert.Equal(t, "First", rg["REQ-0-TEST-SYS-001"].Title)
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001"}, rg[filepath.Join(dir, code)].ParentIds)
+	err = c.check(nil, nil, nil, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "REQ-0-TEST-SWL-001")
+
+	path := filepath.Join(dir, ".git", "reqtraq", "cache.json")
+	assert.NoError(t, c.save(path))
+	loaded, err := loadParseCache(path)
+	assert.NoError(t, err)
+	assert.Equal(t, c, loaded)
+
+	// The deleted code file is only seen once parsed again.
+	assert.NoError(t, os.Remove(filepath.Join(dir, code)))
+	c.update([]string{ord}, nil)
+	assert.Error(t, c.check(nil, nil, nil, nil))
+	assert.Empty(t, c.update([]string{code}, nil))
+	assert.Len(t, c.Files, 1)
+	assert.NoError(t, c.check(nil, nil, nil, nil))
+}
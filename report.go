@@ -3,6 +3,10 @@ package main
 import (
 	"html/template"
 	"io"
+	"sort"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
 )
 
 type Oncer map[string]bool
@@ -13,15 +17,17 @@ func (o Oncer) Once(r *Req) *Req {
 	if !ok {
 		return r
 	}
-	return &Req{ID: r.ID, Title: r.Title, Body: r.Body, Level: -1}
+	return &Req{ID: r.ID, Title: r.Title, Body: r.Body, storedBody: r.storedBody, Level: -1}
 }
 
-var reportTmpl = template.Must(template.New("").Parse(`
+// reportTemplates is the source of the built-in templates of the HTML reports, see
+// loadReportTemplates.
+const reportTemplates = `
 {{ define "REQUIREMENT" }}
 	{{if ne .Level -1 }}
-		<h3><a name="{{ .ID }}"></a>{{ .ID }} {{ .Title }}</h3>
-		{{ if .Body }}
-			<p>{{ .Body }}</p>
+		<h3><a name="{{ .ID }}"></a>{{ .ID }} {{ .Title }}{{ if .Section }} <small>section {{ .Section }}</small>{{ end }}</h3>
+		{{ with .LoadBody }}
+			<p>{{ . }}</p>
 		{{ end }}
 		{{ if .Attributes }}
 			<ul style="list-style: none; padding: 0; margin: 0;">
@@ -31,6 +37,10 @@ var reportTmpl = template.Must(template.New("").Parse(`
 			</ul>
 		{{ end }}
 		{{ template "STATUSFIELD" . }}
+		{{ template "TASKPROGRESS" .Progress }}
+		{{ template "TESTCASES" . }}
+		{{ template "VERIFIEDBY" .VerifiedBy }}
+		{{ template "PARTIALLYIMPLEMENTEDBY" .PartiallyImplementedBy }}
 	{{ else }}
 		<h3><a href="#{{ .ID }}">{{ .ID }} {{ .Title }}</a></h3>
  	{{end}}
@@ -46,16 +56,95 @@ var reportTmpl = template.Must(template.New("").Parse(`
 	</p>
 {{ end }}
 
+{{ define "FUNCTIONS" }}
+	{{ if . }}
+	<p>Functions:
+		{{ range . }}
+			<a href="file://{{ .File.Path }}" target="_blank">{{ .File.ID }}:{{ .Start }}</a> <code>{{ .Name }}</code>
+		{{ end }}
+	</p>
+	{{ end }}
+{{ end }}
+
+{{ define "CODEFUNCTIONS" }}
+	{{ if . }}
+	<ul>
+		{{ range . }}
+			<li><code>{{ .Name }}</code>, lines {{ .Start }}-{{ .End }}: {{ range .Reqs }}<a href="#{{ . }}">{{ . }}</a> {{ end }}</li>
+		{{ end }}
+	</ul>
+	{{ end }}
+{{ end }}
+
+{{ define "VERIFIEDBY" }}
+	{{ if . }}
+	<p>Verified by:
+		{{ range . }}
+			<a href="file://{{ .Path }}" target="_blank">{{ .ID }}</a>
+		{{ end }}
+	</p>
+	{{ end }}
+{{ end }}
+
+{{ define "PARTIALLYIMPLEMENTEDBY" }}
+	{{ if . }}
+	<p>Partially implemented by:
+		{{ range . }}
+			<a href="file://{{ .Path }}" target="_blank">{{ .ID }}</a>
+		{{ end }}
+	</p>
+	{{ end }}
+{{ end }}
+
+{{ define "TESTENVFILES"}}
+	{{ if . }}
+	<p>Test Environment Files:
+		{{ range . }}
+			<a href="file://{{ .Path }}" target="_blank">{{ .ID }}</a>
+		{{ end }}
+	</p>
+	{{ end }}
+{{ end }}
+
+{{ define "CRITERIA" }}
+	{{ if . }}
+	<p>Acceptance Criteria:</p>
+	<ol>
+		{{ range . }}
+			<li>{{ .Text }}
+				{{ range .Files }}
+					<a href="file://{{ .Path }}" target="_blank">{{ .ID }}</a>
+				{{ else }}
+					<span class="text-danger">Not verified</span>
+				{{ end }}
+			</li>
+		{{ end }}
+	</ol>
+	{{ end }}
+{{ end }}
+
 {{ define "CHANGELIST" }}
 	<p>Changelists:
-		{{ range $k, $v := . }}
-			<a href="{{ $v }}" target="_blank"><span class="label label-primary">{{ $k }}</span></a>
+		{{ if .Unavailable }}
+			<span class="text-warning" title="{{ .Unavailable }}">Unavailable, the git history could not be read</span>
 		{{ else }}
-			<span class="text-danger">No changelist</span>
+			{{ range $k, $v := .URLs }}
+				{{ if $v }}
+					<a href="{{ $v }}" target="_blank"><span class="label label-primary">{{ $k }}</span></a>
+				{{ else }}
+					<span class="label label-primary">{{ $k }}</span>
+				{{ end }}
+			{{ else }}
+				<span class="text-danger">No changelist</span>
+			{{ end }}
 		{{ end }}
 	</p>
 {{ end }}
 
+{{ define "TRAILERCHANGELIST" }}
+	{{ if .URLs }}{{ template "CHANGELIST" . }}{{ end }}
+{{ end }}
+
 {{ define "STATUSFIELD" }}
 	<p>Status:
 		{{ if eq .Status 0 }}
@@ -67,16 +156,49 @@ var reportTmpl = template.Must(template.New("").Parse(`
 		{{ end }}
 {{ end }}
 
+{{ define "TASKPROGRESS" }}
+	{{ with . }}
+	<p>Task:
+		<span class="label {{ if .IsClosed }}label-success{{ else }}label-primary{{ end }}">{{ .Status }}</span>
+		{{ if .Assignee }}assigned to {{ .Assignee }}{{ else }}<span class="text-warning">unassigned</span>{{ end }}
+	</p>
+	{{ if .Comments }}
+	<ul>
+		{{ range .Comments }}
+			<li><strong>{{ .Author }}</strong> {{ .Date.Format "2006-01-02" }}: {{ .Text }}</li>
+		{{ end }}
+	</ul>
+	{{ end }}
+	{{ end }}
+{{ end }}
+
+{{ define "TESTCASES" }}
+	{{ if .TestCases }}
+	<p>Test Cases:
+		{{ $s := .VerificationStatus }}
+		<span class="label {{ if eq $s "Passed" }}label-success{{ else if eq $s "Failed" }}label-danger{{ else }}label-warning{{ end }}">{{ $s }}</span>
+		{{ range .TestCases }}
+			<a href="{{ .URL }}" target="_blank" title="{{ .Title }}">{{ .ID }}</a> {{ .Result }}{{ if .Executed }} ({{ .Executed }}){{ end }}
+		{{ end }}
+	</p>
+	{{ end }}
+{{ end }}
+
 {{ define "PROBLEMREPORTS" }}
 	<p>Problem Reports:
-		{{ range $k, $v := . }}
+		{{ range $k, $v := .Tasks }}
 			{{if $v.IsClosed}}
-				<a href="{{ $v.URI }}" target="_blank"> <span class="label label-success">T{{ $v.DisplayID }}</span></a>
+				<a href="{{ $v.URI }}" target="_blank"> <span class="label label-success">{{ $v.DisplayID }}</span></a>
 			{{else}}
-				<a href="{{ $v.URI }}" target="_blank"> <span class="label label-danger">T{{ $v.DisplayID }}</span></a>
+				<a href="{{ $v.URI }}" target="_blank"> <span class="label label-danger">{{ $v.DisplayID }}</span></a>
 			{{end}}
 		{{ else }}
-			<span class="text-danger">No problem reports</span>
+			{{ if not .Unavailable }}
+				<span class="text-danger">No problem reports</span>
+			{{ end }}
+		{{ end }}
+		{{ if .Unavailable }}
+			<span class="text-warning" title="{{ .Unavailable }}">Unavailable, the task manager could not be queried</span>
 		{{ end }}
 	</p>
 {{ end }}
@@ -123,26 +245,27 @@ var reportTmpl = template.Must(template.New("").Parse(`
 </html>
 {{end}}
 
-{{define "TOPDOWN"}}
-	{{template "HEADER"}}
-		<h2>Top Down Tracing</h2>
-		<hr>
-	</section>
+{{define "TOPDOWNTREE"}}
 	<ul style="list-style: none; padding: 0; margin: 0;">
 		{{ range .Reqs.OrdsByPosition }}
 			<li>
 				{{ template "REQUIREMENT" . }}
+				{{ template "TRAILERCHANGELIST" .Changelists }}
 				<!-- HLRs -->
 				<ul>
 				{{ range .Children }}
 					<li>
 						{{ template "REQUIREMENT" ($.Once.Once .) }}
+						{{ template "TRAILERCHANGELIST" .Changelists }}
 						<!-- LLRs -->
 							<ul>
 							{{ range .Children }}
 								<li>
 									{{ template "REQUIREMENT" ($.Once.Once .) }}
-									{{ template "CODEFILES" .Children }}
+									{{ template "CODEFILES" .CodeFiles }}
+									{{ template "FUNCTIONS" .ImplementingFunctions }}
+									{{ template "TESTENVFILES" .TestEnvFiles }}
+									{{ template "CRITERIA" .CriteriaCoverage }}
 									{{ template "CHANGELIST" .Changelists }}
 									{{ template "PROBLEMREPORTS" .Tasklists }}
 								</li>
@@ -160,6 +283,14 @@ var reportTmpl = template.Must(template.New("").Parse(`
 			<li  class="text-danger">Empty graph</li>
 		{{ end }}
 	</ul>
+{{end}}
+
+{{define "TOPDOWN"}}
+	{{template "HEADER"}}
+		<h2>Top Down Tracing</h2>
+		<hr>
+	</section>
+	{{template "TOPDOWNTREE" .}}
 	{{template "FOOTER"}}
 {{end}}
 {{define "BOTTOMUP"}}
@@ -170,8 +301,15 @@ var reportTmpl = template.Must(template.New("").Parse(`
 	<ul style="list-style: none; padding: 0; margin: 0;">
 		{{ range .Reqs.CodeFilesByPosition }}
 			<li>
-				<h3><a href="{{ .Path }}" target="_blank">{{ .ID }}</a></h3>
+				<h3><a href="{{ .Path }}" target="_blank">{{ .ID }}</a>{{ if .TestEnv }} <span class="label label-info">Test environment</span>{{ end }}</h3>
 				{{ template "STATUSFIELD" . }}
+				{{ with .Coverage }}
+				<p>Structural coverage: {{ .LinesCovered }} of {{ .LinesFound }} lines, {{ .BranchesCovered }} of {{ .BranchesFound }} branches</p>
+				{{ end }}
+				{{ template "CODEFUNCTIONS" .Functions }}
+				{{ if .Verifies }}
+				<p>Verifies: {{ range .Verifies }}<a href="#{{ .ID }}">{{ .ID }}</a> {{ end }}</p>
+				{{ end }}
 				<!-- LLRs -->
 				<ul>
 				{{ range .Parents }}
@@ -246,7 +384,10 @@ var reportTmpl = template.Must(template.New("").Parse(`
 				{{ range .Children }}
 					{{ if .Matches $.Filter $.Diffs }}
 						{{ template "REQUIREMENT" ($.Once.Once .) }}
-						{{ template "CODEFILES" .Children }}
+						{{ template "CODEFILES" .CodeFiles }}
+						{{ template "FUNCTIONS" .ImplementingFunctions }}
+						{{ template "TESTENVFILES" .TestEnvFiles }}
+						{{ template "CRITERIA" .CriteriaCoverage }}
 						{{ template "CHANGELIST" .Changelists }}
 						{{ template "PROBLEMREPORTS" .Tasklists }}
 
@@ -269,7 +410,9 @@ var reportTmpl = template.Must(template.New("").Parse(`
 			{{ range .Parents }}
 				{{ if .Matches $.Filter $.Diffs }}
 					{{ template "REQUIREMENT" ($.Once.Once .) }}
-					{{ template "CODEFILES" .Children }}
+					{{ template "CODEFILES" .CodeFiles }}
+					{{ template "FUNCTIONS" .ImplementingFunctions }}
+					{{ template "TESTENVFILES" .TestEnvFiles }}
 					{{ template "CHANGELIST" .Changelists }}
 					{{ template "PROBLEMREPORTS" .Tasklists }}
 
@@ -286,6 +429,534 @@ var reportTmpl = template.Must(template.New("").Parse(`
 	{{ template "FOOTER" }}
 {{ end }}
 
+{{ define "RISK" }}
+	{{template "HEADER"}}
+		<h2>Risk Ranking</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	{{ if $.Unavailable }}<p class="text-warning">The churn is unavailable and not part of the scores: {{ $.Unavailable }}</p>{{ end }}
+	<table class="table table-condensed">
+		<tr>
+			<th>Requirement</th>
+			<th>Score</th>
+			{{ range $.Factors }}<th>{{ . }}</th>{{ end }}
+		</tr>
+		{{ range .Scores }}
+			{{ if .Req.Matches $.Filter $.Diffs }}
+			<tr>
+				<td><strong>{{ .Req.ID }}</strong> {{ .Req.Title }}</td>
+				<td><strong>{{ printf "%.1f" .Score }}</strong></td>
+				{{ $factors := .Factors }}
+				{{ range $.Factors }}<td>{{ printf "%.2f" (index $factors .) }}</td>{{ end }}
+			</tr>
+			{{ end }}
+		{{ else }}
+			<tr><td class="text-danger">Empty graph</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "DERIVED" }}
+	{{template "HEADER"}}
+		<h2>Derived Requirements</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<p>The requirements whose {{ $.Conf.Attribute }} attribute is {{ $.Conf.Value }}, with the records their {{ $.Conf.Rationale }} references.</p>
+	<table class="table table-condensed">
+		<tr>
+			<th>Requirement</th>
+			<th>Parents</th>
+			<th>{{ $.Conf.Rationale }}</th>
+			<th>Records</th>
+		</tr>
+		{{ range .Reqs }}
+			{{ if .Req.Matches $.Filter $.Diffs }}
+			<tr>
+				<td><strong>{{ .Req.ID }}</strong> {{ .Req.Title }}</td>
+				<td>{{ range .Req.Parents }}{{ .ID }}<br>{{ else }}None{{ end }}</td>
+				<td>{{ if .Rationale }}{{ .Rationale }}{{ else }}<span class="text-danger">Missing</span>{{ end }}</td>
+				<td>
+				{{ range .Records }}
+					{{ if .Path }}{{ .ID }} <small>{{ .Path }}</small>{{ else if $.Conf.Dir }}<span class="text-danger">{{ .ID }} not found</span>{{ else }}{{ .ID }}{{ end }}<br>
+				{{ else }}
+					<span class="text-danger">None</span>
+				{{ end }}
+				</td>
+			</tr>
+			{{ end }}
+		{{ else }}
+			<tr><td>No derived requirements</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "CODETRACES" }}
+	{{template "HEADER"}}
+		<h2>Code Traceability</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<table class="table table-condensed">
+		<tr>
+			<th>Code File</th>
+			<th>Requirements</th>
+			<th>Functions</th>
+			<th>Hash</th>
+			<th>HEAD</th>
+		</tr>
+		{{ range .Traces }}
+			<tr>
+				<td><a href="file://{{ .File.Path }}" target="_blank">{{ .File.ID }}</a>{{ if .File.TestEnv }} <span class="label label-info">Test environment</span>{{ end }}</td>
+				<td>
+					{{ range .File.Parents }}{{ .ID }} {{ .Title }}<br>{{ end }}
+					{{ range .File.PartiallyImplements }}{{ .ID }} {{ .Title }} <small>partially</small><br>{{ end }}
+					{{ range .File.Verifies }}{{ .ID }} {{ .Title }} <small>verified</small><br>{{ end }}
+				</td>
+				<td>{{ range .File.Functions }}<code>{{ .Name }}</code>, lines {{ .Start }}-{{ .End }}: {{ range .Reqs }}{{ . }} {{ end }}<br>{{ end }}</td>
+				<td><small>{{ .File.FileHash }}</small></td>
+				<td>{{ if eq .Head "Unchanged" }}<span class="label label-success">{{ .Head }}</span>{{ else if eq .Head "Modified" "Not committed" }}<span class="label label-warning">{{ .Head }}</span>{{ else }}<span class="label label-default">{{ .Head }}</span>{{ end }}</td>
+			</tr>
+		{{ else }}
+			<tr><td>No code files</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "SPLITINDEX" }}
+	{{template "HEADER"}}
+		<h2>Top Down Tracing</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<table class="table table-condensed">
+		<tr>
+			<th>Document</th>
+			<th>Count</th>
+			<th>Pages</th>
+		</tr>
+		{{ range .Docs }}
+			<tr>
+				<td><a href="{{ (index .Pages 0).Name }}">{{ .Title }}</a></td>
+				<td>{{ .Reqs }}</td>
+				<td>{{ range .Pages }}<a href="{{ .Name }}">{{ .Number }}</a> {{ end }}</td>
+			</tr>
+		{{ else }}
+			<tr><td>No requirements</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "SPLITLINKS" }}
+	{{ if .Links }}
+	<p>{{ .Name }}:
+		{{ range .Links }}{{ if .Href }}<a href="{{ .Href }}">{{ .ID }}</a>{{ else }}{{ .ID }}{{ end }} {{ end }}
+	</p>
+	{{ end }}
+{{ end }}
+
+{{ define "SPLITPAGE" }}
+	{{template "HEADER"}}
+		<h2>{{ .Page.Title }}</h2>
+		<p>
+			<a href="index.html">Index</a>
+			{{ if gt .Page.Count 1 }}
+				| Page {{ .Page.Number }} of {{ .Page.Count }}
+				{{ with .Page.Prev }}| <a href="{{ . }}">Previous</a>{{ end }}
+				{{ with .Page.Next }}| <a href="{{ . }}">Next</a>{{ end }}
+			{{ end }}
+		</p>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<ul style="list-style: none; padding: 0; margin: 0;">
+	{{ range .Page.Reqs }}
+		<li>
+		{{ if $.Page.Code }}
+			<h3><a name="{{ .ID }}"></a><a href="file://{{ .Path }}" target="_blank">{{ .ID }}</a>{{ if .TestEnv }} <span class="label label-info">Test environment</span>{{ end }}</h3>
+			{{ template "SPLITLINKS" ($.Links "Implements" .Parents) }}
+			{{ template "SPLITLINKS" ($.Links "Partially implements" .PartiallyImplements) }}
+			{{ template "SPLITLINKS" ($.Links "Verifies" .Verifies) }}
+		{{ else }}
+			{{ template "REQUIREMENT" . }}
+			{{ template "SPLITLINKS" ($.Links "Parents" .Parents) }}
+			{{ template "SPLITLINKS" ($.Links "Children" .Children) }}
+		{{ end }}
+		</li>
+	{{ end }}
+	</ul>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "VERIFICATION" }}
+	{{template "HEADER"}}
+		<h2>Verification Evidence</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<p>{{ range .Counts }}{{ .Status }}: {{ .Count }}&nbsp;&nbsp; {{ end }}</p>
+	<table class="table table-condensed">
+		<tr>
+			<th>Requirement</th>
+			<th>Method</th>
+			<th>Verified by</th>
+			<th>Test Cases</th>
+			<th>Status</th>
+		</tr>
+		{{ range .Evidence }}
+			<tr>
+				<td><strong>{{ .Req.ID }}</strong> {{ .Req.Title }}</td>
+				<td>{{ .Method }}</td>
+				<td>{{ range .Files }}{{ .ID }}<br>{{ end }}</td>
+				<td>{{ range .TestCases }}<a href="{{ .URL }}" target="_blank" title="{{ .Title }}">{{ .ID }}</a> {{ .Result }}{{ if .Executed }} ({{ .Executed }}){{ end }}<br>{{ end }}</td>
+				<td><span class="label {{ if eq .Status "Passed" }}label-success{{ else if or (eq .Status "Failed") (eq .Status "Not verified") }}label-danger{{ else }}label-warning{{ end }}">{{ .Status }}</span></td>
+			</tr>
+		{{ else }}
+			<tr><td>No requirements with a verification method</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "ATTRIBUTES" }}
+	{{template "HEADER"}}
+		<h2>Attribute Statistics</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<table class="table table-condensed">
+		<tr>
+			<th>Attribute</th>
+			<th>Completeness</th>
+			<th>Values</th>
+			<th>Invalid values</th>
+		</tr>
+		{{ range .Stats }}
+			<tr>
+				<td><strong>{{ .Name }}</strong>{{ if .Required }} <small>required</small>{{ end }}</td>
+				<td>{{ if and .Required (lt .Set .Total) }}<span class="text-danger">{{ .Completeness }}</span>{{ else }}{{ .Completeness }}{{ end }}</td>
+				<td>{{ range .Values }}{{ .Value }}: {{ .Count }}<br>{{ end }}</td>
+				<td>{{ range .Invalid }}<span class="text-danger">{{ .ID }}</span><br>{{ end }}</td>
+			</tr>
+		{{ else }}
+			<tr><td>No attributes</td></tr>
+		{{ end }}
+	</table>
+	<h3>Requirements missing required attributes:</h3>
+	<table class="table table-condensed">
+		<tr>
+			<th>Requirement</th>
+			<th>Missing</th>
+		</tr>
+		{{ range .Incomplete }}
+			<tr>
+				<td><strong>{{ .Req.ID }}</strong> {{ .Req.Title }}</td>
+				<td>{{ range .Missing }}<span class="text-danger">{{ . }}</span><br>{{ end }}</td>
+			</tr>
+		{{ else }}
+			<tr><td>None</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "DELETIONS" }}
+	{{template "HEADER"}}
+		<h2>Deleted Requirements</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<p>The requirements marked DELETED, with the commit which marked them and what still references them.</p>
+	<table class="table table-condensed">
+		<tr>
+			<th>Requirement</th>
+			<th>Document</th>
+			<th>Deleted</th>
+			<th>By</th>
+			<th>Referenced by</th>
+		</tr>
+		{{ range .Deletions }}
+			{{ if .Req.Matches $.Filter $.Diffs }}
+			<tr>
+				<td><strong>{{ .Req.ID }}</strong> {{ .Req.Title }}</td>
+				<td>{{ .Req.Path }}</td>
+				<td>{{ if .Commit }}{{ .Date }} <small>{{ .Commit }}</small>{{ else }}Not committed{{ end }}</td>
+				<td>{{ .Author }}</td>
+				<td>{{ range .References }}<span class="text-danger">{{ . }}</span><br>{{ else }}None{{ end }}</td>
+			</tr>
+			{{ end }}
+		{{ else }}
+			<tr><td>No deleted requirements</td></tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "ATTRIBUTECHANGES" }}
+	<table class="table table-condensed">
+		<tr>
+			<th>Requirement</th>
+			<th>Attribute</th>
+			<th>From</th>
+			<th>To</th>
+			<th>Commit</th>
+		</tr>
+		{{ range . }}
+			<tr>
+				<td><strong>{{ .Req.ID }}</strong> {{ .Req.Title }}</td>
+				<td>{{ .Attribute }}</td>
+				<td>{{ .From }}</td>
+				<td>{{ if .Downgrade }}<span class="text-danger">{{ .To }}</span>{{ else }}{{ .To }}{{ end }}</td>
+				<td>{{ .Commit }} {{ .Date }}</td>
+			</tr>
+		{{ else }}
+			<tr><td>None</td></tr>
+		{{ end }}
+	</table>
+{{ end }}
+
+{{ define "HISTORY" }}
+	{{template "HEADER"}}
+		<h2>Attribute History</h2>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<h3>Downgrades:</h3>
+	<p>The classifications changed to less critical ones, which the safety assessment must justify.</p>
+	{{ template "ATTRIBUTECHANGES" .Downgrades }}
+	<h3>Changes:</h3>
+	{{ template "ATTRIBUTECHANGES" .Changes }}
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "AUDIENCENODE" }}
+	{{ template "REQUIREMENT" .Req }}
+	{{ if ne .Req.Level -1 }}
+		{{ if .IsLow }}
+			{{ if .Shows "code" }}
+				{{ template "CODEFILES" .Source.CodeFiles }}
+				{{ template "TESTENVFILES" .Source.TestEnvFiles }}
+			{{ end }}
+			{{ if .Shows "changelists" }}{{ template "CHANGELIST" .Source.Changelists }}{{ end }}
+		{{ else if .Shows "changelists" }}
+			{{ template "TRAILERCHANGELIST" .Source.Changelists }}
+		{{ end }}
+		{{ if .Shows "tasks" }}{{ template "PROBLEMREPORTS" .Source.Tasklists }}{{ end }}
+		{{ if .Children }}
+			<ul>
+			{{ range .Children }}
+				<li>{{ template "AUDIENCENODE" . }}</li>
+			{{ end }}
+			</ul>
+		{{ end }}
+	{{ end }}
+{{ end }}
+
+{{ define "AUDIENCE" }}
+	{{template "HEADER"}}
+		<h2>Top Down Tracing</h2>
+		<h4>For the {{ .Name }}</h4>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<ul style="list-style: none; padding: 0; margin: 0;">
+		{{ range .Roots }}
+			<li>{{ template "AUDIENCENODE" . }}</li>
+		{{ else }}
+			<li class="text-danger">Empty graph</li>
+		{{ end }}
+	</ul>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "SUMMARY" }}
+	{{template "HEADER"}}
+		<h2>Status Summary</h2>
+		<h4>For the {{ .Name }}</h4>
+		<hr>
+	</section>
+	{{ if $.Filter }}<h3><em>Filter Criteria: {{ $.Filter }} </em></h3>{{ end }}
+	<table class="table table-condensed">
+		<tr>
+			<th>Type</th>
+			<th>NOT STARTED</th>
+			<th>STARTED</th>
+			<th>COMPLETED</th>
+			<th>Total</th>
+		</tr>
+		{{ range .Summary }}
+			<tr>
+				<td><strong>{{ .Type }}</strong></td>
+				{{ range .Counts }}<td>{{ . }}</td>{{ end }}
+				<td><strong>{{ .Total }}</strong></td>
+			</tr>
+		{{ else }}
+			<tr><td class="text-danger">Empty graph</td></tr>
+		{{ end }}
+	</table>
+	<table class="table table-condensed">
+		{{ range .Reqs }}
+			<tr>
+				<td><strong>{{ .ID }}</strong> {{ .Title }}</td>
+				<td>{{ template "STATUSFIELD" . }}</td>
+			</tr>
+		{{ end }}
+	</table>
+	{{ template "FOOTER" }}
+{{ end }}
+
+{{ define "SUBMISSION" }}
+<html lang="en">
+	<head>
+		<meta charset="utf-8">
+		<title>{{ .Conf.Title }}</title>
+		<style>
+			body {
+				font-family: "Times New Roman", Times, serif;
+				margin: 0 5%;
+			}
+			@page {
+				@top-center { content: "{{ .Conf.Header }}"; }
+				@bottom-center { content: "{{ .Conf.Footer }} " counter(page) " / " counter(pages); }
+			}
+			.header, .footer {
+				color: gray;
+				text-align: center;
+			}
+			@media print {
+				.header, .footer { display: none; }
+			}
+			h3 { page-break-after: avoid; }
+		</style>
+		<script type="text/javascript" async
+			src="https://cdnjs.cloudflare.com/ajax/libs/mathjax/2.7.1/MathJax.js?config=TeX-AMS-MML_HTMLorMML">
+		</script>
+	</head>
+	<body>
+		<p class="header">{{ .Conf.Header }}</p>
+		<h1>{{ .Conf.Title }}</h1>
+		{{ range .Docs }}
+			<h2>{{ if .Number }}{{ .Number }} {{ end }}{{ .Name }}</h2>
+			{{ range .Reqs }}
+				<h3><a name="{{ .Req.ID }}"></a>{{ if .Number }}{{ .Number }} {{ end }}{{ .Req.ID }} {{ .Req.Title }}</h3>
+				{{ range .Parts }}
+					{{ if .Heading }}<p><strong>{{ .Heading }}:</strong></p>{{ end }}
+					<div>{{ .Content }}</div>
+				{{ end }}
+			{{ end }}
+		{{ else }}
+			<p>No requirements</p>
+		{{ end }}
+		<p class="footer">{{ .Conf.Footer }}</p>
+	</body>
+</html>
+{{ end }}
+
+{{ define "STANDALONE" }}
+<html lang="en">
+	<head>
+		<meta charset="utf-8">
+		<title>Reqtraq Traceability Report</title>
+
+		<!-- No stylesheet nor script is loaded, the report is read offline. -->
+		<style>
+			body {
+				font-family: Roboto, Arial, sans-serif;
+				max-width: 1200px;
+				margin-left: 5%;
+				margin-right: 5%;
+			}
+			a, a:hover {
+				text-decoration: none;
+			}
+			table {
+				border-collapse: collapse;
+				margin-bottom: 20px;
+			}
+			th, td {
+				padding: 4px 8px;
+				border-top: 1px solid #ddd;
+				text-align: left;
+			}
+			.label {
+				padding: .2em .6em .3em;
+				font-size: 75%;
+				font-weight: bold;
+				color: #fff;
+				border-radius: .25em;
+			}
+			.label-default { background-color: #777; }
+			.label-primary { background-color: #337ab7; }
+			.label-success { background-color: #5cb85c; }
+			.label-info { background-color: #5bc0de; }
+			.label-warning { background-color: #f0ad4e; }
+			.label-danger { background-color: #d9534f; }
+			.text-success { color: #3c763d; }
+			.text-warning { color: #8a6d3b; }
+			.text-danger { color: #a94442; }
+			@media print {
+				li { page-break-inside: avoid; }
+			}
+		</style>
+	</head>
+	<body>
+		<section style="max-width:100%; text-align:center;">
+			<h1>Traceability Report</h1>
+			<p>{{ if .Commit }}Commit {{ .Commit }}, {{ end }}generated on {{ .Created }}</p>
+			<hr>
+		</section>
+		<h2>Status Summary</h2>
+		<table>
+			<tr>
+				<th>Type</th>
+				<th>NOT STARTED</th>
+				<th>STARTED</th>
+				<th>COMPLETED</th>
+				<th>Total</th>
+			</tr>
+			{{ range .Summary }}
+				<tr>
+					<td><strong>{{ .Type }}</strong></td>
+					{{ range .Counts }}<td>{{ . }}</td>{{ end }}
+					<td><strong>{{ .Total }}</strong></td>
+				</tr>
+			{{ else }}
+				<tr><td class="text-danger">Empty graph</td></tr>
+			{{ end }}
+		</table>
+		<h2>Top Down Tracing</h2>
+		{{ template "TOPDOWNTREE" . }}
+		<h2>Code Files</h2>
+		<ul>
+		{{ range .Reqs.CodeFilesByPosition }}
+			<li>
+				<strong>{{ .ID }}</strong>{{ if .TestEnv }} <span class="label label-info">Test environment</span>{{ end }}
+				{{ if .Parents }}implements {{ range .Parents }}<a href="#{{ .ID }}">{{ .ID }}</a> {{ end }}{{ end }}
+				{{ if .Verifies }}verifies {{ range .Verifies }}<a href="#{{ .ID }}">{{ .ID }}</a> {{ end }}{{ end }}
+			</li>
+		{{ else }}
+			<li class="text-danger">No code files</li>
+		{{ end }}
+		</ul>
+		<h2>Dangling Requirements</h2>
+		<ul>
+		{{ range .Reqs.DanglingReqsByPosition }}
+			<li>
+				{{ template "REQUIREMENT" ($.Once.Once .) }}
+			</li>
+		{{ else }}
+			<li class="text-success">No dangling HLRs or LLRs found.</li>
+		{{ end }}
+		</ul>
+	</body>
+</html>
+{{ end }}
+
 {{ define "ISSUESFILT" }}
 	{{template "HEADER"}}
 		<h2>Issues</h2>
@@ -302,7 +973,9 @@ var reportTmpl = template.Must(template.New("").Parse(`
 	</ul>
 	{{ template "FOOTER" }}
 {{ end }}
-`))
+`
+
+var reportTmpl = template.Must(template.New("").Parse(reportTemplates))
 
 type reportData struct {
 	Reqs   reqGraph
@@ -323,6 +996,32 @@ func (rg reqGraph) ReportIssues(w io.Writer) error {
 	return reportTmpl.ExecuteTemplate(w, "ISSUES", reportData{rg, nil, Oncer{}, nil})
 }
 
+// standaloneReportData is the data of the standalone report, see ReportStandalone.
+type standaloneReportData struct {
+	Reqs    reqGraph
+	Once    Oncer
+	Commit  string
+	Created string
+	Summary []audienceCount
+}
+
+// @llr REQ-0-DDLN-SWL-054
+// ReportStandalone writes the whole graph as a self-contained HTML report, loading no stylesheet
+// nor script, to be attached to a certification data package: the status summary, the top-down
+// tracing with the attributes, code references and changelists of the requirements, the code files
+// and the dangling requirements, at the commit given if any.
+func (rg reqGraph) ReportStandalone(w io.Writer, commit string, created time.Time) error {
+	var reqs []*Req
+	for _, r := range rg {
+		if r.Level != config.CODE {
+			reqs = append(reqs, r)
+		}
+	}
+	sort.Sort(byLevelModulePosition(reqs))
+	data := standaloneReportData{rg, Oncer{}, commit, created.UTC().Format(time.RFC3339), statusSummary(reqs)}
+	return reportTmpl.ExecuteTemplate(w, "STANDALONE", data)
+}
+
 // @llr REQ-0-DDLN-SWL-006
 func (rg reqGraph) ReportDownFiltered(w io.Writer, f ReqFilter, diffs map[string][]string) error {
 	return reportTmpl.ExecuteTemplate(w, "TOPDOWNFILT", reportData{rg, f, Oncer{}, diffs})
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_ReportStandalone(t *testing.T) {
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	rg := audienceGraph()
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportStandalone(&b, "0123abc", time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)))
+	report := b.String()
+	assert.Contains(t, report, "Commit 0123abc, generated on 2020-03-04T05:06:07Z")
+	for _, s := range []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-001", "Low body.", "Secret rationale", "code/a.go"} {
+		assert.Contains(t, report, s)
+	}
+	assert.True(t, regexp.MustCompile(`(?s)<strong>SWH</strong></td>\s*<td>0</td><td>1</td><td>0</td>\s*<td><strong>1</strong>`).MatchString(report))
+	assert.True(t, regexp.MustCompile(`(?s)<strong>a.go</strong>\s*implements <a href="#REQ-0-TEST-SWL-001">`).MatchString(report))
+	// Self-contained.
+	assert.NotContains(t, report, "<link")
+	assert.NotContains(t, report, "<script")
+}
@@ -8,7 +8,6 @@ package main
 
 import (
 	"bufio"
-	"crypto/sha1"
 	"fmt"
 	"html/template"
 	"io"
@@ -21,6 +20,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/daedaleanai/reqtraq/config"
 	"github.com/daedaleanai/reqtraq/git"
@@ -46,27 +46,74 @@ func (rs RequirementStatus) String() string { return reqStatusToString[rs] }
 
 var (
 	reDiffRev = regexp.MustCompile(`Differential Revision:\s(.*)\s`)
+	// Parents lines, in the .lyx/.md, .org and .toml formats, and requirement definitions in .toml.
+	reParents = regexp.MustCompile(`Parents: REQ-|(?i:^\s*:parents?:)|^\s*(parents|id)\s*=`)
 )
 
 // Req represenents a Requirement Node in the graph of Requirements.
 // The Attributes map has potential elements;
-//  rationale safety_impact verification urgent important mode provenance
+//
+//	rationale safety_impact verification urgent important mode provenance
 type Req struct {
-	ID         string // code files do not have an ID, use Path as primary key
-	Level      config.RequirementLevel
-	Path       string // certification document or code file this was found in relative to repo root
-	FileHash   string // for code files, the sha1 of the contents
-	ParentIds  []string
-	Parents    []*Req
-	Children   []*Req
-	Title      string
+	ID       string // code files do not have an ID, use Path as primary key
+	Level    config.RequirementLevel
+	Path     string // certification document or code file this was found in relative to repo root
+	FileHash string // for code files, the hash of the contents, see formatFileHash
+	// TestEnv is set for the code files of test environments and simulation models, which help verify their parents
+	// rather than implement them.
+	TestEnv bool
+	// VerifiesIds are the requirements a code file verifies, e.g. as a test, referenced with @verifies.
+	// They are neither its parents nor implemented by it.
+	VerifiesIds []string
+	Verifies    []*Req
+	// VerifiedBy are the code files verifying the requirement.
+	VerifiedBy []*Req
+	// VerifiedCriteria are the acceptance criteria referenced by a code file with @verifies, as
+	// "<ID>#AC<n>", see Criteria.
+	VerifiedCriteria []string
+	// Annotations are the references of a code file to the requirements it implements, with their
+	// tags, e.g. @llr or @partially-implements, see annotationTags. Those of the implements kind
+	// are its ParentIds.
+	Annotations []Annotation
+	// PartiallyImplements are the requirements a code file references with a tag of the partial
+	// kind, and PartiallyImplementedBy the code files partially implementing a requirement. They
+	// are neither parents nor children.
+	PartiallyImplements    []*Req
+	PartiallyImplementedBy []*Req
+	// Functions are the functions of a code file referencing requirements, see codeFunctionParsers.
+	Functions []CodeFunction
+	// TestCases are the test cases of the test management tools verifying the requirement, see
+	// ImportTestResults.
+	TestCases []*TestCase
+	// Coverage is the structural coverage of a code file, see MergeLcov.
+	Coverage *CodeCoverage
+	// Progress is the status, the assignee and the comments of the task of a requirement, read back
+	// from the task manager, see ImportTaskProgress.
+	Progress  *taskmgr.TaskProgress
+	ParentIds []string
+	// ExternalParentIds are the parents which don't exist in the branch but in the external graph,
+	// see externalGraph. They are not in Parents.
+	ExternalParentIds []string
+	Parents           []*Req
+	Children          []*Req
+	Title             string
 	// Body contains various HTML tags (links, converted markdown, etc). Type must be HTML,
 	// not a string, so it's not HTML-escaped by the templating engine.
-	Body       template.HTML
-	Attributes map[string]string
-	Position   int
-	Seen       bool
-	Status     RequirementStatus
+	Body template.HTML
+	// storedBody is the location of the body in the store it was moved to, see LoadBody.
+	storedBody *bodyLocation
+	// Rationale, AcceptanceCriteria and Notes are the sections of the body under the headings of
+	// these names, if any, see parseSections.
+	Rationale          template.HTML
+	AcceptanceCriteria template.HTML
+	Notes              template.HTML
+	Attributes         map[string]string
+	Position           int
+	// Section is the section number of a requirement in its certification document, e.g. "3.2.4",
+	// empty if unknown, see setSections.
+	Section string
+	Seen    bool
+	Status  RequirementStatus
 }
 
 // Returns the requirement type for the given requirement, which is one of SYS, SWH, SWL, HWH, HWL or the empty string if
@@ -89,18 +136,50 @@ func (r *Req) resolveUp() {
 func (r *Req) resolveDown() RequirementStatus {
 	r.Seen = true
 	r.Status = COMPLETED
-	if r.Level != config.CODE && len(r.Children) == 0 {
+	implemented := false
+	for _, c := range r.Children {
+		// The test environments don't implement their parents.
+		if c.TestEnv {
+			c.resolveDown()
+			continue
+		}
+		implemented = true
+		if c.resolveDown() != COMPLETED {
+			r.Status = STARTED
+		}
+	}
+	if r.Level != config.CODE && !implemented {
 		r.Status = NOT_STARTED
-	} else {
-		for _, c := range r.Children {
-			if c.resolveDown() != COMPLETED {
-				r.Status = STARTED
-			}
+		// The code partially implementing a requirement starts it.
+		if len(r.PartiallyImplementedBy) > 0 {
+			r.Status = STARTED
 		}
 	}
 	return r.Status
 }
 
+// CodeFiles returns the code files implementing r, the children which are not part of a test environment.
+func (r *Req) CodeFiles() []*Req {
+	var code []*Req
+	for _, c := range r.Children {
+		if c.Level == config.CODE && !c.TestEnv {
+			code = append(code, c)
+		}
+	}
+	return code
+}
+
+// TestEnvFiles returns the code files of the test environments and simulation models helping verify r.
+func (r *Req) TestEnvFiles() []*Req {
+	var files []*Req
+	for _, c := range r.Children {
+		if c.TestEnv {
+			files = append(files, c)
+		}
+	}
+	return files
+}
+
 // IsDeleted checks if the requirement title starts with 'DELETED'
 func (r *Req) IsDeleted() bool {
 	return strings.HasPrefix(r.Title, "DELETED")
@@ -135,18 +214,30 @@ func (r *Req) CheckAttributes(as []map[string]string) []error {
 	return errs
 }
 
-func (r *Req) Tasklists() map[string]*taskmgr.Task {
+// Tasklist holds the tasks of a requirement, as found in the task manager.
+type Tasklist struct {
+	Tasks map[string]*taskmgr.Task
+	// Unavailable is why the tasks couldn't be found, e.g. the task manager isn't reachable, if so.
+	Unavailable string
+}
+
+// Tasklists returns the task of the requirement and the tasks it depends on. If the task manager is not available,
+// the reason is returned instead, so the rest of the reports can still be generated.
+func (r *Req) Tasklists() Tasklist {
 	m := map[string]*taskmgr.Task{}
-	projectID, err1 := taskmgr.TaskMgr.GetProject(config.ProjectName)
-	if err1 != nil {
-		log.Println(err1)
-		return m
+	projectID, err := taskmgr.TaskMgr.GetProject(config.ProjectName)
+	if err != nil {
+		log.Println(err)
+		return Tasklist{Unavailable: err.Error()}
 	}
 	// Find and add primary task corresponding to Req
-	task, err2 := taskmgr.TaskMgr.FindTask(r.ID, r.Title, projectID)
-	if err2 != nil {
-		log.Println(err2)
-		return m
+	task, err := taskmgr.TaskMgr.FindTask(r.ID, r.Title, projectID)
+	if err != nil {
+		log.Println(err)
+		return Tasklist{Unavailable: err.Error()}
+	}
+	if task == nil {
+		return Tasklist{Tasks: m}
 	}
 	m[task.ID] = task
 	// Get all tasks that "task" depends on and add them
@@ -154,42 +245,111 @@ func (r *Req) Tasklists() map[string]*taskmgr.Task {
 		subTask, e := taskmgr.TaskMgr.FindTaskByID(phid)
 		if e != nil {
 			log.Println(e)
-			continue
+			return Tasklist{Tasks: m, Unavailable: e.Error()}
 		}
 		m[subTask.ID] = subTask
 	}
-	return m
+	return Tasklist{Tasks: m}
+}
+
+// Changelist holds the URLs of the changes implementing a requirement, by revision. The commits without differential
+// revision have no URL.
+type Changelist struct {
+	URLs map[string]string
+	// Unavailable is why the changes couldn't be found, e.g. the git history isn't available, if so.
+	Unavailable string
 }
 
 // @llr REQ-0-DDLN-SWL-009
-func (r *Req) Changelists() map[string]string {
-	m := map[string]string{}
+// Changelists returns the changes of the code files implementing a low-level requirement, and the commits declaring
+// they implement a requirement of any level with an Implements-Req: trailer. If the git history is not available, the
+// reason is returned instead, so the rest of the reports can still be generated.
+func (r *Req) Changelists() Changelist {
+	m, err := trailerChangelists(r.ID)
+	if err != nil {
+		log.Println(err)
+		return Changelist{Unavailable: err.Error()}
+	}
 	if r.Level == config.LOW {
 		var paths []string
 		for _, c := range r.Children {
 			paths = append(paths, c.Path)
 		}
-		urls := changelistUrlsForFilepaths(paths)
+		urls, err := changelistUrlsForFilepaths(paths)
+		if err != nil {
+			log.Println(err)
+			return Changelist{Unavailable: err.Error()}
+		}
 		for _, url := range urls {
 			fields := strings.Split(url, "/")
 			m[fields[len(fields)-1]] = url
 		}
 	}
+	return Changelist{URLs: m}
+}
+
+var reImplementsReq = regexp.MustCompile(`^Implements-Req:\s*(.*)$`)
+
+// @llr REQ-0-DDLN-SWL-032
+// trailerChangelists returns the changelists of the commits with an Implements-Req: trailer listing
+// the requirement id, e.g. for deletions or build changes, whose files carry no @llr annotations.
+// They are keyed as the changelists of the code, by their differential revision if any, else by
+// their abbreviated hash, without URL.
+func trailerChangelists(id string) (map[string]string, error) {
+	res, err := linepipes.All(linepipes.Run("git", "-C", git.RepoPath(), "log", "--fixed-strings", "--grep="+id, "--format=%x1e%h%n%B"))
+	if err != nil {
+		return nil, fmt.Errorf("Could not read the git history of %s: %v", git.RepoPath(), err)
+	}
+	return parseTrailerChangelists(id, res), nil
+}
+
+// parseTrailerChangelists returns the changelists implementing the requirement id among the commits
+// of the log, each starting with \x1e followed by its abbreviated hash and its message.
+func parseTrailerChangelists(id, log string) map[string]string {
+	m := map[string]string{}
+	for _, commit := range strings.Split(log, "\x1e") {
+		lines := strings.Split(commit, "\n")
+		implements := false
+		for _, line := range lines[1:] {
+			parts := reImplementsReq.FindStringSubmatch(strings.TrimSpace(line))
+			if len(parts) == 0 {
+				continue
+			}
+			for _, ref := range strings.FieldsFunc(parts[1], func(c rune) bool { return c == ',' || unicode.IsSpace(c) }) {
+				if ref == id {
+					implements = true
+				}
+			}
+		}
+		if !implements {
+			continue
+		}
+		if rev := reDiffRev.FindStringSubmatch(commit); len(rev) > 0 {
+			fields := strings.Split(rev[1], "/")
+			m[fields[len(fields)-1]] = rev[1]
+			continue
+		}
+		m[strings.TrimSpace(lines[0])] = ""
+	}
 	return m
 }
 
-func changelistUrlsForFilepaths(filepaths []string) []string {
+func changelistUrlsForFilepaths(filepaths []string) ([]string, error) {
 	var urls []string
 	for _, path := range filepaths {
-		urls = append(urls, changelistUrlsForFilepath(path)...)
+		u, err := changelistUrlsForFilepath(path)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, u...)
 	}
-	return urls
+	return urls, nil
 }
 
-func changelistUrlsForFilepath(filepath string) []string {
+func changelistUrlsForFilepath(filepath string) ([]string, error) {
 	res, err := linepipes.All(linepipes.Run("git", "-C", path.Dir(filepath), "log", filepath))
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("Could not read the git history of %s: %v", filepath, err)
 	}
 
 	matches := reDiffRev.FindAllStringSubmatch(res, -1)
@@ -200,31 +360,37 @@ func changelistUrlsForFilepath(filepath string) []string {
 
 	var urls []string
 	for _, m := range matches {
-		if len(m) != 2 {
-			log.Fatal("Count not extract changelist substring for filepath: ", filepath)
-		}
 		urls = append(urls, m[1])
 	}
 
-	return urls
+	return urls, nil
 }
 
 // @llr REQ-0-DDLN-SWL-015
 // A ReqGraph maps IDs and Paths to Req structures.
 type reqGraph map[string]*Req
 
-func CreateReqGraph(certdocPath, codePath string) (reqGraph, error) {
+// CreateReqGraph parses the certification documents under certdocPath and the code files under codePath and the
+// testEnvPaths, the directories of the test environments and simulation models. The code files of the test
+// environments are marked as such, they help verify their requirements rather than implement them.
+func CreateReqGraph(certdocPath, codePath string, testEnvPaths ...string) (reqGraph, error) {
 	rg := reqGraph{}
 	errorResult := ""
+	parseErrors := false
 
+	// The files and directories of the ignore file are skipped, e.g. vendored third-party sources.
+	ignore, err := loadIgnoreRules(git.RepoPath())
+	if err != nil {
+		return rg, err
+	}
 	_ = filepath.Walk(filepath.Join(git.RepoPath(), certdocPath),
-		func(fileName string, info os.FileInfo, err error) error {
+		ignore.walkFunc(git.RepoPath(), func(fileName string, info os.FileInfo, err error) error {
 			var errs []error
-			switch strings.ToLower(path.Ext(fileName)) {
-			case ".lyx", ".md":
+			if docParser(fileName) != nil {
 				errs = parseCertdocToGraph(fileName, rg)
 			}
 			if len(errs) > 0 {
+				parseErrors = true
 				errorResult += "Problems found while parsing " + fileName + ":\n"
 				for _, v := range errs {
 					errorResult += "\t" + v.Error() + "\n"
@@ -232,39 +398,66 @@ func CreateReqGraph(certdocPath, codePath string) (reqGraph, error) {
 				errorResult += "\n"
 			}
 			return nil
-		})
+		}))
 
 	// walk the code
-	_ = filepath.Walk(filepath.Join(git.RepoPath(), codePath), func(fileName string, info os.FileInfo, err error) error {
-		switch strings.ToLower(path.Ext(fileName)) {
-		case ".cc", ".c", ".h", ".hh", ".go":
-			// TODO (pk,lb): do that in a nicer way without hard-coded folder names
-			if strings.Contains(codePath, "testdata") || !strings.Contains(fileName, "testdata") {
+	inTestEnv := func(fileName string) bool {
+		for _, p := range testEnvPaths {
+			if isWithin(filepath.Join(git.RepoPath(), p), fileName) {
+				return true
+			}
+		}
+		return false
+	}
+	walkCode := func(codePath string, testEnv bool) {
+		_ = filepath.Walk(filepath.Join(git.RepoPath(), codePath), ignore.walkFunc(git.RepoPath(), func(fileName string, info os.FileInfo, err error) error {
+			if lang := codeFileLanguage(codePath, fileName); lang != nil && inTestEnv(fileName) == testEnv && rg[fileName] == nil {
 				id := relativePathToRepo(fileName, git.RepoPath())
 				if id == "" {
 					log.Fatal("Malformed code file path")
 				}
-				err = parseCode(id, fileName, rg)
+				err = parseCode(id, fileName, lang.references(), rg)
 				if err != nil {
+					parseErrors = true
 					errorResult += err.Error()
 					errorResult += "\n"
 				}
+				if r := rg[fileName]; r != nil {
+					r.TestEnv = testEnv
+				}
 			}
-		}
-		return nil
-	})
+			return nil
+		}))
+	}
+	walkCode(codePath, false)
+	for _, p := range testEnvPaths {
+		walkCode(p, true)
+	}
 
-	err := rg.Resolve()
+	err = rg.Resolve()
 	if err != nil {
 		errorResult += err.Error()
 	}
 
 	if errorResult != "" {
-		return rg, fmt.Errorf(errorResult)
+		return rg, graphError(parseErrors, errorResult)
 	}
 	return rg, nil
 }
 
+// codeFileLanguage returns the language of the code file found under codePath, nil if it isn't a code file.
+func codeFileLanguage(codePath, fileName string) *codeLanguage {
+	lang, ok := codeFileNames[filepath.Base(fileName)]
+	if !ok {
+		lang, ok = codeLanguages[strings.ToLower(path.Ext(fileName))]
+	}
+	// TODO (pk,lb): do that in a nicer way without hard-coded folder names
+	if !ok || !strings.Contains(codePath, "testdata") && strings.Contains(fileName, "testdata") {
+		return nil
+	}
+	return lang
+}
+
 // relativePathToRepo returns filePath relative to repoPath by
 // removing the path to the repository from filePath
 func relativePathToRepo(filePath, repoPath string) string {
@@ -295,38 +488,25 @@ func (rg reqGraph) CheckAttributes(as []map[string]string) []error {
 	return errs
 }
 
+// checkReqReferences checks the references of the certification documents under certdocPath in
+// the repository at repoPath, but for those of the ignore file.
 // @llr REQ-0-DDLN-SWL-004
-func (rg reqGraph) checkReqReferences(certdocPath string) error {
-	reParents := regexp.MustCompile(`Parents: REQ-`)
-
+func (rg reqGraph) checkReqReferences(repoPath, certdocPath string) error {
 	errorResult := ""
 
-	err := filepath.Walk(filepath.Join(git.RepoPath(), certdocPath),
-		func(fileName string, info os.FileInfo, err error) error {
-			r, err := os.Open(fileName)
+	ignore, err := loadIgnoreRules(repoPath)
+	if err != nil {
+		return err
+	}
+	err = filepath.Walk(filepath.Join(repoPath, certdocPath),
+		ignore.walkFunc(repoPath, func(fileName string, info os.FileInfo, err error) error {
+			result, err := rg.checkFileReqReferences(fileName)
 			if err != nil {
 				return err
 			}
-
-			scan := bufio.NewScanner(r)
-			for lno := 1; scan.Scan(); lno++ {
-				line := scan.Text()
-				// parents have alreay been checked in Resolve(), and we don't throw an eror at the place where the deleted req is defined
-				discardRefToDeleted := reParents.MatchString(line) || ReReqDeleted.MatchString(line)
-				parmatch := ReReqID.FindAllStringSubmatchIndex(line, -1)
-
-				for _, ids := range parmatch {
-					reqID := line[ids[0]:ids[1]]
-					v, reqFound := rg[reqID]
-					if !reqFound {
-						errorResult += "Invalid reference to inexistent requirement " + reqID + " in " + fileName + ":" + strconv.Itoa(lno) + "\n"
-					} else if v.IsDeleted() && !discardRefToDeleted {
-						errorResult += "Invalid reference to deleted requirement " + reqID + " in " + fileName + ":" + strconv.Itoa(lno) + "\n"
-					}
-				}
-			}
+			errorResult += result
 			return nil
-		})
+		}))
 
 	if err != nil {
 		return err
@@ -338,6 +518,38 @@ func (rg reqGraph) checkReqReferences(certdocPath string) error {
 	return nil
 }
 
+// checkFileReqReferences returns the invalid references found in the given file, one per line.
+func (rg reqGraph) checkFileReqReferences(fileName string) (string, error) {
+	r, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	errorResult := ""
+	scan := bufio.NewScanner(r)
+	for lno := 1; scan.Scan(); lno++ {
+		line := scan.Text()
+		// parents have alreay been checked in Resolve(), and we don't throw an eror at the place where the deleted req is defined
+		discardRefToDeleted := reParents.MatchString(line) || ReReqDeleted.MatchString(line)
+		parmatch := ReReqID.FindAllStringSubmatchIndex(line, -1)
+
+		for _, ids := range parmatch {
+			reqID := line[ids[0]:ids[1]]
+			v, reqFound := rg[reqID]
+			if !reqFound {
+				// The parents external to the branch are reported by precommit.
+				if !reParents.MatchString(line) || !externalGraph.defines(reqID) {
+					errorResult += "Invalid reference to inexistent requirement " + reqID + " in " + fileName + ":" + strconv.Itoa(lno) + "\n"
+				}
+			} else if v.IsDeleted() && !discardRefToDeleted {
+				errorResult += "Invalid reference to deleted requirement " + reqID + " in " + fileName + ":" + strconv.Itoa(lno) + "\n"
+			}
+		}
+	}
+	return errorResult, nil
+}
+
 func (rg reqGraph) AddCodeRefs(id, fileName, fileHash string, reqIds []string) {
 	rg[fileName] = &Req{ID: id, Path: fileName, FileHash: fileHash, ParentIds: reqIds, Level: config.CODE}
 }
@@ -347,11 +559,18 @@ func (rg reqGraph) Resolve() error {
 	errorResult := ""
 
 	for _, req := range rg {
-		if len(req.ParentIds) == 0 && req.Level != config.SYSTEM {
-			errorResult += "Requirement " + req.ID + " in file " + req.Path + " has no parents.\n"
+		// The code files verifying requirements only, e.g. tests, have no parents.
+		if len(req.ParentIds) == 0 && req.Level != config.SYSTEM && len(req.VerifiesIds) == 0 && len(req.Annotations) == 0 {
+			errorResult += "Requirement " + req.ID + " in file " + req.Location() + " has no parents.\n"
 		}
 		for _, parentID := range req.ParentIds {
 			parent := rg[parentID]
+			if parent != nil && req.Level == config.CODE {
+				if err := checkCodeReference(req, parent); err != "" {
+					errorResult += err
+					continue
+				}
+			}
 			if parent != nil {
 				if parent.IsDeleted() && !req.IsDeleted() {
 					if req.Level != config.CODE {
@@ -362,6 +581,8 @@ func (rg reqGraph) Resolve() error {
 				}
 				parent.Children = append(parent.Children, req)
 				req.Parents = append(req.Parents, parent)
+			} else if externalGraph.defines(parentID) {
+				req.ExternalParentIds = append(req.ExternalParentIds, parentID)
 			} else {
 				if req.Level != config.CODE {
 					errorResult += "Invalid parent of requirement " + req.ID + ": " + parentID + " does not exist.\n"
@@ -370,6 +591,9 @@ func (rg reqGraph) Resolve() error {
 				}
 			}
 		}
+		errorResult += rg.resolveVerifies(req)
+		errorResult += rg.resolvePartial(req)
+		errorResult += rg.checkVerifiedCriteria(req)
 	}
 
 	if errorResult != "" {
@@ -386,12 +610,21 @@ func (rg reqGraph) Resolve() error {
 	for _, req := range rg {
 		sort.Sort(byPosition(req.Parents))
 		sort.Sort(byPosition(req.Children))
+		// The positions of the code files aren't known yet.
+		sort.Sort(byIDOrPath(req.VerifiedBy))
+		sort.Sort(byIDOrPath(req.PartiallyImplementedBy))
 	}
 
 	for _, req := range rg {
 		if req.Level == config.CODE {
 			req.resolveUp()
-			req.Position = req.Parents[0].Position
+			if len(req.Parents) > 0 {
+				req.Position = req.Parents[0].Position
+			} else if len(req.Verifies) > 0 {
+				req.Position = req.Verifies[0].Position
+			} else if len(req.PartiallyImplements) > 0 {
+				req.Position = req.PartiallyImplements[0].Position
+			}
 		}
 	}
 	return nil
@@ -420,18 +653,87 @@ func (rg reqGraph) CodeFilesByPosition() []*Req {
 }
 
 // Updates the tasks associated with each requirement.For each requirement in rg, the method will:
-// - find the task associated with the requirement, by searching for the requirement ID in the task title using the taskmgr API
-// - if a task was found and the requirement was not deleted, its title and description are updated
-// - if a task was found and the requirement was deleted, the task is set as INVALID
-// - if the task was not found, it is created and filled in with the following values:
-// 	Title: <Req ID> <Req Title>
-//	Description: <Requirement Body>
-//	Status: Open
-//	Tags: Project Abbreviation (e.g. DDLN, VXU, etc.)
-//      Parents: the first parent task (Phabricator doesn't yet support multiple parents in the api)
+//   - find the task associated with the requirement, by searching for the requirement ID in the task title using the taskmgr API
+//   - if a task was found and the requirement was not deleted, its title, description and parents are updated, if they
+//     changed since they were last written, see taskSyncState
+//   - if a task was found and the requirement was deleted, the task is set as INVALID
+//   - if the task was not found, it is created and filled in with the following values:
+//     Title: <Req ID> <Req Title>
+//     Description: <Requirement Body>, after the section of the requirement if known
+//     Status: Open
+//     Tags: Project Abbreviation (e.g. DDLN, VXU, etc.), and the tags of the requirement attributes, see TagRule
+//     Parents: the tasks of all the parents, or the first one if the task manager supports a single parent
+//
+// If the title or the description to be updated was also edited in the task manager since it was last written, it is
+// left unchanged unless force is set, and the conflict is returned. The tasks created, updated and deleted are returned
+// as the created, updated and deleted events of their requirements, for the webhooks. With dryRun, the changes are
+// written to out rather than made, and none is returned, see dryRunTaskManager. With queue, the changes are planned in
+// the journal first, then made, so the sync interrupted is resumed with ResumeTaskSync, see taskJournal.
 // The method performs a breadth-first search of the requirement graph, which ensures that all parent tasks have already
 // been created by the time a child is visited.
-func (rg reqGraph) UpdateTasks(filterIDs map[string]bool) error {
+func (rg reqGraph) UpdateTasks(filterIDs map[string]bool, tagRules []TagRule, calls taskmgr.CallConf, force, dryRun, queue bool, out io.Writer) ([]TaskConflict, []LifecycleEvent, error) {
+	tagger, err := newTagger(tagRules)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmgr, err := taskmgr.NewSyncTaskManager(taskmgr.TaskMgr, calls)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(tmgr taskmgr.TaskManager) { taskmgr.TaskMgr = tmgr }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tmgr
+	statePath, err := taskSyncStatePath()
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := loadTaskSyncState(statePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error reading the task sync state %s: %v", statePath, err)
+	}
+	if dryRun {
+		taskmgr.TaskMgr = newDryRunTaskManager(taskmgr.TaskMgr, out)
+		conflicts, _, err := rg.updateTasks(filterIDs, tagger, force, state)
+		return conflicts, nil, err
+	}
+	journalPath, err := taskJournalPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	if j, err := loadTaskJournal(journalPath); err != nil || j != nil {
+		if err == nil {
+			err = fmt.Errorf("A queued sync of the tasks is pending in %s, resume it with reqtraq updatetasks --resume", journalPath)
+		}
+		return nil, nil, err
+	}
+	if queue {
+		planner := newJournalTaskManager(tmgr)
+		taskmgr.TaskMgr = planner
+		// The changes planned despite the requirements failing to sync are made, as without queue.
+		conflicts, events, err := rg.updateTasks(filterIDs, tagger, force, state)
+		j := &taskJournal{path: journalPath, ops: planner.ops, state: state, events: events, ids: map[string]string{}}
+		if writeErr := j.write(); writeErr != nil {
+			return conflicts, nil, writeErr
+		}
+		events, finishErr := j.finish(tmgr, statePath)
+		if finishErr != nil {
+			return conflicts, nil, finishErr
+		}
+		return conflicts, events, err
+	}
+	// Save the progress even if some update failed, and report the tasks changed before.
+	conflicts, events, err := rg.updateTasks(filterIDs, tagger, force, state)
+	if saveErr := state.save(statePath); err == nil {
+		err = saveErr
+	}
+	return conflicts, events, err
+}
+
+func (rg reqGraph) updateTasks(filterIDs map[string]bool, tagger *tagger, force bool, state *taskSyncState) ([]TaskConflict, []LifecycleEvent, error) {
+	var conflicts []TaskConflict
+	var events []LifecycleEvent
+	// The requirements failing to sync are reported at the end, rather than aborting the sync.
+	var failures taskSyncFailures
+	mapped := taskmgr.MappedAttributes(taskmgr.TaskMgr)
 	queue := rg.OrdsByPosition()  // breadth-first traversal queue
 	enqueued := map[string]bool{} // set of elements that have already been enqueued for traversal
 	reqIDToTaskPHID := map[string]string{}
@@ -440,23 +742,23 @@ func (rg reqGraph) UpdateTasks(filterIDs map[string]bool) error {
 	const projectNameLLR = config.ProjectName
 	sysProjectID, err := taskmgr.TaskMgr.GetOrCreateProject(projectNameSYS, "")
 	if err != nil {
-		return err
+		return nil, events, err
 	}
 
 	hlrsProjectID, err := taskmgr.TaskMgr.GetOrCreateProject(projectNameHLR, sysProjectID)
 	if err != nil {
-		return err
+		return nil, events, err
 	}
 
 	llrsProjectID, err := taskmgr.TaskMgr.GetOrCreateProject(config.ProjectName, hlrsProjectID)
 	if err != nil {
-		return err
+		return nil, events, err
 	}
 
 	parentTaskTitle := "Implement " + config.ProjectName
 	parentOfAll, err := taskmgr.TaskMgr.FindTaskByTitle(parentTaskTitle, sysProjectID)
 	if err != nil {
-		return err
+		return nil, events, err
 	}
 	parentOfAllPHID := ""
 	if parentOfAll == nil {
@@ -465,23 +767,36 @@ func (rg reqGraph) UpdateTasks(filterIDs map[string]bool) error {
 		parentOfAllPHID, err = taskmgr.TaskMgr.CreateTask(parentTaskTitle, "Meta-task that incorporates all tasks needed to implement "+config.ProjectName,
 			sysProjectID, map[string]string{}, []string{})
 		if err != nil {
-			return fmt.Errorf("Error creating parent of all tasks, %v", err)
+			return nil, events, fmt.Errorf("Error creating parent of all tasks, %v", err)
 		}
 	} else {
 		parentOfAllPHID = parentOfAll.ID
 	}
 
 	taskLevelToProjectPHID := map[config.RequirementLevel]string{config.SYSTEM: sysProjectID, config.HIGH: hlrsProjectID, config.LOW: llrsProjectID}
+	synced := 0
 	for len(queue) > 0 {
 		currentReq := queue[0]
 		queue = queue[1:]
 		if currentReq.Level == config.CODE {
 			continue
 		}
+		for _, childReq := range currentReq.Children {
+			if _, ok := enqueued[childReq.ID]; !ok {
+				enqueued[childReq.ID] = true
+				queue = append(queue, childReq)
+			}
+		}
+		synced++
 		projectPHID := taskLevelToProjectPHID[currentReq.Level]
 		task, err := taskmgr.TaskMgr.FindTask(currentReq.ID, currentReq.Title, projectPHID)
 		if err != nil {
-			return fmt.Errorf("Error finding task for requirement %s, caused by\n%v", currentReq.ID, err)
+			failures.add(currentReq.ID, fmt.Errorf("Error finding task for requirement %s, caused by\n%v", currentReq.ID, err))
+			continue
+		}
+		if task != nil {
+			reqIDToTaskPHID[currentReq.ID] = task.ID
+			task = state.readSyncTrailer(currentReq.ID, task)
 		}
 
 		var parentTaskIDs []string
@@ -492,55 +807,94 @@ func (rg reqGraph) UpdateTasks(filterIDs map[string]bool) error {
 			for _, parentReq := range currentReq.Parents {
 				taskID, ok := reqIDToTaskPHID[parentReq.ID]
 				if !ok {
-					return fmt.Errorf("Error updating requirement %s. Parent %s has no corresponding task", currentReq.ID, parentReq.ID)
+					err = fmt.Errorf("Error updating requirement %s. Parent %s has no corresponding task", currentReq.ID, parentReq.ID)
+					break
 				}
 				parentTaskIDs = append(parentTaskIDs, taskID)
 			}
+			if err != nil {
+				failures.add(currentReq.ID, err)
+				continue
+			}
 		}
 		//TODO: add support for deleted tasks
 		if filterIDs[currentReq.ID] { // don't update requirements that are filtered
+			title, body := currentReq.ID+": "+currentReq.Title, currentReq.taskDescription()
 			if task == nil {
 				if !currentReq.IsDeleted() {
 					log.Printf("Creating task for requirement %s", currentReq.ID)
 
-					taskPHID, err := taskmgr.TaskMgr.CreateTask(currentReq.ID+": "+currentReq.Title, string(currentReq.Body),
-						projectPHID, currentReq.Attributes, parentTaskIDs)
+					taskPHID, err := taskmgr.TaskMgr.CreateTask(title, withSyncTrailer(title, body), projectPHID, currentReq.Attributes, parentTaskIDs)
 					if err != nil {
-						return fmt.Errorf("Error creating requirement %s, caused by\n%v", currentReq.ID, err)
+						failures.add(currentReq.ID, fmt.Errorf("Error creating requirement %s, caused by\n%v", currentReq.ID, err))
+						continue
 					}
 					reqIDToTaskPHID[currentReq.ID] = taskPHID
+					events = append(events, LifecycleEvent{Kind: eventCreated, ID: currentReq.ID, Task: taskPHID})
+					entry := newTaskSyncEntry(title, body, parentTaskIDs)
+					entry.Attributes = hashTaskAttributes(currentReq.Attributes, mapped)
+					entry.Tags = tagger.tags(currentReq)
+					if err := tagger.syncTaskTags(taskPHID, entry.Tags, nil); err != nil {
+						failures.add(currentReq.ID, fmt.Errorf("Error tagging requirement %s, caused by\n%v", currentReq.ID, err))
+						continue
+					}
+					state.Tasks[currentReq.ID] = entry
 				}
 			} else {
 				if currentReq.IsDeleted() {
 					if task.Status != "invalid" {
 						log.Printf("Marking task T%s for DELETED requirement %s as invalid", task.ID, currentReq.ID)
 
-						err = taskmgr.TaskMgr.DeleteTask(task.ID, currentReq.ID+": "+currentReq.Title, projectPHID)
+						err = taskmgr.TaskMgr.DeleteTask(task.ID, title, projectPHID)
 						if err != nil {
-							return fmt.Errorf("Error updating requirement %s, caused by\n%v", currentReq.ID, err)
+							failures.add(currentReq.ID, fmt.Errorf("Error updating requirement %s, caused by\n%v", currentReq.ID, err))
+							continue
 						}
+						events = append(events, LifecycleEvent{Kind: eventDeleted, ID: currentReq.ID, Task: task.ID})
 					}
+					delete(state.Tasks, currentReq.ID)
 				} else {
-					log.Printf("Updating task T%s for requirement %s", task.ID, currentReq.ID)
-					err = taskmgr.TaskMgr.UpdateTask(task.ID, currentReq.ID+": "+currentReq.Title, string(currentReq.Body),
-						projectPHID, currentReq.Attributes, parentTaskIDs)
-					if err != nil {
-						return fmt.Errorf("Error updating requirement %s, caused by\n%v", currentReq.ID, err)
+					entry := newTaskSyncEntry(title, body, parentTaskIDs)
+					entry.Attributes = hashTaskAttributes(currentReq.Attributes, mapped)
+					fields := state.changedFields(currentReq.ID, entry)
+					if len(mapped) == 0 {
+						fields &^= taskmgr.TaskAttributes
+					}
+					edited := state.editedFields(currentReq.ID, task, mapped)
+					if conflicting := fields & edited; conflicting != 0 && !force {
+						// Keep the conflicting fields as last written, so the conflict is reported until resolved.
+						conflicts = append(conflicts, TaskConflict{currentReq.ID, task.ID, conflicting})
+						fields &^= conflicting
+						entry = entry.keeping(state.Tasks[currentReq.ID], conflicting)
 					}
+					written := fields
+					if fields&taskmgr.TaskTitle != 0 && edited&taskmgr.TaskDescription == 0 {
+						// The description unedited is written along with the title, for its trailer.
+						written |= taskmgr.TaskDescription
+					}
+					if fields != 0 {
+						log.Printf("Updating %s of task T%s for requirement %s", fields, task.ID, currentReq.ID)
+						err = taskmgr.TaskMgr.UpdateTask(task.ID, title, withSyncTrailer(title, body), projectPHID, currentReq.Attributes, parentTaskIDs, written)
+						if err != nil {
+							failures.add(currentReq.ID, fmt.Errorf("Error updating requirement %s, caused by\n%v", currentReq.ID, err))
+							continue
+						}
+						events = append(events, LifecycleEvent{Kind: eventUpdated, ID: currentReq.ID, Task: task.ID, Fields: fields.String()})
+					}
+					entry.Tags = tagger.tags(currentReq)
+					if err := tagger.syncTaskTags(task.ID, entry.Tags, state.Tasks[currentReq.ID].Tags); err != nil {
+						failures.add(currentReq.ID, fmt.Errorf("Error tagging requirement %s, caused by\n%v", currentReq.ID, err))
+						continue
+					}
+					state.Tasks[currentReq.ID] = entry
 				}
 			}
 		}
-		if task != nil {
-			reqIDToTaskPHID[currentReq.ID] = task.ID
-		}
-		for _, childReq := range currentReq.Children {
-			if _, ok := enqueued[childReq.ID]; !ok {
-				enqueued[childReq.ID] = true
-				queue = append(queue, childReq)
-			}
-		}
 	}
-	return nil
+	if len(failures) > 0 {
+		return conflicts, events, failures.error(synced)
+	}
+	return conflicts, events, nil
 }
 
 func (rg reqGraph) DanglingReqsByPosition() []*Req {
@@ -566,59 +920,121 @@ func (a byPosition) Len() int           { return len(a) }
 func (a byPosition) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byPosition) Less(i, j int) bool { return a[i].Position < a[j].Position }
 
-var reLLRReference = regexp.MustCompile(`//\s*@llr\s*(REQ-\d+-\w+-SWL-\d+).*`)
+// reLLRReference matches a reference in a // comment, see compileReferences.
+var reLLRReference *regexp.Regexp
 
-func parseCode(id, fileName string, graph reqGraph) error {
+// parseCode adds the code file to the graph if it references low-level requirements, as found in
+// its lines by llrRef.
+func parseCode(id, fileName string, llrRef func(line string) string, graph reqGraph) error {
 	f, err := os.Open(fileName)
 	if err != nil {
 		return err
 	}
-	var refs []string
-	h := sha1.New()
-	// git compatible hash
-	if s, err := f.Stat(); err == nil {
-		fmt.Fprintf(h, "blob %d", s.Size())
-		h.Write([]byte{0})
+	var refs, verifies []string
+	var annotations []Annotation
+	// refLines are the references by line number, to find the functions referencing them.
+	refLines := map[int][]string{}
+	tag := ""
+	var defined codeRequirementsReader
+	s, err := f.Stat()
+	if err != nil {
+		return err
 	}
+	// git compatible hash
+	h := newFileHash(fileHashAlgorithm, s.Size())
 
-	scanner := bufio.NewScanner(io.TeeReader(f, h))
-	for scanner.Scan() {
-		if parts := reLLRReference.FindStringSubmatch(scanner.Text()); len(parts) > 0 {
-			refs = append(refs, parts[1])
+	r, err := codeReader(fileName, io.TeeReader(f, h))
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %v", fileName, err)
+	}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if ref := llrRef(scanner.Text()); ref != "" {
+			tag = annotationTag(scanner.Text(), ref, tag)
+			annotations = append(annotations, Annotation{tag, ref})
+			// The references of the partial kind aren't parents.
+			if annotationTags[tag] != partialAnnotation {
+				refs = append(refs, ref)
+				refLines[line] = append(refLines[line], ref)
+			}
+		}
+		verifies = append(verifies, verifiesReferences(scanner.Text())...)
+		if codeRequirements {
+			defined.readLine(line, scanner.Text())
 		}
 	}
 	if err := scanner.Err(); err != nil {
 		return err
 	}
-	if len(refs) > 0 {
-		graph.AddCodeRefs(id, fileName, string(h.Sum(nil)), refs)
+	defined.end()
+	// The code file defining requirements implements them.
+	for _, c := range defined.reqs {
+		refLines[c.line] = append(refLines[c.line], c.id)
+	}
+	definedIds, err := addCodeRequirements(fileName, defined.reqs, graph)
+	if err != nil {
+		return err
+	}
+	refs = append(refs, definedIds...)
+	var verifiesIds, criteria []string
+	for _, v := range verifies {
+		if strings.Contains(v, "#") {
+			criteria = append(criteria, v)
+		}
+		reqID := strings.SplitN(v, "#", 2)[0]
+		found := false
+		for _, ref := range verifiesIds {
+			found = found || ref == reqID
+		}
+		if !found {
+			verifiesIds = append(verifiesIds, reqID)
+		}
+	}
+	if len(annotations) > 0 || len(verifiesIds) > 0 || len(definedIds) > 0 {
+		graph.AddCodeRefs(id, fileName, formatFileHash(fileHashAlgorithm, h.Sum(nil)), refs)
+		graph[fileName].Annotations = annotations
+		graph[fileName].VerifiesIds = verifiesIds
+		graph[fileName].VerifiedCriteria = criteria
+		if parse, ok := codeFunctionParsers[strings.ToLower(filepath.Ext(fileName))]; ok {
+			src, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				return err
+			}
+			graph[fileName].Functions = codeFunctions(parse, src, refLines)
+		}
 	}
 	return nil
 }
 
+// parseCertdocToGraph parses the certification document with the parser registered for its format
+// and adds its requirements to the graph.
 func parseCertdocToGraph(fileName string, graph reqGraph) []error {
-	reqs, err := ParseCertdoc(fileName)
-	if err != nil {
+	if err := IsValidDocName(fileName); err != nil {
 		return []error{fmt.Errorf("Error parsing %s: %v", fileName, err)}
 	}
-	isReqPresent := make([]bool, len(reqs))
+	reqs, errs := docParser(fileName).ParseDoc(fileName)
+	// The requirements which failed to parse still count for the sequence numbers.
+	nReqs := len(reqs) + len(errs)
+	isReqPresent := make([]bool, nReqs)
+	if err := setSections(fileName, reqs); err != nil {
+		errs = append(errs, fmt.Errorf("Error numbering the sections of %s: %v", fileName, err))
+	}
 
-	var errs []error
-	for i, v := range reqs {
-		r, err := ParseReq(v)
-		if err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		errs2 := lintLyxReq(fileName, len(reqs), isReqPresent, r)
+	for i, r := range reqs {
+		errs2 := lintLyxReq(fileName, nReqs, isReqPresent, r)
 		if len(errs2) != 0 {
 			errs = append(errs, errs2...)
 			continue
 		}
 		r.Position = i
+		r.parseSections()
 		graph.AddReq(r, fileName)
+		if bodies != nil && graph[r.ID] == r {
+			if err := bodies.put(r); err != nil {
+				errs = append(errs, fmt.Errorf("Error storing the body of %s: %v", r.ID, err))
+			}
+		}
 	}
-
 	return errs
 }
 
@@ -647,7 +1063,7 @@ func (r *Req) Matches(filter ReqFilter, diffs map[string][]string) bool {
 				return false
 			}
 		case BodyFilter:
-			if !e.MatchString(string(r.Body)) {
+			if !e.MatchString(string(r.LoadBody())) {
 				return false
 			}
 		}
@@ -661,23 +1077,36 @@ func (r *Req) Matches(filter ReqFilter, diffs map[string][]string) bool {
 
 func NextId(f string) (string, error) {
 	var (
-		reqs      []string
+		reqIDs    []string
 		reqID     string
 		nextReqID string
 	)
 
-	reqs, err := ParseCertdoc(f)
-	if err != nil {
-		return "", err
+	ext := strings.ToLower(path.Ext(f))
+	if ext == ".toml" {
+		// No need to parse the whole requirements.
+		doc, err := readTomlDoc(f)
+		if err != nil {
+			return "", err
+		}
+		for _, r := range doc.Requirements {
+			reqIDs = append(reqIDs, r.ID)
+		}
+	} else if p := docParser(f); p != nil {
+		reqs, errs := p.ParseDoc(f)
+		if len(errs) > 0 {
+			return "", errs[0]
+		}
+		for _, r := range reqs {
+			reqIDs = append(reqIDs, r.ID)
+		}
 	}
 
 	nextId := 1
-	if len(reqs) > 0 {
+	if len(reqIDs) > 0 {
 		// infer next req ID from existing req IDs
-		for _, v := range reqs {
-			r, err2 := ParseReq(v)
-			reqID = r.ID
-			reqIdComps := strings.Split(r.ID, "-")
+		for _, reqID = range reqIDs {
+			reqIdComps := strings.Split(reqID, "-")
 			currentId, err2 := strconv.Atoi(reqIdComps[len(reqIdComps)-1])
 			if err2 != nil {
 				return "", fmt.Errorf("Requirements failed to parse: %s", reqID)
@@ -708,29 +1137,10 @@ func NextId(f string) (string, error) {
 	return nextReqID, nil
 }
 
-// ParseCertdoc parses raw requirements out of a certdoc.
-func ParseCertdoc(fileName string) ([]string, error) {
-	if err := IsValidDocName(fileName); err != nil {
-		return nil, err
-	}
-
-	ext := path.Ext(fileName)
-	switch strings.ToLower(ext) {
-	case ".lyx":
-		return ParseLyx(fileName, ioutil.Discard)
-	case ".md":
-		return ParseMarkdown(fileName)
-	}
-	return nil, fmt.Errorf("Unrecognized extension: %s", ext)
-}
-
 func IsValidDocName(f string) error {
 	ext := path.Ext(f)
-	switch strings.ToLower(ext) {
-	case ".lyx", ".md":
-		// All good.
-	default:
-		return fmt.Errorf("Invalid extension: '%s'. Only '.lyx' and '.md' are supported", strings.ToLower(ext))
+	if docParser(f) == nil {
+		return fmt.Errorf("Invalid extension: '%s'. Only '%s' are supported", strings.ToLower(ext), strings.Join(docExtensions(), "', '"))
 	}
 	filename := strings.TrimSuffix(path.Base(f), ext)
 	// check if the structure of the filename is correct
@@ -2,12 +2,19 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"strconv"
 	"testing"
 
 	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/daedaleanai/reqtraq/taskmgr"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -184,7 +191,11 @@ func TestReq_MatchesDiffs(t *testing.T) {
 // @tests @llr REQ-0-DDLN-SWL-015
 func TestParsing(t *testing.T) {
 	CheckParsing(t, "testdata/valid_system_requirement/123-TEST-100-ORD.lyx")
+	CheckParsing(t, "testdata/valid_system_requirement/123-TEST-100-ORD.html")
 	CheckParsing(t, "testdata/valid_system_requirement/123-TEST-100-ORD.md")
+	CheckParsing(t, "testdata/valid_system_requirement/123-TEST-100-ORD.odt")
+	CheckParsing(t, "testdata/valid_system_requirement/123-TEST-100-ORD.org")
+	CheckParsing(t, "testdata/valid_system_requirement/123-TEST-100-ORD.toml")
 }
 
 func CheckParsing(t *testing.T, f string) {
@@ -216,3 +227,103 @@ func TestReq_IsDeleted(t *testing.T) {
 	req := Req{ID: "REQ-123-TEST-SYS-002", Title: "DELETED Requirement", Body: "This is the body"}
 	assert.True(t, req.IsDeleted(), "Requirement with title %s should have status DELETED", req.Body)
 }
+
+func TestReq_Tasklists(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{
+		"1": {ID: "1", DisplayID: "1", Title: "REQ-123-TEST-SWL-001: Low", DependsOnTaskIDs: []string{"2"}},
+		"2": {ID: "2", DisplayID: "2", Title: "Subtask"},
+	}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	r := &Req{ID: "REQ-123-TEST-SWL-001", Title: "Low", Level: config.LOW}
+	tasks := r.Tasklists()
+	assert.Equal(t, "", tasks.Unavailable)
+	assert.Len(t, tasks.Tasks, 2)
+
+	// No task.
+	tasks = (&Req{ID: "REQ-123-TEST-SWL-002", Level: config.LOW}).Tasklists()
+	assert.Equal(t, Tasklist{Tasks: map[string]*taskmgr.Task{}}, tasks)
+
+	tm.err = errors.New("connection refused")
+	tasks = r.Tasklists()
+	assert.Equal(t, "connection refused", tasks.Unavailable)
+	var b bytes.Buffer
+	assert.NoError(t, reportTmpl.ExecuteTemplate(&b, "PROBLEMREPORTS", tasks))
+	assert.Contains(t, b.String(), "Unavailable, the task manager could not be queried")
+	assert.NotContains(t, b.String(), "No problem reports")
+}
+
+func TestReq_Changelists(t *testing.T) {
+	code := &Req{ID: "a.go", Path: "testdata/missing/a.go", Level: config.CODE}
+	r := &Req{ID: "REQ-123-TEST-SWL-001", Level: config.LOW, Children: []*Req{code}}
+	changes := r.Changelists()
+	assert.NotEqual(t, "", changes.Unavailable)
+	var b bytes.Buffer
+	assert.NoError(t, reportTmpl.ExecuteTemplate(&b, "CHANGELIST", changes))
+	assert.Contains(t, b.String(), "Unavailable, the git history could not be read")
+}
+
+func TestParseTrailerChangelists(t *testing.T) {
+	log := "\x1eabc1234\nRemove the legacy driver\n\nImplements-Req: REQ-0-TEST-SWL-001, REQ-0-TEST-SWH-002\n" +
+		"\x1edef5678\nBuild the firmware image\n\nDifferential Revision: https://p.example.com/D42\nImplements-Req: REQ-0-TEST-SWL-001\n" +
+		"\x1e0123abc\nMention REQ-0-TEST-SWL-001 in passing\n" +
+		"\x1e4567def\nImplements-Req: REQ-0-TEST-SWL-0011\n"
+	assert.Equal(t, map[string]string{"abc1234": "", "D42": "https://p.example.com/D42"},
+		parseTrailerChangelists("REQ-0-TEST-SWL-001", log))
+	assert.Equal(t, map[string]string{"abc1234": ""}, parseTrailerChangelists("REQ-0-TEST-SWH-002", log))
+	assert.Empty(t, parseTrailerChangelists("REQ-0-TEST-SYS-001", log))
+
+	var b bytes.Buffer
+	assert.NoError(t, reportTmpl.ExecuteTemplate(&b, "CHANGELIST", Changelist{URLs: map[string]string{"abc1234": ""}}))
+	assert.Contains(t, b.String(), `<span class="label label-primary">abc1234</span>`)
+	assert.NotContains(t, b.String(), "href")
+}
+
+func TestCreateReqGraph_TestEnv(t *testing.T) {
+	const dir = "/testdata/TestCreateReqGraphTestEnv"
+	rg, err := CreateReqGraph(dir, dir, dir+"/sim")
+	assert.NoError(t, err)
+
+	code := rg[git.RepoPath()+dir+"/code/a.go"]
+	sim := rg[git.RepoPath()+dir+"/sim/b.go"]
+	if !assert.NotNil(t, code) || !assert.NotNil(t, sim) {
+		return
+	}
+	assert.False(t, code.TestEnv)
+	assert.True(t, sim.TestEnv)
+
+	implemented, verified := rg["REQ-0-TEST-SWL-001"], rg["REQ-0-TEST-SWL-002"]
+	assert.Equal(t, []*Req{code}, implemented.CodeFiles())
+	assert.Equal(t, []*Req{sim}, implemented.TestEnvFiles())
+	assert.Equal(t, COMPLETED, implemented.Status)
+	assert.Empty(t, verified.CodeFiles())
+	assert.Equal(t, []*Req{sim}, verified.TestEnvFiles())
+	assert.Equal(t, NOT_STARTED, verified.Status)
+
+	// Without test environments, the files are all code files.
+	rg, err = CreateReqGraph(dir, dir)
+	assert.NoError(t, err)
+	assert.False(t, rg[git.RepoPath()+dir+"/sim/b.go"].TestEnv)
+	assert.Equal(t, COMPLETED, rg["REQ-0-TEST-SWL-002"].Status)
+}
+
+func TestReqGraph_checkReqReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "certdocs", "templates"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "certdocs", "0-TEST-211-SRD.md"), []byte("##### REQ-0-TEST-SWH-001 High\nSee REQ-0-TEST-SWH-009.\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "certdocs", "templates", "0-TEST-212-SDD.md"), []byte("##### REQ-0-TEST-SWL-001 Template\n- Parents: REQ-0-TEST-SWH-002\n"), 0644))
+	rg := reqGraph{"REQ-0-TEST-SWH-001": &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH}}
+
+	err = rg.checkReqReferences(dir, "certdocs")
+	assert.EqualError(t, err, "Invalid reference to inexistent requirement REQ-0-TEST-SWH-009 in "+filepath.Join(dir, "certdocs", "0-TEST-211-SRD.md")+":2\n"+
+		"Invalid reference to inexistent requirement REQ-0-TEST-SWL-001 in "+filepath.Join(dir, "certdocs", "templates", "0-TEST-212-SDD.md")+":1\n"+
+		"Invalid reference to inexistent requirement REQ-0-TEST-SWH-002 in "+filepath.Join(dir, "certdocs", "templates", "0-TEST-212-SDD.md")+":2\n")
+
+	// The documents ignored aren't checked.
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ignoreFileName), []byte("certdocs/templates/\n"), 0644))
+	err = rg.checkReqReferences(dir, "certdocs")
+	assert.EqualError(t, err, "Invalid reference to inexistent requirement REQ-0-TEST-SWH-009 in "+filepath.Join(dir, "certdocs", "0-TEST-211-SRD.md")+":2\n")
+}
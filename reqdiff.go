@@ -0,0 +1,196 @@
+// @llr REQ-0-DDLN-SWL-073
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// The kinds of the differences of the requirements.
+const (
+	reqAdded    = "Added"
+	reqDeleted  = "Deleted"
+	reqModified = "Modified"
+)
+
+// ReqDiff is the difference of a requirement between two graphs, e.g. at two git refs.
+type ReqDiff struct {
+	// Kind is added, deleted or modified. A requirement deleted is one removed or marked DELETED.
+	Kind  string
+	ID    string
+	Title string
+	// Fields are the fields modified.
+	Fields []FieldDiff
+}
+
+// FieldDiff is the difference of a field of a requirement: the title, the body, an attribute or
+// the parents.
+type FieldDiff struct {
+	Name     string
+	Old, New string
+	// Lines are the lines of the plain text of the bodies, prefixed by "-" if removed, "+" if added
+	// and " " if kept.
+	Lines []string
+}
+
+// DiffFrom returns the differences of the requirements of the graph from those of the graph old,
+// sorted by ID. The code files are left out.
+func (rg reqGraph) DiffFrom(old reqGraph) []ReqDiff {
+	ids := map[string]bool{}
+	for _, g := range []reqGraph{old, rg} {
+		for id, r := range g {
+			if r.Level != config.CODE {
+				ids[id] = true
+			}
+		}
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	var diffs []ReqDiff
+	for _, id := range sorted {
+		o, n := old[id], rg[id]
+		oldExists, newExists := o != nil && !o.IsDeleted(), n != nil && !n.IsDeleted()
+		switch {
+		case !oldExists && newExists:
+			diffs = append(diffs, ReqDiff{Kind: reqAdded, ID: id, Title: n.Title})
+		case oldExists && !newExists:
+			diffs = append(diffs, ReqDiff{Kind: reqDeleted, ID: id, Title: o.Title})
+		case oldExists && newExists:
+			if fields := diffFields(o, n); len(fields) > 0 {
+				diffs = append(diffs, ReqDiff{Kind: reqModified, ID: id, Title: n.Title, Fields: fields})
+			}
+		}
+	}
+	return diffs
+}
+
+// diffFields returns the differences of the title, the body, the attributes, by name, and the
+// parents of the requirement.
+func diffFields(o, n *Req) []FieldDiff {
+	var fields []FieldDiff
+	if o.Title != n.Title {
+		fields = append(fields, FieldDiff{Name: "title", Old: o.Title, New: n.Title})
+	}
+	oldBody, newBody := plainText(string(o.LoadBody())), plainText(string(n.LoadBody()))
+	if oldBody != newBody {
+		fields = append(fields, FieldDiff{Name: "body", Old: oldBody, New: newBody, Lines: diffLines(bodyLines(oldBody), bodyLines(newBody))})
+	}
+	names := map[string]bool{}
+	for name := range o.Attributes {
+		names[name] = true
+	}
+	for name := range n.Attributes {
+		names[name] = true
+	}
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+	for _, name := range sortedNames {
+		// The parents are compared below.
+		if name == "PARENTS" {
+			continue
+		}
+		if ov, nv := o.Attributes[name], n.Attributes[name]; ov != nv {
+			fields = append(fields, FieldDiff{Name: "attribute " + name, Old: ov, New: nv})
+		}
+	}
+	oldParents, newParents := sortedParentIds(o), sortedParentIds(n)
+	if oldParents != newParents {
+		fields = append(fields, FieldDiff{Name: "parents", Old: oldParents, New: newParents})
+	}
+	return fields
+}
+
+// sortedParentIds returns the IDs of the parents of the requirement, sorted and separated by commas.
+func sortedParentIds(r *Req) string {
+	ids := append([]string{}, r.ParentIds...)
+	sort.Strings(ids)
+	return strings.Join(ids, ", ")
+}
+
+// bodyLines returns the non-empty lines of the plain text of a body, trimmed.
+func bodyLines(text string) []string {
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// diffLines returns the lines of a and b, those only in a prefixed with "-", those only in b with
+// "+" and the others with " ", along their longest common subsequence.
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, "+"+b[j])
+	}
+	return lines
+}
+
+// WriteDiff writes the differences of the requirements between the refs, a requirement per
+// paragraph with its fields modified, then the counts.
+func WriteDiff(w io.Writer, from, to string, diffs []ReqDiff) {
+	fmt.Fprintf(w, "Requirements changed from %s to %s\n", from, to)
+	counts := map[string]int{}
+	for _, d := range diffs {
+		counts[d.Kind]++
+		fmt.Fprintf(w, "\n%s %s %s\n", d.Kind, d.ID, d.Title)
+		for _, f := range d.Fields {
+			if f.Lines != nil {
+				fmt.Fprintf(w, "\t%s:\n", f.Name)
+				for _, l := range f.Lines {
+					fmt.Fprintf(w, "\t\t%s\n", l)
+				}
+				continue
+			}
+			fmt.Fprintf(w, "\t%s: %q -> %q\n", f.Name, f.Old, f.New)
+		}
+	}
+	fmt.Fprintf(w, "\n%d added, %d deleted, %d modified\n", counts[reqAdded], counts[reqDeleted], counts[reqModified])
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_DiffFrom(t *testing.T) {
+	// The code files changed are left out.
+	graph := func(hash string, reqs ...*Req) reqGraph {
+		rg := reqGraph{}
+		for _, r := range reqs {
+			rg[r.ID] = r
+		}
+		rg["/repo/a.go"] = &Req{ID: "a.go", Level: config.CODE, FileHash: hash}
+		return rg
+	}
+	old := graph("sha1:1",
+		&Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "Steering"},
+		&Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "Braking"},
+		&Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Filtering", ParentIds: []string{"REQ-0-TEST-SWH-001"},
+			Body: "<p>The software shall filter</p>\n<p>by title.</p>", Attributes: map[string]string{"VERIFICATION": "Test", "SAFETY IMPACT": "None"}},
+		&Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "Unchanged", ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		&Req{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Title: "Removed", ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+	)
+	rg := graph("sha1:2",
+		&Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "Steering"},
+		&Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "DELETED Braking"},
+		&Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Filtering", ParentIds: []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWH-003"},
+			Body: "<p>The software shall filter</p>\n<p>by title and body.</p>", Attributes: map[string]string{"VERIFICATION": "Unit test"}},
+		&Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "Unchanged", ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		&Req{ID: "REQ-0-TEST-SWH-003", Level: config.HIGH, Title: "Filtering"},
+	)
+
+	diffs := rg.DiffFrom(old)
+	assert.Equal(t, []ReqDiff{
+		{Kind: reqDeleted, ID: "REQ-0-TEST-SWH-002", Title: "Braking"},
+		{Kind: reqAdded, ID: "REQ-0-TEST-SWH-003", Title: "Filtering"},
+		{Kind: reqModified, ID: "REQ-0-TEST-SWL-001", Title: "Filtering", Fields: []FieldDiff{
+			{Name: "body", Old: "The software shall filter\nby title.", New: "The software shall filter\nby title and body.",
+				Lines: []string{" The software shall filter", "-by title.", "+by title and body."}},
+			{Name: "attribute SAFETY IMPACT", Old: "None"},
+			{Name: "attribute VERIFICATION", Old: "Test", New: "Unit test"},
+			{Name: "parents", Old: "REQ-0-TEST-SWH-001", New: "REQ-0-TEST-SWH-001, REQ-0-TEST-SWH-003"},
+		}},
+		{Kind: reqDeleted, ID: "REQ-0-TEST-SWL-003", Title: "Removed"},
+	}, diffs)
+
+	var b bytes.Buffer
+	WriteDiff(&b, "v1", "v2", diffs)
+	assert.Equal(t, `Requirements changed from v1 to v2
+
+Deleted REQ-0-TEST-SWH-002 Braking
+
+Added REQ-0-TEST-SWH-003 Filtering
+
+Modified REQ-0-TEST-SWL-001 Filtering
+	body:
+		 The software shall filter
+		-by title.
+		+by title and body.
+	attribute SAFETY IMPACT: "None" -> ""
+	attribute VERIFICATION: "Test" -> "Unit test"
+	parents: "REQ-0-TEST-SWH-001" -> "REQ-0-TEST-SWH-001, REQ-0-TEST-SWH-003"
+
+Deleted REQ-0-TEST-SWL-003 Removed
+
+1 added, 2 deleted, 1 modified
+`, b.String())
+
+	// A requirement re-parented is reported once.
+	old = graph("sha1:1", &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Filtering", ParentIds: []string{"REQ-0-TEST-SWH-001"},
+		Attributes: map[string]string{"PARENTS": "REQ-0-TEST-SWH-001", "VERIFICATION": "Test"}})
+	rg = graph("sha1:1", &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Filtering", ParentIds: []string{"REQ-0-TEST-SWH-002"},
+		Attributes: map[string]string{"PARENTS": "REQ-0-TEST-SWH-002", "VERIFICATION": "Test"}})
+	assert.Equal(t, []ReqDiff{
+		{Kind: reqModified, ID: "REQ-0-TEST-SWL-001", Title: "Filtering", Fields: []FieldDiff{
+			{Name: "parents", Old: "REQ-0-TEST-SWH-001", New: "REQ-0-TEST-SWH-002"},
+		}},
+	}, rg.DiffFrom(old))
+}
+
+func TestDiffLines(t *testing.T) {
+	assert.Equal(t, []string{"-a", " b", "+c", " d", "+e"}, diffLines([]string{"a", "b", "d"}, []string{"b", "c", "d", "e"}))
+	assert.Nil(t, diffLines(nil, nil))
+}
@@ -0,0 +1,273 @@
+// @llr REQ-0-DDLN-SWL-020
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// RiskValueConf maps attribute values matching a regular expression to a score between 0 and 1.
+type RiskValueConf struct {
+	Value string  `json:"value"`
+	Score float64 `json:"score"`
+}
+
+// RiskAttributeConf defines a risk factor derived from the value of a requirement attribute.
+// The first matching value determines the score; Default is used when the attribute is missing
+// or no value matches.
+type RiskAttributeConf struct {
+	Name    string          `json:"name"`
+	Weight  float64         `json:"weight"`
+	Values  []RiskValueConf `json:"values"`
+	Default float64         `json:"default"`
+}
+
+// RiskConf is the configuration of the risk scoring, the "risk" entry in attributes.json.
+type RiskConf struct {
+	Attributes []RiskAttributeConf `json:"attributes"`
+	// Weight of the number of commits touching the code implementing the requirement.
+	ChurnWeight float64 `json:"churn_weight"`
+	// Weight of the number of decision points in the code implementing the requirement.
+	ComplexityWeight float64 `json:"complexity_weight"`
+}
+
+// defaultRiskConf is used when attributes.json doesn't configure the risk scoring.
+var defaultRiskConf = RiskConf{
+	Attributes: []RiskAttributeConf{
+		{Name: "Safety Impact", Weight: 3, Values: []RiskValueConf{{Value: `(?i)^\s*none`, Score: 0}}, Default: 1},
+		{Name: "Verification", Weight: 1, Values: []RiskValueConf{
+			{Value: `(?i)test`, Score: 0.25},
+			{Value: `(?i)analysis`, Score: 0.5},
+			{Value: `(?i)demonstration|inspection|review`, Score: 0.75},
+		}, Default: 1},
+	},
+	ChurnWeight:      1,
+	ComplexityWeight: 1,
+}
+
+const (
+	churnFactor      = "Churn"
+	complexityFactor = "Complexity"
+)
+
+// RiskScore is the risk score of a requirement, between 0 and 100, together with the
+// contribution of each factor, normalized between 0 and 1.
+type RiskScore struct {
+	Req     *Req
+	Score   float64
+	Factors map[string]float64
+}
+
+var reDecisionPoint = regexp.MustCompile(`\b(if|for|while|case|catch|except|elif|when)\b|&&|\|\|`)
+
+// codeComplexity returns a rough measure of the complexity of a code file: the number of
+// decision points plus one.
+func codeComplexity(fileName string) (int, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	return len(reDecisionPoint.FindAllIndex(b, -1)) + 1, nil
+}
+
+// codeDescendants returns the code files implementing r, directly or through its children.
+func (r *Req) codeDescendants() []*Req {
+	seen := map[*Req]bool{}
+	var code []*Req
+	var visit func(*Req)
+	visit = func(r *Req) {
+		for _, c := range r.Children {
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			if c.TestEnv {
+				continue
+			}
+			if c.Level == config.CODE {
+				code = append(code, c)
+			} else {
+				visit(c)
+			}
+		}
+	}
+	visit(r)
+	return code
+}
+
+// RiskScores computes the risk score of all the requirements which are not deleted, and
+// returns them by decreasing score.
+func (rg reqGraph) RiskScores(conf RiskConf) ([]*RiskScore, error) {
+	type valueExpr struct {
+		expr  *regexp.Regexp
+		score float64
+	}
+	exprs := make([][]valueExpr, len(conf.Attributes))
+	for i, a := range conf.Attributes {
+		for _, v := range a.Values {
+			expr, err := regexp.Compile(v.Value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid risk value for attribute '%s': %v", a.Name, err)
+			}
+			exprs[i] = append(exprs[i], valueExpr{expr, v.Score})
+		}
+	}
+
+	// Churn and complexity are computed once per code file.
+	churn := map[string]int{}
+	complexity := map[string]int{}
+	for _, r := range rg {
+		if r.Level != config.CODE || r.TestEnv {
+			continue
+		}
+		if conf.ChurnWeight != 0 {
+			commits, err := git.FileCommits(r.Path)
+			if err != nil {
+				return nil, &historyUnavailableError{r.Path, err}
+			}
+			churn[r.Path] = len(commits)
+		}
+		if conf.ComplexityWeight != 0 {
+			c, err := codeComplexity(r.Path)
+			if err != nil {
+				return nil, err
+			}
+			complexity[r.Path] = c
+		}
+	}
+
+	var scores []*RiskScore
+	rawChurn := map[*RiskScore]int{}
+	rawComplexity := map[*RiskScore]int{}
+	maxChurn, maxComplexity := 0, 0
+	for _, r := range rg {
+		if r.Level == config.CODE || r.IsDeleted() {
+			continue
+		}
+		s := &RiskScore{Req: r, Factors: map[string]float64{}}
+		for i, a := range conf.Attributes {
+			score := a.Default
+			if v, ok := r.Attributes[strings.ToUpper(a.Name)]; ok {
+				for _, e := range exprs[i] {
+					if e.expr.MatchString(v) {
+						score = e.score
+						break
+					}
+				}
+			}
+			s.Factors[a.Name] = score
+		}
+		for _, c := range r.codeDescendants() {
+			rawChurn[s] += churn[c.Path]
+			rawComplexity[s] += complexity[c.Path]
+		}
+		if rawChurn[s] > maxChurn {
+			maxChurn = rawChurn[s]
+		}
+		if rawComplexity[s] > maxComplexity {
+			maxComplexity = rawComplexity[s]
+		}
+		scores = append(scores, s)
+	}
+
+	totalWeight := conf.ChurnWeight + conf.ComplexityWeight
+	for _, a := range conf.Attributes {
+		totalWeight += a.Weight
+	}
+	for _, s := range scores {
+		if conf.ChurnWeight != 0 {
+			s.Factors[churnFactor] = normalize(rawChurn[s], maxChurn)
+		}
+		if conf.ComplexityWeight != 0 {
+			s.Factors[complexityFactor] = normalize(rawComplexity[s], maxComplexity)
+		}
+		if totalWeight == 0 {
+			continue
+		}
+		sum := conf.ChurnWeight*s.Factors[churnFactor] + conf.ComplexityWeight*s.Factors[complexityFactor]
+		for _, a := range conf.Attributes {
+			sum += a.Weight * s.Factors[a.Name]
+		}
+		s.Score = 100 * sum / totalWeight
+	}
+
+	sort.Sort(byRisk(scores))
+	return scores, nil
+}
+
+func normalize(v, max int) float64 {
+	if max == 0 {
+		return 0
+	}
+	return float64(v) / float64(max)
+}
+
+// byRisk sorts by decreasing score, then by level and position.
+type byRisk []*RiskScore
+
+func (a byRisk) Len() int      { return len(a) }
+func (a byRisk) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byRisk) Less(i, j int) bool {
+	if a[i].Score != a[j].Score {
+		return a[i].Score > a[j].Score
+	}
+	if a[i].Req.Level != a[j].Req.Level {
+		return a[i].Req.Level < a[j].Req.Level
+	}
+	return a[i].Req.Position < a[j].Req.Position
+}
+
+// historyUnavailableError is returned when the git history needed for the churn can't be read.
+type historyUnavailableError struct {
+	path string
+	err  error
+}
+
+func (e *historyUnavailableError) Error() string {
+	return fmt.Sprintf("Could not read the git history of %s: %v", e.path, e.err)
+}
+
+type riskReportData struct {
+	Scores  []*RiskScore
+	Factors []string
+	Filter  ReqFilter
+	Diffs   map[string][]string
+	// Unavailable is why the churn is not part of the scores, if so.
+	Unavailable string
+}
+
+// ReportRisk writes an HTML report ranking the requirements matching the filter and the diffs
+// by decreasing risk. If the git history is not available, the scores are computed without the
+// churn, which is marked as unavailable.
+func (rg reqGraph) ReportRisk(w io.Writer, conf RiskConf, f ReqFilter, diffs map[string][]string) error {
+	scores, err := rg.RiskScores(conf)
+	unavailable := ""
+	if e, ok := err.(*historyUnavailableError); ok {
+		log.Println(e)
+		unavailable = e.Error()
+		conf.ChurnWeight = 0
+		scores, err = rg.RiskScores(conf)
+	}
+	if err != nil {
+		return err
+	}
+	var factors []string
+	for _, a := range conf.Attributes {
+		factors = append(factors, a.Name)
+	}
+	if conf.ChurnWeight != 0 {
+		factors = append(factors, churnFactor)
+	}
+	if conf.ComplexityWeight != 0 {
+		factors = append(factors, complexityFactor)
+	}
+	return reportTmpl.ExecuteTemplate(w, "RISK", riskReportData{scores, factors, f, diffs, unavailable})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_RiskScores(t *testing.T) {
+	simple, err := createTempFile("int f() { return 1; }\n", "risk")
+	if simple != nil {
+		defer os.Remove(simple.Name())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	complex, err := createTempFile("int g(int a) { if (a && a > 1) { return 1; } for (;;) {} }\n", "risk")
+	if complex != nil {
+		defer os.Remove(complex.Name())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rg := reqGraph{}
+	rg.AddReq(&Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Position: 1, ParentIds: []string{"REQ-0-TEST-SWH-001"},
+		Attributes: map[string]string{"SAFETY IMPACT": "None", "VERIFICATION": "Unit test"}}, "0-TEST-212-SDD.md")
+	rg.AddReq(&Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Position: 2, ParentIds: []string{"REQ-0-TEST-SWH-001"},
+		Attributes: map[string]string{"SAFETY IMPACT": "Catastrophic", "VERIFICATION": "Unit test"}}, "0-TEST-212-SDD.md")
+	rg.AddReq(&Req{ID: "REQ-0-TEST-SWL-003", Title: "DELETED", Level: config.LOW, Position: 3, ParentIds: []string{"REQ-0-TEST-SWH-001"}}, "0-TEST-212-SDD.md")
+	rg.AddReq(&Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, ParentIds: []string{"REQ-0-TEST-SYS-001"},
+		Attributes: map[string]string{"SAFETY IMPACT": "None", "VERIFICATION": "Demonstration"}}, "0-TEST-211-SRD.md")
+	rg.AddReq(&Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM,
+		Attributes: map[string]string{"SAFETY IMPACT": "None", "VERIFICATION": "Test"}}, "0-TEST-100-ORD.md")
+	rg.AddCodeRefs("simple.cc", simple.Name(), "", []string{"REQ-0-TEST-SWL-001"})
+	rg.AddCodeRefs("complex.cc", complex.Name(), "", []string{"REQ-0-TEST-SWL-002"})
+	if err := rg.Resolve(); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := defaultRiskConf
+	conf.ChurnWeight = 0
+	scores, err := rg.RiskScores(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, s := range scores {
+		ids = append(ids, s.Req.ID)
+	}
+	// The deleted requirement is not scored, the parent accumulates the complexity of its children.
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-002", "REQ-0-TEST-SWH-001", "REQ-0-TEST-SYS-001", "REQ-0-TEST-SWL-001"}, ids)
+
+	assert.Equal(t, 1.0, scores[0].Factors["Safety Impact"])
+	assert.Equal(t, 0.25, scores[0].Factors["Verification"])
+	assert.Equal(t, 0.8, scores[0].Factors[complexityFactor])
+	assert.Equal(t, 1.0, scores[1].Factors[complexityFactor])
+	assert.Equal(t, 100*(3*1+0.25+0.8)/5, scores[0].Score)
+	_, ok := scores[0].Factors[churnFactor]
+	assert.False(t, ok, "churn should not be computed when its weight is 0")
+}
@@ -0,0 +1,161 @@
+// @llr REQ-0-DDLN-SWL-065
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// RuleConf is a conditional rule of the attributes of the requirements, from the "rules" entry of
+// attributes.json, e.g. the requirements of high safety impact must be verified by test and signed
+// off by two reviewers:
+//
+//	{"name": "high-safety-impact", "if": {"Safety Impact": "High"},
+//	 "then": [{"attribute": "Verification", "value": "Test"}, {"attribute": "Reviewers", "min": 2}]}
+type RuleConf struct {
+	// Name identifies the rule in the findings.
+	Name string `json:"name"`
+	// If are the regular expressions the values of the attributes must match entirely, by name,
+	// for the rule to apply. It applies to the requirements matching all of them.
+	If   map[string]string `json:"if"`
+	Then []RuleCondition   `json:"then"`
+}
+
+// RuleCondition is a condition on an attribute of the requirements a rule applies to. If set, the
+// value must match the regular expression Value entirely, and list at least Min distinct values
+// separated by commas, ignoring the case, e.g. the independent reviewers who signed off.
+type RuleCondition struct {
+	Attribute string `json:"attribute"`
+	Value     string `json:"value"`
+	Min       int    `json:"min"`
+}
+
+// compiledRule is a rule with its regular expressions compiled, by upper case attribute name for
+// those of If and by condition for those of Then, nil if the condition has no Value.
+type compiledRule struct {
+	RuleConf
+	ifs    map[string]*regexp.Regexp
+	values []*regexp.Regexp
+}
+
+// compileRules compiles the regular expressions of the rules, matching the values entirely.
+func compileRules(rules []RuleConf) ([]compiledRule, error) {
+	compile := func(rule, expr string) (*regexp.Regexp, error) {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression %q in rule '%s': %v", expr, rule, err)
+		}
+		return re, nil
+	}
+	var compiled []compiledRule
+	for _, r := range rules {
+		c := compiledRule{RuleConf: r, ifs: map[string]*regexp.Regexp{}}
+		for name, expr := range r.If {
+			re, err := compile(r.Name, expr)
+			if err != nil {
+				return nil, err
+			}
+			c.ifs[strings.ToUpper(name)] = re
+		}
+		for _, cond := range r.Then {
+			var re *regexp.Regexp
+			if cond.Value != "" {
+				var err error
+				if re, err = compile(r.Name, cond.Value); err != nil {
+					return nil, err
+				}
+			}
+			c.values = append(c.values, re)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// CheckRules returns the findings of the requirements breaking the rules, citing the rule, sorted
+// by requirement. The code files and the deleted requirements have no attributes to check.
+func (rg reqGraph) CheckRules(rules []RuleConf) ([]error, error) {
+	compiled, err := compileRules(rules)
+	if err != nil || len(compiled) == 0 {
+		return nil, err
+	}
+	var reqs []*Req
+	for _, r := range rg {
+		if r.Level != config.CODE && !r.IsDeleted() {
+			reqs = append(reqs, r)
+		}
+	}
+	sort.Sort(byIDOrPath(reqs))
+	var errs []error
+	for _, r := range reqs {
+		for _, rule := range compiled {
+			errs = append(errs, rule.check(r)...)
+		}
+	}
+	return errs, nil
+}
+
+// check returns the findings of the requirement breaking the rule, one per condition, none if the
+// rule doesn't apply to it.
+func (c compiledRule) check(r *Req) []error {
+	for name, re := range c.ifs {
+		if !re.MatchString(r.Attributes[name]) {
+			return nil
+		}
+	}
+	rule := "'" + c.Name + "'"
+	if cond := c.condition(); cond != "" {
+		rule += " (" + cond + ")"
+	}
+	var errs []error
+	for i, cond := range c.Then {
+		name := strings.ToUpper(cond.Attribute)
+		value, ok := r.Attributes[name]
+		broken := ""
+		switch {
+		case !ok:
+			broken = fmt.Sprintf("attribute '%s' is missing", cond.Attribute)
+		case c.values[i] != nil && !c.values[i].MatchString(value):
+			broken = fmt.Sprintf("attribute '%s' has value '%s', expected %s", cond.Attribute, value, cond.Value)
+		case cond.Min > 0 && len(distinctValues(value)) < cond.Min:
+			broken = fmt.Sprintf("attribute '%s' lists %d distinct values, at least %d expected", cond.Attribute, len(distinctValues(value)), cond.Min)
+		}
+		if broken != "" {
+			errs = append(errs, fmt.Errorf("Requirement '%s' breaks rule %s: %s.\n", r.ID, rule, broken))
+		}
+	}
+	return errs
+}
+
+// condition returns the condition of the rule as written in the findings, e.g. "if Safety Impact
+// is High", empty if it applies to all the requirements.
+func (c compiledRule) condition() string {
+	names := make([]string, 0, len(c.If))
+	for name := range c.If {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var conds []string
+	for _, name := range names {
+		conds = append(conds, name+" is "+c.If[name])
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return "if " + strings.Join(conds, " and ")
+}
+
+// distinctValues returns the distinct values of a list separated by commas, ignoring the case.
+func distinctValues(list string) map[string]bool {
+	values := map[string]bool{}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values[v] = true
+		}
+	}
+	return values
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRules(t *testing.T) {
+	var conf JsonConf
+	assert.NoError(t, json.Unmarshal([]byte(`{"rules": [{
+		"name": "high-safety-impact",
+		"if": {"Safety Impact": "High"},
+		"then": [{"attribute": "Verification", "value": "Test"}, {"attribute": "Reviewers", "min": 2}]
+	}]}`), &conf))
+
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Attributes: map[string]string{
+			"SAFETY IMPACT": "High", "VERIFICATION": "Test", "REVIEWERS": "alice, bob"}},
+		// Only the whole value matches.
+		{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Attributes: map[string]string{
+			"SAFETY IMPACT": "Highest", "VERIFICATION": "Unit test"}},
+		{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Attributes: map[string]string{
+			"SAFETY IMPACT": "High", "VERIFICATION": "Unit test", "REVIEWERS": "alice, Alice"}},
+		{ID: "REQ-0-TEST-SWL-004", Level: config.LOW, Attributes: map[string]string{
+			"SAFETY IMPACT": "High", "VERIFICATION": "Test"}},
+		{ID: "REQ-0-TEST-SWL-005", Level: config.LOW, Title: "DELETED", Attributes: map[string]string{
+			"SAFETY IMPACT": "High"}},
+	} {
+		rg[r.ID] = r
+	}
+	errs, err := rg.CheckRules(conf.Rules)
+	assert.NoError(t, err)
+	var messages []string
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	assert.Equal(t, []string{
+		"Requirement 'REQ-0-TEST-SWL-003' breaks rule 'high-safety-impact' (if Safety Impact is High): attribute 'Verification' has value 'Unit test', expected Test.\n",
+		"Requirement 'REQ-0-TEST-SWL-003' breaks rule 'high-safety-impact' (if Safety Impact is High): attribute 'Reviewers' lists 1 distinct values, at least 2 expected.\n",
+		"Requirement 'REQ-0-TEST-SWL-004' breaks rule 'high-safety-impact' (if Safety Impact is High): attribute 'Reviewers' is missing.\n",
+	}, messages)
+
+	_, err = rg.CheckRules([]RuleConf{{Name: "broken", If: map[string]string{"Safety Impact": "("}}})
+	assert.EqualError(t, err, "Invalid regular expression \"(\" in rule 'broken': error parsing regexp: missing closing ): `^(?:()$`")
+
+	// Without a condition, a rule applies to all the requirements.
+	errs, err = reqGraph{"REQ-0-TEST-SWL-001": rg["REQ-0-TEST-SWL-001"]}.CheckRules([]RuleConf{{Name: "reviewed",
+		Then: []RuleCondition{{Attribute: "Reviewers", Min: 3}}}})
+	assert.NoError(t, err)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "Requirement 'REQ-0-TEST-SWL-001' breaks rule 'reviewed': attribute 'Reviewers' lists 2 distinct values, at least 3 expected.\n", errs[0].Error())
+	}
+}
@@ -0,0 +1,110 @@
+// @llr REQ-0-DDLN-SWL-077
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// Scope restricts the reports and the exports to the requirements of the certification document
+// Document, e.g. 0-DDLN-212-SDD, and to the requirement Under and its descendants, as set with
+// --doc and --under. Empty fields don't restrict them.
+type Scope struct {
+	Document, Under string
+}
+
+// IsZero returns whether the scope restricts nothing.
+func (s Scope) IsZero() bool {
+	return s.Document == "" && s.Under == ""
+}
+
+// scopeDocument returns the name of the certification document of the requirement, without the
+// extension, e.g. 0-DDLN-212-SDD.
+func scopeDocument(r *Req) string {
+	return strings.TrimSuffix(filepath.Base(r.Path), filepath.Ext(r.Path))
+}
+
+// ScopeIDs returns the IDs of the requirements of the graph in the scope, the code files left out.
+// The deleted requirements are kept for the reports listing them. The scope must select at least
+// one requirement.
+func (rg reqGraph) ScopeIDs(s Scope) (map[string]bool, error) {
+	ids := map[string]bool{}
+	if s.Under != "" {
+		root := rg[s.Under]
+		if root == nil || root.Level == config.CODE || root.IsDeleted() {
+			return nil, fmt.Errorf("Unknown requirement %s to scope the reports under", s.Under)
+		}
+		var walk func(r *Req)
+		walk = func(r *Req) {
+			if ids[r.ID] {
+				return
+			}
+			ids[r.ID] = true
+			for _, c := range r.Children {
+				if c.Level != config.CODE {
+					walk(c)
+				}
+			}
+		}
+		walk(root)
+	} else {
+		for id, r := range rg {
+			if r.Level != config.CODE {
+				ids[id] = true
+			}
+		}
+	}
+	document := strings.TrimSuffix(s.Document, filepath.Ext(s.Document))
+	for id := range ids {
+		r := rg[id]
+		if document != "" && scopeDocument(r) != document {
+			delete(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("No requirements in the scope of --doc=%q --under=%q", s.Document, s.Under)
+	}
+	return ids, nil
+}
+
+// scopeDiffs returns the diffs of the requirements in the scope, or all the requirements in the
+// scope if there are no diffs, so the filtered reports only show these.
+func scopeDiffs(diffs map[string][]string, ids map[string]bool) map[string][]string {
+	scoped := map[string][]string{}
+	for id := range ids {
+		if diffs == nil {
+			scoped[id] = nil
+		} else if d, ok := diffs[id]; ok {
+			scoped[id] = d
+		}
+	}
+	return scoped
+}
+
+// Scoped returns the graph of the requirements whose ID is given, and of the code files referencing
+// them, to be exported. The links to the requirements left out are kept.
+func (rg reqGraph) Scoped(ids map[string]bool) reqGraph {
+	scoped := reqGraph{}
+	for k, r := range rg {
+		if r.Level != config.CODE {
+			if ids[r.ID] {
+				scoped[k] = r
+			}
+			continue
+		}
+		refs := append(append([]string{}, r.ParentIds...), r.VerifiesIds...)
+		for _, a := range r.Annotations {
+			refs = append(refs, a.ID)
+		}
+		for _, id := range refs {
+			if ids[id] {
+				scoped[k] = r
+				break
+			}
+		}
+	}
+	return scoped
+}
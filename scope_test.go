@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_Scope(t *testing.T) {
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SYS-001", Path: "/certdocs/0-TEST-100-ORD.md", Level: config.SYSTEM},
+		{ID: "REQ-0-TEST-SWH-001", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, ParentIds: []string{"REQ-0-TEST-SYS-001"}},
+		{ID: "REQ-0-TEST-SWH-002", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, ParentIds: []string{"REQ-0-TEST-SYS-001"}},
+		{ID: "REQ-0-TEST-SWL-001", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		{ID: "REQ-0-TEST-SWL-002", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, ParentIds: []string{"REQ-0-TEST-SWH-002"}},
+	} {
+		rg[r.ID] = r
+	}
+	rg["/repo/a.go"] = &Req{ID: "a.go", Path: "a.go", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001"}}
+	rg["/repo/b_test.go"] = &Req{ID: "b_test.go", Path: "b_test.go", Level: config.CODE, VerifiesIds: []string{"REQ-0-TEST-SWL-002"}}
+	assert.NoError(t, rg.Resolve())
+
+	ids, err := rg.ScopeIDs(Scope{Under: "REQ-0-TEST-SWH-001"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SWH-001": true, "REQ-0-TEST-SWL-001": true}, ids)
+
+	ids, err = rg.ScopeIDs(Scope{Document: "0-TEST-212-SDD.md"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SWL-001": true, "REQ-0-TEST-SWL-002": true}, ids)
+	scoped := rg.Scoped(ids)
+	assert.Len(t, scoped, 4)
+	assert.NotNil(t, scoped["/repo/b_test.go"])
+
+	ids, err = rg.ScopeIDs(Scope{Document: "0-TEST-212-SDD", Under: "REQ-0-TEST-SWH-002"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SWL-002": true}, ids)
+	assert.Equal(t, reqGraph{"REQ-0-TEST-SWL-002": rg["REQ-0-TEST-SWL-002"], "/repo/b_test.go": rg["/repo/b_test.go"]}, rg.Scoped(ids))
+
+	// The diffs are restricted to the scope, or all of it is selected.
+	assert.Equal(t, map[string][]string{"REQ-0-TEST-SWL-002": nil}, scopeDiffs(nil, ids))
+	assert.Equal(t, map[string][]string{}, scopeDiffs(map[string][]string{"REQ-0-TEST-SWL-001": {"title"}}, ids))
+	assert.True(t, rg["REQ-0-TEST-SWL-002"].Matches(nil, scopeDiffs(nil, ids)))
+	assert.False(t, rg["REQ-0-TEST-SWL-001"].Matches(nil, scopeDiffs(nil, ids)))
+
+	_, err = rg.ScopeIDs(Scope{Under: "REQ-0-TEST-SWH-003"})
+	assert.Error(t, err)
+	_, err = rg.ScopeIDs(Scope{Document: "0-TEST-100-ORD", Under: "REQ-0-TEST-SWH-001"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,48 @@
+// @llr REQ-0-DDLN-SWL-035
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// reHeading matches the h1-h6 headings of the HTML body of a requirement, capturing their level
+// and their text.
+var reHeading = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+
+// reTag matches the HTML tags, e.g. of the links in the heading text.
+var reTag = regexp.MustCompile(`<[^>]*>`)
+
+// bodySections are the recognized sections of the bodies, by their lower-case heading, with the
+// field of the requirement holding them.
+var bodySections = map[string]func(r *Req) *template.HTML{
+	"rationale":           func(r *Req) *template.HTML { return &r.Rationale },
+	"acceptance criteria": func(r *Req) *template.HTML { return &r.AcceptanceCriteria },
+	"notes":               func(r *Req) *template.HTML { return &r.Notes },
+	"note":                func(r *Req) *template.HTML { return &r.Notes },
+}
+
+// parseSections sets the section fields of r from the sections of its body, each starting at a
+// heading named after it, e.g. "#### Acceptance Criteria" in markdown, and ending at the next
+// heading of the same or a higher level. The body is left as is.
+func (r *Req) parseSections() {
+	r.Rationale, r.AcceptanceCriteria, r.Notes = "", "", ""
+	body := string(r.Body)
+	headings := reHeading.FindAllStringSubmatchIndex(body, -1)
+	for i, h := range headings {
+		name := strings.ToLower(strings.TrimSpace(reTag.ReplaceAllString(body[h[4]:h[5]], "")))
+		field, ok := bodySections[strings.TrimSpace(strings.TrimSuffix(name, ":"))]
+		if !ok {
+			continue
+		}
+		end := len(body)
+		for _, next := range headings[i+1:] {
+			if body[next[2]:next[3]] <= body[h[2]:h[3]] {
+				end = next[0]
+				break
+			}
+		}
+		*field(r) = template.HTML(strings.TrimSpace(body[h[1]:end]))
+	}
+}
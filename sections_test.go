@@ -0,0 +1,36 @@
+package main
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReq_parseSections(t *testing.T) {
+	r := &Req{ID: "REQ-0-TEST-SWH-001", Body: template.HTML(`<p>The RMT SHALL check the links.</p>
+<h4 id="rationale">Rationale:</h4>
+<p>Dead links hide evidence.</p>
+<h4 id="acceptance-criteria"><em>Acceptance</em> Criteria</h4>
+<p>A link answering 404 is reported.</p>
+<h5 id="example">Example</h5>
+<p>https://example.com/missing</p>
+<h4 id="history">History</h4>
+<p>Added in 2020.</p>
+<h3 id="notes">NOTES</h3>
+<p>None.</p>`)}
+	body := r.Body
+	r.parseSections()
+	assert.Equal(t, template.HTML("<p>Dead links hide evidence.</p>"), r.Rationale)
+	assert.Equal(t, template.HTML(`<p>A link answering 404 is reported.</p>
+<h5 id="example">Example</h5>
+<p>https://example.com/missing</p>`), r.AcceptanceCriteria)
+	assert.Equal(t, template.HTML("<p>None.</p>"), r.Notes)
+	assert.Equal(t, body, r.Body)
+
+	r.Body = "<p>No sections.</p>"
+	r.parseSections()
+	assert.Empty(t, r.Rationale)
+	assert.Empty(t, r.AcceptanceCriteria)
+	assert.Empty(t, r.Notes)
+}
@@ -0,0 +1,178 @@
+// @llr REQ-0-DDLN-SWL-080
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// splitIndex is the name of the index page of a split report.
+const splitIndex = "index.html"
+
+// splitPage is a page of a split report: part of the requirements of a certification document, or
+// of the code files.
+type splitPage struct {
+	// Name is the file name of the page, e.g. 0-DDLN-212-SDD-2.html for the second page of the
+	// document.
+	Name  string
+	Title string
+	// Number is that of the page among the Count pages of the document, from 1, and Prev and Next
+	// the names of the pages around it, if any.
+	Number, Count int
+	Prev, Next    string
+	// Code is whether the page lists code files rather than requirements.
+	Code bool
+	Reqs []*Req
+}
+
+// splitDocument is a certification document, or the code files, in the index of a split report.
+type splitDocument struct {
+	Title string
+	Reqs  int
+	Pages []*splitPage
+}
+
+// splitLink is a link to a requirement or a code file of a split report, Href being empty if it's
+// not in the report.
+type splitLink struct {
+	ID, Href string
+}
+
+// splitLinks are the links of a requirement or a code file to others, e.g. its parents.
+type splitLinks struct {
+	Name  string
+	Links []splitLink
+}
+
+type splitIndexData struct {
+	Docs   []*splitDocument
+	Filter ReqFilter
+}
+
+type splitPageData struct {
+	Page   *splitPage
+	Filter ReqFilter
+	hrefs  map[string]string
+}
+
+// Links returns the links to the requirements or code files, to their pages.
+func (d splitPageData) Links(name string, reqs []*Req) splitLinks {
+	links := splitLinks{Name: name}
+	for _, r := range reqs {
+		links.Links = append(links.Links, splitLink{r.ID, d.hrefs[r.ID]})
+	}
+	return links
+}
+
+// paginate splits the requirements into pages of at most size requirements, all of them in a
+// single page if size is 0, named after base.
+func paginate(title, base string, reqs []*Req, size int, code bool) *splitDocument {
+	doc := &splitDocument{Title: title, Reqs: len(reqs)}
+	if size <= 0 {
+		size = len(reqs)
+	}
+	for start := 0; start < len(reqs); start += size {
+		end := start + size
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		name := base + ".html"
+		if start > 0 {
+			name = fmt.Sprintf("%s-%d.html", base, len(doc.Pages)+1)
+		}
+		doc.Pages = append(doc.Pages, &splitPage{Name: name, Title: title, Number: len(doc.Pages) + 1, Code: code, Reqs: reqs[start:end]})
+	}
+	for i, p := range doc.Pages {
+		p.Count = len(doc.Pages)
+		if i > 0 {
+			p.Prev = doc.Pages[i-1].Name
+		}
+		if i < len(doc.Pages)-1 {
+			p.Next = doc.Pages[i+1].Name
+		}
+	}
+	return doc
+}
+
+// ReportSplit writes the top-down report of the requirements matching the filter and the diffs as
+// several HTML files in the directory, for the graphs too large for a single one: a page per
+// certification document, split into pages of at most pageSize requirements unless pageSize is 0,
+// pages of the code files referencing them, and an index of the pages. The parents and the
+// children of the requirements, and the requirements of the code files, link to their pages.
+func (rg reqGraph) ReportSplit(dir string, f ReqFilter, diffs map[string][]string, pageSize int) error {
+	byDoc := map[string][]*Req{}
+	var names []string
+	included := map[*Req]bool{}
+	for _, r := range rg {
+		if r.Level == config.CODE || !r.Matches(f, diffs) {
+			continue
+		}
+		doc := scopeDocument(r)
+		if byDoc[doc] == nil {
+			names = append(names, doc)
+		}
+		byDoc[doc] = append(byDoc[doc], r)
+		included[r] = true
+	}
+	sort.Strings(names)
+
+	var docs []*splitDocument
+	for _, name := range names {
+		sort.Sort(byPosition(byDoc[name]))
+		docs = append(docs, paginate(name, name, byDoc[name], pageSize, false))
+	}
+	var code []*Req
+	for _, c := range rg.CodeFilesByPosition() {
+		for _, r := range append(append(append([]*Req{}, c.Parents...), c.Verifies...), c.PartiallyImplements...) {
+			if included[r] {
+				code = append(code, c)
+				break
+			}
+		}
+	}
+	sort.Sort(byIDOrPath(code))
+	if len(code) > 0 {
+		docs = append(docs, paginate("Code Files", "code", code, pageSize, true))
+	}
+
+	hrefs := map[string]string{}
+	for _, d := range docs {
+		for _, p := range d.Pages {
+			for _, r := range p.Reqs {
+				hrefs[r.ID] = p.Name + "#" + r.ID
+			}
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := writeSplitFile(filepath.Join(dir, splitIndex), "SPLITINDEX", splitIndexData{docs, f}); err != nil {
+		return err
+	}
+	for _, d := range docs {
+		for _, p := range d.Pages {
+			if err := writeSplitFile(filepath.Join(dir, p.Name), "SPLITPAGE", splitPageData{p, f, hrefs}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSplitFile writes a file of a split report with the template.
+func writeSplitFile(fileName, tmpl string, data interface{}) error {
+	of, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	if err := reportTmpl.ExecuteTemplate(of, tmpl, data); err != nil {
+		of.Close()
+		return err
+	}
+	return of.Close()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_ReportSplit(t *testing.T) {
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SYS-001", Path: "/certdocs/0-TEST-100-ORD.md", Level: config.SYSTEM, Title: "System"},
+		{ID: "REQ-0-TEST-SWH-001", Path: "/certdocs/0-TEST-211-SRD.md", Level: config.HIGH, Title: "High", ParentIds: []string{"REQ-0-TEST-SYS-001"}},
+		{ID: "REQ-0-TEST-SWL-001", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "First", Position: 1, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		{ID: "REQ-0-TEST-SWL-002", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "Second", Position: 2, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+		{ID: "REQ-0-TEST-SWL-003", Path: "/certdocs/0-TEST-212-SDD.md", Level: config.LOW, Title: "Third", Position: 3, ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+	} {
+		rg[r.ID] = r
+	}
+	rg["/repo/a.go"] = &Req{ID: "a.go", Path: "/repo/a.go", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-003"}}
+	assert.NoError(t, rg.Resolve())
+
+	dir, err := ioutil.TempDir("", "reqtraq-split-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, rg.ReportSplit(dir, nil, nil, 2))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"0-TEST-100-ORD.html", "0-TEST-211-SRD.html", "0-TEST-212-SDD-2.html", "0-TEST-212-SDD.html", "code.html", "index.html"}, names)
+
+	read := func(name string) string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		return string(b)
+	}
+	index := read("index.html")
+	assert.Contains(t, index, `<a href="0-TEST-212-SDD.html">0-TEST-212-SDD</a>`)
+	assert.Contains(t, index, `<a href="0-TEST-212-SDD.html">1</a> <a href="0-TEST-212-SDD-2.html">2</a>`)
+
+	page := read("0-TEST-212-SDD-2.html")
+	assert.Contains(t, page, "Page 2 of 2")
+	assert.Contains(t, page, `<a href="0-TEST-212-SDD.html">Previous</a>`)
+	assert.Contains(t, page, "REQ-0-TEST-SWL-003 Third")
+	assert.NotContains(t, page, "REQ-0-TEST-SWL-001 First")
+	assert.Contains(t, page, `<a href="0-TEST-211-SRD.html#REQ-0-TEST-SWH-001">REQ-0-TEST-SWH-001</a>`)
+	assert.Contains(t, page, `<a href="code.html#a.go">a.go</a>`)
+	assert.Contains(t, read("code.html"), `<a href="0-TEST-212-SDD-2.html#REQ-0-TEST-SWL-003">REQ-0-TEST-SWL-003</a>`)
+
+	// The requirements filtered out aren't linked.
+	filtered := filepath.Join(dir, "filtered")
+	assert.NoError(t, rg.ReportSplit(filtered, nil, map[string][]string{"REQ-0-TEST-SWL-001": nil}, 0))
+	b, err := ioutil.ReadFile(filepath.Join(filtered, "0-TEST-212-SDD.html"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), "REQ-0-TEST-SWH-001 ")
+	assert.NotContains(t, string(b), `href="0-TEST-211-SRD.html`)
+	_, err = os.Stat(filepath.Join(filtered, "code.html"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,216 @@
+// @llr REQ-0-DDLN-SWL-041
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// SubmissionConf lays out a data item of a certification submission, e.g. the Software
+// Requirements Data submitted to the FAA or EASA, as one of the "submissions" in attributes.json.
+// The data items are exported as HTML documents, with their name as format.
+type SubmissionConf struct {
+	Title string `json:"title"`
+	// Types are the types of the requirements of the data item, in the order of its sections, e.g.
+	// SWH for the high-level requirements.
+	Types []string `json:"types"`
+	// Sections are the parts of each requirement, in order: body, rationale, acceptance_criteria,
+	// notes, attribute:<name>, parents, children, code and verification, the code files and the
+	// test cases verifying the requirement. The empty parts are skipped.
+	Sections []string `json:"sections"`
+	// Numbering is decimal, e.g. 2.3 for the third requirement of the second document, or none.
+	Numbering string `json:"numbering"`
+	// Header and Footer are the text of the page headers and footers, the title and the page
+	// numbers by default.
+	Header string `json:"header"`
+	Footer string `json:"footer"`
+}
+
+// defaultSubmissions are the data items of DO-178C exported when attributes.json doesn't
+// configure them.
+var defaultSubmissions = map[string]SubmissionConf{
+	"srs": {
+		Title:     "Software Requirements Data",
+		Types:     []string{"SWH"},
+		Sections:  []string{"body", "rationale", "attribute:Safety Impact", "attribute:Verification", "parents"},
+		Numbering: "decimal",
+	},
+	"sdd": {
+		Title:     "Software Design Description",
+		Types:     []string{"SWL"},
+		Sections:  []string{"body", "rationale", "parents", "code"},
+		Numbering: "decimal",
+	},
+	"svcp": {
+		Title:     "Software Verification Cases and Procedures",
+		Types:     []string{"SWH", "SWL"},
+		Sections:  []string{"acceptance_criteria", "attribute:Verification", "verification"},
+		Numbering: "decimal",
+	},
+}
+
+// submissionSections render the parts of a requirement, by section, as their heading and their
+// content, empty if the requirement has none.
+var submissionSections = map[string]func(r *Req) (string, template.HTML){
+	"body": func(r *Req) (string, template.HTML) { return "", r.LoadBody() },
+	"rationale": func(r *Req) (string, template.HTML) {
+		if r.Rationale != "" {
+			return "Rationale", r.Rationale
+		}
+		return "Rationale", template.HTML(template.HTMLEscapeString(r.Attributes["RATIONALE"]))
+	},
+	"acceptance_criteria": func(r *Req) (string, template.HTML) { return "Acceptance Criteria", r.AcceptanceCriteria },
+	"notes":               func(r *Req) (string, template.HTML) { return "Notes", r.Notes },
+	"parents":             func(r *Req) (string, template.HTML) { return "Parents", submissionLinks(r.Parents) },
+	"children": func(r *Req) (string, template.HTML) {
+		var children []*Req
+		for _, c := range r.Children {
+			if c.Level != config.CODE {
+				children = append(children, c)
+			}
+		}
+		return "Children", submissionLinks(children)
+	},
+	"code": func(r *Req) (string, template.HTML) { return "Code", submissionLinks(r.CodeFiles()) },
+	"verification": func(r *Req) (string, template.HTML) {
+		var items []string
+		for _, c := range r.VerifiedBy {
+			items = append(items, template.HTMLEscapeString(c.ID))
+		}
+		for _, tc := range r.TestCases {
+			items = append(items, template.HTMLEscapeString(tc.ID+" "+tc.Title+": "+tc.Result))
+		}
+		return "Verification Cases", template.HTML(strings.Join(items, "<br>"))
+	},
+}
+
+// submissionLinks returns the IDs of the requirements, linked to their sections if they are part
+// of the data item.
+func submissionLinks(reqs []*Req) template.HTML {
+	var links []string
+	for _, r := range reqs {
+		id := template.HTMLEscapeString(r.ID)
+		links = append(links, `<a href="#`+id+`">`+id+`</a>`)
+	}
+	return template.HTML(strings.Join(links, ", "))
+}
+
+func (s SubmissionConf) validate(name string) error {
+	if s.Numbering != "decimal" && s.Numbering != "none" {
+		return fmt.Errorf("Invalid submission %s: unknown numbering %q, expected decimal or none", name, s.Numbering)
+	}
+	if len(s.Types) == 0 {
+		return fmt.Errorf("Invalid submission %s: no requirement types", name)
+	}
+	for _, t := range s.Types {
+		if _, ok := config.ReqTypeToReqLevel[t]; !ok {
+			return fmt.Errorf("Invalid submission %s: unknown requirement type %q", name, t)
+		}
+	}
+	for _, section := range s.Sections {
+		if _, ok := submissionSections[section]; !ok && !strings.HasPrefix(section, matrixAttributePrefix) {
+			return fmt.Errorf("Invalid submission %s: unknown section %q", name, section)
+		}
+	}
+	return nil
+}
+
+// registerSubmissions adds the data items to the export formats, the configured ones replacing
+// the default ones of the same name.
+func registerSubmissions(submissions map[string]SubmissionConf) error {
+	all := map[string]SubmissionConf{}
+	for name, s := range defaultSubmissions {
+		all[name] = s
+	}
+	for name, s := range submissions {
+		all[name] = s
+	}
+	for name, s := range all {
+		if err := s.validate(name); err != nil {
+			return err
+		}
+		if _, ok := exporters[name]; ok {
+			return fmt.Errorf("Invalid submission %s: the export format already exists", name)
+		}
+	}
+	for name, s := range all {
+		exporters[name] = s.export
+	}
+	return nil
+}
+
+type submissionPart struct {
+	Heading string
+	Content template.HTML
+}
+
+type submissionReq struct {
+	Number string
+	Req    *Req
+	Parts  []submissionPart
+}
+
+type submissionDoc struct {
+	Number string
+	Name   string
+	Reqs   []submissionReq
+}
+
+type submissionData struct {
+	Conf SubmissionConf
+	Docs []submissionDoc
+}
+
+// export writes the data item as an HTML document, with a section per certification document
+// of the types of the data item, in the order of the types, and a subsection per requirement, in
+// document order. The deleted requirements are skipped.
+func (s SubmissionConf) export(rg reqGraph, w io.Writer) error {
+	data := submissionData{Conf: s}
+	if s.Header == "" {
+		data.Conf.Header = s.Title
+	}
+	for _, t := range s.Types {
+		var reqs []*Req
+		for _, r := range rg {
+			if !r.IsDeleted() && isOfType(r, t) {
+				reqs = append(reqs, r)
+			}
+		}
+		sort.Sort(byModulePosition(reqs))
+		for _, r := range reqs {
+			module := doorsModule(r.Path)
+			if len(data.Docs) == 0 || data.Docs[len(data.Docs)-1].Name != module {
+				data.Docs = append(data.Docs, submissionDoc{Name: module})
+			}
+			doc := &data.Docs[len(data.Docs)-1]
+			sr := submissionReq{Req: r}
+			for _, section := range s.Sections {
+				p := submissionPart{}
+				if strings.HasPrefix(section, matrixAttributePrefix) {
+					p.Heading = strings.TrimPrefix(section, matrixAttributePrefix)
+					p.Content = template.HTML(template.HTMLEscapeString(r.Attributes[strings.ToUpper(p.Heading)]))
+				} else {
+					p.Heading, p.Content = submissionSections[section](r)
+				}
+				if strings.TrimSpace(string(p.Content)) != "" {
+					sr.Parts = append(sr.Parts, p)
+				}
+			}
+			doc.Reqs = append(doc.Reqs, sr)
+		}
+	}
+	if s.Numbering == "decimal" {
+		for i := range data.Docs {
+			data.Docs[i].Number = fmt.Sprint(i + 1)
+			for j := range data.Docs[i].Reqs {
+				data.Docs[i].Reqs[j].Number = fmt.Sprintf("%d.%d", i+1, j+1)
+			}
+		}
+	}
+	return reportTmpl.ExecuteTemplate(w, "SUBMISSION", data)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterSubmissions(t *testing.T) {
+	defer func() {
+		for _, name := range []string{"srs", "sdd", "svcp", "hsi"} {
+			delete(exporters, name)
+		}
+	}()
+	assert.NoError(t, registerSubmissions(map[string]SubmissionConf{
+		"hsi": {Title: "Hardware/Software Interface", Types: []string{"SWL"}, Sections: []string{"body"}, Numbering: "none"},
+	}))
+	for _, name := range []string{"srs", "sdd", "svcp", "hsi"} {
+		assert.Contains(t, exporters, name)
+	}
+	if err := registerSubmissions(nil); assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the export format already exists")
+	}
+	for name := range defaultSubmissions {
+		delete(exporters, name)
+	}
+	delete(exporters, "hsi")
+
+	assert.EqualError(t, registerSubmissions(map[string]SubmissionConf{"doors": {Types: []string{"SWH"}, Numbering: "none"}}),
+		"Invalid submission doors: the export format already exists")
+	assert.EqualError(t, registerSubmissions(map[string]SubmissionConf{"srs": {Types: []string{"SWH"}, Numbering: "roman"}}),
+		`Invalid submission srs: unknown numbering "roman", expected decimal or none`)
+	assert.EqualError(t, registerSubmissions(map[string]SubmissionConf{"srs": {Types: []string{"HLR"}, Numbering: "none"}}),
+		`Invalid submission srs: unknown requirement type "HLR"`)
+	assert.EqualError(t, registerSubmissions(map[string]SubmissionConf{"srs": {Types: []string{"SWH"}, Sections: []string{"owner"}, Numbering: "none"}}),
+		`Invalid submission srs: unknown section "owner"`)
+	assert.NotContains(t, exporters, "sdd")
+}
+
+func TestSubmissionConf_export(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Title: "System"}
+	var high []*Req
+	for i, path := range []string{"certdocs/0-TEST-211-SRD.md", "certdocs/0-TEST-211-SRD.md", "certdocs/0-TEST-212-SRD.md"} {
+		high = append(high, &Req{ID: "REQ-0-TEST-SWH-00" + string(rune('1'+i)), Level: config.HIGH, Path: path, Position: i,
+			Title: "High", Body: template.HTML("<p>Body " + string(rune('1'+i)) + "</p>"), Parents: []*Req{sys},
+			Attributes: map[string]string{"RATIONALE": "Because <b>", "SAFETY IMPACT": "None"}})
+	}
+	high[1].Rationale = "<p>Sectioned rationale</p>"
+	deleted := &Req{ID: "REQ-0-TEST-SWH-004", Level: config.HIGH, Path: "certdocs/0-TEST-212-SRD.md", Position: 1, Title: "DELETED"}
+	rg := reqGraph{sys.ID: sys, high[0].ID: high[0], high[1].ID: high[1], high[2].ID: high[2], deleted.ID: deleted}
+
+	conf := defaultSubmissions["srs"]
+	conf.Footer = "Company Confidential"
+	var b bytes.Buffer
+	assert.NoError(t, conf.export(rg, &b))
+	doc := b.String()
+	assert.True(t, regexp.MustCompile(`(?s)<h1>Software Requirements Data</h1>\s*`+
+		`<h2>1 0-TEST-211-SRD</h2>.*<h3><a name="REQ-0-TEST-SWH-001"></a>1.1 REQ-0-TEST-SWH-001 High</h3>.*<p>Body 1</p>.*`+
+		`<strong>Rationale:</strong></p>\s*<div>Because &lt;b&gt;</div>.*<strong>Safety Impact:</strong></p>\s*<div>None</div>.*`+
+		`<strong>Parents:</strong></p>\s*<div><a href="#REQ-0-TEST-SYS-001">REQ-0-TEST-SYS-001</a></div>.*`+
+		`1.2 REQ-0-TEST-SWH-002.*Sectioned rationale.*<h2>2 0-TEST-212-SRD</h2>.*2.1 REQ-0-TEST-SWH-003`).MatchString(doc))
+	assert.NotContains(t, doc, "REQ-0-TEST-SWH-004")
+	assert.NotContains(t, doc, "Verification:")
+	assert.Contains(t, doc, `@top-center { content: "Software Requirements Data"; }`)
+	assert.Contains(t, doc, `<p class="footer">Company Confidential</p>`)
+
+	conf.Numbering = "none"
+	b.Reset()
+	assert.NoError(t, conf.export(rg, &b))
+	assert.Contains(t, b.String(), "<h2>0-TEST-211-SRD</h2>")
+	assert.Contains(t, b.String(), `</a>REQ-0-TEST-SWH-001 High</h3>`)
+}
@@ -0,0 +1,281 @@
+// @llr REQ-0-DDLN-SWL-021
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// Kinds of suggestion queries.
+const (
+	parentsQuery      = "parents"      // likely parents of a requirement
+	requirementsQuery = "requirements" // likely requirements implemented by a code file
+)
+
+// SuggestCandidate is a requirement which can be suggested.
+type SuggestCandidate struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// SuggestQuery is what a Suggester is asked about: either a requirement, or a code file, and
+// the candidates to rank.
+type SuggestQuery struct {
+	Kind       string             `json:"kind"`
+	ID         string             `json:"id,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Body       string             `json:"body,omitempty"`
+	Path       string             `json:"path,omitempty"`
+	Code       string             `json:"code,omitempty"`
+	Candidates []SuggestCandidate `json:"candidates"`
+}
+
+// Suggestion is a candidate ranked by a Suggester. The higher the score, the more likely the
+// suggestion.
+type Suggestion struct {
+	ID    string  `json:"id"`
+	Score float64 `json:"score"`
+	// Filled in by the graph: the suggested requirement, and whether it is already linked.
+	Req    *Req `json:"-"`
+	Linked bool `json:"-"`
+}
+
+// Suggester ranks the candidates of a query. Suggestions for unknown candidates are ignored.
+type Suggester interface {
+	Suggest(q *SuggestQuery) ([]Suggestion, error)
+}
+
+// SuggestConf is the configuration of the suggestions, the "suggest" entry in attributes.json.
+type SuggestConf struct {
+	// Command run to get the suggestions, e.g. a client of an external service or an embedding
+	// model. It reads the SuggestQuery as json on stdin and writes a json list of Suggestions on
+	// stdout. The built-in word similarity is used if empty.
+	Command []string `json:"command"`
+	// Max is the maximum number of suggestions presented.
+	Max int `json:"max"`
+}
+
+const defaultSuggestMax = 10
+
+// Suggester returns the Suggester configured by c.
+func (c SuggestConf) Suggester() Suggester {
+	if len(c.Command) == 0 {
+		return wordSuggester{}
+	}
+	return commandSuggester(c.Command)
+}
+
+// commandSuggester runs an external command to get the suggestions.
+type commandSuggester []string
+
+func (c commandSuggester) Suggest(q *SuggestQuery) ([]Suggestion, error) {
+	in, err := json.Marshal(q)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(c[0], c[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Suggester '%s' failed: %v", strings.Join(c, " "), err)
+	}
+	var s []Suggestion
+	if err := json.Unmarshal(out, &s); err != nil {
+		return nil, fmt.Errorf("Invalid output of suggester '%s': %v", strings.Join(c, " "), err)
+	}
+	return s, nil
+}
+
+// wordSuggester ranks the candidates by the cosine similarity of their word frequencies with
+// the ones of the query.
+type wordSuggester struct{}
+
+func (wordSuggester) Suggest(q *SuggestQuery) ([]Suggestion, error) {
+	query := wordFrequencies(q.Title + " " + q.Body + " " + q.Code)
+	var s []Suggestion
+	for _, c := range q.Candidates {
+		if score := cosineSimilarity(query, wordFrequencies(c.Title+" "+c.Body)); score > 0 {
+			s = append(s, Suggestion{ID: c.ID, Score: score})
+		}
+	}
+	return s, nil
+}
+
+var (
+	reWord    = regexp.MustCompile(`[A-Za-z][a-z]+|[A-Z]+`)
+	stopWords = map[string]bool{
+		"and": true, "are": true, "for": true, "from": true, "not": true, "shall": true,
+		"that": true, "the": true, "this": true, "which": true, "with": true,
+	}
+)
+
+// wordFrequencies splits text, including camel-cased identifiers, in lower-case words and
+// counts them.
+func wordFrequencies(text string) map[string]float64 {
+	f := map[string]float64{}
+	for _, w := range reWord.FindAllString(text, -1) {
+		w = strings.ToLower(w)
+		if len(w) < 3 || stopWords[w] {
+			continue
+		}
+		f[w]++
+	}
+	return f
+}
+
+func cosineSimilarity(a, b map[string]float64) float64 {
+	var dot, na, nb float64
+	for w, x := range a {
+		dot += x * b[w]
+		na += x * x
+	}
+	for _, y := range b {
+		nb += y * y
+	}
+	if dot == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+var reHTMLTag = regexp.MustCompile(`<[^>]*>`)
+
+// plainText strips the HTML markup from a requirement body.
+func plainText(body string) string {
+	return strings.TrimSpace(html.UnescapeString(reHTMLTag.ReplaceAllString(body, "")))
+}
+
+// SuggestFor returns the suggestions for what, which is either the ID of a requirement, or
+// the name of a code file of the graph or under codeDir, the directory of the code files. No
+// other file is read, as its content is sent to the suggester.
+func (rg reqGraph) SuggestFor(conf SuggestConf, what, codeDir string) ([]Suggestion, error) {
+	if r, ok := rg[what]; ok && r.Level != config.CODE {
+		return rg.SuggestParents(conf.Suggester(), what, conf.Max)
+	}
+	if ReReqID.MatchString(what) {
+		return nil, fmt.Errorf("Unknown requirement %s", what)
+	}
+	fileName, err := filepath.Abs(what)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := rg[fileName]; !ok || r.Level != config.CODE {
+		if !isCodeFileIn(codeDir, fileName) {
+			return nil, fmt.Errorf("Unknown requirement or code file %s", what)
+		}
+	}
+	return rg.SuggestRequirements(conf.Suggester(), fileName, conf.Max)
+}
+
+// isCodeFileIn returns whether the file is a code file in the directory dir, the symbolic links
+// resolved.
+func isCodeFileIn(dir, fileName string) bool {
+	if codeFileLanguage(dir, fileName) == nil {
+		return false
+	}
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	fileName, err = filepath.EvalSymlinks(fileName)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, fileName)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// SuggestParents asks s for the likely parents of the requirement with the given ID, among the
+// requirements one level above which are not deleted.
+func (rg reqGraph) SuggestParents(s Suggester, id string, max int) ([]Suggestion, error) {
+	r, ok := rg[id]
+	if !ok || r.Level == config.CODE {
+		return nil, fmt.Errorf("Unknown requirement %s", id)
+	}
+	if r.Level == config.SYSTEM {
+		return nil, fmt.Errorf("Requirement %s is a system requirement, it has no parents", id)
+	}
+	q := &SuggestQuery{Kind: parentsQuery, ID: r.ID, Title: r.Title, Body: plainText(string(r.LoadBody()))}
+	return rg.suggest(s, q, r.Level-1, r.ParentIds, max)
+}
+
+// SuggestRequirements asks s for the likely low-level requirements implemented by the code
+// file, which does not need to reference any requirement yet.
+func (rg reqGraph) SuggestRequirements(s Suggester, fileName string, max int) ([]Suggestion, error) {
+	code, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var linked []string
+	if r, ok := rg[fileName]; ok {
+		linked = r.ParentIds
+	}
+	q := &SuggestQuery{Kind: requirementsQuery, Path: fileName, Code: string(code)}
+	return rg.suggest(s, q, config.LOW, linked, max)
+}
+
+// suggest fills in the candidates of q from the requirements at the given level, and returns
+// at most max of the suggestions of s by decreasing score.
+func (rg reqGraph) suggest(s Suggester, q *SuggestQuery, level config.RequirementLevel, linked []string, max int) ([]Suggestion, error) {
+	for _, r := range rg {
+		if r.Level == level && !r.IsDeleted() {
+			q.Candidates = append(q.Candidates, SuggestCandidate{r.ID, r.Title, plainText(string(r.LoadBody()))})
+		}
+	}
+	sort.Sort(byCandidateID(q.Candidates))
+
+	suggestions, err := s.Suggest(q)
+	if err != nil {
+		return nil, err
+	}
+	isCandidate := map[string]bool{}
+	for _, c := range q.Candidates {
+		isCandidate[c.ID] = true
+	}
+	isLinked := map[string]bool{}
+	for _, id := range linked {
+		isLinked[id] = true
+	}
+	var res []Suggestion
+	for _, sg := range suggestions {
+		if !isCandidate[sg.ID] {
+			continue
+		}
+		isCandidate[sg.ID] = false // ignore duplicates
+		sg.Req = rg[sg.ID]
+		sg.Linked = isLinked[sg.ID]
+		res = append(res, sg)
+	}
+	sort.Stable(byScore(res))
+	if max > 0 && len(res) > max {
+		res = res[:max]
+	}
+	return res, nil
+}
+
+type byCandidateID []SuggestCandidate
+
+func (a byCandidateID) Len() int           { return len(a) }
+func (a byCandidateID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCandidateID) Less(i, j int) bool { return a[i].ID < a[j].ID }
+
+// byScore sorts by decreasing score.
+type byScore []Suggestion
+
+func (a byScore) Len() int           { return len(a) }
+func (a byScore) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byScore) Less(i, j int) bool { return a[i].Score > a[j].Score }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func suggestTestGraph() reqGraph {
+	return reqGraph{
+		"REQ-0-TEST-SYS-001": &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "Export the requirements to spreadsheets"},
+		"REQ-0-TEST-SYS-002": &Req{ID: "REQ-0-TEST-SYS-002", Level: config.SYSTEM, Title: "Web interface", Body: "<p>Serve the <b>reports</b> over HTTP.</p>"},
+		"REQ-0-TEST-SYS-003": &Req{ID: "REQ-0-TEST-SYS-003", Level: config.SYSTEM, Title: "DELETED Web reports"},
+		"REQ-0-TEST-SWH-001": &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "Serve the HTTP reports", ParentIds: []string{"REQ-0-TEST-SYS-002"}},
+		"REQ-0-TEST-SWH-002": &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "Spreadsheet export", Body: "<p>Export to spreadsheets</p>"},
+	}
+}
+
+func TestReqGraph_SuggestParents(t *testing.T) {
+	rg := suggestTestGraph()
+
+	s, err := rg.SuggestParents(wordSuggester{}, "REQ-0-TEST-SWH-002", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, s, 1) {
+		assert.Equal(t, "REQ-0-TEST-SYS-001", s[0].ID)
+		assert.Equal(t, rg["REQ-0-TEST-SYS-001"], s[0].Req)
+		assert.False(t, s[0].Linked)
+	}
+
+	// Deleted requirements are not suggested, existing parents are flagged.
+	s, err = rg.SuggestParents(wordSuggester{}, "REQ-0-TEST-SWH-001", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, s, 1) {
+		assert.Equal(t, "REQ-0-TEST-SYS-002", s[0].ID)
+		assert.True(t, s[0].Linked)
+	}
+
+	_, err = rg.SuggestParents(wordSuggester{}, "REQ-0-TEST-SYS-001", 10)
+	assert.EqualError(t, err, "Requirement REQ-0-TEST-SYS-001 is a system requirement, it has no parents")
+	_, err = rg.SuggestParents(wordSuggester{}, "REQ-0-TEST-SWH-003", 10)
+	assert.EqualError(t, err, "Unknown requirement REQ-0-TEST-SWH-003")
+}
+
+func TestReqGraph_SuggestFor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	codeDir := filepath.Join(dir, "code")
+	assert.NoError(t, os.MkdirAll(codeDir, 0755))
+	for _, name := range []string{"code/export.go", "code/notes.txt", "secrets.go"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte("// Export the requirements to spreadsheets."), 0644))
+	}
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "secrets.go"), filepath.Join(codeDir, "link.go")))
+	rg := suggestTestGraph()
+
+	_, err = rg.SuggestFor(SuggestConf{Max: 10}, filepath.Join(codeDir, "export.go"), codeDir)
+	assert.NoError(t, err)
+	s, err := rg.SuggestFor(SuggestConf{Max: 10}, "REQ-0-TEST-SWH-002", codeDir)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, s)
+
+	// Only the code files are read, their content being sent to the suggester.
+	for _, what := range []string{"/etc/passwd", filepath.Join(dir, "secrets.go"), filepath.Join(codeDir, "notes.txt"),
+		filepath.Join(codeDir, "..", "secrets.go"), filepath.Join(codeDir, "link.go")} {
+		_, err = rg.SuggestFor(SuggestConf{Max: 10}, what, codeDir)
+		assert.EqualError(t, err, "Unknown requirement or code file "+what)
+	}
+	// The code files of the graph are.
+	rg[filepath.Join(dir, "secrets.go")] = &Req{ID: "secrets.go", Level: config.CODE}
+	_, err = rg.SuggestFor(SuggestConf{Max: 10}, filepath.Join(dir, "secrets.go"), codeDir)
+	assert.NoError(t, err)
+}
+
+func TestCommandSuggester(t *testing.T) {
+	rg := suggestTestGraph()
+
+	// Unknown, deleted and duplicate suggestions are dropped, the rest is sorted and truncated.
+	s, err := rg.SuggestParents(commandSuggester{"sh", "-c", `grep -q '"kind":"parents"' && echo '[
+		{"id": "REQ-0-TEST-SYS-001", "score": 0.2},
+		{"id": "REQ-0-TEST-SYS-003", "score": 0.9},
+		{"id": "REQ-0-TEST-SYS-009", "score": 0.8},
+		{"id": "REQ-0-TEST-SYS-002", "score": 0.5},
+		{"id": "REQ-0-TEST-SYS-002", "score": 0.1}
+	]'`}, "REQ-0-TEST-SWH-001", 1)
+	assert.NoError(t, err)
+	if assert.Len(t, s, 1) {
+		assert.Equal(t, "REQ-0-TEST-SYS-002", s[0].ID)
+		assert.Equal(t, 0.5, s[0].Score)
+	}
+
+	_, err = rg.SuggestParents(commandSuggester{"sh", "-c", "echo nope"}, "REQ-0-TEST-SWH-001", 1)
+	assert.Contains(t, err.Error(), "Invalid output of suggester 'sh -c echo nope'")
+	_, err = rg.SuggestParents(commandSuggester{"false"}, "REQ-0-TEST-SWH-001", 1)
+	assert.Contains(t, err.Error(), "Suggester 'false' failed")
+}
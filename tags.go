@@ -0,0 +1,118 @@
+// @llr REQ-0-DDLN-SWL-018
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/taskmgr"
+)
+
+// TagRule tags the tasks of the requirements having an attribute, the "tags" entries in
+// attributes.json, for example:
+//
+//	{ "attribute": "MODE" }
+//	{ "attribute": "SAFETY IMPACT", "value": "High", "tag": "Safety critical" }
+type TagRule struct {
+	// Attribute is the name of the attribute, case insensitive.
+	Attribute string `json:"attribute"`
+	// Value is a regular expression the whole value must match, any value if empty.
+	Value string `json:"value"`
+	// Tag is the name of the tag, "<attribute>: <value>" if empty.
+	Tag string `json:"tag"`
+}
+
+// tagger computes the tags of the requirements from the compiled tag rules.
+type tagger struct {
+	rules  []TagRule
+	values []*regexp.Regexp
+}
+
+func newTagger(rules []TagRule) (*tagger, error) {
+	t := &tagger{rules: rules}
+	for _, r := range rules {
+		if r.Attribute == "" {
+			return nil, fmt.Errorf("Invalid tag rule: no attribute")
+		}
+		var expr *regexp.Regexp
+		if r.Value != "" {
+			var err error
+			if expr, err = regexp.Compile(`^(?:` + r.Value + `)$`); err != nil {
+				return nil, fmt.Errorf("Invalid tag rule value for attribute %s: %v", r.Attribute, err)
+			}
+		}
+		t.values = append(t.values, expr)
+	}
+	return t, nil
+}
+
+// tags returns the names of the tags of the requirement, sorted.
+func (t *tagger) tags(r *Req) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for i, rule := range t.rules {
+		value, ok := r.Attributes[strings.ToUpper(rule.Attribute)]
+		value = strings.TrimSpace(value)
+		if !ok || value == "" || (t.values[i] != nil && !t.values[i].MatchString(value)) {
+			continue
+		}
+		tag := rule.Tag
+		if tag == "" {
+			tag = strings.ToUpper(rule.Attribute) + ": " + value
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// syncTaskTags sets the tags of the task, which were last set to last. Only the differences are
+// applied, so the tags set in the task manager are kept.
+func (t *tagger) syncTaskTags(taskID string, tags, last []string) error {
+	add, remove := tagDiff(tags, last), tagDiff(last, tags)
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+	addIDs, err := tagIDs(add)
+	if err != nil {
+		return err
+	}
+	removeIDs, err := tagIDs(remove)
+	if err != nil {
+		return err
+	}
+	return taskmgr.TaskMgr.UpdateTaskTags(taskID, addIDs, removeIDs)
+}
+
+// tagDiff returns the tags of a which aren't in b.
+func tagDiff(a, b []string) []string {
+	in := map[string]bool{}
+	for _, t := range b {
+		in[t] = true
+	}
+	var diff []string
+	for _, t := range a {
+		if !in[t] {
+			diff = append(diff, t)
+		}
+	}
+	return diff
+}
+
+// tagIDs returns the IDs of the tags with the given names, creating the missing ones.
+func tagIDs(names []string) ([]string, error) {
+	var ids []string
+	for _, n := range names {
+		id, err := taskmgr.TaskMgr.GetOrCreateProject(n, "")
+		if err != nil {
+			return nil, fmt.Errorf("Error getting tag %q, caused by\n%v", n, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,182 @@
+// @llr REQ-0-DDLN-SWL-089
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/taskmgr"
+)
+
+// taskPlanner reads the tasks from the task manager but plans the projects and the tasks to be
+// created rather than creating them, giving them placeholder IDs, so that their children are
+// planned as well. It's shared by the dry runs and the queued syncs.
+type taskPlanner struct {
+	taskmgr.TaskManager
+	// prefix prefixes the placeholder IDs, numbered from 1.
+	prefix string
+	// names are the names of the projects and the display IDs of the tasks, by ID.
+	names map[string]string
+	// placeholders are the IDs of the projects and the tasks planned.
+	placeholders map[string]bool
+	// plannedProject is called with the placeholder ID of each project planned.
+	plannedProject func(id, name, parentID string)
+}
+
+func newTaskPlanner(tmgr taskmgr.TaskManager, prefix string, plannedProject func(id, name, parentID string)) taskPlanner {
+	return taskPlanner{TaskManager: tmgr, prefix: prefix, names: map[string]string{}, placeholders: map[string]bool{}, plannedProject: plannedProject}
+}
+
+// name returns the name of the project or the display ID of the task with the given ID.
+func (p *taskPlanner) name(id string) string {
+	if n, ok := p.names[id]; ok {
+		return n
+	}
+	return id
+}
+
+// found records the display ID of the task found.
+func (p *taskPlanner) found(task *taskmgr.Task, err error) (*taskmgr.Task, error) {
+	if task != nil {
+		p.names[task.ID] = task.DisplayID
+	}
+	return task, err
+}
+
+// placeholder returns the ID of the project or the task planned with the given name.
+func (p *taskPlanner) placeholder(name string) string {
+	id := fmt.Sprintf("%s-%d", p.prefix, len(p.placeholders)+1)
+	p.placeholders[id] = true
+	p.names[id] = name
+	return id
+}
+
+func (p *taskPlanner) GetProject(name string) (string, error) {
+	id, err := p.TaskManager.GetProject(name)
+	if id != "" {
+		p.names[id] = name
+	}
+	return id, err
+}
+
+func (p *taskPlanner) CreateProject(name, parentID string) (string, error) {
+	id := p.placeholder(name)
+	p.plannedProject(id, name, parentID)
+	return id, nil
+}
+
+func (p *taskPlanner) GetOrCreateProject(name, parentID string) (string, error) {
+	id, err := p.GetProject(name)
+	if err != nil || id != "" {
+		return id, err
+	}
+	return p.CreateProject(name, parentID)
+}
+
+func (p *taskPlanner) FindTaskByID(id string) (*taskmgr.Task, error) {
+	return p.found(p.TaskManager.FindTaskByID(id))
+}
+
+// FindTaskByTitle finds no task in the projects planned.
+func (p *taskPlanner) FindTaskByTitle(taskTitle, projectID string) (*taskmgr.Task, error) {
+	if p.placeholders[projectID] {
+		return nil, nil
+	}
+	return p.found(p.TaskManager.FindTaskByTitle(taskTitle, projectID))
+}
+
+// FindTask finds no task in the projects planned.
+func (p *taskPlanner) FindTask(requirementID, requirementTitle, projectID string) (*taskmgr.Task, error) {
+	if p.placeholders[projectID] {
+		return nil, nil
+	}
+	return p.found(p.TaskManager.FindTask(requirementID, requirementTitle, projectID))
+}
+
+func (p *taskPlanner) MappedAttributes() []string {
+	return taskmgr.MappedAttributes(p.TaskManager)
+}
+
+// dryRunTaskManager reports the changes a sync would make to the tasks rather than making them, see
+// UpdateTasks and taskPlanner.
+type dryRunTaskManager struct {
+	taskPlanner
+	out io.Writer
+}
+
+func newDryRunTaskManager(tmgr taskmgr.TaskManager, out io.Writer) *dryRunTaskManager {
+	m := &dryRunTaskManager{out: out}
+	m.taskPlanner = newTaskPlanner(tmgr, "dry-run", func(id, name, parentID string) {
+		fmt.Fprintf(m.out, "Would create project %s\n", name)
+	})
+	return m
+}
+
+// joinNames returns the names of the projects or the display IDs of the tasks, separated by commas,
+// "none" if there are none.
+func (m *dryRunTaskManager) joinNames(ids []string) string {
+	if len(ids) == 0 {
+		return "none"
+	}
+	names := make([]string, len(ids))
+	for i, id := range ids {
+		names[i] = m.name(id)
+	}
+	return strings.Join(names, ", ")
+}
+
+// UpdateTask reports the fields which would be updated, the title and the description as the
+// lines removed and added, the trailers of the descriptions left out.
+func (m *dryRunTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields taskmgr.TaskFields) error {
+	task, err := m.FindTaskByID(taskID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(m.out, "Would update the %s of task %s\n", fields, task.DisplayID)
+	if fields&taskmgr.TaskTitle != 0 {
+		fmt.Fprintf(m.out, "\ttitle: %q -> %q\n", task.Title, title)
+	}
+	if fields&taskmgr.TaskDescription != 0 {
+		fmt.Fprintln(m.out, "\tdescription:")
+		was, _ := splitSyncTrailer(task.Description)
+		now, _ := splitSyncTrailer(taskBody)
+		for _, l := range diffLines(strings.Split(was, "\n"), strings.Split(now, "\n")) {
+			if !strings.HasPrefix(l, " ") {
+				fmt.Fprintf(m.out, "\t%s\n", l)
+			}
+		}
+	}
+	if fields&taskmgr.TaskParents != 0 {
+		fmt.Fprintf(m.out, "\tparents: %s\n", m.joinNames(parentTaskIDs))
+	}
+	if fields&taskmgr.TaskAttributes != 0 {
+		for _, name := range m.MappedAttributes() {
+			if was, now := task.Attributes[name], attributes[name]; was != now {
+				fmt.Fprintf(m.out, "\t%s: %q -> %q\n", name, was, now)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *dryRunTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	if len(addTagIDs) > 0 {
+		fmt.Fprintf(m.out, "Would tag task %s with %s\n", m.name(taskID), m.joinNames(addTagIDs))
+	}
+	if len(removeTagIDs) > 0 {
+		fmt.Fprintf(m.out, "Would untag task %s from %s\n", m.name(taskID), m.joinNames(removeTagIDs))
+	}
+	return nil
+}
+
+func (m *dryRunTaskManager) DeleteTask(taskID, title, projectID string) error {
+	fmt.Fprintf(m.out, "Would mark task %s invalid, titled %q\n", m.name(taskID), title)
+	return nil
+}
+
+func (m *dryRunTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	id := m.placeholder("new task " + strings.SplitN(title, ":", 2)[0])
+	fmt.Fprintf(m.out, "Would create %s in project %s, parents: %s\n\ttitle: %q\n", m.name(id), m.name(projectID), m.joinNames(parentTaskIDs), title)
+	return id, nil
+}
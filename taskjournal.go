@@ -0,0 +1,372 @@
+// @llr REQ-0-DDLN-SWL-092
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/taskmgr"
+)
+
+// journalOp is a change of the task manager planned by a queued sync, the call of the TaskManager
+// method Op with the arguments set. The IDs of the projects and the tasks created by the changes
+// before are placeholders, resolved when the change is made.
+type journalOp struct {
+	Op string `json:"op"`
+	// ID is the placeholder ID of the project or the task created.
+	ID            string             `json:"id,omitempty"`
+	Name          string             `json:"name,omitempty"`
+	TaskID        string             `json:"taskID,omitempty"`
+	Title         string             `json:"title,omitempty"`
+	Body          string             `json:"body,omitempty"`
+	ProjectID     string             `json:"projectID,omitempty"`
+	ParentID      string             `json:"parentID,omitempty"`
+	Attributes    map[string]string  `json:"attributes,omitempty"`
+	ParentTaskIDs []string           `json:"parentTaskIDs,omitempty"`
+	AddTagIDs     []string           `json:"addTagIDs,omitempty"`
+	RemoveTagIDs  []string           `json:"removeTagIDs,omitempty"`
+	Fields        taskmgr.TaskFields `json:"fields,omitempty"`
+}
+
+// resolved returns the change with the placeholder IDs replaced by the IDs created.
+func (op journalOp) resolved(ids map[string]string) journalOp {
+	resolve := func(id string) string {
+		if created, ok := ids[id]; ok {
+			return created
+		}
+		return id
+	}
+	resolveAll := func(in []string) []string {
+		var out []string
+		for _, id := range in {
+			out = append(out, resolve(id))
+		}
+		return out
+	}
+	op.TaskID, op.ProjectID, op.ParentID = resolve(op.TaskID), resolve(op.ProjectID), resolve(op.ParentID)
+	op.ParentTaskIDs = resolveAll(op.ParentTaskIDs)
+	op.AddTagIDs, op.RemoveTagIDs = resolveAll(op.AddTagIDs), resolveAll(op.RemoveTagIDs)
+	return op
+}
+
+// creates returns whether the change creates a project or a task, which isn't idempotent.
+func (op journalOp) creates() bool {
+	return op.Op == "CreateProject" || op.Op == "CreateTask"
+}
+
+// reconciled returns the ID of the project or the task the change creates if it was already
+// created, e.g. when the sync was interrupted before the change was recorded, see taskJournal.
+func (op journalOp) reconciled(tmgr taskmgr.TaskManager) (string, error) {
+	switch op.Op {
+	case "CreateProject":
+		return tmgr.GetProject(op.Name)
+	case "CreateTask":
+		task, err := tmgr.FindTaskByTitle(op.Title, op.ProjectID)
+		if err != nil || task == nil {
+			return "", err
+		}
+		return task.ID, nil
+	}
+	return "", nil
+}
+
+// apply makes the change, returning the ID of the project or the task created, if any.
+func (op journalOp) apply(tmgr taskmgr.TaskManager) (string, error) {
+	switch op.Op {
+	case "CreateProject":
+		return tmgr.CreateProject(op.Name, op.ParentID)
+	case "CreateTask":
+		return tmgr.CreateTask(op.Title, op.Body, op.ProjectID, op.Attributes, op.ParentTaskIDs)
+	case "UpdateTask":
+		return "", tmgr.UpdateTask(op.TaskID, op.Title, op.Body, op.ProjectID, op.Attributes, op.ParentTaskIDs, op.Fields)
+	case "UpdateTaskTags":
+		return "", tmgr.UpdateTaskTags(op.TaskID, op.AddTagIDs, op.RemoveTagIDs)
+	case "DeleteTask":
+		return "", tmgr.DeleteTask(op.TaskID, op.Title, op.ProjectID)
+	}
+	return "", fmt.Errorf("Unknown change of the tasks %q", op.Op)
+}
+
+// journalRecord is a line of the journal of a queued sync: a change planned, the task sync state
+// and the events once all the changes are made, or a change made.
+type journalRecord struct {
+	Op     *journalOp       `json:"op,omitempty"`
+	State  *taskSyncState   `json:"state,omitempty"`
+	Events []LifecycleEvent `json:"events,omitempty"`
+	// Done is the number of changes made, and Created the ID of the project or the task created by
+	// the last one, if any.
+	Done    int    `json:"done,omitempty"`
+	Created string `json:"created,omitempty"`
+	// Started is the number of the change creating a project or a task being made.
+	Started int `json:"started,omitempty"`
+}
+
+// taskJournal is the journal of a queued sync of the tasks: the changes are planned and written
+// to the journal first, then made in order, each change made appended to the journal, so that a
+// sync interrupted, e.g. by network failures, is resumed where it stopped rather than leaving the
+// tracker half updated. The changes creating the projects and the tasks are recorded as started
+// before being made, so that those interrupted before being recorded as made are looked up again
+// when resumed rather than created twice.
+type taskJournal struct {
+	path   string
+	ops    []journalOp
+	state  *taskSyncState
+	events []LifecycleEvent
+	// done is the number of changes made, and ids the IDs created, by placeholder.
+	done int
+	ids  map[string]string
+	// size is the length of the records of the journal, those appended follow.
+	size int64
+	// started is whether the next change was started, and may have been made.
+	started bool
+}
+
+// taskJournalPath returns the path of the journal of the queued syncs, next to the task sync
+// state.
+func taskJournalPath() (string, error) {
+	statePath, err := taskSyncStatePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(statePath), "taskjournal.jsonl"), nil
+}
+
+// loadTaskJournal reads the journal in the given file, nil if it doesn't exist, i.e. no sync is
+// pending.
+func loadTaskJournal(path string) (*taskJournal, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := &taskJournal{path: path, ids: map[string]string{}}
+	// A record not ending the line was cut off when interrupted, the change made but not recorded
+	// is redone.
+	for len(content) > 0 {
+		end := bytes.IndexByte(content, '\n')
+		if end < 0 {
+			break
+		}
+		var r journalRecord
+		if err := json.Unmarshal(content[:end], &r); err != nil {
+			return nil, fmt.Errorf("Invalid journal of the task sync %s: %v", path, err)
+		}
+		content = content[end+1:]
+		j.size += int64(end + 1)
+		switch {
+		case r.Op != nil:
+			j.ops = append(j.ops, *r.Op)
+		case r.State != nil:
+			j.state, j.events = r.State, r.Events
+		case r.Done > 0:
+			if r.Done > len(j.ops) {
+				return nil, fmt.Errorf("Invalid journal of the task sync %s: %d changes made of %d", path, r.Done, len(j.ops))
+			}
+			if id := j.ops[r.Done-1].ID; id != "" {
+				j.ids[id] = r.Created
+			}
+			j.done = r.Done
+		case r.Started > 0:
+			j.started = r.Started == j.done+1
+			continue
+		}
+		j.started = false
+	}
+	if j.state == nil {
+		return nil, fmt.Errorf("Invalid journal of the task sync %s: no sync state", path)
+	}
+	return j, nil
+}
+
+// write writes the changes planned, the state and the events to the journal.
+func (j *taskJournal) write() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(j.path + ".tmp")
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(f)
+	for i := range j.ops {
+		if err := e.Encode(journalRecord{Op: &j.ops[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := e.Encode(journalRecord{State: j.state, Events: j.events}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.size = info.Size()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(j.path+".tmp", j.path)
+}
+
+// finish makes the changes left in order with the given task manager, stopping at the first
+// failing, then saves the task sync state to the given file and removes the journal. The events of
+// the sync are returned once all the changes are made.
+func (j *taskJournal) finish(tmgr taskmgr.TaskManager, statePath string) ([]LifecycleEvent, error) {
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// The record cut off, if any, is overwritten.
+	if err := f.Truncate(j.size); err != nil {
+		return nil, err
+	}
+	e := json.NewEncoder(f)
+	record := func(r journalRecord) (err error) {
+		if err := e.Encode(r); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		j.size, err = f.Seek(0, io.SeekCurrent)
+		return err
+	}
+	for j.done < len(j.ops) {
+		op := j.ops[j.done]
+		resolved := op.resolved(j.ids)
+		created := ""
+		if j.started {
+			if created, err = resolved.reconciled(tmgr); err != nil {
+				return nil, fmt.Errorf("Sync of the tasks interrupted after %d of %d changes, resume it with reqtraq updatetasks --resume, caused by\n%v", j.done, len(j.ops), err)
+			}
+		}
+		if created == "" {
+			if op.creates() && !j.started {
+				if err := record(journalRecord{Started: j.done + 1}); err != nil {
+					return nil, err
+				}
+				j.started = true
+			}
+			if created, err = resolved.apply(tmgr); err != nil {
+				return nil, fmt.Errorf("Sync of the tasks interrupted after %d of %d changes, resume it with reqtraq updatetasks --resume, caused by\n%v", j.done, len(j.ops), err)
+			}
+		}
+		if op.ID != "" {
+			j.ids[op.ID] = created
+		}
+		j.done++
+		j.started = false
+		if err := record(journalRecord{Done: j.done, Created: created}); err != nil {
+			return nil, err
+		}
+	}
+	j.resolveState()
+	if err := j.state.save(statePath); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return j.events, os.Remove(j.path)
+}
+
+// resolveState replaces the placeholder IDs of the tasks created in the events, and those of the
+// parents hashed in the task sync state, by the IDs created.
+func (j *taskJournal) resolveState() {
+	for i, e := range j.events {
+		if created, ok := j.ids[e.Task]; ok {
+			j.events[i].Task = created
+		}
+	}
+	for _, op := range j.ops {
+		if op.Op != "CreateTask" && (op.Op != "UpdateTask" || op.Fields&taskmgr.TaskParents == 0) {
+			continue
+		}
+		reqID := strings.SplitN(op.Title, ":", 2)[0]
+		if e, ok := j.state.Tasks[reqID]; ok {
+			e.Parents = newTaskSyncEntry("", "", op.resolved(j.ids).ParentTaskIDs).Parents
+			j.state.Tasks[reqID] = e
+		}
+	}
+}
+
+// journalTaskManager plans the changes of a queued sync rather than making them, see taskJournal
+// and taskPlanner.
+type journalTaskManager struct {
+	taskPlanner
+	ops []journalOp
+}
+
+func newJournalTaskManager(tmgr taskmgr.TaskManager) *journalTaskManager {
+	m := &journalTaskManager{}
+	m.taskPlanner = newTaskPlanner(tmgr, "queued", func(id, name, parentID string) {
+		m.ops = append(m.ops, journalOp{Op: "CreateProject", ID: id, Name: name, ParentID: parentID})
+	})
+	return m
+}
+
+// plan adds the change.
+func (m *journalTaskManager) plan(op journalOp) {
+	m.ops = append(m.ops, op)
+}
+
+func (m *journalTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields taskmgr.TaskFields) error {
+	m.plan(journalOp{Op: "UpdateTask", TaskID: taskID, Title: title, Body: taskBody, ProjectID: projectID, Attributes: attributes, ParentTaskIDs: parentTaskIDs, Fields: fields})
+	return nil
+}
+
+func (m *journalTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	m.plan(journalOp{Op: "UpdateTaskTags", TaskID: taskID, AddTagIDs: addTagIDs, RemoveTagIDs: removeTagIDs})
+	return nil
+}
+
+func (m *journalTaskManager) DeleteTask(taskID, title, projectID string) error {
+	m.plan(journalOp{Op: "DeleteTask", TaskID: taskID, Title: title, ProjectID: projectID})
+	return nil
+}
+
+func (m *journalTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	id := m.placeholder(title)
+	m.plan(journalOp{Op: "CreateTask", ID: id, Title: title, Body: taskBody, ProjectID: projectID, Attributes: attributes, ParentTaskIDs: parentTaskIDs})
+	return id, nil
+}
+
+// ResumeTaskSync makes the changes left of the queued sync interrupted, with the calls to the task
+// manager configured, and returns its events.
+func ResumeTaskSync(calls taskmgr.CallConf) ([]LifecycleEvent, error) {
+	tmgr, err := taskmgr.NewSyncTaskManager(taskmgr.TaskMgr, calls)
+	if err != nil {
+		return nil, err
+	}
+	path, err := taskJournalPath()
+	if err != nil {
+		return nil, err
+	}
+	j, err := loadTaskJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, fmt.Errorf("No queued sync of the tasks to resume, %s not found", path)
+	}
+	statePath, err := taskSyncStatePath()
+	if err != nil {
+		return nil, err
+	}
+	return j.finish(tmgr, statePath)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	journalPath := filepath.Join(dir, "taskjournal.jsonl")
+	statePath := filepath.Join(dir, "tasksync.json")
+
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	planner := newJournalTaskManager(tm)
+	taskmgr.TaskMgr = planner
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Position: 1}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", Parents: []*Req{sys}}
+	sys.Children = []*Req{high}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	all := map[string]bool{sys.ID: true, high.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+
+	// The changes are planned, not made.
+	_, events, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Empty(t, tm.tasks)
+	if assert.Len(t, planner.ops, 3) {
+		assert.Equal(t, "queued-1", planner.ops[0].ID)
+		assert.Equal(t, []string{"queued-2"}, planner.ops[2].ParentTaskIDs)
+	}
+	j := &taskJournal{path: journalPath, ops: planner.ops, state: state, events: events, ids: map[string]string{}}
+	assert.NoError(t, j.write())
+
+	// Interrupted at the change failing, the changes made before are recorded.
+	_, err = j.finish(&failingTaskManager{tm, "REQ-0-TEST-SWH-001: High"}, statePath)
+	assert.EqualError(t, err, "Sync of the tasks interrupted after 2 of 3 changes, resume it with reqtraq updatetasks --resume, caused by\nPhabricator unavailable")
+	assert.Len(t, tm.tasks, 2)
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+
+	// Resumed where it stopped.
+	j, err = loadTaskJournal(journalPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, j.done)
+	assert.Equal(t, map[string]string{"queued-1": "1", "queued-2": "2"}, j.ids)
+	events, err = j.finish(tm, statePath)
+	assert.NoError(t, err)
+	assert.Len(t, tm.tasks, 3)
+	assert.Equal(t, []string{"2"}, tm.tasks["3"].DependsOnTaskIDs)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "3", events[1].Task)
+	}
+	j, err = loadTaskJournal(journalPath)
+	assert.NoError(t, err)
+	assert.Nil(t, j)
+
+	// The state saved has the parents created.
+	state, err = loadTaskSyncState(statePath)
+	assert.NoError(t, err)
+	taskmgr.TaskMgr = tm
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Empty(t, tm.updates)
+}
+
+func TestTaskJournal_torn(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	journalPath := filepath.Join(dir, "taskjournal.jsonl")
+	statePath := filepath.Join(dir, "tasksync.json")
+
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	planner := newJournalTaskManager(tm)
+	taskmgr.TaskMgr = planner
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Position: 1}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", Parents: []*Req{sys}}
+	sys.Children = []*Req{high}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	all := map[string]bool{sys.ID: true, high.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	_, events, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	j := &taskJournal{path: journalPath, ops: planner.ops, state: state, events: events, ids: map[string]string{}}
+	assert.NoError(t, j.write())
+	_, err = j.finish(&failingTaskManager{tm, "REQ-0-TEST-SWH-001: High"}, statePath)
+	assert.Error(t, err)
+
+	// Interrupted while recording the second change, the record is cut off.
+	content, err := ioutil.ReadFile(journalPath)
+	assert.NoError(t, err)
+	cut := bytes.LastIndex(content, []byte(`{"done":2`)) + 5
+	assert.NoError(t, ioutil.WriteFile(journalPath, content[:cut], 0644))
+	j, err = loadTaskJournal(journalPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, j.done)
+
+	// Resumed, the task created but not recorded is found rather than created again, the record
+	// cut off overwritten.
+	_, err = j.finish(&failingTaskManager{tm, "REQ-0-TEST-SWH-001: High"}, statePath)
+	assert.EqualError(t, err, "Sync of the tasks interrupted after 2 of 3 changes, resume it with reqtraq updatetasks --resume, caused by\nPhabricator unavailable")
+	assert.Len(t, tm.tasks, 2)
+
+	// Resumed again.
+	j, err = loadTaskJournal(journalPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, j.done)
+	assert.Equal(t, map[string]string{"queued-1": "1", "queued-2": "2"}, j.ids)
+	_, err = j.finish(tm, statePath)
+	assert.NoError(t, err)
+	assert.Len(t, tm.tasks, 3)
+	assert.Equal(t, []string{"2"}, tm.tasks["3"].DependsOnTaskIDs)
+	j, err = loadTaskJournal(journalPath)
+	assert.NoError(t, err)
+	assert.Nil(t, j)
+}
+
+// timingOutTaskManager creates the task with the given title, but fails as if the reply timed out.
+type timingOutTaskManager struct {
+	*fakeTaskManager
+	title string
+}
+
+func (m *timingOutTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	id, err := m.fakeTaskManager.CreateTask(title, taskBody, projectID, attributes, parentTaskIDs)
+	if err == nil && title == m.title {
+		return "", fmt.Errorf("Phabricator request timed out")
+	}
+	return id, err
+}
+
+func TestTaskJournal_created(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reqtraq")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	journalPath := filepath.Join(dir, "taskjournal.jsonl")
+	statePath := filepath.Join(dir, "tasksync.json")
+
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	planner := newJournalTaskManager(tm)
+	taskmgr.TaskMgr = planner
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Position: 1}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", Parents: []*Req{sys}}
+	sys.Children = []*Req{high}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	all := map[string]bool{sys.ID: true, high.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	_, events, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	j := &taskJournal{path: journalPath, ops: planner.ops, state: state, events: events, ids: map[string]string{}}
+	assert.NoError(t, j.write())
+
+	// The task is created, but the sync fails as if it wasn't.
+	_, err = j.finish(&timingOutTaskManager{tm, "REQ-0-TEST-SWH-001: High"}, statePath)
+	assert.Error(t, err)
+	assert.Len(t, tm.tasks, 3)
+
+	// Resumed, the task is found rather than created twice.
+	j, err = loadTaskJournal(journalPath)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, j.done)
+	assert.True(t, j.started)
+	events, err = j.finish(tm, statePath)
+	assert.NoError(t, err)
+	assert.Len(t, tm.tasks, 3)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "3", events[1].Task)
+	}
+}
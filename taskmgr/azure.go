@@ -0,0 +1,396 @@
+// @llr REQ-0-DDLN-SWL-085
+package taskmgr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// AzureConf is the configuration of the Azure DevOps task manager, the "azure" entry in attributes.json. The tasks
+// are the work items of the project, the projects of reqtraq, one per requirement level, being tags of these items.
+type AzureConf struct {
+	// Organization is the URL of the organization, e.g. https://dev.azure.com/daedalean.
+	Organization string `json:"organization"`
+	// Project is the name of the project of the work items.
+	Project string `json:"project"`
+	// AreaPath is the area of the work items, e.g. "Avionics\Reqtraq", the default area of the project if empty.
+	AreaPath string `json:"areaPath"`
+	// WorkItemTypes are the work item types of the tasks by requirement type, e.g. "SWL": "Task", see
+	// defaultAzureWorkItemTypes.
+	WorkItemTypes map[string]string `json:"workItemTypes"`
+	// RemovedState is the state of the work items of the deleted requirements, Removed by default.
+	RemovedState string `json:"removedState"`
+}
+
+// defaultAzureWorkItemTypes are the work item types of the tasks by requirement type if not configured, the system
+// requirements being epics and the high-level ones features. The other tasks are user stories, and the task grouping
+// all the others, whose title has no requirement ID, is an epic.
+var defaultAzureWorkItemTypes = map[string]string{"SYS": "Epic", "SWH": "Feature", "HWH": "Feature"}
+
+const (
+	defaultAzureWorkItemType = "User Story"
+	defaultAzureRemovedState = "Removed"
+	azureAPIVersion          = "7.0"
+	// azureBatchSize is the number of work items read at once, the maximum of the API.
+	azureBatchSize = 200
+	// azureParent and azureChild are the relations of a work item to its parent and to its children.
+	azureParent = "System.LinkTypes.Hierarchy-Reverse"
+	azureChild  = "System.LinkTypes.Hierarchy-Forward"
+)
+
+// azureClosedStates are the states of the closed work items, in addition to the removed state.
+var azureClosedStates = []string{"Closed", "Done", "Resolved"}
+
+// AzureTaskManager is the TaskManager of the work items of an Azure DevOps project, through the REST API, see
+// https://learn.microsoft.com/en-us/rest/api/azure/devops/wit/
+//
+// A work item has a single parent in Azure DevOps, the one of its first parent requirement, unlike in Phabricator.
+type AzureTaskManager struct {
+	Conf AzureConf
+	// Token is the personal access token authenticating the requests, read from the git config if not set.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewAzureTaskManager returns the task manager of the work items of the configured project.
+func NewAzureTaskManager(conf AzureConf) *AzureTaskManager {
+	return &AzureTaskManager{Conf: conf}
+}
+
+type azureRelation struct {
+	Rel string `json:"rel"`
+	URL string `json:"url"`
+}
+
+type azureWorkItem struct {
+	ID        int                    `json:"id"`
+	Fields    map[string]interface{} `json:"fields"`
+	Relations []azureRelation        `json:"relations"`
+	Links     struct {
+		HTML struct {
+			Href string `json:"href"`
+		} `json:"html"`
+	} `json:"_links"`
+}
+
+// field returns the value of the field of the work item, e.g. System.Title, or "" if it's not set.
+func (w *azureWorkItem) field(name string) string {
+	s, _ := w.Fields[name].(string)
+	return s
+}
+
+// related returns the IDs of the work items related to the work item by the given relation.
+func (w *azureWorkItem) related(rel string) []string {
+	var ids []string
+	for _, r := range w.Relations {
+		if r.Rel == rel {
+			ids = append(ids, r.URL[strings.LastIndex(r.URL, "/")+1:])
+		}
+	}
+	return ids
+}
+
+// tags returns the tags of the work item.
+func (w *azureWorkItem) tags() []string {
+	var tags []string
+	for _, t := range strings.Split(w.field("System.Tags"), ";") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// azurePatch is an operation of the JSON patch documents creating and updating the work items.
+type azurePatch struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// getToken reads the token from the git config, unless already set.
+func (tmgr *AzureTaskManager) getToken() error {
+	if tmgr.Token != "" {
+		return nil
+	}
+	token, err := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.azure-token"))
+	if err != nil {
+		return fmt.Errorf(`No Azure DevOps token set. Please create a personal access token with the Work Items (Read & write)
+scope and paste the token into this command
+	git config --local --replace-all daedalean.azure-token <PASTE_TOKEN_HERE>`)
+	}
+	tmgr.Token = token
+	return nil
+}
+
+// do sends the request with the given method to the path of the organization, see restClient. The bodies of the
+// PATCH and POST requests of the work items are JSON patch documents.
+func (tmgr *AzureTaskManager) do(method, path string, in, out interface{}) error {
+	if err := tmgr.getToken(); err != nil {
+		return err
+	}
+	if strings.Contains(path, "?") {
+		path += "&api-version=" + azureAPIVersion
+	} else {
+		path += "?api-version=" + azureAPIVersion
+	}
+	c := restClient{Name: "Azure DevOps", BaseURL: tmgr.Conf.Organization, HTTPClient: tmgr.HTTPClient, Auth: func(req *http.Request) {
+		req.SetBasicAuth("", tmgr.Token)
+	}}
+	if strings.Contains(path, "/_apis/wit/workitems") && method != "GET" {
+		c.ContentType = "application/json-patch+json"
+	}
+	return c.do(method, path, in, out)
+}
+
+// projectPath returns the path of the project.
+func (tmgr *AzureTaskManager) projectPath() string {
+	return "/" + url.PathEscape(tmgr.Conf.Project)
+}
+
+// workItemURL returns the URL of the work item with the given ID, as in its relations.
+func (tmgr *AzureTaskManager) workItemURL(id string) string {
+	return strings.TrimSuffix(tmgr.Conf.Organization, "/") + "/_apis/wit/workItems/" + id
+}
+
+// workItemType returns the work item type of the task with the given title, by the type of its requirement.
+func (tmgr *AzureTaskManager) workItemType(title string) string {
+	types := tmgr.Conf.WorkItemTypes
+	if types == nil {
+		types = defaultAzureWorkItemTypes
+	}
+	m := reTaskReqType.FindStringSubmatch(title)
+	if m == nil {
+		return "Epic"
+	}
+	if t := types[m[1]]; t != "" {
+		return t
+	}
+	return defaultAzureWorkItemType
+}
+
+func (tmgr *AzureTaskManager) removedState() string {
+	if tmgr.Conf.RemovedState == "" {
+		return defaultAzureRemovedState
+	}
+	return tmgr.Conf.RemovedState
+}
+
+// GetProject returns the tag of the project with the given name, which is the name itself, the tag being created
+// with the first work item tagged so.
+func (tmgr *AzureTaskManager) GetProject(name string) (string, error) {
+	return name, nil
+}
+
+// CreateProject returns the tag of the project with the given name, tags having no parents in Azure DevOps.
+func (tmgr *AzureTaskManager) CreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+// GetOrCreateProject returns the tag of the project with the given name.
+func (tmgr *AzureTaskManager) GetOrCreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+func (tmgr *AzureTaskManager) getWorkItem(id string) (*azureWorkItem, error) {
+	var w azureWorkItem
+	if err := tmgr.do("GET", "/_apis/wit/workitems/"+url.PathEscape(id)+"?$expand=all", nil, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// FindTaskByID returns the Azure DevOps work item with the given ID.
+func (tmgr *AzureTaskManager) FindTaskByID(id string) (*Task, error) {
+	w, err := tmgr.getWorkItem(id)
+	if err != nil {
+		return nil, err
+	}
+	return tmgr.azureWorkItemToTask(w), nil
+}
+
+// wiqlString quotes the string for WIQL.
+func wiqlString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// searchTasks returns the work items of the project with the given tag whose title contains the given text.
+func (tmgr *AzureTaskManager) searchTasks(text, tag string) ([]*Task, error) {
+	query := map[string]string{"query": fmt.Sprintf("SELECT [System.Id] FROM WorkItems WHERE [System.TeamProject] = @project "+
+		"AND [System.Tags] CONTAINS %s AND [System.Title] CONTAINS %s", wiqlString(tag), wiqlString(text))}
+	var res struct {
+		WorkItems []struct {
+			ID int `json:"id"`
+		} `json:"workItems"`
+	}
+	if err := tmgr.do("POST", tmgr.projectPath()+"/_apis/wit/wiql", query, &res); err != nil {
+		return nil, err
+	}
+	var tasks []*Task
+	for start := 0; start < len(res.WorkItems); start += azureBatchSize {
+		var ids []string
+		for i := start; i < len(res.WorkItems) && i < start+azureBatchSize; i++ {
+			ids = append(ids, strconv.Itoa(res.WorkItems[i].ID))
+		}
+		var batch struct {
+			Value []*azureWorkItem `json:"value"`
+		}
+		if err := tmgr.do("GET", "/_apis/wit/workitems?ids="+strings.Join(ids, ",")+"&$expand=all", nil, &batch); err != nil {
+			return nil, err
+		}
+		for _, w := range batch.Value {
+			tasks = append(tasks, tmgr.azureWorkItemToTask(w))
+		}
+	}
+	return tasks, nil
+}
+
+// FindTaskByTitle returns the Azure DevOps work item with the given title, nil if it is not found, or an error if
+// there was an error finding it. In case there are multiple work items with the given title, FindTaskByTitle returns
+// an error.
+func (tmgr *AzureTaskManager) FindTaskByTitle(taskTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(taskTitle, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return taskWithTitle(tasks, taskTitle)
+}
+
+// FindTask returns the Azure DevOps work item corresponding to the given Requirement ID, nil if it was not found or
+// an error if there was an error finding it. In case there are multiple work items with the given ID in the title,
+// FindTask deterministically selects the one with the title that matches as closely as possible the given title.
+func (tmgr *AzureTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(requirementID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return closestTask(tasks, requirementID, requirementTitle), nil
+}
+
+// azureField returns the operation setting the field of a work item.
+func azureField(name string, value interface{}) azurePatch {
+	return azurePatch{Op: "add", Path: "/fields/" + name, Value: value}
+}
+
+// parentRelation returns the operation adding the relation of a work item to the work item of its first parent.
+func (tmgr *AzureTaskManager) parentRelation(parentTaskIDs []string) azurePatch {
+	return azurePatch{Op: "add", Path: "/relations/-", Value: azureRelation{Rel: azureParent, URL: tmgr.workItemURL(parentTaskIDs[0])}}
+}
+
+// azureTagsField returns the operation setting the tags of a work item to its tags with the given ones added and removed.
+func azureTagsField(w *azureWorkItem, add, remove []string) azurePatch {
+	var tags []string
+	for _, t := range w.tags() {
+		if !contains(remove, t) && !contains(add, t) {
+			tags = append(tags, t)
+		}
+	}
+	return azureField("System.Tags", strings.Join(append(tags, add...), "; "))
+}
+
+// UpdateTask updates the given fields of the Azure DevOps work item with the given ID with the data from the given
+// parameters. The attributes are left to the tags.
+func (tmgr *AzureTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
+	w, err := tmgr.getWorkItem(taskID)
+	if err != nil {
+		return err
+	}
+	patch := []azurePatch{azureTagsField(w, []string{projectID}, nil)}
+	if fields&TaskTitle != 0 {
+		patch = append(patch, azureField("System.Title", title))
+	}
+	if fields&TaskDescription != 0 {
+		patch = append(patch, azureField("System.Description", taskBody))
+	}
+	if fields&TaskParents != 0 {
+		parents := w.related(azureParent)
+		if len(parentTaskIDs) == 0 || len(parents) != 1 || parents[0] != parentTaskIDs[0] {
+			// Removed from the last, for the indexes of the others to remain valid.
+			for i := len(w.Relations) - 1; i >= 0; i-- {
+				if w.Relations[i].Rel == azureParent {
+					patch = append(patch, azurePatch{Op: "remove", Path: fmt.Sprintf("/relations/%d", i)})
+				}
+			}
+			if len(parentTaskIDs) > 0 {
+				patch = append(patch, tmgr.parentRelation(parentTaskIDs))
+			}
+		}
+	}
+	return tmgr.do("PATCH", "/_apis/wit/workitems/"+url.PathEscape(taskID), patch, nil)
+}
+
+// UpdateTaskTags adds the tags to the Azure DevOps work item with the given ID, and removes others.
+func (tmgr *AzureTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	if len(addTagIDs) == 0 && len(removeTagIDs) == 0 {
+		return nil
+	}
+	w, err := tmgr.getWorkItem(taskID)
+	if err != nil {
+		return err
+	}
+	patch := []azurePatch{azureTagsField(w, addTagIDs, removeTagIDs)}
+	return tmgr.do("PATCH", "/_apis/wit/workitems/"+url.PathEscape(taskID), patch, nil)
+}
+
+// DeleteTask sets the Azure DevOps work item with the given ID to the removed state.
+func (tmgr *AzureTaskManager) DeleteTask(taskID, title, projectID string) error {
+	w, err := tmgr.getWorkItem(taskID)
+	if err != nil {
+		return err
+	}
+	patch := []azurePatch{
+		azureField("System.Title", title),
+		azureTagsField(w, []string{projectID}, nil),
+		azureField("System.State", tmgr.removedState()),
+	}
+	return tmgr.do("PATCH", "/_apis/wit/workitems/"+url.PathEscape(taskID), patch, nil)
+}
+
+// CreateTask creates a new Azure DevOps work item with the given parameters, of the work item type of the
+// requirement, in the configured area, and returns its ID. The attributes are left to the tags.
+func (tmgr *AzureTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	patch := []azurePatch{
+		azureField("System.Title", title),
+		azureField("System.Description", taskBody),
+		azureField("System.Tags", projectID),
+	}
+	if tmgr.Conf.AreaPath != "" {
+		patch = append(patch, azureField("System.AreaPath", tmgr.Conf.AreaPath))
+	}
+	if len(parentTaskIDs) > 0 {
+		patch = append(patch, tmgr.parentRelation(parentTaskIDs))
+	}
+	var w azureWorkItem
+	path := tmgr.projectPath() + "/_apis/wit/workitems/" + url.PathEscape("$"+tmgr.workItemType(title))
+	if err := tmgr.do("POST", path, patch, &w); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(w.ID), nil
+}
+
+// azureWorkItemToTask returns the task of the work item, the tasks it depends on being its children. The work items
+// in the removed state have the status "invalid", as in Phabricator.
+func (tmgr *AzureTaskManager) azureWorkItemToTask(w *azureWorkItem) *Task {
+	task := &Task{
+		ID:               strconv.Itoa(w.ID),
+		DisplayID:        strconv.Itoa(w.ID),
+		Title:            w.field("System.Title"),
+		Description:      w.field("System.Description"),
+		Status:           w.field("System.State"),
+		URI:              w.Links.HTML.Href,
+		DependsOnTaskIDs: w.related(azureChild),
+	}
+	task.IsClosed = task.Status == tmgr.removedState() || contains(azureClosedStates, task.Status)
+	if task.Status == tmgr.removedState() {
+		task.Status = "invalid"
+	}
+	if p, ok := w.Fields["Microsoft.VSTS.Common.Priority"].(float64); ok {
+		task.Priority = strconv.Itoa(int(p))
+	}
+	return task
+}
@@ -0,0 +1,207 @@
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeAzure is an Azure DevOps organization keeping the work items of the project Avionics in memory.
+type fakeAzure struct {
+	url   string
+	items []*azureWorkItem
+	// types are the work item types of the work items.
+	types []string
+}
+
+var reFakeWiql = regexp.MustCompile(`\[System.Tags\] CONTAINS '(.*)' AND \[System.Title\] CONTAINS '(.*)'$`)
+
+func (a *fakeAzure) patch(w *azureWorkItem, patch []azurePatch) {
+	for _, p := range patch {
+		switch {
+		case p.Op == "add" && strings.HasPrefix(p.Path, "/fields/"):
+			w.Fields[strings.TrimPrefix(p.Path, "/fields/")] = p.Value
+		case p.Op == "add" && p.Path == "/relations/-":
+			rel := p.Value.(map[string]interface{})
+			w.Relations = append(w.Relations, azureRelation{rel["rel"].(string), rel["url"].(string)})
+			// The reverse relation of the parent.
+			parent := a.items[a.index(rel["url"].(string))]
+			parent.Relations = append(parent.Relations, azureRelation{azureChild, fmt.Sprintf("%s/_apis/wit/workItems/%d", a.url, w.ID)})
+		case p.Op == "remove":
+			i, _ := strconv.Atoi(strings.TrimPrefix(p.Path, "/relations/"))
+			parent := a.items[a.index(w.Relations[i].URL)]
+			var children []azureRelation
+			for _, r := range parent.Relations {
+				if r.URL != fmt.Sprintf("%s/_apis/wit/workItems/%d", a.url, w.ID) {
+					children = append(children, r)
+				}
+			}
+			parent.Relations = children
+			w.Relations = append(w.Relations[:i], w.Relations[i+1:]...)
+		}
+	}
+}
+
+// index returns the index of the work item with the ID at the end of the URL.
+func (a *fakeAzure) index(url string) int {
+	id, _ := strconv.Atoi(url[strings.LastIndex(url, "/")+1:])
+	return id - 1
+}
+
+func (a *fakeAzure) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, token, ok := r.BasicAuth(); !ok || token != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.FormValue("api-version") != azureAPIVersion {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	patching := r.Method != "GET" && strings.Contains(r.URL.Path, "/_apis/wit/workitems")
+	if patching != (r.Header.Get("Content-Type") == "application/json-patch+json") {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	var patch []azurePatch
+	if patching {
+		json.NewDecoder(r.Body).Decode(&patch)
+	}
+	path := r.URL.EscapedPath()
+	switch {
+	case r.Method == "POST" && path == "/Avionics/_apis/wit/wiql":
+		var query map[string]string
+		json.NewDecoder(r.Body).Decode(&query)
+		m := reFakeWiql.FindStringSubmatch(query["query"])
+		var res struct {
+			WorkItems []map[string]int `json:"workItems"`
+		}
+		for _, i := range a.items {
+			if m != nil && strings.Contains(i.field("System.Title"), m[2]) && contains(i.tags(), m[1]) {
+				res.WorkItems = append(res.WorkItems, map[string]int{"id": i.ID})
+			}
+		}
+		json.NewEncoder(w).Encode(res)
+	case r.Method == "GET" && path == "/_apis/wit/workitems":
+		var res struct {
+			Value []*azureWorkItem `json:"value"`
+		}
+		for _, id := range strings.Split(r.FormValue("ids"), ",") {
+			res.Value = append(res.Value, a.items[a.index(id)])
+		}
+		json.NewEncoder(w).Encode(res)
+	case r.Method == "POST" && strings.HasPrefix(path, "/Avionics/_apis/wit/workitems/$"):
+		item := &azureWorkItem{ID: len(a.items) + 1, Fields: map[string]interface{}{"System.State": "New"}}
+		item.Links.HTML.Href = fmt.Sprintf("%s/Avionics/_workitems/edit/%d", a.url, item.ID)
+		a.items = append(a.items, item)
+		t, _ := url.PathUnescape(strings.TrimPrefix(path, "/Avionics/_apis/wit/workitems/$"))
+		a.types = append(a.types, t)
+		a.patch(item, patch)
+		json.NewEncoder(w).Encode(item)
+	case strings.HasPrefix(path, "/_apis/wit/workitems/"):
+		i := a.index(path)
+		if i < 0 || i >= len(a.items) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "PATCH" {
+			a.patch(a.items[i], patch)
+		}
+		json.NewEncoder(w).Encode(a.items[i])
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestAzureTaskManager(t *testing.T) {
+	azure := &fakeAzure{}
+	server := httptest.NewServer(azure)
+	defer server.Close()
+	azure.url = server.URL
+
+	tmgr := NewAzureTaskManager(AzureConf{Organization: server.URL, Project: "Avionics", AreaPath: `Avionics\Reqtraq`})
+	tmgr.Token = "secret"
+
+	all, err := tmgr.CreateTask("Implement DDLN", "Meta-task", "DDLN-SYS", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epic, err := tmgr.CreateTask("REQ-0-DDLN-SYS-001: System", "System body", "DDLN-SYS", nil, []string{all})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feature, err := tmgr.CreateTask("REQ-0-DDLN-SWH-001: High", "High body", "DDLN-HLR", nil, []string{epic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tmgr.CreateTask("REQ-0-DDLN-SWH-002: Other", "Other body", "DDLN-HLR", nil, []string{epic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	story, err := tmgr.CreateTask("REQ-0-DDLN-SWL-001: Low", "Low body", "DDLN", nil, []string{feature, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(azure.types, []string{"Epic", "Epic", "Feature", "Feature", "User Story"}) {
+		t.Errorf("unexpected work item types %v", azure.types)
+	}
+	if area := azure.items[4].Fields["System.AreaPath"]; area != `Avionics\Reqtraq` {
+		t.Errorf("unexpected area path %v", area)
+	}
+
+	task, err := tmgr.FindTask("REQ-0-DDLN-SWL-001", "Low", "DDLN")
+	if err != nil || task == nil || task.ID != story || task.URI != server.URL+"/Avionics/_workitems/edit/5" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTask("REQ-0-DDLN-SWL-001", "Low", "DDLN-HLR"); err != nil || task != nil {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByTitle("Implement DDLN", "DDLN-SYS"); err != nil || task == nil || task.ID != all {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	// A work item has a single parent.
+	if task, err = tmgr.FindTaskByID(feature); err != nil || !reflect.DeepEqual(task.DependsOnTaskIDs, []string{story}) {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+
+	// The parent is replaced.
+	if err := tmgr.UpdateTask(story, "REQ-0-DDLN-SWL-001: Renamed", "Edited body", "DDLN", nil, []string{other}, TaskTitle|TaskParents); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = tmgr.FindTaskByID(story); err != nil || task.Title != "REQ-0-DDLN-SWL-001: Renamed" || task.Description != "Low body" {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, _ = tmgr.FindTaskByID(feature); len(task.DependsOnTaskIDs) != 0 {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+	if task, _ = tmgr.FindTaskByID(other); !reflect.DeepEqual(task.DependsOnTaskIDs, []string{story}) {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+
+	if err := tmgr.UpdateTaskTags(story, []string{"Safety critical"}, []string{"DDLN"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags := azure.items[4].Fields["System.Tags"]; tags != "Safety critical" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	if err := tmgr.DeleteTask(story, "REQ-0-DDLN-SWL-001: Deleted", "DDLN"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = tmgr.FindTaskByID(story); task.Status != "invalid" || !task.IsClosed || task.Title != "REQ-0-DDLN-SWL-001: Deleted" {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if tags := azure.items[4].Fields["System.Tags"]; tags != "Safety critical; DDLN" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	tmgr.Token = "wrong"
+	if _, err := tmgr.FindTaskByID(story); err == nil || err.Error() != fmt.Sprintf("Azure DevOps request GET %s/_apis/wit/workitems/5?$expand=all&api-version=7.0 failed: 401 Unauthorized", server.URL) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+// @llr REQ-0-DDLN-SWL-083
+package taskmgr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// GitHubConf is the configuration of the GitHub Issues task manager, the "github" entry in attributes.json. The tasks
+// are the issues of the repository, the projects of reqtraq, one per requirement level, being labels of these issues.
+type GitHubConf struct {
+	// Repository is that of the issues, e.g. daedaleanai/reqtraq.
+	Repository string `json:"repository"`
+	// URL is that of the API, https://api.github.com by default, e.g. https://github.example.com/api/v3 for GitHub
+	// Enterprise.
+	URL string `json:"url"`
+}
+
+const defaultGitHubURL = "https://api.github.com"
+
+// GitHubTaskManager is the TaskManager of the issues of a GitHub repository, through the REST API, see
+// https://docs.github.com/en/rest/issues
+//
+// The parents of a task can't be linked to in GitHub, so the body of the issue of each parent ends with a task list
+// of the issues of its children, which GitHub shows as the tracked issues. The body of the issue of each child
+// records its parents in a comment before the task list, see githubBody.
+type GitHubTaskManager struct {
+	Conf GitHubConf
+	// Token authenticates the requests, read from the git config if not set.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewGitHubTaskManager returns the task manager of the issues of the configured repository.
+func NewGitHubTaskManager(conf GitHubConf) *GitHubTaskManager {
+	return &GitHubTaskManager{Conf: conf}
+}
+
+type githubIssue struct {
+	Number      int    `json:"number"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	State       string `json:"state"`
+	StateReason string `json:"state_reason"`
+	HTMLURL     string `json:"html_url"`
+	Labels      []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+type githubSearchResponse struct {
+	TotalCount int            `json:"total_count"`
+	Items      []*githubIssue `json:"items"`
+}
+
+// githubPageSize is the number of issues searched at once, the maximum of the API.
+const githubPageSize = 100
+
+// getToken reads the token from the git config, unless already set.
+func (tmgr *GitHubTaskManager) getToken() error {
+	if tmgr.Token != "" {
+		return nil
+	}
+	token, err := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.github-token"))
+	if err != nil {
+		return fmt.Errorf(`No GitHub token set. Please create a personal access token with access to the issues of %s
+and paste the token into this command
+	git config --local --replace-all daedalean.github-token <PASTE_TOKEN_HERE>`, tmgr.Conf.Repository)
+	}
+	tmgr.Token = token
+	return nil
+}
+
+// do sends the request with the given method to the path of the GitHub API, see restClient.
+func (tmgr *GitHubTaskManager) do(method, path string, in, out interface{}) error {
+	if err := tmgr.getToken(); err != nil {
+		return err
+	}
+	baseURL := tmgr.Conf.URL
+	if baseURL == "" {
+		baseURL = defaultGitHubURL
+	}
+	c := restClient{Name: "GitHub", BaseURL: baseURL, HTTPClient: tmgr.HTTPClient, Auth: func(req *http.Request) {
+		req.Header.Set("Authorization", "token "+tmgr.Token)
+	}}
+	return c.do(method, path, in, out)
+}
+
+// issuePath returns the path of the API of the issue with the given number, or of the issues if it's empty.
+func (tmgr *GitHubTaskManager) issuePath(number string) string {
+	if number == "" {
+		return "/repos/" + tmgr.Conf.Repository + "/issues"
+	}
+	return "/repos/" + tmgr.Conf.Repository + "/issues/" + url.PathEscape(number)
+}
+
+// reGitHubParents matches the comment listing the parents in the body of an issue, and the task list of the
+// children after it.
+var reGitHubParents = regexp.MustCompile(`(?s)(?:\n\n)?<!-- reqtraq parents:([\d ]*)-->\n?(.*)\z`)
+
+// reGitHubChild matches an item of the task list of the children, e.g. "- [ ] #12".
+var reGitHubChild = regexp.MustCompile(`^- \[[ xX]\] #(\d+)$`)
+
+// githubBody is the body of an issue, made of the description of the task, followed by the numbers of the issues of
+// its parents in a comment and by a task list of the issues of its children, e.g.
+//
+//	Description
+//
+//	<!-- reqtraq parents: 1 2 -->
+//	- [ ] #12
+//	- [x] #13
+type githubBody struct {
+	Description string
+	Parents     []string
+	// Children are the items of the task list of the children, checked or not.
+	Children []string
+}
+
+// parseGitHubBody returns the description, the parents and the children in the body of an issue.
+func parseGitHubBody(body string) githubBody {
+	body = strings.Replace(body, "\r\n", "\n", -1)
+	m := reGitHubParents.FindStringSubmatchIndex(body)
+	if m == nil {
+		return githubBody{Description: body}
+	}
+	b := githubBody{Description: body[:m[0]], Parents: strings.Fields(body[m[2]:m[3]])}
+	for _, line := range strings.Split(body[m[4]:m[5]], "\n") {
+		if reGitHubChild.MatchString(line) {
+			b.Children = append(b.Children, line)
+		}
+	}
+	return b
+}
+
+// String returns the body of the issue.
+func (b githubBody) String() string {
+	s := b.Description + "\n\n<!-- reqtraq parents:"
+	for _, p := range b.Parents {
+		s += " " + p
+	}
+	s += " -->"
+	for _, c := range b.Children {
+		s += "\n" + c
+	}
+	return s
+}
+
+// childIDs returns the numbers of the issues of the children.
+func (b githubBody) childIDs() []string {
+	var ids []string
+	for _, c := range b.Children {
+		ids = append(ids, reGitHubChild.FindStringSubmatch(c)[1])
+	}
+	return ids
+}
+
+// GetProject returns the label of the project with the given name, which is the name itself, the label being
+// created with the first issue labelled so.
+func (tmgr *GitHubTaskManager) GetProject(name string) (string, error) {
+	return name, nil
+}
+
+// CreateProject returns the label of the project with the given name, projects having no parents in GitHub.
+func (tmgr *GitHubTaskManager) CreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+// GetOrCreateProject returns the label of the project with the given name.
+func (tmgr *GitHubTaskManager) GetOrCreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+func (tmgr *GitHubTaskManager) getIssue(number string) (*githubIssue, error) {
+	var issue githubIssue
+	if err := tmgr.do("GET", tmgr.issuePath(number), nil, &issue); err != nil {
+		return nil, err
+	}
+	return &issue, nil
+}
+
+// FindTaskByID returns the GitHub issue with the given number.
+func (tmgr *GitHubTaskManager) FindTaskByID(number string) (*Task, error) {
+	issue, err := tmgr.getIssue(number)
+	if err != nil {
+		return nil, err
+	}
+	return githubIssueToTask(issue), nil
+}
+
+// searchTasks returns the issues of the repository with the given label whose title contains the given text.
+func (tmgr *GitHubTaskManager) searchTasks(text, label string) ([]*Task, error) {
+	query := fmt.Sprintf("repo:%s is:issue label:%q in:title %q", tmgr.Conf.Repository, label, text)
+	var tasks []*Task
+	for page := 1; ; page++ {
+		q := url.Values{}
+		q.Set("q", query)
+		q.Set("page", fmt.Sprint(page))
+		q.Set("per_page", fmt.Sprint(githubPageSize))
+		var res githubSearchResponse
+		if err := tmgr.do("GET", "/search/issues?"+q.Encode(), nil, &res); err != nil {
+			return nil, err
+		}
+		for _, issue := range res.Items {
+			tasks = append(tasks, githubIssueToTask(issue))
+		}
+		if len(res.Items) == 0 || len(tasks) >= res.TotalCount {
+			return tasks, nil
+		}
+	}
+}
+
+// FindTaskByTitle returns the GitHub issue with the given title, nil if the issue is not found, or an error if there
+// was an error finding the issue. In case there are multiple issues with the given title, FindTaskByTitle returns
+// an error.
+func (tmgr *GitHubTaskManager) FindTaskByTitle(taskTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(taskTitle, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return taskWithTitle(tasks, taskTitle)
+}
+
+// FindTask returns the GitHub issue corresponding to the given Requirement ID, nil if the issue was not found or an
+// error if there was an error finding the issue. In case there are multiple issues with the given ID in the title,
+// FindTask deterministically selects the issue with the title that matches as closely as possible the given title.
+// Note: the issues created are found by the search of GitHub after a delay, usually a minute.
+func (tmgr *GitHubTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(requirementID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return closestTask(tasks, requirementID, requirementTitle), nil
+}
+
+// addLabels adds the labels to the GitHub issue with the given number.
+func (tmgr *GitHubTaskManager) addLabels(number string, labels []string) error {
+	return tmgr.do("POST", tmgr.issuePath(number)+"/labels", map[string][]string{"labels": labels}, nil)
+}
+
+// UpdateTask updates the given fields of the GitHub issue with the given number with the data from the given
+// parameters. The issue is removed from the task lists of its former parents and added to those of the new ones.
+// The attributes are left to the tags.
+func (tmgr *GitHubTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
+	issue, err := tmgr.getIssue(taskID)
+	if err != nil {
+		return err
+	}
+	b := parseGitHubBody(issue.Body)
+	edit := map[string]string{}
+	if fields&TaskTitle != 0 {
+		edit["title"] = title
+	}
+	if fields&TaskDescription != 0 {
+		b.Description = taskBody
+	}
+	if fields&TaskParents != 0 {
+		for _, p := range b.Parents {
+			if !contains(parentTaskIDs, p) {
+				if err := tmgr.setChild(p, taskID, false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, p := range parentTaskIDs {
+			if !contains(b.Parents, p) {
+				if err := tmgr.setChild(p, taskID, true); err != nil {
+					return err
+				}
+			}
+		}
+		b.Parents = parentTaskIDs
+	}
+	edit["body"] = b.String()
+	if err := tmgr.do("PATCH", tmgr.issuePath(taskID), edit, nil); err != nil {
+		return err
+	}
+	return tmgr.addLabels(taskID, []string{projectID})
+}
+
+// setChild adds the issue of the child to the task list of the issue of the parent, or removes it.
+func (tmgr *GitHubTaskManager) setChild(parentID, childID string, add bool) error {
+	issue, err := tmgr.getIssue(parentID)
+	if err != nil {
+		return err
+	}
+	b := parseGitHubBody(issue.Body)
+	var children []string
+	for _, c := range b.Children {
+		if reGitHubChild.FindStringSubmatch(c)[1] != childID {
+			children = append(children, c)
+		}
+	}
+	if add {
+		children = append(children, "- [ ] #"+childID)
+	}
+	b.Children = children
+	return tmgr.do("PATCH", tmgr.issuePath(parentID), map[string]string{"body": b.String()}, nil)
+}
+
+// UpdateTaskTags adds the labels to the GitHub issue with the given number, and removes the others it has.
+func (tmgr *GitHubTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	if len(addTagIDs) > 0 {
+		if err := tmgr.addLabels(taskID, addTagIDs); err != nil {
+			return err
+		}
+	}
+	if len(removeTagIDs) == 0 {
+		return nil
+	}
+	// Removing a label the issue doesn't have fails.
+	issue, err := tmgr.getIssue(taskID)
+	if err != nil {
+		return err
+	}
+	for _, l := range issue.Labels {
+		if contains(removeTagIDs, l.Name) {
+			if err := tmgr.do("DELETE", tmgr.issuePath(taskID)+"/labels/"+url.PathEscape(l.Name), nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteTask closes the GitHub issue with the given number as not planned.
+func (tmgr *GitHubTaskManager) DeleteTask(taskID, title, projectID string) error {
+	edit := map[string]string{"title": title, "state": "closed", "state_reason": "not_planned"}
+	if err := tmgr.do("PATCH", tmgr.issuePath(taskID), edit, nil); err != nil {
+		return err
+	}
+	return tmgr.addLabels(taskID, []string{projectID})
+}
+
+// CreateTask creates a new GitHub issue with the given parameters, adds it to the task lists of its parents and
+// returns its number. The attributes are left to the tags.
+func (tmgr *GitHubTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	create := map[string]interface{}{
+		"title":  title,
+		"body":   githubBody{Description: taskBody, Parents: parentTaskIDs}.String(),
+		"labels": []string{projectID},
+	}
+	var issue githubIssue
+	if err := tmgr.do("POST", tmgr.issuePath(""), create, &issue); err != nil {
+		return "", err
+	}
+	number := strconv.Itoa(issue.Number)
+	for _, p := range parentTaskIDs {
+		if err := tmgr.setChild(p, number, true); err != nil {
+			return "", err
+		}
+	}
+	return number, nil
+}
+
+// githubIssueToTask returns the task of the issue, the tasks it depends on being the children in its task list. The
+// issues closed as not planned have the status "invalid", as in Phabricator.
+func githubIssueToTask(issue *githubIssue) *Task {
+	b := parseGitHubBody(issue.Body)
+	task := &Task{
+		ID:               strconv.Itoa(issue.Number),
+		DisplayID:        strconv.Itoa(issue.Number),
+		Title:            issue.Title,
+		Description:      b.Description,
+		IsClosed:         issue.State == "closed",
+		Status:           issue.State,
+		URI:              issue.HTMLURL,
+		DependsOnTaskIDs: b.childIDs(),
+	}
+	if issue.StateReason == "not_planned" {
+		task.Status = "invalid"
+	}
+	return task
+}
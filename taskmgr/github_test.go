@@ -0,0 +1,181 @@
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeGitHub is a GitHub server keeping the issues of the repository daedaleanai/reqtraq in memory.
+type fakeGitHub struct {
+	issues []*githubIssue
+}
+
+func (g *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "token secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	path := strings.TrimPrefix(r.URL.Path, "/repos/daedaleanai/reqtraq/issues")
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	var issue *githubIssue
+	if n, err := strconv.Atoi(parts[0]); err == nil && n > 0 && n <= len(g.issues) {
+		issue = g.issues[n-1]
+	}
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/search/issues":
+		var res githubSearchResponse
+		for _, i := range g.issues {
+			for _, l := range i.Labels {
+				if strings.Contains(r.FormValue("q"), fmt.Sprintf("label:%q", l.Name)) {
+					res.Items = append(res.Items, i)
+					break
+				}
+			}
+		}
+		res.TotalCount = len(res.Items)
+		json.NewEncoder(w).Encode(res)
+	case r.Method == "POST" && path == "":
+		issue := &githubIssue{Number: len(g.issues) + 1, Title: body["title"].(string), Body: body["body"].(string), State: "open"}
+		issue.HTMLURL = fmt.Sprintf("https://github.com/daedaleanai/reqtraq/issues/%d", issue.Number)
+		g.issues = append(g.issues, issue)
+		g.addLabels(issue, body["labels"])
+		json.NewEncoder(w).Encode(issue)
+	case issue == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "GET" && len(parts) == 1:
+		json.NewEncoder(w).Encode(issue)
+	case r.Method == "PATCH" && len(parts) == 1:
+		for k, v := range body {
+			switch k {
+			case "title":
+				issue.Title = v.(string)
+			case "body":
+				issue.Body = v.(string)
+			case "state":
+				issue.State = v.(string)
+			case "state_reason":
+				issue.StateReason = v.(string)
+			}
+		}
+		json.NewEncoder(w).Encode(issue)
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "labels":
+		g.addLabels(issue, body["labels"])
+		fmt.Fprint(w, "[]")
+	case r.Method == "DELETE" && len(parts) == 3 && parts[1] == "labels":
+		labels := issue.Labels[:0]
+		for _, l := range issue.Labels {
+			if l.Name != parts[2] {
+				labels = append(labels, l)
+			}
+		}
+		issue.Labels = labels
+		fmt.Fprint(w, "[]")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (g *fakeGitHub) addLabels(issue *githubIssue, labels interface{}) {
+	for _, l := range labels.([]interface{}) {
+		issue.Labels = append(issue.Labels, struct {
+			Name string `json:"name"`
+		}{l.(string)})
+	}
+}
+
+func TestGitHubTaskManager(t *testing.T) {
+	github := &fakeGitHub{}
+	server := httptest.NewServer(github)
+	defer server.Close()
+
+	tmgr := NewGitHubTaskManager(GitHubConf{Repository: "daedaleanai/reqtraq", URL: server.URL})
+	tmgr.Token = "secret"
+
+	sys, err := tmgr.GetOrCreateProject("DDLN-SYS", "")
+	if err != nil || sys != "DDLN-SYS" {
+		t.Fatalf("unexpected project %q, %v", sys, err)
+	}
+	parent, err := tmgr.CreateTask("REQ-0-DDLN-SYS-001: System", "System body", sys, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := tmgr.CreateTask("REQ-0-DDLN-SWH-001: High", "High body", "DDLN-HLR", nil, []string{parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if github.issues[0].Body != "System body\n\n<!-- reqtraq parents: -->\n- [ ] #2" {
+		t.Errorf("unexpected body %q", github.issues[0].Body)
+	}
+	if github.issues[1].Body != "High body\n\n<!-- reqtraq parents: 1 -->" {
+		t.Errorf("unexpected body %q", github.issues[1].Body)
+	}
+
+	task, err := tmgr.FindTask("REQ-0-DDLN-SWH-001", "High", "DDLN-HLR")
+	if err != nil || task == nil || task.ID != child || task.Description != "High body" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, err := tmgr.FindTask("REQ-0-DDLN-SWH-001", "High", sys); err != nil || task != nil {
+		t.Errorf("unexpected task %v, %v", task, err)
+	}
+	// The checked items of the task lists are kept, and the edits are read as made on the web.
+	github.issues[0].Body = strings.Replace(strings.Replace(github.issues[0].Body, "[ ]", "[x]", 1), "\n", "\r\n", -1)
+	task, err = tmgr.FindTaskByID(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Description != "System body" || !reflect.DeepEqual(task.DependsOnTaskIDs, []string{child}) || task.URI != "https://github.com/daedaleanai/reqtraq/issues/1" {
+		t.Errorf("unexpected task %+v", task)
+	}
+
+	// The issue is moved to the task list of its new parent.
+	other, err := tmgr.CreateTask("REQ-0-DDLN-SYS-002: Other", "Other body", sys, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := tmgr.CreateTask("REQ-0-DDLN-SWH-002: Third", "Third body", "DDLN-HLR", nil, []string{parent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmgr.UpdateTask(child, "REQ-0-DDLN-SWH-001: Renamed", "Edited body", "DDLN-HLR", nil, []string{other}, TaskTitle|TaskParents); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = tmgr.FindTaskByTitle("REQ-0-DDLN-SWH-001: Renamed", "DDLN-HLR"); err != nil || task == nil || task.Description != "High body" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if github.issues[0].Body != "System body\n\n<!-- reqtraq parents: -->\n- [ ] #"+third {
+		t.Errorf("unexpected body %q", github.issues[0].Body)
+	}
+	if task, _ = tmgr.FindTaskByID(other); !reflect.DeepEqual(task.DependsOnTaskIDs, []string{child}) {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+
+	if err := tmgr.UpdateTaskTags(child, []string{"Safety critical"}, []string{"MODE: Manual", "DDLN-HLR"}); err != nil {
+		t.Fatal(err)
+	}
+	var labels []string
+	for _, l := range github.issues[1].Labels {
+		labels = append(labels, l.Name)
+	}
+	if !reflect.DeepEqual(labels, []string{"Safety critical"}) {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if err := tmgr.DeleteTask(child, "REQ-0-DDLN-SWH-001: Deleted", "DDLN-HLR"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = tmgr.FindTaskByID(child); task.Status != "invalid" || !task.IsClosed || task.Title != "REQ-0-DDLN-SWH-001: Deleted" {
+		t.Errorf("unexpected task %+v", task)
+	}
+
+	tmgr.Token = "wrong"
+	if _, err := tmgr.FindTaskByID(child); err == nil || err.Error() != fmt.Sprintf("GitHub request GET %s/repos/daedaleanai/reqtraq/issues/2 failed: 401 Unauthorized", server.URL) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
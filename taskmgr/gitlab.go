@@ -0,0 +1,420 @@
+// @llr REQ-0-DDLN-SWL-084
+package taskmgr
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// GitLabConf is the configuration of the GitLab task manager, the "gitlab" entry in attributes.json. The tasks are
+// epics of the group and issues of the project, the projects of reqtraq, one per requirement level, being labels.
+type GitLabConf struct {
+	// URL is that of the GitLab server, https://gitlab.com by default.
+	URL string `json:"url"`
+	// Project is the path or the ID of the project of the issues, e.g. daedalean/reqtraq.
+	Project string `json:"project"`
+	// Group is the path or the ID of the group of the epics, e.g. daedalean. Without it all the tasks are issues.
+	Group string `json:"group"`
+	// EpicTypes are the requirement types of the tasks which are epics, SYS by default.
+	EpicTypes []string `json:"epicTypes"`
+	// InvalidLabel is the label of the tasks of the deleted requirements, which are closed, Invalid by default.
+	InvalidLabel string `json:"invalidLabel"`
+}
+
+const (
+	defaultGitLabURL          = "https://gitlab.com"
+	defaultGitLabInvalidLabel = "Invalid"
+	// gitlabPageSize is the number of epics or issues listed at once, the maximum of the API.
+	gitlabPageSize = 100
+)
+
+var defaultGitLabEpicTypes = []string{"SYS"}
+
+// GitLabTaskManager is the TaskManager of the epics of a GitLab group and the issues of a GitLab project, through
+// the REST API, see https://docs.gitlab.com/ee/api/rest/
+//
+// The IDs of the tasks are the GitLab references of the epics and of the issues, e.g. &12 and #34. The parent of an
+// epic is an epic, and an issue belongs to the epic of its first epic parent, GitLab allowing a single one. An issue
+// is linked to the issues of its parents, blocking them.
+type GitLabTaskManager struct {
+	Conf GitLabConf
+	// Token authenticates the requests, read from the git config if not set.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewGitLabTaskManager returns the task manager of the epics and the issues of the configured group and project.
+func NewGitLabTaskManager(conf GitLabConf) *GitLabTaskManager {
+	return &GitLabTaskManager{Conf: conf}
+}
+
+// gitlabItem is an epic or an issue.
+type gitlabItem struct {
+	ID          int      `json:"id"`
+	IID         int      `json:"iid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	State       string   `json:"state"`
+	WebURL      string   `json:"web_url"`
+	Labels      []string `json:"labels"`
+	// IssueLinkID and LinkType are those of the link to an issue listed among the links of another.
+	IssueLinkID int    `json:"issue_link_id"`
+	LinkType    string `json:"link_type"`
+}
+
+// getToken reads the token from the git config, unless already set.
+func (tmgr *GitLabTaskManager) getToken() error {
+	if tmgr.Token != "" {
+		return nil
+	}
+	token, err := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.gitlab-token"))
+	if err != nil {
+		return fmt.Errorf(`No GitLab token set. Please create a personal access token with the api scope and paste the token
+into this command
+	git config --local --replace-all daedalean.gitlab-token <PASTE_TOKEN_HERE>`)
+	}
+	tmgr.Token = token
+	return nil
+}
+
+// do sends the request with the given method to the path of the GitLab API, see restClient.
+func (tmgr *GitLabTaskManager) do(method, path string, in, out interface{}) error {
+	if err := tmgr.getToken(); err != nil {
+		return err
+	}
+	baseURL := tmgr.Conf.URL
+	if baseURL == "" {
+		baseURL = defaultGitLabURL
+	}
+	c := restClient{Name: "GitLab", BaseURL: strings.TrimSuffix(baseURL, "/") + "/api/v4", HTTPClient: tmgr.HTTPClient, Auth: func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", tmgr.Token)
+	}}
+	return c.do(method, path, in, out)
+}
+
+// list returns the epics or issues of all the pages of the path with the query.
+func (tmgr *GitLabTaskManager) list(path string, q url.Values) ([]*gitlabItem, error) {
+	var items []*gitlabItem
+	for page := 1; ; page++ {
+		q.Set("page", fmt.Sprint(page))
+		q.Set("per_page", fmt.Sprint(gitlabPageSize))
+		var res []*gitlabItem
+		if err := tmgr.do("GET", path+"?"+q.Encode(), nil, &res); err != nil {
+			return nil, err
+		}
+		items = append(items, res...)
+		if len(res) < gitlabPageSize {
+			return items, nil
+		}
+	}
+}
+
+// itemsPath returns the path of the API of the epics of the group or of the issues of the project.
+func (tmgr *GitLabTaskManager) itemsPath(epic bool) string {
+	if epic {
+		return "/groups/" + url.PathEscape(tmgr.Conf.Group) + "/epics"
+	}
+	return "/projects/" + url.PathEscape(tmgr.Conf.Project) + "/issues"
+}
+
+// itemPath returns the path of the API of the epic or the issue with the given reference, e.g. &12 or #34.
+func (tmgr *GitLabTaskManager) itemPath(ref string) (string, error) {
+	if len(ref) < 2 || (ref[0] != '&' && ref[0] != '#') {
+		return "", fmt.Errorf("Invalid GitLab reference %s, expected &<epic> or #<issue>", ref)
+	}
+	return tmgr.itemsPath(ref[0] == '&') + "/" + url.PathEscape(ref[1:]), nil
+}
+
+// gitlabRef returns the reference of the epic or the issue.
+func gitlabRef(epic bool, item *gitlabItem) string {
+	if epic {
+		return "&" + strconv.Itoa(item.IID)
+	}
+	return "#" + strconv.Itoa(item.IID)
+}
+
+// isEpic returns whether the task with the given title, or of the requirement with the given ID, is an epic: those
+// of the configured requirement types and the task grouping all the others, whose title has no requirement ID.
+func (tmgr *GitLabTaskManager) isEpic(title string) bool {
+	if tmgr.Conf.Group == "" {
+		return false
+	}
+	m := reTaskReqType.FindStringSubmatch(title)
+	if m == nil {
+		return true
+	}
+	types := tmgr.Conf.EpicTypes
+	if types == nil {
+		types = defaultGitLabEpicTypes
+	}
+	return contains(types, m[1])
+}
+
+func (tmgr *GitLabTaskManager) invalidLabel() string {
+	if tmgr.Conf.InvalidLabel == "" {
+		return defaultGitLabInvalidLabel
+	}
+	return tmgr.Conf.InvalidLabel
+}
+
+// GetProject returns the label of the project with the given name, which is the name itself, the label being
+// created with the first epic or issue labelled so.
+func (tmgr *GitLabTaskManager) GetProject(name string) (string, error) {
+	return name, nil
+}
+
+// CreateProject returns the label of the project with the given name, labels having no parents in GitLab.
+func (tmgr *GitLabTaskManager) CreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+// GetOrCreateProject returns the label of the project with the given name.
+func (tmgr *GitLabTaskManager) GetOrCreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+func (tmgr *GitLabTaskManager) getItem(ref string) (*gitlabItem, error) {
+	path, err := tmgr.itemPath(ref)
+	if err != nil {
+		return nil, err
+	}
+	var item gitlabItem
+	if err := tmgr.do("GET", path, nil, &item); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindTaskByID returns the GitLab epic or issue with the given reference, e.g. &12 or #34, with its children: the
+// epics and the issues of an epic, and the issues blocking an issue.
+func (tmgr *GitLabTaskManager) FindTaskByID(ref string) (*Task, error) {
+	item, err := tmgr.getItem(ref)
+	if err != nil {
+		return nil, err
+	}
+	task := tmgr.gitlabItemToTask(ref[0] == '&', item)
+	path, _ := tmgr.itemPath(ref)
+	if ref[0] == '&' {
+		for _, kind := range []struct {
+			epic bool
+			path string
+		}{{true, "/epics"}, {false, "/issues"}} {
+			children, err := tmgr.list(path+kind.path, url.Values{})
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range children {
+				task.DependsOnTaskIDs = append(task.DependsOnTaskIDs, gitlabRef(kind.epic, c))
+			}
+		}
+		return task, nil
+	}
+	links, err := tmgr.list(path+"/links", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		if l.LinkType == "is_blocked_by" {
+			task.DependsOnTaskIDs = append(task.DependsOnTaskIDs, gitlabRef(false, l))
+		}
+	}
+	return task, nil
+}
+
+// searchTasks returns the epics or the issues with the given label whose title contains the given text.
+func (tmgr *GitLabTaskManager) searchTasks(text, label string, epic bool) ([]*Task, error) {
+	q := url.Values{}
+	q.Set("search", text)
+	q.Set("labels", label)
+	if !epic {
+		q.Set("in", "title")
+		q.Set("scope", "all")
+	}
+	items, err := tmgr.list(tmgr.itemsPath(epic), q)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*Task
+	for _, item := range items {
+		tasks = append(tasks, tmgr.gitlabItemToTask(epic, item))
+	}
+	return tasks, nil
+}
+
+// FindTaskByTitle returns the GitLab epic or issue with the given title, nil if it is not found, or an error if there
+// was an error finding it. In case there are multiple epics or issues with the given title, FindTaskByTitle returns
+// an error.
+func (tmgr *GitLabTaskManager) FindTaskByTitle(taskTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(taskTitle, projectID, tmgr.isEpic(taskTitle))
+	if err != nil {
+		return nil, err
+	}
+	return taskWithTitle(tasks, taskTitle)
+}
+
+// FindTask returns the GitLab epic or issue corresponding to the given Requirement ID, nil if it was not found or an
+// error if there was an error finding it. In case there are multiple ones with the given ID in the title, FindTask
+// deterministically selects the one with the title that matches as closely as possible the given title.
+func (tmgr *GitLabTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(requirementID, projectID, tmgr.isEpic(requirementID))
+	if err != nil {
+		return nil, err
+	}
+	return closestTask(tasks, requirementID, requirementTitle), nil
+}
+
+// edit updates the epic or the issue with the given reference.
+func (tmgr *GitLabTaskManager) edit(ref string, fields map[string]interface{}) error {
+	path, err := tmgr.itemPath(ref)
+	if err != nil {
+		return err
+	}
+	return tmgr.do("PUT", path, fields, nil)
+}
+
+// UpdateTask updates the given fields of the GitLab epic or issue with the given reference with the data from the
+// given parameters. The attributes are left to the tags.
+func (tmgr *GitLabTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
+	edit := map[string]interface{}{"add_labels": projectID}
+	if fields&TaskTitle != 0 {
+		edit["title"] = title
+	}
+	if fields&TaskDescription != 0 {
+		edit["description"] = taskBody
+	}
+	if err := tmgr.edit(taskID, edit); err != nil {
+		return err
+	}
+	if fields&TaskParents != 0 {
+		return tmgr.setParents(taskID, parentTaskIDs)
+	}
+	return nil
+}
+
+// setParents sets the parents of the GitLab epic or issue with the given reference: the epic of the first epic
+// parent, and for an issue the links to the issue parents, removing the links to the others. The issue parents of an
+// epic are ignored, GitLab not supporting them.
+func (tmgr *GitLabTaskManager) setParents(ref string, parentRefs []string) error {
+	var epicParent *gitlabItem
+	var issueParents []string
+	for _, p := range parentRefs {
+		if strings.HasPrefix(p, "#") {
+			issueParents = append(issueParents, p)
+		} else if epicParent == nil {
+			// The parents are set by the IDs of the epics, not their references.
+			var err error
+			if epicParent, err = tmgr.getItem(p); err != nil {
+				return err
+			}
+		}
+	}
+	if ref[0] == '&' {
+		var parentID interface{}
+		if epicParent != nil {
+			parentID = epicParent.ID
+		}
+		return tmgr.edit(ref, map[string]interface{}{"parent_id": parentID})
+	}
+	// An epic ID of 0 removes the issue from its epic.
+	epicID := 0
+	if epicParent != nil {
+		epicID = epicParent.ID
+	}
+	if err := tmgr.edit(ref, map[string]interface{}{"epic_id": epicID}); err != nil {
+		return err
+	}
+
+	path, _ := tmgr.itemPath(ref)
+	links, err := tmgr.list(path+"/links", url.Values{})
+	if err != nil {
+		return err
+	}
+	linked := map[string]bool{}
+	for _, l := range links {
+		if l.LinkType != "blocks" {
+			continue
+		}
+		if p := gitlabRef(false, l); contains(issueParents, p) {
+			linked[p] = true
+			continue
+		}
+		if err := tmgr.do("DELETE", fmt.Sprintf("%s/links/%d", path, l.IssueLinkID), nil, nil); err != nil {
+			return err
+		}
+	}
+	for _, p := range issueParents {
+		if linked[p] {
+			continue
+		}
+		link := map[string]string{"target_project_id": tmgr.Conf.Project, "target_issue_iid": p[1:], "link_type": "blocks"}
+		if err := tmgr.do("POST", path+"/links", link, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateTaskTags adds the labels to the GitLab epic or issue with the given reference, and removes others.
+func (tmgr *GitLabTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	edit := map[string]interface{}{}
+	if len(addTagIDs) > 0 {
+		edit["add_labels"] = strings.Join(addTagIDs, ",")
+	}
+	if len(removeTagIDs) > 0 {
+		edit["remove_labels"] = strings.Join(removeTagIDs, ",")
+	}
+	if len(edit) == 0 {
+		return nil
+	}
+	return tmgr.edit(taskID, edit)
+}
+
+// DeleteTask closes the GitLab epic or issue with the given reference with the invalid label.
+func (tmgr *GitLabTaskManager) DeleteTask(taskID, title, projectID string) error {
+	return tmgr.edit(taskID, map[string]interface{}{
+		"title":       title,
+		"state_event": "close",
+		"add_labels":  projectID + "," + tmgr.invalidLabel(),
+	})
+}
+
+// CreateTask creates a new GitLab epic, for the requirement types configured so, or issue with the given parameters
+// and returns its reference. The attributes are left to the tags.
+func (tmgr *GitLabTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	epic := tmgr.isEpic(title)
+	create := map[string]interface{}{"title": title, "description": taskBody, "labels": projectID}
+	var item gitlabItem
+	if err := tmgr.do("POST", tmgr.itemsPath(epic), create, &item); err != nil {
+		return "", err
+	}
+	ref := gitlabRef(epic, &item)
+	if len(parentTaskIDs) > 0 {
+		if err := tmgr.setParents(ref, parentTaskIDs); err != nil {
+			return "", err
+		}
+	}
+	return ref, nil
+}
+
+// gitlabItemToTask returns the task of the epic or the issue. Those closed with the invalid label have the status
+// "invalid", as in Phabricator.
+func (tmgr *GitLabTaskManager) gitlabItemToTask(epic bool, item *gitlabItem) *Task {
+	task := &Task{
+		ID:          gitlabRef(epic, item),
+		DisplayID:   gitlabRef(epic, item),
+		Title:       item.Title,
+		Description: item.Description,
+		IsClosed:    item.State == "closed",
+		Status:      item.State,
+		URI:         item.WebURL,
+	}
+	if task.IsClosed && contains(item.Labels, tmgr.invalidLabel()) {
+		task.Status = "invalid"
+	}
+	return task
+}
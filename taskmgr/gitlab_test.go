@@ -0,0 +1,233 @@
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeGitLab is a GitLab server keeping the epics of the group daedalean and the issues of the project
+// daedalean/reqtraq in memory.
+type fakeGitLab struct {
+	epics, issues []*gitlabItem
+	// epicOf and parentOf are the epic of each issue and the parent of each epic, by ID.
+	epicOf, parentOf map[int]int
+	// links are the issues blocked by each issue, by IID.
+	links map[int][]int
+}
+
+func (g *fakeGitLab) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	var items *[]*gitlabItem
+	path := r.URL.EscapedPath()
+	switch {
+	case strings.HasPrefix(path, "/api/v4/groups/daedalean/epics"):
+		items, path = &g.epics, strings.TrimPrefix(path, "/api/v4/groups/daedalean/epics")
+	case strings.HasPrefix(path, "/api/v4/projects/daedalean%2Freqtraq/issues"):
+		items, path = &g.issues, strings.TrimPrefix(path, "/api/v4/projects/daedalean%2Freqtraq/issues")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	var item *gitlabItem
+	if n, err := strconv.Atoi(parts[0]); err == nil && n > 0 && n <= len(*items) {
+		item = (*items)[n-1]
+	}
+	switch {
+	case r.Method == "GET" && path == "":
+		var found []*gitlabItem
+		for _, i := range *items {
+			if strings.Contains(i.Title, r.FormValue("search")) && contains(i.Labels, r.FormValue("labels")) {
+				found = append(found, i)
+			}
+		}
+		json.NewEncoder(w).Encode(found)
+	case r.Method == "POST" && path == "":
+		item := &gitlabItem{IID: len(*items) + 1, Title: body["title"].(string), Description: body["description"].(string),
+			State: "opened", Labels: strings.Split(body["labels"].(string), ",")}
+		item.ID = item.IID + 100
+		if items == &g.epics {
+			item.ID += 1000
+		}
+		*items = append(*items, item)
+		json.NewEncoder(w).Encode(item)
+	case item == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "GET" && len(parts) == 1:
+		json.NewEncoder(w).Encode(item)
+	case r.Method == "PUT" && len(parts) == 1:
+		for k, v := range body {
+			switch k {
+			case "title":
+				item.Title = v.(string)
+			case "description":
+				item.Description = v.(string)
+			case "state_event":
+				item.State = "closed"
+			case "add_labels":
+				item.Labels = append(item.Labels, strings.Split(v.(string), ",")...)
+			case "remove_labels":
+				var labels []string
+				for _, l := range item.Labels {
+					if !contains(strings.Split(v.(string), ","), l) {
+						labels = append(labels, l)
+					}
+				}
+				item.Labels = labels
+			case "epic_id":
+				g.epicOf[item.ID] = int(v.(float64))
+			case "parent_id":
+				if v == nil {
+					delete(g.parentOf, item.ID)
+				} else {
+					g.parentOf[item.ID] = int(v.(float64))
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(item)
+	case r.Method == "GET" && len(parts) == 2 && items == &g.epics:
+		var children []*gitlabItem
+		if parts[1] == "epics" {
+			for _, e := range g.epics {
+				if g.parentOf[e.ID] == item.ID {
+					children = append(children, e)
+				}
+			}
+		} else {
+			for _, i := range g.issues {
+				if g.epicOf[i.ID] == item.ID {
+					children = append(children, i)
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(children)
+	case r.Method == "GET" && len(parts) == 2 && parts[1] == "links":
+		var links []*gitlabItem
+		for from, to := range g.links {
+			for _, t := range to {
+				if from == item.IID {
+					links = append(links, &gitlabItem{IID: t, IssueLinkID: from*1000 + t, LinkType: "blocks"})
+				} else if t == item.IID {
+					links = append(links, &gitlabItem{IID: from, IssueLinkID: from*1000 + t, LinkType: "is_blocked_by"})
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(links)
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "links":
+		if body["target_project_id"] != "daedalean/reqtraq" || body["link_type"] != "blocks" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		to, _ := strconv.Atoi(body["target_issue_iid"].(string))
+		g.links[item.IID] = append(g.links[item.IID], to)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "DELETE" && len(parts) == 3 && parts[1] == "links":
+		id, _ := strconv.Atoi(parts[2])
+		var to []int
+		for _, t := range g.links[id/1000] {
+			if t != id%1000 {
+				to = append(to, t)
+			}
+		}
+		g.links[id/1000] = to
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestGitLabTaskManager(t *testing.T) {
+	gitlab := &fakeGitLab{epicOf: map[int]int{}, parentOf: map[int]int{}, links: map[int][]int{}}
+	server := httptest.NewServer(gitlab)
+	defer server.Close()
+
+	tmgr := NewGitLabTaskManager(GitLabConf{URL: server.URL, Project: "daedalean/reqtraq", Group: "daedalean"})
+	tmgr.Token = "secret"
+
+	all, err := tmgr.CreateTask("Implement DDLN", "Meta-task", "DDLN-SYS", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	epic, err := tmgr.CreateTask("REQ-0-DDLN-SYS-001: System", "System body", "DDLN-SYS", nil, []string{all})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := tmgr.CreateTask("REQ-0-DDLN-SWH-001: High", "High body", "DDLN-HLR", nil, []string{epic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high2, err := tmgr.CreateTask("REQ-0-DDLN-SWH-002: Other", "Other body", "DDLN-HLR", nil, []string{epic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := tmgr.CreateTask("REQ-0-DDLN-SWL-001: Low", "Low body", "DDLN", nil, []string{high})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := []string{all, epic, high, high2, low}; !reflect.DeepEqual(refs, []string{"&1", "&2", "#1", "#2", "#3"}) {
+		t.Errorf("unexpected references %v", refs)
+	}
+
+	task, err := tmgr.FindTask("REQ-0-DDLN-SYS-001", "System", "DDLN-SYS")
+	if err != nil || task == nil || task.ID != epic {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByTitle("Implement DDLN", "DDLN-SYS"); err != nil || task == nil || task.ID != all {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByID(all); err != nil || !reflect.DeepEqual(task.DependsOnTaskIDs, []string{epic}) {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByID(epic); err != nil || !reflect.DeepEqual(task.DependsOnTaskIDs, []string{high, high2}) {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByID(high); err != nil || !reflect.DeepEqual(task.DependsOnTaskIDs, []string{low}) {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+
+	// The link to the former parent is replaced.
+	if err := tmgr.UpdateTask(low, "REQ-0-DDLN-SWL-001: Renamed", "Edited body", "DDLN", nil, []string{high2}, TaskTitle|TaskParents); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = tmgr.FindTask("REQ-0-DDLN-SWL-001", "Renamed", "DDLN"); err != nil || task == nil || task.Title != "REQ-0-DDLN-SWL-001: Renamed" || task.Description != "Low body" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, _ = tmgr.FindTaskByID(high); len(task.DependsOnTaskIDs) != 0 {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+	if task, _ = tmgr.FindTaskByID(high2); !reflect.DeepEqual(task.DependsOnTaskIDs, []string{low}) {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+
+	if err := tmgr.UpdateTaskTags(low, []string{"Safety critical"}, []string{"DDLN"}); err != nil {
+		t.Fatal(err)
+	}
+	if labels := gitlab.issues[2].Labels; !reflect.DeepEqual(labels, []string{"Safety critical"}) {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if err := tmgr.DeleteTask(low, "REQ-0-DDLN-SWL-001: Deleted", "DDLN"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = tmgr.FindTaskByID(low); task.Status != "invalid" || !task.IsClosed || task.Title != "REQ-0-DDLN-SWL-001: Deleted" {
+		t.Errorf("unexpected task %+v", task)
+	}
+
+	if _, err := tmgr.FindTaskByID("34"); err == nil || err.Error() != "Invalid GitLab reference 34, expected &<epic> or #<issue>" {
+		t.Errorf("unexpected error %v", err)
+	}
+	tmgr.Token = "wrong"
+	if _, err := tmgr.FindTaskByID(low); err == nil || err.Error() != fmt.Sprintf("GitLab request GET %s/api/v4/projects/daedalean%%2Freqtraq/issues/3 failed: 401 Unauthorized", server.URL) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
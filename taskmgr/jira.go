@@ -0,0 +1,460 @@
+// @llr REQ-0-DDLN-SWL-082
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// JiraConf is the configuration of the Jira task manager, the "jira" entry in attributes.json. The tasks of all the
+// requirements are issues of the same Jira project, the projects of reqtraq being labels of these issues.
+type JiraConf struct {
+	// URL is that of the Jira server, e.g. https://example.atlassian.net.
+	URL string `json:"url"`
+	// Project is the key of the Jira project, e.g. DDLN.
+	Project string `json:"project"`
+	// IssueTypes are the issue types of the tasks by requirement type, e.g. "SYS": "Epic", see defaultJiraIssueTypes.
+	IssueTypes map[string]string `json:"issueTypes"`
+	// CustomFields are the IDs of the custom fields set to the requirement attributes, by attribute name, e.g.
+	// "SAFETY IMPACT": "customfield_10042", and read back into the tasks.
+	CustomFields map[string]string `json:"customFields"`
+	// LinkType is the issue link type linking the tasks to the tasks of their parents, Blocks by default.
+	LinkType string `json:"linkType"`
+	// InvalidStatus is the status the tasks of the deleted requirements are transitioned to, Invalid by default.
+	InvalidStatus string `json:"invalidStatus"`
+}
+
+// defaultJiraIssueTypes are the issue types of the tasks by requirement type if not configured, the system
+// requirements being epics and the high-level ones stories. The other tasks are of the type Task.
+var defaultJiraIssueTypes = map[string]string{"SYS": "Epic", "SWH": "Story", "HWH": "Story"}
+
+const (
+	defaultJiraIssueType     = "Task"
+	defaultJiraLinkType      = "Blocks"
+	defaultJiraInvalidStatus = "Invalid"
+	// jiraPageSize is the number of issues searched at once.
+	jiraPageSize = 50
+)
+
+// JiraTaskManager is the TaskManager of the issues of a Jira project, through the REST API of the server, see
+// https://docs.atlassian.com/software/jira/docs/api/REST/latest/
+//
+// The parents of a task are the issues it is linked to by the configured link type, the task being the inward issue
+// of the link, e.g. it blocks its parents.
+type JiraTaskManager struct {
+	Conf JiraConf
+	// User and Token (an API token or a password) authenticate the requests, read from the git config if not set.
+	User       string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewJiraTaskManager returns the task manager of the configured Jira project.
+func NewJiraTaskManager(conf JiraConf) *JiraTaskManager {
+	return &JiraTaskManager{Conf: conf}
+}
+
+type jiraIssueRef struct {
+	Key string `json:"key"`
+}
+
+type jiraIssueLink struct {
+	ID   string `json:"id,omitempty"`
+	Type struct {
+		Name string `json:"name"`
+	} `json:"type"`
+	InwardIssue  *jiraIssueRef `json:"inwardIssue,omitempty"`
+	OutwardIssue *jiraIssueRef `json:"outwardIssue,omitempty"`
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string `json:"summary"`
+		Description string `json:"description"`
+		Status      struct {
+			Name           string `json:"name"`
+			StatusCategory struct {
+				Key string `json:"key"`
+			} `json:"statusCategory"`
+		} `json:"status"`
+		Priority *struct {
+			Name string `json:"name"`
+		} `json:"priority"`
+		IssueLinks []jiraIssueLink `json:"issuelinks"`
+	} `json:"fields"`
+	// AllFields are all the fields of the issue, by ID, to read the custom fields back.
+	AllFields map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON decodes the issue, keeping all its fields in AllFields.
+func (i *jiraIssue) UnmarshalJSON(b []byte) error {
+	type issue jiraIssue
+	if err := json.Unmarshal(b, (*issue)(i)); err != nil {
+		return err
+	}
+	var all struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	i.AllFields = all.Fields
+	return nil
+}
+
+type jiraSearchResponse struct {
+	Issues []*jiraIssue `json:"issues"`
+	Total  int          `json:"total"`
+}
+
+// jiraIssueFields are the fields of the issues read into tasks, with the custom fields, see issueFields.
+const jiraIssueFields = "summary,description,status,priority,issuelinks"
+
+// issueFields returns the fields of the issues read into tasks, those of jiraIssueFields and the custom fields.
+func (tmgr *JiraTaskManager) issueFields() string {
+	fields := jiraIssueFields
+	for _, name := range attributeNames(tmgr.Conf.CustomFields) {
+		fields += "," + tmgr.Conf.CustomFields[name]
+	}
+	return fields
+}
+
+// MappedAttributes returns the names of the attributes set to the custom fields.
+func (tmgr *JiraTaskManager) MappedAttributes() []string {
+	return attributeNames(tmgr.Conf.CustomFields)
+}
+
+// getCredentials reads the user and the token from the git config, unless already set.
+func (tmgr *JiraTaskManager) getCredentials() error {
+	if tmgr.User != "" && tmgr.Token != "" {
+		return nil
+	}
+	user, errUser := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.jira-user"))
+	token, errToken := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.jira-token"))
+	if errUser != nil || errToken != nil {
+		return fmt.Errorf(`No Jira credentials set. Please create an API token for your account and set them with
+	git config --local --replace-all daedalean.jira-user <USER>
+	git config --local --replace-all daedalean.jira-token <API_TOKEN>`)
+	}
+	tmgr.User, tmgr.Token = user, token
+	return nil
+}
+
+// do sends the request with the given method to the path of the Jira server, see restClient.
+func (tmgr *JiraTaskManager) do(method, path string, in, out interface{}) error {
+	if err := tmgr.getCredentials(); err != nil {
+		return err
+	}
+	c := restClient{Name: "Jira", BaseURL: tmgr.Conf.URL, HTTPClient: tmgr.HTTPClient, Auth: func(req *http.Request) {
+		req.SetBasicAuth(tmgr.User, tmgr.Token)
+	}}
+	return c.do(method, path, in, out)
+}
+
+// jiraLabel returns the label of the project or the tag with the given name, labels not containing spaces.
+func jiraLabel(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// jiraString quotes the string for JQL.
+func jiraString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// GetProject returns the label of the project with the given name, the label being created with the first issue
+// labelled so.
+func (tmgr *JiraTaskManager) GetProject(name string) (string, error) {
+	return jiraLabel(name), nil
+}
+
+// CreateProject returns the label of the project with the given name, projects having no parents in Jira.
+func (tmgr *JiraTaskManager) CreateProject(name, parentID string) (string, error) {
+	return jiraLabel(name), nil
+}
+
+// GetOrCreateProject returns the label of the project with the given name.
+func (tmgr *JiraTaskManager) GetOrCreateProject(name, parentID string) (string, error) {
+	return jiraLabel(name), nil
+}
+
+// FindTaskByID returns the Jira issue with the given key.
+func (tmgr *JiraTaskManager) FindTaskByID(key string) (*Task, error) {
+	var issue jiraIssue
+	if err := tmgr.do("GET", "/rest/api/2/issue/"+url.PathEscape(key)+"?fields="+tmgr.issueFields(), nil, &issue); err != nil {
+		return nil, err
+	}
+	return tmgr.jiraIssueToTask(&issue), nil
+}
+
+// searchTasks returns the issues of the Jira project with the given label whose summary contains the given text.
+func (tmgr *JiraTaskManager) searchTasks(text, label string) ([]*Task, error) {
+	jql := fmt.Sprintf("project = %s AND labels = %s AND summary ~ %s", jiraString(tmgr.Conf.Project), jiraString(label),
+		jiraString(jiraString(text)))
+	var tasks []*Task
+	for {
+		q := url.Values{}
+		q.Set("jql", jql)
+		q.Set("fields", tmgr.issueFields())
+		q.Set("startAt", fmt.Sprint(len(tasks)))
+		q.Set("maxResults", fmt.Sprint(jiraPageSize))
+		var res jiraSearchResponse
+		if err := tmgr.do("GET", "/rest/api/2/search?"+q.Encode(), nil, &res); err != nil {
+			return nil, err
+		}
+		for _, issue := range res.Issues {
+			tasks = append(tasks, tmgr.jiraIssueToTask(issue))
+		}
+		if len(res.Issues) == 0 || len(tasks) >= res.Total {
+			return tasks, nil
+		}
+	}
+}
+
+// FindTaskByTitle returns the Jira issue with the given summary, nil if the issue is not found, or an error if there
+// was an error finding the issue. In case there are multiple issues with the given summary, FindTaskByTitle returns
+// an error.
+func (tmgr *JiraTaskManager) FindTaskByTitle(taskTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(taskTitle, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return taskWithTitle(tasks, taskTitle)
+}
+
+// FindTask returns the Jira issue corresponding to the given Requirement ID, nil if the issue was not found or an
+// error if there was an error finding the issue. In case there are multiple issues with the given ID in the summary,
+// FindTask deterministically selects the issue with the summary that matches as closely as possible the given title.
+func (tmgr *JiraTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(requirementID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return closestTask(tasks, requirementID, requirementTitle), nil
+}
+
+// customFields sets the custom fields configured to the values of the attributes, clearing those of the attributes
+// the requirement doesn't have.
+func (tmgr *JiraTaskManager) customFields(fields map[string]interface{}, attributes map[string]string) {
+	for name, field := range tmgr.Conf.CustomFields {
+		if v, ok := attributes[name]; ok {
+			fields[field] = v
+		} else {
+			fields[field] = nil
+		}
+	}
+}
+
+// UpdateTask updates the given fields of the Jira issue with the given key with the data from the given parameters,
+// its custom fields being those of the attributes. The parents of the issue are replaced by the given ones.
+func (tmgr *JiraTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
+	set := map[string]interface{}{}
+	if fields&TaskTitle != 0 {
+		set["summary"] = title
+	}
+	if fields&TaskDescription != 0 {
+		set["description"] = taskBody
+	}
+	if fields&TaskAttributes != 0 {
+		tmgr.customFields(set, attributes)
+	}
+	edit := map[string]interface{}{
+		"fields": set,
+		"update": map[string]interface{}{"labels": []map[string]string{{"add": projectID}}},
+	}
+	if err := tmgr.do("PUT", "/rest/api/2/issue/"+url.PathEscape(taskID), edit, nil); err != nil {
+		return err
+	}
+	if fields&TaskParents != 0 {
+		return tmgr.setParents(taskID, parentTaskIDs)
+	}
+	return nil
+}
+
+// setParents links the Jira issue with the given key to the given parents, removing its links to the others.
+func (tmgr *JiraTaskManager) setParents(key string, parentKeys []string) error {
+	var issue jiraIssue
+	if err := tmgr.do("GET", "/rest/api/2/issue/"+url.PathEscape(key)+"?fields=issuelinks", nil, &issue); err != nil {
+		return err
+	}
+	linked := map[string]bool{}
+	for _, l := range tmgr.parentLinks(&issue) {
+		if contains(parentKeys, l.OutwardIssue.Key) {
+			linked[l.OutwardIssue.Key] = true
+			continue
+		}
+		if err := tmgr.do("DELETE", "/rest/api/2/issueLink/"+url.PathEscape(l.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+	for _, p := range parentKeys {
+		if !linked[p] {
+			if err := tmgr.link(key, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// link links the Jira issue with the given key to the given parent.
+func (tmgr *JiraTaskManager) link(key, parentKey string) error {
+	var l jiraIssueLink
+	l.Type.Name = tmgr.linkType()
+	l.InwardIssue = &jiraIssueRef{key}
+	l.OutwardIssue = &jiraIssueRef{parentKey}
+	return tmgr.do("POST", "/rest/api/2/issueLink", l, nil)
+}
+
+// parentLinks returns the links of the issue to its parents, which are those of the configured type to outward
+// issues.
+func (tmgr *JiraTaskManager) parentLinks(issue *jiraIssue) []jiraIssueLink {
+	var links []jiraIssueLink
+	for _, l := range issue.Fields.IssueLinks {
+		if l.Type.Name == tmgr.linkType() && l.OutwardIssue != nil {
+			links = append(links, l)
+		}
+	}
+	return links
+}
+
+// UpdateTaskTags adds the labels to the Jira issue with the given key, and removes others.
+func (tmgr *JiraTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	var labels []map[string]string
+	for _, t := range addTagIDs {
+		labels = append(labels, map[string]string{"add": t})
+	}
+	for _, t := range removeTagIDs {
+		labels = append(labels, map[string]string{"remove": t})
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	edit := map[string]interface{}{"update": map[string]interface{}{"labels": labels}}
+	return tmgr.do("PUT", "/rest/api/2/issue/"+url.PathEscape(taskID), edit, nil)
+}
+
+// DeleteTask transitions the Jira issue with the given key to the configured invalid status.
+func (tmgr *JiraTaskManager) DeleteTask(taskID, title, projectID string) error {
+	edit := map[string]interface{}{
+		"fields": map[string]interface{}{"summary": title},
+		"update": map[string]interface{}{"labels": []map[string]string{{"add": projectID}}},
+	}
+	if err := tmgr.do("PUT", "/rest/api/2/issue/"+url.PathEscape(taskID), edit, nil); err != nil {
+		return err
+	}
+	var res struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+			To   struct {
+				Name string `json:"name"`
+			} `json:"to"`
+		} `json:"transitions"`
+	}
+	path := "/rest/api/2/issue/" + url.PathEscape(taskID) + "/transitions"
+	if err := tmgr.do("GET", path, nil, &res); err != nil {
+		return err
+	}
+	for _, t := range res.Transitions {
+		if strings.EqualFold(t.To.Name, tmgr.invalidStatus()) {
+			return tmgr.do("POST", path, map[string]interface{}{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+	}
+	return fmt.Errorf("No transition of Jira issue %s to the status %s", taskID, tmgr.invalidStatus())
+}
+
+// CreateTask creates a new Jira issue with the given parameters, of the issue type of the requirement, and returns
+// its key.
+func (tmgr *JiraTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	fields := map[string]interface{}{
+		"project":     jiraIssueRef{tmgr.Conf.Project},
+		"issuetype":   map[string]string{"name": tmgr.issueType(title)},
+		"summary":     title,
+		"description": taskBody,
+		"labels":      []string{projectID},
+	}
+	tmgr.customFields(fields, attributes)
+	var res jiraIssueRef
+	if err := tmgr.do("POST", "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &res); err != nil {
+		return "", err
+	}
+	for _, p := range parentTaskIDs {
+		if err := tmgr.link(res.Key, p); err != nil {
+			return "", err
+		}
+	}
+	return res.Key, nil
+}
+
+// issueType returns the issue type of the task with the given title, by the type of its requirement.
+func (tmgr *JiraTaskManager) issueType(title string) string {
+	types := tmgr.Conf.IssueTypes
+	if types == nil {
+		types = defaultJiraIssueTypes
+	}
+	if m := reTaskReqType.FindStringSubmatch(title); m != nil && types[m[1]] != "" {
+		return types[m[1]]
+	}
+	return defaultJiraIssueType
+}
+
+func (tmgr *JiraTaskManager) linkType() string {
+	if tmgr.Conf.LinkType == "" {
+		return defaultJiraLinkType
+	}
+	return tmgr.Conf.LinkType
+}
+
+func (tmgr *JiraTaskManager) invalidStatus() string {
+	if tmgr.Conf.InvalidStatus == "" {
+		return defaultJiraInvalidStatus
+	}
+	return tmgr.Conf.InvalidStatus
+}
+
+// jiraIssueToTask returns the task of the issue, the tasks it depends on being those of the children linked to it.
+// The issues in the invalid status have the status "invalid", as in Phabricator.
+func (tmgr *JiraTaskManager) jiraIssueToTask(issue *jiraIssue) *Task {
+	task := &Task{
+		ID:          issue.Key,
+		DisplayID:   issue.Key,
+		Title:       issue.Fields.Summary,
+		Description: issue.Fields.Description,
+		IsClosed:    issue.Fields.Status.StatusCategory.Key == "done",
+		Status:      issue.Fields.Status.Name,
+		URI:         strings.TrimSuffix(tmgr.Conf.URL, "/") + "/browse/" + issue.Key,
+	}
+	if strings.EqualFold(task.Status, tmgr.invalidStatus()) {
+		task.Status = "invalid"
+	}
+	if issue.Fields.Priority != nil {
+		task.Priority = issue.Fields.Priority.Name
+	}
+	for _, l := range issue.Fields.IssueLinks {
+		if l.Type.Name == tmgr.linkType() && l.InwardIssue != nil {
+			task.DependsOnTaskIDs = append(task.DependsOnTaskIDs, l.InwardIssue.Key)
+		}
+	}
+	if len(tmgr.Conf.CustomFields) > 0 {
+		task.Attributes = map[string]string{}
+		for name, field := range tmgr.Conf.CustomFields {
+			// The select lists are options, whose value is the attribute.
+			switch v := issue.AllFields[field].(type) {
+			case string:
+				task.Attributes[name] = v
+			case map[string]interface{}:
+				task.Attributes[name] = fmt.Sprint(v["value"])
+			case nil:
+			default:
+				task.Attributes[name] = fmt.Sprint(v)
+			}
+		}
+	}
+	return task
+}
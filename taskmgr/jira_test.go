@@ -0,0 +1,218 @@
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeJira is a Jira server keeping the issues created and the links between them in memory.
+type fakeJira struct {
+	issues map[string]*jiraIssue
+	fields map[string]map[string]interface{}
+	links  []jiraIssueLink
+}
+
+func (j *fakeJira) issue(w http.ResponseWriter, key string) *jiraIssue {
+	issue := j.issues[key]
+	if issue == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return nil
+	}
+	issue.Fields.IssueLinks = nil
+	for _, l := range j.links {
+		if l.InwardIssue.Key == key {
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks, jiraIssueLink{ID: l.ID, Type: l.Type, OutwardIssue: l.OutwardIssue})
+		}
+		if l.OutwardIssue.Key == key {
+			issue.Fields.IssueLinks = append(issue.Fields.IssueLinks, jiraIssueLink{ID: l.ID, Type: l.Type, InwardIssue: l.InwardIssue})
+		}
+	}
+	return issue
+}
+
+// encode returns the issue as sent by Jira, with its custom fields.
+func (j *fakeJira) encode(issue *jiraIssue) map[string]interface{} {
+	var res map[string]interface{}
+	b, _ := json.Marshal(issue)
+	json.Unmarshal(b, &res)
+	for k, v := range j.fields[issue.Key] {
+		if strings.HasPrefix(k, "customfield_") {
+			res["fields"].(map[string]interface{})[k] = v
+		}
+	}
+	return res
+}
+
+func (j *fakeJira) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if user, token, ok := r.BasicAuth(); !ok || user != "user" || token != "token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	path := strings.TrimPrefix(r.URL.Path, "/rest/api/2")
+	switch {
+	case r.Method == "POST" && path == "/issue":
+		fields := body["fields"].(map[string]interface{})
+		key := fmt.Sprintf("DDLN-%d", len(j.issues)+1)
+		issue := &jiraIssue{Key: key}
+		issue.Fields.Summary = fields["summary"].(string)
+		issue.Fields.Description = fields["description"].(string)
+		issue.Fields.Status.Name = "To Do"
+		j.issues[key] = issue
+		j.fields[key] = fields
+		fmt.Fprintf(w, `{"id": "1000%d", "key": "%s"}`, len(j.issues), key)
+	case r.Method == "GET" && path == "/search":
+		issues := []interface{}{}
+		for _, k := range []string{"DDLN-1", "DDLN-2", "DDLN-3"} {
+			if issue := j.issues[k]; issue != nil && strings.Contains(r.FormValue("jql"), `labels = "`+j.fields[k]["labels"].([]interface{})[0].(string)+`"`) {
+				issues = append(issues, j.encode(j.issue(w, k)))
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"issues": issues, "total": len(issues)})
+	case r.Method == "GET" && strings.HasSuffix(path, "/transitions"):
+		fmt.Fprint(w, `{"transitions": [{"id": "11", "to": {"name": "Done"}}, {"id": "21", "to": {"name": "Invalid"}}]}`)
+	case r.Method == "POST" && strings.HasSuffix(path, "/transitions"):
+		if issue := j.issues[strings.TrimSuffix(strings.TrimPrefix(path, "/issue/"), "/transitions")]; issue != nil && fmt.Sprint(body["transition"]) == "map[id:21]" {
+			issue.Fields.Status.Name = "Invalid"
+		}
+	case r.Method == "GET" && strings.HasPrefix(path, "/issue/"):
+		if issue := j.issue(w, strings.TrimPrefix(path, "/issue/")); issue != nil {
+			json.NewEncoder(w).Encode(j.encode(issue))
+		}
+	case r.Method == "PUT" && strings.HasPrefix(path, "/issue/"):
+		issue := j.issue(w, strings.TrimPrefix(path, "/issue/"))
+		if issue == nil {
+			return
+		}
+		if fields, ok := body["fields"].(map[string]interface{}); ok {
+			if s, ok := fields["summary"].(string); ok {
+				issue.Fields.Summary = s
+			}
+			if d, ok := fields["description"].(string); ok {
+				issue.Fields.Description = d
+			}
+			for k, v := range fields {
+				j.fields[issue.Key][k] = v
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST" && path == "/issueLink":
+		var l jiraIssueLink
+		b, _ := json.Marshal(body)
+		json.Unmarshal(b, &l)
+		l.ID = fmt.Sprint(len(j.links) + 1)
+		j.links = append(j.links, l)
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == "DELETE" && strings.HasPrefix(path, "/issueLink/"):
+		for i, l := range j.links {
+			if l.ID == strings.TrimPrefix(path, "/issueLink/") {
+				j.links = append(j.links[:i], j.links[i+1:]...)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestJiraTaskManager(t *testing.T) {
+	jira := &fakeJira{issues: map[string]*jiraIssue{}, fields: map[string]map[string]interface{}{}}
+	server := httptest.NewServer(jira)
+	defer server.Close()
+
+	tmgr := NewJiraTaskManager(JiraConf{URL: server.URL + "/", Project: "DDLN", CustomFields: map[string]string{"SAFETY IMPACT": "customfield_1"}})
+	tmgr.User, tmgr.Token = "user", "token"
+
+	sys, err := tmgr.GetOrCreateProject("DDLN-SYS", "")
+	if err != nil || sys != "DDLN-SYS" {
+		t.Fatalf("unexpected project %q, %v", sys, err)
+	}
+	epic, err := tmgr.CreateTask("REQ-0-DDLN-SYS-001: System", "System body", sys, map[string]string{"SAFETY IMPACT": "High"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	story, err := tmgr.CreateTask("REQ-0-DDLN-SWH-001: High", "High body", "DDLN-HLR", map[string]string{}, []string{epic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jira.fields[epic]["issuetype"]; fmt.Sprint(got) != "map[name:Epic]" {
+		t.Errorf("unexpected issue type %v", got)
+	}
+	if got := jira.fields[story]["issuetype"]; fmt.Sprint(got) != "map[name:Story]" {
+		t.Errorf("unexpected issue type %v", got)
+	}
+	if got := jira.fields[epic]["customfield_1"]; got != "High" {
+		t.Errorf("unexpected custom field %v", got)
+	}
+	if got, ok := jira.fields[story]["customfield_1"]; !ok || got != nil {
+		t.Errorf("unexpected custom field %v", got)
+	}
+
+	task, err := tmgr.FindTask("REQ-0-DDLN-SWH-001", "High", "DDLN-HLR")
+	if err != nil || task == nil || task.ID != story {
+		t.Fatalf("unexpected task %v, %v", task, err)
+	}
+	if task, err := tmgr.FindTask("REQ-0-DDLN-SWH-001", "High", sys); err != nil || task != nil {
+		t.Errorf("unexpected task %v, %v", task, err)
+	}
+	parent, err := tmgr.FindTaskByID(epic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parent.DependsOnTaskIDs, []string{story}) || parent.URI != server.URL+"/browse/"+epic ||
+		!reflect.DeepEqual(parent.Attributes, map[string]string{"SAFETY IMPACT": "High"}) {
+		t.Errorf("unexpected task %+v", parent)
+	}
+	if len(task.Attributes) != 0 {
+		t.Errorf("unexpected attributes %v", task.Attributes)
+	}
+
+	// The parents are replaced.
+	other, err := tmgr.CreateTask("REQ-0-DDLN-SYS-002: Other", "Other body", sys, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmgr.UpdateTask(story, "REQ-0-DDLN-SWH-001: Renamed", "Edited body", "DDLN-HLR", nil, []string{other}, TaskTitle|TaskParents); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = tmgr.FindTaskByTitle("REQ-0-DDLN-SWH-001: Renamed", "DDLN-HLR"); err != nil || task == nil {
+		t.Fatalf("unexpected task %v, %v", task, err)
+	}
+	if task.Description != "High body" {
+		t.Errorf("unexpected description %q", task.Description)
+	}
+	// The custom fields are only set with the attributes.
+	if err := tmgr.UpdateTask(story, "", "", "DDLN-HLR", map[string]string{"SAFETY IMPACT": "Low"}, nil, TaskAttributes); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = tmgr.FindTaskByID(story); task.Attributes["SAFETY IMPACT"] != "Low" || task.Title != "REQ-0-DDLN-SWH-001: Renamed" {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if parent, _ = tmgr.FindTaskByID(epic); len(parent.DependsOnTaskIDs) != 0 {
+		t.Errorf("unexpected children %v", parent.DependsOnTaskIDs)
+	}
+	if parent, _ = tmgr.FindTaskByID(other); !reflect.DeepEqual(parent.DependsOnTaskIDs, []string{story}) {
+		t.Errorf("unexpected children %v", parent.DependsOnTaskIDs)
+	}
+
+	if err := tmgr.UpdateTaskTags(story, []string{"Safety_critical"}, []string{"MODE:_Manual"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmgr.DeleteTask(story, "REQ-0-DDLN-SWH-001: Deleted", "DDLN-HLR"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = tmgr.FindTaskByID(story); task.Status != "invalid" || task.Title != "REQ-0-DDLN-SWH-001: Deleted" {
+		t.Errorf("unexpected task %+v", task)
+	}
+
+	tmgr.Token = "wrong"
+	if _, err := tmgr.FindTaskByID(story); err == nil || err.Error() != fmt.Sprintf("Jira request GET %s/rest/api/2/issue/DDLN-2?fields=%s failed: 401 Unauthorized", server.URL, jiraIssueFields+",customfield_1") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -4,6 +4,7 @@
 // conditional build using
 // 	go build -tags <your_tag>
 
+//go:build phabricator || !phabricator
 // +build phabricator !phabricator
 
 // @llr REQ-0-DDLN-SWL-018
@@ -11,7 +12,7 @@ package taskmgr
 
 import (
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/arbovm/levenshtein"
 	"github.com/danieldanciu/gonduit"
@@ -22,11 +23,27 @@ import (
 	"github.com/daedaleanai/reqtraq/linepipes"
 )
 
+// PhabricatorConf is the configuration of the Phabricator task manager, the "phabricator" entry in attributes.json.
+type PhabricatorConf struct {
+	// CustomFields are the keys of the Maniphest custom fields set to the requirement attributes, by attribute name,
+	// e.g. "SAFETY IMPACT": "daedalean:safety-impact", as defined in maniphest.custom-field-definitions, and read back
+	// into the tasks.
+	CustomFields map[string]string `json:"customFields"`
+	// URL is that of the Phabricator server, https://p.daedalean.ai if not set.
+	URL string `json:"url,omitempty"`
+}
+
 type PhabricatorTaskManager struct {
+	Conf           PhabricatorConf
 	cachedApiToken string
 }
 
-var TaskMgr TaskManager = &PhabricatorTaskManager{}
+// NewPhabricatorTaskManager returns the Phabricator task manager with the given configuration.
+func NewPhabricatorTaskManager(conf PhabricatorConf) *PhabricatorTaskManager {
+	return &PhabricatorTaskManager{Conf: conf}
+}
+
+var TaskMgr TaskManager = NewPhabricatorTaskManager(PhabricatorConf{})
 
 // getApiToken returns the Phabricator API token that allows us to make authenticated API operations.
 func (tmgr *PhabricatorTaskManager) getApiToken() (string, error) {
@@ -59,12 +76,17 @@ func (tmgr *PhabricatorTaskManager) getApiClient() (*gonduit.Conn, error) {
 	if err != nil {
 		return nil, err
 	}
-	return gonduit.Dial("https://p.daedalean.ai", &core.ClientOptions{APIToken: apiToken})
+	url := tmgr.Conf.URL
+	if url == "" {
+		url = "https://p.daedalean.ai"
+	}
+	return gonduit.Dial(url, &core.ClientOptions{APIToken: apiToken})
 }
 
 // GetProject returns the ID of the Phabricator project ID with the given name or nil if the project doesn't exist.
 // For example, when called with "Reqtraq" the method will return "ID-PROJ-3e2qnmcuzxzl3iko7xdl".
 // The method calls https://p.daedalean.ai/api/project.query with the request
+//
 //	{
 //		"names": ["Reqtraq"]
 //	}
@@ -134,7 +156,8 @@ func (tmgr *PhabricatorTaskManager) FindTaskByID(phid string) (*Task, error) {
 	}
 
 	for _, v := range *res {
-		return maniphestTaskToTask(v), nil
+		task := maniphestTaskToTask(v)
+		return task, tmgr.readCustomFields(client, []*Task{task})
 	}
 	return nil, fmt.Errorf("No task with phid %s found.", phid)
 }
@@ -162,7 +185,7 @@ func (tmgr *PhabricatorTaskManager) FindTaskByTitle(taskTitle, projectPHID strin
 		return nil, nil
 	}
 	if len(tasks) == 1 {
-		return tasks[0], nil
+		return tasks[0], tmgr.readCustomFields(client, tasks)
 	}
 	return nil, fmt.Errorf("Multiple tasks found with title '%s'", taskTitle)
 }
@@ -182,7 +205,7 @@ func (tmgr *PhabricatorTaskManager) FindTask(requirementID, requirementTitle, pr
 
 	var tasks []*entities.ManiphestTask
 	for _, task := range *res {
-		if strings.Contains(task.Title, requirementID) {
+		if hasRequirementID(task.Title, requirementID) {
 			tasks = append(tasks, task)
 		}
 	}
@@ -190,7 +213,8 @@ func (tmgr *PhabricatorTaskManager) FindTask(requirementID, requirementTitle, pr
 		return nil, nil
 	}
 	if len(tasks) == 1 {
-		return maniphestTaskToTask(tasks[0]), nil
+		task := maniphestTaskToTask(tasks[0])
+		return task, tmgr.readCustomFields(client, []*Task{task})
 	}
 	bestTask := tasks[0]
 	bestDist := levenshtein.Distance(bestTask.Title, requirementTitle)
@@ -200,22 +224,31 @@ func (tmgr *PhabricatorTaskManager) FindTask(requirementID, requirementTitle, pr
 			bestTask = task
 		}
 	}
-	return maniphestTaskToTask(bestTask), nil
+	task := maniphestTaskToTask(bestTask)
+	return task, tmgr.readCustomFields(client, []*Task{task})
 }
 
-// UpdateTask updates the Maniphest task with the given ID with the data from the given parameters
-func (tmgr *PhabricatorTaskManager) UpdateTask(taskID, title, taskBody, projectPHID string, attributes map[string]string, parentTaskIDs []string) error {
+// UpdateTask updates the given fields of the Maniphest task with the given ID with the data from the given parameters.
+// The parents are set to all the parent tasks, replacing the others, with the parents.set transaction.
+func (tmgr *PhabricatorTaskManager) UpdateTask(taskID, title, taskBody, projectPHID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
 	client, err := tmgr.getApiClient()
 	if err != nil {
 		return err
 	}
 	transactions := []requests.Transaction{
-		requests.Transaction{TransactionType: "title", Value: title},
-		requests.Transaction{TransactionType: "description", Value: taskBody},
 		requests.Transaction{TransactionType: "projects.add", Value: []string{projectPHID}},
 	}
-	if len(parentTaskIDs) > 0 {
-		transactions = append(transactions, requests.Transaction{TransactionType: "parent", Value: parentTaskIDs[0]})
+	if fields&TaskTitle != 0 {
+		transactions = append(transactions, requests.Transaction{TransactionType: "title", Value: title})
+	}
+	if fields&TaskDescription != 0 {
+		transactions = append(transactions, requests.Transaction{TransactionType: "description", Value: taskBody})
+	}
+	if fields&TaskParents != 0 && len(parentTaskIDs) > 0 {
+		transactions = append(transactions, requests.Transaction{TransactionType: "parents.set", Value: parentTaskIDs})
+	}
+	if fields&TaskAttributes != 0 {
+		transactions = append(transactions, tmgr.customFieldTransactions(attributes)...)
 	}
 	_, err = client.ManiphestEditTask(requests.EditEndpointRequest{
 		ObjectIdentifier: taskID,
@@ -224,6 +257,25 @@ func (tmgr *PhabricatorTaskManager) UpdateTask(taskID, title, taskBody, projectP
 
 }
 
+// UpdateTaskTags adds the project tags to the Maniphest task with the given ID, and removes others
+func (tmgr *PhabricatorTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	client, err := tmgr.getApiClient()
+	if err != nil {
+		return err
+	}
+	var transactions []requests.Transaction
+	if len(addTagIDs) > 0 {
+		transactions = append(transactions, requests.Transaction{TransactionType: "projects.add", Value: addTagIDs})
+	}
+	if len(removeTagIDs) > 0 {
+		transactions = append(transactions, requests.Transaction{TransactionType: "projects.remove", Value: removeTagIDs})
+	}
+	_, err = client.ManiphestEditTask(requests.EditEndpointRequest{
+		ObjectIdentifier: taskID,
+		Transactions:     transactions})
+	return err
+}
+
 // DeleteTask closes the Maniphest task with the given ID as INVALID
 func (tmgr *PhabricatorTaskManager) DeleteTask(taskID, title, projectPHID string) error {
 	client, err := tmgr.getApiClient()
@@ -242,7 +294,7 @@ func (tmgr *PhabricatorTaskManager) DeleteTask(taskID, title, projectPHID string
 
 }
 
-// CreateTask creates a new task with the given parameters
+// CreateTask creates a new task with the given parameters, the subtask of all the parent tasks
 func (tmgr *PhabricatorTaskManager) CreateTask(title, taskBody, projectPHID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
 	client, err := tmgr.getApiClient()
 	if err != nil {
@@ -255,8 +307,9 @@ func (tmgr *PhabricatorTaskManager) CreateTask(title, taskBody, projectPHID stri
 		requests.Transaction{TransactionType: "projects.set", Value: []string{projectPHID}},
 	}
 	if len(parentTaskIDs) > 0 {
-		transactions = append(transactions, requests.Transaction{TransactionType: "parent", Value: parentTaskIDs[0]})
+		transactions = append(transactions, requests.Transaction{TransactionType: "parents.set", Value: parentTaskIDs})
 	}
+	transactions = append(transactions, tmgr.customFieldTransactions(attributes)...)
 	res, err := client.ManiphestEditTask(requests.EditEndpointRequest{
 		Transactions: transactions})
 	if err != nil {
@@ -266,10 +319,194 @@ func (tmgr *PhabricatorTaskManager) CreateTask(title, taskBody, projectPHID stri
 
 }
 
+// ProjectTasks returns the Maniphest tasks of the project with the given PHID, listed a page of 100 at a time by calling
+// https://p.daedalean.ai/api/maniphest.query with the requests
+//
+//	{
+//		"projectPHIDs": ["PHID-PROJ-3e2qnmcuzxzl3iko7xdl"],
+//		"limit": 100,
+//		"offset": 0
+//	}
+func (tmgr *PhabricatorTaskManager) ProjectTasks(projectPHID string) ([]*Task, error) {
+	client, err := tmgr.getApiClient()
+	if err != nil {
+		return nil, err
+	}
+	const pageSize = 100
+	var tasks []*Task
+	for offset := uint64(0); ; offset += pageSize {
+		res, err := client.ManiphestQuery(requests.ManiphestQueryRequest{ProjectPHIDs: []string{projectPHID}, Limit: pageSize, Offset: offset})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range *res {
+			tasks = append(tasks, maniphestTaskToTask(task))
+		}
+		if len(*res) < pageSize {
+			return tasks, tmgr.readCustomFields(client, tasks)
+		}
+	}
+}
+
+// MappedAttributes returns the names of the attributes set to the custom fields.
+func (tmgr *PhabricatorTaskManager) MappedAttributes() []string {
+	return attributeNames(tmgr.Conf.CustomFields)
+}
+
+// customFieldTransactions returns the transactions setting the custom fields to the values of the attributes,
+// clearing those of the attributes the requirement doesn't have.
+func (tmgr *PhabricatorTaskManager) customFieldTransactions(attributes map[string]string) []requests.Transaction {
+	var transactions []requests.Transaction
+	for _, name := range tmgr.MappedAttributes() {
+		transactions = append(transactions, requests.Transaction{TransactionType: "custom." + tmgr.Conf.CustomFields[name], Value: attributes[name]})
+	}
+	return transactions
+}
+
+// readCustomFields reads the custom fields of the tasks back into their attributes, if any is configured, by calling
+// https://p.daedalean.ai/api/maniphest.search for 100 tasks at a time with the request
+//
+//	{
+//		"constraints": { "phids": ["PHID-TASK-ocmdhvhk2gq3cgtidwzn"] },
+//		"limit": 100
+//	}
+func (tmgr *PhabricatorTaskManager) readCustomFields(client *gonduit.Conn, tasks []*Task) error {
+	if len(tmgr.Conf.CustomFields) == 0 {
+		return nil
+	}
+	const pageSize = 100
+	for start := 0; start < len(tasks); start += pageSize {
+		page := tasks[start:]
+		if len(page) > pageSize {
+			page = page[:pageSize]
+		}
+		byPHID := map[string]*Task{}
+		var phids []string
+		for _, t := range page {
+			t.Attributes = map[string]string{}
+			byPHID[t.ID] = t
+			phids = append(phids, t.ID)
+		}
+		var res struct {
+			Data []struct {
+				PHID   string                 `json:"phid"`
+				Fields map[string]interface{} `json:"fields"`
+			} `json:"data"`
+		}
+		params := map[string]interface{}{"constraints": map[string]interface{}{"phids": phids}, "limit": pageSize}
+		if err := client.Call("maniphest.search", params, &res); err != nil {
+			return err
+		}
+		for _, d := range res.Data {
+			t, ok := byPHID[d.PHID]
+			if !ok {
+				continue
+			}
+			for name, key := range tmgr.Conf.CustomFields {
+				if v, ok := d.Fields["custom."+key]; ok && v != nil {
+					t.Attributes[name] = fmt.Sprint(v)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// TaskProgress returns the status, the owner and the comments of the Maniphest task with the given ID. The comments
+// are those of the latest transactions, found by calling https://p.daedalean.ai/api/transaction.search with the request
+//
+//	{
+//		"objectIdentifier": "PHID-TASK-ocmdhvhk2gq3cgtidwzn"
+//	}
+//
+// and the owner and the authors of the comments are named by their user names.
+func (tmgr *PhabricatorTaskManager) TaskProgress(taskID string) (*TaskProgress, error) {
+	client, err := tmgr.getApiClient()
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.ManiphestQuery(requests.ManiphestQueryRequest{PHIDs: []string{taskID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(*res) == 0 {
+		return nil, fmt.Errorf("No task with phid %s found.", taskID)
+	}
+	task := (*res)[0]
+
+	var transactions struct {
+		Data []struct {
+			Type        string `json:"type"`
+			AuthorPHID  string `json:"authorPHID"`
+			DateCreated int64  `json:"dateCreated"`
+			Comments    []struct {
+				Content struct {
+					Raw string `json:"raw"`
+				} `json:"content"`
+			} `json:"comments"`
+		} `json:"data"`
+	}
+	if err := client.Call("transaction.search", map[string]interface{}{"objectIdentifier": taskID}, &transactions); err != nil {
+		return nil, err
+	}
+	users := []string{}
+	if task.OwnerPHID != "" {
+		users = append(users, task.OwnerPHID)
+	}
+	for _, t := range transactions.Data {
+		if t.Type == "comment" {
+			users = append(users, t.AuthorPHID)
+		}
+	}
+	names, err := tmgr.userNames(client, users)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &TaskProgress{Status: task.Status, IsClosed: task.IsClosed, Assignee: names[task.OwnerPHID]}
+	// The transactions are the newest first.
+	for i := len(transactions.Data) - 1; i >= 0; i-- {
+		t := transactions.Data[i]
+		if t.Type != "comment" || len(t.Comments) == 0 {
+			continue
+		}
+		progress.Comments = append(progress.Comments, TaskComment{
+			Author: names[t.AuthorPHID],
+			Date:   time.Unix(t.DateCreated, 0).UTC(),
+			Text:   t.Comments[0].Content.Raw,
+		})
+	}
+	return progress, nil
+}
+
+// userNames returns the user names of the Phabricator users with the given PHIDs, found by calling
+// https://p.daedalean.ai/api/user.search.
+func (tmgr *PhabricatorTaskManager) userNames(client *gonduit.Conn, phids []string) (map[string]string, error) {
+	names := map[string]string{}
+	if len(phids) == 0 {
+		return names, nil
+	}
+	var res struct {
+		Data []struct {
+			PHID   string `json:"phid"`
+			Fields struct {
+				Username string `json:"username"`
+			} `json:"fields"`
+		} `json:"data"`
+	}
+	if err := client.Call("user.search", map[string]interface{}{"constraints": map[string]interface{}{"phids": phids}}, &res); err != nil {
+		return nil, err
+	}
+	for _, u := range res.Data {
+		names[u.PHID] = u.Fields.Username
+	}
+	return names, nil
+}
+
 func maniphestTaskToTask(task *entities.ManiphestTask) *Task {
 	return &Task{
-		ID: task.PHID,
-		DisplayID:               task.ID,
+		ID:               task.PHID,
+		DisplayID:        "T" + task.ID,
 		Title:            task.Title,
 		DependsOnTaskIDs: task.DependsOnTaskPHIDs,
 		Description:      task.Description,
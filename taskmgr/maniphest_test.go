@@ -0,0 +1,96 @@
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// fakeConduit is a Phabricator server recording the transactions of the maniphest.edit calls.
+type fakeConduit struct {
+	edits []maniphestEdit
+}
+
+// maniphestEdit holds the parameters of a maniphest.edit call.
+type maniphestEdit struct {
+	ObjectIdentifier string `json:"objectIdentifier"`
+	Transactions     []struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	} `json:"transactions"`
+	Conduit struct {
+		Token string `json:"token"`
+	} `json:"__conduit__"`
+}
+
+// parents returns the parents set by the edit, nil if none.
+func (e maniphestEdit) parents() []string {
+	var parents []string
+	for _, t := range e.Transactions {
+		if t.Type == "parents.set" {
+			json.Unmarshal(t.Value, &parents)
+		}
+	}
+	return parents
+}
+
+func (c *fakeConduit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var edit maniphestEdit
+	if err := json.Unmarshal([]byte(r.FormValue("params")), &edit); err != nil || r.URL.Path != "/api/maniphest.edit" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"error_code": "ERR-CONDUIT-CALL", "error_info": r.URL.Path})
+		return
+	}
+	if edit.Conduit.Token != "secret" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"error_code": "ERR-INVALID-AUTH", "error_info": "Invalid token"})
+		return
+	}
+	c.edits = append(c.edits, edit)
+	phid := edit.ObjectIdentifier
+	if phid == "" {
+		phid = fmt.Sprintf("PHID-TASK-%d", len(c.edits))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"object": map[string]interface{}{"id": len(c.edits), "phid": phid}}})
+}
+
+func TestPhabricatorTaskManager_parents(t *testing.T) {
+	conduit := &fakeConduit{}
+	server := httptest.NewServer(conduit)
+	defer server.Close()
+
+	tmgr := NewPhabricatorTaskManager(PhabricatorConf{URL: server.URL})
+	tmgr.cachedApiToken = "secret"
+
+	// A task created is the subtask of all its parents.
+	parents := []string{"PHID-TASK-sys1", "PHID-TASK-sys2"}
+	task, err := tmgr.CreateTask("REQ-0-DDLN-SWH-001: High", "High body", "PHID-PROJ-swh", nil, parents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task != "PHID-TASK-1" {
+		t.Errorf("unexpected task %q", task)
+	}
+	if len(conduit.edits) != 1 || !reflect.DeepEqual(conduit.edits[0].parents(), parents) {
+		t.Fatalf("unexpected parents set on create %v", conduit.edits)
+	}
+
+	// Updating only the parents replaces them with all the new ones.
+	parents = []string{"PHID-TASK-sys1", "PHID-TASK-sys3", "PHID-TASK-sys4"}
+	if err := tmgr.UpdateTask(task, "REQ-0-DDLN-SWH-001: High", "High body", "PHID-PROJ-swh", nil, parents, TaskParents); err != nil {
+		t.Fatal(err)
+	}
+	if len(conduit.edits) != 2 {
+		t.Fatalf("unexpected edits %v", conduit.edits)
+	}
+	update := conduit.edits[1]
+	if update.ObjectIdentifier != task || !reflect.DeepEqual(update.parents(), parents) {
+		t.Errorf("unexpected parents set on update of %q: %v", update.ObjectIdentifier, update.parents())
+	}
+	for _, tr := range update.Transactions {
+		if tr.Type == "title" || tr.Type == "description" {
+			t.Errorf("unexpected %s transaction updating only the parents", tr.Type)
+		}
+	}
+}
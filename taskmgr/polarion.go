@@ -0,0 +1,469 @@
+// @llr REQ-0-DDLN-SWL-086
+package taskmgr
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// PolarionConf is the configuration of the Polarion task manager, the "polarion" entry in attributes.json. The tasks
+// are the work items of the project, the projects of reqtraq, one per requirement level, and the tags being kept in a
+// custom field of these items.
+type PolarionConf struct {
+	// URL is that of the server, e.g. https://polarion.example.com.
+	URL string `json:"url"`
+	// Project is the ID of the project of the work items.
+	Project string `json:"project"`
+	// WorkItemTypes are the IDs of the work item types of the tasks by requirement type, e.g. "SYS":
+	// "systemRequirement", requirement by default.
+	WorkItemTypes map[string]string `json:"workItemTypes"`
+	// TagsField is the ID of the custom string field of the work items holding the projects and the tags, reqtraqTags
+	// by default.
+	TagsField string `json:"tagsField"`
+	// ParentRole is the ID of the link role of the work items to their parents, parent by default.
+	ParentRole string `json:"parentRole"`
+	// InvalidStatus is the status of the work items of the deleted requirements, obsolete by default.
+	InvalidStatus string `json:"invalidStatus"`
+	// ApprovalAttribute is the attribute of the requirements the approval status of their work items is read back
+	// into, Approval by default.
+	ApprovalAttribute string `json:"approvalAttribute"`
+}
+
+const (
+	defaultPolarionWorkItemType      = "requirement"
+	defaultPolarionTagsField         = "reqtraqTags"
+	defaultPolarionParentRole        = "parent"
+	defaultPolarionInvalidStatus     = "obsolete"
+	defaultPolarionApprovalAttribute = "Approval"
+	// polarionPageSize is the number of work items read at once, the maximum of the API.
+	polarionPageSize = 100
+)
+
+// PolarionTaskManager is the TaskManager of the work items of a Polarion ALM project, through the REST API, see
+// https://docs.sw.siemens.com/en-US/doc/230235217/PL20231017258116340.polarion_help_sc.xid2134849/xid2134871
+//
+// All the parents of a work item are linked, with the parent role by default. Polarion being the system of record of
+// the approvals, their status is read back by ApprovalStatus.
+type PolarionTaskManager struct {
+	Conf PolarionConf
+	// Token is the personal access token authenticating the requests, read from the git config if not set.
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewPolarionTaskManager returns the task manager of the work items of the configured project.
+func NewPolarionTaskManager(conf PolarionConf) *PolarionTaskManager {
+	return &PolarionTaskManager{Conf: conf}
+}
+
+// ApprovalAttributeName returns the attribute of the requirements the approval status is read back into.
+func (c PolarionConf) ApprovalAttributeName() string {
+	if c.ApprovalAttribute == "" {
+		return defaultPolarionApprovalAttribute
+	}
+	return c.ApprovalAttribute
+}
+
+// polarionResource is a resource of the JSON:API documents of the REST API, e.g. a work item.
+type polarionResource struct {
+	Type          string                          `json:"type"`
+	ID            string                          `json:"id,omitempty"`
+	Attributes    map[string]interface{}          `json:"attributes,omitempty"`
+	Relationships map[string]polarionRelationship `json:"relationships,omitempty"`
+	Links         map[string]string               `json:"links,omitempty"`
+}
+
+type polarionRelationship struct {
+	Data interface{} `json:"data"`
+}
+
+// polarionText is the value of the rich text fields, e.g. the description.
+type polarionText struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// polarionDocument is a JSON:API document of a single resource.
+type polarionDocument struct {
+	Data polarionResource `json:"data"`
+}
+
+// polarionCollection is a JSON:API document of several resources.
+type polarionCollection struct {
+	Data []polarionResource `json:"data"`
+}
+
+// attribute returns the value of the attribute of the resource, or "" if it's not set.
+func (r *polarionResource) attribute(name string) string {
+	s, _ := r.Attributes[name].(string)
+	return s
+}
+
+// shortID returns the ID of the work item within its project, e.g. WI-12 for DDLN/WI-12.
+func (r *polarionResource) shortID() string {
+	return r.ID[strings.LastIndex(r.ID, "/")+1:]
+}
+
+// linked returns the IDs of the work items of the links of the relationship, e.g. linkedWorkItems, with the given
+// role. The IDs of the links are <project>/<source>/<role>/<project>/<target>, the linked work item being the source
+// of the back links and the target of the others.
+func (r *polarionResource) linked(relationship, role string) []string {
+	links, _ := r.Relationships[relationship].Data.([]interface{})
+	var ids []string
+	for _, l := range links {
+		link, _ := l.(map[string]interface{})
+		id, _ := link["id"].(string)
+		parts := strings.Split(id, "/")
+		if len(parts) != 5 || parts[2] != role {
+			continue
+		}
+		if relationship == "backlinkedWorkItems" {
+			ids = append(ids, parts[1])
+		} else {
+			ids = append(ids, parts[4])
+		}
+	}
+	return ids
+}
+
+// polarionHTML returns the rich text of the plain text, which polarionPlainText reads back unchanged.
+func polarionHTML(s string) polarionText {
+	return polarionText{Type: "text/html", Value: strings.Replace(html.EscapeString(s), "\n", "<br/>", -1)}
+}
+
+func polarionPlainText(v interface{}) string {
+	text, _ := v.(map[string]interface{})
+	s, _ := text["value"].(string)
+	return html.UnescapeString(strings.Replace(s, "<br/>", "\n", -1))
+}
+
+// getToken reads the token from the git config, unless already set.
+func (tmgr *PolarionTaskManager) getToken() error {
+	if tmgr.Token != "" {
+		return nil
+	}
+	token, err := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.polarion-token"))
+	if err != nil {
+		return fmt.Errorf(`No Polarion token set. Please create a personal access token in the settings of your Polarion
+account and paste the token into this command
+	git config --local --replace-all daedalean.polarion-token <PASTE_TOKEN_HERE>`)
+	}
+	tmgr.Token = token
+	return nil
+}
+
+// do sends the request with the given method to the path of the REST API, see restClient.
+func (tmgr *PolarionTaskManager) do(method, path string, in, out interface{}) error {
+	if err := tmgr.getToken(); err != nil {
+		return err
+	}
+	c := restClient{Name: "Polarion", BaseURL: strings.TrimSuffix(tmgr.Conf.URL, "/") + "/polarion/rest/v1", HTTPClient: tmgr.HTTPClient,
+		Auth: func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+tmgr.Token)
+		}}
+	return c.do(method, path, in, out)
+}
+
+// workItemsPath returns the path of the work items of the project, or of the work item with the given ID.
+func (tmgr *PolarionTaskManager) workItemsPath(id string) string {
+	path := "/projects/" + url.PathEscape(tmgr.Conf.Project) + "/workitems"
+	if id != "" {
+		path += "/" + url.PathEscape(id)
+	}
+	return path
+}
+
+// workItemType returns the work item type of the task with the given title, by the type of its requirement.
+func (tmgr *PolarionTaskManager) workItemType(title string) string {
+	if m := reTaskReqType.FindStringSubmatch(title); m != nil && tmgr.Conf.WorkItemTypes[m[1]] != "" {
+		return tmgr.Conf.WorkItemTypes[m[1]]
+	}
+	return defaultPolarionWorkItemType
+}
+
+func (tmgr *PolarionTaskManager) tagsField() string {
+	if tmgr.Conf.TagsField == "" {
+		return defaultPolarionTagsField
+	}
+	return tmgr.Conf.TagsField
+}
+
+func (tmgr *PolarionTaskManager) parentRole() string {
+	if tmgr.Conf.ParentRole == "" {
+		return defaultPolarionParentRole
+	}
+	return tmgr.Conf.ParentRole
+}
+
+func (tmgr *PolarionTaskManager) invalidStatus() string {
+	if tmgr.Conf.InvalidStatus == "" {
+		return defaultPolarionInvalidStatus
+	}
+	return tmgr.Conf.InvalidStatus
+}
+
+// tags returns the projects and the tags of the work item.
+func (tmgr *PolarionTaskManager) tags(w *polarionResource) []string {
+	var tags []string
+	for _, t := range strings.Split(w.attribute(tmgr.tagsField()), ";") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// tagsValue returns the value of the tags field of the work item with the given tags added and removed.
+func (tmgr *PolarionTaskManager) tagsValue(w *polarionResource, add, remove []string) string {
+	var tags []string
+	for _, t := range tmgr.tags(w) {
+		if !contains(remove, t) && !contains(add, t) {
+			tags = append(tags, t)
+		}
+	}
+	return strings.Join(append(tags, add...), "; ")
+}
+
+// GetProject returns the tag of the project with the given name, which is the name itself, the tag being set in the
+// tags field of the work items.
+func (tmgr *PolarionTaskManager) GetProject(name string) (string, error) {
+	return name, nil
+}
+
+// CreateProject returns the tag of the project with the given name, tags having no parents in Polarion.
+func (tmgr *PolarionTaskManager) CreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+// GetOrCreateProject returns the tag of the project with the given name.
+func (tmgr *PolarionTaskManager) GetOrCreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+
+func (tmgr *PolarionTaskManager) getWorkItem(id string) (*polarionResource, error) {
+	var doc polarionDocument
+	if err := tmgr.do("GET", tmgr.workItemsPath(id)+"?fields%5Bworkitems%5D=@all", nil, &doc); err != nil {
+		return nil, err
+	}
+	return &doc.Data, nil
+}
+
+// FindTaskByID returns the Polarion work item with the given ID, e.g. WI-12.
+func (tmgr *PolarionTaskManager) FindTaskByID(id string) (*Task, error) {
+	w, err := tmgr.getWorkItem(id)
+	if err != nil {
+		return nil, err
+	}
+	return tmgr.polarionWorkItemToTask(w), nil
+}
+
+// luceneString quotes the string for the Lucene queries of Polarion.
+func luceneString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// searchTasks returns the work items of the project with the given tag whose title contains the given text. The
+// query matching words, the work items are filtered again on the exact tag and text.
+func (tmgr *PolarionTaskManager) searchTasks(text, tag string) ([]*Task, error) {
+	query := fmt.Sprintf("title:%s AND %s:%s", luceneString(text), tmgr.tagsField(), luceneString(tag))
+	var tasks []*Task
+	for page := 1; ; page++ {
+		var res polarionCollection
+		path := fmt.Sprintf("%s?query=%s&fields%%5Bworkitems%%5D=@all&page%%5Bsize%%5D=%d&page%%5Bnumber%%5D=%d",
+			tmgr.workItemsPath(""), url.QueryEscape(query), polarionPageSize, page)
+		if err := tmgr.do("GET", path, nil, &res); err != nil {
+			return nil, err
+		}
+		for i := range res.Data {
+			w := &res.Data[i]
+			if strings.Contains(w.attribute("title"), text) && contains(tmgr.tags(w), tag) {
+				tasks = append(tasks, tmgr.polarionWorkItemToTask(w))
+			}
+		}
+		if len(res.Data) < polarionPageSize {
+			return tasks, nil
+		}
+	}
+}
+
+// FindTaskByTitle returns the Polarion work item with the given title, nil if it is not found, or an error if there
+// was an error finding it. In case there are multiple work items with the given title, FindTaskByTitle returns an
+// error.
+func (tmgr *PolarionTaskManager) FindTaskByTitle(taskTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(taskTitle, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return taskWithTitle(tasks, taskTitle)
+}
+
+// FindTask returns the Polarion work item corresponding to the given Requirement ID, nil if it was not found or an
+// error if there was an error finding it. In case there are multiple work items with the given ID in the title,
+// FindTask deterministically selects the one with the title that matches as closely as possible the given title.
+func (tmgr *PolarionTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*Task, error) {
+	tasks, err := tmgr.searchTasks(requirementID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return closestTask(tasks, requirementID, requirementTitle), nil
+}
+
+// patchWorkItem sets the attributes of the work item with the given ID.
+func (tmgr *PolarionTaskManager) patchWorkItem(id string, attributes map[string]interface{}) error {
+	doc := polarionDocument{polarionResource{Type: "workitems", ID: tmgr.Conf.Project + "/" + id, Attributes: attributes}}
+	return tmgr.do("PATCH", tmgr.workItemsPath(id), doc, nil)
+}
+
+// link links the work item with the given ID to its parents.
+func (tmgr *PolarionTaskManager) link(id string, parentTaskIDs []string) error {
+	if len(parentTaskIDs) == 0 {
+		return nil
+	}
+	var links polarionCollection
+	for _, p := range parentTaskIDs {
+		links.Data = append(links.Data, polarionResource{
+			Type:       "linkedworkitems",
+			Attributes: map[string]interface{}{"role": tmgr.parentRole()},
+			Relationships: map[string]polarionRelationship{
+				"workItem": {polarionResource{Type: "workitems", ID: tmgr.Conf.Project + "/" + p}},
+			},
+		})
+	}
+	return tmgr.do("POST", tmgr.workItemsPath(id)+"/linkedworkitems", links, nil)
+}
+
+// setParents links the work item to the parents it's not linked to yet, and removes the links to its former parents.
+func (tmgr *PolarionTaskManager) setParents(w *polarionResource, parentTaskIDs []string) error {
+	id := w.shortID()
+	current := w.linked("linkedWorkItems", tmgr.parentRole())
+	for _, p := range current {
+		if !contains(parentTaskIDs, p) {
+			path := fmt.Sprintf("%s/linkedworkitems/%s/%s/%s", tmgr.workItemsPath(id), url.PathEscape(tmgr.parentRole()),
+				url.PathEscape(tmgr.Conf.Project), url.PathEscape(p))
+			if err := tmgr.do("DELETE", path, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+	var added []string
+	for _, p := range parentTaskIDs {
+		if !contains(current, p) {
+			added = append(added, p)
+		}
+	}
+	return tmgr.link(id, added)
+}
+
+// UpdateTask updates the given fields of the Polarion work item with the given ID with the data from the given
+// parameters. The attributes are left to the tags.
+func (tmgr *PolarionTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
+	w, err := tmgr.getWorkItem(taskID)
+	if err != nil {
+		return err
+	}
+	update := map[string]interface{}{tmgr.tagsField(): tmgr.tagsValue(w, []string{projectID}, nil)}
+	if fields&TaskTitle != 0 {
+		update["title"] = title
+	}
+	if fields&TaskDescription != 0 {
+		update["description"] = polarionHTML(taskBody)
+	}
+	if err := tmgr.patchWorkItem(taskID, update); err != nil {
+		return err
+	}
+	if fields&TaskParents != 0 {
+		return tmgr.setParents(w, parentTaskIDs)
+	}
+	return nil
+}
+
+// UpdateTaskTags adds the tags to the Polarion work item with the given ID, and removes others.
+func (tmgr *PolarionTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	if len(addTagIDs) == 0 && len(removeTagIDs) == 0 {
+		return nil
+	}
+	w, err := tmgr.getWorkItem(taskID)
+	if err != nil {
+		return err
+	}
+	return tmgr.patchWorkItem(taskID, map[string]interface{}{tmgr.tagsField(): tmgr.tagsValue(w, addTagIDs, removeTagIDs)})
+}
+
+// DeleteTask sets the Polarion work item with the given ID to the invalid status.
+func (tmgr *PolarionTaskManager) DeleteTask(taskID, title, projectID string) error {
+	w, err := tmgr.getWorkItem(taskID)
+	if err != nil {
+		return err
+	}
+	return tmgr.patchWorkItem(taskID, map[string]interface{}{
+		"title":          title,
+		tmgr.tagsField(): tmgr.tagsValue(w, []string{projectID}, nil),
+		"status":         tmgr.invalidStatus(),
+	})
+}
+
+// CreateTask creates a new Polarion work item with the given parameters, of the work item type of the requirement,
+// links it to its parents and returns its ID. The attributes are left to the tags.
+func (tmgr *PolarionTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	doc := polarionCollection{[]polarionResource{{Type: "workitems", Attributes: map[string]interface{}{
+		"type":           tmgr.workItemType(title),
+		"title":          title,
+		"description":    polarionHTML(taskBody),
+		tmgr.tagsField(): projectID,
+	}}}}
+	var res polarionCollection
+	if err := tmgr.do("POST", tmgr.workItemsPath(""), doc, &res); err != nil {
+		return "", err
+	}
+	if len(res.Data) != 1 {
+		return "", fmt.Errorf("Polarion created %d work items for %s", len(res.Data), title)
+	}
+	id := res.Data[0].shortID()
+	return id, tmgr.link(id, parentTaskIDs)
+}
+
+// ApprovalStatus returns the approval status of the Polarion work item with the given ID: disapproved if any approver
+// disapproved it, waiting if any approval is still expected, approved if all the approvers approved it, and "" if no
+// approval was requested.
+func (tmgr *PolarionTaskManager) ApprovalStatus(taskID string) (string, error) {
+	var res polarionCollection
+	if err := tmgr.do("GET", tmgr.workItemsPath(taskID)+"/approvals?fields%5Bworkitem_approvals%5D=@all", nil, &res); err != nil {
+		return "", err
+	}
+	statuses := map[string]bool{}
+	for i := range res.Data {
+		statuses[res.Data[i].attribute("status")] = true
+	}
+	for _, s := range []string{"disapproved", "waiting", "approved"} {
+		if statuses[s] {
+			return s, nil
+		}
+	}
+	return "", nil
+}
+
+// polarionWorkItemToTask returns the task of the work item, the tasks it depends on being the work items linked to it
+// as to their parent. The work items in the invalid status have the status "invalid", as in Phabricator, and the
+// resolved ones are closed.
+func (tmgr *PolarionTaskManager) polarionWorkItemToTask(w *polarionResource) *Task {
+	task := &Task{
+		ID:               w.shortID(),
+		DisplayID:        w.shortID(),
+		Title:            w.attribute("title"),
+		Description:      polarionPlainText(w.Attributes["description"]),
+		Status:           w.attribute("status"),
+		IsClosed:         w.attribute("resolution") != "",
+		Priority:         w.attribute("priority"),
+		URI:              w.Links["portal"],
+		DependsOnTaskIDs: w.linked("backlinkedWorkItems", tmgr.parentRole()),
+	}
+	if task.Status == tmgr.invalidStatus() {
+		task.Status, task.IsClosed = "invalid", true
+	}
+	return task
+}
@@ -0,0 +1,236 @@
+package taskmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakePolarion is a Polarion server keeping the work items of the project Avionics in memory.
+type fakePolarion struct {
+	items []*polarionResource
+	// parents are the IDs of the parents of each work item, by ID.
+	parents map[string][]string
+	// approvals are the statuses of the approvals of each work item, by ID.
+	approvals map[string][]string
+}
+
+// links returns the links of the work item with the given ID, to its parents or from its children.
+func (p *fakePolarion) links(id string) (links, backlinks []interface{}) {
+	for _, parent := range p.parents[id] {
+		links = append(links, map[string]interface{}{"type": "linkedworkitems", "id": "Avionics/" + id + "/parent/Avionics/" + parent})
+	}
+	for _, i := range p.items {
+		if child := i.shortID(); contains(p.parents[child], id) {
+			backlinks = append(backlinks, map[string]interface{}{"type": "linkedworkitems", "id": "Avionics/" + child + "/parent/Avionics/" + id})
+		}
+	}
+	return links, backlinks
+}
+
+func (p *fakePolarion) resource(w *polarionResource) *polarionResource {
+	links, backlinks := p.links(w.shortID())
+	w.Relationships = map[string]polarionRelationship{"linkedWorkItems": {links}, "backlinkedWorkItems": {backlinks}}
+	return w
+}
+
+func (p *fakePolarion) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	const prefix = "/polarion/rest/v1/projects/Avionics/workitems"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	var item *polarionResource
+	if n, err := strconv.Atoi(strings.TrimPrefix(parts[0], "WI-")); err == nil && n > 0 && n <= len(p.items) {
+		item = p.items[n-1]
+	}
+	switch {
+	case r.Method == "GET" && parts[0] == "":
+		var res polarionCollection
+		for _, i := range p.items {
+			// The query is only matched on the tags, as Polarion matches words of the title.
+			if strings.Contains(r.FormValue("query"), fmt.Sprintf("reqtraqTags:%q", i.attribute("reqtraqTags"))) {
+				res.Data = append(res.Data, *p.resource(i))
+			}
+		}
+		json.NewEncoder(w).Encode(res)
+	case r.Method == "POST" && parts[0] == "":
+		var doc polarionCollection
+		json.NewDecoder(r.Body).Decode(&doc)
+		var res polarionCollection
+		for i := range doc.Data {
+			d := &doc.Data[i]
+			id := fmt.Sprintf("WI-%d", len(p.items)+1)
+			d.ID = "Avionics/" + id
+			d.Attributes["status"] = "draft"
+			d.Links = map[string]string{"portal": "https://polarion.example.com/polarion/#/project/Avionics/workitem?id=" + id}
+			p.items = append(p.items, d)
+			res.Data = append(res.Data, polarionResource{Type: "workitems", ID: d.ID})
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(res)
+	case item == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == "GET" && len(parts) == 1:
+		json.NewEncoder(w).Encode(polarionDocument{*p.resource(item)})
+	case r.Method == "PATCH" && len(parts) == 1:
+		var doc polarionDocument
+		json.NewDecoder(r.Body).Decode(&doc)
+		if doc.Data.ID != item.ID {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for k, v := range doc.Data.Attributes {
+			item.Attributes[k] = v
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST" && len(parts) == 2 && parts[1] == "linkedworkitems":
+		var doc polarionCollection
+		json.NewDecoder(r.Body).Decode(&doc)
+		for _, l := range doc.Data {
+			target := l.Relationships["workItem"].Data.(map[string]interface{})["id"].(string)
+			if l.attribute("role") != "parent" || !strings.HasPrefix(target, "Avionics/") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			p.parents[parts[0]] = append(p.parents[parts[0]], strings.TrimPrefix(target, "Avionics/"))
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"data": []}`)
+	case r.Method == "DELETE" && len(parts) == 5 && parts[1] == "linkedworkitems":
+		var parents []string
+		for _, parent := range p.parents[parts[0]] {
+			if parent != parts[4] {
+				parents = append(parents, parent)
+			}
+		}
+		p.parents[parts[0]] = parents
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && len(parts) == 2 && parts[1] == "approvals":
+		var res polarionCollection
+		for _, s := range p.approvals[parts[0]] {
+			res.Data = append(res.Data, polarionResource{Type: "workitem_approvals", Attributes: map[string]interface{}{"status": s}})
+		}
+		json.NewEncoder(w).Encode(res)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPolarionTaskManager(t *testing.T) {
+	polarion := &fakePolarion{parents: map[string][]string{}, approvals: map[string][]string{}}
+	server := httptest.NewServer(polarion)
+	defer server.Close()
+
+	tmgr := NewPolarionTaskManager(PolarionConf{URL: server.URL, Project: "Avionics", WorkItemTypes: map[string]string{"SYS": "systemRequirement"}})
+	tmgr.Token = "secret"
+
+	all, err := tmgr.CreateTask("Implement DDLN", "Meta-task", "DDLN-SYS", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, err := tmgr.CreateTask("REQ-0-DDLN-SYS-001: System", "System body", "DDLN-SYS", nil, []string{all})
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := tmgr.CreateTask("REQ-0-DDLN-SWH-001: High", "High body", "DDLN-HLR", nil, []string{sys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tmgr.CreateTask("REQ-0-DDLN-SWH-002: Other", "Other body", "DDLN-HLR", nil, []string{sys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := tmgr.CreateTask("REQ-0-DDLN-SWL-001: Low", "Low <body>\nwith lines", "DDLN", nil, []string{high, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, i := range polarion.items {
+		types = append(types, i.attribute("type"))
+	}
+	if !reflect.DeepEqual(types, []string{"requirement", "systemRequirement", "requirement", "requirement", "requirement"}) {
+		t.Errorf("unexpected work item types %v", types)
+	}
+	if d := polarion.items[4].Attributes["description"]; !reflect.DeepEqual(d, map[string]interface{}{"type": "text/html", "value": "Low &lt;body&gt;<br/>with lines"}) {
+		t.Errorf("unexpected description %v", d)
+	}
+
+	task, err := tmgr.FindTask("REQ-0-DDLN-SWL-001", "Low", "DDLN")
+	if err != nil || task == nil || task.ID != low || task.Description != "Low <body>\nwith lines" ||
+		task.URI != "https://polarion.example.com/polarion/#/project/Avionics/workitem?id=WI-5" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTask("REQ-0-DDLN-SWL-001", "Low", "DDLN-HLR"); err != nil || task != nil {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByTitle("Implement DDLN", "DDLN-SYS"); err != nil || task == nil || task.ID != all {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTaskByID(sys); err != nil || !reflect.DeepEqual(task.DependsOnTaskIDs, []string{high, other}) {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if !reflect.DeepEqual(polarion.parents[low], []string{high, other}) {
+		t.Errorf("unexpected parents %v", polarion.parents[low])
+	}
+
+	// The link to the former parent is removed, and the one to the parent kept.
+	if err := tmgr.UpdateTask(low, "REQ-0-DDLN-SWL-001: Renamed", "Edited body", "DDLN", nil, []string{other}, TaskTitle|TaskParents); err != nil {
+		t.Fatal(err)
+	}
+	if task, err = tmgr.FindTaskByID(low); err != nil || task.Title != "REQ-0-DDLN-SWL-001: Renamed" || task.Description != "Low <body>\nwith lines" {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, _ = tmgr.FindTaskByID(high); len(task.DependsOnTaskIDs) != 0 {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+	if task, _ = tmgr.FindTaskByID(other); !reflect.DeepEqual(task.DependsOnTaskIDs, []string{low}) {
+		t.Errorf("unexpected children %v", task.DependsOnTaskIDs)
+	}
+
+	if err := tmgr.UpdateTaskTags(low, []string{"Safety critical"}, []string{"DDLN"}); err != nil {
+		t.Fatal(err)
+	}
+	if tags := polarion.items[4].attribute("reqtraqTags"); tags != "Safety critical" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+	if err := tmgr.DeleteTask(low, "REQ-0-DDLN-SWL-001: Deleted", "DDLN"); err != nil {
+		t.Fatal(err)
+	}
+	if task, _ = tmgr.FindTaskByID(low); task.Status != "invalid" || !task.IsClosed || task.Title != "REQ-0-DDLN-SWL-001: Deleted" {
+		t.Errorf("unexpected task %+v", task)
+	}
+	if tags := polarion.items[4].attribute("reqtraqTags"); tags != "Safety critical; DDLN" {
+		t.Errorf("unexpected tags %v", tags)
+	}
+
+	for _, c := range []struct {
+		approvals []string
+		status    string
+	}{
+		{nil, ""},
+		{[]string{"approved", "waiting"}, "waiting"},
+		{[]string{"approved", "disapproved", "waiting"}, "disapproved"},
+		{[]string{"approved", "approved"}, "approved"},
+	} {
+		polarion.approvals[high] = c.approvals
+		if status, err := tmgr.ApprovalStatus(high); err != nil || status != c.status {
+			t.Errorf("unexpected approval status %q of %v, %v", status, c.approvals, err)
+		}
+	}
+
+	tmgr.Token = "wrong"
+	if _, err := tmgr.FindTaskByID(low); err == nil || err.Error() != fmt.Sprintf("Polarion request GET %s/polarion/rest/v1/projects/Avionics/workitems/WI-5?fields%%5Bworkitems%%5D=@all failed: 401 Unauthorized", server.URL) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// @llr REQ-0-DDLN-SWL-082
+// @llr REQ-0-DDLN-SWL-083
+package taskmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// restClient sends the requests of the REST API of a task manager, the bodies being json.
+type restClient struct {
+	// Name is that of the task manager in the errors, e.g. Jira.
+	Name    string
+	BaseURL string
+	// ContentType is that of the bodies of the requests, application/json by default.
+	ContentType string
+	// Auth authenticates the requests.
+	Auth       func(req *http.Request)
+	HTTPClient *http.Client
+}
+
+// do sends the request with the given method to the path of the server, with the body encoded as json if not nil,
+// and decodes the response into out if not nil.
+func (c restClient) do(method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.BaseURL, "/")+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		contentType := c.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	c.Auth(req)
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s request %s %s failed: %s", c.Name, method, req.URL, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("Invalid %s response to %s %s: %v", c.Name, method, req.URL, err)
+	}
+	return nil
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+// @llr REQ-0-DDLN-SWL-091
+package taskmgr
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+)
+
+// CallConf configures the calls to the task manager of a sync of the tasks.
+type CallConf struct {
+	// Attempts is the number of attempts of a call failing transiently, 3 by default.
+	Attempts int `json:"attempts"`
+	// Backoff is the delay before the first retry, doubled before each next one, e.g. "500ms", 1s by default.
+	Backoff string `json:"backoff"`
+	// RequestsPerSecond is the maximum rate of the calls, unlimited if 0.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+}
+
+// TaskLister is implemented by the task managers listing all the tasks of a project at once, e.g. Phabricator, so
+// that a sync finds the tasks of the requirements with a few calls per project rather than one per requirement.
+type TaskLister interface {
+	// ProjectTasks returns the tasks of the project with the given ID.
+	ProjectTasks(projectID string) ([]*Task, error)
+}
+
+// reTransient matches the errors of the calls which may succeed when retried, e.g. "Jira request GET <url> failed: 503
+// Service Unavailable", or those of the connections timing out or being reset.
+var reTransient = regexp.MustCompile(`failed: (429|502|503|504)\b|(?i)timeout|timed out|connection reset|connection refused|rate limit|too many requests`)
+
+// reUnsent matches the errors of the calls which didn't reach the task manager, e.g. the connections refused, or
+// those rejected before being handled because of the rate limits.
+var reUnsent = regexp.MustCompile(`failed: 429\b|(?i)connection refused|no such host|rate limit|too many requests`)
+
+// isTransient returns whether the call failing with the given error may succeed when retried.
+func isTransient(err error) bool {
+	if e, ok := err.(net.Error); ok && (e.Timeout() || e.Temporary()) {
+		return true
+	}
+	return reTransient.MatchString(err.Error())
+}
+
+// isUnsent returns whether the call failing with the given error didn't reach the task manager, so that a call
+// creating a task or a project can be retried without creating it twice. A call timing out or whose connection is
+// reset may have been handled.
+func isUnsent(err error) bool {
+	if e, ok := err.(*net.OpError); ok && e.Op == "dial" {
+		return true
+	}
+	return reUnsent.MatchString(err.Error())
+}
+
+// NewSyncTaskManager returns the task manager of a sync, retrying the calls to the given one which fail transiently,
+// with exponential backoff, and limiting their rate as configured. If the task manager lists the tasks of the
+// projects, the tasks are found among those listed at the first find in their project.
+func NewSyncTaskManager(tmgr TaskManager, conf CallConf) (TaskManager, error) {
+	r := &retryingTaskManager{tmgr: tmgr, attempts: conf.Attempts, backoff: time.Second, sleep: time.Sleep, now: time.Now}
+	if r.attempts <= 0 {
+		r.attempts = 3
+	}
+	if conf.Backoff != "" {
+		d, err := time.ParseDuration(conf.Backoff)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid backoff of the task manager calls %q: %v", conf.Backoff, err)
+		}
+		r.backoff = d
+	}
+	if conf.RequestsPerSecond > 0 {
+		r.interval = time.Duration(float64(time.Second) / conf.RequestsPerSecond)
+	}
+	lister, ok := tmgr.(TaskLister)
+	if !ok {
+		return r, nil
+	}
+	return &batchingTaskManager{TaskManager: r, projects: map[string][]*Task{}, list: func(projectID string) (tasks []*Task, err error) {
+		err = r.call(func() (err error) { tasks, err = lister.ProjectTasks(projectID); return err })
+		return tasks, err
+	}}, nil
+}
+
+// retryingTaskManager retries the calls failing transiently, and spaces the calls by the interval, if any.
+type retryingTaskManager struct {
+	tmgr     TaskManager
+	attempts int
+	backoff  time.Duration
+	interval time.Duration
+	// last is the time of the last call.
+	last  time.Time
+	sleep func(time.Duration)
+	now   func() time.Time
+}
+
+// call calls f, which is idempotent, until it succeeds, fails with an error which isn't transient, or the attempts
+// are exhausted.
+func (m *retryingTaskManager) call(f func() error) error {
+	return m.retry(f, isTransient)
+}
+
+// callOnce calls f, which isn't idempotent, retrying it only while it fails without reaching the task manager.
+func (m *retryingTaskManager) callOnce(f func() error) error {
+	return m.retry(f, isUnsent)
+}
+
+// retry calls f until it succeeds, fails with an error which isn't retryable, or the attempts are exhausted.
+func (m *retryingTaskManager) retry(f func() error, retryable func(error) bool) error {
+	delay := m.backoff
+	for attempt := 1; ; attempt++ {
+		if m.interval > 0 {
+			if wait := m.last.Add(m.interval).Sub(m.now()); wait > 0 {
+				m.sleep(wait)
+			}
+			m.last = m.now()
+		}
+		err := f()
+		if err == nil || attempt >= m.attempts || !retryable(err) {
+			return err
+		}
+		m.sleep(delay)
+		delay *= 2
+	}
+}
+
+func (m *retryingTaskManager) GetProject(name string) (id string, err error) {
+	err = m.call(func() (err error) { id, err = m.tmgr.GetProject(name); return err })
+	return id, err
+}
+
+func (m *retryingTaskManager) CreateProject(name, parentID string) (id string, err error) {
+	err = m.callOnce(func() (err error) { id, err = m.tmgr.CreateProject(name, parentID); return err })
+	return id, err
+}
+
+func (m *retryingTaskManager) GetOrCreateProject(name, parentID string) (id string, err error) {
+	err = m.callOnce(func() (err error) { id, err = m.tmgr.GetOrCreateProject(name, parentID); return err })
+	return id, err
+}
+
+func (m *retryingTaskManager) FindTaskByID(id string) (task *Task, err error) {
+	err = m.call(func() (err error) { task, err = m.tmgr.FindTaskByID(id); return err })
+	return task, err
+}
+
+func (m *retryingTaskManager) FindTaskByTitle(taskTitle, projectID string) (task *Task, err error) {
+	err = m.call(func() (err error) { task, err = m.tmgr.FindTaskByTitle(taskTitle, projectID); return err })
+	return task, err
+}
+
+func (m *retryingTaskManager) FindTask(requirementID, requirementTitle, projectID string) (task *Task, err error) {
+	err = m.call(func() (err error) {
+		task, err = m.tmgr.FindTask(requirementID, requirementTitle, projectID)
+		return err
+	})
+	return task, err
+}
+
+func (m *retryingTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error {
+	return m.call(func() error {
+		return m.tmgr.UpdateTask(taskID, title, taskBody, projectID, attributes, parentTaskIDs, fields)
+	})
+}
+
+func (m *retryingTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	return m.call(func() error { return m.tmgr.UpdateTaskTags(taskID, addTagIDs, removeTagIDs) })
+}
+
+func (m *retryingTaskManager) DeleteTask(taskID, title, projectID string) error {
+	return m.callOnce(func() error { return m.tmgr.DeleteTask(taskID, title, projectID) })
+}
+
+func (m *retryingTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (id string, err error) {
+	err = m.callOnce(func() (err error) {
+		id, err = m.tmgr.CreateTask(title, taskBody, projectID, attributes, parentTaskIDs)
+		return err
+	})
+	return id, err
+}
+
+func (m *retryingTaskManager) MappedAttributes() []string {
+	return MappedAttributes(m.tmgr)
+}
+
+// batchingTaskManager finds the tasks among those of their project, listed at the first find in it. Each task of a
+// sync being found before it's changed, the tasks listed needn't be updated.
+type batchingTaskManager struct {
+	TaskManager
+	list     func(projectID string) ([]*Task, error)
+	projects map[string][]*Task
+}
+
+// projectTasks returns the tasks of the project with the given ID, listed once.
+func (m *batchingTaskManager) projectTasks(projectID string) ([]*Task, error) {
+	if tasks, ok := m.projects[projectID]; ok {
+		return tasks, nil
+	}
+	tasks, err := m.list(projectID)
+	if err != nil {
+		return nil, err
+	}
+	m.projects[projectID] = tasks
+	return tasks, nil
+}
+
+func (m *batchingTaskManager) FindTaskByTitle(taskTitle, projectID string) (*Task, error) {
+	tasks, err := m.projectTasks(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return taskWithTitle(tasks, taskTitle)
+}
+
+func (m *batchingTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*Task, error) {
+	tasks, err := m.projectTasks(projectID)
+	if err != nil {
+		return nil, err
+	}
+	return closestTask(tasks, requirementID, requirementTitle), nil
+}
+
+func (m *batchingTaskManager) MappedAttributes() []string {
+	return MappedAttributes(m.TaskManager)
+}
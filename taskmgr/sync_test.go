@@ -0,0 +1,142 @@
+package taskmgr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// listingTaskManager lists the tasks of the projects, failing the given number of calls first.
+type listingTaskManager struct {
+	TaskManager
+	tasks map[string][]*Task
+	fail  int
+	calls []string
+}
+
+func (m *listingTaskManager) ProjectTasks(projectID string) ([]*Task, error) {
+	m.calls = append(m.calls, "ProjectTasks "+projectID)
+	if m.fail > 0 {
+		m.fail--
+		return nil, errors.New("Phabricator request failed: 503 Service Unavailable")
+	}
+	return m.tasks[projectID], nil
+}
+
+func (m *listingTaskManager) FindTaskByID(id string) (*Task, error) {
+	m.calls = append(m.calls, "FindTaskByID "+id)
+	return nil, errors.New("No task with phid " + id + " found.")
+}
+
+func TestNewSyncTaskManager(t *testing.T) {
+	if _, err := NewSyncTaskManager(&listingTaskManager{}, CallConf{Backoff: "soon"}); err == nil {
+		t.Error("expected an invalid backoff")
+	}
+
+	inner := &listingTaskManager{tasks: map[string][]*Task{"DDLN": {
+		{ID: "1", Title: "REQ-0-DDLN-SWL-001: Low"},
+		{ID: "2", Title: "REQ-0-DDLN-SWL-002: Other"},
+		{ID: "3", Title: "REQ-0-DDLN-SWL-0010: Other"},
+	}}, fail: 2}
+	tmgr, err := NewSyncTaskManager(inner, CallConf{Attempts: 3, Backoff: "100ms", RequestsPerSecond: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The clock only advances by the sleeps.
+	var sleeps []time.Duration
+	now := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	r := tmgr.(*batchingTaskManager).TaskManager.(*retryingTaskManager)
+	r.now = func() time.Time { return now }
+	r.sleep = func(d time.Duration) { sleeps = append(sleeps, d); now = now.Add(d) }
+
+	// The listing is retried after 100ms and 200ms, each attempt 500ms after the previous one.
+	task, err := tmgr.FindTask("REQ-0-DDLN-SWL-002", "Other", "DDLN")
+	if err != nil || task == nil || task.ID != "2" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 400 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}; !reflect.DeepEqual(sleeps, want) {
+		t.Errorf("unexpected sleeps %v", sleeps)
+	}
+	// The tasks are found among those listed.
+	if task, err = tmgr.FindTaskByTitle("REQ-0-DDLN-SWL-001: Low", "DDLN"); err != nil || task == nil || task.ID != "1" {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTask("REQ-0-DDLN-SWL-003", "New", "DDLN"); err != nil || task != nil {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	// The ID is matched as the prefix of the titles.
+	if task, err = tmgr.FindTask("REQ-0-DDLN-SWL-001", "REQ-0-DDLN-SWL-0010: Other", "DDLN"); err != nil || task == nil || task.ID != "1" {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if task, err = tmgr.FindTask("REQ-0-DDLN-SWL-00", "Other", "DDLN"); err != nil || task != nil {
+		t.Errorf("unexpected task %+v, %v", task, err)
+	}
+	if want := []string{"ProjectTasks DDLN", "ProjectTasks DDLN", "ProjectTasks DDLN"}; !reflect.DeepEqual(inner.calls, want) {
+		t.Errorf("unexpected calls %v", inner.calls)
+	}
+
+	// The errors which aren't transient aren't retried.
+	inner.calls = nil
+	if _, err := tmgr.FindTaskByID("PHID-TASK-1"); err == nil || !reflect.DeepEqual(inner.calls, []string{"FindTaskByID PHID-TASK-1"}) {
+		t.Errorf("unexpected calls %v, %v", inner.calls, err)
+	}
+}
+
+// creatingTaskManager creates the tasks, failing the calls with the given errors first.
+type creatingTaskManager struct {
+	TaskManager
+	errs  []error
+	calls int
+}
+
+func (m *creatingTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	m.calls++
+	if len(m.errs) > 0 {
+		err := m.errs[0]
+		m.errs = m.errs[1:]
+		return "", err
+	}
+	return "PHID-TASK-1", nil
+}
+
+func TestNewSyncTaskManager_create(t *testing.T) {
+	inner := &creatingTaskManager{errs: []error{
+		errors.New("Post https://p.daedalean.ai/api/maniphest.edit: dial tcp 10.0.0.1:443: connect: connection refused"),
+		errors.New("GitHub request POST https://api.github.com/issues failed: 429 Too Many Requests"),
+	}}
+	tmgr, err := NewSyncTaskManager(inner, CallConf{Attempts: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmgr.(*retryingTaskManager).sleep = func(time.Duration) {}
+
+	// The creations not reaching the task manager are retried.
+	if id, err := tmgr.CreateTask("REQ-0-DDLN-SWL-001: Low", "", "DDLN", nil, nil); err != nil || id != "PHID-TASK-1" || inner.calls != 3 {
+		t.Errorf("unexpected task %q, %v after %d calls", id, err, inner.calls)
+	}
+	// Those which may have been handled aren't, not to create the task twice.
+	for _, msg := range []string{
+		"Post https://p.daedalean.ai/api/maniphest.edit: net/http: request canceled (Client.Timeout exceeded while awaiting headers)",
+		"Post https://p.daedalean.ai/api/maniphest.edit: read: connection reset by peer",
+		"Phabricator request failed: 503 Service Unavailable",
+	} {
+		inner.errs, inner.calls = []error{errors.New(msg)}, 0
+		if _, err := tmgr.CreateTask("REQ-0-DDLN-SWL-001: Low", "", "DDLN", nil, nil); err == nil || inner.calls != 1 {
+			t.Errorf("%q expected not to be retried, %v after %d calls", msg, err, inner.calls)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	for msg, transient := range map[string]bool{
+		"Jira request GET https://example.atlassian.net/rest/api/2/issue/429 failed: 404 Not Found": false,
+		"GitHub request POST https://api.github.com/issues failed: 429 Too Many Requests":           true,
+		"Post https://p.daedalean.ai/api/maniphest.edit: read: connection reset by peer":            true,
+		"ERR-CONDUIT-CORE: Task not found":                                                          false,
+	} {
+		if isTransient(errors.New(msg)) != transient {
+			t.Errorf("%q expected transient %v", msg, transient)
+		}
+	}
+}
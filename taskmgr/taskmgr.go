@@ -1,11 +1,22 @@
 // This file defines the interface for handling tasks and the generic Task object.
-// We expect specific implementation of this interface to be marked with a build tag (see maniphest.go for more details)
+// The implementations are Phabricator (see maniphest.go), Jira (see jira.go), GitHub Issues (see github.go), GitLab (see
+// gitlab.go), Azure DevOps (see azure.go) and Polarion (see polarion.go), selected by the configuration.
 package taskmgr
 
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arbovm/levenshtein"
+)
+
 // Task represents a single task in Maniphest, JIRA, Bugzilla, etc.
 type Task struct {
 	ID string
-	// This ID will be displayed in the reports; can be the same as ID
+	// This ID will be displayed in the reports, e.g. T123 in Phabricator or #123 in GitHub; can be the same as ID
 	DisplayID        string
 	Status           string
 	IsClosed         bool
@@ -14,6 +25,39 @@ type Task struct {
 	Description      string
 	URI              string
 	DependsOnTaskIDs []string
+	// Attributes are the values of the custom fields read back, by the name of the requirement attribute they're
+	// mapped to, nil if the task manager maps none, see CustomFieldMapper.
+	Attributes map[string]string
+}
+
+// reTaskReqType matches the requirement type in the title of a task, e.g. SWL in "REQ-0-DDLN-SWL-001: Title".
+var reTaskReqType = regexp.MustCompile(`^REQ-\w+-\w+-(\w+)-\d+`)
+
+// TaskFields selects fields of a task, e.g. the ones to be changed by UpdateTask.
+type TaskFields uint
+
+const (
+	TaskTitle TaskFields = 1 << iota
+	TaskDescription
+	TaskParents
+	// TaskAttributes are the custom fields of the requirement attributes, see CustomFieldMapper.
+	TaskAttributes
+
+	TaskAllFields = TaskTitle | TaskDescription | TaskParents | TaskAttributes
+)
+
+// String returns the names of the fields, e.g. "title, parents".
+func (f TaskFields) String() string {
+	var names []string
+	for _, n := range []struct {
+		field TaskFields
+		name  string
+	}{{TaskTitle, "title"}, {TaskDescription, "description"}, {TaskParents, "parents"}, {TaskAttributes, "attributes"}} {
+		if f&n.field != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ", ")
 }
 
 type TaskManager interface {
@@ -45,8 +89,13 @@ type TaskManager interface {
 	// Note: The task title is usually "<reqid> reqname", so the uniqueness requirement is not unreasonable
 	FindTask(requirementID, requirementTitle, projectID string) (*Task, error)
 
-	// UpdateTask updates the task with the given ID with the data from the given parameters
-	UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) error
+	// UpdateTask updates the given fields of the task with the given ID with the data from the given parameters. The
+	// other fields are left unchanged.
+	UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields TaskFields) error
+
+	// UpdateTaskTags adds and removes tags of the task with the given ID. Tags are projects, e.g. Phabricator project
+	// tags or JIRA labels, as found by GetOrCreateProject.
+	UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error
 
 	// DeleteTask closes the task with the given ID (or simply deletes the task if the task management tool supports
 	// task deletion)
@@ -55,3 +104,105 @@ type TaskManager interface {
 	// CreateTask creates a new task with the given parameters
 	CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error)
 }
+
+// ApprovalReader is implemented by the task managers keeping the approvals of the tasks, e.g. Polarion, whose approval
+// status is read back into the requirements.
+type ApprovalReader interface {
+	// ApprovalStatus returns the approval status of the task with the given ID, "" if no approval was requested.
+	ApprovalStatus(taskID string) (string, error)
+}
+
+// TaskProgress is the progress of the implementation of a task, as read back from the task manager.
+type TaskProgress struct {
+	Status   string
+	IsClosed bool
+	// Assignee is the name of the user the task is assigned to, empty if it's unassigned.
+	Assignee string
+	// Comments are the comments of the task, oldest first.
+	Comments []TaskComment
+}
+
+// TaskComment is a comment of a task, by the name of its author.
+type TaskComment struct {
+	Author string
+	Date   time.Time
+	Text   string
+}
+
+// ProgressReader is implemented by the task managers whose status, assignee and comments of the tasks are read back
+// into the requirements, e.g. Phabricator.
+type ProgressReader interface {
+	// TaskProgress returns the progress of the task with the given ID.
+	TaskProgress(taskID string) (*TaskProgress, error)
+}
+
+// CustomFieldMapper is implemented by the task managers writing requirement attributes to custom fields of the tasks,
+// e.g. Phabricator and Jira, and reading them back into Task.Attributes.
+type CustomFieldMapper interface {
+	// MappedAttributes returns the names of the attributes mapped to custom fields, sorted.
+	MappedAttributes() []string
+}
+
+// MappedAttributes returns the names of the attributes the task manager maps to custom fields, sorted, none if it
+// isn't a CustomFieldMapper.
+func MappedAttributes(tmgr TaskManager) []string {
+	if m, ok := tmgr.(CustomFieldMapper); ok {
+		return m.MappedAttributes()
+	}
+	return nil
+}
+
+// attributeNames returns the names of the attributes of the mapping of attribute names to custom fields, sorted.
+func attributeNames(fields map[string]string) []string {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// taskWithTitle returns the task with the given title among the tasks found, nil if there's none, or an error if
+// there are several.
+func taskWithTitle(tasks []*Task, taskTitle string) (*Task, error) {
+	var found []*Task
+	for _, t := range tasks {
+		if t.Title == taskTitle {
+			found = append(found, t)
+		}
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return nil, fmt.Errorf("Multiple tasks found with title '%s'", taskTitle)
+}
+
+// hasRequirementID returns whether the task title starts with the requirement ID, followed by a space or a colon, so
+// that an ID doesn't match the tasks of the requirements whose IDs it prefixes, e.g. those numbered 0010 for 001.
+func hasRequirementID(title, requirementID string) bool {
+	if !strings.HasPrefix(title, requirementID) {
+		return false
+	}
+	rest := title[len(requirementID):]
+	return rest == "" || rest[0] == ' ' || rest[0] == ':'
+}
+
+// closestTask returns the task whose title starts with the requirement ID among the tasks found, nil if there's none. In
+// case there are several, it deterministically selects the task with the title that matches as closely as possible
+// the given title.
+func closestTask(tasks []*Task, requirementID, requirementTitle string) *Task {
+	var best *Task
+	bestDist := 0
+	for _, t := range tasks {
+		if !hasRequirementID(t.Title, requirementID) {
+			continue
+		}
+		if dist := levenshtein.Distance(t.Title, requirementTitle); best == nil || dist < bestDist {
+			best, bestDist = t, dist
+		}
+	}
+	return best
+}
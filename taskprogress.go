@@ -0,0 +1,35 @@
+// @llr REQ-0-DDLN-SWL-090
+package main
+
+import (
+	"fmt"
+
+	"github.com/daedaleanai/reqtraq/taskmgr"
+)
+
+// ImportTaskProgress sets the progress of the requirements to that of their tasks, read back from the task manager,
+// e.g. Phabricator, so that the reports show the implementation progress tracked there alongside the status derived
+// from the children. The requirements without a task, and the deleted ones, have no progress.
+func (rg reqGraph) ImportTaskProgress(progress taskmgr.ProgressReader) error {
+	projects, err := levelProjects()
+	if err != nil {
+		return err
+	}
+	for _, r := range rg {
+		projectID, ok := projects[r.Level]
+		if !ok || r.IsDeleted() {
+			continue
+		}
+		task, err := taskmgr.TaskMgr.FindTask(r.ID, r.Title, projectID)
+		if err != nil {
+			return fmt.Errorf("Error finding task for requirement %s, caused by\n%v", r.ID, err)
+		}
+		if task == nil {
+			continue
+		}
+		if r.Progress, err = progress.TaskProgress(task.ID); err != nil {
+			return fmt.Errorf("Error reading the progress of requirement %s, caused by\n%v", r.ID, err)
+		}
+	}
+	return nil
+}
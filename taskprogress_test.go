@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProgressTaskManager also provides the progress of the tasks, by ID.
+type fakeProgressTaskManager struct {
+	fakeTaskManager
+	progress map[string]*taskmgr.TaskProgress
+}
+
+func (m *fakeProgressTaskManager) TaskProgress(taskID string) (*taskmgr.TaskProgress, error) {
+	return m.progress[taskID], nil
+}
+
+func TestReqGraph_ImportTaskProgress(t *testing.T) {
+	tm := &fakeProgressTaskManager{
+		fakeTaskManager: fakeTaskManager{tasks: map[string]*taskmgr.Task{
+			"1": {ID: "1", Title: "REQ-0-TEST-SWH-001: High"},
+			"2": {ID: "2", Title: "REQ-0-TEST-SWL-001: Low"},
+		}},
+		progress: map[string]*taskmgr.TaskProgress{
+			"1": {Status: "open", Assignee: "alice", Comments: []taskmgr.TaskComment{
+				{Author: "bob", Date: time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC), Text: "Blocked by the parser"},
+			}},
+			"2": {Status: "resolved", IsClosed: true},
+		},
+	}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	rg := audienceGraph()
+	assert.NoError(t, rg.ImportTaskProgress(tm))
+	assert.Nil(t, rg["REQ-0-TEST-SYS-001"].Progress)
+	assert.Equal(t, tm.progress["1"], rg["REQ-0-TEST-SWH-001"].Progress)
+	assert.Equal(t, tm.progress["2"], rg["REQ-0-TEST-SWL-001"].Progress)
+	assert.Nil(t, rg["code/a.go"].Progress)
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportDown(&b))
+	report := b.String()
+	assert.Contains(t, report, `<span class="label label-primary">open</span>`)
+	assert.Contains(t, report, "assigned to alice")
+	assert.Contains(t, report, "<li><strong>bob</strong> 2020-03-04: Blocked by the parser</li>")
+	assert.Contains(t, report, `<span class="label label-success">resolved</span>`)
+	assert.Contains(t, report, "unassigned")
+}
@@ -0,0 +1,281 @@
+// @llr REQ-0-DDLN-SWL-018
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+)
+
+// taskSyncState records what was last written to the task of each requirement, so only the task
+// fields whose requirement fields changed since are updated, and the edits made in the task
+// manager to the other ones are preserved.
+type taskSyncState struct {
+	Tasks map[string]taskSyncEntry `json:"tasks"`
+}
+
+// taskSyncEntry holds the hashes of the task fields last written for a requirement.
+type taskSyncEntry struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Parents     string `json:"parents"`
+	// Attributes is the hash of the attributes last written to custom fields, empty if none is mapped, see
+	// hashTaskAttributes.
+	Attributes string `json:"attributes,omitempty"`
+	// Tags are the names of the tags last set from the attributes.
+	Tags []string `json:"tags,omitempty"`
+}
+
+func newTaskSyncEntry(title, description string, parentTaskIDs []string) taskSyncEntry {
+	parents := append([]string{}, parentTaskIDs...)
+	sort.Strings(parents)
+	return taskSyncEntry{
+		Title:       hashTaskField(title),
+		Description: hashTaskField(description),
+		Parents:     hashTaskField(strings.Join(parents, "\n")),
+	}
+}
+
+func hashTaskField(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashTaskAttributes returns the hash of the values of the given attributes, those mapped to custom fields, empty if
+// there are none.
+func hashTaskAttributes(attributes map[string]string, names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, name := range names {
+		lines = append(lines, name+"="+attributes[name])
+	}
+	return hashTaskField(strings.Join(lines, "\n"))
+}
+
+// taskSyncTrailer starts the last line of the descriptions of the tasks written by a sync, holding
+// the hashes of the title and the description written, so the edits made in the task manager since
+// are detected even without the sync state, e.g. in a fresh clone or by another user.
+const taskSyncTrailer = "reqtraq-sync: "
+
+// withSyncTrailer returns the description of the task followed by the trailer of its title and
+// description.
+func withSyncTrailer(title, description string) string {
+	return description + "\n\n" + taskSyncTrailer + hashTaskField(title) + " " + hashTaskField(description)
+}
+
+// splitSyncTrailer returns the description of the task without its trailer, and the hashes of the
+// title and the description recorded in the trailer, nil if there's none.
+func splitSyncTrailer(description string) (string, *taskSyncEntry) {
+	description = strings.TrimRight(description, " \r\n")
+	i := strings.LastIndex(description, "\n\n"+taskSyncTrailer)
+	if i < 0 {
+		return description, nil
+	}
+	hashes := strings.Fields(description[i+2+len(taskSyncTrailer):])
+	if len(hashes) != 2 {
+		return description, nil
+	}
+	return description[:i], &taskSyncEntry{Title: hashes[0], Description: hashes[1]}
+}
+
+// readSyncTrailer returns the task without the trailer of its description. The task sync state
+// lacking the requirement, what was last written is taken from the trailer, and the parents from
+// the task.
+func (s *taskSyncState) readSyncTrailer(reqID string, task *taskmgr.Task) *taskmgr.Task {
+	t := *task
+	description, written := splitSyncTrailer(task.Description)
+	t.Description = description
+	if _, ok := s.Tasks[reqID]; !ok && written != nil {
+		s.Tasks[reqID] = newTaskSyncEntry("", "", task.DependsOnTaskIDs).keeping(*written, taskmgr.TaskTitle|taskmgr.TaskDescription)
+	}
+	return &t
+}
+
+// changedFields returns the fields of the task of the requirement which differ from e. All of
+// them differ if the task was never written, as nothing is known about its fields.
+func (s *taskSyncState) changedFields(reqID string, e taskSyncEntry) taskmgr.TaskFields {
+	last, ok := s.Tasks[reqID]
+	if !ok {
+		return taskmgr.TaskAllFields
+	}
+	var fields taskmgr.TaskFields
+	if e.Title != last.Title {
+		fields |= taskmgr.TaskTitle
+	}
+	if e.Description != last.Description {
+		fields |= taskmgr.TaskDescription
+	}
+	if e.Parents != last.Parents {
+		fields |= taskmgr.TaskParents
+	}
+	if e.Attributes != last.Attributes {
+		fields |= taskmgr.TaskAttributes
+	}
+	return fields
+}
+
+// editedFields returns the title, description and custom fields of the given attributes of the task
+// if they differ from what was last written, i.e. if they were edited in the task manager since.
+// Nothing is known about the tasks never written, nor about the custom fields not read back.
+func (s *taskSyncState) editedFields(reqID string, task *taskmgr.Task, attributes []string) taskmgr.TaskFields {
+	last, ok := s.Tasks[reqID]
+	if !ok {
+		return 0
+	}
+	var fields taskmgr.TaskFields
+	if hashTaskField(task.Title) != last.Title {
+		fields |= taskmgr.TaskTitle
+	}
+	if hashTaskField(task.Description) != last.Description {
+		fields |= taskmgr.TaskDescription
+	}
+	if task.Attributes != nil && last.Attributes != "" && hashTaskAttributes(task.Attributes, attributes) != last.Attributes {
+		fields |= taskmgr.TaskAttributes
+	}
+	return fields
+}
+
+// keeping returns e with the given fields as in last.
+func (e taskSyncEntry) keeping(last taskSyncEntry, fields taskmgr.TaskFields) taskSyncEntry {
+	if fields&taskmgr.TaskTitle != 0 {
+		e.Title = last.Title
+	}
+	if fields&taskmgr.TaskDescription != 0 {
+		e.Description = last.Description
+	}
+	if fields&taskmgr.TaskParents != 0 {
+		e.Parents = last.Parents
+	}
+	if fields&taskmgr.TaskAttributes != 0 {
+		e.Attributes = last.Attributes
+	}
+	return e
+}
+
+// TaskConflict is a task with fields both edited in the task manager and changed in the
+// requirement since they were last written. They are not updated unless forced.
+type TaskConflict struct {
+	ReqID  string
+	TaskID string
+	Fields taskmgr.TaskFields
+}
+
+func (c TaskConflict) String() string {
+	return fmt.Sprintf("Task T%s edited in the task manager conflicts with requirement %s: %s", c.TaskID, c.ReqID, c.Fields)
+}
+
+// taskSyncFailure is a requirement whose task failed to sync, and why.
+type taskSyncFailure struct {
+	ReqID string
+	Err   error
+}
+
+// taskSyncFailures are the requirements whose tasks failed to sync, in the order of the sync.
+type taskSyncFailures []taskSyncFailure
+
+func (f *taskSyncFailures) add(reqID string, err error) {
+	*f = append(*f, taskSyncFailure{reqID, err})
+}
+
+// error returns the summary of the failures among the given number of requirements synced.
+func (f taskSyncFailures) error(synced int) error {
+	lines := []string{fmt.Sprintf("%d of %d requirements failed to sync with the task manager:", len(f), synced)}
+	for _, failure := range f {
+		lines = append(lines, fmt.Sprintf("\t%s: %s", failure.ReqID, strings.Replace(failure.Err.Error(), "\n", " ", -1)))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
+
+// TaskSelection selects the requirements whose tasks are synced by updatetasks, as set with --doc,
+// --under, --types, the filters and --since. The zero value selects them all.
+type TaskSelection struct {
+	Scope Scope
+	// Types are the types of the requirements selected, e.g. SWH, all if empty.
+	Types  []string
+	Filter ReqFilter
+	// Diffs are the changes of the requirements since a commit, only the changed requirements
+	// being selected if not nil, see Matches.
+	Diffs map[string][]string
+}
+
+// SelectTaskIDs returns the IDs of the requirements selected, the code files left out.
+func (rg reqGraph) SelectTaskIDs(s TaskSelection) (map[string]bool, error) {
+	ids := map[string]bool{}
+	if s.Scope.IsZero() {
+		for id, r := range rg {
+			if r.Level != config.CODE {
+				ids[id] = true
+			}
+		}
+	} else {
+		var err error
+		if ids, err = rg.ScopeIDs(s.Scope); err != nil {
+			return nil, err
+		}
+	}
+	types := map[string]bool{}
+	for _, t := range s.Types {
+		types[strings.ToUpper(strings.TrimSpace(t))] = true
+	}
+	for id := range ids {
+		r := rg[id]
+		if len(types) > 0 && !types[r.ReqType()] || !r.Matches(s.Filter, s.Diffs) {
+			delete(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// taskSyncStatePath returns the path of the file holding the task sync state of the repository,
+// in its git directory so it is neither committed nor shared between clones.
+func taskSyncStatePath() (string, error) {
+	dir, err := git.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "reqtraq", "tasksync.json"), nil
+}
+
+// loadTaskSyncState reads the task sync state from the given file, empty if it doesn't exist.
+func loadTaskSyncState(path string) (*taskSyncState, error) {
+	s := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if s.Tasks == nil {
+		s.Tasks = map[string]taskSyncEntry{}
+	}
+	return s, nil
+}
+
+// save writes the task sync state to the given file.
+func (s *taskSyncState) save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
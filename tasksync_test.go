@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/taskmgr"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTaskManager keeps the tasks in memory and records the updates.
+type fakeTaskManager struct {
+	tasks   map[string]*taskmgr.Task
+	updates []string
+	tags    map[string]map[string]bool
+	err     error // returned by GetProject, e.g. if the task manager is not reachable
+}
+
+func (m *fakeTaskManager) GetProject(name string) (string, error) { return name, m.err }
+func (m *fakeTaskManager) CreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+func (m *fakeTaskManager) GetOrCreateProject(name, parentID string) (string, error) {
+	return name, nil
+}
+func (m *fakeTaskManager) FindTaskByID(id string) (*taskmgr.Task, error) { return m.tasks[id], nil }
+func (m *fakeTaskManager) FindTaskByTitle(taskTitle, projectID string) (*taskmgr.Task, error) {
+	for _, t := range m.tasks {
+		if t.Title == taskTitle {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+func (m *fakeTaskManager) FindTask(requirementID, requirementTitle, projectID string) (*taskmgr.Task, error) {
+	for _, t := range m.tasks {
+		if len(t.Title) > len(requirementID) && t.Title[:len(requirementID)+1] == requirementID+":" {
+			return t, nil
+		}
+	}
+	return nil, nil
+}
+func (m *fakeTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields taskmgr.TaskFields) error {
+	t := m.tasks[taskID]
+	if fields&taskmgr.TaskTitle != 0 {
+		t.Title = title
+	}
+	if fields&taskmgr.TaskDescription != 0 {
+		t.Description = taskBody
+	}
+	if fields&taskmgr.TaskParents != 0 {
+		t.DependsOnTaskIDs = parentTaskIDs
+	}
+	m.updates = append(m.updates, taskID+" "+fields.String())
+	return nil
+}
+func (m *fakeTaskManager) UpdateTaskTags(taskID string, addTagIDs, removeTagIDs []string) error {
+	if m.tags == nil {
+		m.tags = map[string]map[string]bool{}
+	}
+	if m.tags[taskID] == nil {
+		m.tags[taskID] = map[string]bool{}
+	}
+	for _, t := range addTagIDs {
+		m.tags[taskID][t] = true
+	}
+	for _, t := range removeTagIDs {
+		delete(m.tags[taskID], t)
+	}
+	m.updates = append(m.updates, fmt.Sprintf("%s tags +%v -%v", taskID, addTagIDs, removeTagIDs))
+	return nil
+}
+func (m *fakeTaskManager) DeleteTask(taskID, title, projectID string) error {
+	m.tasks[taskID].Status = "invalid"
+	return nil
+}
+func (m *fakeTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	id := fmt.Sprint(len(m.tasks) + 1)
+	m.tasks[id] = &taskmgr.Task{ID: id, Title: title, Description: taskBody, DependsOnTaskIDs: parentTaskIDs}
+	return id, nil
+}
+
+// taskDescription returns the description of the task without its trailer.
+func taskDescription(task *taskmgr.Task) string {
+	description, _ := splitSyncTrailer(task.Description)
+	return description
+}
+
+// assertUpdateTasks updates the tasks and checks there's no error and no conflict.
+func assertUpdateTasks(t *testing.T, rg reqGraph, filterIDs map[string]bool, force bool, state *taskSyncState) {
+	conflicts, _, err := rg.updateTasks(filterIDs, &tagger{}, force, state)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+}
+
+func TestReqGraph_UpdateTasks(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Body: "System body"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", Body: "High body", Parents: []*Req{sys}}
+	sys.Children = []*Req{high}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	all := map[string]bool{sys.ID: true, high.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+
+	assertUpdateTasks(t, rg, all, false, state)
+	// The parent of all tasks and the two requirement tasks.
+	assert.Len(t, tm.tasks, 3)
+	assert.Empty(t, tm.updates)
+	assert.Len(t, state.Tasks, 2)
+
+	// Nothing changed.
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Empty(t, tm.updates)
+
+	// A manual edit of the description is preserved when only the title changes.
+	task, _ := tm.FindTask(high.ID, high.Title, "")
+	task.Description = "Edited in the task manager"
+	high.Title = "High, renamed"
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Equal(t, []string{task.ID + " title"}, tm.updates)
+	assert.Equal(t, "REQ-0-TEST-SWH-001: High, renamed", task.Title)
+	assert.Equal(t, "Edited in the task manager", task.Description)
+
+	// Without a sync state, what was last written is read from the trailers of the descriptions, and all
+	// the fields of the tasks without one are updated.
+	tm.updates = nil
+	assertUpdateTasks(t, rg, all, false, &taskSyncState{Tasks: map[string]taskSyncEntry{}})
+	assert.Equal(t, []string{task.ID + " title, description, parents"}, tm.updates)
+	assert.Equal(t, "High body", taskDescription(task))
+}
+
+func TestReqGraph_UpdateTasks_events(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Body: "System body"}
+	rg := reqGraph{sys.ID: sys}
+	all := map[string]bool{sys.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+
+	_, events, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	// The parent of all tasks isn't a requirement.
+	assert.Equal(t, []LifecycleEvent{{Kind: "created", ID: sys.ID, Task: "2"}}, events)
+
+	sys.Title = "System, renamed"
+	_, events, err = rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []LifecycleEvent{{Kind: "updated", ID: sys.ID, Task: "2", Fields: "title"}}, events)
+
+	sys.Title = "DELETED System"
+	_, events, err = rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []LifecycleEvent{{Kind: "deleted", ID: sys.ID, Task: "2"}}, events)
+}
+
+func TestReqGraph_UpdateTasks_conflicts(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Body: "System body"}
+	rg := reqGraph{sys.ID: sys}
+	all := map[string]bool{sys.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	assertUpdateTasks(t, rg, all, false, state)
+	task, _ := tm.FindTask(sys.ID, sys.Title, "")
+
+	// Edited in the task manager, but unchanged in the requirement.
+	task.Description = "Discussion"
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Equal(t, "Discussion", task.Description)
+
+	// Edited in both, only the title is updated.
+	sys.Title = "System, renamed"
+	sys.Body = "New system body"
+	conflicts, _, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []TaskConflict{{sys.ID, task.ID, taskmgr.TaskDescription}}, conflicts)
+	assert.Equal(t, "Task T2 edited in the task manager conflicts with requirement REQ-0-TEST-SYS-001: description", conflicts[0].String())
+	assert.Equal(t, "REQ-0-TEST-SYS-001: System, renamed", task.Title)
+	assert.Equal(t, "Discussion", task.Description)
+
+	// Reported until resolved.
+	conflicts, _, err = rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Len(t, conflicts, 1)
+
+	// Overwritten when forced.
+	assertUpdateTasks(t, rg, all, true, state)
+	assert.Equal(t, "New system body", taskDescription(task))
+	assertUpdateTasks(t, rg, all, false, state)
+}
+
+func TestReqGraph_UpdateTasks_trailer(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Body: "System body"}
+	rg := reqGraph{sys.ID: sys}
+	all := map[string]bool{sys.ID: true}
+	assertUpdateTasks(t, rg, all, false, &taskSyncState{Tasks: map[string]taskSyncEntry{}})
+	task, _ := tm.FindTask(sys.ID, sys.Title, "")
+	assert.Equal(t, withSyncTrailer("REQ-0-TEST-SYS-001: System", "System body"), task.Description)
+	description, written := splitSyncTrailer(task.Description + "\n")
+	assert.Equal(t, "System body", description)
+	assert.Equal(t, &taskSyncEntry{Title: hashTaskField("REQ-0-TEST-SYS-001: System"), Description: hashTaskField("System body")}, written)
+	description, written = splitSyncTrailer("Body\n\nreqtraq-sync: edited")
+	assert.Equal(t, "Body\n\nreqtraq-sync: edited", description)
+	assert.Nil(t, written)
+
+	// The description edited above the trailer conflicts with the requirement changed, without a sync state.
+	task.Description = "Discussion\n" + task.Description
+	sys.Body = "New system body"
+	conflicts, _, err := rg.updateTasks(all, &tagger{}, false, &taskSyncState{Tasks: map[string]taskSyncEntry{}})
+	assert.NoError(t, err)
+	assert.Equal(t, []TaskConflict{{sys.ID, task.ID, taskmgr.TaskDescription}}, conflicts)
+	assert.Empty(t, tm.updates)
+
+	// The title changed, the description unedited is written again for its trailer.
+	task.Description = withSyncTrailer("REQ-0-TEST-SYS-001: System", "System body")
+	sys.Title = "System, renamed"
+	sys.Body = "System body"
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Equal(t, []string{task.ID + " title, description"}, tm.updates)
+	assert.Equal(t, withSyncTrailer("REQ-0-TEST-SYS-001: System, renamed", "System body"), task.Description)
+	tm.updates = nil
+	assertUpdateTasks(t, rg, all, false, &taskSyncState{Tasks: map[string]taskSyncEntry{}})
+	assert.Empty(t, tm.updates)
+}
+
+func TestReqGraph_UpdateTasks_tags(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	tagger, err := newTagger([]TagRule{{Attribute: "Mode"}, {Attribute: "SAFETY IMPACT", Value: "High|Hazardous", Tag: "Safety critical"}})
+	assert.NoError(t, err)
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Attributes: map[string]string{"MODE": "Automatic", "SAFETY IMPACT": "High"}}
+	rg := reqGraph{sys.ID: sys}
+	all := map[string]bool{sys.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+
+	_, _, err = rg.updateTasks(all, tagger, false, state)
+	assert.NoError(t, err)
+	task, _ := tm.FindTask(sys.ID, sys.Title, "")
+	assert.Equal(t, map[string]bool{"MODE: Automatic": true, "Safety critical": true}, tm.tags[task.ID])
+	assert.Equal(t, []string{"MODE: Automatic", "Safety critical"}, state.Tasks[sys.ID].Tags)
+
+	// Unchanged.
+	tm.updates = nil
+	_, _, err = rg.updateTasks(all, tagger, false, state)
+	assert.NoError(t, err)
+	assert.Empty(t, tm.updates)
+
+	// The tags added in the task manager are kept.
+	tm.tags[task.ID]["Manual"] = true
+	sys.Attributes["SAFETY IMPACT"] = "Higher"
+	sys.Attributes["MODE"] = "Manual"
+	_, _, err = rg.updateTasks(all, tagger, false, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{task.ID + " tags +[MODE: Manual] -[MODE: Automatic Safety critical]"}, tm.updates)
+	assert.Equal(t, map[string]bool{"MODE: Manual": true, "Manual": true}, tm.tags[task.ID])
+}
+
+func TestNewTagger(t *testing.T) {
+	_, err := newTagger([]TagRule{{Value: "High"}})
+	assert.EqualError(t, err, "Invalid tag rule: no attribute")
+	_, err = newTagger([]TagRule{{Attribute: "MODE", Value: "("}})
+	assert.Error(t, err)
+}
+
+func TestTaskSyncState_changedFields(t *testing.T) {
+	s := &taskSyncState{Tasks: map[string]taskSyncEntry{
+		"REQ-0-TEST-SWH-001": newTaskSyncEntry("title", "body", []string{"1", "2"}),
+	}}
+	assert.Equal(t, taskmgr.TaskAllFields, s.changedFields("REQ-0-TEST-SWH-002", newTaskSyncEntry("title", "body", nil)))
+	assert.Equal(t, taskmgr.TaskFields(0), s.changedFields("REQ-0-TEST-SWH-001", newTaskSyncEntry("title", "body", []string{"2", "1"})))
+	assert.Equal(t, taskmgr.TaskDescription|taskmgr.TaskParents, s.changedFields("REQ-0-TEST-SWH-001", newTaskSyncEntry("title", "new body", []string{"1"})))
+}
+
+func TestTaskSyncState_save(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tasksync")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "reqtraq", "tasksync.json")
+
+	s, err := loadTaskSyncState(path)
+	assert.NoError(t, err)
+	assert.Empty(t, s.Tasks)
+
+	s.Tasks["REQ-0-TEST-SWH-001"] = newTaskSyncEntry("title", "body", nil)
+	assert.NoError(t, s.save(path))
+	loaded, err := loadTaskSyncState(path)
+	assert.NoError(t, err)
+	assert.Equal(t, s, loaded)
+}
+
+func TestReqGraph_UpdateTasks_dryRun(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = tm
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Body: "System body"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", Body: "High body", Parents: []*Req{sys}}
+	sys.Children = []*Req{high}
+	rg := reqGraph{sys.ID: sys, high.ID: high}
+	all := map[string]bool{sys.ID: true, high.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	assertUpdateTasks(t, rg, all, false, state)
+	for _, task := range tm.tasks {
+		task.DisplayID = "T" + task.ID
+	}
+
+	// The new requirement and the changes are reported, and nothing is changed.
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Low", Body: "Low body", Parents: []*Req{high}}
+	high.Children = []*Req{low}
+	rg[low.ID] = low
+	all[low.ID] = true
+	high.Title = "High, renamed"
+	high.Body = "High body\nwith details"
+	sys.Title = "DELETED System"
+	var out bytes.Buffer
+	dry := newDryRunTaskManager(tm, &out)
+	taskmgr.TaskMgr = dry
+	conflicts, _, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, `Would mark task T2 invalid, titled "REQ-0-TEST-SYS-001: DELETED System"
+Would update the title, description of task T3
+	title: "REQ-0-TEST-SWH-001: High" -> "REQ-0-TEST-SWH-001: High, renamed"
+	description:
+	+with details
+Would create new task REQ-0-TEST-SWL-001 in project Reqtraq, parents: T3
+	title: "REQ-0-TEST-SWL-001: Low"
+`, out.String())
+	assert.Len(t, tm.tasks, 3)
+	assert.Empty(t, tm.updates)
+	assert.Equal(t, "", tm.tasks["2"].Status)
+}
+
+// failingTaskManager fails to create the task with the given title.
+type failingTaskManager struct {
+	*fakeTaskManager
+	title string
+}
+
+func (m *failingTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	if title == m.title {
+		return "", fmt.Errorf("Phabricator unavailable")
+	}
+	return m.fakeTaskManager.CreateTask(title, taskBody, projectID, attributes, parentTaskIDs)
+}
+
+func TestReqGraph_UpdateTasks_failures(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = &failingTaskManager{tm, "REQ-0-TEST-SYS-001: System"}
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Position: 1}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", Parents: []*Req{sys}}
+	sys.Children = []*Req{high}
+	other := &Req{ID: "REQ-0-TEST-SYS-002", Level: config.SYSTEM, Title: "Other", Position: 2}
+	rg := reqGraph{sys.ID: sys, high.ID: high, other.ID: other}
+	all := map[string]bool{sys.ID: true, high.ID: true, other.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+
+	// The requirement failing and its child are reported, the other is synced.
+	_, _, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.EqualError(t, err, `2 of 3 requirements failed to sync with the task manager:
+	REQ-0-TEST-SYS-001: Error creating requirement REQ-0-TEST-SYS-001, caused by Phabricator unavailable
+	REQ-0-TEST-SWH-001: Error updating requirement REQ-0-TEST-SWH-001. Parent REQ-0-TEST-SYS-001 has no corresponding task`)
+	assert.Len(t, state.Tasks, 1)
+	assert.Contains(t, state.Tasks, other.ID)
+}
+
+// fieldsTaskManager writes the mapped attributes to the custom fields of the tasks.
+type fieldsTaskManager struct {
+	*fakeTaskManager
+	names []string
+}
+
+func (m *fieldsTaskManager) MappedAttributes() []string { return m.names }
+
+func (m *fieldsTaskManager) setFields(taskID string, attributes map[string]string) {
+	m.tasks[taskID].Attributes = map[string]string{}
+	for _, name := range m.names {
+		m.tasks[taskID].Attributes[name] = attributes[name]
+	}
+}
+
+func (m *fieldsTaskManager) UpdateTask(taskID, title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string, fields taskmgr.TaskFields) error {
+	if fields&taskmgr.TaskAttributes != 0 {
+		m.setFields(taskID, attributes)
+	}
+	return m.fakeTaskManager.UpdateTask(taskID, title, taskBody, projectID, attributes, parentTaskIDs, fields)
+}
+
+func (m *fieldsTaskManager) CreateTask(title, taskBody, projectID string, attributes map[string]string, parentTaskIDs []string) (string, error) {
+	id, err := m.fakeTaskManager.CreateTask(title, taskBody, projectID, attributes, parentTaskIDs)
+	m.setFields(id, attributes)
+	return id, err
+}
+
+func TestReqGraph_UpdateTasks_attributes(t *testing.T) {
+	tm := &fakeTaskManager{tasks: map[string]*taskmgr.Task{}}
+	defer func(old taskmgr.TaskManager) { taskmgr.TaskMgr = old }(taskmgr.TaskMgr)
+	taskmgr.TaskMgr = &fieldsTaskManager{tm, []string{"SAFETY IMPACT"}}
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System", Body: "System body",
+		Attributes: map[string]string{"SAFETY IMPACT": "High", "RATIONALE": "Because"}}
+	rg := reqGraph{sys.ID: sys}
+	all := map[string]bool{sys.ID: true}
+	state := &taskSyncState{Tasks: map[string]taskSyncEntry{}}
+	assertUpdateTasks(t, rg, all, false, state)
+	task, _ := tm.FindTask(sys.ID, sys.Title, "")
+	assert.Equal(t, map[string]string{"SAFETY IMPACT": "High"}, task.Attributes)
+
+	// The attributes which aren't mapped don't update the task.
+	sys.Attributes["RATIONALE"] = "Because of the hazards"
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Empty(t, tm.updates)
+
+	sys.Attributes["SAFETY IMPACT"] = "Low"
+	assertUpdateTasks(t, rg, all, false, state)
+	assert.Equal(t, []string{task.ID + " attributes"}, tm.updates)
+	assert.Equal(t, "Low", task.Attributes["SAFETY IMPACT"])
+
+	// Edited in both, the custom field is kept.
+	task.Attributes["SAFETY IMPACT"] = "Medium"
+	sys.Attributes["SAFETY IMPACT"] = "High"
+	conflicts, _, err := rg.updateTasks(all, &tagger{}, false, state)
+	assert.NoError(t, err)
+	assert.Equal(t, []TaskConflict{{sys.ID, task.ID, taskmgr.TaskAttributes}}, conflicts)
+	assert.Equal(t, "Medium", task.Attributes["SAFETY IMPACT"])
+	assertUpdateTasks(t, rg, all, true, state)
+	assert.Equal(t, "High", task.Attributes["SAFETY IMPACT"])
+}
+
+func TestReqGraph_SelectTaskIDs(t *testing.T) {
+	rg := audienceGraph()
+	ids, err := rg.SelectTaskIDs(TaskSelection{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SYS-001": true, "REQ-0-TEST-SWH-001": true, "REQ-0-TEST-SWL-001": true}, ids)
+
+	ids, err = rg.SelectTaskIDs(TaskSelection{Types: []string{"swh", " SWL"}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SWH-001": true, "REQ-0-TEST-SWL-001": true}, ids)
+
+	ids, err = rg.SelectTaskIDs(TaskSelection{Scope: Scope{Under: "REQ-0-TEST-SWH-001"}, Filter: ReqFilter{TitleFilter: regexp.MustCompile("Low")}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SWL-001": true}, ids)
+
+	ids, err = rg.SelectTaskIDs(TaskSelection{Scope: Scope{Document: "0-TEST-211-SRD"}, Diffs: map[string][]string{}})
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	ids, err = rg.SelectTaskIDs(TaskSelection{Diffs: map[string][]string{"REQ-0-TEST-SYS-001": {"Title changed"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"REQ-0-TEST-SYS-001": true}, ids)
+
+	_, err = rg.SelectTaskIDs(TaskSelection{Scope: Scope{Under: "REQ-0-TEST-SWH-999"}})
+	assert.Error(t, err)
+}
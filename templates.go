@@ -0,0 +1,45 @@
+// @llr REQ-0-DDLN-SWL-079
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// loadReportTemplates parses the templates of the directory, the files ending in .html, over those
+// of the HTML reports. A template defined in them, e.g. {{ define "HEADER" }} for the branding,
+// replaces the built-in one of the same name, and the others can be used by the templates
+// replaced, e.g. for additional columns or sections.
+func loadReportTemplates(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.html"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("No report templates, files ending in .html, in %s", dir)
+	}
+	// The built-in templates are parsed again, as those executed can't be redefined.
+	t := template.Must(template.New("").Parse(reportTemplates))
+	if _, err := t.ParseFiles(files...); err != nil {
+		return fmt.Errorf("Invalid report templates in %s: %v", dir, err)
+	}
+	reportTmpl = t
+	return nil
+}
+
+// useReportTemplates loads the report templates of the directory of the "templates" entry of the
+// attributes json at the given path, if any, relative to the repository root.
+func useReportTemplates(confPath string) error {
+	conf, err := loadJsonConf(confPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if conf.Templates == "" {
+		return nil
+	}
+	return loadReportTemplates(filepath.Join(git.RepoPath(), conf.Templates))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadReportTemplates(t *testing.T) {
+	builtin := reportTmpl
+	defer func() { reportTmpl = builtin }()
+
+	dir, err := ioutil.TempDir("", "reqtraq-templates-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.Error(t, loadReportTemplates(dir))
+
+	footer := `{{ define "FOOTER" }}{{ template "BRANDING" }}</body></html>{{ end }}`
+	branding := `{{ define "BRANDING" }}<footer>ACME Avionics</footer>{{ end }}`
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "footer.html"), []byte(footer), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "branding.html"), []byte(branding), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("{{ define"), 0644))
+	assert.NoError(t, loadReportTemplates(dir))
+
+	var b bytes.Buffer
+	assert.NoError(t, reqGraph{}.ReportDown(&b))
+	assert.Contains(t, b.String(), "<footer>ACME Avionics</footer></body></html>")
+	assert.Contains(t, b.String(), "<h2>Top Down Tracing</h2>")
+
+	b.Reset()
+	assert.NoError(t, builtin.ExecuteTemplate(&b, "FOOTER", nil))
+	assert.NotContains(t, b.String(), "ACME")
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "broken.html"), []byte(`{{ define "HEADER" }}{{ .Missing`), 0644))
+	assert.Error(t, loadReportTemplates(dir))
+}
@@ -0,0 +1,2 @@
+// @llr REQ-0-TEST-SWL-001
+package code
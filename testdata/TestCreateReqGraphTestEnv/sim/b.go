@@ -0,0 +1,3 @@
+// @llr REQ-0-TEST-SWL-001
+// @llr REQ-0-TEST-SWL-002
+package sim
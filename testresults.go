@@ -0,0 +1,254 @@
+// @llr REQ-0-DDLN-SWL-038
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/git"
+)
+
+// TestResultsConf is an export of a test management tool, an entry of the "testresults" list in
+// attributes.json.
+type TestResultsConf struct {
+	// Format is one of testrail, xray or zephyr, see testImporters.
+	Format string
+	// Path of the export, relative to the repository root.
+	Path string
+	// URL of the test management tool, to link the test cases to, e.g.
+	// "https://example.testrail.io" or the URL of the Jira instance.
+	URL string
+}
+
+// TestCase is a test case of a test management tool verifying requirements, with the result of
+// its last execution.
+type TestCase struct {
+	ID    string
+	Title string
+	// URL of the test case in the test management tool.
+	URL  string
+	Reqs []string
+	// Result is one of Passed, Failed, Blocked, Not run, or the status exported if unknown.
+	Result string
+	// Executed is the date of the execution, as exported.
+	Executed string
+}
+
+// testImporters read the test cases of an export of a test management tool, by format, linking
+// them to the tool at the given URL.
+var testImporters = map[string]func(r io.Reader, url string) ([]*TestCase, error){
+	// The CSV export of the tests of a TestRail run, whose References hold the requirement IDs.
+	"testrail": func(r io.Reader, url string) ([]*TestCase, error) {
+		return csvTestCases(r, csvTestColumns{
+			id:       []string{"Case ID", "ID"},
+			title:    []string{"Title"},
+			reqs:     []string{"References"},
+			result:   []string{"Status"},
+			executed: []string{"Tested On"},
+		}, func(id string) string { return url + "/index.php?/cases/view/" + strings.TrimPrefix(id, "C") })
+	},
+	// The CSV export of the test executions of Zephyr Scale, whose covered issues hold the
+	// requirement IDs.
+	"zephyr": func(r io.Reader, url string) ([]*TestCase, error) {
+		return csvTestCases(r, csvTestColumns{
+			id:       []string{"Test Case Key", "Key"},
+			title:    []string{"Test Case Name", "Name"},
+			reqs:     []string{"Coverage (Issues)", "Issues", "Issue Links"},
+			result:   []string{"Execution Status", "Status"},
+			executed: []string{"Actual End Date", "Executed On"},
+		}, func(id string) string { return url + "/secure/Tests.jspa#/testCase/" + id })
+	},
+	"xray": xrayTestCases,
+}
+
+// testResults normalizes the statuses of the test management tools, by lower-case status.
+var testResults = map[string]string{
+	"passed":       "Passed",
+	"pass":         "Passed",
+	"failed":       "Failed",
+	"fail":         "Failed",
+	"blocked":      "Blocked",
+	"":             "Not run",
+	"untested":     "Not run",
+	"not run":      "Not run",
+	"not executed": "Not run",
+	"todo":         "Not run",
+}
+
+func testResult(status string) string {
+	if r, ok := testResults[strings.ToLower(strings.TrimSpace(status))]; ok {
+		return r
+	}
+	return strings.TrimSpace(status)
+}
+
+// csvTestColumns are the names of the columns of a CSV export holding the fields of the test
+// cases, the first one found being used.
+type csvTestColumns struct {
+	id, title, reqs, result, executed []string
+}
+
+// csvTestCases reads the test cases of a CSV export with a header row, each row being a test case
+// or an execution of one. The test cases are identified by the column id, which is mandatory.
+func csvTestCases(r io.Reader, columns csvTestColumns, url func(id string) string) ([]*TestCase, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	index := func(names []string) int {
+		for _, n := range names {
+			for i, h := range rows[0] {
+				if strings.EqualFold(strings.TrimSpace(h), n) {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	id := index(columns.id)
+	if id == -1 {
+		return nil, fmt.Errorf("missing column %q", columns.id[0])
+	}
+	title, reqs, result, executed := index(columns.title), index(columns.reqs), index(columns.result), index(columns.executed)
+	cell := func(row []string, i int) string {
+		if i == -1 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	var cases []*TestCase
+	for _, row := range rows[1:] {
+		tc := &TestCase{
+			ID:       cell(row, id),
+			Title:    cell(row, title),
+			Reqs:     ReReqID.FindAllString(cell(row, reqs), -1),
+			Result:   testResult(cell(row, result)),
+			Executed: cell(row, executed),
+		}
+		tc.URL = url(tc.ID)
+		cases = append(cases, tc)
+	}
+	return cases, nil
+}
+
+// xrayTestCases reads the test cases of an Xray JSON export of test execution results, whose
+// requirement keys and labels hold the requirement IDs.
+func xrayTestCases(r io.Reader, url string) ([]*TestCase, error) {
+	var results struct {
+		Tests []struct {
+			TestKey  string `json:"testKey"`
+			Status   string `json:"status"`
+			Finish   string `json:"finish"`
+			TestInfo struct {
+				Summary         string   `json:"summary"`
+				RequirementKeys []string `json:"requirementKeys"`
+				Labels          []string `json:"labels"`
+			} `json:"testInfo"`
+		} `json:"tests"`
+	}
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, err
+	}
+	var cases []*TestCase
+	for _, t := range results.Tests {
+		refs := strings.Join(append(t.TestInfo.RequirementKeys, t.TestInfo.Labels...), " ")
+		cases = append(cases, &TestCase{
+			ID:       t.TestKey,
+			Title:    t.TestInfo.Summary,
+			URL:      url + "/browse/" + t.TestKey,
+			Reqs:     ReReqID.FindAllString(refs, -1),
+			Result:   testResult(t.Status),
+			Executed: t.Finish,
+		})
+	}
+	return cases, nil
+}
+
+// importTestResults reads the test cases of the export.
+func importTestResults(conf TestResultsConf) ([]*TestCase, error) {
+	importer, ok := testImporters[strings.ToLower(conf.Format)]
+	if !ok {
+		var formats []string
+		for f := range testImporters {
+			formats = append(formats, f)
+		}
+		sort.Strings(formats)
+		return nil, withExitCode(exitUsage, fmt.Errorf("Invalid test results %s: unknown format %q, expected one of %s", conf.Path, conf.Format, strings.Join(formats, ", ")))
+	}
+	f, err := os.Open(filepath.Join(git.RepoPath(), conf.Path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cases, err := importer(f, strings.TrimSuffix(conf.URL, "/"))
+	if err != nil {
+		return nil, withExitCode(exitParse, fmt.Errorf("Error parsing test results %s: %v", conf.Path, err))
+	}
+	return cases, nil
+}
+
+// ImportTestResults adds the test cases of the exports of the test management tools to the
+// requirements they verify. A test case exported again, e.g. in the results of a later run,
+// replaces the previous one.
+func (rg reqGraph) ImportTestResults(exports []TestResultsConf) error {
+	errorResult := ""
+	byKey := map[string]*TestCase{}
+	var keys []string
+	for _, e := range exports {
+		cases, err := importTestResults(e)
+		if err != nil {
+			return err
+		}
+		for _, tc := range cases {
+			key := strings.ToLower(e.Format) + " " + tc.ID
+			if _, ok := byKey[key]; !ok {
+				keys = append(keys, key)
+			}
+			byKey[key] = tc
+			for _, id := range tc.Reqs {
+				if rg[id] == nil {
+					errorResult += "Invalid reference in test case " + tc.ID + " of " + e.Path + ": " + id + " does not exist.\n"
+				}
+			}
+		}
+	}
+	if errorResult != "" {
+		return graphError(false, errorResult)
+	}
+	for _, key := range keys {
+		tc := byKey[key]
+		for _, id := range tc.Reqs {
+			rg[id].TestCases = append(rg[id].TestCases, tc)
+		}
+	}
+	return nil
+}
+
+// VerificationStatus returns the status of the verification of r by the test cases imported:
+// Failed if one of them failed, Passed if all of them passed, Incomplete otherwise, or "" if r
+// has no test cases.
+func (r *Req) VerificationStatus() string {
+	if len(r.TestCases) == 0 {
+		return ""
+	}
+	status := "Passed"
+	for _, tc := range r.TestCases {
+		switch tc.Result {
+		case "Failed":
+			return "Failed"
+		case "Passed":
+		default:
+			status = "Incomplete"
+		}
+	}
+	return status
+}
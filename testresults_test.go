@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_ImportTestResults(t *testing.T) {
+	rg := reqGraph{}
+	for _, id := range []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-002", "REQ-0-TEST-SWL-003"} {
+		rg[id] = &Req{ID: id}
+	}
+	dir := "testdata/TestReqGraph_ImportTestResults/"
+	assert.NoError(t, rg.ImportTestResults([]TestResultsConf{
+		{Format: "testrail", Path: dir + "testrail.csv", URL: "https://example.testrail.io/"},
+		{Format: "xray", Path: dir + "xray.json", URL: "https://example.atlassian.net"},
+	}))
+	low := rg["REQ-0-TEST-SWL-001"].TestCases
+	if assert.Len(t, low, 2) {
+		assert.Equal(t, &TestCase{ID: "C12", Title: "Report dead links", URL: "https://example.testrail.io/index.php?/cases/view/12",
+			Reqs: []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWH-001"}, Result: "Passed", Executed: "2020-03-01"}, low[0])
+		assert.Equal(t, &TestCase{ID: "RQ-7", Title: "Rate limit per host", URL: "https://example.atlassian.net/browse/RQ-7",
+			Reqs: []string{"REQ-0-TEST-SWL-001"}, Result: "Passed", Executed: "2020-03-02T10:00:00+01:00"}, low[1])
+	}
+	assert.Equal(t, low[0], rg["REQ-0-TEST-SWH-001"].TestCases[0])
+	assert.Equal(t, "Passed", rg["REQ-0-TEST-SWL-001"].VerificationStatus())
+	assert.Equal(t, "Failed", rg["REQ-0-TEST-SWL-002"].VerificationStatus())
+	assert.Equal(t, "", rg["REQ-0-TEST-SWL-003"].VerificationStatus())
+
+	// The test case run again replaces the failed execution.
+	for _, r := range rg {
+		r.TestCases = nil
+	}
+	assert.NoError(t, rg.ImportTestResults([]TestResultsConf{
+		{Format: "testrail", Path: dir + "testrail.csv"},
+		{Format: "TestRail", Path: dir + "testrail-rerun.csv"},
+	}))
+	assert.Equal(t, "Incomplete", rg["REQ-0-TEST-SWL-002"].VerificationStatus())
+	assert.Equal(t, []string{"Passed", "Not run"}, []string{rg["REQ-0-TEST-SWL-002"].TestCases[0].Result, rg["REQ-0-TEST-SWL-002"].TestCases[1].Result})
+
+	err := rg.ImportTestResults([]TestResultsConf{{Format: "zephyr", Path: dir + "zephyr.csv"}})
+	assert.EqualError(t, err, "Invalid reference in test case RQ-T1 of "+dir+"zephyr.csv: REQ-0-TEST-SWL-009 does not exist.\n")
+	err = rg.ImportTestResults([]TestResultsConf{{Format: "qtest", Path: dir + "zephyr.csv"}})
+	assert.EqualError(t, err, "Invalid test results "+dir+"zephyr.csv: unknown format \"qtest\", expected one of testrail, xray, zephyr")
+}
+
+func TestCsvTestCases(t *testing.T) {
+	cases, err := testImporters["zephyr"](strings.NewReader("Key,Name,Issues,Status\nRQ-T2,Cache,\"REQ-0-TEST-SWL-001\",Blocked\n"), "https://jira")
+	assert.NoError(t, err)
+	assert.Equal(t, []*TestCase{{ID: "RQ-T2", Title: "Cache", URL: "https://jira/secure/Tests.jspa#/testCase/RQ-T2",
+		Reqs: []string{"REQ-0-TEST-SWL-001"}, Result: "Blocked"}}, cases)
+
+	_, err = testImporters["testrail"](strings.NewReader("Title,Status\nCache,Passed\n"), "")
+	assert.EqualError(t, err, `missing column "Case ID"`)
+}
@@ -0,0 +1,620 @@
+// @llr REQ-0-DDLN-SWL-001
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+func init() {
+	RegisterDocParser(DocParserFunc(ParseTomlCertdoc), ".toml")
+}
+
+// The .toml certification document format stores one document per file, using a small subset
+// of TOML (https://github.com/toml-lang/toml): comments, bare or quoted keys, basic and literal
+// strings (single and multi-line), arrays of strings and tables. The schema is:
+//
+//	[document]                  optional
+//	title = "..."               optional, the document title
+//
+//	[[requirement]]             one per requirement, in document order
+//	id = "REQ-..."              mandatory
+//	title = "..."               mandatory
+//	parents = ["REQ-...", ...]  optional
+//	body = '''...'''            optional, markdown
+//
+//	[requirement.attributes]    optional, attributes of the requirement above
+//	rationale = "..."
+//	"safety impact" = "..."
+//
+// Any other table or key is an error. Attribute names are case insensitive. Parents are given
+// as a list, hence a 'parents' attribute is not allowed.
+
+// tomlAttribute is a requirement attribute, kept in the order it was found in the document.
+type tomlAttribute struct {
+	Name  string
+	Value string
+}
+
+// tomlReq is a requirement as stored in a .toml certification document.
+type tomlReq struct {
+	ID         string
+	Title      string
+	Parents    []string
+	Body       string
+	Attributes []tomlAttribute
+	line       int // line of the [[requirement]] header
+}
+
+// tomlDoc is the contents of a .toml certification document.
+type tomlDoc struct {
+	Title        string
+	Requirements []*tomlReq
+}
+
+// ParseTomlCertdoc reads the .toml certification document f and returns its requirements, in
+// document order.
+func ParseTomlCertdoc(f string) ([]*Req, error) {
+	doc, err := readTomlDoc(f)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Reqs()
+}
+
+func readTomlDoc(f string) (*tomlDoc, error) {
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return parseTomlDoc(string(b))
+}
+
+// Reqs converts the requirements in the document into Req structures.
+func (d *tomlDoc) Reqs() ([]*Req, error) {
+	var reqs []*Req
+	for _, tr := range d.Requirements {
+		r, err := tr.toReq()
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, r)
+	}
+	return reqs, nil
+}
+
+func (tr *tomlReq) toReq() (*Req, error) {
+	if tr.ID == "" {
+		return nil, fmt.Errorf("requirement on line %d has no id", tr.line)
+	}
+	if loc := ReReqID.FindStringIndex(tr.ID); loc == nil || loc[0] != 0 || loc[1] != len(tr.ID) {
+		return nil, fmt.Errorf("malformed requirement: invalid id %q on line %d (doesn't match %q)", tr.ID, tr.line, ReReqID)
+	}
+	if tr.Title == "" {
+		return nil, fmt.Errorf("requirement %s has no title", tr.ID)
+	}
+	r := &Req{
+		ID:         tr.ID,
+		Title:      tr.Title,
+		Attributes: map[string]string{},
+	}
+	for _, a := range tr.Attributes {
+		key := strings.ToUpper(a.Name)
+		if key == "PARENT" || key == "PARENTS" {
+			return nil, fmt.Errorf("requirement %s: parents must be given in the 'parents' list, not as attribute %q", tr.ID, a.Name)
+		}
+		if _, ok := r.Attributes[key]; ok {
+			return nil, fmt.Errorf("requirement %s contains duplicate attribute: %q", tr.ID, key)
+		}
+		r.Attributes[key] = a.Value
+	}
+	for _, p := range tr.Parents {
+		if loc := ReReqID.FindStringIndex(p); loc == nil || loc[0] != 0 || loc[1] != len(p) {
+			return nil, fmt.Errorf("requirement %s parents: %q is not a requirement id", tr.ID, p)
+		}
+		r.ParentIds = append(r.ParentIds, p)
+	}
+	if len(r.ParentIds) > 0 {
+		r.Attributes["PARENTS"] = strings.Join(r.ParentIds, ", ")
+	}
+
+	level, ok := config.ReqTypeToReqLevel[r.ReqType()]
+	if !ok {
+		return nil, fmt.Errorf("Invalid request type: %q", r.ReqType())
+	}
+	r.Level = level
+	r.Body = formatBodyAsHTML(tr.Body)
+	return r, nil
+}
+
+// tomlParser is a recursive descent parser for the TOML subset used by certification documents.
+type tomlParser struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *tomlParser) next() byte {
+	c := p.src[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// skipSpace skips spaces and tabs.
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.next()
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine expects only spaces and an optional comment until the end of the current line.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpace()
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("unexpected %q after value", p.src[p.pos:p.lineEnd()])
+	}
+	return nil
+}
+
+func (p *tomlParser) lineEnd() int {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		return p.pos + i
+	}
+	return len(p.src)
+}
+
+func isBareKeyChar(c byte) bool {
+	return c == '_' || c == '-' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *tomlParser) parseKey() (string, error) {
+	switch p.peek() {
+	case '"':
+		return p.parseBasicString()
+	case '\'':
+		return p.parseLiteralString()
+	}
+	start := p.pos
+	for !p.eof() && isBareKeyChar(p.peek()) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", p.errorf("expected key, got %q", p.src[p.pos:p.lineEnd()])
+	}
+	return p.src[start:p.pos], nil
+}
+
+// parseTableHeader parses [name] or [[name]] and returns the dotted name and whether it is an
+// array of tables.
+func (p *tomlParser) parseTableHeader() (string, bool, error) {
+	p.next() // [
+	array := p.peek() == '['
+	if array {
+		p.next()
+	}
+	var parts []string
+	for {
+		p.skipSpace()
+		k, err := p.parseKey()
+		if err != nil {
+			return "", false, err
+		}
+		parts = append(parts, k)
+		p.skipSpace()
+		if p.peek() != '.' {
+			break
+		}
+		p.next()
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(p.src[p.pos:], closing) {
+		return "", false, p.errorf("malformed table header, expected %q", closing)
+	}
+	p.pos += len(closing)
+	return strings.Join(parts, "."), array, p.endOfLine()
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], `"""`):
+		return p.parseMultilineString(`"""`)
+	case strings.HasPrefix(p.src[p.pos:], `'''`):
+		return p.parseMultilineString(`'''`)
+	case p.peek() == '"':
+		return p.parseBasicString()
+	case p.peek() == '\'':
+		return p.parseLiteralString()
+	case p.peek() == '[':
+		return p.parseArray()
+	}
+	return nil, p.errorf("unsupported value %q, only strings and arrays of strings are allowed", p.src[p.pos:p.lineEnd()])
+}
+
+func (p *tomlParser) parseArray() ([]string, error) {
+	p.next() // [
+	values := []string{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("unterminated array")
+		}
+		if p.peek() == ']' {
+			p.next()
+			return values, nil
+		}
+		var (
+			s   string
+			err error
+		)
+		switch p.peek() {
+		case '"':
+			s, err = p.parseBasicString()
+		case '\'':
+			s, err = p.parseLiteralString()
+		default:
+			err = p.errorf("arrays may only contain single-line strings")
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.next()
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.next() // '
+	end := strings.IndexAny(p.src[p.pos:], "'\n")
+	if end < 0 || p.src[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.src[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.next() // "
+	var buf bytes.Buffer
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.next()
+		switch c {
+		case '"':
+			return buf.String(), nil
+		case '\\':
+			if err := p.parseEscape(&buf); err != nil {
+				return "", err
+			}
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// parseMultilineString parses a string delimited by delim (three double or single quotes). A
+// newline immediately following the opening delimiter is trimmed.
+func (p *tomlParser) parseMultilineString(delim string) (string, error) {
+	p.pos += len(delim)
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos++
+	}
+	if p.peek() == '\n' {
+		p.next()
+	}
+	var buf bytes.Buffer
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated multi-line string")
+		}
+		if strings.HasPrefix(p.src[p.pos:], delim) {
+			p.pos += len(delim)
+			return buf.String(), nil
+		}
+		c := p.next()
+		if c == '\\' && delim == `"""` {
+			if err := p.parseEscape(&buf); err != nil {
+				return "", err
+			}
+			continue
+		}
+		buf.WriteByte(c)
+	}
+}
+
+func (p *tomlParser) parseEscape(buf *bytes.Buffer) error {
+	if p.eof() {
+		return p.errorf("unterminated escape sequence")
+	}
+	c := p.next()
+	switch c {
+	case 'b':
+		buf.WriteByte('\b')
+	case 't':
+		buf.WriteByte('\t')
+	case 'n':
+		buf.WriteByte('\n')
+	case 'f':
+		buf.WriteByte('\f')
+	case 'r':
+		buf.WriteByte('\r')
+	case '"', '\\':
+		buf.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.src) {
+			return p.errorf("invalid unicode escape")
+		}
+		var r rune
+		if _, err := fmt.Sscanf(p.src[p.pos:p.pos+n], "%x", &r); err != nil {
+			return p.errorf("invalid unicode escape %q", p.src[p.pos:p.pos+n])
+		}
+		p.pos += n
+		buf.WriteRune(r)
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+// parseTomlDoc parses and validates the contents of a .toml certification document.
+func parseTomlDoc(src string) (*tomlDoc, error) {
+	p := &tomlParser{src: src, line: 1}
+	doc := &tomlDoc{}
+	table := ""
+	var current *tomlReq
+	seen := map[string]bool{} // keys seen in the current table
+
+	for {
+		p.skipBlank()
+		if p.eof() {
+			break
+		}
+		if p.peek() == '[' {
+			line := p.line
+			name, array, err := p.parseTableHeader()
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case name == "document" && !array:
+				if table != "" {
+					return nil, fmt.Errorf("line %d: [document] must be the first table", line)
+				}
+			case name == "requirement" && array:
+				current = &tomlReq{line: line}
+				doc.Requirements = append(doc.Requirements, current)
+			case name == "requirement.attributes" && !array:
+				if current == nil || table != "requirement" {
+					return nil, fmt.Errorf("line %d: [requirement.attributes] must follow a [[requirement]]", line)
+				}
+			default:
+				return nil, fmt.Errorf("line %d: unknown table %q", line, name)
+			}
+			table = name
+			seen = map[string]bool{}
+			continue
+		}
+
+		line := p.line
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.peek() != '=' {
+			return nil, p.errorf("expected '=' after key %q", key)
+		}
+		p.next()
+		p.skipSpace()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.endOfLine(); err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(key)] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line, key)
+		}
+		seen[strings.ToLower(key)] = true
+		if err := doc.set(table, current, key, value); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+	}
+	return doc, nil
+}
+
+// set assigns value to key in the given table, validating it against the schema.
+func (d *tomlDoc) set(table string, current *tomlReq, key string, value interface{}) error {
+	str, isString := value.(string)
+	switch table {
+	case "", "document":
+		if key != "title" {
+			return fmt.Errorf("unknown document key %q", key)
+		}
+		if !isString {
+			return fmt.Errorf("document title must be a string")
+		}
+		d.Title = str
+	case "requirement":
+		switch key {
+		case "id", "title", "body":
+			if !isString {
+				return fmt.Errorf("requirement %s must be a string", key)
+			}
+			switch key {
+			case "id":
+				current.ID = str
+			case "title":
+				if strings.ContainsAny(str, "\n\r") {
+					return fmt.Errorf("requirement title must be on a single line")
+				}
+				current.Title = str
+			case "body":
+				current.Body = str
+			}
+		case "parents":
+			list, ok := value.([]string)
+			if !ok {
+				return fmt.Errorf("requirement parents must be an array of strings")
+			}
+			current.Parents = list
+		default:
+			return fmt.Errorf("unknown requirement key %q", key)
+		}
+	case "requirement.attributes":
+		if !isString {
+			return fmt.Errorf("attribute %q must be a string", key)
+		}
+		current.Attributes = append(current.Attributes, tomlAttribute{key, str})
+	}
+	return nil
+}
+
+// WriteTo writes the document in its canonical form. Comments are not preserved.
+func (d *tomlDoc) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	if d.Title != "" {
+		fmt.Fprintf(&buf, "[document]\ntitle = %s\n", tomlQuote(d.Title))
+	}
+	for _, r := range d.Requirements {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[[requirement]]\nid = %s\ntitle = %s\n", tomlQuote(r.ID), tomlQuote(r.Title))
+		if len(r.Parents) > 0 {
+			var quoted []string
+			for _, p := range r.Parents {
+				quoted = append(quoted, tomlQuote(p))
+			}
+			fmt.Fprintf(&buf, "parents = [%s]\n", strings.Join(quoted, ", "))
+		}
+		if r.Body != "" {
+			fmt.Fprintf(&buf, "body = %s\n", tomlQuoteMultiline(r.Body))
+		}
+		if len(r.Attributes) > 0 {
+			buf.WriteString("\n[requirement.attributes]\n")
+			for _, a := range r.Attributes {
+				fmt.Fprintf(&buf, "%s = %s\n", tomlKey(a.Name), tomlQuote(a.Value))
+			}
+		}
+	}
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+func tomlKey(k string) string {
+	for i := 0; i < len(k); i++ {
+		if !isBareKeyChar(k[i]) {
+			return tomlQuote(k)
+		}
+	}
+	if k == "" {
+		return `""`
+	}
+	return k
+}
+
+// tomlQuote returns s as a single-line basic string.
+func tomlQuote(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\r':
+			buf.WriteString(`\r`)
+		default:
+			if unicode.IsControl(r) {
+				fmt.Fprintf(&buf, `\u%04X`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// tomlQuoteMultiline returns s as a multi-line string. Literal strings are preferred, so
+// markdown (and especially math) doesn't need any escaping.
+func tomlQuoteMultiline(s string) string {
+	if !strings.Contains(s, "'''") && !strings.HasSuffix(s, "'") {
+		return "'''\n" + s + "'''"
+	}
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"""`, `""\"`, -1)
+	if strings.HasSuffix(s, `"`) {
+		s = s[:len(s)-1] + `\"`
+	}
+	return `"""` + "\n" + s + `"""`
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseTomlDoc checks that parseTomlDoc parses the supported TOML subset and enforces the
+// certification document schema.
+func TestParseTomlDoc(t *testing.T) {
+	doc, err := parseTomlDoc(`# A comment
+[document]
+title = "Test \"document\"" # trailing comment
+
+[[requirement]]
+id = "REQ-0-TEST-SWH-001"
+title = 'Literal title'
+parents = [
+	"REQ-0-TEST-SYS-001", # first parent
+	"REQ-0-TEST-SYS-002",
+]
+body = """
+Line one\tand é
+Line two
+"""
+
+[requirement.attributes]
+Rationale = "Because."
+"Safety Impact" = "None"
+
+[[requirement]]
+id = "REQ-0-TEST-SWH-002"
+title = "Second"
+body = '''
+$\frac{a}{b}$
+'''
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &tomlDoc{
+		Title: `Test "document"`,
+		Requirements: []*tomlReq{
+			{
+				ID:      "REQ-0-TEST-SWH-001",
+				Title:   "Literal title",
+				Parents: []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SYS-002"},
+				Body:    "Line one\tand é\nLine two\n",
+				Attributes: []tomlAttribute{
+					{"Rationale", "Because."},
+					{"Safety Impact", "None"},
+				},
+				line: 5,
+			},
+			{
+				ID:    "REQ-0-TEST-SWH-002",
+				Title: "Second",
+				Body:  "$\\frac{a}{b}$\n",
+				line:  21,
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, doc) {
+		t.Errorf("\nexpected %#v,\n     got %#v", expected, doc)
+	}
+
+	for _, c := range []struct {
+		content, expectedError string
+	}{
+		{"[[requirement]]\nid = 1\n", "line 2: unsupported value"},
+		{"[[requirement]]\nid = \"REQ-0-TEST-SYS-001\"\nid = \"REQ-0-TEST-SYS-002\"\n", "line 3: duplicate key \"id\""},
+		{"[[requirement]]\nfoo = \"bar\"\n", "line 2: unknown requirement key \"foo\""},
+		{"[requirement.attributes]\nfoo = \"bar\"\n", "line 1: [requirement.attributes] must follow a [[requirement]]"},
+		{"[[requirement]]\n[requirements]\n", "line 2: unknown table \"requirements\""},
+		{"[[requirement]]\ntitle = \"unterminated\n", "line 2: unterminated string"},
+		{"[[requirement]]\nparents = [\"a\" \"b\"]\n", "line 2: expected ',' or ']' in array"},
+		{"[[requirement]]\nbody = '''\nno end\n", "unterminated multi-line string"},
+		{"[[requirement]]\ntitle = \"a\" b\n", "line 2: unexpected \"b\" after value"},
+	} {
+		_, err := parseTomlDoc(c.content)
+		if err == nil {
+			t.Errorf("content `%s` does not generate error `%s`", c.content, c.expectedError)
+			continue
+		}
+		assert.Contains(t, err.Error(), c.expectedError)
+	}
+}
+
+// TestTomlDoc_ToReq checks the schema checks done when converting to Req structures.
+func TestTomlDoc_ToReq(t *testing.T) {
+	for _, c := range []struct {
+		content, expectedError string
+	}{
+		{"[[requirement]]\ntitle = \"No ID\"\n", "requirement on line 1 has no id"},
+		{"[[requirement]]\nid = \"REQ-0-TEST-SYS-001 Title\"\n", "invalid id \"REQ-0-TEST-SYS-001 Title\""},
+		{"[[requirement]]\nid = \"REQ-0-TEST-SYS-001\"\n", "requirement REQ-0-TEST-SYS-001 has no title"},
+		{"[[requirement]]\nid = \"REQ-0-TEST-SWH-001\"\ntitle = \"T\"\nparents = [\"SYS-1\"]\n", "\"SYS-1\" is not a requirement id"},
+		{"[[requirement]]\nid = \"REQ-0-TEST-SWH-001\"\ntitle = \"T\"\n[requirement.attributes]\nparents = \"REQ-0-TEST-SYS-001\"\n", "parents must be given in the 'parents' list"},
+		{"[[requirement]]\nid = \"REQ-0-TEST-SWH-001\"\ntitle = \"T\"\n[requirement.attributes]\nmode = \"a\"\nMODE = \"b\"\n", "duplicate key \"MODE\""},
+	} {
+		doc, err := parseTomlDoc(c.content)
+		if err == nil {
+			_, err = doc.Reqs()
+		}
+		if err == nil {
+			t.Errorf("content `%s` does not generate error `%s`", c.content, c.expectedError)
+			continue
+		}
+		assert.Contains(t, err.Error(), c.expectedError)
+	}
+}
+
+// TestTomlDoc_RoundTrip checks that writing a parsed document produces the canonical form, and
+// that the canonical form is stable.
+func TestTomlDoc_RoundTrip(t *testing.T) {
+	const f = "testdata/valid_system_requirement/123-TEST-100-ORD.toml"
+	canonical, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := readTomlDoc(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(canonical), buf.String())
+
+	// Values needing escaping survive the round trip.
+	doc = &tomlDoc{Requirements: []*tomlReq{{
+		ID:         "REQ-0-TEST-SYS-001",
+		Title:      `A "quoted" \ title`,
+		Body:       "Has ''' and a backslash \\ and \"\"\" quotes\"",
+		Attributes: []tomlAttribute{{"Safety Impact", "None"}},
+		line:       1,
+	}}}
+	buf.Reset()
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseTomlDoc(buf.String())
+	if err != nil {
+		t.Fatalf("%v in\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(doc, parsed) {
+		t.Errorf("\nexpected %#v,\n     got %#v", doc, parsed)
+	}
+}
@@ -0,0 +1,102 @@
+// @llr REQ-0-DDLN-SWL-059
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+func init() {
+	exporters["trace"] = exportTraceMatrix
+}
+
+// traceHeader is the header of the trace matrix, with two columns per level: the requirement or
+// the code file, and its status or the hash of the code file.
+var traceHeader = []string{
+	"System Requirement", "Status",
+	"High-Level Requirement", "Status",
+	"Low-Level Requirement", "Status",
+	"Code", "Hash",
+}
+
+// traceColumn is the index in the trace matrix of the columns of the requirements of each level.
+var traceColumn = map[config.RequirementLevel]int{
+	config.SYSTEM: 0,
+	config.HIGH:   2,
+	config.LOW:    4,
+	config.CODE:   6,
+}
+
+// exportTraceMatrix writes the classic traceability matrix from the system requirements down to
+// the code as CSV, with a row per chain of links, e.g. from a system requirement to a code file.
+// The chains start at the requirements without parents, so those not traced to a system
+// requirement have the first columns empty, and stop at those without children. The deleted
+// requirements are left out.
+func exportTraceMatrix(rg reqGraph, w io.Writer) error {
+	var roots []*Req
+	for _, r := range rg {
+		if r.Level != config.CODE && !r.IsDeleted() && len(r.Parents) == 0 {
+			roots = append(roots, r)
+		}
+	}
+	sortTraced(roots)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(traceHeader); err != nil {
+		return err
+	}
+	var walk func(r *Req, row []string) error
+	walk = func(r *Req, row []string) error {
+		row = append([]string{}, row...)
+		i := traceColumn[r.Level]
+		row[i] = r.ID
+		if r.Level == config.CODE {
+			row[i+1] = r.FileHash
+		} else {
+			row[i+1] = r.Status.String()
+		}
+		var children []*Req
+		for _, c := range r.Children {
+			if !c.IsDeleted() {
+				children = append(children, c)
+			}
+		}
+		if len(children) == 0 {
+			return cw.Write(row)
+		}
+		sortTraced(children)
+		for _, c := range children {
+			if err := walk(c, row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, r := range roots {
+		if err := walk(r, make([]string, len(traceHeader))); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// sortTraced sorts the requirements by certification document and by position, then the code
+// files by path.
+func sortTraced(reqs []*Req) {
+	sort.Sort(byTraceOrder(reqs))
+}
+
+type byTraceOrder []*Req
+
+func (a byTraceOrder) Len() int      { return len(a) }
+func (a byTraceOrder) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byTraceOrder) Less(i, j int) bool {
+	if ci, cj := a[i].Level == config.CODE, a[j].Level == config.CODE; ci || cj {
+		return !ci || (cj && a[i].ID < a[j].ID)
+	}
+	return byModulePosition(a).Less(i, j)
+}
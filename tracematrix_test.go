@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportTraceMatrix(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Position: 1}
+	high1 := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 2,
+		ParentIds: []string{sys.ID}}
+	high2 := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 1,
+		ParentIds: []string{sys.ID}}
+	deleted := &Req{ID: "REQ-0-TEST-SWH-003", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 3,
+		Title: "DELETED", ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 1,
+		ParentIds: []string{high1.ID}}
+	orphan := &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 2}
+	b := &Req{ID: "code/b.go", Level: config.CODE, Path: "/repo/code/b.go", FileHash: "sha1:bb", ParentIds: []string{low.ID}}
+	a := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", FileHash: "sha1:aa", ParentIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high1.ID: high1, high2.ID: high2, deleted.ID: deleted, low.ID: low, a.Path: a, b.Path: b}
+	assert.NoError(t, rg.Resolve())
+	// Added once resolved, as it has no parents.
+	rg[orphan.ID] = orphan
+
+	var w bytes.Buffer
+	assert.NoError(t, rg.Export("trace", &w))
+	assert.Equal(t, `System Requirement,Status,High-Level Requirement,Status,Low-Level Requirement,Status,Code,Hash
+REQ-0-TEST-SYS-001,STARTED,REQ-0-TEST-SWH-002,NOT STARTED,,,,
+REQ-0-TEST-SYS-001,STARTED,REQ-0-TEST-SWH-001,COMPLETED,REQ-0-TEST-SWL-001,COMPLETED,code/a.go,sha1:aa
+REQ-0-TEST-SYS-001,STARTED,REQ-0-TEST-SWH-001,COMPLETED,REQ-0-TEST-SWL-001,COMPLETED,code/b.go,sha1:bb
+,,,,REQ-0-TEST-SWL-002,NOT STARTED,,
+`, w.String())
+}
@@ -0,0 +1,90 @@
+// @llr REQ-0-DDLN-SWL-078
+package main
+
+import (
+	"io"
+	"sort"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// verificationAttribute is the attribute holding the verification method of a requirement.
+const verificationAttribute = "VERIFICATION"
+
+// The statuses of the verification evidence, in addition to those of VerificationStatus.
+const (
+	evidenceNoResults   = "No results"
+	evidenceNotVerified = "Not verified"
+)
+
+// VerificationEvidence is the evidence of the verification of a requirement: the code files
+// verifying it, with @verifies annotations, and the test cases imported from the test management
+// tools, with the results of their last execution.
+type VerificationEvidence struct {
+	Req *Req
+	// Method is the verification method, the VERIFICATION attribute.
+	Method    string
+	Files     []*Req
+	TestCases []*TestCase
+	// Status is the verification status of the test cases, see VerificationStatus, or "No
+	// results" if only code files verify the requirement, or "Not verified" if nothing does.
+	Status string
+}
+
+// VerificationEvidence returns the verification evidence of the requirements of the graph with a
+// VERIFICATION attribute matching the filter and the diffs, sorted by ID. The deleted requirements
+// are left out.
+func (rg reqGraph) VerificationEvidence(f ReqFilter, diffs map[string][]string) []*VerificationEvidence {
+	var evidence []*VerificationEvidence
+	for _, r := range rg {
+		method, ok := r.Attributes[verificationAttribute]
+		if !ok || r.Level == config.CODE || r.IsDeleted() || !r.Matches(f, diffs) {
+			continue
+		}
+		e := &VerificationEvidence{Req: r, Method: method, Files: r.VerifiedBy, TestCases: r.TestCases, Status: r.VerificationStatus()}
+		if e.Status == "" {
+			e.Status = evidenceNotVerified
+			if len(e.Files) > 0 {
+				e.Status = evidenceNoResults
+			}
+		}
+		evidence = append(evidence, e)
+	}
+	sort.Sort(byEvidenceID(evidence))
+	return evidence
+}
+
+// byEvidenceID sorts the verification evidence by the ID of the requirement.
+type byEvidenceID []*VerificationEvidence
+
+func (a byEvidenceID) Len() int           { return len(a) }
+func (a byEvidenceID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byEvidenceID) Less(i, j int) bool { return a[i].Req.ID < a[j].Req.ID }
+
+// evidenceCount is the number of requirements with a verification status.
+type evidenceCount struct {
+	Status string
+	Count  int
+}
+
+type verificationReportData struct {
+	Evidence []*VerificationEvidence
+	Counts   []evidenceCount
+	Filter   ReqFilter
+}
+
+// ReportVerification writes an HTML report of the verification evidence of the requirements
+// matching the filter and the diffs, with the number of requirements by verification status, for
+// the verification traceability of the accomplishment summary.
+func (rg reqGraph) ReportVerification(w io.Writer, f ReqFilter, diffs map[string][]string) error {
+	evidence := rg.VerificationEvidence(f, diffs)
+	byStatus := map[string]int{}
+	for _, e := range evidence {
+		byStatus[e.Status]++
+	}
+	var counts []evidenceCount
+	for _, s := range []string{"Passed", "Failed", "Incomplete", evidenceNoResults, evidenceNotVerified} {
+		counts = append(counts, evidenceCount{s, byStatus[s]})
+	}
+	return reportTmpl.ExecuteTemplate(w, "VERIFICATION", verificationReportData{evidence, counts, f})
+}
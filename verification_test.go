@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReqGraph_VerificationEvidence(t *testing.T) {
+	rg := reqGraph{}
+	for _, r := range []*Req{
+		{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Passed", Attributes: map[string]string{"VERIFICATION": "Test"}},
+		{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "Failed", Attributes: map[string]string{"VERIFICATION": "Test"}},
+		{ID: "REQ-0-TEST-SWL-003", Level: config.LOW, Title: "Unit tested", Attributes: map[string]string{"VERIFICATION": "Unit test"}},
+		{ID: "REQ-0-TEST-SWL-004", Level: config.LOW, Title: "Untested", Attributes: map[string]string{"VERIFICATION": "Review"}},
+		{ID: "REQ-0-TEST-SWL-005", Level: config.LOW, Title: "No method"},
+		{ID: "REQ-0-TEST-SWL-006", Level: config.LOW, Title: "DELETED", Attributes: map[string]string{"VERIFICATION": "Test"}},
+	} {
+		rg[r.ID] = r
+	}
+	test := &Req{ID: "a_test.go", Level: config.CODE, VerifiesIds: []string{"REQ-0-TEST-SWL-001", "REQ-0-TEST-SWL-003"}}
+	rg["/repo/a_test.go"] = test
+	rg["REQ-0-TEST-SWL-001"].VerifiedBy = []*Req{test}
+	rg["REQ-0-TEST-SWL-003"].VerifiedBy = []*Req{test}
+	rg["REQ-0-TEST-SWL-001"].TestCases = []*TestCase{{ID: "C1", URL: "https://example.testrail.io/index.php?/cases/view/1", Result: "Passed", Executed: "2020-03-01"}}
+	rg["REQ-0-TEST-SWL-002"].TestCases = []*TestCase{{ID: "C2", Result: "Passed"}, {ID: "C3", Result: "Failed"}}
+
+	evidence := rg.VerificationEvidence(nil, nil)
+	var statuses []string
+	for _, e := range evidence {
+		statuses = append(statuses, e.Req.ID+" "+e.Status)
+	}
+	assert.Equal(t, []string{
+		"REQ-0-TEST-SWL-001 Passed",
+		"REQ-0-TEST-SWL-002 Failed",
+		"REQ-0-TEST-SWL-003 No results",
+		"REQ-0-TEST-SWL-004 Not verified",
+	}, statuses)
+	assert.Equal(t, "Unit test", evidence[2].Method)
+	assert.Equal(t, []*Req{test}, evidence[2].Files)
+
+	var b bytes.Buffer
+	assert.NoError(t, rg.ReportVerification(&b, nil, nil))
+	report := b.String()
+	assert.Contains(t, report, "Passed: 1&nbsp;&nbsp; Failed: 1&nbsp;&nbsp; Incomplete: 0&nbsp;&nbsp; No results: 1&nbsp;&nbsp; Not verified: 1")
+	assert.Contains(t, report, `<a href="https://example.testrail.io/index.php?/cases/view/1" target="_blank" title="">C1</a> Passed (2020-03-01)`)
+	assert.Contains(t, report, `<span class="label label-danger">Not verified</span>`)
+	assert.NotContains(t, report, "REQ-0-TEST-SWL-005")
+}
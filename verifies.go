@@ -0,0 +1,94 @@
+// @llr REQ-0-DDLN-SWL-039
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// reVerifies matches the references of the code verifying requirements, e.g. in tests,
+// "@verifies <ID>" for the whole requirement or "@verifies <ID>#AC2" for its second acceptance
+// criterion, with the tags of the verifies kind, see compileReferences.
+var reVerifies *regexp.Regexp
+
+// verifiesReferences returns the requirements and the acceptance criteria verified according to
+// the line, as "<ID>" or "<ID>#AC<n>".
+func verifiesReferences(line string) []string {
+	var refs []string
+	for _, m := range reVerifies.FindAllStringSubmatch(line, -1) {
+		refs = append(refs, m[1]+m[2])
+	}
+	return refs
+}
+
+// resolveVerifies links the code file to the requirements it verifies, and returns the errors of
+// the references to requirements which don't exist or are deleted, one per line.
+func (rg reqGraph) resolveVerifies(code *Req) string {
+	errorResult := ""
+	for _, id := range code.VerifiesIds {
+		r := rg[id]
+		switch {
+		case r == nil:
+			errorResult += "Invalid reference in file " + code.Path + ": " + id + " does not exist.\n"
+		case r.IsDeleted():
+			errorResult += "Invalid reference in file " + code.Path + ": " + id + " is deleted.\n"
+		default:
+			code.Verifies = append(code.Verifies, r)
+			r.VerifiedBy = append(r.VerifiedBy, code)
+		}
+	}
+	return errorResult
+}
+
+// IsImplemented returns whether r is completely implemented by code, see RequirementStatus.
+func (r *Req) IsImplemented() bool {
+	return r.Status == COMPLETED
+}
+
+// IsVerified returns whether r is verified: by code referencing it with @verifies unless one of
+// its test cases failed, or by test cases which all passed.
+func (r *Req) IsVerified() bool {
+	status := r.VerificationStatus()
+	return len(r.VerifiedBy) > 0 && status != "Failed" || status == "Passed"
+}
+
+// CoverageGaps returns the requirements of the graph which aren't implemented or verified, and
+// the number of requirements checked, the system and deleted ones being skipped.
+func (rg reqGraph) CoverageGaps() ([]*Req, int) {
+	var (
+		gaps []*Req
+		n    int
+	)
+	for _, r := range rg {
+		if r.Level == config.CODE || r.Level == config.SYSTEM || r.IsDeleted() {
+			continue
+		}
+		n++
+		if !r.IsImplemented() || !r.IsVerified() {
+			gaps = append(gaps, r)
+		}
+	}
+	sort.Sort(byIDOrPath(gaps))
+	return gaps, n
+}
+
+// ReportCoverage writes the requirements which aren't implemented or verified, and a summary.
+func (rg reqGraph) ReportCoverage(w io.Writer) {
+	gaps, n := rg.CoverageGaps()
+	for _, r := range gaps {
+		var missing []string
+		if !r.IsImplemented() {
+			missing = append(missing, "implemented")
+		}
+		if !r.IsVerified() {
+			missing = append(missing, "verified")
+		}
+		fmt.Fprintf(w, "%s %s: not %s\n", r.ID, r.Title, strings.Join(missing, " nor "))
+	}
+	fmt.Fprintf(w, "%d of %d requirements implemented and verified\n", n-len(gaps), n)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifiesReferences(t *testing.T) {
+	v := "@" + "verifies "
+	assert.Equal(t, []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-002#AC3"},
+		verifiesReferences("/"+"/ "+v+"REQ-0-TEST-SWH-001, "+v+"REQ-0-TEST-SWL-002#AC3"))
+	assert.Empty(t, verifiesReferences("/"+"/ "+v+"REQ-0-TEST-SWL"))
+}
+
+func TestReqGraph_verifies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "verifies")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	code := filepath.Join(dir, "links.go")
+	test := filepath.Join(dir, "links_test.go")
+	assert.NoError(t, ioutil.WriteFile(code, []byte("/"+"/ @llr REQ-0-TEST-SWL-001\npackage main\n"), 0644))
+	assert.NoError(t, ioutil.WriteFile(test, []byte("package main\n\n/"+"/ @"+"verifies REQ-0-TEST-SWL-001\n/"+"/ @"+"verifies REQ-0-TEST-SWH-001\n"), 0644))
+
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System"}
+	high := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High", ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Low", ParentIds: []string{high.ID}}
+	untested := &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Title: "Untested", ParentIds: []string{high.ID}, Position: 1}
+	rg := reqGraph{sys.ID: sys, high.ID: high, low.ID: low, untested.ID: untested}
+	assert.NoError(t, parseCode("links.go", code, codeLanguages[".go"].references(), rg))
+	assert.NoError(t, parseCode("links_test.go", test, codeLanguages[".go"].references(), rg))
+	assert.Empty(t, rg[test].ParentIds)
+	assert.NoError(t, rg.Resolve())
+
+	assert.Equal(t, []*Req{rg[code]}, low.Children)
+	assert.Equal(t, []*Req{rg[test]}, low.VerifiedBy)
+	assert.Equal(t, []*Req{rg[test]}, high.VerifiedBy)
+	assert.Equal(t, []*Req{low, high}, rg[test].Verifies)
+	assert.True(t, low.IsImplemented())
+	assert.True(t, low.IsVerified())
+	assert.False(t, untested.IsImplemented())
+	assert.False(t, untested.IsVerified())
+	// The high-level requirement is partially implemented.
+	assert.False(t, high.IsImplemented())
+
+	var b bytes.Buffer
+	rg.ReportCoverage(&b)
+	assert.Equal(t, "REQ-0-TEST-SWH-001 High: not implemented\nREQ-0-TEST-SWL-002 Untested: not implemented nor verified\n1 of 3 requirements implemented and verified\n", b.String())
+
+	low.TestCases = []*TestCase{{ID: "C1", Result: "Failed"}}
+	assert.False(t, low.IsVerified())
+	untested.TestCases = []*TestCase{{ID: "C2", Result: "Passed"}}
+	assert.True(t, untested.IsVerified())
+
+	rg = reqGraph{test: {ID: "links_test.go", Path: test, Level: config.CODE, VerifiesIds: []string{"REQ-0-TEST-SWL-003"}}}
+	assert.EqualError(t, rg.Resolve(), "Invalid reference in file "+test+": REQ-0-TEST-SWL-003 does not exist.\n\n")
+}
@@ -0,0 +1,212 @@
+// @llr REQ-0-DDLN-SWL-024
+package main
+
+import (
+	"errors"
+
+	"github.com/daedaleanai/reqtraq/config"
+)
+
+// SkipChildren is returned by a VisitFunc to skip the requirements below (or above, when
+// visiting up) the current one. It is not returned as an error by the visits.
+var SkipChildren = errors.New("skip children")
+
+// VisitFunc is called for each requirement of a visit, with its distance from the start. If
+// it returns an error other than SkipChildren, the visit stops and returns the error.
+type VisitFunc func(r *Req, depth int) error
+
+// VisitDown visits r and the requirements and code files below it, depth first and in the
+// order of the children. Each one is visited once, even if reachable through several paths,
+// so a visit is O(V+E) in time and O(V) in memory for the visited-set.
+func (r *Req) VisitDown(fn VisitFunc) error {
+	return visit(r, fn, func(r *Req) []*Req { return r.Children })
+}
+
+// VisitUp visits r and the requirements above it, as VisitDown does downwards.
+func (r *Req) VisitUp(fn VisitFunc) error {
+	return visit(r, fn, func(r *Req) []*Req { return r.Parents })
+}
+
+func visit(start *Req, fn VisitFunc, next func(*Req) []*Req) error {
+	seen := map[*Req]bool{}
+	var walk func(r *Req, depth int) error
+	walk = func(r *Req, depth int) error {
+		if seen[r] {
+			return nil
+		}
+		seen[r] = true
+		if err := fn(r, depth); err != nil {
+			if err == SkipChildren {
+				return nil
+			}
+			return err
+		}
+		for _, n := range next(r) {
+			if err := walk(n, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(start, 0)
+}
+
+// ReqView is a filtered view of a requirement graph. Views are immutable, the methods
+// narrowing a view return a new one, and the requirements are found while iterating instead
+// of being collected in slices.
+type ReqView struct {
+	graph   reqGraph
+	filter  ReqFilter
+	diffs   map[string][]string
+	levels  map[config.RequirementLevel]bool
+	deleted bool
+}
+
+// View returns a view of all the requirements and code files of the graph, except for the
+// deleted requirements.
+func (rg reqGraph) View() *ReqView {
+	return &ReqView{graph: rg}
+}
+
+// Matching returns a view narrowed to the requirements matching the filter and the diffs, as
+// Req.Matches.
+func (v *ReqView) Matching(filter ReqFilter, diffs map[string][]string) *ReqView {
+	n := *v
+	n.filter, n.diffs = filter, diffs
+	return &n
+}
+
+// Levels returns a view narrowed to the given levels.
+func (v *ReqView) Levels(levels ...config.RequirementLevel) *ReqView {
+	n := *v
+	n.levels = map[config.RequirementLevel]bool{}
+	for _, l := range levels {
+		n.levels[l] = true
+	}
+	return &n
+}
+
+// WithDeleted returns a view which includes the deleted requirements.
+func (v *ReqView) WithDeleted() *ReqView {
+	n := *v
+	n.deleted = true
+	return &n
+}
+
+// Contains returns whether r is part of the view.
+func (v *ReqView) Contains(r *Req) bool {
+	if v.levels != nil && !v.levels[r.Level] {
+		return false
+	}
+	if !v.deleted && r.IsDeleted() {
+		return false
+	}
+	return r.Matches(v.filter, v.diffs)
+}
+
+// Each calls fn for each requirement of the view, in no particular order, until fn returns an
+// error, which is returned. It is O(V) in time and O(1) in memory.
+func (v *ReqView) Each(fn func(r *Req) error) error {
+	for _, r := range v.graph {
+		if !v.Contains(r) {
+			continue
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of requirements in the view.
+func (v *ReqView) Count() int {
+	n := 0
+	v.Each(func(*Req) error {
+		n++
+		return nil
+	})
+	return n
+}
+
+// ReqField selects fields of a requirement, see Req.Select.
+type ReqField uint
+
+const (
+	FieldID ReqField = 1 << iota
+	FieldLevel
+	FieldPath
+	FieldTitle
+	FieldBody
+	FieldSections
+	FieldAttributes
+	FieldParents
+	FieldChildren
+	FieldStatus
+	FieldHash
+
+	FieldAll = FieldID | FieldLevel | FieldPath | FieldTitle | FieldBody | FieldSections | FieldAttributes | FieldParents | FieldChildren | FieldStatus | FieldHash
+)
+
+// reqFieldNames are the keys of the fields in the maps returned by Req.Select.
+var reqFieldNames = map[ReqField]string{
+	FieldID:         "id",
+	FieldLevel:      "level",
+	FieldPath:       "path",
+	FieldTitle:      "title",
+	FieldBody:       "body",
+	FieldSections:   "sections",
+	FieldAttributes: "attributes",
+	FieldParents:    "parents",
+	FieldChildren:   "children",
+	FieldStatus:     "status",
+	FieldHash:       "hash",
+}
+
+// Select returns the selected fields of r, keyed by their lower-case name, e.g. to be encoded as
+// json. The parents and the children are given by ID, the hash is that of the code files.
+func (r *Req) Select(fields ReqField) map[string]interface{} {
+	m := map[string]interface{}{}
+	for f, name := range reqFieldNames {
+		if fields&f == 0 {
+			continue
+		}
+		switch f {
+		case FieldID:
+			m[name] = r.ID
+		case FieldLevel:
+			m[name] = int(r.Level)
+		case FieldPath:
+			m[name] = r.Path
+		case FieldTitle:
+			m[name] = r.Title
+		case FieldBody:
+			m[name] = string(r.LoadBody())
+		case FieldSections:
+			m[name] = map[string]string{
+				"rationale":           string(r.Rationale),
+				"acceptance_criteria": string(r.AcceptanceCriteria),
+				"notes":               string(r.Notes),
+			}
+		case FieldAttributes:
+			m[name] = r.Attributes
+		case FieldParents:
+			m[name] = idsOf(r.Parents)
+		case FieldChildren:
+			m[name] = idsOf(r.Children)
+		case FieldStatus:
+			m[name] = r.Status.String()
+		case FieldHash:
+			m[name] = r.FileHash
+		}
+	}
+	return m
+}
+
+// idsOf returns the IDs of the requirements, which are the paths for code files.
+func idsOf(reqs []*Req) []string {
+	ids := make([]string, 0, len(reqs))
+	for _, r := range reqs {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
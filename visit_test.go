@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// visitTestGraph returns a graph with one system requirement, two high-level requirements both
+// implemented by the same low-level requirement, and one code file.
+func visitTestGraph() reqGraph {
+	rg := reqGraph{
+		"REQ-0-TEST-SYS-001": &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System"},
+		"REQ-0-TEST-SWH-001": &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: "High 1", ParentIds: []string{"REQ-0-TEST-SYS-001"}},
+		"REQ-0-TEST-SWH-002": &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: "DELETED High 2", ParentIds: []string{"REQ-0-TEST-SYS-001"}},
+		"REQ-0-TEST-SWL-001": &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Low", ParentIds: []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWH-002"}},
+		"/repo/a.go":         &Req{ID: "a.go", Path: "/repo/a.go", Level: config.CODE, ParentIds: []string{"REQ-0-TEST-SWL-001"}},
+	}
+	for _, r := range rg {
+		for _, id := range r.ParentIds {
+			p := rg[id]
+			r.Parents = append(r.Parents, p)
+			p.Children = append(p.Children, r)
+		}
+	}
+	// Deterministic order of the children.
+	rg["REQ-0-TEST-SYS-001"].Children = []*Req{rg["REQ-0-TEST-SWH-001"], rg["REQ-0-TEST-SWH-002"]}
+	return rg
+}
+
+func TestReq_VisitDown(t *testing.T) {
+	rg := visitTestGraph()
+	var visited []string
+	err := rg["REQ-0-TEST-SYS-001"].VisitDown(func(r *Req, depth int) error {
+		visited = append(visited, fmt.Sprintf("%d %s", depth, r.ID))
+		return nil
+	})
+	assert.NoError(t, err)
+	// The low-level requirement is visited once.
+	assert.Equal(t, []string{"0 REQ-0-TEST-SYS-001", "1 REQ-0-TEST-SWH-001", "2 REQ-0-TEST-SWL-001", "3 a.go", "1 REQ-0-TEST-SWH-002"}, visited)
+
+	visited = nil
+	err = rg["REQ-0-TEST-SYS-001"].VisitDown(func(r *Req, depth int) error {
+		visited = append(visited, r.ID)
+		if r.Level == config.HIGH {
+			return SkipChildren
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SWH-001", "REQ-0-TEST-SWH-002"}, visited)
+
+	stop := errors.New("stop")
+	visited = nil
+	err = rg["REQ-0-TEST-SYS-001"].VisitDown(func(r *Req, depth int) error {
+		visited = append(visited, r.ID)
+		if r.Level == config.LOW {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []string{"REQ-0-TEST-SYS-001", "REQ-0-TEST-SWH-001", "REQ-0-TEST-SWL-001"}, visited)
+}
+
+func TestReq_VisitUp(t *testing.T) {
+	rg := visitTestGraph()
+	var visited []string
+	err := rg["/repo/a.go"].VisitUp(func(r *Req, depth int) error {
+		visited = append(visited, fmt.Sprintf("%d %s", depth, r.ID))
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0 a.go", "1 REQ-0-TEST-SWL-001", "2 REQ-0-TEST-SWH-001", "3 REQ-0-TEST-SYS-001", "2 REQ-0-TEST-SWH-002"}, visited)
+}
+
+func TestReqView(t *testing.T) {
+	rg := visitTestGraph()
+	assert.Equal(t, 4, rg.View().Count())
+	assert.Equal(t, 5, rg.View().WithDeleted().Count())
+	assert.Equal(t, 1, rg.View().Levels(config.HIGH).Count())
+	assert.Equal(t, 2, rg.View().Levels(config.HIGH, config.LOW).Count())
+
+	title := rg.View().Matching(ReqFilter{TitleFilter: regexp.MustCompile("High")}, nil)
+	assert.Equal(t, 1, title.Count())
+	assert.Equal(t, 2, title.WithDeleted().Count())
+	// The original view is not changed.
+	assert.Equal(t, 4, rg.View().Count())
+
+	var ids []string
+	err := rg.View().Matching(nil, map[string][]string{"REQ-0-TEST-SWL-001": nil}).Each(func(r *Req) error {
+		ids = append(ids, r.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"REQ-0-TEST-SWL-001"}, ids)
+}
+
+func TestReq_Select(t *testing.T) {
+	rg := visitTestGraph()
+	assert.Equal(t, map[string]interface{}{
+		"id":       "REQ-0-TEST-SWL-001",
+		"parents":  []string{"REQ-0-TEST-SWH-001", "REQ-0-TEST-SWH-002"},
+		"children": []string{"a.go"},
+	}, rg["REQ-0-TEST-SWL-001"].Select(FieldID|FieldParents|FieldChildren))
+	assert.Len(t, rg["REQ-0-TEST-SWL-001"].Select(FieldAll), len(reqFieldNames))
+}
+
+// benchGraph returns a graph of n system requirements, each with 10 high-level requirements,
+// each with 10 low-level requirements, each implemented by a code file, i.e. 211*n nodes.
+func benchGraph(n int) (reqGraph, []*Req) {
+	rg := reqGraph{}
+	var roots []*Req
+	add := func(r *Req, parent *Req) {
+		rg[r.ID] = r
+		if parent != nil {
+			r.Parents = []*Req{parent}
+			parent.Children = append(parent.Children, r)
+		}
+	}
+	for i := 0; i < n; i++ {
+		sys := &Req{ID: fmt.Sprintf("REQ-0-TEST-SYS-%d", i), Level: config.SYSTEM}
+		add(sys, nil)
+		roots = append(roots, sys)
+		for j := 0; j < 10; j++ {
+			high := &Req{ID: fmt.Sprintf("REQ-0-TEST-SWH-%d-%d", i, j), Level: config.HIGH}
+			add(high, sys)
+			for k := 0; k < 10; k++ {
+				low := &Req{ID: fmt.Sprintf("REQ-0-TEST-SWL-%d-%d-%d", i, j, k), Level: config.LOW}
+				add(low, high)
+				add(&Req{ID: fmt.Sprintf("code/%d/%d/%d.go", i, j, k), Level: config.CODE}, low)
+			}
+		}
+	}
+	return rg, roots
+}
+
+// BenchmarkVisitDown visits the whole graph from the system requirements, which is linear in
+// the size of the graph.
+func BenchmarkVisitDown(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		_, roots := benchGraph(n)
+		b.Run(fmt.Sprintf("nodes=%d", 211*n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for _, r := range roots {
+					r.VisitDown(func(*Req, int) error { return nil })
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReqView_Each iterates over a filtered view, which is linear in the size of the
+// graph and doesn't allocate per requirement.
+func BenchmarkReqView_Each(b *testing.B) {
+	filter := ReqFilter{IdFilter: regexp.MustCompile("SWL")}
+	for _, n := range []int{10, 100, 1000} {
+		rg, _ := benchGraph(n)
+		v := rg.View().Levels(config.LOW).Matching(filter, nil)
+		b.Run(fmt.Sprintf("nodes=%d", 211*n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				v.Each(func(*Req) error { return nil })
+			}
+		})
+	}
+}
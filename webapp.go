@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 
@@ -26,10 +27,20 @@ var errorTemplate *template.Template = template.Must(template.New("error").Parse
 
 func handler(w http.ResponseWriter, r *http.Request) {
 	log.Print(r.Method, r.URL)
+	// The liveness check doesn't wait for the lock, held e.g. while a document is formatted.
+	if r.URL.Path == "/healthz" {
+		fmt.Fprintln(w, "ok")
+		return
+	}
 	var err error
 	switch r.Method {
 	case "GET":
-		err = get(w, r)
+		var lock *repoLock
+		lock, err = lockRepo(false)
+		if err == nil {
+			err = get(w, r)
+			lock.Unlock()
+		}
 	default:
 		err = fmt.Errorf("Unknown HTTP method: %s", r.Method)
 	}
@@ -91,6 +102,12 @@ var indexTemplate *template.Template = template.Must(template.New("index").Parse
 {{ range .Commits }}<option value="{{ . }}">{{ . }}</option>{{ end }}</select></div>
 </div>
 <div class="rTableRow">
+<div class="rTableCell">Audience:</div>
+<div class="rTableCell"><select name="audience">
+<option value="">Everything</option>
+{{ range .Audiences }}<option value="{{ . }}">{{ . }}</option>{{ end }}</select></div>
+</div>
+<div class="rTableRow">
 <div class="rTableCell"></div>
 <div class="rTableCell"><input type="reset"></div>
 </div>
@@ -98,14 +115,53 @@ var indexTemplate *template.Template = template.Must(template.New("index").Parse
 <input type="submit" name="report-type" value="Bottom Up"/>
 <input type="submit" name="report-type" value="Top Down"/>
 <input type="submit" name="report-type" value="Issues"/>
+<input type="submit" name="report-type" value="Risk"/>
+</p>
+</form>
+
+<form action="/suggest" method="get">
+<p>Suggest the parents of a requirement, or the requirements of a code file:
+<input name="for" type="text" placeholder="Requirement ID or code file">
+<input type="submit" value="Suggest"/>
 </p>
 </form>
 </body>
 </html>`))
 
+var suggestTemplate *template.Template = template.Must(template.New("suggest").Parse(
+	`<!DOCTYPE html>
+<html lang="en">
+<head>
+<title>Suggestions for {{.For}}</title>
+</head>
+
+<body>
+<h1>Suggestions for {{.For}}</h1>
+<p>The suggestions are not applied, edit the certification documents or the code to apply them.</p>
+{{ if .Suggestions }}
+<table>
+<tr><th>Score</th><th>Requirement</th><th></th></tr>
+{{ range .Suggestions }}<tr>
+<td>{{ printf "%.2f" .Score }}</td>
+<td><b>{{ .ID }}</b> {{ .Req.Title }}</td>
+<td>{{ if .Linked }}already linked{{ end }}</td>
+</tr>
+{{ end }}</table>
+{{ else }}
+<p>No suggestions.</p>
+{{ end }}
+</body>
+</html>`))
+
+type suggestData struct {
+	For         string
+	Suggestions []Suggestion
+}
+
 type indexData struct {
-	RepoName string
-	Commits  []string
+	RepoName  string
+	Commits   []string
+	Audiences []string
 }
 
 func get(w http.ResponseWriter, r *http.Request) error {
@@ -117,7 +173,11 @@ func get(w http.ResponseWriter, r *http.Request) error {
 		if err != nil {
 			return err
 		}
-		indexTemplate.Execute(w, indexData{repoName, commits})
+		conf, err := loadJsonConf(*fReportJsonConfPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		indexTemplate.Execute(w, indexData{repoName, commits, conf.audienceNames()})
 
 	case path == "/report":
 		at := r.FormValue("at_commit")
@@ -130,6 +190,11 @@ func get(w http.ResponseWriter, r *http.Request) error {
 			return err
 		}
 		defer os.RemoveAll(dir)
+		if atCommit == "" {
+			if err := recordBuild(rg); err != nil {
+				return err
+			}
+		}
 		filter := ReqFilter{}
 		if len(r.FormValue("title_filter")) > 0 {
 			filter[TitleFilter], err = regexp.Compile(r.FormValue("title_filter"))
@@ -167,6 +232,17 @@ func get(w http.ResponseWriter, r *http.Request) error {
 			}
 			return rg.ReportUp(w)
 		case "Top Down":
+			if name := r.FormValue("audience"); name != "" {
+				conf, err := loadJsonConf(*fReportJsonConfPath)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				a, err := conf.audienceConf(name)
+				if err != nil {
+					return err
+				}
+				return rg.ReportAudience(w, name, a, filter, diffs)
+			}
 			if len(filter) > 0 || diffs != nil {
 				return rg.ReportDownFiltered(w, filter, diffs)
 			}
@@ -176,7 +252,51 @@ func get(w http.ResponseWriter, r *http.Request) error {
 				return rg.ReportIssuesFiltered(w, filter, diffs)
 			}
 			return rg.ReportIssues(w)
+		case "Risk":
+			conf, err := loadJsonConf(*fReportJsonConfPath)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return rg.ReportRisk(w, conf.riskConf(), filter, diffs)
+		}
+
+	case path == "/readyz":
+		serveReady(w)
+
+	case path == "/version":
+		return serveVersion(w)
+
+	case strings.HasPrefix(path, "/badge/") && strings.HasSuffix(path, ".svg"):
+		rg, _, err := buildGraph("")
+		if rg == nil {
+			return err
+		}
+		b, ok := rg.Badges(err == nil)[strings.TrimSuffix(strings.TrimPrefix(path, "/badge/"), ".svg")]
+		if !ok {
+			http.NotFound(w, r)
+			return nil
+		}
+		w.Header().Set("Content-Type", "image/svg+xml")
+		// The badges are embedded in other pages, which shouldn't show them stale.
+		w.Header().Set("Cache-Control", "no-cache")
+		return b.WriteSVG(w)
+
+	case path == "/suggest":
+		what := strings.TrimSpace(r.FormValue("for"))
+		if what == "" {
+			return fmt.Errorf("Missing requirement ID or code file")
+		}
+		conf, err := loadJsonConf(*fReportJsonConfPath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// The graph is used even if it has issues, e.g. the requirement has no parents yet.
+		rg, _ := CreateReqGraph(*fCertdocPath, *fCodePath, conf.TestEnv...)
+		suggestions, err := rg.SuggestFor(conf.suggestConf(), what, filepath.Join(git.RepoPath(), *fCodePath))
+		if err != nil {
+			return err
 		}
+		return suggestTemplate.Execute(w, suggestData{what, suggestions})
 	}
 	return nil
 }
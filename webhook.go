@@ -0,0 +1,291 @@
+// @llr REQ-0-DDLN-SWL-068
+// @llr REQ-0-DDLN-SWL-088
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/daedaleanai/reqtraq/git"
+	"github.com/daedaleanai/reqtraq/linepipes"
+)
+
+// The kinds of the lifecycle events.
+const (
+	eventApproved    = "approved"
+	eventBaselined   = "baselined"
+	eventDeleted     = "deleted"
+	eventSuspectLink = "suspect-link"
+	// The tasks of the requirements created and updated by a sync, deleted ones being deleted events.
+	eventCreated = "created"
+	eventUpdated = "updated"
+	// The checks of precommit and quickcheck finding issues.
+	eventValidationFailed = "validation-failed"
+)
+
+// WebhookConf configures the lifecycle events of the requirements posted to a quality management
+// system, from the "webhook" entry of attributes.json:
+//
+//	"webhook": {
+//		"url": "https://qms.example.com/api/requirements/events",
+//		"events": ["approved", "deleted"],
+//		"approved": {"attribute": "Approval", "value": "Approved"}
+//	}
+//
+// The payloads are signed with the secret of the git config daedalean.webhook-secret, see
+// PostEvents. Other webhooks, e.g. of chat bots and dashboards, are configured alike in the
+// "webhooks" entry.
+type WebhookConf struct {
+	URL string `json:"url"`
+	// Events are the kinds of the events posted, all by default.
+	Events []string `json:"events"`
+	// Approved is the attribute, and the regular expression its whole value matches, of the
+	// approved requirements. Without it no approved events are posted.
+	Approved *ApprovalConf `json:"approved"`
+}
+
+// ApprovalConf is the attribute marking the requirements approved, see WebhookConf.
+type ApprovalConf struct {
+	Attribute string `json:"attribute"`
+	Value     string `json:"value"`
+}
+
+// LifecycleEvent is an event of the lifecycle of the requirements.
+type LifecycleEvent struct {
+	// Kind is approved, baselined, created, deleted, suspect-link, updated or validation-failed.
+	Kind string `json:"kind"`
+	// ID is the requirement approved, created, deleted or updated.
+	ID string `json:"id,omitempty"`
+	// Task is the task of the requirement created, deleted or updated by a sync, and Fields the
+	// fields of the task updated, e.g. "title, parents".
+	Task   string `json:"task,omitempty"`
+	Fields string `json:"fields,omitempty"`
+	// Parent and Child are the ends of a suspect link: the parent changed, but not the child,
+	// which is to be reviewed again. The child is a requirement or a code file.
+	Parent string `json:"parent,omitempty"`
+	Child  string `json:"child,omitempty"`
+	// Baseline is the name of the archive or the baseline created.
+	Baseline string `json:"baseline,omitempty"`
+	// Check is the command whose validation failed, precommit or quickcheck, and Issues the
+	// issues it found.
+	Check  string   `json:"check,omitempty"`
+	Issues []string `json:"issues,omitempty"`
+}
+
+// webhookPayload is the body of the POST of the events.
+type webhookPayload struct {
+	Commit string           `json:"commit"`
+	Time   string           `json:"time"`
+	Events []LifecycleEvent `json:"events"`
+}
+
+// webhookClient posts the events, the QMS answering within the timeout.
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// posts returns whether the kind of events is posted.
+func (c WebhookConf) posts(kind string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, e := range c.Events {
+		if e == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// webhooks returns the webhooks configured with a url, the "webhook" entry first.
+func (c JsonConf) webhooks() []WebhookConf {
+	var hooks []WebhookConf
+	if c.Webhook != nil && c.Webhook.URL != "" {
+		hooks = append(hooks, *c.Webhook)
+	}
+	for _, h := range c.Webhooks {
+		if h.URL != "" {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// LifecycleEvents returns the events of the requirements since the snapshot old, those posted
+// only, sorted by kind and ID:
+//
+// - approved, for the requirements whose approval attribute matches and didn't, see ApprovalConf.
+//
+// - deleted, for the requirements deleted or removed.
+//
+// - suspect-link, for the links of the snapshot from a requirement whose title, body, sections or
+// attributes changed to a child which didn't change, nor its hash for a code file.
+func (rg reqGraph) LifecycleEvents(old map[string]map[string]interface{}, conf WebhookConf) ([]LifecycleEvent, error) {
+	var approval *regexp.Regexp
+	if conf.Approved != nil {
+		var err error
+		if approval, err = regexp.Compile("^(?:" + conf.Approved.Value + ")$"); err != nil {
+			return nil, fmt.Errorf("Invalid regular expression %q of the approved attribute: %v", conf.Approved.Value, err)
+		}
+	}
+	d, err := rg.Delta(old)
+	if err != nil {
+		return nil, err
+	}
+	changed := map[string]bool{}
+	for _, c := range d.Changed {
+		for name := range c.Fields {
+			switch name {
+			case "title", "body", "sections", "attributes", "hash":
+				changed[c.ID] = true
+			}
+		}
+	}
+	current := map[string]*Req{}
+	for _, r := range rg {
+		current[r.ID] = r
+	}
+
+	var events []LifecycleEvent
+	if approval != nil && conf.posts(eventApproved) {
+		name := strings.ToUpper(conf.Approved.Attribute)
+		for _, id := range sortedReqIDs(current) {
+			r := current[id]
+			if r.Level == config.CODE || r.IsDeleted() || !approval.MatchString(r.Attributes[name]) {
+				continue
+			}
+			if o := old[id]; o != nil {
+				attributes, _ := o["attributes"].(map[string]interface{})
+				if value, _ := attributes[name].(string); approval.MatchString(value) {
+					continue
+				}
+			}
+			events = append(events, LifecycleEvent{Kind: eventApproved, ID: id})
+		}
+	}
+	if conf.posts(eventDeleted) {
+		for _, id := range sortedNodeIDs(old) {
+			o := old[id]
+			title, _ := o["title"].(string)
+			if level, _ := o["level"].(float64); config.RequirementLevel(level) == config.CODE || strings.HasPrefix(title, "DELETED") {
+				continue
+			}
+			if r := current[id]; r == nil || r.IsDeleted() {
+				events = append(events, LifecycleEvent{Kind: eventDeleted, ID: id})
+			}
+		}
+	}
+	if conf.posts(eventSuspectLink) {
+		oldLinks := snapshotLinks(old)
+		for _, l := range sortedLinks(oldLinks) {
+			p, c := current[l.Parent], current[l.Child]
+			if p == nil || c == nil || p.IsDeleted() || !changed[p.ID] || changed[c.ID] {
+				continue
+			}
+			for _, child := range p.Children {
+				if child == c {
+					events = append(events, LifecycleEvent{Kind: eventSuspectLink, Parent: p.ID, Child: c.ID})
+				}
+			}
+		}
+	}
+	return events, nil
+}
+
+// sortedReqIDs returns the IDs of the requirements, sorted.
+func sortedReqIDs(reqs map[string]*Req) []string {
+	ids := make([]string, 0, len(reqs))
+	for id := range reqs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// webhookSecret returns the secret the payloads are signed with, from the git config.
+func webhookSecret() (string, error) {
+	secret, _ := linepipes.Single(linepipes.Run("git", "config", "--get", "daedalean.webhook-secret"))
+	if secret == "" {
+		return "", fmt.Errorf("No webhook secret set, see git config daedalean.webhook-secret")
+	}
+	return secret, nil
+}
+
+// validationFailedEvent returns the event of the check finding the issues of err, one per line.
+func validationFailedEvent(check string, err error) LifecycleEvent {
+	var issues []string
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			issues = append(issues, line)
+		}
+	}
+	return LifecycleEvent{Kind: eventValidationFailed, Check: check, Issues: issues}
+}
+
+// notifyWebhooks posts the events of the head commit to each of the webhooks, those of the kinds
+// it posts. The webhooks being notified of the commands run for other purposes, e.g. a sync of
+// the tasks, the failures are logged rather than failing the command.
+func notifyWebhooks(hooks []WebhookConf, events []LifecycleEvent) {
+	if len(hooks) == 0 || len(events) == 0 {
+		return
+	}
+	secret, err := webhookSecret()
+	if err != nil {
+		log.Printf("Warning: %v, the webhooks are not notified", err)
+		return
+	}
+	// Empty in a repository without commits.
+	commit, _ := git.HeadCommit()
+	for _, h := range hooks {
+		var posted []LifecycleEvent
+		for _, e := range events {
+			if h.posts(e.Kind) {
+				posted = append(posted, e)
+			}
+		}
+		if err := h.PostEvents(secret, commit, time.Now(), posted); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// PostEvents posts the events of the commit to the webhook as json, nothing if there are none. The
+// X-Reqtraq-Signature header is the HMAC-SHA256 of the body with the secret, as
+// "sha256=<hex>", to be verified by the QMS.
+func (c WebhookConf) PostEvents(secret, commit string, now time.Time, events []LifecycleEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(webhookPayload{commit, now.UTC().Format(time.RFC3339), events})
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Reqtraq-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error while posting the events to %s: %v", c.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error while posting the events to %s: %s\n%s", c.URL, resp.Status, b)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycleEvents(t *testing.T) {
+	graph := func(reqs ...*Req) reqGraph {
+		rg := reqGraph{}
+		for _, r := range reqs {
+			if r.Level == config.CODE {
+				rg[r.Path] = r
+			} else {
+				rg[r.ID] = r
+			}
+		}
+		assert.NoError(t, rg.Resolve())
+		return rg
+	}
+	reqs := func(high1Title, approval, high2Title string) []*Req {
+		sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Title: "System"}
+		return []*Req{sys,
+			&Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Title: high1Title, Attributes: map[string]string{"APPROVAL": approval},
+				ParentIds: []string{sys.ID}},
+			&Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Title: high2Title, ParentIds: []string{sys.ID}},
+			&Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Title: "Low", ParentIds: []string{"REQ-0-TEST-SWH-001"}},
+			&Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", FileHash: "sha1:aa", ParentIds: []string{"REQ-0-TEST-SWL-001"}},
+		}
+	}
+	removed := &Req{ID: "REQ-0-TEST-SWH-003", Level: config.HIGH, Title: "Removed", ParentIds: []string{"REQ-0-TEST-SYS-001"}}
+	var b bytes.Buffer
+	assert.NoError(t, graph(append(reqs("High", "Draft", "Land"), removed)...).Export("snapshot", &b))
+	old, err := decodeSnapshot(b.Bytes(), "old.json")
+	assert.NoError(t, err)
+
+	rg := graph(reqs("Higher", "Approved", "DELETED Land")...)
+	conf := WebhookConf{Approved: &ApprovalConf{"Approval", "Approved"}}
+	events, err := rg.LifecycleEvents(old, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []LifecycleEvent{
+		{Kind: "approved", ID: "REQ-0-TEST-SWH-001"},
+		{Kind: "deleted", ID: "REQ-0-TEST-SWH-002"},
+		{Kind: "deleted", ID: "REQ-0-TEST-SWH-003"},
+		// The code isn't suspect, its parent didn't change.
+		{Kind: "suspect-link", Parent: "REQ-0-TEST-SWH-001", Child: "REQ-0-TEST-SWL-001"},
+	}, events)
+
+	conf.Events = []string{"deleted"}
+	events, err = rg.LifecycleEvents(old, conf)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// Without the approval attribute, no approved events.
+	events, err = rg.LifecycleEvents(old, WebhookConf{Events: []string{"approved"}})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestPostEvents(t *testing.T) {
+	var body []byte
+	var signature string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get("X-Reqtraq-Signature")
+		w.WriteHeader(status)
+		w.Write([]byte("rejected"))
+	}))
+	defer server.Close()
+
+	conf := WebhookConf{URL: server.URL}
+	now := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	events := []LifecycleEvent{{Kind: "deleted", ID: "REQ-0-TEST-SWH-002"}, {Kind: "baselined", Baseline: "closure.zip"}}
+	assert.NoError(t, conf.PostEvents("secret", "4b36b3e", now, events))
+	assert.Equal(t, `{"commit":"4b36b3e","time":"2020-03-01T10:00:00Z","events":[{"kind":"deleted","id":"REQ-0-TEST-SWH-002"},{"kind":"baselined","baseline":"closure.zip"}]}`, string(body))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), signature)
+
+	// Nothing is posted without events.
+	body = nil
+	assert.NoError(t, conf.PostEvents("secret", "4b36b3e", now, nil))
+	assert.Nil(t, body)
+
+	status = http.StatusForbidden
+	assert.EqualError(t, conf.PostEvents("secret", "4b36b3e", now, events),
+		"Error while posting the events to "+server.URL+": 403 Forbidden\nrejected")
+}
+
+func TestWebhooks(t *testing.T) {
+	conf := JsonConf{
+		Webhook:  &WebhookConf{URL: "https://qms.example.com/events"},
+		Webhooks: []WebhookConf{{URL: "https://bots.example.com/reqtraq", Events: []string{"validation-failed"}}, {}},
+	}
+	hooks := conf.webhooks()
+	if assert.Len(t, hooks, 2) {
+		assert.Equal(t, "https://qms.example.com/events", hooks[0].URL)
+		assert.True(t, hooks[1].posts(eventValidationFailed))
+		assert.False(t, hooks[1].posts(eventCreated))
+	}
+	assert.Empty(t, JsonConf{Webhook: &WebhookConf{}}.webhooks())
+
+	event := validationFailedEvent("precommit", graphError(false, "Invalid reference REQ-0-TEST-SWH-009\n\nMissing attribute Rationale\n"))
+	assert.Equal(t, LifecycleEvent{Kind: "validation-failed", Check: "precommit",
+		Issues: []string{"Invalid reference REQ-0-TEST-SWH-009", "Missing attribute Rationale"}}, event)
+}
@@ -0,0 +1,264 @@
+// @llr REQ-0-DDLN-SWL-061
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	exporters["xlsx"] = exportXLSX
+}
+
+// xlsxSheet is a sheet of the xlsx matrix, with the links from the requirements of one type to
+// their children of another, e.g. from SYS to SWH.
+type xlsxSheet struct {
+	name              string
+	parents, children string
+	// hardware sheets are left out when the graph has no hardware requirements.
+	hardware bool
+}
+
+var xlsxSheets = []xlsxSheet{
+	{"SYS-SWH", "SYS", "SWH", false},
+	{"SWH-SWL", "SWH", "SWL", false},
+	{"SWL-Code", "SWL", "CODE", false},
+	{"SYS-HWH", "SYS", "HWH", true},
+	{"HWH-HWL", "HWH", "HWL", true},
+	{"HWL-Code", "HWL", "CODE", true},
+}
+
+// The colors of the statuses in the xlsx matrix, as the differential formats of styles.xml.
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+<dxfs count="3">
+<dxf><font><color rgb="FF9C0006"/></font><fill><patternFill><bgColor rgb="FFFFC7CE"/></patternFill></fill></dxf>
+<dxf><font><color rgb="FF9C5700"/></font><fill><patternFill><bgColor rgb="FFFFEB9C"/></patternFill></fill></dxf>
+<dxf><font><color rgb="FF006100"/></font><fill><patternFill><bgColor rgb="FFC6EFCE"/></patternFill></fill></dxf>
+</dxfs>
+</styleSheet>
+`
+
+// xlsxStatusFormats are the indexes of the differential formats of the statuses in xlsxStyles.
+var xlsxStatusFormats = []struct {
+	status RequirementStatus
+	dxf    int
+}{
+	{NOT_STARTED, 0},
+	{STARTED, 1},
+	{COMPLETED, 2},
+}
+
+// exportXLSX writes the traceability matrix as an Excel workbook, with a sheet per transition
+// between two levels, e.g. SYS-SWH, and a row per link, see xlsxSheet.rows. The header rows are frozen
+// and the statuses colored.
+func exportXLSX(rg reqGraph, w io.Writer) error {
+	hardware := false
+	for _, r := range rg {
+		hardware = hardware || isOfType(r, "HWH") || isOfType(r, "HWL")
+	}
+	var names []string
+	var sheets [][]byte
+	for _, s := range xlsxSheets {
+		if s.hardware && !hardware {
+			continue
+		}
+		header, rows, statusColumns := s.rows(rg)
+		names = append(names, s.name)
+		sheets = append(sheets, xlsxWorksheet(header, rows, statusColumns))
+	}
+
+	var contentTypes, workbook, workbookRels bytes.Buffer
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+`)
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+`)
+	for i, name := range names {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`+"\n", xmlEscape(name), i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n", i+1, i+1)
+	}
+	contentTypes.WriteString("</Types>\n")
+	workbook.WriteString("</sheets>\n</workbook>\n")
+	workbookRels.WriteString("</Relationships>\n")
+
+	files := []archiveFile{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.Bytes()},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for i, s := range sheets {
+		files = append(files, archiveFile{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), s})
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.Name, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.Content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// rows returns the header and the rows of the sheet, and the indexes of the columns of the
+// statuses. There is a row per link from a requirement of the parents type to a child of the
+// children type, in the order of the certification documents. The parents without such children
+// and the children without parents have a row too, with the other columns empty, so the gaps show.
+// The deleted requirements are left out.
+func (s xlsxSheet) rows(rg reqGraph) ([]string, [][]string, []int) {
+	var parents, orphans []*Req
+	for _, r := range rg {
+		if r.IsDeleted() {
+			continue
+		}
+		if isOfType(r, s.parents) {
+			parents = append(parents, r)
+		} else if isOfType(r, s.children) && len(r.Parents) == 0 {
+			orphans = append(orphans, r)
+		}
+	}
+	sortTraced(parents)
+	sortTraced(orphans)
+
+	header := []string{s.parents, s.parents + " Title", s.parents + " Status"}
+	statusColumns := []int{2}
+	if s.children == "CODE" {
+		header = append(header, "Code", "Hash")
+	} else {
+		header = append(header, s.children, s.children+" Title", s.children+" Status")
+		statusColumns = append(statusColumns, 5)
+	}
+	cells := func(r *Req) []string {
+		if r == nil {
+			return make([]string, 3)
+		}
+		return []string{r.ID, r.Title, r.Status.String()}
+	}
+	childCells := func(r *Req) []string {
+		if s.children != "CODE" {
+			return cells(r)
+		}
+		if r == nil {
+			return make([]string, 2)
+		}
+		return []string{r.ID, r.FileHash}
+	}
+
+	var rows [][]string
+	for _, p := range parents {
+		var children []*Req
+		for _, c := range p.Children {
+			if !c.IsDeleted() && isOfType(c, s.children) {
+				children = append(children, c)
+			}
+		}
+		sortTraced(children)
+		if len(children) == 0 {
+			rows = append(rows, append(cells(p), childCells(nil)...))
+		}
+		for _, c := range children {
+			rows = append(rows, append(cells(p), childCells(c)...))
+		}
+	}
+	for _, c := range orphans {
+		rows = append(rows, append(cells(nil), childCells(c)...))
+	}
+	return header, rows, statusColumns
+}
+
+// xlsxWorksheet returns the XML of a worksheet with the header, bold and frozen, and the rows,
+// the status columns being colored by status.
+func xlsxWorksheet(header []string, rows [][]string, statusColumns []int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<cols>
+`)
+	for i, h := range header {
+		width := 24
+		if strings.HasSuffix(h, " Title") {
+			width = 60
+		}
+		fmt.Fprintf(&b, `<col min="%d" max="%d" width="%d" customWidth="1"/>`+"\n", i+1, i+1, width)
+	}
+	b.WriteString("</cols>\n<sheetData>\n")
+	xlsxRow(&b, 1, header, 1)
+	for i, row := range rows {
+		xlsxRow(&b, i+2, row, 0)
+	}
+	b.WriteString("</sheetData>\n")
+	last := xlsxCell(len(header)-1, len(rows)+1)
+	fmt.Fprintf(&b, `<autoFilter ref="A1:%s"/>`+"\n", last)
+	if len(rows) > 0 {
+		priority := 1
+		for _, c := range statusColumns {
+			first := xlsxCell(c, 2)
+			fmt.Fprintf(&b, `<conditionalFormatting sqref="%s:%s">`+"\n", first, xlsxCell(c, len(rows)+1))
+			for _, f := range xlsxStatusFormats {
+				fmt.Fprintf(&b, `<cfRule type="cellIs" dxfId="%d" priority="%d" operator="equal"><formula>"%s"</formula></cfRule>`+"\n",
+					f.dxf, priority, xmlEscape(f.status.String()))
+				priority++
+			}
+			b.WriteString("</conditionalFormatting>\n")
+		}
+	}
+	b.WriteString("</worksheet>\n")
+	return b.Bytes()
+}
+
+// xlsxRow writes a row of inline strings, of the style of the given index in xlsxStyles.
+func xlsxRow(b *bytes.Buffer, n int, cells []string, style int) {
+	fmt.Fprintf(b, `<row r="%d">`, n)
+	for i, c := range cells {
+		if c == "" {
+			continue
+		}
+		fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, xlsxCell(i, n), style, xmlEscape(c))
+	}
+	b.WriteString("</row>\n")
+}
+
+// xlsxCell returns the reference of the cell at the column index and row number, e.g. B3. The
+// matrices have less than 27 columns.
+func xlsxCell(column, row int) string {
+	return string(rune('A'+column)) + strconv.Itoa(row)
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/daedaleanai/reqtraq/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportXLSX(t *testing.T) {
+	sys := &Req{ID: "REQ-0-TEST-SYS-001", Level: config.SYSTEM, Path: "certdocs/0-TEST-100-ORD.md", Position: 1, Title: "Fly & land"}
+	high1 := &Req{ID: "REQ-0-TEST-SWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 2,
+		Title: "Fly", ParentIds: []string{sys.ID}}
+	high2 := &Req{ID: "REQ-0-TEST-SWH-002", Level: config.HIGH, Path: "certdocs/0-TEST-211-SRD.md", Position: 1,
+		Title: "Land", ParentIds: []string{sys.ID}}
+	low := &Req{ID: "REQ-0-TEST-SWL-001", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 1,
+		Title: "Climb", ParentIds: []string{high1.ID}}
+	orphan := &Req{ID: "REQ-0-TEST-SWL-002", Level: config.LOW, Path: "certdocs/0-TEST-212-SDD.md", Position: 2, Title: "Descend"}
+	a := &Req{ID: "code/a.go", Level: config.CODE, Path: "/repo/code/a.go", FileHash: "sha1:aa", ParentIds: []string{low.ID}}
+	rg := reqGraph{sys.ID: sys, high1.ID: high1, high2.ID: high2, low.ID: low, a.Path: a}
+	assert.NoError(t, rg.Resolve())
+	// Added once resolved, as it has no parents.
+	rg[orphan.ID] = orphan
+
+	var w bytes.Buffer
+	assert.NoError(t, rg.Export("xlsx", &w))
+	zr, err := zip.NewReader(bytes.NewReader(w.Bytes()), int64(w.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		b, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		r.Close()
+		parts[f.Name] = string(b)
+		// All the parts are well-formed.
+		d := xml.NewDecoder(bytes.NewReader(b))
+		for {
+			if _, err := d.Token(); err != nil {
+				assert.Equal(t, io.EOF, err, f.Name)
+				break
+			}
+		}
+	}
+	assert.Len(t, parts, 8)
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		assert.Contains(t, parts, name)
+	}
+
+	// No hardware sheets without hardware requirements.
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="SYS-SWH" sheetId="1" r:id="rId1"/>
+<sheet name="SWH-SWL" sheetId="2" r:id="rId2"/>
+<sheet name="SWL-Code" sheetId="3" r:id="rId3"/>
+</sheets>`)
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	assert.Contains(t, sheet, `<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">SYS</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="F1" s="1" t="inlineStr"><is><t xml:space="preserve">SWH Status</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="B2" s="0" t="inlineStr"><is><t xml:space="preserve">Fly &amp; land</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="D2" s="0" t="inlineStr"><is><t xml:space="preserve">REQ-0-TEST-SWH-002</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="D3" s="0" t="inlineStr"><is><t xml:space="preserve">REQ-0-TEST-SWH-001</t></is></c>`)
+	assert.Contains(t, sheet, `<autoFilter ref="A1:F3"/>`)
+	assert.Contains(t, sheet, `<conditionalFormatting sqref="C2:C3">`)
+	assert.Contains(t, sheet, `<conditionalFormatting sqref="F2:F3">
+<cfRule type="cellIs" dxfId="0" priority="4" operator="equal"><formula>"NOT STARTED"</formula></cfRule>
+<cfRule type="cellIs" dxfId="1" priority="5" operator="equal"><formula>"STARTED"</formula></cfRule>
+<cfRule type="cellIs" dxfId="2" priority="6" operator="equal"><formula>"COMPLETED"</formula></cfRule>
+</conditionalFormatting>`)
+
+	// The parents without children and the children without parents show as gaps.
+	sheet = parts["xl/worksheets/sheet3.xml"]
+	assert.Contains(t, sheet, `<row r="2"><c r="A2" s="0" t="inlineStr"><is><t xml:space="preserve">REQ-0-TEST-SWL-001</t></is></c>`+
+		`<c r="B2" s="0" t="inlineStr"><is><t xml:space="preserve">Climb</t></is></c>`+
+		`<c r="C2" s="0" t="inlineStr"><is><t xml:space="preserve">COMPLETED</t></is></c>`+
+		`<c r="D2" s="0" t="inlineStr"><is><t xml:space="preserve">code/a.go</t></is></c>`+
+		`<c r="E2" s="0" t="inlineStr"><is><t xml:space="preserve">sha1:aa</t></is></c></row>`)
+	assert.Contains(t, sheet, `<row r="3"><c r="A3" s="0" t="inlineStr"><is><t xml:space="preserve">REQ-0-TEST-SWL-002</t></is></c>`+
+		`<c r="B3" s="0" t="inlineStr"><is><t xml:space="preserve">Descend</t></is></c>`+
+		`<c r="C3" s="0" t="inlineStr"><is><t xml:space="preserve">NOT STARTED</t></is></c></row>`)
+	assert.Contains(t, parts["xl/worksheets/sheet2.xml"], `<row r="4"><c r="D4" s="0" t="inlineStr"><is><t xml:space="preserve">REQ-0-TEST-SWL-002</t></is></c>`)
+
+	// The hardware sheets, once there are hardware requirements.
+	hw := &Req{ID: "REQ-0-TEST-HWH-001", Level: config.HIGH, Path: "certdocs/0-TEST-311-HRD.md", Position: 1,
+		Title: "Wing", ParentIds: []string{sys.ID}}
+	rg[hw.ID] = hw
+	sys.Children = append(sys.Children, hw)
+	hw.Parents = []*Req{sys}
+	w.Reset()
+	assert.NoError(t, rg.Export("xlsx", &w))
+	zr, err = zip.NewReader(bytes.NewReader(w.Bytes()), int64(w.Len()))
+	if assert.NoError(t, err) {
+		assert.Len(t, zr.File, 11)
+	}
+}